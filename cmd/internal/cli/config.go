@@ -20,19 +20,300 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/logging"
 )
 
 type Config struct {
-	DebugAWS      bool   `json:"-"`
-	Store         string `json:"object_store"`
-	Region        string `json:"aws_region"`
-	ECRRepository string `json:"ecr_repository"`
-	IAMRole       string `json:"iam_role"`
-	S3Concurrency int    `json:"s3_concurrency"`
-	Honeycomb     struct {
+	DebugAWS         bool                `json:"-"`
+	Store            string              `json:"object_store"`
+	Region           string              `json:"aws_region"`
+	ECRRepository    string              `json:"ecr_repository"`
+	IAMRole          string              `json:"iam_role"`
+	S3Concurrency    int                 `json:"s3_concurrency"`
+	Architecture     string              `json:"architecture,omitempty"`
+	CompressionLevel int                 `json:"compression_level,omitempty"`
+	FailoverRegions  []string            `json:"failover_regions,omitempty"`
+	S3Endpoint       string              `json:"s3_endpoint,omitempty"`
+	S3PathStyle      bool                `json:"s3_path_style,omitempty"`
+	S3SkipVerify     bool                `json:"s3_insecure_skip_verify,omitempty"`
+	LocalFunctions   map[string][]string `json:"local_functions,omitempty"`
+	WarmPoolSize     int64               `json:"warm_pool_size,omitempty"`
+	// If set, encrypt everything we write to the store with this
+	// key; see encstore.LoadKey
+	StoreKey string `json:"store_key,omitempty"`
+	Retry    struct {
+		MaxAttempts int    `json:"max_attempts,omitempty"`
+		Backoff     string `json:"backoff,omitempty"`
+		MaxBackoff  string `json:"max_backoff,omitempty"`
+	} `json:"retry,omitempty"`
+	// Overrides for individual prices used to estimate costs
+	Pricing daemon.Pricing `json:"pricing,omitempty"`
+	Budget  daemon.Budget  `json:"budget,omitempty"`
+	// Overrides for the daemon's concurrency limits; a negative
+	// limit is unlimited
+	Schedule daemon.Schedule `json:"schedule,omitempty"`
+	// Cache objects we download from an S3 store on local disk, in
+	// Path (default: ~/.llama/cache), evicting the least recently
+	// used objects beyond SizeMB
+	DiskCache struct {
+		Path   string `json:"path,omitempty"`
+		SizeMB uint64 `json:"size_mb,omitempty"`
+	} `json:"disk_cache,omitempty"`
+	// Attach an EFS filesystem to functions we create, and cache
+	// objects on it that are shared by every instance, up to
+	// SizeMB
+	EFS struct {
+		AccessPoint    string   `json:"access_point,omitempty"`
+		MountPath      string   `json:"mount_path,omitempty"`
+		SizeMB         uint64   `json:"size_mb,omitempty"`
+		Subnets        []string `json:"subnets,omitempty"`
+		SecurityGroups []string `json:"security_groups,omitempty"`
+	} `json:"efs,omitempty"`
+	Honeycomb struct {
 		APIKey  string `json:"api_key,omitempty"`
 		Dataset string `json:"dataset,omitempty"`
 	} `json:"honeycomb,omitempty"`
+	// Tuning for slow or distant links to S3: use Transfer
+	// Acceleration, and upload large objects in parts of
+	// S3PartSizeMB, S3PartConcurrency at a time
+	S3Accelerate      bool   `json:"s3_accelerate,omitempty"`
+	S3PartSizeMB      int64  `json:"s3_part_size_mb,omitempty"`
+	S3PartConcurrency int    `json:"s3_part_concurrency,omitempty"`
+	S3RequestTimeout  string `json:"s3_request_timeout,omitempty"`
+	// The default log format for llama commands, and the format
+	// for functions' logs: "text" or "json"
+	LogFormat string `json:"log_format,omitempty"`
+
+	// How long to remember, across restarts, the objects we have
+	// uploaded to an S3 store (default: 7 days); "0" disables the
+	// record
+	UploadIndexTTL string `json:"upload_index_ttl,omitempty"`
+
+	// Enable AWS X-Ray active tracing on functions we create, and
+	// have the runtime send its spans to X-Ray
+	XRayTracing bool `json:"xray_tracing,omitempty"`
+
+	// Regular expressions for the names of local environment
+	// variables to pass to every job we invoke from the command
+	// line
+	EnvPassthrough []string `json:"env_passthrough,omitempty"`
+
+	// The S3 storage class for new objects, such as
+	// INTELLIGENT_TIERING
+	S3StorageClass string `json:"s3_storage_class,omitempty"`
+	// Expire objects in an S3 store this many days after they
+	// were last written; `llama bootstrap` sets the bucket's
+	// lifecycle rules to match. See S3RefreshAge.
+	S3ExpireDays int `json:"s3_expire_days,omitempty"`
+
+	// Pack up to MaxJobs invocations of the same function that
+	// arrive within Window of each other into one Lambda request;
+	// see daemon.Packing
+	Packing struct {
+		MaxJobs     int    `json:"max_jobs,omitempty"`
+		Window      string `json:"window,omitempty"`
+		Parallelism int    `json:"parallelism,omitempty"`
+	} `json:"packing,omitempty"`
+
+	// Don't check the outputs we fetch against the checksums the
+	// runtime records for them
+	SkipOutputChecksums bool `json:"skip_output_checksums,omitempty"`
+
+	// A DynamoDB table, in aws_region, in which to index the
+	// objects in an S3 store; see s3store.Options.IndexTable
+	StoreIndexTable string `json:"store_index_table,omitempty"`
+
+	// The hash new objects are named by, "blake2b" or "blake3";
+	// see s3store.Options.Hash
+	StoreHash string `json:"store_hash,omitempty"`
+
+	// Carry files of up to this many bytes in invocations
+	// themselves, rather than through the object store; 0 selects
+	// the default, and -1 disables inlining. See InlinePolicy.
+	InlineLimit int `json:"inline_limit,omitempty"`
+
+	// How to share the result cache with other users of the
+	// store: the namespace to record results in, and namespaces,
+	// such as one CI populates, to only read from
+	Cache daemon.CacheConfig `json:"cache,omitempty"`
+
+	// Invoke the functions in URLs through those HTTPS endpoints
+	// -- Lambda Function URLs, or an API Gateway or CDN in front
+	// of them -- rather than the Lambda API. See
+	// llama.FunctionURLs.
+	FunctionURLs struct {
+		URLs      map[string]string `json:"urls,omitempty"`
+		Auth      string            `json:"auth,omitempty"`
+		TokenFile string            `json:"token_file,omitempty"`
+	} `json:"function_urls,omitempty"`
+
+	// The tools llamatool runs remotely, by name; see ToolSpec
+	Tools map[string]ToolSpec `json:"tools,omitempty"`
+}
+
+// S3RefreshAge returns the age past which we rewrite objects that
+// are still in use, so that they don't expire under S3ExpireDays, or
+// zero if objects don't expire. Result-cache entries, which refer to
+// objects up to this old, expire at the same age.
+func (c *Config) S3RefreshAge() time.Duration {
+	return time.Duration(c.S3ExpireDays) * 24 * time.Hour / 2
+}
+
+// RetryPolicy returns the configured retry policy for invocations,
+// starting from llama.DefaultRetryPolicy.
+func (c *Config) RetryPolicy() (llama.RetryPolicy, error) {
+	policy := llama.DefaultRetryPolicy
+	if c.Retry.MaxAttempts != 0 {
+		policy.MaxAttempts = c.Retry.MaxAttempts
+	}
+	var err error
+	if c.Retry.Backoff != "" {
+		if policy.Backoff, err = time.ParseDuration(c.Retry.Backoff); err != nil {
+			return policy, fmt.Errorf("retry.backoff: %w", err)
+		}
+	}
+	if c.Retry.MaxBackoff != "" {
+		if policy.MaxBackoff, err = time.ParseDuration(c.Retry.MaxBackoff); err != nil {
+			return policy, fmt.Errorf("retry.max_backoff: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// Prices returns the prices to estimate costs with: the list prices
+// for our architecture, with any overrides from the config.
+func (c *Config) Prices() daemon.Pricing {
+	p := daemon.PricingFor(c.Architecture)
+	override := func(dst *float64, v float64) {
+		if v != 0 {
+			*dst = v
+		}
+	}
+	override(&p.LambdaGBSecond, c.Pricing.LambdaGBSecond)
+	override(&p.LambdaRequest, c.Pricing.LambdaRequest)
+	override(&p.S3Write, c.Pricing.S3Write)
+	override(&p.S3Read, c.Pricing.S3Read)
+	override(&p.S3XferOutGB, c.Pricing.S3XferOutGB)
+	return p
+}
+
+// Scheduling returns the daemon's concurrency limits:
+// daemon.DefaultSchedule, with any overrides from the config.
+func (c *Config) Scheduling() daemon.Schedule {
+	s := daemon.DefaultSchedule
+	override := func(dst *int64, v int64) {
+		if v != 0 {
+			*dst = v
+		}
+	}
+	override(&s.Concurrency, c.Schedule.Concurrency)
+	override(&s.Interactive, c.Schedule.Interactive)
+	override(&s.Batch, c.Schedule.Batch)
+	s.Fixed = c.Schedule.Fixed
+	return s
+}
+
+// InlinePolicy returns which small files the daemon carries in
+// invocations themselves: files.DefaultInline, with the limit from
+// the config.
+func (c *Config) InlinePolicy() files.Inline {
+	p := files.DefaultInline
+	if c.InlineLimit < 0 {
+		return files.Inline{}
+	}
+	if c.InlineLimit != 0 {
+		p.Limit = c.InlineLimit
+	}
+	return p
+}
+
+// PackingPolicy returns how the daemon should pack invocations
+// together.
+func (c *Config) PackingPolicy() (daemon.Packing, error) {
+	p := daemon.Packing{
+		MaxJobs:     c.Packing.MaxJobs,
+		Parallelism: c.Packing.Parallelism,
+	}
+	if c.Packing.Window != "" {
+		var err error
+		if p.Window, err = time.ParseDuration(c.Packing.Window); err != nil {
+			return p, fmt.Errorf("packing.window: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// URLInvoker returns how to invoke functions through their HTTPS
+// endpoints, signing requests with the credentials in `sess`, or nil
+// if none are configured.
+func (c *Config) URLInvoker(sess *session.Session) (*llama.FunctionURLs, error) {
+	if len(c.FunctionURLs.URLs) == 0 {
+		return nil, nil
+	}
+	urls := c.functionURLs()
+	urls.Credentials = sess.Config.Credentials
+	urls.Region = aws.StringValue(sess.Config.Region)
+	if err := urls.Validate(); err != nil {
+		return nil, fmt.Errorf("function_urls: %w", err)
+	}
+	return urls, nil
+}
+
+func (c *Config) functionURLs() *llama.FunctionURLs {
+	return &llama.FunctionURLs{
+		URLs:      c.FunctionURLs.URLs,
+		Auth:      c.FunctionURLs.Auth,
+		TokenFile: c.FunctionURLs.TokenFile,
+	}
+}
+
+// Validate checks what we can of the config without talking to AWS,
+// and reports the first problem it finds.
+func (c *Config) Validate() error {
+	if _, err := c.RetryPolicy(); err != nil {
+		return err
+	}
+	if _, err := c.PackingPolicy(); err != nil {
+		return err
+	}
+	if err := c.Cache.Validate(); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	if err := c.functionURLs().Validate(); err != nil {
+		return fmt.Errorf("function_urls: %w", err)
+	}
+	for key, val := range map[string]string{
+		"s3_request_timeout": c.S3RequestTimeout,
+		"upload_index_ttl":   c.UploadIndexTTL,
+	} {
+		if val == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(val); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	switch c.Architecture {
+	case "", lambda.ArchitectureX8664, lambda.ArchitectureArm64:
+	default:
+		return fmt.Errorf("architecture: unknown architecture %q (want %s or %s)",
+			c.Architecture, lambda.ArchitectureX8664, lambda.ArchitectureArm64)
+	}
+	switch c.LogFormat {
+	case "", logging.FormatText, logging.FormatJSON:
+	default:
+		return fmt.Errorf("log_format: unknown log format %q (want %s or %s)",
+			c.LogFormat, logging.FormatText, logging.FormatJSON)
+	}
+	return nil
 }
 
 func WriteConfig(cfg *Config, configPath string) error {
@@ -15,15 +15,23 @@
 package cli
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"path"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/mitchellh/go-homedir"
 	"github.com/nelhage/llama/store"
+	_ "github.com/nelhage/llama/store/azstore"
+	"github.com/nelhage/llama/store/encstore"
+	_ "github.com/nelhage/llama/store/filestore"
+	_ "github.com/nelhage/llama/store/gcsstore"
 	"github.com/nelhage/llama/store/s3store"
 )
 
@@ -34,6 +42,13 @@ type GlobalState struct {
 	session *session.Session
 
 	Config *Config
+	// The project configuration for the working directory, if
+	// any
+	Project *ProjectConfig
+	// NoTouch opens the store without recording reads as uses, for
+	// commands like gc which read objects without using them. It
+	// must be set before the store is first opened.
+	NoTouch bool
 
 	store store.Store
 }
@@ -107,18 +122,82 @@ func (g *GlobalState) Store() (store.Store, error) {
 	if g.store != nil {
 		return g.store, nil
 	}
+	st, err := g.openStoreLocked()
+	if err != nil {
+		return nil, err
+	}
+	if g.Config.StoreKey != "" {
+		sess, err := g.sessionLocked()
+		if err != nil {
+			return nil, err
+		}
+		key, err := encstore.LoadKey(context.Background(), sess, g.Config.StoreKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading store key: %w", err)
+		}
+		if st, err = encstore.New(st, key); err != nil {
+			return nil, err
+		}
+	}
+	g.store = st
+	return g.store, nil
+}
+
+func (g *GlobalState) openStoreLocked() (store.Store, error) {
+	if !strings.HasPrefix(g.Config.Store, "s3:") {
+		return store.Open(context.Background(), g.Config.Store)
+	}
 	sess, err := g.sessionLocked()
 	if err != nil {
 		return nil, err
 	}
 	opts := s3store.Options{
 		DisableHeadCheck: true,
+		CompressionLevel: g.Config.CompressionLevel,
+
+		Endpoint:           g.Config.S3Endpoint,
+		ForcePathStyle:     g.Config.S3PathStyle,
+		InsecureSkipVerify: g.Config.S3SkipVerify,
+
+		Accelerate:      g.Config.S3Accelerate,
+		PartSize:        g.Config.S3PartSizeMB << 20,
+		PartConcurrency: g.Config.S3PartConcurrency,
 	}
-	g.store, err = s3store.FromSessionAndOptions(sess, g.Config.Store, opts)
-	if err != nil {
-		return nil, err
+	if g.Config.S3RequestTimeout != "" {
+		if opts.RequestTimeout, err = time.ParseDuration(g.Config.S3RequestTimeout); err != nil {
+			return nil, fmt.Errorf("s3_request_timeout: %w", err)
+		}
 	}
-	return g.store, nil
+	opts.UploadIndexPath = UploadIndexPath(g.Config.Store)
+	if g.Config.UploadIndexTTL != "" {
+		if opts.UploadIndexTTL, err = time.ParseDuration(g.Config.UploadIndexTTL); err != nil {
+			return nil, fmt.Errorf("upload_index_ttl: %w", err)
+		}
+		if opts.UploadIndexTTL <= 0 {
+			opts.UploadIndexPath = ""
+		}
+	}
+	opts.StorageClass = g.Config.S3StorageClass
+	if g.NoTouch {
+		opts.TouchAge = -1
+	}
+	opts.RefreshAge = g.Config.S3RefreshAge()
+	opts.IndexTable = g.Config.StoreIndexTable
+	opts.IndexRegion = g.Config.Region
+	opts.Hash = g.Config.StoreHash
+	if g.Config.DiskCache.SizeMB > 0 {
+		opts.DiskCachePath = g.Config.DiskCache.Path
+		if opts.DiskCachePath == "" {
+			opts.DiskCachePath = CacheDir()
+		}
+		opts.DiskCacheBytes = g.Config.DiskCache.SizeMB << 20
+	}
+	if g.Config.StoreKey != "" {
+		// Encrypted objects don't compress; encstore compresses
+		// them before encrypting instead
+		opts.CompressionLevel = -1
+	}
+	return s3store.FromSessionAndOptions(sess, g.Config.Store, opts)
 }
 
 func (g *GlobalState) MustStore() store.Store {
@@ -15,9 +15,11 @@
 package cli
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
-	"path"
+	"path/filepath"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -30,13 +32,24 @@ func ConfigDir() string {
 	if err != nil {
 		log.Fatalf("Cannot find homedir: %s", err.Error())
 	}
-	return path.Join(dir, ".llama")
+	return filepath.Join(dir, ".llama")
 }
 
 func ConfigPath() string {
-	return path.Join(ConfigDir(), "llama.json")
+	return filepath.Join(ConfigDir(), "llama.json")
+}
+
+func CacheDir() string {
+	return filepath.Join(ConfigDir(), "cache")
 }
 
 func SocketPath() string {
-	return path.Join(ConfigDir(), "llama.sock")
+	return filepath.Join(ConfigDir(), "llama.sock")
+}
+
+// UploadIndexPath returns the path of the index of objects we have
+// uploaded to the given store.
+func UploadIndexPath(store string) string {
+	sum := sha256.Sum256([]byte(store))
+	return filepath.Join(ConfigDir(), "uploaded", hex.EncodeToString(sum[:8]))
 }
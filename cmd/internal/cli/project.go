@@ -0,0 +1,126 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectConfigName is the name of the per-project configuration
+// file, which we look for in the working directory and each of its
+// parents.
+const ProjectConfigName = ".llamarc"
+
+// A ProjectConfig holds settings for one project, which override the
+// global configuration for commands run inside it.
+type ProjectConfig struct {
+	// The file the settings were read from
+	Path string `json:"-"`
+	// Settings for llamacc, named like its LLAMACC_ environment
+	// variables, without the prefix; the environment takes
+	// precedence.
+	Llamacc map[string]json.RawMessage `json:"llamacc,omitempty"`
+	// The default number of concurrent jobs for `llama xargs`
+	Concurrency int `json:"concurrency,omitempty"`
+	// The function `llama run` runs commands in, by default
+	RunFunction string `json:"run_function,omitempty"`
+	// The tools llamatool runs remotely, which override those of
+	// the same name in the global config
+	Tools map[string]ToolSpec `json:"tools,omitempty"`
+}
+
+// FindProjectConfig reads the nearest ProjectConfigName file in `dir`
+// or one of its parents. It returns nil if there is none.
+func FindProjectConfig(dir string) (*ProjectConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigName)
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			cfg := ProjectConfig{Path: path}
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return &cfg, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LlamaccEnv returns the llamacc settings as environment variables,
+// LLAMACC_KEY=VALUE, with keys upper-cased. Strings and numbers are
+// used as-is; true is "1" and false is empty, and lists of strings
+// are joined with commas.
+func (p *ProjectConfig) LlamaccEnv() ([]string, error) {
+	var env []string
+	for key, raw := range p.Llamacc {
+		val, err := envValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: llamacc.%s: %w", p.Path, key, err)
+		}
+		env = append(env, "LLAMACC_"+strings.ToUpper(key)+"="+val)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+func envValue(raw json.RawMessage) (string, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "", nil
+	case []interface{}:
+		var parts []string
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return "", errors.New("expected a list of strings")
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", errors.New("expected a string, number, boolean, or list of strings")
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "src", "lib")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+
+	proj, err := FindProjectConfig(sub)
+	require.NoError(t, err)
+	if proj != nil {
+		// Someone has a .llamarc above the temp dir
+		assert.NotEqual(t, dir, filepath.Dir(proj.Path))
+	}
+
+	path := filepath.Join(dir, ProjectConfigName)
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+  "concurrency": 200,
+  "llamacc": {
+    "function": "gcc-12",
+    "fallback": true,
+    "verbose": false,
+    "memory": 3008,
+    "path_map": ["/opt/sdk=/sdk", "/opt/vendor=upload"]
+  }
+}`), 0644))
+
+	proj, err = FindProjectConfig(sub)
+	require.NoError(t, err)
+	require.NotNil(t, proj)
+	assert.Equal(t, path, proj.Path)
+	assert.Equal(t, 200, proj.Concurrency)
+	env, err := proj.LlamaccEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"LLAMACC_FALLBACK=1",
+		"LLAMACC_FUNCTION=gcc-12",
+		"LLAMACC_MEMORY=3008",
+		"LLAMACC_PATH_MAP=/opt/sdk=/sdk,/opt/vendor=upload",
+		"LLAMACC_VERBOSE=",
+	}, env)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"llamacc": {"function": {"name": "gcc"}}}`), 0644))
+	proj, err = FindProjectConfig(sub)
+	require.NoError(t, err)
+	_, err = proj.LlamaccEnv()
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{`), 0644))
+	_, err = FindProjectConfig(sub)
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A Setting is one value in the config, named by the dotted path of
+// its keys in llama.json, such as "retry.max_attempts".
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// GetSetting returns the setting `key`, formatted as SetSetting
+// parses it. Naming a group of settings, such as "retry", returns
+// them all as a JSON object.
+func (c *Config) GetSetting(key string) (string, error) {
+	v, err := configField(c, key)
+	if err != nil {
+		return "", err
+	}
+	return formatSetting(v)
+}
+
+// SetSetting sets `key` to `value`. Strings are taken as they are,
+// and other settings parsed as JSON: a number, `true`, `["a", "b"]`
+// or, for a group of settings, an object. An empty value resets the
+// setting to its default.
+func (c *Config) SetSetting(key, value string) error {
+	v, err := configField(c, key)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+	case value == "":
+		v.Set(reflect.Zero(v.Type()))
+	default:
+		parsed := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		v.Set(parsed.Elem())
+	}
+	return nil
+}
+
+// Settings lists every setting that isn't at its default, in the
+// order Config declares them.
+func (c *Config) Settings() ([]Setting, error) {
+	var out []Setting
+	var walk func(prefix string, v reflect.Value) error
+	walk = func(prefix string, v reflect.Value) error {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := jsonName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			key := prefix + name
+			f := v.Field(i)
+			if f.Kind() == reflect.Struct {
+				if err := walk(key+".", f); err != nil {
+					return err
+				}
+				continue
+			}
+			if f.IsZero() {
+				continue
+			}
+			val, err := formatSetting(f)
+			if err != nil {
+				return err
+			}
+			out = append(out, Setting{Key: key, Value: val})
+		}
+		return nil
+	}
+	if err := walk("", reflect.ValueOf(c).Elem()); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// configField finds the field of `c` named by the dotted path `key`.
+func configField(c *Config, key string) (reflect.Value, error) {
+	v := reflect.ValueOf(c).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown setting %q", key)
+		}
+		t := v.Type()
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			if jsonName(t.Field(i)) == part {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return v, nil
+}
+
+// jsonName returns the key a field is stored under in llama.json, or
+// "" if it isn't stored.
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func formatSetting(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettings(t *testing.T) {
+	var cfg Config
+	require.NoError(t, cfg.SetSetting("object_store", "s3://bucket/llama"))
+	require.NoError(t, cfg.SetSetting("retry.max_attempts", "6"))
+	require.NoError(t, cfg.SetSetting("s3_path_style", "true"))
+	require.NoError(t, cfg.SetSetting("failover_regions", `["us-east-2", "us-west-1"]`))
+	require.NoError(t, cfg.SetSetting("cache.policy", "read-only"))
+	require.NoError(t, cfg.SetSetting("function_urls.urls", `{"gcc": "https://x.example.com"}`))
+
+	assert.Equal(t, "s3://bucket/llama", cfg.Store)
+	assert.Equal(t, 6, cfg.Retry.MaxAttempts)
+	assert.True(t, cfg.S3PathStyle)
+	assert.Equal(t, []string{"us-east-2", "us-west-1"}, cfg.FailoverRegions)
+	assert.Equal(t, daemon.CacheReadOnly, cfg.Cache.Policy)
+	assert.Equal(t, "https://x.example.com", cfg.FunctionURLs.URLs["gcc"])
+
+	got, err := cfg.GetSetting("retry.max_attempts")
+	require.NoError(t, err)
+	assert.Equal(t, "6", got)
+	got, err = cfg.GetSetting("retry")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"max_attempts": 6}`, got)
+	got, err = cfg.GetSetting("object_store")
+	require.NoError(t, err)
+	assert.Equal(t, "s3://bucket/llama", got)
+
+	settings, err := cfg.Settings()
+	require.NoError(t, err)
+	assert.Equal(t, []Setting{
+		{"object_store", "s3://bucket/llama"},
+		{"failover_regions", `["us-east-2","us-west-1"]`},
+		{"s3_path_style", "true"},
+		{"retry.max_attempts", "6"},
+		{"cache.policy", "read-only"},
+		{"function_urls.urls", `{"gcc":"https://x.example.com"}`},
+	}, settings)
+
+	require.NoError(t, cfg.SetSetting("retry.max_attempts", ""))
+	assert.Equal(t, 0, cfg.Retry.MaxAttempts)
+
+	assert.Error(t, cfg.SetSetting("retry.max_attempts", "many"))
+	assert.Error(t, cfg.SetSetting("no_such_setting", "1"))
+	assert.Error(t, cfg.SetSetting("object_store.bucket", "x"))
+	_, err = cfg.GetSetting("DebugAWS")
+	assert.Error(t, err, "unstored fields aren't settings")
+}
+
+func TestValidate(t *testing.T) {
+	var cfg Config
+	assert.NoError(t, cfg.Validate())
+
+	for _, bad := range []func(*Config){
+		func(c *Config) { c.Retry.Backoff = "soon" },
+		func(c *Config) { c.Packing.Window = "10" },
+		func(c *Config) { c.Cache.Namespace = "../ci" },
+		func(c *Config) { c.FunctionURLs.Auth = "kerberos" },
+		func(c *Config) { c.UploadIndexTTL = "a week" },
+		func(c *Config) { c.Architecture = "riscv" },
+		func(c *Config) { c.LogFormat = "xml" },
+	} {
+		cfg := Config{}
+		bad(&cfg)
+		assert.Error(t, cfg.Validate(), "%+v", cfg)
+	}
+}
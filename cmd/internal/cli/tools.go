@@ -0,0 +1,60 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+// A ToolSpec describes, for llamatool, which of a tool's flags name
+// the files it reads and writes, so that code generators like protoc,
+// moc or bison can run remotely without code of their own. Each flag
+// is matched given separately ("-o FILE"), with an "=" ("--out=FILE"),
+// or, for single-letter flags, joined to its value ("-oFILE").
+// Arguments which aren't flags and name an existing file are inputs.
+type ToolSpec struct {
+	// The function to run the tool in, whose image must have the
+	// tool installed unless Upload is set
+	Function string `json:"function,omitempty"`
+	// Upload the local binary and run that, for tools which are
+	// statically linked
+	Upload bool `json:"upload,omitempty"`
+	// Flags naming files the tool reads, and directories it
+	// searches, which we upload as they are
+	Inputs    []string `json:"inputs,omitempty"`
+	InputDirs []string `json:"input_dirs,omitempty"`
+	// Flags naming files the tool writes, and directories it
+	// writes files into
+	Outputs    []string `json:"outputs,omitempty"`
+	OutputDirs []string `json:"output_dirs,omitempty"`
+	// Fetch every file the tool creates or modifies in the
+	// working directory, for tools which write files their
+	// command line doesn't name, like `bison -d`
+	Changes bool `json:"changes,omitempty"`
+}
+
+// ToolSpecs returns the tools llamatool knows how to run: those in
+// the global config, and in the project config, which takes
+// precedence.
+func ToolSpecs(cfg *Config, project *ProjectConfig) map[string]ToolSpec {
+	out := make(map[string]ToolSpec)
+	if cfg != nil {
+		for name, spec := range cfg.Tools {
+			out[name] = spec
+		}
+	}
+	if project != nil {
+		for name, spec := range project.Tools {
+			out[name] = spec
+		}
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolSpecs(t *testing.T) {
+	cfg := &Config{Tools: map[string]ToolSpec{
+		"protoc": {Function: "global", InputDirs: []string{"-I"}},
+		"moc":    {Outputs: []string{"-o"}},
+	}}
+	project := &ProjectConfig{Tools: map[string]ToolSpec{
+		"protoc": {Function: "project"},
+	}}
+
+	specs := ToolSpecs(cfg, project)
+	assert.Equal(t, ToolSpec{Function: "project"}, specs["protoc"])
+	assert.Equal(t, ToolSpec{Outputs: []string{"-o"}}, specs["moc"])
+	assert.Len(t, ToolSpecs(cfg, nil), 2)
+	assert.Empty(t, ToolSpecs(nil, nil))
+}
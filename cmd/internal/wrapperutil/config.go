@@ -0,0 +1,101 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrapperutil
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration every wrapper reads from its
+// environment variables
+type Config struct {
+	Verbose bool
+	Local   bool
+	Cache   bool
+	// If the remote invocation fails, run the command locally
+	// instead of failing the build
+	Fallback bool
+	// The function to run commands in
+	Function string
+
+	// Minimum function memory (in MB) and timeout
+	Memory  int64
+	Timeout time.Duration
+}
+
+// Set sets the setting named by the environment variable `key`, less
+// its prefix, to `val`. It reports whether `key` names a setting.
+func (c *Config) Set(key, val string) (bool, error) {
+	switch key {
+	case "VERBOSE":
+		c.Verbose = val != ""
+	case "LOCAL":
+		c.Local = val != ""
+	case "CACHE":
+		c.Cache = val != ""
+	case "FALLBACK":
+		c.Fallback = val != ""
+	case "FUNCTION":
+		c.Function = val
+	case "MEMORY":
+		mem, err := strconv.ParseInt(val, 10, 64)
+		c.Memory = mem
+		return true, err
+	case "TIMEOUT":
+		timeout, err := time.ParseDuration(val)
+		c.Timeout = timeout
+		return true, err
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// ParseEnv calls `set` with the name, less `prefix`, and value of
+// each variable in `env` whose name starts with `prefix`, such as
+// "LLAMAGO_". It logs those `set` reports it doesn't know, or fails
+// to parse.
+func ParseEnv(prefix string, env []string, set func(key, val string) (bool, error)) {
+	name := strings.ToLower(strings.TrimSuffix(prefix, "_"))
+	for _, ev := range env {
+		if !strings.HasPrefix(ev, prefix) {
+			continue
+		}
+		var eq = strings.IndexRune(ev, '=')
+		if eq < 0 {
+			panic("env var missing `=`?")
+		}
+		ok, err := set(ev[len(prefix):eq], ev[eq+1:])
+		if err != nil {
+			log.Printf("%s: bad %s: %s", name, ev, err.Error())
+		} else if !ok {
+			log.Printf("%s: unknown env var: %s", name, ev)
+		}
+	}
+}
+
+// SplitList splits a comma-separated list, dropping empty elements
+func SplitList(val string) []string {
+	var out []string
+	for _, elt := range strings.Split(val, ",") {
+		if elt = strings.TrimSpace(elt); elt != "" {
+			out = append(out, elt)
+		}
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrapperutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnv(t *testing.T) {
+	cfg := Config{Function: "gcc"}
+	var extra []string
+	ParseEnv("LLAMAX_", []string{
+		"PATH=/bin",
+		"LLAMAX_FALLBACK=1",
+		"LLAMAX_MEMORY=lots",
+		"LLAMAX_TIMEOUT=5m",
+		"LLAMAX_EXTRA=a, b,,c",
+		"LLAMAXY_FUNCTION=other",
+	}, func(key, val string) (bool, error) {
+		if key == "EXTRA" {
+			extra = SplitList(val)
+			return true, nil
+		}
+		return cfg.Set(key, val)
+	})
+	assert.Equal(t, Config{
+		Function: "gcc",
+		Fallback: true,
+		Timeout:  5 * time.Minute,
+	}, cfg)
+	assert.Equal(t, []string{"a", "b", "c"}, extra)
+}
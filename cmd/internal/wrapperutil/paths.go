@@ -0,0 +1,88 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wrapperutil holds what the compiler and tool wrappers --
+// llamatool, llamago, llamarustc and llamatest -- have in common:
+// mapping local paths to where their jobs find them remotely,
+// reading their configuration from the environment, and running
+// commands remotely, or locally when they can't be.
+package wrapperutil
+
+import (
+	"bytes"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/nelhage/llama/files"
+)
+
+// RemoteRoot is the directory, in a remote job's working directory,
+// under which we upload files at their local absolute paths
+const RemoteRoot = "_root"
+
+// ToAbs returns the absolute path of `local`, relative to `wd`
+func ToAbs(local, wd string) string {
+	if filepath.IsAbs(local) {
+		return filepath.Clean(local)
+	}
+	return filepath.Join(wd, local)
+}
+
+// ToRemote returns the remote path of `local`, relative to the
+// directory the invocation runs in
+func ToRemote(local, wd string) string {
+	return path.Join(RemoteRoot, files.RemotePath(ToAbs(local, wd)))
+}
+
+// Remap returns the mapping which uploads `local` to ToRemote(local)
+func Remap(local, wd string) files.Mapped {
+	return files.Mapped{
+		Local:  files.LocalFile{Path: ToAbs(local, wd)},
+		Remote: ToRemote(local, wd),
+	}
+}
+
+// ToRemoteRel returns the remote path of `local`, relative to the
+// remote copy of `wd`. Relative paths are unchanged; absolute ones
+// climb to the top of RemoteRoot and back down.
+func ToRemoteRel(local, wd string) string {
+	if !filepath.IsAbs(local) {
+		return filepath.ToSlash(filepath.Clean(local))
+	}
+	return RootPrefix(wd) + strings.TrimPrefix(files.RemotePath(local), "/")
+}
+
+// RootPrefix returns the relative path from the remote copy of `wd`
+// to the top of RemoteRoot
+func RootPrefix(wd string) string {
+	depth := 0
+	for _, elt := range strings.Split(files.RemotePath(wd), "/") {
+		if elt != "" {
+			depth++
+		}
+	}
+	return strings.Repeat("../", depth)
+}
+
+// Localize rewrites the remote paths in a command's output to their
+// local paths. Local paths on Windows hosts have drive letters which
+// the remote paths lack, so there we leave the output alone.
+func Localize(data []byte) []byte {
+	if runtime.GOOS == "windows" {
+		return data
+	}
+	return bytes.ReplaceAll(data, []byte(RemoteRoot+"/"), []byte("/"))
+}
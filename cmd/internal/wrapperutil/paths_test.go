@@ -0,0 +1,45 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrapperutil
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToRemote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("paths are Unix paths")
+	}
+	assert.Equal(t, "_root/src/gen/a.h", ToRemote("gen/a.h", "/src"))
+	assert.Equal(t, "_root/usr/include/a.h", ToRemote("/usr/include/../include/a.h", "/src"))
+
+	wd := "/home/me/src"
+	assert.Equal(t, "foo.c", ToRemoteRel("foo.c", wd))
+	assert.Equal(t, "../include/foo.h", ToRemoteRel("./../include/foo.h", wd))
+	assert.Equal(t, "../../../usr/include/foo.h", ToRemoteRel("/usr/include/foo.h", wd))
+	assert.Equal(t, "", RootPrefix("/"))
+}
+
+func TestLocalize(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("output is left alone on Windows")
+	}
+	assert.Equal(t,
+		`{"artifact":"/p/target/debug/deps/libfoo-0123.rmeta","emit":"metadata"}`,
+		string(Localize([]byte(`{"artifact":"_root/p/target/debug/deps/libfoo-0123.rmeta","emit":"metadata"}`))))
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrapperutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/server"
+)
+
+// InvokeError marks failures to run a command remotely at all, as
+// opposed to the command failing, which Config.Fallback recovers from
+// by running it locally.
+type InvokeError struct {
+	Err error
+}
+
+func (e *InvokeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvokeError) Unwrap() error {
+	return e.Err
+}
+
+// RunLocal runs `argv` locally, with our standard streams, and
+// returns its exit status
+func RunLocal(argv []string) (int, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var ex *exec.ExitError
+	if errors.As(err, &ex) {
+		return ex.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// Invoke runs `args` through the daemon, starting it if need be, and
+// returns the command's exit status. It copies the command's output
+// to ours, localizing the remote paths in its stderr, and in its
+// stdout too if `localizeStdout`.
+func Invoke(args *daemon.InvokeWithFilesArgs, localizeStdout bool) (int, error) {
+	client, err := server.DialWithAutostart(context.Background(), cli.SocketPath(), server.LlamaCCPath)
+	if err != nil {
+		return 0, &InvokeError{err}
+	}
+	defer client.Close()
+	out, err := client.InvokeWithFiles(args)
+	if err != nil {
+		return 0, &InvokeError{err}
+	}
+	if localizeStdout {
+		os.Stdout.Write(Localize(out.Stdout))
+	} else {
+		os.Stdout.Write(out.Stdout)
+	}
+	os.Stderr.Write(Localize(out.Stderr))
+	if out.InvokeErr != "" {
+		return 0, &InvokeError{fmt.Errorf("invoke: %s", out.InvokeErr)}
+	}
+	return out.ExitStatus, nil
+}
+
+// Main runs `argv` with `remote`, and exits with its status. If
+// `remote` fails with an InvokeError, we fail too, unless
+// cfg.Fallback is set; if it fails with any other error, which says
+// why the command can't run remotely, or cfg.Local is set, we run
+// `argv` locally instead. `name` names the wrapper, and with
+// "_LOCAL" appended, its variable which sets cfg.Local.
+func Main(name string, cfg *Config, argv []string, remote func() (int, error)) {
+	err := fmt.Errorf("%s_LOCAL set", strings.ToUpper(name))
+	if !cfg.Local {
+		var status int
+		status, err = remote()
+		var ie *InvokeError
+		if err == nil {
+			os.Exit(status)
+		} else if errors.As(err, &ie) && !cfg.Fallback {
+			fmt.Fprintf(os.Stderr, "Running %s: %s\n", name, err.Error())
+			os.Exit(1)
+		}
+	}
+	if cfg.Verbose {
+		log.Printf("[%s] running locally: %s (%q)", name, err.Error(), argv)
+	}
+
+	status, err := RunLocal(argv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Running %s locally: %s\n", argv[0], err.Error())
+		os.Exit(1)
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,605 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	protocol_files "github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+)
+
+const (
+	asyncQueuePrefix = "llama-async-"
+	// SQS limits a message, and a batch of messages, to 256KiB
+	maxMessageBytes = 256 * 1024
+	maxMessageBatch = 10
+	// Jobs which crash or time out the function this many times
+	// are moved to the dead-letter queue
+	maxReceiveCount = 3
+)
+
+// asyncQueueName returns the name of the SQS queue feeding `function`
+func asyncQueueName(function string) (string, error) {
+	name := asyncQueuePrefix + function
+	if len(name) > 80 {
+		return "", fmt.Errorf("queue name %q is longer than SQS allows", name)
+	}
+	return name, nil
+}
+
+// asyncVisibilityTimeout returns the visibility timeout for a queue
+// feeding a function with the given timeout. AWS recommends six times
+// the function's timeout; SQS allows at most 12 hours.
+func asyncVisibilityTimeout(timeout int64) int64 {
+	v := 6 * timeout
+	if v > 12*60*60 {
+		v = 12 * 60 * 60
+	}
+	return v
+}
+
+func queueAttr(svc *sqs.SQS, url, attr string) (string, error) {
+	out, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       &url,
+		AttributeNames: []*string{&attr},
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.Attributes[attr]), nil
+}
+
+// ensureQueue returns the URL of the named queue, creating it if it
+// doesn't exist, and sets its attributes.
+func ensureQueue(svc *sqs.SQS, name string, attrs map[string]*string) (string, error) {
+	got, err := svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: &name})
+	if err == nil {
+		_, err = svc.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+			QueueUrl:   got.QueueUrl,
+			Attributes: attrs,
+		})
+		return aws.StringValue(got.QueueUrl), err
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != sqs.ErrCodeQueueDoesNotExist {
+		return "", err
+	}
+	created, err := svc.CreateQueue(&sqs.CreateQueueInput{
+		QueueName:  &name,
+		Attributes: attrs,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(created.QueueUrl), nil
+}
+
+// ensureAsyncQueue sets up the SQS queue, and its dead-letter queue,
+// for asynchronous jobs on `function`, and connects it to the
+// function. It returns the queue's URL.
+func ensureAsyncQueue(sess *session.Session, function string) (string, error) {
+	name, err := asyncQueueName(function)
+	if err != nil {
+		return "", err
+	}
+	lam := lambda.New(sess)
+	cfg, err := lam.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{FunctionName: &function})
+	if err != nil {
+		return "", err
+	}
+
+	svc := sqs.New(sess)
+	deadURL, err := ensureQueue(svc, name+"-dead", map[string]*string{
+		"MessageRetentionPeriod": aws.String(strconv.Itoa(14 * 24 * 60 * 60)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("dead-letter queue: %w", err)
+	}
+	deadArn, err := queueAttr(svc, deadURL, sqs.QueueAttributeNameQueueArn)
+	if err != nil {
+		return "", err
+	}
+	redrive, err := json.Marshal(map[string]string{
+		"deadLetterTargetArn": deadArn,
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+	if err != nil {
+		return "", err
+	}
+	url, err := ensureQueue(svc, name, map[string]*string{
+		"VisibilityTimeout": aws.String(strconv.FormatInt(asyncVisibilityTimeout(aws.Int64Value(cfg.Timeout)), 10)),
+		"RedrivePolicy":     aws.String(string(redrive)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("queue: %w", err)
+	}
+	arn, err := queueAttr(svc, url, sqs.QueueAttributeNameQueueArn)
+	if err != nil {
+		return "", err
+	}
+
+	target := aws.StringValue(cfg.FunctionArn)
+	mappings, err := lam.ListEventSourceMappings(&lambda.ListEventSourceMappingsInput{
+		EventSourceArn: &arn,
+		FunctionName:   &target,
+	})
+	if err != nil {
+		return "", err
+	}
+	// The runtime reports which messages of a batch it couldn't
+	// record results for, so that SQS doesn't redeliver the rest
+	responseTypes := []*string{aws.String(lambda.FunctionResponseTypeReportBatchItemFailures)}
+	if len(mappings.EventSourceMappings) == 0 {
+		_, err = lam.CreateEventSourceMapping(&lambda.CreateEventSourceMappingInput{
+			EventSourceArn:        &arn,
+			FunctionName:          &target,
+			BatchSize:             aws.Int64(1),
+			Enabled:               aws.Bool(true),
+			FunctionResponseTypes: responseTypes,
+		})
+		if err != nil {
+			return "", fmt.Errorf("connecting %s to %s (does the function's role allow sqs:ReceiveMessage?): %w", name, function, err)
+		}
+		return url, nil
+	}
+	for _, m := range mappings.EventSourceMappings {
+		if reportsBatchItemFailures(m) {
+			continue
+		}
+		_, err = lam.UpdateEventSourceMapping(&lambda.UpdateEventSourceMappingInput{
+			UUID:                  m.UUID,
+			FunctionResponseTypes: responseTypes,
+		})
+		if err != nil {
+			return "", fmt.Errorf("updating the connection from %s to %s: %w", name, function, err)
+		}
+	}
+	return url, nil
+}
+
+func reportsBatchItemFailures(m *lambda.EventSourceMappingConfiguration) bool {
+	for _, t := range m.FunctionResponseTypes {
+		if aws.StringValue(t) == lambda.FunctionResponseTypeReportBatchItemFailures {
+			return true
+		}
+	}
+	return false
+}
+
+func newBatchID(now time.Time) string {
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(buf[:])
+}
+
+// messageBatcher groups messages into SendMessageBatch requests
+type messageBatcher struct {
+	entries []*sqs.SendMessageBatchRequestEntry
+	bytes   int
+	send    func([]*sqs.SendMessageBatchRequestEntry) error
+}
+
+func (b *messageBatcher) add(id, body string) error {
+	if len(body) > maxMessageBytes {
+		return fmt.Errorf("job %s is %d bytes, more than SQS allows", id, len(body))
+	}
+	if len(b.entries) == maxMessageBatch || b.bytes+len(body) > maxMessageBytes {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+	b.entries = append(b.entries, &sqs.SendMessageBatchRequestEntry{
+		Id:          aws.String(id),
+		MessageBody: aws.String(body),
+	})
+	b.bytes += len(body)
+	return nil
+}
+
+func (b *messageBatcher) flush() error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+	err := b.send(b.entries)
+	b.entries = nil
+	b.bytes = 0
+	return err
+}
+
+type SubmitCommand struct {
+	files       files.List
+	concurrency int
+	memory      int64
+	timeout     time.Duration
+	batch       string
+	jsonInput   bool
+
+	env envFlags
+}
+
+func (*SubmitCommand) Name() string { return "submit" }
+func (*SubmitCommand) Synopsis() string {
+	return "Queue a llama command over a list of inputs, to run asynchronously"
+}
+func (*SubmitCommand) Usage() string {
+	return `submit [flags] FUNCTION-NAME ARGS... < INPUTS
+
+Like "llama xargs", but enqueues each job to an SQS queue which feeds
+the function, and exits once they are all queued. Results are written
+to the object store; collect them with "llama wait BATCH". The batch
+ID is printed to stdout.
+`
+}
+
+func (c *SubmitCommand) SetFlags(flags *flag.FlagSet) {
+	flags.Var(&c.files, "f", "Pass a file through to the invocation")
+	flags.Var(&c.files, "file", "Pass a file through to the invocation")
+	flags.IntVar(&c.concurrency, "j", 32, "Number of jobs to prepare concurrently")
+	flags.Int64Var(&c.memory, "memory", 0, "Run on a variant of the function with at least this much memory, in MB")
+	flags.DurationVar(&c.timeout, "timeout", 0, "Run on a variant of the function with at least this timeout")
+	flags.StringVar(&c.batch, "batch", "", "Name the batch, instead of generating an ID")
+	flags.BoolVar(&c.jsonInput, "json", false, "Read a JSON object from each input line, and expose its fields to templates (e.g. '{{.file}}')")
+	c.env.SetFlags(flags)
+}
+
+func (c *SubmitCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	st := global.MustStore()
+	kv, ok := st.(store.KeyValue)
+	if !ok {
+		log.Printf("submit: the configured store does not support keys")
+		return subcommands.ExitFailure
+	}
+	function := flag.Arg(0)
+	if _, ok := global.Config.LocalFunctions[function]; ok {
+		log.Printf("submit: %s is a local function", function)
+		return subcommands.ExitFailure
+	}
+	if c.memory != 0 || c.timeout != 0 {
+		variants, err := llama.ListVariants(lambda.New(global.MustSession()), function)
+		if err != nil {
+			log.Fatalf("listing variants: %s", err.Error())
+		}
+		v, err := llama.PickVariant(variants, c.memory, c.timeout)
+		if err != nil {
+			log.Fatalf("%s: %s", function, err.Error())
+		}
+		if v.Function != "" {
+			function = v.Function
+		}
+	}
+	queue, err := ensureAsyncQueue(global.MustSession(), function)
+	if err != nil {
+		log.Printf("submit: setting up queue: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	environ, err := c.env.Environ(global.Config, os.Environ())
+	if err != nil {
+		log.Printf("submit: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	var fileMap protocol.FileList
+	if len(c.files) > 0 {
+		fileMap, err = c.files.Upload(ctx, st, fileMap)
+		if err != nil {
+			log.Fatalf("files: %s", err.Error())
+		}
+	}
+	batch := c.batch
+	if batch == "" {
+		batch = newBatchID(time.Now())
+	}
+
+	jobs := make(chan *Invocation)
+	go generateJobs(ctx, os.Stdin, flag.Args()[1:], c.jsonInput, jobs)
+
+	// Prepare jobs concurrently, since uploading their inputs
+	// dominates, but send them in one place so we can batch
+	// messages.
+	bodies := make(chan protocol.AsyncJob)
+	var prepErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				spec, err := prepareInvocation(ctx, st, fileMap, job)
+				if err == nil && len(spec.Outputs) > 0 {
+					err = errors.New("output files are not supported by llama submit")
+				}
+				if err != nil {
+					errOnce.Do(func() { prepErr = fmt.Errorf("job %d: %w", job.TemplateContext.Idx, err) })
+					continue
+				}
+				spec.Env = environ
+				bodies <- protocol.AsyncJob{Batch: batch, Index: job.TemplateContext.Idx, Spec: *spec}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(bodies)
+	}()
+
+	svc := sqs.New(global.MustSession())
+	sender := messageBatcher{send: func(entries []*sqs.SendMessageBatchRequestEntry) error {
+		out, err := svc.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: &queue,
+			Entries:  entries,
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Failed) > 0 {
+			f := out.Failed[0]
+			return fmt.Errorf("job %s: %s", aws.StringValue(f.Id), aws.StringValue(f.Message))
+		}
+		return nil
+	}}
+	count := 0
+	var sendErr error
+	for job := range bodies {
+		if sendErr != nil {
+			continue
+		}
+		data, err := json.Marshal(&job)
+		if err == nil {
+			err = sender.add(strconv.Itoa(job.Index), string(data))
+		}
+		if err != nil {
+			sendErr = err
+			continue
+		}
+		count++
+	}
+	if sendErr == nil {
+		sendErr = sender.flush()
+	}
+	if sendErr == nil {
+		sendErr = prepErr
+	}
+	if sendErr != nil {
+		log.Printf("submit: %s", sendErr.Error())
+		if count > 0 {
+			log.Printf("submit: some jobs of batch %s were already queued, and will run", batch)
+		}
+		return subcommands.ExitFailure
+	}
+
+	desc, err := json.Marshal(&protocol.AsyncBatch{
+		Function:  function,
+		Jobs:      count,
+		Args:      flag.Args()[1:],
+		Submitted: time.Now(),
+	})
+	if err == nil {
+		err = kv.SetKey(ctx, protocol.AsyncBatchKey(batch), desc)
+	}
+	if err != nil {
+		log.Printf("submit: recording batch: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	fmt.Println(batch)
+	return subcommands.ExitSuccess
+}
+
+type WaitCommand struct {
+	poll       time.Duration
+	timeout    time.Duration
+	quiet      bool
+	stdoutPath string
+	stderrPath string
+}
+
+func (*WaitCommand) Name() string { return "wait" }
+func (*WaitCommand) Synopsis() string {
+	return "Wait for a batch of jobs from llama submit to complete"
+}
+func (*WaitCommand) Usage() string {
+	return `wait [flags] BATCH
+
+Waits for every job in a batch queued by "llama submit" to complete,
+and reports each failed job. Exits non-zero if any job failed, or
+-timeout elapsed first. Waiting again for the same batch reports the
+same results; the jobs are not run again.
+`
+}
+
+func (c *WaitCommand) SetFlags(flags *flag.FlagSet) {
+	flags.DurationVar(&c.poll, "poll", 10*time.Second, "How often to check for results")
+	flags.DurationVar(&c.timeout, "timeout", 0, "Give up after this long (0 to wait forever)")
+	flags.BoolVar(&c.quiet, "quiet", false, "Only report failed jobs, with no progress or summary")
+	flags.StringVar(&c.stdoutPath, "stdout", "", "Write each job's stdout to this file, templated with {{.Idx}}")
+	flags.StringVar(&c.stderrPath, "stderr", "", "Write each job's stderr to this file, templated with {{.Idx}}")
+}
+
+// asyncIndex parses the index of a job out of its result key
+func asyncIndex(batch, key string) (int, bool) {
+	idx, err := strconv.Atoi(strings.TrimPrefix(key, protocol.AsyncResultPrefix(batch)))
+	return idx, err == nil
+}
+
+func (c *WaitCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	st := global.MustStore()
+	kv, kvOK := st.(store.KeyValue)
+	coll, collOK := st.(store.Collectable)
+	if !kvOK || !collOK {
+		log.Printf("wait: the configured store does not support listing keys")
+		return subcommands.ExitFailure
+	}
+	var stdoutTpl, stderrTpl *template.Template
+	var err error
+	if stdoutTpl, err = parseOutputTemplate("stdout", c.stdoutPath); err != nil {
+		log.Fatal(err)
+	}
+	if stderrTpl, err = parseOutputTemplate("stderr", c.stderrPath); err != nil {
+		log.Fatal(err)
+	}
+
+	batch := flag.Arg(0)
+	data, err := kv.GetKey(ctx, protocol.AsyncBatchKey(batch))
+	if err != nil {
+		if errors.Is(err, store.ErrNotExists) {
+			log.Printf("wait: no such batch: %q", batch)
+		} else {
+			log.Printf("wait: reading batch: %s", err.Error())
+		}
+		return subcommands.ExitFailure
+	}
+	var desc protocol.AsyncBatch
+	if err := json.Unmarshal(data, &desc); err != nil {
+		log.Printf("wait: reading batch: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	var deadline time.Time
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	seen := make(map[int]bool)
+	failed := 0
+	for {
+		var fresh []int
+		err := coll.ListKeys(ctx, protocol.AsyncResultPrefix(batch), func(info store.ObjectInfo) error {
+			if idx, ok := asyncIndex(batch, info.Id); ok && !seen[idx] {
+				fresh = append(fresh, idx)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("wait: listing results: %s", err.Error())
+			return subcommands.ExitFailure
+		}
+		sort.Ints(fresh)
+		for _, idx := range fresh {
+			seen[idx] = true
+			if !c.collect(ctx, st, kv, batch, idx, stdoutTpl, stderrTpl) {
+				failed++
+			}
+		}
+		if !c.quiet && len(fresh) > 0 {
+			log.Printf("%d/%d jobs complete, %d failed", len(seen), desc.Jobs, failed)
+		}
+		if len(seen) >= desc.Jobs {
+			break
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			log.Printf("wait: timed out with %d jobs incomplete", desc.Jobs-len(seen))
+			return subcommands.ExitFailure
+		}
+		select {
+		case <-time.After(c.poll):
+		case <-ctx.Done():
+			return subcommands.ExitFailure
+		}
+	}
+	if failed > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// collect reads the result of one job, writes its output files if
+// requested, and reports it if it failed. It returns whether the job
+// succeeded.
+func (c *WaitCommand) collect(ctx context.Context,
+	st store.Store, kv store.KeyValue, batch string, idx int,
+	stdoutTpl, stderrTpl *template.Template) bool {
+	display := fmt.Sprintf("%s/%d", batch, idx)
+	data, err := kv.GetKey(ctx, protocol.AsyncResultKey(batch, idx))
+	if err != nil {
+		log.Printf("Reading result: %s: %s", display, err.Error())
+		return false
+	}
+	var result protocol.AsyncResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("Reading result: %s: %s", display, err.Error())
+		return false
+	}
+	if result.Response == nil {
+		log.Printf("Invocation failed: %s: %s", display, result.Error)
+		return false
+	}
+	resp := result.Response
+	ok := resp.ExitStatus == 0
+	if ok && stdoutTpl == nil && stderrTpl == nil {
+		return true
+	}
+
+	job := Invocation{TemplateContext: jobContext{Idx: idx}}
+	for _, out := range []struct {
+		blob *protocol.Blob
+		tpl  *template.Template
+		dst  *[]byte
+	}{
+		{resp.Stdout, stdoutTpl, &job.Stdout},
+		{resp.Stderr, stderrTpl, &job.Stderr},
+	} {
+		if out.blob != nil {
+			if *out.dst, err = protocol_files.Read(ctx, st, out.blob); err != nil {
+				log.Printf("Reading output: %s: %s", display, err.Error())
+				return false
+			}
+		}
+		if out.tpl != nil {
+			if err := writeOutputFile(out.tpl, &job, *out.dst); err != nil {
+				log.Printf("Writing output: %s: %s", display, err.Error())
+				return false
+			}
+		}
+	}
+	if ok {
+		return true
+	}
+	log.Printf("Command exited with status: %s: %d", display, resp.ExitStatus)
+	if job.Stdout != nil && stdoutTpl == nil {
+		log.Printf("==== stdout ====\n%s\n==== end stdout ====\n", job.Stdout)
+	}
+	if job.Stderr != nil && stderrTpl == nil {
+		log.Printf("==== stderr ====\n%s\n==== end stderr ====\n", job.Stderr)
+	}
+	return false
+}
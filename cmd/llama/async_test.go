@@ -0,0 +1,80 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncQueueName(t *testing.T) {
+	name, err := asyncQueueName("gcc")
+	require.NoError(t, err)
+	assert.Equal(t, "llama-async-gcc", name)
+	_, err = asyncQueueName(strings.Repeat("f", 64) + "-m3008-t300")
+	assert.Error(t, err)
+}
+
+func TestAsyncVisibilityTimeout(t *testing.T) {
+	assert.Equal(t, int64(360), asyncVisibilityTimeout(60))
+	assert.Equal(t, int64(43200), asyncVisibilityTimeout(900*10))
+}
+
+func TestNewBatchID(t *testing.T) {
+	now := time.Date(2020, 12, 1, 13, 4, 5, 0, time.UTC)
+	id := newBatchID(now)
+	assert.True(t, strings.HasPrefix(id, "20201201-130405-"), id)
+	assert.NotEqual(t, id, newBatchID(now))
+}
+
+func TestMessageBatcher(t *testing.T) {
+	var sent [][]string
+	b := messageBatcher{send: func(entries []*sqs.SendMessageBatchRequestEntry) error {
+		var ids []string
+		for _, e := range entries {
+			ids = append(ids, aws.StringValue(e.Id))
+		}
+		sent = append(sent, ids)
+		return nil
+	}}
+	for i := 0; i < 12; i++ {
+		require.NoError(t, b.add(string(rune('a'+i)), "x"))
+	}
+	big := strings.Repeat("x", maxMessageBytes-1)
+	require.NoError(t, b.add("big", big))
+	require.NoError(t, b.flush())
+	assert.Equal(t, [][]string{
+		{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
+		{"k", "l"},
+		{"big"},
+	}, sent)
+
+	assert.Error(t, b.add("huge", big+"xx"))
+}
+
+func TestAsyncIndex(t *testing.T) {
+	idx, ok := asyncIndex("b", protocol.AsyncResultKey("b", 42))
+	assert.True(t, ok)
+	assert.Equal(t, 42, idx)
+	_, ok = asyncIndex("b", protocol.AsyncBatchKey("b"))
+	assert.False(t, ok)
+}
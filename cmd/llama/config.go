@@ -16,38 +16,212 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/google/subcommands"
 	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/store"
 )
 
 type ConfigCommand struct {
 	shell bool
+	file  bool
 }
 
 func (*ConfigCommand) Name() string     { return "config" }
 func (*ConfigCommand) Synopsis() string { return "Read/Write llama configuration" }
 func (*ConfigCommand) Usage() string {
-	return `config [FLAGS]
+	return `config [FLAGS] list|get KEY|set KEY VALUE|validate [FUNCTION...]
+
+list prints every setting that isn't at its default, and get prints
+one, as KEY=VALUE; KEYs are dotted paths into llama.json, such as
+"retry.max_attempts". Both show the configuration in effect, after
+any overrides from flags and the environment, unless -file is given.
+
+set writes a setting to llama.json. Strings are taken as they are,
+and other values parsed as JSON; an empty VALUE restores the
+default. set refuses to write a configuration that doesn't validate.
+
+validate checks the configuration, your AWS credentials, that the
+object store is reachable, and that each FUNCTION exists.
 `
 }
 
 func (c *ConfigCommand) SetFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&c.shell, "shell", false, "Write out AWS configuration as a set of shell assignments")
+	flags.BoolVar(&c.file, "file", false, "With list and get, show the config file, ignoring overrides")
 }
 
 func (c *ConfigCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	if c.shell {
 		return c.shellConfig(ctx)
 	}
-	log.Printf("config: Must specify an action")
+	if flag.NArg() == 0 {
+		log.Printf("config: Must specify an action")
+		return subcommands.ExitUsageError
+	}
+	args := flag.Args()[1:]
+	var err error
+	switch action := flag.Arg(0); {
+	case action == "list" && len(args) == 0:
+		err = c.list(ctx)
+	case action == "get" && len(args) == 1:
+		err = c.get(ctx, args[0])
+	case action == "set" && len(args) == 2:
+		err = setConfig(cli.ConfigPath(), args[0], args[1])
+	case action == "validate":
+		if !validateConfig(ctx, os.Stdout, args) {
+			return subcommands.ExitFailure
+		}
+	default:
+		log.Printf("Usage: llama %s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err != nil {
+		log.Printf("config: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// config returns the configuration to show: the one in effect, or,
+// with -file, the config file's
+func (c *ConfigCommand) config(ctx context.Context) (*cli.Config, error) {
+	if c.file {
+		return cli.ReadConfig(cli.ConfigPath())
+	}
+	return cli.MustState(ctx).Config, nil
+}
 
-	return subcommands.ExitFailure
+func (c *ConfigCommand) list(ctx context.Context) error {
+	cfg, err := c.config(ctx)
+	if err != nil {
+		return err
+	}
+	settings, err := cfg.Settings()
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		fmt.Fprintf(os.Stdout, "%s=%s\n", s.Key, s.Value)
+	}
+	return nil
+}
+
+func (c *ConfigCommand) get(ctx context.Context, key string) error {
+	cfg, err := c.config(ctx)
+	if err != nil {
+		return err
+	}
+	val, err := cfg.GetSetting(key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, val)
+	return nil
+}
+
+// setConfig sets `key` in the config file at `path`, if the result
+// is valid.
+func setConfig(path, key, value string) error {
+	cfg, err := cli.ReadConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.SetSetting(key, value); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	return cli.WriteConfig(cfg, path)
+}
+
+// validateConfig checks the configuration in effect, and that we
+// can reach AWS and the object store with it, reporting each check
+// to `w`. It returns whether every check passed.
+func validateConfig(ctx context.Context, w io.Writer, functions []string) bool {
+	global := cli.MustState(ctx)
+	ok := true
+	check := func(what string, fn func() (string, error)) {
+		detail, err := fn()
+		if err != nil {
+			fmt.Fprintf(w, "%s: FAILED: %s\n", what, err.Error())
+			ok = false
+			return
+		}
+		fmt.Fprintf(w, "%s: ok", what)
+		if detail != "" {
+			fmt.Fprintf(w, " (%s)", detail)
+		}
+		fmt.Fprintln(w)
+	}
+
+	check("config", func() (string, error) {
+		return cli.ConfigPath(), global.Config.Validate()
+	})
+	sess, err := global.Session()
+	check("region", func() (string, error) {
+		if err != nil {
+			return "", err
+		}
+		region := aws.StringValue(sess.Config.Region)
+		if region == "" {
+			return "", fmt.Errorf("no region configured; set aws_region or $AWS_REGION")
+		}
+		return region, nil
+	})
+	if err != nil {
+		return false
+	}
+	check("credentials", func() (string, error) {
+		ident, err := sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(ident.Arn), nil
+	})
+	check("object store", func() (string, error) {
+		if global.Config.Store == "" {
+			return "", fmt.Errorf("no object_store configured; try running `llama bootstrap`")
+		}
+		st, err := global.Store()
+		if err != nil {
+			return "", err
+		}
+		kv, ok := st.(store.KeyValue)
+		if !ok {
+			return global.Config.Store + "; access not checked", nil
+		}
+		// Looking up a key that doesn't exist checks that we
+		// can read the store without fetching anything
+		if _, err := kv.GetKey(ctx, "llama-config-validate"); err != nil && !errors.Is(err, store.ErrNotExists) {
+			return "", err
+		}
+		return global.Config.Store, nil
+	})
+	svc := lambda.New(sess)
+	for _, fn := range functions {
+		check("function "+fn, func() (string, error) {
+			conf, err := svc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+				FunctionName: aws.String(fn),
+			})
+			if err != nil {
+				return "", err
+			}
+			return aws.StringValue(conf.State), nil
+		})
+	}
+	return ok
 }
 
 func shellquote(word string) string {
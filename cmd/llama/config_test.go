@@ -0,0 +1,40 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llama.json")
+	require.NoError(t, setConfig(path, "aws_region", "us-west-2"))
+	require.NoError(t, setConfig(path, "retry.backoff", "1s"))
+
+	cfg, err := cli.ReadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", cfg.Region)
+	assert.Equal(t, "1s", cfg.Retry.Backoff)
+
+	assert.Error(t, setConfig(path, "retry.backoff", "soon"))
+	cfg, err = cli.ReadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1s", cfg.Retry.Backoff, "an invalid setting isn't written")
+}
@@ -18,10 +18,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"text/tabwriter"
 	"time"
@@ -29,8 +34,11 @@ import (
 	"github.com/google/subcommands"
 	"github.com/nelhage/llama/cmd/internal/cli"
 	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/daemonpb"
+	"github.com/nelhage/llama/daemon/icecc"
 	"github.com/nelhage/llama/daemon/server"
-	"golang.org/x/sys/unix"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/protocol"
 )
 
 type DaemonCommand struct {
@@ -38,10 +46,26 @@ type DaemonCommand struct {
 	ping             bool
 	shutdown         bool
 	stats            bool
+	version          bool
+	reset            bool
 	start, autostart bool
 	detach           bool
 	idleTimeout      time.Duration
 	ccConcurrency    int64
+	warmPoolIdle     time.Duration
+	distcc           string
+	distccFunction   string
+	distccAllow      string
+	iceccScheduler   string
+	icecc            string
+	iceccCapacity    int
+	iceccFunction    string
+	remoteExec       string
+	remoteExecFunc   string
+	remoteExecAllow  string
+	buildIdle        time.Duration
+	drain            bool
+	drainTimeout     time.Duration
 }
 
 func (*DaemonCommand) Name() string     { return "daemon" }
@@ -56,30 +80,33 @@ func (c *DaemonCommand) SetFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&c.shutdown, "shutdown", false, "Stop the running server")
 	flags.BoolVar(&c.start, "start", false, "Start the server")
 	flags.BoolVar(&c.stats, "stats", false, "Show server statistics")
+	flags.BoolVar(&c.version, "version", false, "Show the versions of this client and the running server")
+	flags.BoolVar(&c.reset, "reset", false, "With -stats, reset statistics and start a new budget session")
 	flags.BoolVar(&c.autostart, "autostart", false, "Start the server if it is not already running")
 	flags.BoolVar(&c.detach, "detach", false, "Detach and run the server in the background")
 	flags.StringVar(&c.path, "path", cli.SocketPath(), "Path to daemon socket")
 	flags.DurationVar(&c.idleTimeout, "idle-timeout", 10*time.Minute, "Idle timeout")
 	flags.Int64Var(&c.ccConcurrency, "cc-concurrency", 0, "Configure llamacc concurrency limit")
-}
-
-func raiseRlimits() {
-	var limits unix.Rlimit
-	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limits); err != nil {
-		log.Printf("Warning: Unable to read RLIMIT_NOFILE: %s", err.Error())
-		return
-	}
-	target := uint64(65535)
-	limits.Cur = target
-	if limits.Cur > limits.Max {
-		limits.Cur = limits.Max
-	}
-	if err := unix.Setrlimit(unix.RLIMIT_NOFILE, &limits); err != nil {
-		log.Printf("Warning: setting RLIMIT_NOFILE: %s", err.Error())
-	}
+	flags.DurationVar(&c.warmPoolIdle, "warm-pool-idle", 10*time.Minute, "Release a function's warm pool after it has been idle this long")
+	flags.StringVar(&c.distcc, "distcc", "", "Accept jobs from distcc clients on this address (e.g. :3632)")
+	flags.StringVar(&c.distccFunction, "distcc-function", "gcc", "Function to compile distcc jobs with")
+	flags.StringVar(&c.distccAllow, "distcc-allow", "", "Comma-separated CIDR blocks to accept distcc clients from (default: this machine only)")
+	flags.StringVar(&c.iceccScheduler, "icecc-scheduler", "", "Join the icecream scheduler at this address (e.g. scheduler:8765) as a compile server")
+	flags.StringVar(&c.icecc, "icecc", fmt.Sprintf(":%d", icecc.DefaultPort), "With -icecc-scheduler, accept jobs from icecream clients on this address")
+	flags.IntVar(&c.iceccCapacity, "icecc-capacity", 1000, "With -icecc-scheduler, how many jobs to advertise to the scheduler that we can run at once")
+	flags.StringVar(&c.iceccFunction, "icecc-function", "gcc", "Function to compile icecream jobs with")
+	flags.StringVar(&c.remoteExec, "remote-exec", "", "Serve the Bazel Remote Execution API on this address (e.g. :8980)")
+	flags.StringVar(&c.remoteExecFunc, "remote-exec-function", "", "Function to run remote execution actions with, unless their platform sets the \"function\" property")
+	flags.StringVar(&c.remoteExecAllow, "remote-exec-allow", "", "Comma-separated CIDR blocks to accept remote execution clients from (default: this machine only)")
+	flags.DurationVar(&c.buildIdle, "build-idle", time.Minute, "End a build session after it has been idle this long (0 to only end sessions explicitly)")
+	flags.BoolVar(&c.drain, "drain", false, "With -shutdown, stop accepting jobs and wait for the ones in flight before exiting")
+	flags.DurationVar(&c.drainTimeout, "drain-timeout", 0, "How long a graceful shutdown waits for jobs in flight (default: 2m, or the running daemon's setting with -shutdown -drain)")
 }
 
 func (c *DaemonCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.version {
+		return c.showVersion(ctx)
+	}
 	if c.ping || c.shutdown || c.stats {
 		client, err := daemon.Dial(ctx, c.path)
 		defer client.Close()
@@ -93,13 +120,23 @@ func (c *DaemonCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...in
 			}
 			log.Printf("The daemon is alive!")
 		} else if c.shutdown {
-			_, err = client.Shutdown(&daemon.ShutdownArgs{})
+			if c.drain {
+				log.Printf("Waiting for the daemon's in-flight jobs to finish...")
+			}
+			reply, err := client.Shutdown(&daemon.ShutdownArgs{
+				Drain:        c.drain,
+				DrainTimeout: c.drainTimeout,
+			})
 			if err != nil {
 				log.Fatalf("Shutting down daemon: %s", err.Error())
 			}
-			log.Printf("The daemon is exiting.")
+			if reply.Abandoned > 0 {
+				log.Printf("The daemon is exiting, abandoning %d in-flight jobs.", reply.Abandoned)
+			} else {
+				log.Printf("The daemon is exiting.")
+			}
 		} else if c.stats {
-			stats, err := client.GetDaemonStats(&daemon.StatsArgs{})
+			stats, err := client.GetDaemonStats(&daemon.StatsArgs{Reset: c.reset})
 			if err != nil {
 				log.Fatalf("Getting stats: %s", err.Error())
 			}
@@ -108,67 +145,120 @@ func (c *DaemonCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...in
 			fmt.Fprintf(os.Stdout, "invocations=%d\n", stats.Stats.Invocations)
 			fmt.Fprintf(os.Stdout, "func_errors=%d\n", stats.Stats.FunctionErrors)
 			fmt.Fprintf(os.Stdout, "other_errors=%d\n", stats.Stats.OtherErrors)
-			fmt.Fprintf(os.Stdout, "AWS Usage:\n")
-			cost := 0.0
-			tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
-			fmt.Fprintf(tw, "  Lambda runtime\tms\t%d\n", stats.Stats.Usage.Lambda_Millis)
-			fmt.Fprintf(tw, "  Lambda runtime\tMB-ms\t%d\t$%.2f\n",
-				stats.Stats.Usage.Lambda_MB_Millis,
-				float64(stats.Stats.Usage.Lambda_MB_Millis)*0.0000166667/1000000,
-			)
-			cost += float64(stats.Stats.Usage.Lambda_MB_Millis) * 0.0000166667 / 1000000
-			fmt.Fprintf(tw, "  Lambda requests\t\t%d\t$%.2f\n",
-				stats.Stats.Usage.Lambda_Requests,
-				float64(stats.Stats.Usage.Lambda_Requests)*0.20/1000000,
-			)
-			cost += float64(stats.Stats.Usage.Lambda_Requests) * 0.20 / 1000000
-			fmt.Fprintf(tw, "  S3 Write requests\t\t%d\t$%.2f\n",
-				stats.Stats.Usage.S3_Write_Requests,
-				0.005/1000*float64(stats.Stats.Usage.S3_Write_Requests),
-			)
-			cost += 0.005 / 1000 * float64(stats.Stats.Usage.S3_Write_Requests)
-			fmt.Fprintf(tw, "  S3 Read requests\t\t%d\t$%.2f\n",
-				stats.Stats.Usage.S3_Read_Requests,
-				0.0004/1000*float64(stats.Stats.Usage.S3_Read_Requests),
-			)
-			cost += 0.0004 / 1000 * float64(stats.Stats.Usage.S3_Read_Requests)
-			fmt.Fprintf(tw, "  S3 Xfer in\tMB\t%d\t$%.2f\n",
-				stats.Stats.Usage.S3_Xfer_In/(1024*1024),
-				0.0,
-			)
-			fmt.Fprintf(tw, "  S3 Xfer out\tMB\t%d\t$%.2f\n",
-				stats.Stats.Usage.S3_Xfer_Out/(1024*1024),
-				float64(stats.Stats.Usage.S3_Xfer_Out)*0.09/(1024*1024*1024),
-			)
-			cost += float64(stats.Stats.Usage.S3_Xfer_Out) * 0.09 / (1024 * 1024 * 1024)
-			fmt.Fprintf(tw, "  Total\t$\t\t$%.2f\n",
-				cost,
-			)
-			tw.Flush()
+			fmt.Fprintf(os.Stdout, "local_fallbacks=%d\n", stats.Stats.LocalFallbacks)
+			fmt.Fprintf(os.Stdout, "cache_hits=%d\n", stats.Stats.CacheHits)
+			fmt.Fprintf(os.Stdout, "cache_misses=%d\n", stats.Stats.CacheMisses)
+			fmt.Fprintf(os.Stdout, "region_failovers=%d\n", stats.Stats.RegionFailovers)
+			fmt.Fprintf(os.Stdout, "retries=%d\n", stats.Stats.Retries)
+			fmt.Fprintf(os.Stdout, "budget_throttled=%d\n", stats.Stats.BudgetThrottled)
+			fmt.Fprintf(os.Stdout, "budget_refused=%d\n", stats.Stats.BudgetRefused)
+			fmt.Fprintf(os.Stdout, "throttles=%d\n", stats.Stats.Throttles)
+			fmt.Fprintf(os.Stdout, "concurrency_limit=%d\n", stats.Stats.ConcurrencyLimit)
+			fmt.Fprintf(os.Stdout, "packed_jobs=%d\n", stats.Stats.PackedJobs)
+			fmt.Fprintf(os.Stdout, "checksum_mismatches=%d\n", stats.Stats.ChecksumMismatches)
+			fmt.Fprintf(os.Stdout, "deps_cache_hits=%d\n", stats.Stats.DepsCacheHits)
+			fmt.Fprintf(os.Stdout, "deps_cache_misses=%d\n", stats.Stats.DepsCacheMisses)
+			fmt.Fprintf(os.Stdout, "staged_files=%d\n", stats.Stats.StagedFiles)
+			writeUsage(os.Stdout, &stats.Stats.Usage, &stats.Cost, &stats.Budget)
 		}
 		return subcommands.ExitSuccess
 	} else if c.start || c.autostart {
 		raiseRlimits()
 		if c.detach {
-			cmd := exec.Command("/proc/self/exe", "daemon", "-start",
+			self, err := os.Executable()
+			if err != nil {
+				log.Fatalf("Starting daemon: %s", err.Error())
+			}
+			cmd := exec.Command(self, "daemon", "-start",
 				"-idle-timeout", c.idleTimeout.String(),
+				"-warm-pool-idle", c.warmPoolIdle.String(),
 				"-path", c.path,
+				"-distcc", c.distcc,
+				"-distcc-function", c.distccFunction,
+				"-distcc-allow", c.distccAllow,
+				"-icecc-scheduler", c.iceccScheduler,
+				"-icecc", c.icecc,
+				"-icecc-capacity", strconv.Itoa(c.iceccCapacity),
+				"-icecc-function", c.iceccFunction,
+				"-remote-exec", c.remoteExec,
+				"-remote-exec-function", c.remoteExecFunc,
+				"-remote-exec-allow", c.remoteExecAllow,
+				"-build-idle", c.buildIdle.String(),
+				"-drain-timeout", c.drainTimeout.String(),
 			)
-			cmd.SysProcAttr = &syscall.SysProcAttr{
-				Setsid: true,
-			}
+			cmd.SysProcAttr = server.DetachedProcAttr()
 			signal.Ignore(syscall.SIGHUP)
 			if err := cmd.Start(); err != nil {
 				log.Fatalf("Starting daemon: %s", err.Error())
 			}
 		} else {
+			if err := logging.Setup("", "daemon"); err != nil {
+				log.Fatalf("%s", err.Error())
+			}
 			global := cli.MustState(ctx)
+			retry, err := global.Config.RetryPolicy()
+			if err != nil {
+				log.Fatalf("reading config: %s", err.Error())
+			}
+			packing, err := global.Config.PackingPolicy()
+			if err != nil {
+				log.Fatalf("reading config: %s", err.Error())
+			}
+			allow, err := parseCIDRs(c.distccAllow)
+			if err != nil {
+				log.Fatalf("-distcc-allow: %s", err.Error())
+			}
+			remoteExecAllow, err := parseCIDRs(c.remoteExecAllow)
+			if err != nil {
+				log.Fatalf("-remote-exec-allow: %s", err.Error())
+			}
+			if c.iceccScheduler != "" && c.iceccCapacity <= 0 {
+				log.Fatalf("-icecc-capacity must be positive")
+			}
+			if global.Config.WarmPoolSize > 0 && c.warmPoolIdle <= 0 {
+				log.Fatalf("-warm-pool-idle must be positive")
+			}
+			if err := global.Config.Cache.Validate(); err != nil {
+				log.Fatalf("reading config: cache: %s", err.Error())
+			}
+			urls, err := global.Config.URLInvoker(global.MustSession())
+			if err != nil {
+				log.Fatalf("reading config: %s", err.Error())
+			}
 			if err := server.Start(ctx, &server.StartArgs{
 				Path:               c.path,
 				Session:            global.MustSession(),
 				Store:              global.MustStore(),
 				IdleTimeout:        c.idleTimeout,
 				LlamaCCConcurrency: c.ccConcurrency,
+				FailoverRegions:    global.Config.FailoverRegions,
+				LocalFunctions:     global.Config.LocalFunctions,
+				WarmPoolSize:       global.Config.WarmPoolSize,
+				WarmPoolIdle:       c.warmPoolIdle,
+				Retry:              retry,
+				Pricing:            global.Config.Prices(),
+				Budget:             global.Config.Budget,
+				Schedule:           global.Config.Scheduling(),
+				DistccAddr:         c.distcc,
+				DistccFunction:     c.distccFunction,
+				DistccAllow:        allow,
+				IceccScheduler:     iceccSchedulerAddr(c.iceccScheduler),
+				IceccAddr:          c.icecc,
+				IceccCapacity:      c.iceccCapacity,
+				IceccFunction:      c.iceccFunction,
+				IceccPlatform:      iceccPlatform(global.Config.Architecture),
+				RemoteExecAddr:     c.remoteExec,
+				RemoteExecFunction: c.remoteExecFunc,
+				RemoteExecAllow:    remoteExecAllow,
+				BuildIdle:          c.buildIdle,
+				BuildReportDir:     filepath.Join(cli.ConfigDir(), "builds"),
+				DrainTimeout:       c.drainTimeout,
+				ProfilePath:        filepath.Join(cli.ConfigDir(), "profiles.json"),
+				Packing:            packing,
+				SkipChecksums:      global.Config.SkipOutputChecksums,
+				Inline:             global.Config.InlinePolicy(),
+				Cache:              global.Config.Cache,
+				FunctionURLs:       urls,
 			}); err != nil {
 				if c.autostart && err == server.ErrAlreadyRunning {
 					return subcommands.ExitSuccess
@@ -182,3 +272,85 @@ func (c *DaemonCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...in
 
 	return subcommands.ExitSuccess
 }
+
+func (c *DaemonCommand) showVersion(ctx context.Context) subcommands.ExitStatus {
+	fmt.Fprintf(os.Stdout, "client_version=%s\n", daemon.Version())
+	fmt.Fprintf(os.Stdout, "client_protocol_version=%d\n", daemon.ProtocolVersion)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	client, err := daemonpb.Dial(ctx, daemon.GRPCSocketPath(c.path))
+	if err != nil {
+		log.Fatalf("Connecting to daemon: %s", err.Error())
+	}
+	defer client.Close()
+	v, err := client.GetVersion(ctx, &daemonpb.VersionRequest{
+		ClientVersion:   daemon.Version(),
+		ProtocolVersion: daemon.ProtocolVersion,
+	})
+	if err != nil {
+		log.Fatalf("Getting version: %s", err.Error())
+	}
+	fmt.Fprintf(os.Stdout, "daemon_version=%s\n", v.DaemonVersion)
+	fmt.Fprintf(os.Stdout, "daemon_protocol_version=%d\n", v.ProtocolVersion)
+	if !v.Supports(daemon.ProtocolVersion) || v.DaemonVersion != daemon.Version() {
+		log.Printf("The daemon is running a different version of llama. Restart it with `llama daemon -shutdown`.")
+	}
+	return subcommands.ExitSuccess
+}
+
+func writeUsage(w io.Writer, usage *protocol.UsageMetrics, cost *daemon.Cost, budget *daemon.Budget) {
+	fmt.Fprintf(w, "AWS Usage:\n")
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  Lambda runtime\tms\t%d\n", usage.Lambda_Millis)
+	fmt.Fprintf(tw, "  Lambda runtime\tGB-s\t%.1f\t$%.2f\n", daemon.GBSeconds(usage), cost.LambdaCompute)
+	fmt.Fprintf(tw, "  Lambda requests\t\t%d\t$%.2f\n", usage.Lambda_Requests, cost.LambdaRequests)
+	fmt.Fprintf(tw, "  S3 Write requests\t\t%d\t$%.2f\n", usage.S3_Write_Requests, cost.S3Writes)
+	fmt.Fprintf(tw, "  S3 Read requests\t\t%d\t$%.2f\n", usage.S3_Read_Requests, cost.S3Reads)
+	fmt.Fprintf(tw, "  S3 Xfer in\tMB\t%d\t$%.2f\n", usage.S3_Xfer_In/(1024*1024), 0.0)
+	fmt.Fprintf(tw, "  S3 Xfer out\tMB\t%d\t$%.2f\n", usage.S3_Xfer_Out/(1024*1024), cost.S3XferOut)
+	fmt.Fprintf(tw, "  Total\t$\t\t$%.2f\n", cost.Total())
+	if budget.ThrottleCost > 0 {
+		fmt.Fprintf(tw, "  Throttle at\t$\t\t$%.2f\n", budget.ThrottleCost)
+	}
+	if budget.MaxCost > 0 {
+		fmt.Fprintf(tw, "  Budget\t$\t\t$%.2f\n", budget.MaxCost)
+	}
+	tw.Flush()
+}
+
+func parseCIDRs(list string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// iceccSchedulerAddr adds the default scheduler port to `addr` if it
+// has none
+func iceccSchedulerAddr(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, strconv.Itoa(icecc.DefaultSchedulerPort))
+}
+
+// iceccPlatform returns the icecream platform name for functions
+// built for `arch`
+func iceccPlatform(arch string) string {
+	if arch == "arm64" {
+		return "aarch64"
+	}
+	return "x86_64"
+}
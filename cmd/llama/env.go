@@ -0,0 +1,108 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+)
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// envFlags control which environment variables we send to remote
+// jobs. By default they get none of ours.
+type envFlags struct {
+	vars        stringList
+	passthrough stringList
+}
+
+func (e *envFlags) SetFlags(flags *flag.FlagSet) {
+	flags.Var(&e.vars, "env", "Set an environment variable for the command, as KEY=VALUE, or KEY to pass its local value")
+	flags.Var(&e.passthrough, "env-passthrough", "Pass local environment variables whose names match this regular expression")
+}
+
+// Environ returns the variables to set for a remote job, as
+// KEY=VALUE: those from environ whose names match env_passthrough in
+// the config or a -env-passthrough pattern, overridden by any set
+// with -env.
+func (e *envFlags) Environ(cfg *cli.Config, environ []string) ([]string, error) {
+	var patterns []*regexp.Regexp
+	for _, p := range append(append([]string(nil), cfg.EnvPassthrough...), e.passthrough...) {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("env passthrough %q: %w", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	local := make(map[string]string, len(environ))
+	var out []string
+	index := make(map[string]int)
+	set := func(k, v string) {
+		if i, ok := index[k]; ok {
+			out[i] = k + "=" + v
+			return
+		}
+		index[k] = len(out)
+		out = append(out, k+"="+v)
+	}
+
+	for _, kv := range environ {
+		eq := strings.IndexByte(kv, '=')
+		if eq <= 0 {
+			continue
+		}
+		k, v := kv[:eq], kv[eq+1:]
+		local[k] = v
+		for _, re := range patterns {
+			if re.MatchString(k) {
+				set(k, v)
+				break
+			}
+		}
+	}
+	for _, kv := range e.vars {
+		k, v := kv, ""
+		eq := strings.IndexByte(kv, '=')
+		if eq >= 0 {
+			k, v = kv[:eq], kv[eq+1:]
+		}
+		if k == "" {
+			return nil, errors.New("-env: empty variable name")
+		}
+		if eq < 0 {
+			var ok bool
+			if v, ok = local[k]; !ok {
+				continue
+			}
+		}
+		set(k, v)
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvFlags(t *testing.T) {
+	environ := []string{
+		"HOME=/home/user",
+		"LANG=C.UTF-8",
+		"LC_ALL=C",
+		"LC_CTYPE=en_US.UTF-8",
+		"TMPDIR=/var/tmp",
+		"XLANG=no",
+	}
+	cases := []struct {
+		name        string
+		config      []string
+		vars        []string
+		passthrough []string
+		want        []string
+	}{
+		{"none", nil, nil, nil, nil},
+		{"set", nil, []string{"CC=gcc", "EMPTY="}, nil, []string{"CC=gcc", "EMPTY="}},
+		{"local", nil, []string{"TMPDIR", "UNSET"}, nil, []string{"TMPDIR=/var/tmp"}},
+		{"passthrough", nil, nil, []string{"LANG|LC_.*"},
+			[]string{"LANG=C.UTF-8", "LC_ALL=C", "LC_CTYPE=en_US.UTF-8"}},
+		{"config", []string{"LANG"}, nil, []string{"TMPDIR"},
+			[]string{"LANG=C.UTF-8", "TMPDIR=/var/tmp"}},
+		{"override", []string{"LANG"}, []string{"LANG=en_GB.UTF-8", "LANG=fr_FR.UTF-8"}, nil,
+			[]string{"LANG=fr_FR.UTF-8"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := envFlags{vars: tc.vars, passthrough: tc.passthrough}
+			got, err := e.Environ(&cli.Config{EnvPassthrough: tc.config}, environ)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := (&envFlags{passthrough: []string{"LC_("}}).Environ(&cli.Config{}, environ)
+	assert.Error(t, err)
+	_, err = (&envFlags{vars: []string{"=x"}}).Environ(&cli.Config{}, environ)
+	assert.Error(t, err)
+}
@@ -0,0 +1,259 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/store/s3store"
+)
+
+type GCCommand struct {
+	maxAge   time.Duration
+	maxBytes int64
+	dryRun   bool
+	verbose  bool
+}
+
+func (*GCCommand) Name() string     { return "gc" }
+func (*GCCommand) Synopsis() string { return "Delete old objects from the llama object store" }
+func (*GCCommand) Usage() string {
+	return `gc [flags]
+
+Objects and keys last used more than -max-age ago are deleted.
+Objects referenced by a surviving result-cache entry are always
+kept. If -max-bytes is given, the least recently used remaining
+objects are then deleted until the store is under that size.
+
+Llama records each object's and key's use to within a day: reading
+one whose last-modified time is older than that, or finding an
+object already stored, copies it onto itself to bring it up to
+date. -max-age must therefore be longer than a day. If the store
+keeps an index (see store_index_table in the README), gc lists
+objects from the index, which records reads to within an hour.
+
+A running llama daemon remembers which objects it has uploaded, and
+will not notice them being deleted; stop it with "llama daemon
+-shutdown" after collecting garbage.
+`
+}
+
+func (c *GCCommand) SetFlags(flags *flag.FlagSet) {
+	flags.DurationVar(&c.maxAge, "max-age", 30*24*time.Hour, "Delete objects last used longer ago than this (0 to disable)")
+	flags.Int64Var(&c.maxBytes, "max-bytes", 0, "Delete the oldest objects until the store is under this many bytes")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "Report what would be deleted without deleting anything")
+	flags.BoolVar(&c.verbose, "v", false, "Print each deleted object")
+}
+
+type gcPolicy struct {
+	maxAge   time.Duration
+	maxBytes int64
+}
+
+// expired reports whether an object is too old to keep
+func (p *gcPolicy) expired(obj *store.ObjectInfo, now time.Time) bool {
+	return p.maxAge > 0 && now.Sub(obj.LastUsed()) > p.maxAge
+}
+
+// planGC selects the objects to delete out of `objects`, never
+// selecting any whose ID is in `live`.
+func planGC(objects []store.ObjectInfo, live map[string]bool, policy gcPolicy, now time.Time) []store.ObjectInfo {
+	var remove, keep []store.ObjectInfo
+	var keepBytes int64
+	for _, obj := range objects {
+		if !live[obj.Id] && policy.expired(&obj, now) {
+			remove = append(remove, obj)
+		} else {
+			keep = append(keep, obj)
+			keepBytes += obj.Size
+		}
+	}
+	if policy.maxBytes <= 0 || keepBytes <= policy.maxBytes {
+		return remove
+	}
+	sort.Slice(keep, func(i, j int) bool {
+		return keep[i].LastUsed().Before(keep[j].LastUsed())
+	})
+	for _, obj := range keep {
+		if keepBytes <= policy.maxBytes {
+			break
+		}
+		if live[obj.Id] {
+			continue
+		}
+		remove = append(remove, obj)
+		keepBytes -= obj.Size
+	}
+	return remove
+}
+
+func (c *GCCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	// Reading result-cache entries to find their references
+	// mustn't make them look used
+	global.NoTouch = true
+	st := global.MustStore()
+	coll, ok := st.(store.Collectable)
+	if !ok {
+		log.Printf("gc: the configured store does not support garbage collection")
+		return subcommands.ExitFailure
+	}
+	if c.maxAge > 0 && c.maxAge <= s3store.DefaultTouchAge {
+		log.Printf("gc: -max-age must be longer than %s, which objects in use may go without being marked used", s3store.DefaultTouchAge)
+		return subcommands.ExitUsageError
+	}
+	policy := gcPolicy{maxAge: c.maxAge, maxBytes: c.maxBytes}
+	now := time.Now()
+
+	var keys []store.ObjectInfo
+	if err := coll.ListKeys(ctx, "", func(info store.ObjectInfo) error {
+		keys = append(keys, info)
+		return nil
+	}); err != nil {
+		log.Printf("gc: listing keys: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	live := make(map[string]bool)
+	var expiredKeys []store.ObjectInfo
+	// The references held by the expired result-cache entries, if
+	// the store's index counts them
+	var released []string
+	kv, _ := st.(store.KeyValue)
+	idx, _ := st.(store.Indexer)
+	if idx != nil && !idx.HasIndex() {
+		idx = nil
+	}
+	for _, key := range keys {
+		expired := policy.expired(&key, now)
+		if expired {
+			expiredKeys = append(expiredKeys, key)
+			if idx == nil {
+				continue
+			}
+		}
+		if kv == nil || !strings.HasPrefix(key.Id, daemon.ResultCachePrefix+"/") {
+			continue
+		}
+		data, err := kv.GetKey(ctx, key.Id)
+		if err != nil {
+			log.Printf("gc: reading %s: %s", key.Id, err.Error())
+			return subcommands.ExitFailure
+		}
+		var resp protocol.InvocationResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			log.Printf("gc: decoding %s: %s", key.Id, err.Error())
+			continue
+		}
+		if expired {
+			released = append(released, resp.Refs()...)
+			continue
+		}
+		for _, ref := range resp.Refs() {
+			live[ref] = true
+		}
+	}
+
+	var objects []store.ObjectInfo
+	var totalBytes int64
+	if err := coll.ListObjects(ctx, func(info store.ObjectInfo) error {
+		objects = append(objects, info)
+		totalBytes += info.Size
+		return nil
+	}); err != nil {
+		log.Printf("gc: listing objects: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	remove := planGC(objects, live, policy, now)
+
+	var reclaim int64
+	ids := make([]string, len(remove))
+	for i, obj := range remove {
+		ids[i] = obj.Id
+		reclaim += obj.Size
+	}
+	keyIds := make([]string, len(expiredKeys))
+	for i, key := range expiredKeys {
+		keyIds[i] = key.Id
+		reclaim += key.Size
+	}
+
+	if c.verbose {
+		for _, id := range keyIds {
+			fmt.Printf("key %s\n", id)
+		}
+		for _, id := range ids {
+			fmt.Printf("object %s\n", id)
+		}
+	}
+
+	summary := fmt.Sprintf("%d/%d objects and %d/%d keys, reclaiming %d of %d bytes",
+		len(ids), len(objects), len(keyIds), len(keys), reclaim, totalBytes)
+	if c.dryRun {
+		fmt.Printf("would delete %s\n", summary)
+		return subcommands.ExitSuccess
+	}
+
+	// Delete keys first, so that we never leave a result-cache
+	// entry pointing at a deleted object.
+	if err := coll.DeleteKeys(ctx, keyIds); err != nil {
+		log.Printf("gc: deleting keys: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	if err := coll.DeleteObjects(ctx, ids); err != nil {
+		log.Printf("gc: deleting objects: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("deleted %s\n", summary)
+
+	if len(released) > 0 {
+		deleted := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			deleted[id] = true
+		}
+		var survivors []string
+		for _, ref := range released {
+			if !deleted[ref] {
+				survivors = append(survivors, ref)
+			}
+		}
+		if err := idx.AddRefs(ctx, survivors, -1); err != nil {
+			log.Printf("gc: updating reference counts: %s", err.Error())
+		}
+	}
+
+	// Our record of uploaded objects may now name deleted ones
+	if len(ids) > 0 {
+		if err := os.Remove(cli.UploadIndexPath(global.Config.Store)); err != nil && !os.IsNotExist(err) {
+			log.Printf("gc: removing upload index: %s", err.Error())
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanGC(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	objects := []store.ObjectInfo{
+		{Id: "new", Size: 100, LastModified: now.Add(-1 * day)},
+		{Id: "mid", Size: 100, LastModified: now.Add(-5 * day)},
+		{Id: "old", Size: 100, LastModified: now.Add(-40 * day)},
+		{Id: "live", Size: 100, LastModified: now.Add(-50 * day)},
+	}
+	live := map[string]bool{"live": true}
+
+	ids := func(objs []store.ObjectInfo) []string {
+		var out []string
+		for _, o := range objs {
+			out = append(out, o.Id)
+		}
+		return out
+	}
+
+	cases := []struct {
+		name   string
+		policy gcPolicy
+		want   []string
+	}{
+		{"age", gcPolicy{maxAge: 30 * day}, []string{"old"}},
+		{"none", gcPolicy{}, nil},
+		{"bytes", gcPolicy{maxBytes: 200}, []string{"old", "mid"}},
+		{"age+bytes", gcPolicy{maxAge: 30 * day, maxBytes: 250}, []string{"old", "mid"}},
+		{"live", gcPolicy{maxAge: day / 2, maxBytes: 0}, []string{"new", "mid", "old"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := planGC(objects, live, tc.policy, now)
+			assert.Equal(t, tc.want, ids(got))
+		})
+	}
+}
+
+func TestPlanGCLastAccess(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	objects := []store.ObjectInfo{
+		{Id: "read", Size: 100, LastModified: now.Add(-40 * day), LastAccessed: now.Add(-2 * day)},
+		{Id: "unread", Size: 100, LastModified: now.Add(-40 * day), LastAccessed: now.Add(-40 * day)},
+		{Id: "written", Size: 100, LastModified: now.Add(-10 * day)},
+	}
+	got := planGC(objects, nil, gcPolicy{maxAge: 30 * day}, now)
+	require.Len(t, got, 1)
+	assert.Equal(t, "unread", got[0].Id)
+
+	got = planGC(objects, nil, gcPolicy{maxBytes: 100}, now)
+	require.Len(t, got, 2)
+	assert.Equal(t, "unread", got[0].Id)
+	assert.Equal(t, "written", got[1].Id)
+}
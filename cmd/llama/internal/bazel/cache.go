@@ -0,0 +1,110 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bazel exposes the llama object store to Bazel and
+// compatible build tools using Bazel's HTTP remote caching protocol.
+//
+// Entries are stored under the same keys as the daemon's gRPC Remote
+// Execution API frontend uses (see package reapi), so the two share a
+// cache.
+package bazel
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/reapi"
+	"github.com/nelhage/llama/store"
+)
+
+const maxObjectBytes = 1 << 30
+
+// Handler serves `/ac/HASH` and `/cas/HASH` out of a key-value store.
+type Handler struct {
+	Store store.KeyValue
+	// Whether to serve entries, store them, or both. Entries the
+	// policy doesn't let us serve are reported missing, and
+	// uploads it doesn't let us store are accepted and dropped,
+	// so that Bazel carries on without the cache.
+	Policy daemon.CachePolicy
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	// Bazel allows an arbitrary prefix before the /ac/ or /cas/
+	kind, hash := parts[len(parts)-2], parts[len(parts)-1]
+	var key string
+	switch {
+	case !reapi.ValidHash(hash):
+		http.NotFound(w, r)
+		return
+	case kind == "ac":
+		key = reapi.ACKey(hash)
+	case kind == "cas":
+		key = reapi.CASKey(hash)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !h.Policy.Reads() {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := h.Store.GetKey(r.Context(), key)
+		if err == store.ErrNotExists {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxObjectBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if kind == "cas" {
+			if reapi.DigestOf(data).Hash != hash {
+				http.Error(w, "content does not match hash", http.StatusBadRequest)
+				return
+			}
+		}
+		if !h.Policy.Writes() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if err := h.Store.SetKey(r.Context(), key, data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	h := &Handler{Store: store.InMemory().(store.KeyValue)}
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return w
+	}
+
+	const blob = "hello, bazel\n"
+	sum := sha256.Sum256([]byte(blob))
+	hash := hex.EncodeToString(sum[:])
+
+	assert.Equal(t, http.StatusNotFound, do("GET", "/cas/"+hash, "").Code)
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/cas/"+hash, "wrong").Code)
+	assert.Equal(t, http.StatusOK, do("PUT", "/cas/"+hash, blob).Code)
+
+	got := do("GET", "/prefix/cas/"+hash, "")
+	assert.Equal(t, http.StatusOK, got.Code)
+	assert.Equal(t, blob, got.Body.String())
+
+	// Action cache entries are not content-addressed
+	assert.Equal(t, http.StatusOK, do("PUT", "/ac/"+hash, "result").Code)
+	assert.Equal(t, "result", do("GET", "/ac/"+hash, "").Body.String())
+
+	assert.Equal(t, http.StatusNotFound, do("GET", "/cas/nothex", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/other/"+hash, "").Code)
+
+	h.Policy = daemon.CacheWriteOnly
+	assert.Equal(t, http.StatusNotFound, do("GET", "/ac/"+hash, "").Code)
+	assert.Equal(t, http.StatusOK, do("PUT", "/ac/"+hash, "rebuilt").Code)
+	h.Policy = daemon.CacheReadOnly
+	assert.Equal(t, "rebuilt", do("GET", "/ac/"+hash, "").Body.String())
+	assert.Equal(t, http.StatusOK, do("PUT", "/ac/"+hash, "hacked").Code)
+	assert.Equal(t, "rebuilt", do("GET", "/ac/"+hash, "").Body.String(), "read-only drops uploads")
+}
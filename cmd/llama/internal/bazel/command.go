@@ -0,0 +1,70 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazel
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/store"
+)
+
+type BazelCacheCommand struct {
+	listen string
+	policy daemon.CachePolicy
+}
+
+func (*BazelCacheCommand) Name() string { return "bazel-cache" }
+func (*BazelCacheCommand) Synopsis() string {
+	return "Serve the llama object store as a Bazel HTTP remote cache"
+}
+func (*BazelCacheCommand) Usage() string {
+	return `bazel-cache [flags]
+
+Run bazel with --remote_cache=http://ADDRESS to use the cache.
+`
+}
+
+func (c *BazelCacheCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.listen, "listen", "localhost:9090", "Address to listen on")
+	flags.Var(&c.policy, "cache-policy", "read-write, read-only, write-only or bypass (default: the cache.policy setting)")
+}
+
+func (c *BazelCacheCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	kv, ok := global.MustStore().(store.KeyValue)
+	if !ok {
+		log.Printf("bazel-cache: the configured store does not support named keys")
+		return subcommands.ExitFailure
+	}
+	if err := global.Config.Cache.Validate(); err != nil {
+		log.Printf("bazel-cache: reading config: cache: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	// As with the daemon, the flag can only narrow the configured
+	// policy
+	policy := global.Config.Cache.Policy.Restrict(c.policy)
+	log.Printf("Serving Bazel remote cache on http://%s/ (%s)", c.listen, policy)
+	if err := http.ListenAndServe(c.listen, &Handler{Store: kv, Policy: policy}); err != nil {
+		log.Printf("bazel-cache: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -52,6 +52,14 @@ func stackRolledBack(s *cloudformation.Stack) bool {
 type BootstrapCommand struct {
 	in  *bufio.Reader
 	out io.Writer
+
+	arch     string
+	warmPool int64
+	output   string
+
+	printPolicy bool
+	functions   string
+	lifecycle   bool
 }
 
 func (*BootstrapCommand) Name() string     { return "bootstrap" }
@@ -62,6 +70,12 @@ func (*BootstrapCommand) Usage() string {
 }
 
 func (c *BootstrapCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.arch, "arch", "", "Default architecture for Llama functions (x86_64 or arm64)")
+	flags.Int64Var(&c.warmPool, "warm-pool", 0, "Keep this many instances of each function warm while it is in use")
+	flags.StringVar(&c.output, "output", "", "Print a template for the resources (cloudformation or terraform) instead of creating them")
+	flags.BoolVar(&c.printPolicy, "print-policy", false, "Print least-privilege IAM policies for the configured resources, instead of creating them")
+	flags.StringVar(&c.functions, "functions", "gcc", "Comma-separated Lambda functions for -print-policy to cover")
+	flags.BoolVar(&c.lifecycle, "lifecycle", false, "Set the object store bucket's lifecycle rules from s3_expire_days, instead of creating resources")
 }
 
 func (c *BootstrapCommand) ensureLlamaCxx() error {
@@ -85,6 +99,48 @@ func (c *BootstrapCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ..
 		c.out = os.Stdout
 	}
 
+	if c.output != "" {
+		if err := writeTemplate(c.out, c.output, c.arch); err != nil {
+			log.Printf("%s", err.Error())
+			return subcommands.ExitUsageError
+		}
+		return subcommands.ExitSuccess
+	}
+
+	if c.printPolicy {
+		global := cli.MustState(ctx)
+		scope, err := scopeFromConfig(global.Config, strings.Split(c.functions, ","))
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return subcommands.ExitFailure
+		}
+		if err := writePolicies(c.out, scope); err != nil {
+			log.Printf("%s", err.Error())
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+
+	if c.lifecycle {
+		global := cli.MustState(ctx)
+		days := global.Config.S3ExpireDays
+		if days == 0 {
+			log.Printf("Set s3_expire_days in %s first", cli.ConfigPath())
+			return subcommands.ExitUsageError
+		}
+		// Check that the store accepts the setting
+		if _, err := global.Store(); err != nil {
+			log.Printf("%s", err.Error())
+			return subcommands.ExitFailure
+		}
+		if err := applyLifecycle(global.MustSession(), global.Config.Store, days); err != nil {
+			log.Printf("%s", err.Error())
+			return subcommands.ExitFailure
+		}
+		log.Printf("Objects in %s now expire %d days after they were last written", global.Config.Store, days)
+		return subcommands.ExitSuccess
+	}
+
 	log.Printf("Ensuring llamac++ symlink exists...")
 	err := c.ensureLlamaCxx()
 	if err != nil {
@@ -123,10 +179,17 @@ func (c *BootstrapCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ..
 
 	log.Printf("Creating cloudformation stack...")
 
+	var parameters []*cloudformation.Parameter
+	if c.arch != "" {
+		parameters = append(parameters, &cloudformation.Parameter{
+			ParameterKey:   aws.String("Architecture"),
+			ParameterValue: aws.String(c.arch),
+		})
+	}
 	cf := cloudformation.New(session)
 	_, err = cf.CreateStack(&cloudformation.CreateStackInput{
 		Capabilities: []*string{aws.String(cloudformation.CapabilityCapabilityIam)},
-		Parameters:   []*cloudformation.Parameter{},
+		Parameters:   parameters,
 		TemplateBody: aws.String(CFTemplate),
 		StackName:    aws.String("llama"),
 	})
@@ -186,9 +249,23 @@ poll:
 		}
 	}
 	newCfg.Region = *session.Config.Region
+	if c.arch != "" {
+		newCfg.Architecture = c.arch
+	}
+	if c.warmPool != 0 {
+		newCfg.WarmPoolSize = c.warmPool
+	}
 
 	cli.WriteConfig(&newCfg, cli.ConfigPath())
 
+	if newCfg.S3ExpireDays > 0 {
+		log.Printf("Setting the bucket to expire objects after %d days...", newCfg.S3ExpireDays)
+		if err := applyLifecycle(session, newCfg.Store, newCfg.S3ExpireDays); err != nil {
+			log.Printf("Error: %s", err.Error())
+			log.Printf("Rerun `llama bootstrap -lifecycle` to try again.")
+		}
+	}
+
 	log.Printf("Llama bootstrap complete. You can now create and use Llama functions.")
 
 	return subcommands.ExitSuccess
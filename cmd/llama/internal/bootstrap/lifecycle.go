@@ -0,0 +1,121 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	lifecycleRulePrefix = "llama-"
+	// The rule in CFTemplate, which ours replace
+	stackLifecycleRule = "Expire old objects"
+)
+
+// lifecycleRules returns the rules which expire objects under prefix
+// days after they were last written, result-cache entries and other
+// keys after half that, and abandoned multipart uploads and streamed
+// output after a day.
+func lifecycleRules(prefix string, days int) []*s3.LifecycleRule {
+	return []*s3.LifecycleRule{
+		{
+			ID:     aws.String(lifecycleRulePrefix + "expire-objects"),
+			Status: aws.String(s3.ExpirationStatusEnabled),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(prefix)},
+			Expiration: &s3.LifecycleExpiration{
+				Days: aws.Int64(int64(days)),
+			},
+			AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: aws.Int64(1),
+			},
+		},
+		{
+			ID:     aws.String(lifecycleRulePrefix + "expire-keys"),
+			Status: aws.String(s3.ExpirationStatusEnabled),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(prefix + "keys/")},
+			Expiration: &s3.LifecycleExpiration{
+				Days: aws.Int64(int64(days / 2)),
+			},
+		},
+		{
+			// The runtime deletes its streamed output when
+			// the command exits, unless it dies first
+			ID:     aws.String(lifecycleRulePrefix + "expire-streams"),
+			Status: aws.String(s3.ExpirationStatusEnabled),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(prefix + "keys/streams/")},
+			Expiration: &s3.LifecycleExpiration{
+				Days: aws.Int64(1),
+			},
+		},
+	}
+}
+
+// mergeLifecycleRules replaces any rules of ours in existing with
+// rules, keeping everyone else's.
+func mergeLifecycleRules(existing, rules []*s3.LifecycleRule) []*s3.LifecycleRule {
+	var out []*s3.LifecycleRule
+	for _, rule := range existing {
+		id := aws.StringValue(rule.ID)
+		if strings.HasPrefix(id, lifecycleRulePrefix) || id == stackLifecycleRule {
+			continue
+		}
+		out = append(out, rule)
+	}
+	return append(out, rules...)
+}
+
+// applyLifecycle sets the lifecycle rules for the S3 object store at
+// store to expire objects after days.
+func applyLifecycle(sess *session.Session, store string, days int) error {
+	if days < 2 {
+		return errors.New("s3_expire_days must be at least 2")
+	}
+	u, err := url.Parse(store)
+	if err != nil || u.Scheme != "s3" {
+		return fmt.Errorf("object store %q is not in S3", store)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	svc := s3.New(sess)
+	var existing []*s3.LifecycleRule
+	current, err := svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(u.Host),
+	})
+	if err == nil {
+		existing = current.Rules
+	} else if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "NoSuchLifecycleConfiguration" {
+		return fmt.Errorf("reading lifecycle rules: %w", err)
+	}
+	_, err = svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(u.Host),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: mergeLifecycleRules(existing, lifecycleRules(prefix, days)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("setting lifecycle rules: %w", err)
+	}
+	return nil
+}
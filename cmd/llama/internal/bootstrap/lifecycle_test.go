@@ -0,0 +1,47 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycleRules(t *testing.T) {
+	rules := lifecycleRules("obj/", 30)
+	if assert.Len(t, rules, 3) {
+		assert.Equal(t, "obj/", *rules[0].Filter.Prefix)
+		assert.Equal(t, int64(30), *rules[0].Expiration.Days)
+		assert.Equal(t, "obj/keys/", *rules[1].Filter.Prefix)
+		assert.Equal(t, int64(15), *rules[1].Expiration.Days)
+		assert.Equal(t, "obj/keys/streams/", *rules[2].Filter.Prefix)
+		assert.Equal(t, int64(1), *rules[2].Expiration.Days)
+	}
+
+	existing := []*s3.LifecycleRule{
+		{ID: aws.String("Expire old objects")},
+		{ID: aws.String("llama-expire-objects")},
+		{ID: aws.String("archive-logs")},
+	}
+	merged := mergeLifecycleRules(existing, rules)
+	var ids []string
+	for _, rule := range merged {
+		ids = append(ids, *rule.ID)
+	}
+	assert.Equal(t, []string{"archive-logs", "llama-expire-objects", "llama-expire-keys", "llama-expire-streams"}, ids)
+}
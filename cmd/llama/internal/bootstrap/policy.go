@@ -0,0 +1,443 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+)
+
+// policyDocument is an IAM policy document, as accepted by `aws iam
+// create-policy` or an inline role policy.
+type policyDocument struct {
+	Version   string
+	Statement []policyStatement
+}
+
+type policyStatement struct {
+	Sid       string
+	Effect    string
+	Action    []string
+	Resource  []string
+	Condition map[string]map[string][]string `json:",omitempty"`
+}
+
+// policyScope names the resources llama's policies are scoped to.
+type policyScope struct {
+	partition  string
+	account    string
+	regions    []string
+	bucket     string
+	prefix     string
+	role       string
+	repository string
+	functions  []string
+	xray       bool
+	indexTable string
+	// Result-cache namespaces the developer may not write to;
+	// "" protects every namespace. See daemon.CacheConfig.
+	readOnlyCaches []string
+}
+
+// scopeFromConfig reads the resources a bootstrapped llama uses out
+// of its configuration. `functions` names the Lambda functions the
+// policies should cover.
+func scopeFromConfig(cfg *cli.Config, functions []string) (*policyScope, error) {
+	if cfg.Store == "" || cfg.IAMRole == "" || cfg.Region == "" {
+		return nil, errors.New("object_store, iam_role and aws_region must be configured; run `llama bootstrap` first")
+	}
+	var names []string
+	for _, fn := range functions {
+		if fn != "" {
+			names = append(names, fn)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no functions to scope the policy to")
+	}
+	u, err := url.Parse(cfg.Store)
+	if err != nil {
+		return nil, fmt.Errorf("parsing object_store: %w", err)
+	}
+	if u.Scheme != "s3" {
+		return nil, fmt.Errorf("object_store: %q: policies can only be generated for S3 stores", cfg.Store)
+	}
+	role, err := arn.Parse(cfg.IAMRole)
+	if err != nil {
+		return nil, fmt.Errorf("parsing iam_role: %w", err)
+	}
+	scope := &policyScope{
+		partition: role.Partition,
+		account:   role.AccountID,
+		regions:   append([]string{cfg.Region}, cfg.FailoverRegions...),
+		bucket:    u.Host,
+		prefix:    strings.TrimPrefix(u.Path, "/"),
+		role:      cfg.IAMRole,
+		functions: names,
+		xray:      cfg.XRayTracing,
+	}
+	if err := cfg.Cache.Validate(); err != nil {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	if !cfg.Cache.Policy.Writes() {
+		scope.readOnlyCaches = []string{""}
+	} else {
+		scope.readOnlyCaches = cfg.Cache.Shared
+	}
+	if cfg.StoreIndexTable != "" {
+		scope.indexTable = scope.arn("dynamodb", cfg.Region, "table/"+cfg.StoreIndexTable)
+	}
+	if cfg.ECRRepository != "" {
+		slash := strings.IndexByte(cfg.ECRRepository, '/')
+		if slash < 0 {
+			return nil, fmt.Errorf("ecr_repository: %q: expected REGISTRY/NAME", cfg.ECRRepository)
+		}
+		registry := strings.Split(cfg.ECRRepository[:slash], ".")
+		if len(registry) < 4 || registry[1] != "dkr" || registry[2] != "ecr" {
+			return nil, fmt.Errorf("ecr_repository: %q: not an ECR repository", cfg.ECRRepository)
+		}
+		scope.repository = scope.arn("ecr", registry[3], "repository/"+cfg.ECRRepository[slash+1:])
+	}
+	return scope, nil
+}
+
+func (s *policyScope) arn(service, region, resource string) string {
+	return arn.ARN{
+		Partition: s.partition,
+		Service:   service,
+		Region:    region,
+		AccountID: s.account,
+		Resource:  resource,
+	}.String()
+}
+
+// each returns `format` applied to every (region, function) pair
+func (s *policyScope) each(service, format string) []string {
+	var out []string
+	for _, region := range s.regions {
+		for _, fn := range s.functions {
+			out = append(out, s.arn(service, region, fmt.Sprintf(format, fn)))
+		}
+	}
+	return out
+}
+
+func (s *policyScope) bucketARN() string {
+	return fmt.Sprintf("arn:%s:s3:::%s", s.partition, s.bucket)
+}
+
+func (s *policyScope) objectsARN() string {
+	return fmt.Sprintf("arn:%s:s3:::%s/%s*", s.partition, s.bucket, s.prefix)
+}
+
+// readOnlyCacheARNs covers the result-cache entries in the read-only
+// namespaces.
+func (s *policyScope) readOnlyCacheARNs() []string {
+	var out []string
+	for _, ns := range s.readOnlyCaches {
+		key := path.Join(s.prefix, "keys", daemon.ResultCachePrefix, ns)
+		out = append(out, fmt.Sprintf("arn:%s:s3:::%s/%s/*", s.partition, s.bucket, key))
+	}
+	return out
+}
+
+func (s *policyScope) queuesARN() []string {
+	var out []string
+	for _, region := range s.regions {
+		out = append(out, s.arn("sqs", region, asyncQueuePrefix+"*"))
+	}
+	return out
+}
+
+// functionARNs covers the functions, their variants (see
+// llama.VariantFunction), and the versions and aliases of both, which
+// is how warm pools are published.
+func (s *policyScope) functionARNs() []string {
+	var out []string
+	for _, name := range []string{"function:%s", "function:%s:*", "function:%s-m*", "function:%s-m*:*"} {
+		out = append(out, s.each("lambda", name)...)
+	}
+	return out
+}
+
+func (s *policyScope) logGroupARNs() []string {
+	return append(s.each("logs", "log-group:/aws/lambda/%s:*"), s.each("logs", "log-group:/aws/lambda/%s-m*:*")...)
+}
+
+// asyncQueuePrefix matches the queues `llama async` creates; see
+// cmd/llama/async.go
+const asyncQueuePrefix = "llama-async-"
+
+// developerPolicy is what someone running `llama`, `llamacc` and the
+// daemon needs: invoking and updating the functions, reading and
+// writing the object store, pushing images and queueing async jobs.
+func developerPolicy(s *policyScope) policyDocument {
+	doc := policyDocument{
+		Version: "2012-10-17",
+		Statement: []policyStatement{
+			{
+				Sid:    "LlamaListObjectStore",
+				Effect: "Allow",
+				Action: []string{
+					"s3:ListBucket",
+				},
+				Resource: []string{s.bucketARN()},
+			},
+			{
+				Sid:    "LlamaAccessObjectStore",
+				Effect: "Allow",
+				Action: []string{
+					"s3:GetObject",
+					"s3:PutObject",
+					"s3:DeleteObject",
+					"s3:AbortMultipartUpload",
+				},
+				Resource: []string{s.objectsARN()},
+			},
+			{
+				Sid:    "LlamaInvokeFunctions",
+				Effect: "Allow",
+				Action: []string{
+					"lambda:InvokeFunction",
+					"lambda:InvokeFunctionUrl",
+					"lambda:GetFunction",
+					"lambda:GetFunctionConfiguration",
+				},
+				Resource: s.functionARNs(),
+			},
+			{
+				Sid:    "LlamaUpdateFunctions",
+				Effect: "Allow",
+				Action: []string{
+					"lambda:CreateFunction",
+					"lambda:UpdateFunctionCode",
+					"lambda:UpdateFunctionConfiguration",
+					"lambda:PublishVersion",
+					"lambda:CreateAlias",
+					"lambda:UpdateAlias",
+					"lambda:PutProvisionedConcurrencyConfig",
+					"lambda:DeleteProvisionedConcurrencyConfig",
+					"lambda:TagResource",
+				},
+				Resource: s.functionARNs(),
+			},
+			{
+				Sid:    "LlamaPassRole",
+				Effect: "Allow",
+				Action: []string{
+					"iam:PassRole",
+				},
+				Resource: []string{s.role},
+				Condition: map[string]map[string][]string{
+					"StringEquals": {"iam:PassedToService": {"lambda.amazonaws.com"}},
+				},
+			},
+			{
+				Sid:    "LlamaSubmitAsyncJobs",
+				Effect: "Allow",
+				Action: []string{
+					"sqs:CreateQueue",
+					"sqs:GetQueueUrl",
+					"sqs:GetQueueAttributes",
+					"sqs:SetQueueAttributes",
+					"sqs:SendMessage",
+				},
+				Resource: s.queuesARN(),
+			},
+			{
+				Sid:    "LlamaConnectAsyncJobs",
+				Effect: "Allow",
+				Action: []string{
+					"lambda:CreateEventSourceMapping",
+					"lambda:UpdateEventSourceMapping",
+				},
+				Resource: []string{"*"},
+				Condition: map[string]map[string][]string{
+					"StringLike": {"lambda:FunctionArn": s.functionARNs()},
+				},
+			},
+			{
+				// None of these supports resource-level
+				// permissions.
+				Sid:    "LlamaUnscoped",
+				Effect: "Allow",
+				Action: []string{
+					"lambda:ListFunctions",
+					"lambda:ListEventSourceMappings",
+					"ecr:GetAuthorizationToken",
+				},
+				Resource: []string{"*"},
+			},
+			{
+				Sid:    "LlamaReadLogs",
+				Effect: "Allow",
+				Action: []string{
+					"logs:FilterLogEvents",
+					"logs:GetLogEvents",
+				},
+				Resource: s.logGroupARNs(),
+			},
+		},
+	}
+	if s.repository != "" {
+		doc.Statement = append(doc.Statement, policyStatement{
+			Sid:    "LlamaPushImages",
+			Effect: "Allow",
+			Action: []string{
+				"ecr:BatchCheckLayerAvailability",
+				"ecr:BatchGetImage",
+				"ecr:GetDownloadUrlForLayer",
+				"ecr:InitiateLayerUpload",
+				"ecr:UploadLayerPart",
+				"ecr:CompleteLayerUpload",
+				"ecr:PutImage",
+			},
+			Resource: []string{s.repository},
+		})
+	}
+	if s.indexTable != "" {
+		doc.Statement = append(doc.Statement, policyStatement{
+			Sid:    "LlamaAccessStoreIndex",
+			Effect: "Allow",
+			Action: []string{
+				"dynamodb:UpdateItem",
+				"dynamodb:BatchGetItem",
+				"dynamodb:BatchWriteItem",
+				"dynamodb:Scan",
+			},
+			Resource: []string{s.indexTable},
+		})
+	}
+	if len(s.readOnlyCaches) != 0 {
+		// An explicit Deny wins over the Allow above, so
+		// someone with this policy can consume a shared
+		// cache, but can't add to or poison it.
+		doc.Statement = append(doc.Statement, policyStatement{
+			Sid:    "LlamaProtectSharedCache",
+			Effect: "Deny",
+			Action: []string{
+				"s3:PutObject",
+				"s3:DeleteObject",
+			},
+			Resource: s.readOnlyCacheARNs(),
+		})
+	}
+	return doc
+}
+
+// functionPolicy is what the functions themselves need, replacing
+// the role's AWSLambdaBasicExecutionRole and inline policies.
+func functionPolicy(s *policyScope) policyDocument {
+	var logGroups []string
+	for _, region := range s.regions {
+		logGroups = append(logGroups, s.arn("logs", region, "*"))
+	}
+	doc := policyDocument{
+		Version: "2012-10-17",
+		Statement: []policyStatement{
+			{
+				Sid:    "LlamaCreateLogGroup",
+				Effect: "Allow",
+				Action: []string{
+					"logs:CreateLogGroup",
+				},
+				Resource: logGroups,
+			},
+			{
+				Sid:    "LlamaWriteLogs",
+				Effect: "Allow",
+				Action: []string{
+					"logs:CreateLogStream",
+					"logs:PutLogEvents",
+				},
+				Resource: s.logGroupARNs(),
+			},
+			{
+				Sid:    "LlamaListObjectStore",
+				Effect: "Allow",
+				Action: []string{
+					"s3:ListBucket",
+				},
+				Resource: []string{s.bucketARN()},
+			},
+			{
+				Sid:    "LlamaAccessObjectStore",
+				Effect: "Allow",
+				Action: []string{
+					"s3:GetObject",
+					"s3:PutObject",
+				},
+				Resource: []string{s.objectsARN()},
+			},
+			{
+				Sid:    "LlamaReceiveAsyncJobs",
+				Effect: "Allow",
+				Action: []string{
+					"sqs:ReceiveMessage",
+					"sqs:DeleteMessage",
+					"sqs:GetQueueAttributes",
+				},
+				Resource: s.queuesARN(),
+			},
+		},
+	}
+	if s.xray {
+		doc.Statement = append(doc.Statement, policyStatement{
+			Sid:    "LlamaWriteTraces",
+			Effect: "Allow",
+			Action: []string{
+				"xray:PutTraceSegments",
+				"xray:PutTelemetryRecords",
+			},
+			// X-Ray has no resource-level permissions
+			Resource: []string{"*"},
+		})
+	}
+	if s.indexTable != "" {
+		doc.Statement = append(doc.Statement, policyStatement{
+			Sid:    "LlamaUpdateStoreIndex",
+			Effect: "Allow",
+			Action: []string{
+				"dynamodb:UpdateItem",
+				"dynamodb:BatchGetItem",
+			},
+			Resource: []string{s.indexTable},
+		})
+	}
+	return doc
+}
+
+// writePolicies writes the developer and function policies for
+// `scope` to `w`, as a single JSON object.
+func writePolicies(w io.Writer, scope *policyScope) error {
+	out, err := json.MarshalIndent(struct {
+		Developer policyDocument `json:"developer"`
+		Function  policyDocument `json:"function"`
+	}{developerPolicy(scope), functionPolicy(scope)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeFromConfig(t *testing.T) {
+	cfg := &cli.Config{
+		Store:           "s3://llama-bucket/obj/",
+		Region:          "us-west-2",
+		FailoverRegions: []string{"us-east-1"},
+		IAMRole:         "arn:aws:iam::123456789012:role/llama-Role",
+		ECRRepository:   "123456789012.dkr.ecr.us-west-2.amazonaws.com/llama",
+		StoreIndexTable: "llama-index",
+	}
+	scope, err := scopeFromConfig(cfg, []string{"gcc", "", "rustc"})
+	require.NoError(t, err)
+	assert.Equal(t, "aws", scope.partition)
+	assert.Equal(t, "123456789012", scope.account)
+	assert.Equal(t, "llama-bucket", scope.bucket)
+	assert.Equal(t, "obj/", scope.prefix)
+	assert.Equal(t, []string{"gcc", "rustc"}, scope.functions)
+	assert.Equal(t, "arn:aws:ecr:us-west-2:123456789012:repository/llama", scope.repository)
+	assert.Equal(t, "arn:aws:s3:::llama-bucket/obj/*", scope.objectsARN())
+	assert.Equal(t, "arn:aws:dynamodb:us-west-2:123456789012:table/llama-index", scope.indexTable)
+	assert.Equal(t, []string{
+		"arn:aws:lambda:us-west-2:123456789012:function:gcc",
+		"arn:aws:lambda:us-west-2:123456789012:function:rustc",
+		"arn:aws:lambda:us-east-1:123456789012:function:gcc",
+		"arn:aws:lambda:us-east-1:123456789012:function:rustc",
+		"arn:aws:lambda:us-west-2:123456789012:function:gcc:*",
+		"arn:aws:lambda:us-west-2:123456789012:function:rustc:*",
+		"arn:aws:lambda:us-east-1:123456789012:function:gcc:*",
+		"arn:aws:lambda:us-east-1:123456789012:function:rustc:*",
+		"arn:aws:lambda:us-west-2:123456789012:function:gcc-m*",
+		"arn:aws:lambda:us-west-2:123456789012:function:rustc-m*",
+		"arn:aws:lambda:us-east-1:123456789012:function:gcc-m*",
+		"arn:aws:lambda:us-east-1:123456789012:function:rustc-m*",
+		"arn:aws:lambda:us-west-2:123456789012:function:gcc-m*:*",
+		"arn:aws:lambda:us-west-2:123456789012:function:rustc-m*:*",
+		"arn:aws:lambda:us-east-1:123456789012:function:gcc-m*:*",
+		"arn:aws:lambda:us-east-1:123456789012:function:rustc-m*:*",
+	}, scope.functionARNs())
+
+	_, err = scopeFromConfig(cfg, []string{""})
+	assert.Error(t, err)
+	_, err = scopeFromConfig(&cli.Config{Region: "us-west-2"}, []string{"gcc"})
+	assert.Error(t, err)
+	bad := *cfg
+	bad.Store = "file:///tmp/llama"
+	_, err = scopeFromConfig(&bad, []string{"gcc"})
+	assert.Error(t, err)
+}
+
+func TestWritePolicies(t *testing.T) {
+	scope, err := scopeFromConfig(&cli.Config{
+		Store:   "s3://llama-bucket/obj/",
+		Region:  "us-west-2",
+		IAMRole: "arn:aws:iam::123456789012:role/llama-Role",
+	}, []string{"gcc"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writePolicies(&buf, scope))
+	var out map[string]policyDocument
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+
+	actions := func(doc policyDocument) map[string][]string {
+		got := make(map[string][]string)
+		for _, st := range doc.Statement {
+			assert.Equal(t, "Allow", st.Effect)
+			for _, a := range st.Action {
+				got[a] = st.Resource
+			}
+		}
+		return got
+	}
+
+	dev := actions(out["developer"])
+	assert.Equal(t, []string{"arn:aws:iam::123456789012:role/llama-Role"}, dev["iam:PassRole"])
+	assert.Contains(t, dev["lambda:InvokeFunction"], "arn:aws:lambda:us-west-2:123456789012:function:gcc")
+	assert.Contains(t, dev["lambda:InvokeFunctionUrl"], "arn:aws:lambda:us-west-2:123456789012:function:gcc")
+	assert.Equal(t, []string{"arn:aws:s3:::llama-bucket/obj/*"}, dev["s3:DeleteObject"])
+	assert.NotContains(t, dev, "ecr:PutImage", "no repository is configured")
+
+	fn := actions(out["function"])
+	assert.Equal(t, []string{"arn:aws:s3:::llama-bucket/obj/*"}, fn["s3:PutObject"])
+	assert.Equal(t, []string{"arn:aws:sqs:us-west-2:123456789012:llama-async-*"}, fn["sqs:ReceiveMessage"])
+	assert.Equal(t, []string{
+		"arn:aws:logs:us-west-2:123456789012:log-group:/aws/lambda/gcc:*",
+		"arn:aws:logs:us-west-2:123456789012:log-group:/aws/lambda/gcc-m*:*",
+	}, fn["logs:PutLogEvents"])
+	assert.NotContains(t, fn, "lambda:InvokeFunction")
+	assert.NotContains(t, fn, "s3:DeleteObject")
+	assert.NotContains(t, fn, "xray:PutTraceSegments")
+
+	assert.NotContains(t, fn, "dynamodb:UpdateItem")
+
+	scope.xray = true
+	scope.indexTable = "arn:aws:dynamodb:us-west-2:123456789012:table/llama-index"
+	fn = actions(functionPolicy(scope))
+	assert.Equal(t, []string{"*"}, fn["xray:PutTraceSegments"])
+	assert.Equal(t, []string{scope.indexTable}, fn["dynamodb:UpdateItem"])
+	assert.NotContains(t, fn, "dynamodb:Scan")
+	dev = actions(developerPolicy(scope))
+	assert.Equal(t, []string{scope.indexTable}, dev["dynamodb:Scan"])
+}
+
+func TestSharedCachePolicy(t *testing.T) {
+	cfg := &cli.Config{
+		Store:   "s3://llama-bucket/obj/",
+		Region:  "us-west-2",
+		IAMRole: "arn:aws:iam::123456789012:role/llama-Role",
+	}
+	denied := func(scope *policyScope) []string {
+		for _, st := range developerPolicy(scope).Statement {
+			if st.Effect == "Deny" {
+				assert.Equal(t, []string{"s3:PutObject", "s3:DeleteObject"}, st.Action)
+				return st.Resource
+			}
+		}
+		return nil
+	}
+
+	scope, err := scopeFromConfig(cfg, []string{"gcc"})
+	require.NoError(t, err)
+	assert.Nil(t, denied(scope))
+
+	cfg.Cache.Namespace = "alice"
+	cfg.Cache.Shared = []string{"ci", "release"}
+	scope, err = scopeFromConfig(cfg, []string{"gcc"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"arn:aws:s3:::llama-bucket/obj/keys/results/ci/*",
+		"arn:aws:s3:::llama-bucket/obj/keys/results/release/*",
+	}, denied(scope))
+
+	cfg.Cache.Policy = daemon.CacheWriteOnly
+	scope, err = scopeFromConfig(cfg, []string{"gcc"})
+	require.NoError(t, err)
+	assert.Len(t, denied(scope), 2, "write-only still protects the shared namespaces")
+
+	cfg.Cache.Policy = daemon.CacheReadOnly
+	scope, err = scopeFromConfig(cfg, []string{"gcc"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:s3:::llama-bucket/obj/keys/results/*"}, denied(scope))
+
+	cfg.Cache.Shared = []string{"../objects"}
+	_, err = scopeFromConfig(cfg, []string{"gcc"})
+	assert.Error(t, err)
+}
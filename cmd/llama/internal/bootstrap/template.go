@@ -23,8 +23,27 @@ const CFTemplate = `{
       "Default": "llama",
       "AllowedPattern": "(?:[a-z0-9]+(?:[._-][a-z0-9]+)*/)*[a-z0-9]+(?:[._-][a-z0-9]+)*",
       "ConstraintDescription": "must be a valid ECR repository name"
+    },
+    "FunctionName": {
+      "Type": "String",
+      "Description": "The name for the llama Lambda function",
+      "Default": "gcc"
+    },
+    "FunctionImage": {
+      "Type": "String",
+      "Description": "The image URI for the Lambda function. Leave empty to create the function later with llama update-function",
+      "Default": ""
+    },
+    "Architecture": {
+      "Type": "String",
+      "Description": "The architecture of the Lambda function",
+      "Default": "x86_64",
+      "AllowedValues": ["x86_64", "arm64"]
     }
   },
+  "Conditions": {
+    "HasFunction": {"Fn::Not": [{"Fn::Equals": [{"Ref": "FunctionImage"}, ""]}]}
+  },
   "Outputs": {
     "ObjectStore": {
       "Description": "URL to the Llama object store",
@@ -114,10 +133,47 @@ const CFTemplate = `{
                 }
               ]
             }
+          },
+          {
+            "PolicyName": "llama-receive-async-jobs",
+            "PolicyDocument": {
+              "Version": "2012-10-17",
+              "Statement": [
+                {
+                  "Sid": "LlamaReceiveAsyncJobs",
+                  "Effect": "Allow",
+                  "Action": [
+                    "sqs:ReceiveMessage",
+                    "sqs:DeleteMessage",
+                    "sqs:GetQueueAttributes"
+                  ],
+                  "Resource": {"Fn::Sub": "arn:${AWS::Partition}:sqs:${AWS::Region}:${AWS::AccountId}:llama-async-*"}
+                }
+              ]
+            }
           }
         ]
       }
     },
+    "Function": {
+      "Type": "AWS::Lambda::Function",
+      "Condition": "HasFunction",
+      "Properties": {
+        "FunctionName": {"Ref": "FunctionName"},
+        "PackageType": "Image",
+        "Code": {"ImageUri": {"Ref": "FunctionImage"}},
+        "Role": {"Fn::GetAtt": ["Role", "Arn"]},
+        "MemorySize": 1769,
+        "Timeout": 60,
+        "Architectures": [{"Ref": "Architecture"}],
+        "Environment": {
+          "Variables": {
+            "LLAMA_OBJECT_STORE": {"Fn::Sub": "s3://${Bucket}/obj/"}
+          }
+        },
+        "Tags": [{"Key": "LlamaFunction", "Value": "true"}]
+      }
+    },
     "Repository": {
       "Type": "AWS::ECR::Repository",
       "Properties": {
@@ -0,0 +1,201 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// terraformTemplate describes the same resources as CFTemplate, for
+// teams who manage their infrastructure with Terraform. The outputs
+// are named after the llama.json settings they belong in.
+var terraformTemplate = template.Must(template.New("terraform").Parse(`# Llama's AWS resources, generated by ` + "`llama bootstrap -output terraform`" + `.
+#
+# After applying, copy the outputs into ~/.llama/llama.json as
+# "object_store", "iam_role" and "ecr_repository".
+
+variable "ecr_repository_name" {
+  description = "The name for the llama ECR repository"
+  type        = string
+  default     = "llama"
+}
+
+variable "function_name" {
+  description = "The name for the llama Lambda function"
+  type        = string
+  default     = "gcc"
+}
+
+variable "function_image" {
+  description = "The image URI for the Lambda function. Leave empty to create the function later with llama update-function"
+  type        = string
+  default     = ""
+}
+
+variable "architecture" {
+  description = "The architecture of the Lambda function"
+  type        = string
+  default     = "{{.Architecture}}"
+}
+
+data "aws_partition" "current" {}
+data "aws_region" "current" {}
+data "aws_caller_identity" "current" {}
+
+resource "aws_s3_bucket" "llama" {
+  bucket_prefix = "llama-"
+}
+
+resource "aws_s3_bucket_lifecycle_configuration" "llama" {
+  bucket = aws_s3_bucket.llama.id
+
+  rule {
+    id     = "Expire old objects"
+    status = "Enabled"
+    filter {
+      prefix = "obj/"
+    }
+    expiration {
+      days = 28
+    }
+  }
+}
+
+resource "aws_iam_role" "llama" {
+  name_prefix        = "llama-"
+  description        = "The role used to invoke llama Lambda functions"
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Effect    = "Allow"
+      Principal = { Service = "lambda.amazonaws.com" }
+      Action    = "sts:AssumeRole"
+    }]
+  })
+}
+
+resource "aws_iam_role_policy_attachment" "llama_basic_execution" {
+  role       = aws_iam_role.llama.name
+  policy_arn = "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"
+}
+
+resource "aws_iam_role_policy" "llama_access_object_store" {
+  name = "llama-access-object-store"
+  role = aws_iam_role.llama.id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Sid    = "LlamaAccessObjectStore"
+      Effect = "Allow"
+      Action = [
+        "s3:PutObject",
+        "s3:GetObject",
+        "s3:ListBucketMultipartUploads",
+        "s3:ListBucket",
+      ]
+      Resource = [
+        aws_s3_bucket.llama.arn,
+        "${aws_s3_bucket.llama.arn}/*",
+      ]
+    }]
+  })
+}
+
+resource "aws_iam_role_policy" "llama_receive_async_jobs" {
+  name = "llama-receive-async-jobs"
+  role = aws_iam_role.llama.id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Sid    = "LlamaReceiveAsyncJobs"
+      Effect = "Allow"
+      Action = [
+        "sqs:ReceiveMessage",
+        "sqs:DeleteMessage",
+        "sqs:GetQueueAttributes",
+      ]
+      Resource = "arn:${data.aws_partition.current.partition}:sqs:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:llama-async-*"
+    }]
+  })
+}
+
+resource "aws_ecr_repository" "llama" {
+  name = var.ecr_repository_name
+}
+
+resource "aws_lambda_function" "llama" {
+  count = var.function_image == "" ? 0 : 1
+
+  function_name = var.function_name
+  package_type  = "Image"
+  image_uri     = var.function_image
+  role          = aws_iam_role.llama.arn
+  memory_size   = 1769
+  timeout       = 60
+  architectures = [var.architecture]
+
+  environment {
+    variables = {
+      LLAMA_OBJECT_STORE = "s3://${aws_s3_bucket.llama.bucket}/obj/"
+    }
+  }
+
+  tags = {
+    LlamaFunction = "true"
+  }
+}
+
+output "object_store" {
+  value = "s3://${aws_s3_bucket.llama.bucket}/obj/"
+}
+
+output "iam_role" {
+  value = aws_iam_role.llama.arn
+}
+
+output "ecr_repository" {
+  value = aws_ecr_repository.llama.repository_url
+}
+`))
+
+// writeTemplate writes infrastructure-as-code describing llama's AWS
+// resources to `w`, in the requested format, instead of creating them.
+func writeTemplate(w io.Writer, format string, arch string) error {
+	if arch == "" {
+		arch = "x86_64"
+	}
+	switch format {
+	case "cloudformation":
+		var tpl map[string]interface{}
+		if err := json.Unmarshal([]byte(CFTemplate), &tpl); err != nil {
+			return err
+		}
+		params := tpl["Parameters"].(map[string]interface{})
+		params["Architecture"].(map[string]interface{})["Default"] = arch
+		out, err := json.MarshalIndent(tpl, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	case "terraform":
+		return terraformTemplate.Execute(w, struct{ Architecture string }{arch})
+	default:
+		return fmt.Errorf("unknown output format %q: expected cloudformation or terraform", format)
+	}
+}
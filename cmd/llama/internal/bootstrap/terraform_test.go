@@ -0,0 +1,59 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateMatchesJSON(t *testing.T) {
+	data, err := ioutil.ReadFile("template.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(data), CFTemplate)
+}
+
+func TestWriteTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeTemplate(&buf, "cloudformation", "arm64"))
+	var tpl struct {
+		Parameters map[string]struct{ Default string }
+		Resources  map[string]struct{ Type string }
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &tpl))
+	assert.Equal(t, "arm64", tpl.Parameters["Architecture"].Default)
+	assert.Equal(t, "AWS::Lambda::Function", tpl.Resources["Function"].Type)
+
+	buf.Reset()
+	require.NoError(t, writeTemplate(&buf, "terraform", ""))
+	out := buf.String()
+	assert.Contains(t, out, `default     = "x86_64"`)
+	for _, res := range []string{
+		`resource "aws_s3_bucket" "llama"`,
+		`resource "aws_iam_role" "llama"`,
+		`resource "aws_ecr_repository" "llama"`,
+		`resource "aws_lambda_function" "llama"`,
+		`output "object_store"`,
+	} {
+		assert.Contains(t, out, res)
+	}
+
+	assert.Error(t, writeTemplate(&buf, "pulumi", ""))
+}
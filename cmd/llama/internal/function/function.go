@@ -18,16 +18,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"os"
 	"os/exec"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/google/subcommands"
 	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/llama"
 )
 
 type UpdateFunctionCommand struct {
@@ -36,6 +38,8 @@ type UpdateFunctionCommand struct {
 	tag          string
 	memory       int64
 	timeout      time.Duration
+	arch         string
+	variants     string
 
 	create bool
 }
@@ -43,9 +47,20 @@ type UpdateFunctionCommand struct {
 type functionConfig struct {
 	name string
 
-	tag     string
-	memory  int64
-	timeout time.Duration
+	tag      string
+	memory   int64
+	timeout  time.Duration
+	arch     string
+	variants []llama.Variant
+
+	// The compiler the image records in its llama.ToolchainLabel,
+	// if we built one
+	toolchain string
+}
+
+var dockerPlatforms = map[string]string{
+	lambda.ArchitectureX8664: "linux/amd64",
+	lambda.ArchitectureArm64: "linux/arm64",
 }
 
 func (*UpdateFunctionCommand) Name() string     { return "update-function" }
@@ -62,6 +77,8 @@ func (c *UpdateFunctionCommand) SetFlags(flags *flag.FlagSet) {
 
 	flags.Int64Var(&c.memory, "memory", 0, "Specify the function memory size, in MB")
 	flags.DurationVar(&c.timeout, "timeout", 0, "Specify the function timeout")
+	flags.StringVar(&c.arch, "arch", "", "Specify the function architecture (x86_64 or arm64)")
+	flags.StringVar(&c.variants, "variants", "", "Publish variants of the function with other sizes, as MEMORY[:TIMEOUT],...")
 
 	flags.BoolVar(&c.create, "create", false, "Create the function if it does not exist")
 }
@@ -76,18 +93,47 @@ func (c *UpdateFunctionCommand) Execute(ctx context.Context, flag *flag.FlagSet,
 
 	var cfg functionConfig
 	cfg.name = args[0]
+	cfg.arch = c.arch
+	if cfg.arch == "" {
+		cfg.arch = global.Config.Architecture
+	}
+	if _, ok := dockerPlatforms[cfg.arch]; cfg.arch != "" && !ok {
+		log.Printf("Unknown architecture: %q", cfg.arch)
+		return subcommands.ExitUsageError
+	}
 
 	var err error
-	cfg.tag, err = c.buildImage(ctx, global, cfg.name)
-	if err != nil {
-		log.Printf("Building image: %s", err.Error())
+	if cfg.variants, err = parseVariants(c.variants); err != nil {
+		log.Printf("%s", err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	if err := c.deploy(ctx, global, &cfg); err != nil {
+		log.Printf("%s: %s", cfg.name, err.Error())
 		return subcommands.ExitFailure
 	}
 
+	return subcommands.ExitSuccess
+}
+
+// deploy builds and pushes the function's image, if requested, and
+// then creates or updates the function.
+func (c *UpdateFunctionCommand) deploy(ctx context.Context, global *cli.GlobalState, cfg *functionConfig) error {
+	var err error
+	cfg.tag, err = c.buildImage(ctx, global, cfg)
+	if err != nil {
+		return fmt.Errorf("building image: %w", err)
+	}
+
 	if cfg.tag != "" {
-		if err := c.pushTag(ctx, global, cfg.tag); err != nil {
-			log.Printf("Pushing image tag: %s", err.Error())
-			return subcommands.ExitFailure
+		for _, tag := range []string{toolchainTag(global, cfg.name), cfg.tag} {
+			if err := pushTag(global, tag); err != nil {
+				return fmt.Errorf("pushing image tag: %w", err)
+			}
+		}
+		cfg.toolchain, err = imageToolchain(cfg.tag)
+		if err != nil {
+			return fmt.Errorf("inspecting image: %w", err)
 		}
 	}
 
@@ -95,50 +141,45 @@ func (c *UpdateFunctionCommand) Execute(ctx context.Context, flag *flag.FlagSet,
 	cfg.timeout = c.timeout
 
 	if c.create {
-		err = createOrUpdateFunction(ctx, global, &cfg)
-	} else {
-		err = updateFunction(ctx, global, &cfg)
+		return createOrUpdateFunction(ctx, global, cfg)
 	}
-
-	if err != nil {
-		log.Printf("%s: %s", cfg.name, err.Error())
-		return subcommands.ExitFailure
-	}
-
-	return subcommands.ExitSuccess
+	return updateFunction(ctx, global, cfg)
 }
 
-func (c *UpdateFunctionCommand) buildImage(ctx context.Context, global *cli.GlobalState, functionName string) (string, error) {
-	tag := fmt.Sprintf("%s:%s", global.Config.ECRRepository, functionName)
+func (c *UpdateFunctionCommand) buildImage(ctx context.Context, global *cli.GlobalState, cfg *functionConfig) (string, error) {
+	toolchain := toolchainTag(global, cfg.name)
 	if c.build != "" && c.tag != "" {
 		return "", fmt.Errorf("-build and -tag are mutually exclusive")
 	} else if c.tag != "" {
-		if err := runSh("docker", "tag", c.tag, tag); err != nil {
+		if err := runSh("docker", "tag", c.tag, toolchain); err != nil {
 			return "", err
 		}
-		return tag, nil
 	} else if c.build != "" {
 		if c.buildRuntime != "" {
-			log.Printf("Building the llama runtime from %s...", c.buildRuntime)
-			cmd := exec.Command("docker", "build", "-t", "ghcr.io/nelhage/llama", c.buildRuntime)
-			cmd.Stderr = os.Stderr
-			cmd.Stdout = os.Stdout
-			if err := runCmd(cmd); err != nil {
+			if err := buildRuntime(c.buildRuntime, cfg.arch); err != nil {
 				return "", err
 			}
 		}
 		log.Printf("Building image from %s...", c.build)
-		cmd := exec.Command("docker", "build", "-t", tag, c.build)
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
-		return tag, runCmd(cmd)
+		if err := dockerBuild(dockerPlatform(cfg.arch), toolchain, c.build); err != nil {
+			return "", err
+		}
 	} else {
 		return "", nil
 	}
+	tag := functionTag(global, cfg.name)
+	return tag, buildRuntimeLayer(toolchain, defaultRuntimeImage, tag, cfg.arch)
 }
 
-func (c *UpdateFunctionCommand) pushTag(ctx context.Context, global *cli.GlobalState, tag string) error {
-	err := runSh("docker", "push", tag)
+// pushTag pushes `tag` to ECR, logging in first if we need to.
+func pushTag(global *cli.GlobalState, tag string) error {
+	return withECRLogin(global, "docker", "push", tag)
+}
+
+// withECRLogin runs a docker command that talks to ECR, logging in to
+// ECR and trying again if it fails.
+func withECRLogin(global *cli.GlobalState, args ...string) error {
+	err := runSh(args...)
 	if err == nil {
 		return nil
 	}
@@ -163,5 +204,19 @@ func (c *UpdateFunctionCommand) pushTag(ctx context.Context, global *cli.GlobalS
 		return err
 	}
 
-	return runSh("docker", "push", tag)
+	return runSh(args...)
+}
+
+// imageToolchain returns the compiler recorded in the
+// llama.ToolchainLabel of the image `tag`, or "" if it has none.
+func imageToolchain(tag string) (string, error) {
+	out, err := output("docker", "inspect", "--format", "{{json .Config.Labels}}", tag)
+	if err != nil {
+		return "", err
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(out), &labels); err != nil {
+		return "", err
+	}
+	return labels[llama.ToolchainLabel], nil
 }
@@ -18,12 +18,19 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/store/encstore"
 )
 
 const (
@@ -33,17 +40,132 @@ const (
 	defaultMemory = 1769
 
 	defaultTimeout = 60 * time.Second
+
+	// Lambda requires EFS filesystems to be mounted under /mnt
+	defaultEFSMountPath = "/mnt/llama"
 )
 
+func functionEnvironment(g *cli.GlobalState) (map[string]*string, error) {
+	env := map[string]*string{
+		"LLAMA_OBJECT_STORE": aws.String(g.Config.Store),
+	}
+	if g.Config.CompressionLevel != 0 {
+		env["LLAMA_COMPRESSION_LEVEL"] = aws.String(strconv.Itoa(g.Config.CompressionLevel))
+	}
+	if g.Config.S3Endpoint != "" {
+		env["LLAMA_S3_ENDPOINT"] = aws.String(g.Config.S3Endpoint)
+	}
+	if g.Config.S3PathStyle {
+		env["LLAMA_S3_PATH_STYLE"] = aws.String("1")
+	}
+	if g.Config.S3SkipVerify {
+		env["LLAMA_S3_INSECURE_SKIP_VERIFY"] = aws.String("1")
+	}
+	if g.Config.S3StorageClass != "" {
+		env["LLAMA_S3_STORAGE_CLASS"] = aws.String(g.Config.S3StorageClass)
+	}
+	if refresh := g.Config.S3RefreshAge(); refresh > 0 {
+		env["LLAMA_S3_REFRESH_AGE"] = aws.String(refresh.String())
+	}
+	if g.Config.StoreIndexTable != "" {
+		env["LLAMA_STORE_INDEX_TABLE"] = aws.String(g.Config.StoreIndexTable)
+		// Functions in failover regions share the table
+		env["LLAMA_STORE_INDEX_REGION"] = aws.String(g.Config.Region)
+	}
+	if g.Config.StoreHash != "" {
+		env["LLAMA_STORE_HASH"] = aws.String(g.Config.StoreHash)
+	}
+	if g.Config.StoreKey != "" {
+		key, err := encstore.ForFunction(g.Config.StoreKey)
+		if err != nil {
+			return nil, fmt.Errorf("store key: %w", err)
+		}
+		env["LLAMA_STORE_KEY"] = aws.String(key)
+	}
+	if g.Config.LogFormat != "" {
+		env[logging.FormatEnv] = aws.String(g.Config.LogFormat)
+	}
+	if g.Config.EFS.AccessPoint != "" {
+		env["LLAMA_CACHE_DIR"] = aws.String(efsMountPath(g))
+		if g.Config.EFS.SizeMB != 0 {
+			env["LLAMA_CACHE_DIR_MB"] = aws.String(strconv.FormatUint(g.Config.EFS.SizeMB, 10))
+		}
+	}
+	if hc := g.Config.Honeycomb; hc.APIKey != "" {
+		// The runtime exports its spans straight to Honeycomb
+		// over OTLP
+		headers := "x-honeycomb-team=" + url.QueryEscape(hc.APIKey)
+		if hc.Dataset != "" {
+			headers += ",x-honeycomb-dataset=" + url.QueryEscape(hc.Dataset)
+		}
+		env["OTEL_EXPORTER_OTLP_ENDPOINT"] = aws.String(honeycombEndpoint)
+		env["OTEL_EXPORTER_OTLP_PROTOCOL"] = aws.String("http/protobuf")
+		env["OTEL_EXPORTER_OTLP_HEADERS"] = aws.String(headers)
+	}
+	return env, nil
+}
+
+const honeycombEndpoint = "https://api.honeycomb.io"
+
+// tracingConfig returns the function's X-Ray tracing mode. With
+// active tracing, Lambda runs the X-Ray daemon alongside the runtime.
+func tracingConfig(g *cli.GlobalState) *lambda.TracingConfig {
+	mode := lambda.TracingModePassThrough
+	if g.Config.XRayTracing {
+		mode = lambda.TracingModeActive
+	}
+	return &lambda.TracingConfig{Mode: aws.String(mode)}
+}
+
+func efsMountPath(g *cli.GlobalState) string {
+	if g.Config.EFS.MountPath != "" {
+		return g.Config.EFS.MountPath
+	}
+	return defaultEFSMountPath
+}
+
+// efsConfig returns the filesystem and VPC configuration to attach
+// the configured EFS access point to a function, or nils if none is
+// configured. A function must run inside a VPC that can reach the
+// filesystem's mount targets in order to use it.
+func efsConfig(g *cli.GlobalState) ([]*lambda.FileSystemConfig, *lambda.VpcConfig, error) {
+	efs := &g.Config.EFS
+	if efs.AccessPoint == "" {
+		return nil, nil, nil
+	}
+	if len(efs.Subnets) == 0 {
+		return nil, nil, fmt.Errorf("efs: subnets are required to mount %s", efs.AccessPoint)
+	}
+	mount := efsMountPath(g)
+	if !strings.HasPrefix(mount, "/mnt/") {
+		return nil, nil, fmt.Errorf("efs: mount path %q must be under /mnt/", mount)
+	}
+	fs := []*lambda.FileSystemConfig{{
+		Arn:            aws.String(efs.AccessPoint),
+		LocalMountPath: aws.String(mount),
+	}}
+	vpc := &lambda.VpcConfig{
+		SubnetIds:        aws.StringSlice(efs.Subnets),
+		SecurityGroupIds: aws.StringSlice(efs.SecurityGroups),
+	}
+	return fs, vpc, nil
+}
+
 func createOrUpdateFunction(ctx context.Context, g *cli.GlobalState, cfg *functionConfig) error {
+	env, err := functionEnvironment(g)
+	if err != nil {
+		return err
+	}
+	fs, vpc, err := efsConfig(g)
+	if err != nil {
+		return err
+	}
 	client := lambda.New(g.MustSession())
 	args := &lambda.CreateFunctionInput{
 		FunctionName: aws.String(cfg.name),
 		Role:         aws.String(g.Config.IAMRole),
 		Environment: &lambda.Environment{
-			Variables: map[string]*string{
-				"LLAMA_OBJECT_STORE": aws.String(g.Config.Store),
-			},
+			Variables: env,
 		},
 		Tags: map[string]*string{
 			"LlamaFunction": aws.String("true"),
@@ -51,7 +173,10 @@ func createOrUpdateFunction(ctx context.Context, g *cli.GlobalState, cfg *functi
 		Code: &lambda.FunctionCode{
 			ImageUri: aws.String(cfg.tag),
 		},
-		PackageType: aws.String(lambda.PackageTypeImage),
+		PackageType:       aws.String(lambda.PackageTypeImage),
+		FileSystemConfigs: fs,
+		VpcConfig:         vpc,
+		TracingConfig:     tracingConfig(g),
 	}
 	if cfg.memory != 0 {
 		args.MemorySize = &cfg.memory
@@ -63,10 +188,22 @@ func createOrUpdateFunction(ctx context.Context, g *cli.GlobalState, cfg *functi
 	} else {
 		args.Timeout = aws.Int64(int64(defaultTimeout.Seconds()))
 	}
+	if cfg.arch != "" {
+		args.Architectures = []*string{aws.String(cfg.arch)}
+	}
+	if cfg.toolchain != "" {
+		args.Tags[llama.ToolchainTag] = aws.String(cfg.toolchain)
+	}
 
-	_, err := client.CreateFunction(args)
+	_, err = client.CreateFunction(args)
 	if err == nil {
-		return waitForFunction(ctx, client, cfg)
+		if err := waitForFunction(ctx, client, cfg); err != nil {
+			return err
+		}
+		if err := publishWarmAlias(g, client, cfg); err != nil {
+			return err
+		}
+		return publishVariants(ctx, g, cfg)
 	}
 	if reqerr, ok := err.(awserr.RequestFailure); ok && reqerr.StatusCode() == 409 {
 		return updateFunction(ctx, g, cfg)
@@ -75,15 +212,24 @@ func createOrUpdateFunction(ctx context.Context, g *cli.GlobalState, cfg *functi
 }
 
 func updateFunction(ctx context.Context, g *cli.GlobalState, cfg *functionConfig) error {
+	env, err := functionEnvironment(g)
+	if err != nil {
+		return err
+	}
+	fs, vpc, err := efsConfig(g)
+	if err != nil {
+		return err
+	}
 	client := lambda.New(g.MustSession())
 	args := &lambda.UpdateFunctionConfigurationInput{
 		FunctionName: aws.String(cfg.name),
 		Role:         aws.String(g.Config.IAMRole),
 		Environment: &lambda.Environment{
-			Variables: map[string]*string{
-				"LLAMA_OBJECT_STORE": aws.String(g.Config.Store),
-			},
+			Variables: env,
 		},
+		FileSystemConfigs: fs,
+		VpcConfig:         vpc,
+		TracingConfig:     tracingConfig(g),
 	}
 	if cfg.memory != 0 {
 		args.MemorySize = &cfg.memory
@@ -101,12 +247,134 @@ func updateFunction(ctx context.Context, g *cli.GlobalState, cfg *functionConfig
 			FunctionName: aws.String(cfg.name),
 			ImageUri:     aws.String(cfg.tag),
 		}
-		if _, err := client.UpdateFunctionCode(codeArgs); err != nil {
+		if cfg.arch != "" {
+			codeArgs.Architectures = []*string{aws.String(cfg.arch)}
+		}
+		fn, err := client.UpdateFunctionCode(codeArgs)
+		if err != nil {
 			return err
 		}
+		// Overwrite any toolchain recorded for the old image,
+		// even with nothing, so llamacc doesn't check against
+		// a compiler the function no longer has
+		if _, err := client.TagResource(&lambda.TagResourceInput{
+			Resource: fn.FunctionArn,
+			Tags:     map[string]*string{llama.ToolchainTag: aws.String(cfg.toolchain)},
+		}); err != nil {
+			return fmt.Errorf("tagging function: %w", err)
+		}
 
 	}
-	return waitForFunction(ctx, client, cfg)
+	if err := waitForFunction(ctx, client, cfg); err != nil {
+		return err
+	}
+	if err := publishWarmAlias(g, client, cfg); err != nil {
+		return err
+	}
+	return publishVariants(ctx, g, cfg)
+}
+
+// publishWarmAlias publishes the function's current code and
+// configuration as a new version, and points the warm-pool alias at
+// it. The daemon attaches provisioned concurrency to the alias, which
+// Lambda does not support for $LATEST.
+func publishWarmAlias(g *cli.GlobalState, client *lambda.Lambda, cfg *functionConfig) error {
+	if g.Config.WarmPoolSize == 0 {
+		return nil
+	}
+	return publishAlias(client, cfg, daemon.WarmPoolAlias)
+}
+
+// publishAlias publishes the function's current code and
+// configuration as a new version, and points `alias` at it.
+func publishAlias(client *lambda.Lambda, cfg *functionConfig, alias string) error {
+	version, err := client.PublishVersion(&lambda.PublishVersionInput{
+		FunctionName: aws.String(cfg.name),
+	})
+	if err != nil {
+		return fmt.Errorf("publishing version: %w", err)
+	}
+	log.Printf("Published version %s, updating alias %s...", *version.Version, alias)
+	_, err = client.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    aws.String(cfg.name),
+		Name:            aws.String(alias),
+		FunctionVersion: version.Version,
+	})
+	if reqerr, ok := err.(awserr.RequestFailure); ok && reqerr.StatusCode() == 404 {
+		_, err = client.CreateAlias(&lambda.CreateAliasInput{
+			FunctionName:    aws.String(cfg.name),
+			Name:            aws.String(alias),
+			FunctionVersion: version.Version,
+		})
+	}
+	return err
+}
+
+// publishVariants deploys a variant of the function for each of
+// `cfg.variants`: a function of its own, named by
+// llama.VariantFunction, running the same image with the same
+// configuration, but the variant's memory and timeout. The function
+// itself is left alone, so invocations in flight aren't disturbed.
+func publishVariants(ctx context.Context, g *cli.GlobalState, cfg *functionConfig) error {
+	if len(cfg.variants) == 0 {
+		return nil
+	}
+	client := lambda.New(g.MustSession())
+	base, err := client.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: aws.String(cfg.name),
+	})
+	if err != nil {
+		return err
+	}
+	// Pin the image the function runs, in case its tag has moved
+	image := aws.StringValue(base.Code.ResolvedImageUri)
+	if image == "" {
+		image = aws.StringValue(base.Code.ImageUri)
+	}
+	for _, v := range cfg.variants {
+		timeout := v.Timeout
+		if timeout == 0 {
+			timeout = time.Duration(*base.Configuration.Timeout) * time.Second
+		}
+		variant := functionConfig{
+			name:      llama.VariantFunction(cfg.name, v.Memory, timeout),
+			tag:       image,
+			memory:    v.Memory,
+			timeout:   timeout,
+			arch:      cfg.arch,
+			toolchain: aws.StringValue(base.Tags[llama.ToolchainTag]),
+		}
+		log.Printf("Deploying variant %s with %dMB and timeout %s...", variant.name, v.Memory, timeout)
+		if err := createOrUpdateFunction(ctx, g, &variant); err != nil {
+			return fmt.Errorf("variant %s: %w", variant.name, err)
+		}
+	}
+	return nil
+}
+
+// parseVariants parses a list of function variants, in the form
+// MEMORY[:TIMEOUT],...
+func parseVariants(spec string) ([]llama.Variant, error) {
+	var out []llama.Variant
+	for _, word := range strings.Split(spec, ",") {
+		if word == "" {
+			continue
+		}
+		var v llama.Variant
+		var err error
+		mem := word
+		if colon := strings.IndexByte(word, ':'); colon >= 0 {
+			mem = word[:colon]
+			if v.Timeout, err = time.ParseDuration(word[colon+1:]); err != nil {
+				return nil, fmt.Errorf("variant %q: %w", word, err)
+			}
+		}
+		if v.Memory, err = strconv.ParseInt(mem, 10, 64); err != nil {
+			return nil, fmt.Errorf("variant %q: bad memory size", word)
+		}
+		out = append(out, v)
+	}
+	return out, nil
 }
 
 func waitForFunction(ctx context.Context, client *lambda.Lambda, config *functionConfig) error {
@@ -0,0 +1,115 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/llama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariants(t *testing.T) {
+	vs, err := parseVariants("3008,10240:15m")
+	require.NoError(t, err)
+	assert.Equal(t, []llama.Variant{
+		{Memory: 3008},
+		{Memory: 10240, Timeout: 15 * time.Minute},
+	}, vs)
+
+	vs, err = parseVariants("")
+	require.NoError(t, err)
+	assert.Empty(t, vs)
+
+	for _, bad := range []string{"big", "3008:forever"} {
+		_, err := parseVariants(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestEFSConfig(t *testing.T) {
+	g := &cli.GlobalState{Config: &cli.Config{}}
+	fs, vpc, err := efsConfig(g)
+	require.NoError(t, err)
+	assert.Nil(t, fs)
+	assert.Nil(t, vpc)
+	env, err := functionEnvironment(g)
+	require.NoError(t, err)
+	assert.NotContains(t, env, "LLAMA_CACHE_DIR")
+
+	g.Config.EFS.AccessPoint = "arn:aws:elasticfilesystem:us-west-2:123456789012:access-point/fsap-1234"
+	_, _, err = efsConfig(g)
+	assert.Error(t, err, "no subnets")
+
+	g.Config.EFS.Subnets = []string{"subnet-1", "subnet-2"}
+	g.Config.EFS.SecurityGroups = []string{"sg-1"}
+	fs, vpc, err = efsConfig(g)
+	require.NoError(t, err)
+	require.Len(t, fs, 1)
+	assert.Equal(t, g.Config.EFS.AccessPoint, *fs[0].Arn)
+	assert.Equal(t, "/mnt/llama", *fs[0].LocalMountPath)
+	assert.Equal(t, []string{"subnet-1", "subnet-2"}, aws.StringValueSlice(vpc.SubnetIds))
+	assert.Equal(t, []string{"sg-1"}, aws.StringValueSlice(vpc.SecurityGroupIds))
+	env, err = functionEnvironment(g)
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/llama", *env["LLAMA_CACHE_DIR"])
+
+	g.Config.EFS.MountPath = "/var/cache"
+	_, _, err = efsConfig(g)
+	assert.Error(t, err, "mount outside /mnt")
+}
+
+func TestTracingEnvironment(t *testing.T) {
+	g := &cli.GlobalState{Config: &cli.Config{}}
+	env, err := functionEnvironment(g)
+	require.NoError(t, err)
+	assert.NotContains(t, env, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	assert.Equal(t, "PassThrough", *tracingConfig(g).Mode)
+
+	g.Config.Honeycomb.APIKey = "key/1"
+	g.Config.Honeycomb.Dataset = "llama"
+	g.Config.XRayTracing = true
+	env, err = functionEnvironment(g)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.honeycomb.io", *env["OTEL_EXPORTER_OTLP_ENDPOINT"])
+	assert.Equal(t, "x-honeycomb-team=key%2F1,x-honeycomb-dataset=llama", *env["OTEL_EXPORTER_OTLP_HEADERS"])
+	assert.Equal(t, "Active", *tracingConfig(g).Mode)
+}
+
+func TestStoreEnvironment(t *testing.T) {
+	g := &cli.GlobalState{Config: &cli.Config{Store: "s3://bucket/obj/", Region: "us-west-2"}}
+	env, err := functionEnvironment(g)
+	require.NoError(t, err)
+	assert.NotContains(t, env, "LLAMA_S3_STORAGE_CLASS")
+	assert.NotContains(t, env, "LLAMA_S3_REFRESH_AGE")
+	assert.NotContains(t, env, "LLAMA_STORE_INDEX_TABLE")
+	assert.NotContains(t, env, "LLAMA_STORE_HASH")
+
+	g.Config.S3StorageClass = "INTELLIGENT_TIERING"
+	g.Config.S3ExpireDays = 30
+	g.Config.StoreIndexTable = "llama-index"
+	g.Config.StoreHash = "blake3"
+	env, err = functionEnvironment(g)
+	require.NoError(t, err)
+	assert.Equal(t, "INTELLIGENT_TIERING", *env["LLAMA_S3_STORAGE_CLASS"])
+	assert.Equal(t, "360h0m0s", *env["LLAMA_S3_REFRESH_AGE"])
+	assert.Equal(t, "llama-index", *env["LLAMA_STORE_INDEX_TABLE"])
+	assert.Equal(t, "us-west-2", *env["LLAMA_STORE_INDEX_REGION"])
+	assert.Equal(t, "blake3", *env["LLAMA_STORE_HASH"])
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/llama"
+)
+
+// A function's image is built in two parts: the toolchain image,
+// built from the user's Dockerfile and pushed as
+// REPOSITORY:FUNCTION-toolchain, and on top of it a single layer
+// containing the llama runtime, pushed as REPOSITORY:FUNCTION. This
+// lets `llama update-runtime` replace the runtime without rebuilding
+// the toolchain, and lets Lambda and ECR reuse the toolchain's
+// layers.
+
+const defaultRuntimeImage = "ghcr.io/nelhage/llama"
+
+var runtimeLayer = template.Must(template.New("Dockerfile").Parse(
+	`FROM {{.Runtime}} as llama
+FROM {{.Toolchain}}
+COPY --from=llama /llama_runtime /llama_runtime
+ENTRYPOINT ["/llama_runtime"]
+`))
+
+func functionTag(global *cli.GlobalState, name string) string {
+	return fmt.Sprintf("%s:%s", global.Config.ECRRepository, name)
+}
+
+func toolchainTag(global *cli.GlobalState, name string) string {
+	return functionTag(global, name) + "-toolchain"
+}
+
+func dockerPlatform(arch string) []string {
+	if arch == "" {
+		return nil
+	}
+	return []string{"--platform", dockerPlatforms[arch]}
+}
+
+func dockerBuild(platform []string, tag, dir string) error {
+	cmd := exec.Command("docker", append(append([]string{"build"}, platform...), "-t", tag, dir)...)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	return runCmd(cmd)
+}
+
+// buildRuntime builds the llama runtime image from the checkout in
+// `dir`, and tags it as `defaultRuntimeImage`.
+func buildRuntime(dir, arch string) error {
+	log.Printf("Building the llama runtime from %s...", dir)
+	return dockerBuild(dockerPlatform(arch), defaultRuntimeImage, dir)
+}
+
+func runtimeDockerfile(toolchain, runtime string) ([]byte, error) {
+	var out bytes.Buffer
+	err := runtimeLayer.Execute(&out, struct{ Runtime, Toolchain string }{runtime, toolchain})
+	return out.Bytes(), err
+}
+
+// buildRuntimeLayer builds `tag` by adding the runtime from the
+// `runtime` image to the `toolchain` image.
+func buildRuntimeLayer(toolchain, runtime, tag, arch string) error {
+	dockerfile, err := runtimeDockerfile(toolchain, runtime)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "llama-runtime")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(path.Join(dir, "Dockerfile"), dockerfile, 0644); err != nil {
+		return err
+	}
+	log.Printf("Adding the llama runtime from %s...", runtime)
+	return dockerBuild(dockerPlatform(arch), tag, dir)
+}
+
+type UpdateRuntimeCommand struct {
+	runtime      string
+	buildRuntime string
+}
+
+func (*UpdateRuntimeCommand) Name() string { return "update-runtime" }
+func (*UpdateRuntimeCommand) Synopsis() string {
+	return "Update the llama runtime in functions, without rebuilding their images"
+}
+func (*UpdateRuntimeCommand) Usage() string {
+	return `update-runtime [options] FUNCTION-NAME...
+
+Replace the llama runtime in each function's image with the current
+one, reusing the toolchain image from the function's last
+update-function.
+`
+}
+
+func (c *UpdateRuntimeCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.runtime, "runtime", defaultRuntimeImage, "Take the runtime from this image")
+	flags.StringVar(&c.buildRuntime, "build-runtime", "", "Build a copy of the llama runtime image from a checkout")
+}
+
+func (c *UpdateRuntimeCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	if flag.NArg() == 0 {
+		log.Printf("Usage: %s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if c.buildRuntime != "" && c.runtime != defaultRuntimeImage {
+		log.Printf("-runtime and -build-runtime are mutually exclusive")
+		return subcommands.ExitUsageError
+	}
+
+	built := map[string]bool{}
+	for _, name := range flag.Args() {
+		if err := c.update(ctx, global, name, built); err != nil {
+			log.Printf("%s: %s", name, err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *UpdateRuntimeCommand) update(ctx context.Context, global *cli.GlobalState, name string, built map[string]bool) error {
+	client := lambda.New(global.MustSession())
+	fn, err := client.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(name),
+	})
+	if err != nil {
+		return err
+	}
+	cfg := functionConfig{name: name}
+	if len(fn.Architectures) > 0 {
+		cfg.arch = aws.StringValue(fn.Architectures[0])
+	}
+	variants, err := llama.ListVariants(client, name)
+	if err != nil {
+		return fmt.Errorf("listing variants: %w", err)
+	}
+	// The first is the function itself
+	cfg.variants = variants[1:]
+
+	if c.buildRuntime != "" && !built[cfg.arch] {
+		if err := buildRuntime(c.buildRuntime, cfg.arch); err != nil {
+			return fmt.Errorf("building runtime: %w", err)
+		}
+		built[cfg.arch] = true
+	}
+
+	toolchain := toolchainTag(global, name)
+	if err := withECRLogin(global, "docker", "pull", toolchain); err != nil {
+		// Functions last updated before we split their images
+		// have no toolchain image; adopt their current image
+		// as one. The runtime we add will shadow the one it
+		// contains.
+		current := functionTag(global, name)
+		log.Printf("No toolchain image for %s, adopting %s...", name, current)
+		if err := withECRLogin(global, "docker", "pull", current); err != nil {
+			return err
+		}
+		if err := runSh("docker", "tag", current, toolchain); err != nil {
+			return err
+		}
+	}
+
+	cfg.tag = functionTag(global, name)
+	if err := buildRuntimeLayer(toolchain, c.runtime, cfg.tag, cfg.arch); err != nil {
+		return fmt.Errorf("building image: %w", err)
+	}
+	for _, tag := range []string{toolchain, cfg.tag} {
+		if err := pushTag(global, tag); err != nil {
+			return fmt.Errorf("pushing image tag: %w", err)
+		}
+	}
+	return updateFunction(ctx, global, &cfg)
+}
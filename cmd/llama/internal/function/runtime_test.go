@@ -0,0 +1,32 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeDockerfile(t *testing.T) {
+	df, err := runtimeDockerfile("123.dkr.ecr.us-west-2.amazonaws.com/llama:gcc-toolchain", defaultRuntimeImage)
+	require.NoError(t, err)
+	assert.Equal(t, `FROM ghcr.io/nelhage/llama as llama
+FROM 123.dkr.ecr.us-west-2.amazonaws.com/llama:gcc-toolchain
+COPY --from=llama /llama_runtime /llama_runtime
+ENTRYPOINT ["/llama_runtime"]
+`, string(df))
+}
@@ -0,0 +1,314 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/llama"
+)
+
+type ToolchainCommand struct {
+	cc, cxx string
+	extra   string
+	arch    string
+	create  bool
+	dryRun  bool
+}
+
+func (*ToolchainCommand) Name() string { return "toolchain" }
+func (*ToolchainCommand) Synopsis() string {
+	return "Build a function image containing the local compiler"
+}
+func (*ToolchainCommand) Usage() string {
+	return `toolchain sync [options] FUNCTION-NAME
+
+Inspect the local C and C++ compilers, build a function image which
+installs exactly the same compiler and C library packages, and update
+FUNCTION-NAME to use it. Only Debian and Ubuntu hosts are supported.
+`
+}
+
+func (c *ToolchainCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.cc, "cc", "cc", "The local C compiler")
+	flags.StringVar(&c.cxx, "cxx", "c++", "The local C++ compiler")
+	flags.StringVar(&c.extra, "extra-packages", "", "Additional Debian packages to install, separated by spaces")
+	flags.StringVar(&c.arch, "arch", "", "Specify the function architecture (x86_64 or arm64); must match the local compiler")
+	flags.BoolVar(&c.create, "create", false, "Create the function if it does not exist")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "Print the generated Dockerfile instead of building it")
+}
+
+func (c *ToolchainCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	args := flag.Args()
+	if len(args) != 2 || args[0] != "sync" {
+		log.Printf("Usage: %s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+
+	tc, err := detectToolchain(c.cc, c.cxx)
+	if err != nil {
+		log.Printf("Inspecting local toolchain: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	log.Printf("Found %s %s for %s on %s", tc.Family, tc.Version, tc.Machine, tc.Base)
+	tc.Extra = strings.Fields(c.extra)
+
+	dockerfile, err := tc.dockerfile()
+	if err != nil {
+		log.Printf("Generating Dockerfile: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	if c.dryRun {
+		os.Stdout.Write(dockerfile)
+		return subcommands.ExitSuccess
+	}
+
+	var cfg functionConfig
+	cfg.name = args[1]
+	cfg.arch = c.arch
+	if cfg.arch == "" {
+		cfg.arch = global.Config.Architecture
+	}
+	if cfg.arch == "" {
+		cfg.arch = tc.arch()
+	}
+	if cfg.arch != tc.arch() {
+		log.Printf("The local compiler targets %s, but the function is %s", tc.Machine, cfg.arch)
+		return subcommands.ExitUsageError
+	}
+
+	dir, err := ioutil.TempDir("", "llama-toolchain")
+	if err != nil {
+		log.Printf("Creating build directory: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(path.Join(dir, "Dockerfile"), dockerfile, 0644); err != nil {
+		log.Printf("Writing Dockerfile: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	update := UpdateFunctionCommand{build: dir, arch: cfg.arch, create: c.create}
+	if err := update.deploy(ctx, global, &cfg); err != nil {
+		log.Printf("%s: %s", cfg.name, err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type debPackage struct {
+	Name    string
+	Version string
+}
+
+// A toolchain describes a local compiler, and the Debian packages
+// needed to reproduce it exactly.
+type toolchain struct {
+	// "gcc" or "clang"
+	Family  string
+	Version string
+	// The compiler's target triple, from -dumpmachine
+	Machine string
+	// The base image matching the local distribution
+	Base string
+	// The paths of the C and C++ compiler binaries
+	CC, CXX  string
+	Packages []debPackage
+	// Additional, unpinned, packages to install
+	Extra []string
+}
+
+func (t *toolchain) arch() string {
+	switch strings.SplitN(t.Machine, "-", 2)[0] {
+	case "x86_64":
+		return lambda.ArchitectureX8664
+	case "aarch64":
+		return lambda.ArchitectureArm64
+	}
+	return ""
+}
+
+var dockerfileTemplate = template.Must(template.New("Dockerfile").Parse(
+	`FROM ghcr.io/nelhage/llama as llama
+FROM {{.Base}}
+ENV DEBIAN_FRONTEND noninteractive
+RUN apt-get update && apt-get -y install --no-install-recommends ca-certificates
+{{- range .Packages}} \
+        {{.Name}}={{.Version}}
+{{- end}}
+{{- range .Extra}} \
+        {{.}}
+{{- end}} && \
+    apt-get clean
+RUN ln -sf {{.CC}} /usr/local/bin/cc && ln -sf {{.CXX}} /usr/local/bin/c++
+LABEL {{.Label}}="{{.Family}} {{.Version}} {{.Machine}}"
+COPY --from=llama /llama_runtime /llama_runtime
+WORKDIR /
+ENTRYPOINT ["/llama_runtime"]
+`))
+
+// Label is the image label under which we record the compiler, for
+// llamacc to check the local compiler against
+func (t *toolchain) Label() string {
+	return llama.ToolchainLabel
+}
+
+func (t *toolchain) dockerfile() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := dockerfileTemplate.Execute(&buf, t); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func output(args ...string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	if err := runCmd(cmd); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func resolveBinary(name string) (string, error) {
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(p)
+}
+
+func detectToolchain(cc, cxx string) (*toolchain, error) {
+	var tc toolchain
+
+	osRelease, err := ioutil.ReadFile("/etc/os-release")
+	if err != nil {
+		return nil, err
+	}
+	if tc.Base, err = baseImage(parseOSRelease(osRelease)); err != nil {
+		return nil, err
+	}
+
+	if tc.CC, err = resolveBinary(cc); err != nil {
+		return nil, err
+	}
+	if tc.CXX, err = resolveBinary(cxx); err != nil {
+		return nil, err
+	}
+	probe, err := llama.ProbeCompiler(tc.CC)
+	if err != nil {
+		return nil, err
+	}
+	tc.Family, tc.Version, tc.Machine = probe.Family, probe.Version, probe.Machine
+
+	// Pin every package which contributes to compilation: the
+	// drivers, the compilers proper, and the C and C++ standard
+	// library headers.
+	paths := []string{tc.CC, tc.CXX, "/usr/include/stdio.h"}
+	for _, q := range [][]string{
+		{tc.CC, "-print-prog-name=cc1"},
+		{tc.CXX, "-print-prog-name=cc1plus"},
+		{tc.CXX, "-print-file-name=libstdc++.so"},
+	} {
+		if p, err := output(q...); err == nil && path.IsAbs(p) {
+			paths = append(paths, p)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		out, err := output("dpkg", "-S", p)
+		if err != nil {
+			return nil, fmt.Errorf("finding package for %s: %w", p, err)
+		}
+		name, err := parseDpkgSearch(out)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		version, err := output("dpkg-query", "-W", "-f=${Version}", name)
+		if err != nil {
+			return nil, err
+		}
+		tc.Packages = append(tc.Packages, debPackage{Name: name, Version: version})
+	}
+	return &tc, nil
+}
+
+// parseOSRelease parses the KEY=VALUE lines of /etc/os-release
+func parseOSRelease(data []byte) map[string]string {
+	out := make(map[string]string)
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out[line[:eq]] = strings.Trim(line[eq+1:], `"'`)
+	}
+	return out
+}
+
+func baseImage(osRelease map[string]string) (string, error) {
+	id := osRelease["ID"]
+	if id != "debian" && id != "ubuntu" {
+		return "", fmt.Errorf("unsupported distribution %q: only Debian and Ubuntu are supported", id)
+	}
+	release := osRelease["VERSION_CODENAME"]
+	if release == "" {
+		release = osRelease["VERSION_ID"]
+	}
+	if release == "" {
+		return "", fmt.Errorf("can't determine %s release", id)
+	}
+	return id + ":" + release, nil
+}
+
+// parseDpkgSearch returns the package named in the output of `dpkg
+// -S PATH`, which looks like `gcc-9: /usr/bin/gcc-9` or
+// `libc6-dev:amd64: /usr/include/stdio.h`, possibly preceded by
+// lines describing diversions.
+func parseDpkgSearch(out string) (string, error) {
+	for _, line := range strings.Split(out, "\n") {
+		colon := strings.Index(line, ": ")
+		if colon < 0 || strings.HasPrefix(line, "diversion ") {
+			continue
+		}
+		name := strings.SplitN(line[:colon], ", ", 2)[0]
+		return strings.SplitN(name, ":", 2)[0], nil
+	}
+	return "", fmt.Errorf("unexpected dpkg output: %q", out)
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseImage(t *testing.T) {
+	cases := []struct {
+		osRelease string
+		want      string
+		err       bool
+	}{
+		{"NAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID=\"20.04\"\nVERSION_CODENAME=focal\n", "ubuntu:focal", false},
+		{"ID=debian\nVERSION_ID=\"12\"\n", "debian:12", false},
+		{"ID=fedora\nVERSION_ID=34\n", "", true},
+	}
+	for _, tc := range cases {
+		got, err := baseImage(parseOSRelease([]byte(tc.osRelease)))
+		if tc.err {
+			assert.Error(t, err, tc.osRelease)
+		} else if assert.NoError(t, err, tc.osRelease) {
+			assert.Equal(t, tc.want, got)
+		}
+	}
+}
+
+func TestParseDpkgSearch(t *testing.T) {
+	cases := []struct {
+		out  string
+		want string
+	}{
+		{"gcc-9: /usr/bin/x86_64-linux-gnu-gcc-9", "gcc-9"},
+		{"libc6-dev:amd64: /usr/include/stdio.h", "libc6-dev"},
+		{"diversion by foo from: /usr/bin/cc\ndiversion by foo to: /usr/bin/cc.real\nlibfoo, libbar: /usr/bin/cc", "libfoo"},
+	}
+	for _, tc := range cases {
+		got, err := parseDpkgSearch(tc.out)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+	_, err := parseDpkgSearch("garbage")
+	assert.Error(t, err)
+}
+
+func TestToolchainDockerfile(t *testing.T) {
+	tc := toolchain{
+		Family:  "gcc",
+		Version: "9.3.0",
+		Machine: "aarch64-linux-gnu",
+		Base:    "ubuntu:focal",
+		CC:      "/usr/bin/aarch64-linux-gnu-gcc-9",
+		CXX:     "/usr/bin/aarch64-linux-gnu-g++-9",
+		Packages: []debPackage{
+			{"gcc-9", "9.3.0-17ubuntu1~20.04"},
+			{"libc6-dev", "2.31-0ubuntu9.2"},
+		},
+		Extra: []string{"zlib1g-dev"},
+	}
+	assert.Equal(t, "arm64", tc.arch())
+	df, err := tc.dockerfile()
+	require.NoError(t, err)
+	assert.Contains(t, string(df), "FROM ubuntu:focal\n")
+	assert.Contains(t, string(df), "ca-certificates \\\n        gcc-9=9.3.0-17ubuntu1~20.04 \\\n        libc6-dev=2.31-0ubuntu9.2 \\\n        zlib1g-dev && \\\n")
+	assert.Contains(t, string(df), "ln -sf /usr/bin/aarch64-linux-gnu-gcc-9 /usr/local/bin/cc")
+}
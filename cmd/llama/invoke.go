@@ -24,6 +24,7 @@ import (
 	"net/rpc"
 	"os"
 	"text/template"
+	"time"
 
 	"github.com/google/subcommands"
 	"github.com/nelhage/llama/cmd/internal/cli"
@@ -36,8 +37,16 @@ type InvokeCommand struct {
 	stdin  bool
 	logs   bool
 	time   bool
+	stream bool
 	files  files.List
 	output files.List
+	trees  files.List
+
+	memory   int64
+	timeout  time.Duration
+	priority daemon.Priority
+
+	env envFlags
 }
 
 func (*InvokeCommand) Name() string     { return "invoke" }
@@ -51,10 +60,16 @@ func (c *InvokeCommand) SetFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&c.stdin, "stdin", false, "Read from stdin and pass it to the command")
 	flags.BoolVar(&c.logs, "logs", false, "Display command invocation logs")
 	flags.BoolVar(&c.time, "time", false, "Display invocation timing")
+	flags.BoolVar(&c.stream, "stream", false, "Print the command's output as it runs, instead of when it exits")
 	flags.Var(&c.files, "f", "Pass a file through to the invocation")
 	flags.Var(&c.files, "file", "Pass a file through to the invocation")
 	flags.Var(&c.output, "o", "Fetch additional output files")
 	flags.Var(&c.output, "output", "Fetch additional output files")
+	flags.Var(&c.trees, "tree", "Pass a directory tree through to the invocation")
+	flags.Int64Var(&c.memory, "memory", 0, "Run on a variant of the function with at least this much memory, in MB")
+	flags.DurationVar(&c.timeout, "timeout", 0, "Run on a variant of the function with at least this timeout")
+	flags.Var(&c.priority, "priority", "Scheduling class in the daemon: interactive or batch")
+	c.env.SetFlags(flags)
 }
 
 func (c *InvokeCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -72,6 +87,11 @@ func (c *InvokeCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...in
 	}
 
 	var err error
+	if args.Env, err = c.env.Environ(global.Config, os.Environ()); err != nil {
+		log.Println(err.Error())
+		return subcommands.ExitFailure
+	}
+
 	var ioctx files.IOContext
 	args.Args, ioctx, err = prepareArgs(ctx, global, flag.Args()[1:])
 	args.Files = c.files.Append(ioctx.Inputs...)
@@ -88,15 +108,32 @@ func (c *InvokeCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...in
 	}
 	args.Function = flag.Arg(0)
 	args.ReturnLogs = c.logs
+	args.Memory = c.memory
+	args.Timeout = c.timeout
+	args.Priority = c.priority
 
 	wd, err := files.WorkingDir()
 	if err != nil {
 		log.Fatalf("getcwd: %s", err.Error())
 	}
-	args.Files = args.Files.MakeAbsolute(wd)
+	args.Files, args.Trees, err = splitDirs(args.Files.MakeAbsolute(wd))
+	if err != nil {
+		log.Println(err.Error())
+		return subcommands.ExitFailure
+	}
 	args.Outputs = args.Outputs.MakeAbsolute(wd)
+	args.Trees = append(args.Trees, c.trees.MakeAbsolute(wd)...)
 
+	var stream *daemon.OutputStream
+	if c.stream {
+		stream = cl.StartStream(os.Stdout, os.Stderr)
+		args.Stream = stream.ID
+	}
 	response, err := cl.InvokeWithFiles(&args)
+	var wroteOut, wroteErr int
+	if stream != nil {
+		wroteOut, wroteErr = stream.Stop()
+	}
 	if err != nil {
 		log.Fatalf("invoke: %s", err.Error())
 	}
@@ -104,21 +141,25 @@ func (c *InvokeCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...in
 		fmt.Fprintf(os.Stderr, "==== invocation logs ====\n%s\n==== end logs ====\n", response.Logs)
 	}
 
-	if response.Stdout != nil {
-		os.Stdout.Write(response.Stdout)
+	if wroteOut < len(response.Stdout) {
+		os.Stdout.Write(response.Stdout[wroteOut:])
 	}
-	if response.Stderr != nil {
-		os.Stderr.Write(response.Stderr)
+	if wroteErr < len(response.Stderr) {
+		os.Stderr.Write(response.Stderr[wroteErr:])
 	}
 
 	if c.time {
 		log.Printf("Invoke timing:")
 		log.Printf("total:   %s", response.Timing.E2E)
 		log.Printf("upload:  %s", response.Timing.Upload)
+		log.Printf("queue:   %s", response.Timing.Queue)
 		log.Printf("invoke:  %s", response.Timing.Invoke)
 		log.Printf("fetch:   %s", response.Timing.Fetch)
 		log.Printf("remote:")
 		log.Printf("  total:   %s", response.Timing.Remote.E2E)
+		if response.Timing.Remote.ColdStart {
+			log.Printf("  init:    %s", response.Timing.Remote.Init)
+		}
 		log.Printf("  fetch:   %s", response.Timing.Remote.Fetch)
 		log.Printf("  exec:    %s", response.Timing.Remote.Exec)
 		log.Printf("  upload:  %s", response.Timing.Remote.Upload)
@@ -159,3 +200,22 @@ func prepareArgs(ctx context.Context, global *cli.GlobalState, args []string) ([
 
 	return outArgs, ioctx, nil
 }
+
+// splitDirs separates the directories passed with -f, which we upload
+// as trees, from the files.
+func splitDirs(list files.List) (fileList, trees files.List, err error) {
+	for _, m := range list {
+		if m.Local.Path != "" {
+			st, err := os.Stat(m.Local.Path)
+			if err != nil {
+				return nil, nil, err
+			}
+			if st.IsDir() {
+				trees = trees.Append(m)
+				continue
+			}
+		}
+		fileList = fileList.Append(m)
+	}
+	return fileList, trees, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+)
+
+type LogsCommand struct {
+	path     string
+	function string
+	region   string
+	since    time.Duration
+}
+
+func (*LogsCommand) Name() string     { return "logs" }
+func (*LogsCommand) Synopsis() string { return "Print the CloudWatch logs of a function invocation" }
+func (*LogsCommand) Usage() string {
+	return `logs [flags] ID
+
+Print the CloudWatch logs of one invocation of a Lambda function. ID
+is the invocation ID of a recent failure shown by "llama top", or a
+Lambda request ID, which needs -function unless the daemon knows it.
+`
+}
+
+func (c *LogsCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.path, "path", cli.SocketPath(), "Path to daemon socket")
+	flags.StringVar(&c.function, "function", "", "The function that ran the invocation")
+	flags.StringVar(&c.region, "region", "", "The region the invocation ran in (default: the configured region)")
+	flags.DurationVar(&c.since, "since", 24*time.Hour, "How far back to search for the invocation")
+}
+
+// logQuery identifies the invocation whose logs to print
+type logQuery struct {
+	function  string
+	region    string
+	requestID string
+	// The window to search for the invocation in
+	start, end time.Time
+}
+
+// findFailure looks `id` up among the daemon's recent failures
+func findFailure(failures []daemon.FailureStatus, id string) *daemon.FailureStatus {
+	for i := len(failures) - 1; i >= 0; i-- {
+		f := &failures[i]
+		if f.InvocationID == id || f.RequestID == id {
+			return f
+		}
+	}
+	return nil
+}
+
+func (c *LogsCommand) query(ctx context.Context, id string, now time.Time) (*logQuery, error) {
+	q := logQuery{
+		function:  c.function,
+		region:    c.region,
+		requestID: id,
+		start:     now.Add(-c.since),
+		end:       now,
+	}
+	if client, err := daemon.Dial(ctx, c.path); err == nil {
+		defer client.Close()
+		if st, err := client.Status(&daemon.StatusArgs{}); err == nil {
+			if f := findFailure(st.RecentFailures, id); f != nil {
+				if f.RequestID == "" {
+					return nil, fmt.Errorf("%s never reached Lambda, so it has no logs: %s", id, f.Error)
+				}
+				q.function = f.Function
+				q.region = f.Region
+				q.requestID = f.RequestID
+				// Lambda invocations run for at most 15
+				// minutes
+				q.start = f.Time.Add(-20 * time.Minute)
+				q.end = f.Time.Add(time.Minute)
+			}
+		}
+	}
+	if c.function != "" {
+		q.function = c.function
+	}
+	if c.region != "" {
+		q.region = c.region
+	}
+	if q.function == "" {
+		return nil, fmt.Errorf("%s is not a recent failure the daemon knows of; pass -function and a Lambda request ID", id)
+	}
+	return &q, nil
+}
+
+func (c *LogsCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if flag.NArg() != 1 {
+		log.Printf("Usage: llama %s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+	global := cli.MustState(ctx)
+	q, err := c.query(ctx, flag.Arg(0), time.Now())
+	if err != nil {
+		log.Printf("logs: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	sess := global.MustSession()
+	if q.region != "" {
+		sess = sess.Copy(aws.NewConfig().WithRegion(q.region))
+	}
+	if err := printInvocationLogs(ctx, cloudwatchlogs.New(sess), q, os.Stdout); err != nil {
+		log.Printf("logs: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+var errNoLogs = errors.New("no logs found for the invocation")
+
+// printInvocationLogs finds the log stream holding q's invocation,
+// and prints its events from the START line to the REPORT line.
+// Lambda runs one invocation at a time in each stream, so everything
+// between belongs to it.
+func printInvocationLogs(ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI, q *logQuery, w io.Writer) error {
+	group := "/aws/lambda/" + q.function
+	var start *cloudwatchlogs.FilteredLogEvent
+	err := svc.FilterLogEventsPagesWithContext(ctx, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:  &group,
+		FilterPattern: aws.String(fmt.Sprintf("%q", "START RequestId: "+q.requestID)),
+		StartTime:     aws.Int64(toMillis(q.start)),
+		EndTime:       aws.Int64(toMillis(q.end)),
+	}, func(page *cloudwatchlogs.FilterLogEventsOutput, last bool) bool {
+		if len(page.Events) > 0 {
+			start = page.Events[0]
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("searching %s: %w", group, err)
+	}
+	if start == nil {
+		return errNoLogs
+	}
+
+	report := "REPORT RequestId: " + q.requestID
+	in := cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  &group,
+		LogStreamName: start.LogStreamName,
+		StartTime:     start.Timestamp,
+		StartFromHead: aws.Bool(true),
+	}
+	for {
+		page, err := svc.GetLogEventsWithContext(ctx, &in)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", aws.StringValue(start.LogStreamName), err)
+		}
+		for _, ev := range page.Events {
+			msg := aws.StringValue(ev.Message)
+			io.WriteString(w, msg)
+			if !strings.HasSuffix(msg, "\n") {
+				io.WriteString(w, "\n")
+			}
+			if strings.HasPrefix(msg, report) {
+				return nil
+			}
+		}
+		// The token stays the same at the end of the stream
+		if len(page.Events) == 0 || aws.StringValue(page.NextForwardToken) == aws.StringValue(in.NextToken) {
+			return nil
+		}
+		in.NextToken = page.NextForwardToken
+	}
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
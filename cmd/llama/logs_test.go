@@ -0,0 +1,124 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLogs serves a single log stream, a page of pageSize events at
+// a time
+type fakeLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	stream   []string
+	pageSize int
+	filter   *cloudwatchlogs.FilterLogEventsInput
+}
+
+func (f *fakeLogs) FilterLogEventsPagesWithContext(ctx aws.Context, in *cloudwatchlogs.FilterLogEventsInput, fn func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool, _ ...request.Option) error {
+	f.filter = in
+	want := strings.Trim(aws.StringValue(in.FilterPattern), `"`)
+	var out cloudwatchlogs.FilterLogEventsOutput
+	for i, msg := range f.stream {
+		if strings.Contains(msg, want) {
+			out.Events = append(out.Events, &cloudwatchlogs.FilteredLogEvent{
+				LogStreamName: aws.String("stream"),
+				Message:       aws.String(msg),
+				Timestamp:     aws.Int64(int64(i)),
+			})
+		}
+	}
+	fn(&out, true)
+	return nil
+}
+
+func (f *fakeLogs) GetLogEventsWithContext(ctx aws.Context, in *cloudwatchlogs.GetLogEventsInput, _ ...request.Option) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	pos := int(aws.Int64Value(in.StartTime))
+	if in.NextToken != nil {
+		pos = len(*in.NextToken)
+	}
+	end := pos + f.pageSize
+	if end > len(f.stream) {
+		end = len(f.stream)
+	}
+	var out cloudwatchlogs.GetLogEventsOutput
+	for _, msg := range f.stream[pos:end] {
+		out.Events = append(out.Events, &cloudwatchlogs.OutputLogEvent{Message: aws.String(msg)})
+	}
+	out.NextForwardToken = aws.String(strings.Repeat("x", end))
+	return &out, nil
+}
+
+func TestPrintInvocationLogs(t *testing.T) {
+	svc := &fakeLogs{
+		pageSize: 2,
+		stream: []string{
+			"START RequestId: aaa Version: $LATEST\n",
+			"END RequestId: aaa\n",
+			"REPORT RequestId: aaa Duration: 1 ms\n",
+			"START RequestId: bbb Version: $LATEST\n",
+			"cc1: internal compiler error",
+			"END RequestId: bbb\n",
+			"REPORT RequestId: bbb Duration: 2 ms\n",
+			"START RequestId: ccc Version: $LATEST\n",
+		},
+	}
+	now := time.Now()
+	q := &logQuery{function: "gcc", requestID: "bbb", start: now.Add(-time.Hour), end: now}
+
+	var buf bytes.Buffer
+	require.NoError(t, printInvocationLogs(context.Background(), svc, q, &buf))
+	assert.Equal(t, "/aws/lambda/gcc", aws.StringValue(svc.filter.LogGroupName))
+	assert.Equal(t, `"START RequestId: bbb"`, aws.StringValue(svc.filter.FilterPattern))
+	assert.Equal(t, strings.Join([]string{
+		"START RequestId: bbb Version: $LATEST",
+		"cc1: internal compiler error",
+		"END RequestId: bbb",
+		"REPORT RequestId: bbb Duration: 2 ms",
+	}, "\n")+"\n", buf.String())
+
+	// An invocation that's still running prints to the end of
+	// the stream
+	buf.Reset()
+	q.requestID = "ccc"
+	require.NoError(t, printInvocationLogs(context.Background(), svc, q, &buf))
+	assert.Equal(t, "START RequestId: ccc Version: $LATEST\n", buf.String())
+
+	q.requestID = "ddd"
+	assert.Equal(t, errNoLogs, printInvocationLogs(context.Background(), svc, q, &buf))
+}
+
+func TestFindFailure(t *testing.T) {
+	failures := []daemon.FailureStatus{
+		{Function: "gcc", InvocationID: "inv1", RequestID: "req1"},
+		{Function: "gcc", InvocationID: "inv2"},
+	}
+	assert.Equal(t, &failures[0], findFailure(failures, "inv1"))
+	assert.Equal(t, &failures[0], findFailure(failures, "req1"))
+	assert.Equal(t, &failures[1], findFailure(failures, "inv2"))
+	assert.Nil(t, findFailure(failures, "nope"))
+}
@@ -27,10 +27,13 @@ import (
 	"github.com/google/subcommands"
 	"github.com/klauspost/compress/zstd"
 	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/cmd/llama/internal/bazel"
 	"github.com/nelhage/llama/cmd/llama/internal/bootstrap"
 	"github.com/nelhage/llama/cmd/llama/internal/function"
 	"github.com/nelhage/llama/cmd/llama/internal/trace"
+	"github.com/nelhage/llama/logging"
 	"github.com/nelhage/llama/tracing"
+	"github.com/nelhage/llama/tracing/otlp"
 )
 
 func main() {
@@ -40,13 +43,25 @@ func main() {
 	subcommands.Register(&bootstrap.BootstrapCommand{}, "config")
 	subcommands.Register(&ConfigCommand{}, "config")
 	subcommands.Register(&function.UpdateFunctionCommand{}, "config")
+	subcommands.Register(&function.ToolchainCommand{}, "config")
+	subcommands.Register(&function.UpdateRuntimeCommand{}, "config")
+	subcommands.Register(&StoreKeyCommand{}, "config")
 
 	subcommands.Register(&InvokeCommand{}, "")
+	subcommands.Register(&RunCommand{}, "")
 	subcommands.Register(&XargsCommand{}, "")
+	subcommands.Register(&SubmitCommand{}, "")
+	subcommands.Register(&WaitCommand{}, "")
 	subcommands.Register(&DaemonCommand{}, "")
+	subcommands.Register(&TopCommand{}, "")
+	subcommands.Register(&LogsCommand{}, "")
+	subcommands.Register(&SessionCommand{}, "")
+	subcommands.Register(&bazel.BazelCacheCommand{}, "")
 
 	subcommands.Register(&StoreCommand{}, "internals")
 	subcommands.Register(&GetCommand{}, "internals")
+	subcommands.Register(&MaterializeCommand{}, "internals")
+	subcommands.Register(&GCCommand{}, "internals")
 	subcommands.Register(&trace.TraceCommand{}, "tracing")
 	subcommands.Register(&MultigetCommand{}, "internals")
 
@@ -66,6 +81,7 @@ func runLlama(ctx context.Context) int {
 	var storeConcurrency int
 	var trace string
 	var cpuProfile, memProfile string
+	var logFormat string
 	flag.StringVar(&regionOverride, "region", "", "AWS region")
 	flag.StringVar(&storeOverride, "store", "", "Path to the llama object store. s3://BUCKET/PATH")
 	flag.BoolVar(&debugAWS, "debug-aws", false, "Log all AWS requests/responses")
@@ -73,6 +89,7 @@ func runLlama(ctx context.Context) int {
 	flag.StringVar(&trace, "trace", "", "Write tracing data to file")
 	flag.StringVar(&cpuProfile, "cpu-profile", "", "Write CPU profile to file")
 	flag.StringVar(&memProfile, "mem-profile", "", "Write memory profile to file")
+	flag.StringVar(&logFormat, "log-format", "", "Log format: text or json (default $LLAMA_LOG_FORMAT, or log_format from the config)")
 
 	flag.Parse()
 
@@ -122,10 +139,33 @@ func runLlama(ctx context.Context) int {
 		defer wt.Close()
 	}
 
+	otlpOpts, err := otlp.OptionsFromEnv("llama")
+	if err != nil {
+		log.Fatalf("otlp: %s", err.Error())
+	}
+	if otlpOpts != nil {
+		exp, err := otlp.New(otlpOpts)
+		if err != nil {
+			log.Fatalf("otlp: %s", err.Error())
+		}
+		defer exp.Close()
+		var tr tracing.Tracer = exp
+		if wt, ok := tracing.TracerFromContext(ctx); ok {
+			tr = tracing.Tee(wt, exp)
+		}
+		ctx = tracing.WithTracer(ctx, tr)
+	}
+
 	cfg, err := cli.ReadConfig(cli.ConfigPath())
 	if err != nil {
 		log.Fatalf("reading config file: %s", err.Error())
 	}
+	if logFormat == "" && os.Getenv(logging.FormatEnv) == "" {
+		logFormat = cfg.LogFormat
+	}
+	if err := logging.Setup(logFormat, "llama"); err != nil {
+		log.Fatalf("-log-format: %s", err.Error())
+	}
 
 	if storeOverride == "" {
 		storeOverride = os.Getenv("LLAMA_OBJECT_STORE")
@@ -133,6 +173,12 @@ func runLlama(ctx context.Context) int {
 	if storeOverride != "" {
 		cfg.Store = storeOverride
 	}
+	if endpoint := os.Getenv("LLAMA_S3_ENDPOINT"); endpoint != "" {
+		cfg.S3Endpoint = endpoint
+	}
+	if os.Getenv("LLAMA_S3_ACCELERATE") != "" {
+		cfg.S3Accelerate = true
+	}
 	if storeConcurrency != defaultStoreConcurrency || cfg.S3Concurrency == 0 {
 		cfg.S3Concurrency = storeConcurrency
 	}
@@ -143,6 +189,11 @@ func runLlama(ctx context.Context) int {
 
 	var state cli.GlobalState
 	state.Config = cfg
+	if wd, err := os.Getwd(); err == nil {
+		if state.Project, err = cli.FindProjectConfig(wd); err != nil {
+			log.Fatalf("reading project config: %s", err.Error())
+		}
+	}
 
 	ctx = cli.WithState(ctx, &state)
 
@@ -23,6 +23,7 @@ import (
 
 	"github.com/google/subcommands"
 	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/protocol/files"
 	"github.com/nelhage/llama/store"
 )
 
@@ -30,9 +31,16 @@ type StoreCommand struct {
 }
 
 func (*StoreCommand) Name() string     { return "store" }
-func (*StoreCommand) Synopsis() string { return "Store an object to the llama object store" }
+func (*StoreCommand) Synopsis() string { return "Store or inspect objects in the llama object store" }
 func (*StoreCommand) Usage() string {
-	return `store PATH
+	return `store PATH...
+store ls [-keys] [PREFIX]
+store cat [-key] ID...
+store stat PATH...
+
+With paths, stores each file as an object. The ls, cat, and stat
+subcommands inspect the object store; run "llama store help" for
+details.
 `
 }
 
@@ -40,6 +48,9 @@ func (c *StoreCommand) SetFlags(flags *flag.FlagSet) {
 }
 
 func (c *StoreCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if storeSubcommands[flag.Arg(0)] {
+		return executeStoreSubcommand(ctx, flag.Args())
+	}
 	global := cli.MustState(ctx)
 
 	for _, arg := range flag.Args() {
@@ -85,3 +96,34 @@ func (c *GetCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...inter
 
 	return subcommands.ExitSuccess
 }
+
+type MaterializeCommand struct {
+}
+
+func (*MaterializeCommand) Name() string { return "materialize" }
+func (*MaterializeCommand) Synopsis() string {
+	return "Replace reference files left by LLAMACC_REMOTE_OBJECTS with their contents"
+}
+func (*MaterializeCommand) Usage() string {
+	return `materialize PATH...
+
+Downloads the contents of each PATH that is a reference to an object
+in the store, in place. Other files are left alone.
+`
+}
+
+func (c *MaterializeCommand) SetFlags(flags *flag.FlagSet) {
+}
+
+func (c *MaterializeCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+
+	n, err := files.Materialize(ctx, global.MustStore(), flag.Args())
+	if err != nil {
+		log.Printf("materialize: %v", err)
+		return subcommands.ExitFailure
+	}
+	log.Printf("materialized %d file(s)", n)
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// xargsProgress tracks the state of a `llama xargs` run. On a
+// terminal, it draws a status line at the bottom of the screen;
+// other output should be written through it so that it can keep the
+// status line out of the way.
+type xargsProgress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	tty   bool
+	drawn bool
+
+	start     time.Time
+	read      int
+	inputDone bool
+
+	started   int
+	succeeded int
+	failed    int
+	errored   int
+	retries   int
+
+	jobTime time.Duration
+	maxTime time.Duration
+}
+
+func newXargsProgress(w io.Writer, tty bool, start time.Time) *xargsProgress {
+	return &xargsProgress{w: w, tty: tty, start: start}
+}
+
+func (p *xargsProgress) queued() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.read++
+}
+
+func (p *xargsProgress) inputComplete() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inputDone = true
+}
+
+func (p *xargsProgress) jobStarted() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started++
+}
+
+func (p *xargsProgress) jobDone(job *Invocation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case job.Err != nil:
+		p.errored++
+	case job.Result.Response.ExitStatus != 0:
+		p.failed++
+	default:
+		p.succeeded++
+	}
+	p.jobTime += job.Elapsed
+	if job.Elapsed > p.maxTime {
+		p.maxTime = job.Elapsed
+	}
+}
+
+// retried records that a job failed, and we're going to run it again
+func (p *xargsProgress) retried() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries++
+}
+
+func (p *xargsProgress) done() int {
+	return p.succeeded + p.failed + p.errored
+}
+
+func (p *xargsProgress) throughput(now time.Time) float64 {
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.done()) / elapsed
+}
+
+// status returns a one-line summary of progress at `now`
+func (p *xargsProgress) status(now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.statusLocked(now)
+}
+
+func (p *xargsProgress) statusLocked(now time.Time) string {
+	total := "?"
+	if p.inputDone {
+		total = fmt.Sprint(p.read)
+	}
+	rate := p.throughput(now)
+	line := fmt.Sprintf("[%d/%s] ok=%d failed=%d in_flight=%d %.1f jobs/s",
+		p.done(), total, p.succeeded, p.failed+p.errored, p.started-p.done(), rate)
+	if p.inputDone && rate > 0 {
+		eta := time.Duration(float64(p.read-p.done()) / rate * float64(time.Second))
+		line += fmt.Sprintf(" eta=%s", eta.Round(time.Second))
+	}
+	return line
+}
+
+func (p *xargsProgress) clearLocked() {
+	if p.drawn {
+		io.WriteString(p.w, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+func (p *xargsProgress) drawLocked(now time.Time) {
+	p.clearLocked()
+	io.WriteString(p.w, p.statusLocked(now))
+	p.drawn = true
+}
+
+// Write writes `buf` above the status line
+func (p *xargsProgress) Write(buf []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.tty {
+		return p.w.Write(buf)
+	}
+	p.clearLocked()
+	n, err := p.w.Write(buf)
+	p.drawLocked(time.Now())
+	return n, err
+}
+
+// refresh redraws the status line every `interval` until `stop` is
+// closed, and then erases it.
+func (p *xargsProgress) refresh(interval time.Duration, stop <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case now := <-tick.C:
+			p.mu.Lock()
+			p.drawLocked(now)
+			p.mu.Unlock()
+		case <-stop:
+			p.mu.Lock()
+			p.clearLocked()
+			p.mu.Unlock()
+			return
+		}
+	}
+}
+
+// summary writes a table summarizing the run to `w`
+func (p *xargsProgress) summary(w io.Writer, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "jobs\t%d\n", p.done())
+	fmt.Fprintf(tw, "succeeded\t%d\n", p.succeeded)
+	fmt.Fprintf(tw, "nonzero exit\t%d\n", p.failed)
+	fmt.Fprintf(tw, "errors\t%d\n", p.errored)
+	if p.retries > 0 {
+		fmt.Fprintf(tw, "retries\t%d\n", p.retries)
+	}
+	fmt.Fprintf(tw, "elapsed\t%s\n", now.Sub(p.start).Round(time.Millisecond))
+	fmt.Fprintf(tw, "throughput\t%.1f jobs/s\n", p.throughput(now))
+	if n := p.done(); n > 0 {
+		mean := p.jobTime / time.Duration(n)
+		fmt.Fprintf(tw, "job time\tmean=%s max=%s\n",
+			mean.Round(time.Millisecond), p.maxTime.Round(time.Millisecond))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXargsProgress(t *testing.T) {
+	start := time.Date(2020, 12, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	p := newXargsProgress(&buf, false, start)
+
+	for i := 0; i < 10; i++ {
+		p.queued()
+	}
+	for i := 0; i < 6; i++ {
+		p.jobStarted()
+	}
+	p.jobDone(&Invocation{
+		Result:  &llama.InvokeResult{Response: protocol.InvocationResponse{ExitStatus: 0}},
+		Elapsed: time.Second,
+	})
+	p.jobDone(&Invocation{
+		Result:  &llama.InvokeResult{Response: protocol.InvocationResponse{ExitStatus: 0}},
+		Elapsed: 3 * time.Second,
+	})
+	p.jobDone(&Invocation{
+		Result:  &llama.InvokeResult{Response: protocol.InvocationResponse{ExitStatus: 1}},
+		Elapsed: time.Second,
+	})
+	p.jobDone(&Invocation{Err: errors.New("boom"), Elapsed: 3 * time.Second})
+
+	now := start.Add(2 * time.Second)
+	assert.Equal(t, "[4/?] ok=2 failed=2 in_flight=2 2.0 jobs/s", p.status(now))
+	p.inputComplete()
+	assert.Equal(t, "[4/10] ok=2 failed=2 in_flight=2 2.0 jobs/s eta=3s", p.status(now))
+
+	p.summary(&buf, now)
+	out := buf.String()
+	assert.Contains(t, out, "succeeded     2\n")
+	assert.Contains(t, out, "nonzero exit  1\n")
+	assert.Contains(t, out, "errors        1\n")
+	assert.Contains(t, out, "throughput    2.0 jobs/s\n")
+	assert.Contains(t, out, "mean=2s max=3s")
+}
+
+func TestXargsProgressWrite(t *testing.T) {
+	var buf bytes.Buffer
+	p := newXargsProgress(&buf, false, time.Now())
+	p.Write([]byte("hello\n"))
+	assert.Equal(t, "hello\n", buf.String())
+
+	buf.Reset()
+	p = newXargsProgress(&buf, true, time.Now())
+	p.Write([]byte("one\n"))
+	p.Write([]byte("two\n"))
+	assert.Regexp(t, `^one\n\[0/\?\] [^\n]*\r\033\[Ktwo\n\[0/\?\] `, buf.String())
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"log"
+
+	"golang.org/x/sys/unix"
+)
+
+func raiseRlimits() {
+	var limits unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limits); err != nil {
+		log.Printf("Warning: Unable to read RLIMIT_NOFILE: %s", err.Error())
+		return
+	}
+	target := uint64(65535)
+	limits.Cur = target
+	if limits.Cur > limits.Max {
+		limits.Cur = limits.Max
+	}
+	if err := unix.Setrlimit(unix.RLIMIT_NOFILE, &limits); err != nil {
+		log.Printf("Warning: setting RLIMIT_NOFILE: %s", err.Error())
+	}
+}
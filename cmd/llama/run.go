@@ -0,0 +1,160 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/rpc"
+	"os"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/server"
+	"github.com/nelhage/llama/files"
+)
+
+type RunCommand struct {
+	function string
+	stdin    bool
+	logs     bool
+	stream   bool
+	quiet    bool
+	ignore   stringList
+
+	memory   int64
+	timeout  time.Duration
+	priority daemon.Priority
+
+	env envFlags
+}
+
+func (*RunCommand) Name() string { return "run" }
+func (*RunCommand) Synopsis() string {
+	return "Run a command on a copy of the current directory, and copy back the files it changes"
+}
+func (*RunCommand) Usage() string {
+	return `run [flags] -- COMMAND ARGS...
+`
+}
+
+func (c *RunCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.function, "function", "", "The function to run the command in (default: run_function from .llamarc)")
+	flags.BoolVar(&c.stdin, "stdin", false, "Read from stdin and pass it to the command")
+	flags.BoolVar(&c.logs, "logs", false, "Display command invocation logs")
+	flags.BoolVar(&c.stream, "stream", false, "Print the command's output as it runs, instead of when it exits")
+	flags.BoolVar(&c.quiet, "quiet", false, "Don't list the files copied back")
+	flags.Var(&c.ignore, "ignore", "Leave files matching this .gitignore-style pattern out of the upload")
+	flags.Int64Var(&c.memory, "memory", 0, "Run on a variant of the function with at least this much memory, in MB")
+	flags.DurationVar(&c.timeout, "timeout", 0, "Run on a variant of the function with at least this timeout")
+	flags.Var(&c.priority, "priority", "Scheduling class in the daemon: interactive or batch")
+	c.env.SetFlags(flags)
+}
+
+// runIgnorePolicy returns the files `llama run` leaves out of the
+// directory it uploads: those git would, and the repository itself.
+func runIgnorePolicy(extra []string) *files.IgnorePolicy {
+	return &files.IgnorePolicy{
+		Patterns: append([]string{".git/"}, extra...),
+		Files:    []string{".gitignore", files.IgnoreFileName},
+	}
+}
+
+func (c *RunCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+
+	if flag.NArg() == 0 {
+		log.Println("llama run: no command given")
+		return subcommands.ExitUsageError
+	}
+	function := c.function
+	if function == "" && global.Project != nil {
+		function = global.Project.RunFunction
+	}
+	if function == "" {
+		log.Println("llama run: no function; pass -function, or set run_function in .llamarc")
+		return subcommands.ExitUsageError
+	}
+
+	wd, err := files.WorkingDir()
+	if err != nil {
+		log.Fatalf("getcwd: %s", err.Error())
+	}
+
+	args := daemon.InvokeWithFilesArgs{
+		Function:   function,
+		ReturnLogs: c.logs,
+		Args:       flag.Args(),
+		Trees:      files.List{{Local: files.LocalFile{Path: wd}, Remote: "."}},
+		Ignore:     runIgnorePolicy(c.ignore),
+		ChangesDir: wd,
+		Memory:     c.memory,
+		Timeout:    c.timeout,
+		Priority:   c.priority,
+	}
+	if c.stdin {
+		if args.Stdin, err = ioutil.ReadAll(os.Stdin); err != nil {
+			log.Printf("reading stdin: %s", err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	if args.Env, err = c.env.Environ(global.Config, os.Environ()); err != nil {
+		log.Println(err.Error())
+		return subcommands.ExitFailure
+	}
+
+	cl, err := server.DialWithAutostart(ctx, cli.SocketPath(), rpc.DefaultRPCPath)
+	if err != nil {
+		log.Fatalf("connecting to daemon: %s", err.Error())
+	}
+	defer cl.Close()
+
+	var stream *daemon.OutputStream
+	if c.stream {
+		stream = cl.StartStream(os.Stdout, os.Stderr)
+		args.Stream = stream.ID
+	}
+	response, err := cl.InvokeWithFiles(&args)
+	var wroteOut, wroteErr int
+	if stream != nil {
+		wroteOut, wroteErr = stream.Stop()
+	}
+	if err != nil {
+		log.Fatalf("run: %s", err.Error())
+	}
+	if response.Logs != nil {
+		fmt.Fprintf(os.Stderr, "==== invocation logs ====\n%s\n==== end logs ====\n", response.Logs)
+	}
+	if wroteOut < len(response.Stdout) {
+		os.Stdout.Write(response.Stdout[wroteOut:])
+	}
+	if wroteErr < len(response.Stderr) {
+		os.Stderr.Write(response.Stderr[wroteErr:])
+	}
+	if !c.quiet {
+		for _, p := range response.Changed {
+			fmt.Fprintf(os.Stderr, "llama run: updated %s\n", p)
+		}
+	}
+	if response.InvokeErr != "" {
+		log.Fatalf("run: %s", response.InvokeErr)
+	}
+	return subcommands.ExitStatus(response.ExitStatus)
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/rpc"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/server"
+)
+
+type SessionCommand struct {
+	path string
+}
+
+func (*SessionCommand) Name() string     { return "session" }
+func (*SessionCommand) Synopsis() string { return "Begin or end a build session" }
+func (*SessionCommand) Usage() string {
+	return `session begin|end [ID]
+
+Build sessions aggregate statistics about a build's invocations, and
+write a report when they end. llamacc records its invocations in the
+session named by $LLAMACC_BUILD_ID, or "default", beginning it if it
+isn't running; sessions end after the daemon's -build-idle timeout
+without an invocation, or with "session end", which prints the
+report. ID defaults to $LLAMACC_BUILD_ID, or "default".
+`
+}
+
+func (c *SessionCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.path, "path", cli.SocketPath(), "Path to daemon socket")
+}
+
+// sessionID returns the build session named by `args`, or the one
+// llamacc would use
+func sessionID(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	if id := os.Getenv("LLAMACC_BUILD_ID"); id != "" {
+		return id
+	}
+	return daemon.DefaultBuild
+}
+
+func (c *SessionCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		log.Printf("Usage: llama %s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+	id := sessionID(flag.Args()[1:])
+	switch flag.Arg(0) {
+	case "begin":
+		client, err := server.DialWithAutostart(ctx, c.path, rpc.DefaultRPCPath)
+		if err != nil {
+			log.Printf("Connecting to daemon: %s", err.Error())
+			return subcommands.ExitFailure
+		}
+		defer client.Close()
+		if _, err := client.BeginBuild(&daemon.BeginBuildArgs{Build: id}); err != nil {
+			log.Printf("Beginning build %s: %s", id, err.Error())
+			return subcommands.ExitFailure
+		}
+	case "end":
+		client, err := daemon.Dial(ctx, c.path)
+		if err != nil {
+			log.Printf("Connecting to daemon: %s", err.Error())
+			return subcommands.ExitFailure
+		}
+		defer client.Close()
+		reply, err := client.EndBuild(&daemon.EndBuildArgs{Build: id})
+		if err != nil {
+			log.Printf("Ending build %s: %s", id, err.Error())
+			return subcommands.ExitFailure
+		}
+		if !reply.Found {
+			log.Printf("No build session %s is running", id)
+			return subcommands.ExitFailure
+		}
+		reply.Report.WriteText(os.Stdout)
+	default:
+		log.Printf("Usage: llama %s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+	return subcommands.ExitSuccess
+}
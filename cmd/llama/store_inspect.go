@@ -0,0 +1,365 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/store"
+)
+
+// storeSubcommands are the commands `llama store` dispatches to when
+// named as its first argument, rather than treating it as a path.
+var storeSubcommands = map[string]bool{
+	"ls": true, "cat": true, "stat": true, "stats": true, "reindex": true, "help": true,
+}
+
+func executeStoreSubcommand(ctx context.Context, args []string) subcommands.ExitStatus {
+	fs := flag.NewFlagSet("llama store", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return subcommands.ExitUsageError
+	}
+	cmdr := subcommands.NewCommander(fs, "llama store")
+	cmdr.Register(cmdr.HelpCommand(), "")
+	cmdr.Register(&StoreLsCommand{}, "")
+	cmdr.Register(&StoreCatCommand{}, "")
+	cmdr.Register(&StoreStatCommand{}, "")
+	cmdr.Register(&StoreStatsCommand{}, "")
+	cmdr.Register(&StoreReindexCommand{}, "")
+	return cmdr.Execute(ctx)
+}
+
+type StoreLsCommand struct {
+	keys bool
+}
+
+func (*StoreLsCommand) Name() string     { return "ls" }
+func (*StoreLsCommand) Synopsis() string { return "List objects in the llama object store" }
+func (*StoreLsCommand) Usage() string {
+	return `store ls [-keys] [PREFIX]
+
+Lists the objects whose IDs start with PREFIX, or every object, with
+their sizes and ages. With -keys, lists keyed entries, such as
+result-cache entries, instead.
+`
+}
+
+func (c *StoreLsCommand) SetFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&c.keys, "keys", false, "List keys instead of objects")
+}
+
+func (c *StoreLsCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	coll, ok := global.MustStore().(store.Collectable)
+	if !ok {
+		log.Printf("store ls: the configured store does not support listing")
+		return subcommands.ExitFailure
+	}
+	prefix := flag.Arg(0)
+
+	var objs []store.ObjectInfo
+	collect := func(info store.ObjectInfo) error {
+		if strings.HasPrefix(info.Id, prefix) {
+			objs = append(objs, info)
+		}
+		return nil
+	}
+	var err error
+	if c.keys {
+		err = coll.ListKeys(ctx, prefix, collect)
+	} else {
+		err = coll.ListObjects(ctx, collect)
+	}
+	if err != nil {
+		log.Printf("store ls: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	renderObjects(os.Stdout, objs, time.Now())
+	return subcommands.ExitSuccess
+}
+
+// fmtAge formats the age of an object coarsely, the way a human
+// wants to read it
+func fmtAge(age time.Duration) string {
+	const day = 24 * time.Hour
+	if age >= 2*day {
+		return fmt.Sprintf("%dd", age/day)
+	}
+	return age.Truncate(time.Second).String()
+}
+
+func renderObjects(w io.Writer, objs []store.ObjectInfo, now time.Time) {
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tSIZE\tAGE\n")
+	var total int64
+	for _, obj := range objs {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", obj.Id, obj.Size, fmtAge(now.Sub(obj.LastModified)))
+		total += obj.Size
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d objects, %d bytes\n", len(objs), total)
+}
+
+type StoreCatCommand struct {
+	key bool
+}
+
+func (*StoreCatCommand) Name() string { return "cat" }
+func (*StoreCatCommand) Synopsis() string {
+	return "Write objects from the llama object store to stdout"
+}
+func (*StoreCatCommand) Usage() string {
+	return `store cat [-key] ID...
+
+Writes the named objects, or with -key the values of the named keys,
+to stdout.
+`
+}
+
+func (c *StoreCatCommand) SetFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&c.key, "key", false, "Arguments name keys, not object IDs")
+}
+
+func (c *StoreCatCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	st := global.MustStore()
+	kv, _ := st.(store.KeyValue)
+	if c.key && kv == nil {
+		log.Printf("store cat: the configured store does not support keys")
+		return subcommands.ExitFailure
+	}
+
+	for _, arg := range flag.Args() {
+		var data []byte
+		var err error
+		if c.key {
+			data, err = kv.GetKey(ctx, arg)
+		} else {
+			data, err = store.Get(ctx, st, arg)
+		}
+		if err != nil {
+			log.Printf("store cat: %s: %s", arg, err.Error())
+			return subcommands.ExitFailure
+		}
+		os.Stdout.Write(data)
+	}
+	return subcommands.ExitSuccess
+}
+
+type StoreStatCommand struct{}
+
+func (*StoreStatCommand) Name() string { return "stat" }
+func (*StoreStatCommand) Synopsis() string {
+	return "Report whether local files are present in the llama object store"
+}
+func (*StoreStatCommand) Usage() string {
+	return `store stat PATH...
+
+For each file, prints the ID its contents would be stored under, and
+whether the object store already holds that object. An upload of a file
+the store already holds is skipped; a missing object is uploaded.
+`
+}
+
+func (c *StoreStatCommand) SetFlags(flags *flag.FlagSet) {}
+
+func (c *StoreStatCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	insp, ok := global.MustStore().(store.Inspectable)
+	if !ok {
+		log.Printf("store stat: the configured store does not support inspecting objects")
+		return subcommands.ExitFailure
+	}
+
+	now := time.Now()
+	tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "PATH\tID\tSTATUS\n")
+	for _, arg := range flag.Args() {
+		id, info, err := statFile(ctx, insp, arg)
+		if err != nil {
+			tw.Flush()
+			log.Printf("store stat: %s: %s", arg, err.Error())
+			return subcommands.ExitFailure
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", arg, id, fmtStatus(info, now))
+	}
+	return subcommands.ExitSuccess
+}
+
+// statFile returns the ID under which `st` stores `file`'s contents,
+// and information about that object, or nil if `st` doesn't hold it.
+func statFile(ctx context.Context, st store.Inspectable, file string) (string, *store.ObjectInfo, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := st.ObjectID(data)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := st.StatObject(ctx, id)
+	if errors.Is(err, store.ErrNotExists) {
+		return id, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return id, &info, nil
+}
+
+func fmtStatus(info *store.ObjectInfo, now time.Time) string {
+	if info == nil {
+		return "missing"
+	}
+	status := fmt.Sprintf("present size=%d", info.Size)
+	if !info.LastModified.IsZero() {
+		status += " age=" + fmtAge(now.Sub(info.LastModified))
+	}
+	return status
+}
+
+type StoreStatsCommand struct{}
+
+func (*StoreStatsCommand) Name() string { return "stats" }
+func (*StoreStatsCommand) Synopsis() string {
+	return "Summarize the contents of the llama object store"
+}
+func (*StoreStatsCommand) Usage() string {
+	return `store stats
+
+Prints the number and total size of the objects in the store, and how
+many were last used within a day, a week and 30 days. With a store
+index, objects count as used when they were last read or written, and
+the index's reference counts are summarized too; without one, the
+whole store is listed, and only writes count.
+`
+}
+
+func (c *StoreStatsCommand) SetFlags(flags *flag.FlagSet) {}
+
+// storeStats summarizes a listing of the objects in a store
+type storeStats struct {
+	objects, bytes int64
+	// How many objects were last used within each of statsAges
+	used                 [len(statsAges)]int64
+	referenced, refBytes int64
+}
+
+// statsAges are the ages storeStats counts objects last used within
+var statsAges = [...]struct {
+	name string
+	age  time.Duration
+}{
+	{"1d", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+func (s *storeStats) add(obj *store.ObjectInfo, now time.Time) {
+	s.objects++
+	s.bytes += obj.Size
+	age := now.Sub(obj.LastUsed())
+	for i, within := range statsAges {
+		if age <= within.age {
+			s.used[i]++
+		}
+	}
+	if obj.Refs > 0 {
+		s.referenced++
+		s.refBytes += obj.Size
+	}
+}
+
+func (s *storeStats) render(w io.Writer, indexed bool) {
+	fmt.Fprintf(w, "objects=%d\n", s.objects)
+	fmt.Fprintf(w, "bytes=%d\n", s.bytes)
+	for i, within := range statsAges {
+		fmt.Fprintf(w, "used_%s=%d\n", within.name, s.used[i])
+	}
+	if indexed {
+		fmt.Fprintf(w, "referenced=%d\n", s.referenced)
+		fmt.Fprintf(w, "referenced_bytes=%d\n", s.refBytes)
+	}
+}
+
+func (c *StoreStatsCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	st := global.MustStore()
+	coll, ok := st.(store.Collectable)
+	if !ok {
+		log.Printf("store stats: the configured store does not support listing")
+		return subcommands.ExitFailure
+	}
+	idx, ok := st.(store.Indexer)
+	indexed := ok && idx.HasIndex()
+
+	var stats storeStats
+	now := time.Now()
+	if err := coll.ListObjects(ctx, func(info store.ObjectInfo) error {
+		stats.add(&info, now)
+		return nil
+	}); err != nil {
+		log.Printf("store stats: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	stats.render(os.Stdout, indexed)
+	return subcommands.ExitSuccess
+}
+
+type StoreReindexCommand struct{}
+
+func (*StoreReindexCommand) Name() string { return "reindex" }
+func (*StoreReindexCommand) Synopsis() string {
+	return "Add objects missing from the store index"
+}
+func (*StoreReindexCommand) Usage() string {
+	return `store reindex
+
+Lists the whole object store and records every object missing from the
+store index, such as those stored before the index was configured.
+Until then, gc and "store stats" don't see them.
+`
+}
+
+func (c *StoreReindexCommand) SetFlags(flags *flag.FlagSet) {}
+
+func (c *StoreReindexCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	idx, ok := global.MustStore().(store.Indexer)
+	if !ok || !idx.HasIndex() {
+		log.Printf("store reindex: the configured store has no index; see store_index_table")
+		return subcommands.ExitFailure
+	}
+	added, err := idx.Reindex(ctx)
+	if err != nil {
+		log.Printf("store reindex: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("added %d objects to the index\n", added)
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFmtAge(t *testing.T) {
+	assert.Equal(t, "1m30s", fmtAge(90*time.Second+300*time.Millisecond))
+	assert.Equal(t, "30h0m0s", fmtAge(30*time.Hour))
+	assert.Equal(t, "3d", fmtAge(80*time.Hour))
+}
+
+func TestRenderObjects(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	renderObjects(&buf, []store.ObjectInfo{
+		{Id: "abc", Size: 100, LastModified: now.Add(-time.Minute)},
+		{Id: "abd:zstd", Size: 50, LastModified: now.Add(-72 * time.Hour)},
+	}, now)
+	out := buf.String()
+	assert.Contains(t, out, "abc       100   1m0s\n")
+	assert.Contains(t, out, "abd:zstd  50    3d\n")
+	assert.Contains(t, out, "2 objects, 150 bytes\n")
+}
+
+func TestStatFile(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "llama")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	st := store.InMemory()
+	insp := st.(store.Inspectable)
+	path := filepath.Join(dir, "a.c")
+	require.NoError(t, ioutil.WriteFile(path, []byte("int x;\n"), 0644))
+
+	id, info, err := statFile(ctx, insp, path)
+	require.NoError(t, err)
+	assert.Nil(t, info)
+	assert.Equal(t, "missing", fmtStatus(info, time.Now()))
+
+	stored, err := st.Store(ctx, []byte("int x;\n"))
+	require.NoError(t, err)
+	assert.Equal(t, stored, id)
+
+	_, info, err = statFile(ctx, insp, path)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "present size=7", fmtStatus(info, time.Now()))
+
+	_, _, err = statFile(ctx, insp, filepath.Join(dir, "missing.c"))
+	assert.Error(t, err)
+}
+
+func TestStoreStats(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	var stats storeStats
+	for _, obj := range []store.ObjectInfo{
+		{Id: "a", Size: 100, LastModified: now.Add(-time.Hour), Refs: 2},
+		{Id: "b", Size: 50, LastModified: now.Add(-40 * day), LastAccessed: now.Add(-3 * day)},
+		{Id: "c", Size: 10, LastModified: now.Add(-40 * day)},
+	} {
+		stats.add(&obj, now)
+	}
+	var buf bytes.Buffer
+	stats.render(&buf, true)
+	assert.Equal(t, `objects=3
+bytes=160
+used_1d=1
+used_7d=2
+used_30d=2
+referenced=1
+referenced_bytes=100
+`, buf.String())
+
+	buf.Reset()
+	stats.render(&buf, false)
+	assert.NotContains(t, buf.String(), "referenced")
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/store/encstore"
+)
+
+type StoreKeyCommand struct {
+	kmsKey  string
+	keyFile string
+	force   bool
+}
+
+func (*StoreKeyCommand) Name() string     { return "store-key" }
+func (*StoreKeyCommand) Synopsis() string { return "Generate a key to encrypt the object store with" }
+func (*StoreKeyCommand) Usage() string {
+	return `store-key (-kms KEY-ID | -file PATH)
+`
+}
+
+func (c *StoreKeyCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.kmsKey, "kms", "", "Generate a data key under this AWS KMS key ID, ARN, or alias")
+	flags.StringVar(&c.keyFile, "file", "", "Generate a key and write it to this local file")
+	flags.BoolVar(&c.force, "force", false, "Replace an existing key. Objects encrypted with the old key will become unreadable.")
+}
+
+func (c *StoreKeyCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	global := cli.MustState(ctx)
+	if (c.kmsKey == "") == (c.keyFile == "") {
+		log.Printf("store-key: must pass exactly one of -kms or -file")
+		return subcommands.ExitUsageError
+	}
+	// Read the config afresh, so that we don't save any overrides
+	// from the command line or environment
+	cfg, err := cli.ReadConfig(cli.ConfigPath())
+	if err != nil {
+		log.Fatalf("reading config: %s", err.Error())
+	}
+	if cfg.StoreKey != "" && !c.force {
+		log.Fatalf("llama is already configured with a store key; pass -force to replace it")
+	}
+
+	var spec string
+	if c.kmsKey != "" {
+		spec, err = encstore.GenerateKMSKey(ctx, global.MustSession(), c.kmsKey)
+	} else {
+		spec, err = encstore.GenerateKeyFile(c.keyFile)
+	}
+	if err != nil {
+		log.Fatalf("generating key: %s", err.Error())
+	}
+	cfg.StoreKey = spec
+	if err := cli.WriteConfig(cfg, cli.ConfigPath()); err != nil {
+		log.Fatalf("writing config: %s", err.Error())
+	}
+	log.Printf("Store encryption configured. Run `llama update-function` on each of your functions to give them the key.")
+	return subcommands.ExitSuccess
+}
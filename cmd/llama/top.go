@@ -0,0 +1,138 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+)
+
+type TopCommand struct {
+	path     string
+	interval time.Duration
+	once     bool
+	maxRows  int
+}
+
+func (*TopCommand) Name() string     { return "top" }
+func (*TopCommand) Synopsis() string { return "Show live status of the Llama daemon" }
+func (*TopCommand) Usage() string {
+	return `top [flags]
+`
+}
+
+func (c *TopCommand) SetFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.path, "path", cli.SocketPath(), "Path to daemon socket")
+	flags.DurationVar(&c.interval, "interval", time.Second, "Refresh interval")
+	flags.BoolVar(&c.once, "once", false, "Print the status once and exit")
+	flags.IntVar(&c.maxRows, "rows", 20, "Maximum number of in-flight invocations to show")
+}
+
+func (c *TopCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	client, err := daemon.Dial(ctx, c.path)
+	if err != nil {
+		log.Printf("Connecting to daemon: %s", err.Error())
+		return subcommands.ExitFailure
+	}
+	defer client.Close()
+
+	for {
+		status, err := client.Status(&daemon.StatusArgs{})
+		if err != nil {
+			log.Printf("Getting status: %s", err.Error())
+			return subcommands.ExitFailure
+		}
+		var buf bytes.Buffer
+		if !c.once {
+			// Clear the screen and home the cursor
+			buf.WriteString("\033[H\033[2J")
+		}
+		renderStatus(&buf, status, time.Now(), c.maxRows)
+		os.Stdout.Write(buf.Bytes())
+		if c.once {
+			return subcommands.ExitSuccess
+		}
+		select {
+		case <-ctx.Done():
+			return subcommands.ExitSuccess
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+func renderStatus(w io.Writer, st *daemon.StatusReply, now time.Time, maxRows int) {
+	stats := &st.Stats
+	fmt.Fprintf(w, "in_flight=%d queued=%d invocations=%d func_errors=%d other_errors=%d\n",
+		stats.InFlight, st.Queued, stats.Invocations, stats.FunctionErrors, stats.OtherErrors)
+	hitRate := 0.0
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		hitRate = 100 * float64(stats.CacheHits) / float64(lookups)
+	}
+	fmt.Fprintf(w, "cache_hits=%d cache_misses=%d hit_rate=%.1f%% local_fallbacks=%d region_failovers=%d retries=%d\n",
+		stats.CacheHits, stats.CacheMisses, hitRate, stats.LocalFallbacks, stats.RegionFailovers, stats.Retries)
+	fmt.Fprintf(w, "waiting_interactive=%d waiting_batch=%d concurrency_limit=%d throttles=%d\n",
+		st.Waiting[daemon.PriorityInteractive], st.Waiting[daemon.PriorityBatch],
+		stats.ConcurrencyLimit, stats.Throttles)
+	fmt.Fprintf(w, "lambda_requests=%d lambda_gb_seconds=%.1f est_cost=$%.2f\n",
+		stats.Usage.Lambda_Requests, daemon.GBSeconds(&stats.Usage), st.Cost.Total())
+
+	fmt.Fprintf(w, "\nIn flight:\n")
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  AGE\tFUNCTION\tFILE\n")
+	for i, inv := range st.InFlight {
+		if maxRows > 0 && i >= maxRows {
+			fmt.Fprintf(tw, "  ...\t(%d more)\t\n", len(st.InFlight)-maxRows)
+			break
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n",
+			now.Sub(inv.Started).Truncate(100*time.Millisecond), inv.Function, inv.Description)
+	}
+	tw.Flush()
+
+	if len(st.RecentFailures) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nRecent failures:\n")
+	tw = tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  TIME\tFUNCTION\tID\tFILE\tERROR\n")
+	for i := len(st.RecentFailures) - 1; i >= 0; i-- {
+		f := &st.RecentFailures[i]
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n",
+			f.Time.Format("15:04:05"), f.Function, failureID(f), f.Description, f.Error)
+	}
+	tw.Flush()
+}
+
+// failureID returns the ID to pass to `llama logs` for a failure
+func failureID(f *daemon.FailureStatus) string {
+	switch {
+	case f.InvocationID != "":
+		return f.InvocationID
+	case f.RequestID != "":
+		return f.RequestID
+	}
+	return "-"
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderStatus(t *testing.T) {
+	now := time.Date(2020, 12, 1, 12, 0, 0, 0, time.UTC)
+	var st daemon.StatusReply
+	st.Queued = 3
+	st.Stats.InFlight = 2
+	st.Stats.CacheHits = 1
+	st.Stats.CacheMisses = 3
+	st.Stats.Usage.Lambda_MB_Millis = 2048 * 1000
+	st.InFlight = []daemon.InvocationStatus{
+		{Function: "gcc", Description: "/src/a.o", Started: now.Add(-2 * time.Second)},
+		{Function: "gcc", Description: "/src/b.o", Started: now.Add(-time.Second)},
+	}
+	st.RecentFailures = []daemon.FailureStatus{
+		{Time: now.Add(-time.Minute), Function: "gcc", Description: "/src/c.o", Error: "exit status 1", InvocationID: "0123abcd"},
+	}
+
+	var buf bytes.Buffer
+	renderStatus(&buf, &st, now, 1)
+	out := buf.String()
+	assert.Contains(t, out, "in_flight=2 queued=3")
+	assert.Contains(t, out, "hit_rate=25.0%")
+	assert.Contains(t, out, "lambda_gb_seconds=2.0")
+	assert.Contains(t, out, "/src/a.o")
+	assert.NotContains(t, out, "/src/b.o")
+	assert.Contains(t, out, "(1 more)")
+	assert.Contains(t, out, "exit status 1")
+	assert.Contains(t, out, "0123abcd")
+}
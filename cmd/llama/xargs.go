@@ -18,33 +18,72 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net/rpc"
 	"os"
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/google/subcommands"
 	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/server"
 	"github.com/nelhage/llama/files"
 	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/logging"
 	"github.com/nelhage/llama/protocol"
 	protocol_files "github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/runner"
 	"github.com/nelhage/llama/store"
 )
 
+// The number of input lines `llama xargs` reads ahead of the jobs it
+// is running
+const xargsReadAhead = 10000
+
+const defaultXargsConcurrency = 100
+
 type XargsCommand struct {
 	logs        bool
 	files       files.List
 	concurrency int
+	memory      int64
+	timeout     time.Duration
+	quiet       bool
+	direct      bool
+	priority    daemon.Priority
+	ordered     bool
+	stdoutPath  string
+	stderrPath  string
+	jsonInput   bool
+	retries     int
+	failedPath  string
+
+	stdoutTpl *template.Template
+	stderrTpl *template.Template
+	progress  *xargsProgress
+	retry     llama.RetryPolicy
+	backoff   llama.RetryPolicy
+	lambda    *lambda.Lambda
+	local     *runner.Runner
+	function  string
+	// The variant of `function` to invoke, if any
+	variant string
+	fileMap protocol.FileList
+	client  *daemon.Client
 
-	lambda   *lambda.Lambda
-	function string
-	fileMap  protocol.FileList
+	env     envFlags
+	environ []string
+
+	skipChecksums bool
 }
 
 func (*XargsCommand) Name() string     { return "xargs" }
@@ -58,7 +97,21 @@ func (c *XargsCommand) SetFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&c.logs, "logs", false, "Display command invocation logs")
 	flags.Var(&c.files, "f", "Pass a file through to the invocation")
 	flags.Var(&c.files, "file", "Pass a file through to the invocation")
-	flags.IntVar(&c.concurrency, "j", 100, "Number of concurrent lambdas to execute")
+	flags.IntVar(&c.concurrency, "j", 0, "Number of concurrent lambdas to execute (default: concurrency from .llamarc, or 100)")
+	flags.Int64Var(&c.memory, "memory", 0, "Run on a variant of the function with at least this much memory, in MB")
+	flags.DurationVar(&c.timeout, "timeout", 0, "Run on a variant of the function with at least this timeout")
+	flags.BoolVar(&c.quiet, "quiet", false, "Only report failed jobs, with no progress display or summary")
+	flags.BoolVar(&c.direct, "direct", false, "Invoke functions directly, instead of through the daemon's scheduler")
+	c.priority = daemon.PriorityBatch
+	flags.Var(&c.priority, "priority", "Scheduling class in the daemon: interactive or batch")
+	flags.BoolVar(&c.ordered, "ordered", false, "Print each job's stdout and stderr once it completes, in input order")
+	flags.StringVar(&c.stdoutPath, "stdout", "", "Write each job's stdout to this file, templated like the arguments (e.g. 'logs/{{.Idx}}.out')")
+	flags.StringVar(&c.stderrPath, "stderr", "", "Write each job's stderr to this file, templated like the arguments")
+	flags.BoolVar(&c.jsonInput, "json", false, "Read a JSON object from each input line, and expose its fields to templates (e.g. '{{.file}}')")
+	flags.IntVar(&c.retries, "retries", 0, "Retry each failed job, including ones that exit nonzero, up to this many times")
+	flags.DurationVar(&c.backoff.Backoff, "retry-backoff", time.Second, "The delay before retrying a failed job, doubling with each retry")
+	flags.StringVar(&c.failedPath, "failed-out", "", "Write the input lines of jobs that failed, after any retries, to this file")
+	c.env.SetFlags(flags)
 }
 
 type Invocation struct {
@@ -69,23 +122,110 @@ type Invocation struct {
 	OutputPaths     map[string]string
 	Result          *llama.InvokeResult
 	Err             error
+	Elapsed         time.Duration
+	// How many times we ran the job, with -retries
+	Attempts int
+
+	// The job's output, if we fetched it
+	Stdout, Stderr []byte
 }
 
 func (c *XargsCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	global := cli.MustState(ctx)
 
 	var err error
+	if c.stdoutTpl, err = parseOutputTemplate("stdout", c.stdoutPath); err != nil {
+		log.Fatal(err)
+	}
+	if c.stderrTpl, err = parseOutputTemplate("stderr", c.stderrPath); err != nil {
+		log.Fatal(err)
+	}
+	if c.environ, err = c.env.Environ(global.Config, os.Environ()); err != nil {
+		log.Fatal(err)
+	}
 	if len(c.files) > 0 {
 		c.fileMap, err = c.files.Upload(ctx, global.MustStore(), c.fileMap)
 		if err != nil {
 			log.Fatalf("files: %s", err.Error())
 		}
 	}
-	c.lambda = lambda.New(global.MustSession())
+	if c.retry, err = global.Config.RetryPolicy(); err != nil {
+		log.Fatalf("reading config: %s", err.Error())
+	}
+	c.backoff.MaxBackoff = xargsMaxRetryBackoff
+	if c.backoff.Backoff > c.backoff.MaxBackoff {
+		c.backoff.MaxBackoff = c.backoff.Backoff
+	}
+	c.skipChecksums = global.Config.SkipOutputChecksums
+	if c.concurrency == 0 {
+		c.concurrency = defaultXargsConcurrency
+		if global.Project != nil && global.Project.Concurrency > 0 {
+			c.concurrency = global.Project.Concurrency
+		}
+	}
 	c.function = flag.Arg(0)
+	if cmdline, ok := global.Config.LocalFunctions[c.function]; ok {
+		c.local = runner.New(global.MustStore(), cmdline, "local")
+	} else {
+		c.lambda = lambda.New(global.MustSession())
+		if c.memory != 0 || c.timeout != 0 {
+			variants, err := llama.ListVariants(c.lambda, c.function)
+			if err != nil {
+				log.Fatalf("listing variants: %s", err.Error())
+			}
+			v, err := llama.PickVariant(variants, c.memory, c.timeout)
+			if err != nil {
+				log.Fatalf("%s: %s", c.function, err.Error())
+			}
+			c.variant = v.Function
+		}
+	}
+
+	if !c.direct {
+		c.client, err = server.DialWithAutostart(ctx, cli.SocketPath(), rpc.DefaultRPCPath)
+		if err != nil {
+			log.Fatalf("connecting to daemon: %s", err.Error())
+		}
+		defer c.client.Close()
+	}
+
+	var failedOut *os.File
+	if c.failedPath != "" {
+		// We write each line as its job fails, so that a long
+		// run that is interrupted still records its failures.
+		if failedOut, err = os.Create(c.failedPath); err != nil {
+			log.Fatalf("-failed-out: %s", err.Error())
+		}
+		defer failedOut.Close()
+	}
+
+	tty := !c.quiet && isTerminal(os.Stderr)
+	c.progress = newXargsProgress(os.Stderr, tty, time.Now())
+	stop := make(chan struct{})
+	refreshed := make(chan struct{})
+	if tty {
+		logging.SetOutput(c.progress)
+		go func() {
+			c.progress.refresh(250*time.Millisecond, stop)
+			close(refreshed)
+		}()
+	} else {
+		close(refreshed)
+	}
 
-	submit := make(chan *Invocation)
-	go generateJobs(ctx, os.Stdin, flag.Args()[1:], submit)
+	jobs := make(chan *Invocation)
+	go generateJobs(ctx, os.Stdin, flag.Args()[1:], c.jsonInput, jobs)
+	// Read ahead of the workers, so that we can usually
+	// estimate how much work remains.
+	submit := make(chan *Invocation, xargsReadAhead)
+	go func() {
+		for job := range jobs {
+			c.progress.queued()
+			submit <- job
+		}
+		c.progress.inputComplete()
+		close(submit)
+	}()
 	results := make(chan *Invocation)
 
 	var wg sync.WaitGroup
@@ -102,53 +242,79 @@ func (c *XargsCommand) Execute(ctx context.Context, flag *flag.FlagSet, _ ...int
 	}
 
 	code := subcommands.ExitSuccess
+	order := newJobOrderer()
 	for done := range results {
-		if done.Err != nil || done.Result.Response.ExitStatus != 0 {
-			code = subcommands.ExitFailure
+		ready := []*Invocation{done}
+		if c.ordered {
+			ready = order.add(done)
 		}
-		displayCmd := append([]string{c.function}, done.FormattedArgs...)
-		if done.Err == nil && done.Result.Response.ExitStatus == 0 {
-			log.Printf("Done: %v", displayCmd)
-			continue
-		}
-
-		if done.Err == nil {
-			log.Printf("Command exited with status: %v: %d", displayCmd, done.Result.Response.ExitStatus)
-		} else if done.Err != nil {
-			log.Printf("Invocation failed: %v: %s", displayCmd, done.Err.Error())
-			if ret, ok := done.Err.(*llama.ErrorReturn); ok {
-				if ret.Logs != nil {
-					log.Printf("==== logs ====\n%s\n==== end logs ====\n", ret.Logs)
+		for _, job := range ready {
+			if !c.report(job, tty) {
+				code = subcommands.ExitFailure
+				if failedOut != nil {
+					if err := writeFailed(failedOut, job); err != nil {
+						log.Fatalf("-failed-out: %s", err.Error())
+					}
 				}
 			}
 		}
-		if done.Result == nil {
-			continue
-		}
-		if done.Result.Logs != nil {
-			log.Printf("==== logs ====\n%s\n==== end logs ====\n", done.Result.Logs)
-		}
-		if done.Result.Response.Stdout != nil {
-			stdout, err := protocol_files.Read(ctx, global.MustStore(), done.Result.Response.Stdout)
-			if err == nil {
-				log.Printf("==== stdout ====\n%s\n==== end stdout ====\n", stdout)
-			}
+	}
+
+	close(stop)
+	<-refreshed
+	logging.SetOutput(os.Stderr)
+	if !c.quiet {
+		c.progress.summary(os.Stderr, time.Now())
+	}
+	return code
+}
+
+// report prints a completed job's output, if requested, and details
+// of its failure, if any. It returns whether the job succeeded.
+func (c *XargsCommand) report(done *Invocation, tty bool) bool {
+	if c.ordered {
+		os.Stdout.Write(done.Stdout)
+		c.progress.Write(done.Stderr)
+	}
+	displayCmd := append([]string{c.function}, done.FormattedArgs...)
+	if done.succeeded() {
+		if !c.quiet && !tty {
+			log.Printf("Done: %v", displayCmd)
 		}
-		if done.Result.Response.Stderr != nil {
-			stderr, err := protocol_files.Read(ctx, global.MustStore(), done.Result.Response.Stderr)
-			if err == nil {
-				log.Printf("==== stderr ====\n%s\n==== end stderr ====\n", stderr)
+		return true
+	}
+
+	if done.Err == nil {
+		log.Printf("Command exited with status: %v: %d", displayCmd, done.Result.Response.ExitStatus)
+	} else {
+		log.Printf("Invocation failed: %v: %s", displayCmd, done.Err.Error())
+		if ret, ok := done.Err.(*llama.ErrorReturn); ok {
+			if ret.Logs != nil {
+				log.Printf("==== logs ====\n%s\n==== end logs ====\n", ret.Logs)
 			}
 		}
 	}
-
-	return code
+	if done.Result == nil {
+		return false
+	}
+	if done.Result.Logs != nil {
+		log.Printf("==== logs ====\n%s\n==== end logs ====\n", done.Result.Logs)
+	}
+	// With -ordered or -stdout/-stderr, the output is already
+	// somewhere the user can see it.
+	if done.Stdout != nil && !c.ordered && c.stdoutTpl == nil {
+		log.Printf("==== stdout ====\n%s\n==== end stdout ====\n", done.Stdout)
+	}
+	if done.Stderr != nil && !c.ordered && c.stderrTpl == nil {
+		log.Printf("==== stderr ====\n%s\n==== end stderr ====\n", done.Stderr)
+	}
+	return false
 }
 
 func prepareTemplates(args []string) ([]*template.Template, error) {
 	var argTemplates []*template.Template
 	for i, arg := range args {
-		tpl, err := template.New(fmt.Sprintf("arg-%d", i)).Parse(arg)
+		tpl, err := template.New(fmt.Sprintf("arg-%d", i)).Option("missingkey=error").Parse(arg)
 		if err != nil {
 			return nil, fmt.Errorf("template parse error: %q: %w", arg, err)
 		}
@@ -157,7 +323,7 @@ func prepareTemplates(args []string) ([]*template.Template, error) {
 	return argTemplates, nil
 }
 
-func generateJobs(ctx context.Context, lines io.Reader, args []string, out chan<- *Invocation) {
+func generateJobs(ctx context.Context, lines io.Reader, args []string, jsonInput bool, out chan<- *Invocation) {
 	argTemplates, err := prepareTemplates(args)
 	if err != nil {
 		log.Fatal(err)
@@ -184,6 +350,11 @@ func generateJobs(ctx context.Context, lines io.Reader, args []string, out chan<
 			},
 			Templates: argTemplates,
 		}
+		if jsonInput {
+			if job.TemplateContext.fields, err = parseJSONLine(line); err != nil {
+				log.Fatalf("input line %d: %s", i+1, err.Error())
+			}
+		}
 		out <- &job
 	}
 }
@@ -191,7 +362,14 @@ func generateJobs(ctx context.Context, lines io.Reader, args []string, out chan<
 func (c *XargsCommand) worker(ctx context.Context, jobs <-chan *Invocation, out chan<- *Invocation) {
 	global := cli.MustState(ctx)
 	for job := range jobs {
-		c.run(ctx, global, job)
+		c.progress.jobStarted()
+		start := time.Now()
+		c.runWithRetries(ctx, job, func(job *Invocation) {
+			c.run(ctx, global, job)
+			c.captureOutput(ctx, global.MustStore(), job)
+		})
+		job.Elapsed = time.Since(start)
+		c.progress.jobDone(job)
 		out <- job
 	}
 }
@@ -202,8 +380,72 @@ type jobContext struct {
 	files.IOContext
 	Idx  int
 	Line string
+
+	// The fields of the input line, with -json
+	fields map[string]interface{}
+}
+
+// parseJSONLine decodes a line of -json input, which must hold a
+// single JSON object. Numbers are kept as written.
+func parseJSONLine(line string) (map[string]interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var fields map[string]interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errors.New("expected a JSON object")
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after JSON object")
+	}
+	return fields, nil
+}
+
+// fieldMap returns the job's input fields, plus the Idx and Line
+// built-ins, which take precedence.
+func (j *jobContext) fieldMap() map[string]interface{} {
+	data := make(map[string]interface{}, len(j.fields)+2)
+	for k, v := range j.fields {
+		data[k] = v
+	}
+	data["Idx"] = j.Idx
+	data["Line"] = j.Line
+	return data
 }
 
+// templateData returns the value we execute argument templates
+// against
+func (j *jobContext) templateData() interface{} {
+	if j.fields == nil {
+		return j
+	}
+	data := jsonJob(j.fieldMap())
+	data[jsonJobKey] = j
+	return data
+}
+
+// jsonJob is the template context for a line of -json input. It's a
+// map so that templates can name fields directly, as in {{.file}},
+// and has the same methods as jobContext, which delegate to the job
+// stored under jsonJobKey.
+type jsonJob map[string]interface{}
+
+const jsonJobKey = "\x00job"
+
+func (j jsonJob) job() *jobContext {
+	return j[jsonJobKey].(*jobContext)
+}
+
+func (j jsonJob) Input(file string) (string, error)       { return j.job().Input(file) }
+func (j jsonJob) I(file string) (string, error)           { return j.job().I(file) }
+func (j jsonJob) Output(file string) (string, error)      { return j.job().Output(file) }
+func (j jsonJob) O(file string) (string, error)           { return j.job().O(file) }
+func (j jsonJob) InputOutput(file string) (string, error) { return j.job().InputOutput(file) }
+func (j jsonJob) IO(file string) (string, error)          { return j.job().IO(file) }
+func (j jsonJob) AsFile(data string) string               { return j.job().AsFile(data) }
+
 func (j *jobContext) AsFile(data string) string {
 	dest := fmt.Sprintf("llama/tmp.%d", len(j.Inputs))
 	if !strings.HasSuffix(data, "\n") {
@@ -222,9 +464,10 @@ func prepareInvocation(ctx context.Context,
 	store store.Store,
 	globalFiles protocol.FileList,
 	job *Invocation) (*protocol.InvocationSpec, error) {
+	data := job.TemplateContext.templateData()
 	for _, tpl := range job.Templates {
 		var w bytes.Buffer
-		err := tpl.Execute(&w, &job.TemplateContext)
+		err := tpl.Execute(&w, data)
 		if err != nil {
 			return nil, err
 		}
@@ -256,8 +499,13 @@ func (c *XargsCommand) run(ctx context.Context, global *cli.GlobalState, job *In
 		job.Err = err
 		return
 	}
+	spec.Env = c.environ
+	function := c.function
+	if c.variant != "" {
+		function = c.variant
+	}
 	job.Args = &llama.InvokeArgs{
-		Function:   c.function,
+		Function:   function,
 		ReturnLogs: c.logs,
 		Spec:       *spec,
 	}
@@ -265,13 +513,21 @@ func (c *XargsCommand) run(ctx context.Context, global *cli.GlobalState, job *In
 	if job.Err != nil {
 		return
 	}
-	job.Result, job.Err = llama.Invoke(ctx, c.lambda, st, job.Args)
+	if c.client != nil {
+		// The daemon retries for us
+		job.Result, job.Err = c.invokeDaemon(job.Args)
+	} else {
+		job.Err = c.retry.Do(ctx, c.invokeDirect(ctx, st, job))
+	}
 
 	if job.Err == nil {
 		fetchList, extra := job.TemplateContext.Outputs.TransformToLocal(ctx, job.Result.Response.Outputs)
 		for _, out := range extra {
 			log.Printf("Remote returned unexpected output: %s", out.Path)
 		}
+		if c.skipChecksums {
+			protocol_files.SkipChecksums(fetchList)
+		}
 		var gets []store.GetRequest
 		for _, file := range fetchList {
 			gets = protocol_files.AppendGet(gets, &file.Blob)
@@ -286,3 +542,32 @@ func (c *XargsCommand) run(ctx context.Context, global *cli.GlobalState, job *In
 		}
 	}
 }
+
+func (c *XargsCommand) invokeDirect(ctx context.Context, st store.Store, job *Invocation) func(int) error {
+	return func(int) error {
+		var err error
+		if c.local != nil {
+			job.Result, err = llama.InvokeLocal(ctx, c.local, st, job.Args)
+		} else {
+			job.Result, err = llama.Invoke(ctx, &llama.LambdaSender{Lambda: c.lambda}, st, job.Args)
+		}
+		return err
+	}
+}
+
+func (c *XargsCommand) invokeDaemon(args *llama.InvokeArgs) (*llama.InvokeResult, error) {
+	reply, err := c.client.Invoke(&daemon.InvokeArgs{
+		Function:   args.Function,
+		Qualifier:  args.Qualifier,
+		ReturnLogs: args.ReturnLogs,
+		Spec:       args.Spec,
+		Priority:   c.priority,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reply.FunctionErr != nil {
+		return nil, &llama.ErrorReturn{Payload: reply.FunctionErr, Logs: reply.Logs}
+	}
+	return &llama.InvokeResult{Logs: reply.Logs, Response: reply.Response}, nil
+}
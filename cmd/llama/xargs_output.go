@@ -0,0 +1,134 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	protocol_files "github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+)
+
+// The context object passed to the -stdout and -stderr templates
+type outputContext struct {
+	Idx  int
+	Line string
+}
+
+func parseOutputTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("-%s: %w", name, err)
+	}
+	return tpl, nil
+}
+
+// writeOutputFile writes `data` to the path `tpl` expands to for
+// `job`, creating directories as necessary.
+func writeOutputFile(tpl *template.Template, job *Invocation, data []byte) error {
+	var buf bytes.Buffer
+	var tplData interface{} = &outputContext{
+		Idx:  job.TemplateContext.Idx,
+		Line: job.TemplateContext.Line,
+	}
+	if job.TemplateContext.fields != nil {
+		tplData = job.TemplateContext.fieldMap()
+	}
+	if err := tpl.Execute(&buf, tplData); err != nil {
+		return err
+	}
+	path := buf.String()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// captureOutput fetches a job's stdout and stderr, if we will need
+// them, and writes them to any files requested with -stdout and
+// -stderr.
+func (c *XargsCommand) captureOutput(ctx context.Context, st store.Store, job *Invocation) {
+	if job.Result == nil {
+		return
+	}
+	failed := !job.succeeded()
+	if !(failed || c.ordered || c.stdoutTpl != nil || c.stderrTpl != nil) {
+		return
+	}
+	resp := &job.Result.Response
+	var err error
+	if resp.Stdout != nil {
+		if job.Stdout, err = protocol_files.Read(ctx, st, resp.Stdout); err != nil {
+			job.setErr(fmt.Errorf("reading stdout: %w", err))
+		}
+	}
+	if resp.Stderr != nil {
+		if job.Stderr, err = protocol_files.Read(ctx, st, resp.Stderr); err != nil {
+			job.setErr(fmt.Errorf("reading stderr: %w", err))
+		}
+	}
+	if c.stdoutTpl != nil {
+		if err := writeOutputFile(c.stdoutTpl, job, job.Stdout); err != nil {
+			job.setErr(fmt.Errorf("writing stdout: %w", err))
+		}
+	}
+	if c.stderrTpl != nil {
+		if err := writeOutputFile(c.stderrTpl, job, job.Stderr); err != nil {
+			job.setErr(fmt.Errorf("writing stderr: %w", err))
+		}
+	}
+}
+
+// setErr records `err` as the job's error, unless it already failed
+func (job *Invocation) setErr(err error) {
+	if job.Err == nil {
+		job.Err = err
+	}
+}
+
+// jobOrderer puts completed jobs back in input order
+type jobOrderer struct {
+	next    int
+	pending map[int]*Invocation
+}
+
+func newJobOrderer() *jobOrderer {
+	return &jobOrderer{pending: make(map[int]*Invocation)}
+}
+
+// add records that `job` has completed, and returns the jobs, in
+// order, which are now ready to report.
+func (o *jobOrderer) add(job *Invocation) []*Invocation {
+	o.pending[job.TemplateContext.Idx] = job
+	var ready []*Invocation
+	for {
+		next, ok := o.pending[o.next]
+		if !ok {
+			return ready
+		}
+		delete(o.pending, o.next)
+		ready = append(ready, next)
+		o.next++
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobOrderer(t *testing.T) {
+	o := newJobOrderer()
+	job := func(i int) *Invocation {
+		return &Invocation{TemplateContext: jobContext{Idx: i}}
+	}
+	idxs := func(jobs []*Invocation) []int {
+		out := []int{}
+		for _, j := range jobs {
+			out = append(out, j.TemplateContext.Idx)
+		}
+		return out
+	}
+	assert.Equal(t, []int{}, idxs(o.add(job(2))))
+	assert.Equal(t, []int{}, idxs(o.add(job(1))))
+	assert.Equal(t, []int{0, 1, 2}, idxs(o.add(job(0))))
+	assert.Equal(t, []int{3}, idxs(o.add(job(3))))
+	assert.Equal(t, []int{}, idxs(o.add(job(5))))
+	assert.Equal(t, []int{4, 5}, idxs(o.add(job(4))))
+}
+
+func TestCaptureOutput(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	dir := t.TempDir()
+
+	stdout, err := files.NewBlob(ctx, st, []byte("out\n"))
+	require.NoError(t, err)
+	stderr, err := files.NewBlob(ctx, st, []byte("err\n"))
+	require.NoError(t, err)
+
+	var c XargsCommand
+	c.stdoutTpl, err = parseOutputTemplate("stdout", filepath.Join(dir, "logs/{{.Line}}-{{.Idx}}.out"))
+	require.NoError(t, err)
+
+	job := &Invocation{
+		TemplateContext: jobContext{Idx: 3, Line: "a"},
+		Result: &llama.InvokeResult{
+			Response: protocol.InvocationResponse{Stdout: stdout, Stderr: stderr},
+		},
+	}
+	c.captureOutput(ctx, st, job)
+	require.NoError(t, job.Err)
+	assert.Equal(t, "out\n", string(job.Stdout))
+	assert.Equal(t, "err\n", string(job.Stderr))
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "logs/a-3.out"))
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", string(got))
+
+	// Without -ordered or output files, we only fetch the output
+	// of failed jobs
+	c = XargsCommand{}
+	job.Stdout, job.Stderr = nil, nil
+	c.captureOutput(ctx, st, job)
+	assert.Nil(t, job.Stdout)
+	job.Result.Response.ExitStatus = 1
+	c.captureOutput(ctx, st, job)
+	assert.Equal(t, "out\n", string(job.Stdout))
+}
+
+func TestParseOutputTemplate(t *testing.T) {
+	tpl, err := parseOutputTemplate("stdout", "")
+	assert.NoError(t, err)
+	assert.Nil(t, tpl)
+	_, err = parseOutputTemplate("stdout", "logs/{{.Idx")
+	assert.Error(t, err)
+}
+
+func TestWriteOutputFileJSON(t *testing.T) {
+	dir := t.TempDir()
+	tpl, err := parseOutputTemplate("stdout", filepath.Join(dir, "{{.shard}}/{{.Idx}}.out"))
+	require.NoError(t, err)
+	fields, err := parseJSONLine(`{"shard": 7}`)
+	require.NoError(t, err)
+	job := &Invocation{TemplateContext: jobContext{Idx: 2, fields: fields}}
+	require.NoError(t, writeOutputFile(tpl, job, []byte("out\n")))
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "7/2.out"))
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", string(got))
+}
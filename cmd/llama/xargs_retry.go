@@ -0,0 +1,86 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/nelhage/llama/files"
+)
+
+// The longest we wait between attempts at a job with -retries
+const xargsMaxRetryBackoff = time.Minute
+
+// succeeded returns whether the job ran and exited successfully
+func (job *Invocation) succeeded() bool {
+	return job.Err == nil && job.Result.Response.ExitStatus == 0
+}
+
+// reset discards the outcome of an attempt at the job, so that we
+// can run it again. Executing the argument templates records the
+// job's files again, so we discard those too.
+func (job *Invocation) reset() {
+	job.FormattedArgs = nil
+	job.TemplateContext.IOContext = files.IOContext{}
+	job.Args = nil
+	job.Result = nil
+	job.Err = nil
+	job.Stdout = nil
+	job.Stderr = nil
+}
+
+// runWithRetries calls `attempt` to run the job, and, if it fails,
+// runs it again up to -retries more times, backing off
+// exponentially between attempts. Unlike the retries of transient
+// invocation errors that the daemon (or -direct) makes, these
+// retry any failure, including a nonzero exit status.
+func (c *XargsCommand) runWithRetries(ctx context.Context, job *Invocation, attempt func(*Invocation)) {
+	for i := 0; ; i++ {
+		job.Attempts++
+		attempt(job)
+		if job.succeeded() || i >= c.retries {
+			return
+		}
+		if !c.quiet {
+			log.Printf("Retrying (attempt %d of %d): %v: %s",
+				i+2, c.retries+1, append([]string{c.function}, job.FormattedArgs...), failureReason(job))
+		}
+		c.progress.retried()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.backoff.Delay(i)):
+		}
+		job.reset()
+	}
+}
+
+func failureReason(job *Invocation) string {
+	if job.Err != nil {
+		return job.Err.Error()
+	}
+	return fmt.Sprintf("exit status %d", job.Result.Response.ExitStatus)
+}
+
+// writeFailed records the input line of a job which failed for good
+// to the -failed-out file, so that the user can re-run just those.
+func writeFailed(w io.Writer, job *Invocation) error {
+	_, err := fmt.Fprintln(w, job.TemplateContext.Line)
+	return err
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWithRetries(t *testing.T) {
+	var log bytes.Buffer
+	c := XargsCommand{
+		retries:  2,
+		quiet:    true,
+		backoff:  llama.RetryPolicy{Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		progress: newXargsProgress(&log, false, time.Now()),
+	}
+	exit := func(status int) *llama.InvokeResult {
+		return &llama.InvokeResult{Response: protocol.InvocationResponse{ExitStatus: status}}
+	}
+
+	// Each attempt starts afresh
+	var job Invocation
+	var calls int
+	c.runWithRetries(context.Background(), &job, func(job *Invocation) {
+		assert.Nil(t, job.FormattedArgs)
+		assert.Empty(t, job.TemplateContext.Outputs)
+		job.FormattedArgs = []string{"arg"}
+		job.TemplateContext.Outputs = job.TemplateContext.Outputs.Append(files.Mapped{Remote: "out"})
+		calls++
+		if calls == 1 {
+			job.Err = errors.New("boom")
+		} else {
+			job.Result = exit(0)
+		}
+	})
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, job.Attempts)
+	assert.True(t, job.succeeded())
+
+	// A job that keeps failing is run retries+1 times
+	job = Invocation{}
+	calls = 0
+	c.runWithRetries(context.Background(), &job, func(job *Invocation) {
+		calls++
+		job.Result = exit(1)
+	})
+	assert.Equal(t, 3, calls)
+	assert.False(t, job.succeeded())
+	assert.Equal(t, 1, job.Result.Response.ExitStatus)
+
+	c.progress.summary(&log, time.Now())
+	assert.Contains(t, log.String(), "retries       3\n")
+}
+
+func TestWriteFailed(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, writeFailed(&out, &Invocation{TemplateContext: jobContext{Idx: 3, Line: `{"file": "a b.png"}`}}))
+	assert.NoError(t, writeFailed(&out, &Invocation{TemplateContext: jobContext{Idx: 7, Line: "c.png"}}))
+	assert.Equal(t, "{\"file\": \"a b.png\"}\nc.png\n", out.String())
+}
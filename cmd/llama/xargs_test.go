@@ -17,6 +17,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -67,7 +68,7 @@ func generateAndPrepare(
 	input string, args []string) []*protocol.InvocationSpec {
 	read := strings.NewReader(input)
 	jobs := make(chan *Invocation)
-	go generateJobs(context.Background(), read, args, jobs)
+	go generateJobs(context.Background(), read, args, false, jobs)
 	var specs []*protocol.InvocationSpec
 	for job := range jobs {
 		spec, err := prepareInvocation(ctx, st, files, job)
@@ -206,3 +207,68 @@ func TestPrepareInvocation_Files(t *testing.T) {
 	gotFiles = readFiles(t, ctx, st, specs[0].Files)
 	assert.Equal(t, wantFiles, gotFiles, ".I and .AsFile")
 }
+
+func TestPrepareInvocation_JSON(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	input := `{"file": "testdata/a.txt", "shard": 3, "opts": {"level": "high"}}` + "\n" +
+		`{"file": "testdata/b.txt", "shard": 10000000, "opts": {"level": "low"}, "Idx": "ignored"}` + "\n"
+	jobs := make(chan *Invocation)
+	go generateJobs(ctx, strings.NewReader(input), []string{
+		"{{.Idx}}",
+		"-shard={{.shard}}",
+		"{{.opts.level}}",
+		"{{.I .file}}",
+		`{{.O (printf "out/%v.txt" .shard)}}`,
+	}, true, jobs)
+	var specs []*protocol.InvocationSpec
+	for job := range jobs {
+		spec, err := prepareInvocation(ctx, st, nil, job)
+		must(t, err)
+		specs = append(specs, spec)
+	}
+
+	a_txt, err := ioutil.ReadFile("testdata/a.txt")
+	must(t, err)
+	b_txt, err := ioutil.ReadFile("testdata/b.txt")
+	must(t, err)
+	want := []expectation{
+		{
+			Args:    []string{"0", "-shard=3", "high", "testdata/a.txt", "out/3.txt"},
+			Files:   map[string][]byte{"testdata/a.txt": a_txt},
+			Outputs: []string{"out/3.txt"},
+		},
+		{
+			Args:    []string{"1", "-shard=10000000", "low", "testdata/b.txt", "out/10000000.txt"},
+			Files:   map[string][]byte{"testdata/b.txt": b_txt},
+			Outputs: []string{"out/10000000.txt"},
+		},
+	}
+	assert.Equal(t, len(want), len(specs))
+	for i, w := range want {
+		assertSpec(t, ctx, st, fmt.Sprintf("spec %d", i), &w, specs[i])
+	}
+
+	// Missing fields are an error, not "<no value>"
+	tpls, err := prepareTemplates([]string{"{{.nope}}"})
+	must(t, err)
+	fields, err := parseJSONLine(`{"file": "x"}`)
+	must(t, err)
+	_, err = prepareInvocation(ctx, st, nil, &Invocation{
+		TemplateContext: jobContext{fields: fields},
+		Templates:       tpls,
+	})
+	assert.Error(t, err)
+}
+
+func TestParseJSONLine(t *testing.T) {
+	fields, err := parseJSONLine(`{"a": 1, "b": "two"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": json.Number("1"), "b": "two"}, fields)
+
+	for _, bad := range []string{``, `null`, `[1, 2]`, `"str"`, `{"a": 1} {"b": 2}`, `{"a": `} {
+		_, err := parseJSONLine(bad)
+		assert.Error(t, err, "%q", bad)
+	}
+}
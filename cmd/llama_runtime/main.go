@@ -28,18 +28,46 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws/session"
 
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/runner"
 	"github.com/nelhage/llama/store"
+	_ "github.com/nelhage/llama/store/azstore"
+	"github.com/nelhage/llama/store/encstore"
+	_ "github.com/nelhage/llama/store/filestore"
+	_ "github.com/nelhage/llama/store/gcsstore"
 	"github.com/nelhage/llama/store/s3store"
 )
 
 const DiskCacheLimit = 100 * 1024 * 1024
 
 func initStore() (store.Store, error) {
+	st, err := initInnerStore()
+	if err != nil {
+		return nil, err
+	}
+	spec := os.Getenv("LLAMA_STORE_KEY")
+	if spec == "" {
+		return st, nil
+	}
+	session, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	key, err := encstore.LoadKey(context.Background(), session, spec)
+	if err != nil {
+		return nil, fmt.Errorf("LLAMA_STORE_KEY: %w", err)
+	}
+	return encstore.New(st, key)
+}
+
+func initInnerStore() (store.Store, error) {
 	session, err := session.NewSession()
 	if err != nil {
 		return nil, err
@@ -48,6 +76,9 @@ func initStore() (store.Store, error) {
 	if url == "" {
 		return nil, errors.New("Could not read llama s3 bucket from LLAMA_OBJECT_STORE")
 	}
+	if !strings.HasPrefix(url, "s3:") {
+		return store.Open(context.Background(), url)
+	}
 	cacheDir, err := ioutil.TempDir("", "llama.cache.*")
 	if err != nil {
 		return nil, err
@@ -55,6 +86,38 @@ func initStore() (store.Store, error) {
 	opts := s3store.Options{
 		DiskCachePath:  cacheDir,
 		DiskCacheBytes: DiskCacheLimit,
+		// Set when the function has an EFS filesystem
+		// attached; see `llama update-function`
+		SharedCachePath: os.Getenv("LLAMA_CACHE_DIR"),
+	}
+	if mb := os.Getenv("LLAMA_CACHE_DIR_MB"); mb != "" {
+		size, err := strconv.ParseUint(mb, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("LLAMA_CACHE_DIR_MB: %w", err)
+		}
+		opts.SharedCacheBytes = size << 20
+	}
+	if level := os.Getenv("LLAMA_COMPRESSION_LEVEL"); level != "" {
+		opts.CompressionLevel, err = strconv.Atoi(level)
+		if err != nil {
+			return nil, fmt.Errorf("LLAMA_COMPRESSION_LEVEL: %w", err)
+		}
+	}
+	opts.Endpoint = os.Getenv("LLAMA_S3_ENDPOINT")
+	opts.ForcePathStyle = os.Getenv("LLAMA_S3_PATH_STYLE") != ""
+	opts.InsecureSkipVerify = os.Getenv("LLAMA_S3_INSECURE_SKIP_VERIFY") != ""
+	opts.StorageClass = os.Getenv("LLAMA_S3_STORAGE_CLASS")
+	if age := os.Getenv("LLAMA_S3_REFRESH_AGE"); age != "" {
+		if opts.RefreshAge, err = time.ParseDuration(age); err != nil {
+			return nil, fmt.Errorf("LLAMA_S3_REFRESH_AGE: %w", err)
+		}
+	}
+	opts.IndexTable = os.Getenv("LLAMA_STORE_INDEX_TABLE")
+	opts.IndexRegion = os.Getenv("LLAMA_STORE_INDEX_REGION")
+	opts.Hash = os.Getenv("LLAMA_STORE_HASH")
+	if os.Getenv("LLAMA_STORE_KEY") != "" {
+		// encstore compresses objects before encrypting them
+		opts.CompressionLevel = -1
 	}
 	s3, err := s3store.FromSessionAndOptions(session, url, opts)
 	if err != nil {
@@ -65,6 +128,9 @@ func initStore() (store.Store, error) {
 }
 
 func main() {
+	if err := logging.Setup("", "runtime"); err != nil {
+		log.Printf("%s", err.Error())
+	}
 	runtimeURI := os.Getenv("AWS_LAMBDA_RUNTIME_API")
 	if runtimeURI == "" {
 		log.Fatalf("could not read runtime API endpoint")
@@ -91,13 +157,10 @@ func main() {
 		log.Fatalf("gen ID: %s", err.Error())
 	}
 
-	runtime := Runtime{
-		store:    store,
-		cmdline:  cmdline,
-		workerId: hex.EncodeToString(workerId[:]),
-	}
+	runtime := runner.New(store, cmdline, hex.EncodeToString(workerId[:]))
+	setupExporters(runtime)
 
-	lambda.StartWithContext(ctx, runtime.RunOne)
+	lambda.StartWithContext(ctx, runtime.Handle)
 }
 
 func computeCmdline(argv []string) []string {
@@ -0,0 +1,69 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+// +build llama.runtime
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/nelhage/llama/runner"
+	"github.com/nelhage/llama/tracing"
+	"github.com/nelhage/llama/tracing/otlp"
+	"github.com/nelhage/llama/tracing/xray"
+)
+
+// otlpExporter exports each job's spans before the job returns, since
+// Lambda may freeze us before the exporter's next periodic export
+type otlpExporter struct {
+	*otlp.Exporter
+}
+
+func (e otlpExporter) Export(spans []tracing.Span) error {
+	for i := range spans {
+		e.Submit(&spans[i])
+	}
+	e.Flush()
+	return nil
+}
+
+// setupExporters has the runner export spans itself: to X-Ray when
+// the function has active tracing, and to the OTLP collector (such
+// as Honeycomb) configured in the function's environment, if any.
+func setupExporters(r *runner.Runner) {
+	if addr := xray.AddrFromEnv(); addr != "" {
+		exp, err := xray.New(addr, os.Getenv("AWS_LAMBDA_FUNCTION_NAME"))
+		if err != nil {
+			log.Printf("xray: %s", err.Error())
+		} else {
+			r.AddExporter(exp)
+		}
+	}
+	opts, err := otlp.OptionsFromEnv("llama-runtime")
+	if err != nil {
+		log.Printf("otlp: %s", err.Error())
+		return
+	}
+	if opts != nil {
+		exp, err := otlp.New(opts)
+		if err != nil {
+			log.Printf("otlp: %s", err.Error())
+			return
+		}
+		r.AddExporter(otlpExporter{exp})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The clang static analyzer
+//
+// `clang --analyze` runs the analyzer instead of compiling, and
+// writes its report to the output, which is `FILE.plist` unless `-o`
+// says otherwise. `--analyzer-output` selects the report's format.
+// The HTML formats write a directory of reports with names we can't
+// predict, so we can't fetch them back, and run those locally.
+
+// AnalyzeFlags records the options that configure the analyzer
+type AnalyzeFlags struct {
+	// --analyze was given
+	Enabled bool
+	// The --analyzer-output format, or "" for the default, plist
+	Format string
+	// The options passed on with -Xanalyzer, in order
+	Args []string
+}
+
+// analyzeExts are the extensions of the reports in each format we
+// can fetch; "text" writes only diagnostics.
+var analyzeExts = map[string]string{
+	"":                 ".plist",
+	"plist":            ".plist",
+	"plist-multi-file": ".plist",
+	"sarif":            ".sarif",
+	"text":             "",
+}
+
+// WritesReport reports whether `comp` runs the analyzer with a format
+// that writes a report to the output.
+func (c *Compilation) WritesReport() bool {
+	return c.Analyze.Enabled && analyzeExts[c.Analyze.Format] != ""
+}
+
+func analyzeArg(c *Compilation, _ string) (filterWhere, error) {
+	c.Analyze.Enabled = true
+	return filterBoth, nil
+}
+
+func analyzerOutputArg(c *Compilation, arg string) (filterWhere, error) {
+	c.Analyze.Format = arg
+	return filterBoth, nil
+}
+
+func xanalyzerArg(c *Compilation, arg string) (filterWhere, error) {
+	if strings.HasPrefix(arg, "-analyzer-output=") {
+		c.Analyze.Format = strings.TrimPrefix(arg, "-analyzer-output=")
+	}
+	c.Analyze.Args = append(c.Analyze.Args, arg)
+	return filterBoth, nil
+}
+
+// analyzeArgs returns the options to run the analyzer remotely with
+func (c *Compilation) analyzeArgs() []string {
+	out := []string{"--analyze"}
+	if c.Analyze.Format != "" {
+		out = append(out, "--analyzer-output", c.Analyze.Format)
+	}
+	for _, arg := range c.Analyze.Args {
+		out = append(out, "-Xanalyzer", arg)
+	}
+	return out
+}
+
+// checkAnalyzeSupported returns an error if we can't run the analyzer
+// over `comp` remotely
+func checkAnalyzeSupported(cfg *Config, comp *Compilation) error {
+	if !comp.Analyze.Enabled {
+		return nil
+	}
+	if _, ok := analyzeExts[comp.Analyze.Format]; !ok {
+		return fmt.Errorf("--analyzer-output %s: reports in this format are not supported remotely", comp.Analyze.Format)
+	}
+	if cfg.LocalPreprocess {
+		return errors.New("--analyze given, and LLAMACC_LOCAL_PREPROCESS set")
+	}
+	if comp.MSVC || comp.IsPCH() || comp.UsesModules() {
+		return errors.New("--analyze is only supported for C and C++ sources")
+	}
+	return nil
+}
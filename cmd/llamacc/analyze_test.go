@@ -0,0 +1,66 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompileAnalyze(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{
+		"clang", "--analyze", "-Xanalyzer", "-analyzer-checker=core", "-O2", "parse.c",
+	})
+	require.NoError(t, err)
+	assert.True(t, comp.Analyze.Enabled)
+	assert.Equal(t, "parse.plist", comp.Output)
+	assert.True(t, comp.WritesReport())
+	assert.Equal(t, []string{"-O2"}, comp.UnknownArgs)
+	assert.Equal(t, []string{"--analyze", "-Xanalyzer", "-analyzer-checker=core"}, comp.analyzeArgs())
+	assert.False(t, comp.keepsObject())
+	require.NoError(t, checkSupported(&DefaultConfig, &comp))
+
+	comp, err = ParseCompile(&DefaultConfig, []string{
+		"clang", "--analyze", "--analyzer-output", "sarif", "-c", "parse.c", "-o", "out/parse.sarif",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sarif", comp.Analyze.Format)
+	assert.Equal(t, "out/parse.sarif", comp.Output)
+	assert.False(t, comp.keepsObject(), "the report is never left remote")
+	assert.Equal(t, []string{"--analyze", "--analyzer-output", "sarif"}, comp.analyzeArgs())
+
+	comp, err = ParseCompile(&DefaultConfig, []string{"clang", "--analyze", "--analyzer-output=text", "parse.c"})
+	require.NoError(t, err)
+	assert.Equal(t, "text", comp.Analyze.Format)
+	assert.False(t, comp.WritesReport())
+	require.NoError(t, checkSupported(&DefaultConfig, &comp))
+
+	for _, argv := range [][]string{
+		{"clang", "--analyze", "--analyzer-output", "html", "parse.c"},
+		{"clang", "--analyze", "-Xanalyzer", "-analyzer-output=plist-html", "parse.c"},
+	} {
+		comp, err = ParseCompile(&DefaultConfig, argv)
+		require.NoError(t, err)
+		assert.Error(t, checkSupported(&DefaultConfig, &comp), "%q", argv)
+	}
+
+	cfg := DefaultConfig
+	cfg.LocalPreprocess = true
+	comp, err = ParseCompile(&cfg, []string{"clang", "--analyze", "parse.c"})
+	require.NoError(t, err)
+	assert.Error(t, checkSupported(&cfg, &comp))
+}
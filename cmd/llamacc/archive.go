@@ -0,0 +1,177 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/tracing"
+)
+
+// arModifiers are the modifiers to ar's `r` and `q` operations
+// that behave the same remotely. Notably, `u` depends on timestamps
+// and `P` on full paths, neither of which survive the trip.
+const arModifiers = "cDsSUv"
+
+// An Archive is an invocation of `ar` or `ranlib` that creates or
+// updates a static library.
+type Archive struct {
+	// "ar" or "ranlib"
+	Tool string
+	// The arguments before the archive: ar's key, or ranlib's
+	// options
+	Args    []string
+	Output  string
+	Members []string
+	// Local files which must be uploaded to build the archive
+	Inputs []string
+}
+
+// ParseArchive parses `argv` as an invocation of `tool`, which is
+// either "ar" or "ranlib". Only invocations which build or index a
+// single archive out of local files are supported.
+func ParseArchive(tool string, argv []string) (Archive, error) {
+	out := Archive{Tool: tool}
+	args, err := expandResponseFiles(argv[1:])
+	if err != nil {
+		return out, err
+	}
+	switch tool {
+	case "ar":
+		err = out.parseAr(args)
+	case "ranlib":
+		err = out.parseRanlib(args)
+	default:
+		err = fmt.Errorf("unknown archiver: %s", tool)
+	}
+	if err != nil {
+		return out, err
+	}
+	out.Inputs = append(out.Inputs, out.Members...)
+	if tool == "ranlib" || fileExists(out.Output) {
+		// ar updates an existing archive in place
+		out.Inputs = append(out.Inputs, out.Output)
+	}
+	return out, nil
+}
+
+func (a *Archive) parseAr(args []string) error {
+	if len(args) < 2 {
+		return errors.New("expected an operation and an archive")
+	}
+	if strings.HasPrefix(args[0], "--") {
+		return fmt.Errorf("unsupported option: %s", args[0])
+	}
+	key := strings.TrimPrefix(args[0], "-")
+	ops := 0
+	for _, c := range key {
+		switch {
+		case c == 'r' || c == 'q':
+			ops++
+		case !strings.ContainsRune(arModifiers, c):
+			return fmt.Errorf("unsupported ar key: %s", args[0])
+		}
+	}
+	if ops != 1 {
+		return fmt.Errorf("unsupported ar key: %s", args[0])
+	}
+	a.Args = []string{key}
+	a.Output = args[1]
+	a.Members = args[2:]
+	for _, m := range a.Members {
+		if strings.HasPrefix(m, "-") {
+			return fmt.Errorf("unsupported option: %s", m)
+		}
+	}
+	return nil
+}
+
+func (a *Archive) parseRanlib(args []string) error {
+	for _, arg := range args {
+		switch {
+		case arg == "-D" || arg == "-U":
+			a.Args = append(a.Args, arg)
+		case strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("unsupported option: %s", arg)
+		case a.Output != "":
+			return fmt.Errorf("multiple archives: %s, %s", a.Output, arg)
+		default:
+			a.Output = arg
+		}
+	}
+	if a.Output == "" {
+		return errors.New("no archive given")
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.Mode().IsRegular()
+}
+
+func runLlamaArchive(cfg *Config, arc *Archive) error {
+	return runRemote(cfg, func(ctx context.Context, client *daemon.Client) error {
+		return buildRemoteArchive(ctx, client, cfg, arc)
+	})
+}
+
+// buildRemoteArchive runs `arc` on Lambda. Members compiled by
+// llamacc are already in the object store, so uploading them costs
+// no more than hashing them.
+func buildRemoteArchive(ctx context.Context, client *daemon.Client, cfg *Config, arc *Archive) error {
+	ctx, span := tracing.StartSpan(ctx, arc.Tool)
+	defer span.End()
+	span.AddField("members", len(arc.Members))
+
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return err
+	}
+
+	args := daemon.InvokeWithFilesArgs{
+		Function:      cfg.Function,
+		DropSemaphore: true,
+		Trace:         tracing.PropagationFromContext(ctx),
+	}
+	for _, in := range arc.Inputs {
+		args.Files = args.Files.Append(remap(in, wd))
+	}
+	args.Outputs = args.Outputs.Append(remap(arc.Output, wd))
+	cfg.keepRemote(&args, arc.Output, wd)
+
+	args.Args = []string{cfg.RemoteAR}
+	if arc.Tool == "ranlib" {
+		args.Args[0] = cfg.RemoteRanlib
+	}
+	args.Args = append(args.Args, arc.Args...)
+	args.Args = append(args.Args, toRemote(arc.Output, wd))
+	for _, m := range arc.Members {
+		args.Args = append(args.Args, toRemote(m, wd))
+	}
+	if cfg.Verbose {
+		log.Printf("[llamacc] running %s remotely: %#v", arc.Tool, args)
+	}
+
+	_, err = invokeRemote(client, cfg, &args, os.Stdout)
+	return err
+}
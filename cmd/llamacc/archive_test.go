@@ -0,0 +1,109 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llamacc-archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "libold.a")
+	require.NoError(t, ioutil.WriteFile(existing, []byte("!<arch>\n"), 0644))
+	fresh := filepath.Join(dir, "libnew.a")
+
+	tests := []struct {
+		tool string
+		argv []string
+		out  Archive
+		err  bool
+	}{
+		{
+			"ar",
+			[]string{"ar", "rcs", fresh, "a.o", "b.o"},
+			Archive{
+				Tool:    "ar",
+				Args:    []string{"rcs"},
+				Output:  fresh,
+				Members: []string{"a.o", "b.o"},
+				Inputs:  []string{"a.o", "b.o"},
+			},
+			false,
+		},
+		{
+			"ar",
+			[]string{"ar", "-qD", existing, "c.o"},
+			Archive{
+				Tool:    "ar",
+				Args:    []string{"qD"},
+				Output:  existing,
+				Members: []string{"c.o"},
+				Inputs:  []string{"c.o", existing},
+			},
+			false,
+		},
+		{
+			"ranlib",
+			[]string{"ranlib", "-D", existing},
+			Archive{
+				Tool:   "ranlib",
+				Args:   []string{"-D"},
+				Output: existing,
+				Inputs: []string{existing},
+			},
+			false,
+		},
+		{"ar", []string{"ar", "t", existing}, Archive{}, true},
+		{"ar", []string{"ar", "rcu", fresh, "a.o"}, Archive{}, true},
+		{"ar", []string{"ar", "rq", fresh, "a.o"}, Archive{}, true},
+		{"ar", []string{"ar", "--plugin", "liblto.so", "rcs", fresh}, Archive{}, true},
+		{"ar", []string{"ar", "rcs"}, Archive{}, true},
+		{"ranlib", []string{"ranlib", "-t", existing}, Archive{}, true},
+		{"ranlib", []string{"ranlib", existing, fresh}, Archive{}, true},
+	}
+	for i, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := ParseArchive(tc.tool, tc.argv)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &tc.out, &got)
+		})
+	}
+}
+
+func TestArchiver(t *testing.T) {
+	assert.Equal(t, "ar", DefaultConfig.Archiver("llama-ar"))
+	assert.Equal(t, "ar", DefaultConfig.Archiver(`C:\bin\llamaar.exe`))
+	assert.Equal(t, "ranlib", DefaultConfig.Archiver("/usr/local/bin/llama-ranlib"))
+	assert.Equal(t, "", DefaultConfig.Archiver("llamacc"))
+	assert.Equal(t, "", DefaultConfig.Archiver("llamac++"))
+	cfg := ParseConfig([]string{"LLAMACC_DRIVER=ranlib"})
+	assert.Equal(t, "ranlib", cfg.Archiver("llamacc"))
+	cfg = ParseConfig([]string{"LLAMACC_DRIVER=c++"})
+	assert.Equal(t, "", cfg.Archiver("llama-ar"))
+}
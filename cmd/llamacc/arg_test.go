@@ -16,6 +16,9 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -38,12 +41,33 @@ func TestParseCompile(t *testing.T) {
 				Input:                "platform/linux/linux_ptrace.c",
 				Output:               "platform/linux/linux_ptrace.o",
 				UnknownArgs:          []string{"-Wall", "-Werror", "-g"},
-				LocalArgs:            []string{"-MD", "-Wall", "-Werror", "-D_GNU_SOURCE", "-g", "-MF", "platform/linux/linux_ptrace.d"},
+				LocalArgs:            []string{"-MD", "-Wall", "-Werror", "-D_GNU_SOURCE", "-g", "-MF", "platform/linux/linux_ptrace.d", "-MT", "platform/linux/linux_ptrace.o"},
 				RemoteArgs:           []string{"-Wall", "-Werror", "-g", "-c"},
 				Flag: Flags{
 					MD: true,
 					C:  true,
 					MF: "platform/linux/linux_ptrace.d",
+					MT: []DepTarget{{"-MT", "platform/linux/linux_ptrace.o"}},
+				},
+			},
+			false,
+		},
+		{
+			[]string{
+				"cc", "-MMD", "-MF", "obj/foo.o.d", "-MQ", "obj/foo.o", "-c", "../src/foo.c", "-o", "obj/foo.o",
+			},
+			Compilation{
+				Language:             "c",
+				PreprocessedLanguage: "cpp-output",
+				Input:                "../src/foo.c",
+				Output:               "obj/foo.o",
+				LocalArgs:            []string{"-MMD", "-MF", "obj/foo.o.d", "-MQ", "obj/foo.o"},
+				RemoteArgs:           []string{"-c"},
+				Flag: Flags{
+					MMD: true,
+					C:   true,
+					MF:  "obj/foo.o.d",
+					MT:  []DepTarget{{"-MQ", "obj/foo.o"}},
 				},
 			},
 			false,
@@ -98,6 +122,52 @@ func TestParseCompile(t *testing.T) {
 			},
 			false,
 		},
+		{
+			[]string{
+				"cc", "-MD", "-MF", "src/start.d", "-c", "src/start.s", "-o", "src/start.o",
+			},
+			Compilation{
+				Language:             LangAssembler,
+				PreprocessedLanguage: "assembler",
+				Input:                "src/start.s",
+				Output:               "src/start.o",
+				LocalArgs:            []string{"-MD", "-MF", "src/start.d"},
+				RemoteArgs:           []string{"-c"},
+				Flag: Flags{
+					C: true,
+				},
+			},
+			false,
+		},
+		{
+			[]string{
+				"c++", "-x", "c++-header", "-O2", "src/pch.h", "-o", "src/pch.h.gch",
+			},
+			Compilation{
+				Language:    LangCxxHeader,
+				Input:       "src/pch.h",
+				Output:      "src/pch.h.gch",
+				UnknownArgs: []string{"-O2"},
+				LocalArgs:   []string{"-x", "c++-header", "-O2"},
+				RemoteArgs:  []string{"-O2"},
+			},
+			false,
+		},
+		{
+			[]string{
+				"cc", "-c", "pch.h",
+			},
+			Compilation{
+				Language:   LangCHeader,
+				Input:      "pch.h",
+				Output:     "pch.h.gch",
+				RemoteArgs: []string{"-c"},
+				Flag: Flags{
+					C: true,
+				},
+			},
+			false,
+		},
 	}
 	for i, tc := range tests {
 		tc := tc
@@ -117,6 +187,148 @@ func TestParseCompile(t *testing.T) {
 	}
 }
 
+func TestParseCompileInstrumentation(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{
+		"cc", "-fsanitize=address", "-fsanitize-ignorelist=asan.txt", "-c", "foo.c",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []AuxInput{{"-fsanitize-ignorelist=", "asan.txt"}}, comp.AuxInputs)
+	assert.Equal(t, []string{"-fsanitize=address"}, comp.UnknownArgs)
+	assert.Equal(t, []string{"-fsanitize=address", "-c"}, comp.RemoteArgs)
+
+	for _, flag := range []string{"--coverage", "-fprofile-arcs", "-fprofile-generate=prof"} {
+		_, err := ParseCompile(&DefaultConfig, []string{"cc", flag, "-c", "foo.c"})
+		assert.Error(t, err, flag)
+	}
+
+	_, err = ParseCompile(&DefaultConfig, []string{"clang", "-fprofile-instr-generate", "-fcoverage-mapping", "-c", "foo.c"})
+	assert.NoError(t, err)
+}
+
+func TestParseCompileExplicitLanguage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llamacc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	input := path.Join(dir, "vectors.asm")
+	require.NoError(t, ioutil.WriteFile(input, []byte("nop\n"), 0644))
+
+	comp, err := ParseCompile(&DefaultConfig, []string{
+		"cc", "-x", "assembler-with-cpp", "-c", input, "-o", "vectors.o",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, LangAssemblerWithCpp, comp.Language)
+	assert.Equal(t, input, comp.Input)
+	assert.True(t, comp.IsAssembly())
+
+	_, err = ParseCompile(&DefaultConfig, []string{"cc", "-c", input, "-o", "vectors.o"})
+	assert.Error(t, err)
+}
+
+func TestRemoteCompiler(t *testing.T) {
+	cfg := ParseConfig([]string{
+		"LLAMACC_REMOTE_CXX=x86_64-linux-gnu-g++",
+		"LLAMACC_TARGET=x86_64-linux-gnu",
+	})
+	c := Compilation{Language: LangC}
+	assert.Equal(t, "cc", c.RemoteCompiler(&cfg))
+	c.Language = LangCxx
+	assert.Equal(t, "x86_64-linux-gnu-g++", c.RemoteCompiler(&cfg))
+	assert.Equal(t, []string{"--target=x86_64-linux-gnu"}, cfg.TargetArgs())
+	assert.Nil(t, DefaultConfig.TargetArgs())
+}
+
+func TestParseCompileLanguage(t *testing.T) {
+	cxx := ParseConfig([]string{"LLAMACC_DRIVER=c++"})
+	cases := []struct {
+		cfg  *Config
+		argv []string
+		lang Lang
+	}{
+		{&DefaultConfig, []string{"cc", "-c", "foo.c"}, LangC},
+		{&DefaultConfig, []string{"llamac++", "-c", "foo.c"}, LangCxx},
+		{&DefaultConfig, []string{"/usr/bin/llamacxx", "-c", "foo.h"}, LangCxxHeader},
+		{&DefaultConfig, []string{"llamac++-10", "-c", "foo.c"}, LangCxx},
+		{&cxx, []string{"llamacc", "-c", "foo.c"}, LangCxx},
+		{&DefaultConfig, []string{"cc", "-c", "foo.C"}, LangCxx},
+		{&DefaultConfig, []string{"cc", "-std=c++17", "-c", "foo.h"}, LangCxxHeader},
+		{&DefaultConfig, []string{"cc", "-std=c++17", "-std=gnu11", "-c", "foo.h"}, LangCHeader},
+		{&DefaultConfig, []string{"cc", "-std=c++17", "-c", "foo.c"}, LangC},
+		{&DefaultConfig, []string{"cc", "-x", "c++", "-c", "foo.c"}, LangCxx},
+		{&DefaultConfig, []string{"cc", "-x", "c++", "-c", "foo.c", "-x", "none"}, LangCxx},
+		{&DefaultConfig, []string{"cc", "-x", "c++", "-x", "none", "-c", "foo.c"}, LangC},
+		{&DefaultConfig, []string{"cc", "-c", "foo.c", "-x", "c++"}, LangC},
+		{&DefaultConfig, []string{"cc", "-c", "foo.m"}, LangObjC},
+		{&DefaultConfig, []string{"cc", "-c", "foo.mm"}, LangObjCxx},
+	}
+	for _, tc := range cases {
+		comp, err := ParseCompile(tc.cfg, tc.argv)
+		if assert.NoError(t, err, "%q", tc.argv) {
+			assert.Equal(t, tc.lang, comp.Language, "%q", tc.argv)
+		}
+	}
+
+	cfg := DefaultConfig
+	comp, err := ParseCompile(&cfg, []string{"cc", "-c", "foo.mm"})
+	require.NoError(t, err)
+	assert.Equal(t, "c++", comp.LocalCompiler(&cfg))
+	assert.Equal(t, "objective-c++-cpp-output", comp.PreprocessedLanguage)
+}
+
+func TestIsCxx(t *testing.T) {
+	cases := []struct {
+		argv0 string
+		cxx   bool
+	}{
+		{"llamacc", false},
+		{"/usr/local/bin/llamacc", false},
+		{"llamac++", true},
+		{"llamacxx", true},
+		{"llamac++-12", true},
+		{"x86_64-linux-gnu-llamac++", true},
+		{"/opt/c++/bin/llamacc", false},
+		{"llamac++.exe", true},
+		{"llamacc.EXE", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.cxx, DefaultConfig.IsCxx(tc.argv0), tc.argv0)
+	}
+	cfg := ParseConfig([]string{"LLAMACC_DRIVER=cc"})
+	assert.False(t, cfg.IsCxx("llamac++"))
+}
+
+func TestEnvIncludes(t *testing.T) {
+	cfg := ParseConfig([]string{
+		"CPATH=/opt/include::",
+		"C_INCLUDE_PATH=/opt/c",
+		"CPLUS_INCLUDE_PATH=/opt/cxx:/opt/cxx2",
+	})
+	assert.Equal(t, []Include{
+		{"-I", "/opt/include"},
+		{"-I", "."},
+		{"-I", "."},
+		{"-isystem", "/opt/c"},
+	}, cfg.EnvIncludes(LangC))
+	assert.Equal(t, []Include{
+		{"-I", "/opt/include"},
+		{"-I", "."},
+		{"-I", "."},
+		{"-isystem", "/opt/cxx"},
+		{"-isystem", "/opt/cxx2"},
+	}, cfg.EnvIncludes(LangCxx))
+	assert.Nil(t, DefaultConfig.EnvIncludes(LangC))
+
+	comp, err := ParseCompile(&cfg, []string{"cc", "-Iinc", "-isystem", "sys", "-c", "foo.c"})
+	require.NoError(t, err)
+	assert.Equal(t, []Include{
+		{"-I", "inc"},
+		{"-isystem", "sys"},
+		{"-I", "/opt/include"},
+		{"-I", "."},
+		{"-I", "."},
+		{"-isystem", "/opt/c"},
+	}, comp.Includes)
+}
+
 func TestRewriteWp(t *testing.T) {
 	cases := []struct {
 		in  []string
@@ -140,3 +352,35 @@ func TestRewriteWp(t *testing.T) {
 		assert.Equal(t, tc.out, got)
 	}
 }
+
+func TestSplitResponseFile(t *testing.T) {
+	cases := []struct {
+		in  string
+		out []string
+	}{
+		{"", nil},
+		{"-c foo.c\n-o foo.o\n", []string{"-c", "foo.c", "-o", "foo.o"}},
+		{`-DMSG="hello world" 'a b' c\ d`, []string{"-DMSG=hello world", "a b", "c d"}},
+		{`"" -I\\server`, []string{"", `-I\server`}},
+	}
+	for _, tc := range cases {
+		got := splitResponseFile(tc.in)
+		assert.Equal(t, tc.out, got, "split(%q)", tc.in)
+	}
+}
+
+func TestExpandResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	outer := path.Join(dir, "outer.rsp")
+	inner := path.Join(dir, "inner.rsp")
+	require.NoError(t, ioutil.WriteFile(outer, []byte("-c @"+inner+"\n-o foo.o"), 0644))
+	require.NoError(t, ioutil.WriteFile(inner, []byte("-DFOO foo.c"), 0644))
+
+	got, err := expandResponseFiles([]string{"-g", "@" + outer, "@missing.rsp"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-g", "-c", "-DFOO", "foo.c", "-o", "foo.o", "@missing.rsp"}, got)
+
+	require.NoError(t, ioutil.WriteFile(inner, []byte("@"+inner), 0644))
+	_, err = expandResponseFiles([]string{"@" + inner})
+	assert.Error(t, err)
+}
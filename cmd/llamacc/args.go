@@ -17,7 +17,9 @@ package main
 import (
 	"errors"
 	"fmt"
-	"path"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -28,6 +30,21 @@ const (
 	LangCxx              Lang = "c++"
 	LangAssembler        Lang = "assembler"
 	LangAssemblerWithCpp Lang = "assembler-with-cpp"
+	LangCHeader          Lang = "c-header"
+	LangCxxHeader        Lang = "c++-header"
+	LangObjC             Lang = "objective-c"
+	LangObjCxx           Lang = "objective-c++"
+	LangObjCHeader       Lang = "objective-c-header"
+	LangObjCxxHeader     Lang = "objective-c++-header"
+	// A C++20 module interface unit, in clang's dialect; see
+	// modules.go
+	LangCxxModule Lang = "c++-module"
+	// Fortran, in fixed and free form, with and without the C
+	// preprocessor; see fortran.go
+	LangFortran77    Lang = "f77"
+	LangFortran77Cpp Lang = "f77-cpp-input"
+	LangFortran      Lang = "f95"
+	LangFortranCpp   Lang = "f95-cpp-input"
 )
 
 var knownLangs = map[string]Lang{
@@ -35,21 +52,81 @@ var knownLangs = map[string]Lang{
 	string(LangCxx):              LangCxx,
 	string(LangAssembler):        LangAssembler,
 	string(LangAssemblerWithCpp): LangAssemblerWithCpp,
+	string(LangCHeader):          LangCHeader,
+	string(LangCxxHeader):        LangCxxHeader,
+	string(LangObjC):             LangObjC,
+	string(LangObjCxx):           LangObjCxx,
+	string(LangObjCHeader):       LangObjCHeader,
+	string(LangObjCxxHeader):     LangObjCxxHeader,
+	string(LangCxxModule):        LangCxxModule,
+	string(LangFortran77):        LangFortran77,
+	string(LangFortran77Cpp):     LangFortran77Cpp,
+	string(LangFortran):          LangFortran,
+	string(LangFortranCpp):       LangFortranCpp,
 }
 
 var extLangs = map[string]Lang{
-	".c":   LangC,
-	".cxx": LangCxx,
-	".cc":  LangCxx,
-	".cpp": LangCxx,
-	".s":   LangAssembler,
-	".S":   LangAssemblerWithCpp,
+	".c":    LangC,
+	".cxx":  LangCxx,
+	".cc":   LangCxx,
+	".cpp":  LangCxx,
+	".cp":   LangCxx,
+	".c++":  LangCxx,
+	".C":    LangCxx,
+	".CPP":  LangCxx,
+	".m":    LangObjC,
+	".mm":   LangObjCxx,
+	".M":    LangObjCxx,
+	".s":    LangAssembler,
+	".S":    LangAssemblerWithCpp,
+	".h":    LangCHeader,
+	".hh":   LangCxxHeader,
+	".hpp":  LangCxxHeader,
+	".hxx":  LangCxxHeader,
+	".cppm": LangCxxModule,
+	".ccm":  LangCxxModule,
+	".cxxm": LangCxxModule,
+	".c++m": LangCxxModule,
+	".f":    LangFortran77,
+	".for":  LangFortran77,
+	".ftn":  LangFortran77,
+	".F":    LangFortran77Cpp,
+	".FOR":  LangFortran77Cpp,
+	".FTN":  LangFortran77Cpp,
+	".fpp":  LangFortran77Cpp,
+	".FPP":  LangFortran77Cpp,
+	".f90":  LangFortran,
+	".f95":  LangFortran,
+	".f03":  LangFortran,
+	".f08":  LangFortran,
+	".F90":  LangFortranCpp,
+	".F95":  LangFortranCpp,
+	".F03":  LangFortranCpp,
+	".F08":  LangFortranCpp,
+}
+
+// cxxLangs maps languages to the language the C++ driver compiles
+// them as, for the extensions which the C and C++ drivers treat
+// differently.
+var cxxLangs = map[Lang]Lang{
+	LangC:       LangCxx,
+	LangCHeader: LangCxxHeader,
 }
 
 var preprocessedLang = map[Lang]string{
 	LangCxx:              "c++-cpp-output",
 	LangC:                "cpp-output",
+	LangObjC:             "objective-c-cpp-output",
+	LangObjCxx:           "objective-c++-cpp-output",
 	LangAssemblerWithCpp: "assembler",
+	// Plain assembly is never preprocessed
+	LangAssembler: "assembler",
+	// We never preprocess Fortran locally; see
+	// checkFortranSupported
+	LangFortran77Cpp: string(LangFortran77),
+	LangFortranCpp:   string(LangFortran),
+	LangFortran77:    string(LangFortran77),
+	LangFortran:      string(LangFortran),
 }
 
 type Compilation struct {
@@ -63,6 +140,33 @@ type Compilation struct {
 	Flag                 Flags
 	Defs                 []Def
 	Includes             []Include
+	AuxInputs            []AuxInput
+	PrefixMaps           []PrefixMap
+	// If true, the arguments are in the dialect of MSVC's cl.exe,
+	// and we compile with clang-cl
+	MSVC bool
+
+	// C++20 modules: the options configuring them, and the module
+	// declarations in the input, which checkSupported scans for
+	Modules ModuleFlags
+	Unit    ModuleUnit
+
+	// Where `-save-temps` keeps intermediates: "cwd" or "obj", or
+	// empty if it wasn't given; see SecondaryOutputs
+	SaveTemps string
+
+	// Clang's static analyzer; see analyze.go
+	Analyze AnalyzeFlags
+
+	// Fortran modules; see fortran.go
+	Fortran FortranFlags
+
+	// The source, if Input is `-`; see stdio.go
+	Stdin []byte
+
+	// The sysroot, from `--sysroot` or LLAMACC_SYSROOT; see
+	// sysroot.go
+	Sysroot string
 }
 
 type Def struct {
@@ -70,23 +174,121 @@ type Def struct {
 	Def string
 }
 
+// An AuxInput is an option naming a file, other than the source
+// file and its headers, which the compiler reads, such as a sanitizer
+// ignore list.
+type AuxInput struct {
+	// The option, including any trailing `=`
+	Opt  string
+	Path string
+}
+
+// A PrefixMap is an option like `-ffile-prefix-map=OLD=NEW`, which
+// rewrites the paths the compiler records in its output.
+type PrefixMap struct {
+	// The option, including the trailing `=`
+	Opt string
+	Old string
+	New string
+}
+
+func (m PrefixMap) String() string {
+	return m.Opt + m.Old + "=" + m.New
+}
+
+type DepTarget struct {
+	Opt    string
+	Target string
+}
+
 type Include struct {
 	Opt  string
 	Path string
 }
 
+// IsCxx returns true if `l` is a dialect of C++, and so must be
+// compiled with the C++ compiler.
+func (l Lang) IsCxx() bool {
+	switch l {
+	case LangCxx, LangCxxHeader, LangObjCxx, LangObjCxxHeader, LangCxxModule:
+		return true
+	}
+	return false
+}
+
 func (c *Compilation) LocalCompiler(cfg *Config) string {
-	if c.Language == "c++" {
+	if c.MSVC {
+		return cfg.LocalCL
+	}
+	if c.Language.IsFortran() {
+		return cfg.LocalFC
+	}
+	if c.Language.IsCxx() {
 		return cfg.LocalCXX
 	}
 	return cfg.LocalCC
 }
 
 func (c *Compilation) RemoteCompiler(cfg *Config) string {
-	if c.Language == "c++" {
-		return "c++"
+	if c.MSVC {
+		return cfg.RemoteCL
+	}
+	if c.Language.IsFortran() {
+		return cfg.RemoteFC
 	}
-	return "cc"
+	if c.Language.IsCxx() {
+		return cfg.RemoteCXX
+	}
+	return cfg.RemoteCC
+}
+
+// TargetArgs returns the arguments needed to make the remote
+// compiler produce code for the configured target, if any.
+func (cfg *Config) TargetArgs() []string {
+	if cfg.Target == "" {
+		return nil
+	}
+	return []string{"--target=" + cfg.Target}
+}
+
+// IsAssembly returns true if this compilation assembles a `.s` or
+// `.S` file.
+func (c *Compilation) IsAssembly() bool {
+	return c.Language == LangAssembler || c.Language == LangAssemblerWithCpp
+}
+
+// IsPCH returns true if this compilation generates a precompiled
+// header, instead of an object file.
+func (c *Compilation) IsPCH() bool {
+	switch c.Language {
+	case LangCHeader, LangCxxHeader, LangObjCHeader, LangObjCxxHeader:
+		return true
+	}
+	return false
+}
+
+// PrecompiledHeaders returns the precompiled headers that may be
+// consumed by this compilation and which exist on the local
+// filesystem. GCC looks for `FILE.gch` alongside any header passed
+// via `-include FILE`, and clang consumes `-include-pch FILE`
+// directly.
+func (c *Compilation) PrecompiledHeaders() []string {
+	var out []string
+	for _, inc := range c.Includes {
+		var pch string
+		switch inc.Opt {
+		case "-include":
+			pch = inc.Path + ".gch"
+		case "-include-pch":
+			pch = inc.Path
+		default:
+			continue
+		}
+		if st, err := os.Stat(pch); err == nil && st.Mode().IsRegular() {
+			out = append(out, pch)
+		}
+	}
+	return out
 }
 
 type Flags struct {
@@ -94,13 +296,23 @@ type Flags struct {
 	MMD bool
 	MP  bool
 	MF  string
+	// -MT and -MQ options, in order
+	MT []DepTarget
 
 	C bool
 	S bool
+
+	// The cl.exe `/showIncludes` option, if given
+	ShowIncludes string
+
+	TestCoverage bool
+	// -gsplit-dwarf, which writes debug info to a `.dwo` file
+	// beside the object
+	SplitDwarf bool
 }
 
 func smellsLikeInput(arg string) bool {
-	ext := path.Ext(arg)
+	ext := filepath.Ext(arg)
 	_, ok := extLangs[ext]
 	return ok
 
@@ -114,6 +326,23 @@ func smellsLikeInput(arg string) bool {
 	*/
 }
 
+// isCxxStd returns true if the last `-std=` in `args` selects a C++
+// standard.
+func isCxxStd(args []string) bool {
+	std := ""
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-std=") {
+			std = arg[len("-std="):]
+		}
+	}
+	return strings.Contains(std, "++")
+}
+
+func isFile(arg string) bool {
+	fi, err := os.Stat(arg)
+	return err == nil && fi.Mode().IsRegular()
+}
+
 type filterWhere int
 
 const (
@@ -145,6 +374,36 @@ func includeArg(opt string) argSpec {
 	}, true}
 }
 
+func auxInputArg(opt string) argSpec {
+	return argSpec{opt, func(c *Compilation, arg string) (filterWhere, error) {
+		c.AuxInputs = append(c.AuxInputs, AuxInput{opt, arg})
+		return filterRemote, nil
+	}, true}
+}
+
+// Prefix maps name local paths, which we rewrite to match the paths
+// the remote compiler sees; see remotePrefixMaps.
+func prefixMapArg(opt string) argSpec {
+	return argSpec{opt, func(c *Compilation, arg string) (filterWhere, error) {
+		eq := strings.IndexByte(arg, '=')
+		if eq < 0 {
+			return 0, fmt.Errorf("%s%s: expected OLD=NEW", opt, arg)
+		}
+		c.PrefixMaps = append(c.PrefixMaps, PrefixMap{opt, arg[:eq], arg[eq+1:]})
+		return filterRemote, nil
+	}, true}
+}
+
+// GCC's gcov instrumentation records the absolute path of the object
+// file, as the compiler sees it, to locate the .gcda files, so it
+// can't be generated remotely. The .gcno notes file alone, from
+// -ftest-coverage, is a secondary output.
+func gcovArg(opt string) argSpec {
+	return argSpec{opt, func(c *Compilation, _ string) (filterWhere, error) {
+		return 0, fmt.Errorf("%s: gcov instrumentation is not supported remotely", opt)
+	}, false}
+}
+
 var argSpecs = []argSpec{
 	{"-MD", func(c *Compilation, _ string) (filterWhere, error) {
 		c.Flag.MD = true
@@ -158,7 +417,12 @@ var argSpecs = []argSpec{
 		c.Flag.MF = arg
 		return filterRemote, nil
 	}, true},
-	{"-MT", func(c *Compilation, _ string) (filterWhere, error) {
+	{"-MT", func(c *Compilation, arg string) (filterWhere, error) {
+		c.Flag.MT = append(c.Flag.MT, DepTarget{"-MT", arg})
+		return filterRemote, nil
+	}, true},
+	{"-MQ", func(c *Compilation, arg string) (filterWhere, error) {
+		c.Flag.MT = append(c.Flag.MT, DepTarget{"-MQ", arg})
 		return filterRemote, nil
 	}, true},
 	{"-MP", func(c *Compilation, _ string) (filterWhere, error) {
@@ -185,6 +449,12 @@ var argSpecs = []argSpec{
 		return 0, errors.New("-S given")
 	}, false},
 	{"-x", func(c *Compilation, arg string) (filterWhere, error) {
+		if arg == "none" {
+			// Go back to inferring the language from the
+			// extension
+			c.Language = ""
+			return filterRemote, nil
+		}
 		lang, ok := knownLangs[arg]
 		if ok {
 			c.Language = lang
@@ -208,17 +478,107 @@ var argSpecs = []argSpec{
 	includeArg("-iwithprefixbefore"),
 	includeArg("-iwithprefix"),
 	includeArg("-isysroot"),
+	// Must precede --sysroot, since specs are matched by prefix
+	sysrootArg("--sysroot="),
+	sysrootArg("--sysroot"),
+	// Must precede -include, since specs are matched by prefix
+	includeArg("-include-pch"),
 	includeArg("-include"),
 	{"-nostdinc", func(c *Compilation, _ string) (filterWhere, error) {
 		return filterRemote, nil
 	}, false},
+	auxInputArg("-fsanitize-blacklist="),
+	auxInputArg("-fsanitize-ignorelist="),
+	auxInputArg("-fsanitize-coverage-whitelist="),
+	auxInputArg("-fsanitize-coverage-allowlist="),
+	auxInputArg("-fsanitize-coverage-blacklist="),
+	auxInputArg("-fsanitize-coverage-ignorelist="),
+	auxInputArg("-fprofile-instr-use="),
+	auxInputArg("-fprofile-sample-use="),
+	auxInputArg("-fprofile-list="),
+	prefixMapArg("-ffile-prefix-map="),
+	prefixMapArg("-fdebug-prefix-map="),
+	prefixMapArg("-fmacro-prefix-map="),
+	prefixMapArg("-fprofile-prefix-map="),
+	gcovArg("--coverage"),
+	gcovArg("-fprofile-arcs"),
+	{"-ftest-coverage", func(c *Compilation, _ string) (filterWhere, error) {
+		c.Flag.TestCoverage = true
+		return 0, nil
+	}, false},
+	gcovArg("-fprofile-generate"),
+	gcovArg("-fprofile-use"),
+	gcovArg("-fauto-profile"),
+	{"-fmodules-ts", func(c *Compilation, _ string) (filterWhere, error) {
+		c.Modules.GCC = true
+		return filterRemote, nil
+	}, false},
+	{"--precompile", func(c *Compilation, _ string) (filterWhere, error) {
+		c.Modules.Precompile = true
+		return filterRemote, nil
+	}, false},
+	{"-fmodule-output=", func(c *Compilation, arg string) (filterWhere, error) {
+		c.Modules.Output = arg
+		return filterRemote, nil
+	}, true},
+	// Must follow -fmodule-output=, since specs are matched by
+	// prefix
+	{"-fmodule-output", func(c *Compilation, _ string) (filterWhere, error) {
+		// The output is named after the object file; see
+		// ParseCompile
+		c.Modules.Output = "-"
+		return filterRemote, nil
+	}, false},
+	{"-fmodule-file=", moduleFileArg, true},
+	{"-fprebuilt-module-path=", func(c *Compilation, arg string) (filterWhere, error) {
+		c.Modules.PrebuiltPaths = append(c.Modules.PrebuiltPaths, arg)
+		return filterRemote, nil
+	}, true},
+	// clang's -gsplit-dwarf=single keeps the debug info in the
+	// object
+	{"-gsplit-dwarf=", func(c *Compilation, arg string) (filterWhere, error) {
+		c.Flag.SplitDwarf = arg != "single"
+		return 0, nil
+	}, true},
+	// Must follow -gsplit-dwarf=, since specs are matched by prefix
+	{"-gsplit-dwarf", func(c *Compilation, _ string) (filterWhere, error) {
+		c.Flag.SplitDwarf = true
+		return 0, nil
+	}, false},
+	// We always keep temporaries beside the output remotely; see
+	// SecondaryOutputs
+	{"-save-temps=", func(c *Compilation, arg string) (filterWhere, error) {
+		if arg != "cwd" && arg != "obj" {
+			return 0, fmt.Errorf("-save-temps=%s: unsupported", arg)
+		}
+		c.SaveTemps = arg
+		return filterRemote, nil
+	}, true},
+	// Must follow -save-temps=, since specs are matched by prefix
+	{"-save-temps", func(c *Compilation, _ string) (filterWhere, error) {
+		c.SaveTemps = "cwd"
+		return filterRemote, nil
+	}, false},
+	// gfortran's module directory
+	{"-J", func(c *Compilation, arg string) (filterWhere, error) {
+		c.Fortran.ModDir = arg
+		return filterRemote, nil
+	}, true},
+	unsupportedModuleArg("-fmodule-mapper="),
+	unsupportedModuleArg("-fmodule-header"),
+	// These must precede --analyze, since specs are matched by
+	// prefix
+	{"--analyzer-output=", analyzerOutputArg, true},
+	{"--analyzer-output", analyzerOutputArg, true},
+	{"--analyze", analyzeArg, false},
+	{"-Xanalyzer", xanalyzerArg, true},
 }
 
 func replaceExt(file string, newExt string) string {
 	if newExt[0] != '.' {
 		panic("replaceExt: provided extension must start with `.`")
 	}
-	ext := path.Ext(file)
+	ext := filepath.Ext(file)
 	return file[:len(file)-len(ext)] + newExt
 }
 
@@ -264,17 +624,120 @@ func rewriteWp(args []string) []string {
 	return args
 }
 
+// splitResponseFile splits the contents of a response file into
+// arguments, following the quoting rules of GCC's `buildargv`:
+// arguments are separated by whitespace, and single quotes, double
+// quotes, and backslashes may be used to include whitespace.
+func splitResponseFile(data string) []string {
+	var out []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escape := false
+	for _, ch := range data {
+		switch {
+		case escape:
+			arg.WriteRune(ch)
+			escape = false
+		case ch == '\\':
+			escape = true
+			inArg = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inArg = true
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '\v':
+			if inArg {
+				out = append(out, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		out = append(out, arg.String())
+	}
+	return out
+}
+
+const maxResponseFileDepth = 16
+
+// expandResponseFiles replaces any `@file` arguments with the
+// arguments contained in `file`. As with GCC, `@file` is left as-is
+// if `file` can't be read.
+func expandResponseFiles(args []string) ([]string, error) {
+	return expandResponseFilesDepth(args, 0)
+}
+
+func expandResponseFilesDepth(args []string, depth int) ([]string, error) {
+	var out []string
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "@") || len(arg) == 1 {
+			if out != nil {
+				out = append(out, arg)
+			}
+			continue
+		}
+		data, err := ioutil.ReadFile(arg[1:])
+		if err != nil {
+			if out != nil {
+				out = append(out, arg)
+			}
+			continue
+		}
+		if depth >= maxResponseFileDepth {
+			return nil, fmt.Errorf("%s: response files nested too deeply", arg[1:])
+		}
+		expanded, err := expandResponseFilesDepth(splitResponseFile(string(data)), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = make([]string, 0, len(args)+len(expanded))
+			out = append(out, args[:i]...)
+		}
+		out = append(out, expanded...)
+	}
+	if out != nil {
+		return out, nil
+	}
+	return args, nil
+}
+
 func ParseCompile(cfg *Config, argv []string) (Compilation, error) {
 	var out Compilation
-	args := argv[1:]
+	args, err := expandResponseFiles(argv[1:])
+	if err != nil {
+		return out, err
+	}
 
 	args = rewriteWp(args)
 
+	var inputLang Lang
 	i := 0
 	for i < len(args) {
 		arg := args[i]
 		i++
-		if strings.HasPrefix(arg, "-") {
+		if arg == "-" {
+			// Like GCC, we can only tell what's on stdin
+			// from `-x`
+			if out.Language == "" {
+				return out, errors.New("-x is required when reading from stdin")
+			}
+			if out.Input != "" {
+				return out, fmt.Errorf("multiple inputs given: %s, %s", out.Input, arg)
+			}
+			out.Input = arg
+			inputLang = out.Language
+		} else if strings.HasPrefix(arg, "-") {
 			found := false
 			for _, spec := range argSpecs {
 				if !strings.HasPrefix(arg, spec.flag) {
@@ -315,11 +778,15 @@ func ParseCompile(cfg *Config, argv []string) (Compilation, error) {
 				out.LocalArgs = append(out.LocalArgs, arg)
 				out.RemoteArgs = append(out.RemoteArgs, arg)
 			}
-		} else if smellsLikeInput(arg) {
+		} else if smellsLikeInput(arg) || (out.Language != "" && isFile(arg)) {
+			// With an explicit `-x`, the input may have
+			// any extension at all
 			if out.Input != "" {
 				return out, fmt.Errorf("multiple inputs given: %s, %s", out.Input, arg)
 			}
 			out.Input = arg
+			// `-x` only applies to inputs which follow it
+			inputLang = out.Language
 		} else {
 			out.UnknownArgs = append(out.UnknownArgs, arg)
 			out.LocalArgs = append(out.LocalArgs, arg)
@@ -330,25 +797,70 @@ func ParseCompile(cfg *Config, argv []string) (Compilation, error) {
 	if out.Input == "" {
 		return out, errors.New("no supported input detected")
 	}
-	if !out.Flag.C {
+	out.Language = inputLang
+	if out.Language == "" {
+		lang, ok := extLangs[filepath.Ext(out.Input)]
+		if !ok {
+			return out, fmt.Errorf("Unsupported extension: %s", out.Input)
+		}
+		if cxx, ok := cxxLangs[lang]; ok && cfg.IsCxx(argv[0]) {
+			// The C++ driver compiles `.c` files as C++
+			lang = cxx
+		} else if lang == LangCHeader && isCxxStd(out.UnknownArgs) {
+			// A `.h` file is ambiguous, but a C++ -std=
+			// tells us which language the user means
+			lang = LangCxxHeader
+		}
+		out.Language = lang
+	}
+	out.Includes = append(out.Includes, cfg.EnvIncludes(out.Language)...)
+	cfg.defaultSysroot(&out)
+	// Precompiled headers, modules and analyzer reports are
+	// generated without -c
+	if !out.Flag.C && !out.IsPCH() && !out.Modules.Precompile && !out.Analyze.Enabled {
 		return out, errors.New("-c not detected")
 	}
 	if out.Output == "" {
-		out.Output = replaceExt(out.Input, ".o")
+		if out.Analyze.Enabled {
+			out.Output = replaceExt(out.Input, ".plist")
+			if ext := analyzeExts[out.Analyze.Format]; ext != "" {
+				out.Output = replaceExt(out.Input, ext)
+			}
+		} else if out.IsPCH() {
+			out.Output = out.Input + ".gch"
+		} else if out.Modules.Precompile {
+			out.Output = replaceExt(out.Input, ".pcm")
+		} else {
+			out.Output = replaceExt(out.Input, ".o")
+		}
+	}
+	if out.Modules.Output == "-" {
+		out.Modules.Output = replaceExt(out.Output, ".pcm")
+	}
+	if out.Language == LangAssembler {
+		// GCC doesn't run the preprocessor on plain assembly,
+		// and so silently ignores dependency options.
+		out.Flag.MD, out.Flag.MMD, out.Flag.MP = false, false, false
+		out.Flag.MF = ""
+		out.Flag.MT = nil
 	}
 	if (out.Flag.MD || out.Flag.MMD) && out.Flag.MF == "" {
 		out.Flag.MF = replaceExt(out.Output, ".d")
 		out.LocalArgs = append(out.LocalArgs, "-MF", out.Flag.MF)
 	}
-	if out.Language == "" {
-		lang, ok := extLangs[path.Ext(out.Input)]
-		if !ok {
-			return out, fmt.Errorf("Unsupported extension: %s", out.Input)
-		}
-		out.Language = lang
+	// The compiler names the target in the depfile after the
+	// output as it sees it, which is not what the user passed if
+	// we compile remotely or preprocess to stdout. Name it
+	// explicitly so that the depfile matches what the build
+	// system expects.
+	if out.Flag.MF != "" && len(out.Flag.MT) == 0 {
+		out.Flag.MT = []DepTarget{{"-MT", out.Output}}
+		out.LocalArgs = append(out.LocalArgs, "-MT", out.Output)
 	}
 	out.PreprocessedLanguage = preprocessedLang[out.Language]
-	if out.PreprocessedLanguage == "" {
+	// We never preprocess module units locally; see
+	// checkModulesSupported
+	if out.PreprocessedLanguage == "" && !out.IsPCH() && out.Language != LangCxxModule {
 		return out, fmt.Errorf("Don't know what happens when we preprocess %s", out.Language)
 	}
 
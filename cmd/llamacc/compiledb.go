@@ -0,0 +1,157 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// A compileCommand is an entry in a JSON compilation database, as
+// written by CMake, Ninja (`ninja -t compdb`) or Bear.
+type compileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Command   string   `json:"command,omitempty"`
+	Arguments []string `json:"arguments,omitempty"`
+	Output    string   `json:"output,omitempty"`
+}
+
+// splitCommand splits a command line into words, following the
+// quoting rules of a POSIX shell, which is what compilation databases
+// use in their "command" field.
+func splitCommand(cmd string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(cmd) {
+				i++
+				word.WriteByte(cmd[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(cmd[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inWord = true
+			for i++; ; i++ {
+				if i >= len(cmd) {
+					return nil, errors.New("unterminated double quote")
+				}
+				if cmd[i] == '"' {
+					break
+				}
+				if cmd[i] == '\\' && i+1 < len(cmd) && strings.IndexByte("\"\\$`", cmd[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(cmd[i])
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// prewarmUnits reads a compilation database and describes each
+// translation unit in it we can parse for the include server.
+func prewarmUnits(cfg *Config, data []byte) ([]daemon.ScanIncludesArgs, error) {
+	var db []compileCommand
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	var units []daemon.ScanIncludesArgs
+	for _, cmd := range db {
+		argv := cmd.Arguments
+		if len(argv) == 0 {
+			var err error
+			if argv, err = splitCommand(cmd.Command); err != nil || len(argv) == 0 {
+				continue
+			}
+		}
+		var comp Compilation
+		var err error
+		if cfg.IsCl(argv[0]) {
+			comp, err = ParseCompileCL(cfg, argv)
+		} else {
+			comp, err = ParseCompile(cfg, argv)
+		}
+		if err != nil || comp.Input == "" {
+			continue
+		}
+		var unit daemon.ScanIncludesArgs
+		unit.Quote, unit.Bracket = includeSearchPath(&comp, cmd.Directory)
+		unit.Inputs = append(unit.Inputs, toAbs(comp.Input, cmd.Directory))
+		for _, inc := range comp.Includes {
+			if inc.Opt == "-include" {
+				unit.Inputs = append(unit.Inputs, toAbs(inc.Path, cmd.Directory))
+			}
+		}
+		units = append(units, unit)
+	}
+	return units, nil
+}
+
+// prewarm has the daemon start uploading the headers of every
+// translation unit in the compilation database, if this is the first
+// compilation it has seen since the database changed.
+func prewarm(client *daemon.Client, cfg *Config) error {
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return err
+	}
+	args := daemon.PrewarmArgs{Database: toAbs(cfg.CompileDB, wd)}
+	reply, err := client.Prewarm(&args)
+	if err != nil || !reply.Wanted {
+		return err
+	}
+	data, err := ioutil.ReadFile(args.Database)
+	if err != nil {
+		return err
+	}
+	if args.Units, err = prewarmUnits(cfg, data); err != nil {
+		return fmt.Errorf("parsing %s: %w", args.Database, err)
+	}
+	if len(args.Units) == 0 {
+		return nil
+	}
+	_, err = client.Prewarm(&args)
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		in  string
+		out []string
+	}{
+		{"cc -c foo.c", []string{"cc", "-c", "foo.c"}},
+		{"  cc\t-c   foo.c ", []string{"cc", "-c", "foo.c"}},
+		{`cc -DNAME=\"llama\" -c foo.c`, []string{"cc", `-DNAME="llama"`, "-c", "foo.c"}},
+		{`cc '-DMSG=hello world' -c "dir with spaces/foo.c"`, []string{"cc", "-DMSG=hello world", "-c", "dir with spaces/foo.c"}},
+		{`cc -DQ="\"x\" \\ \n" -c a''b.c`, []string{"cc", `-DQ="x" \ \n`, "-c", "ab.c"}},
+		{`cc ""`, []string{"cc", ""}},
+	}
+	for _, tc := range cases {
+		got, err := splitCommand(tc.in)
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.out, got, tc.in)
+	}
+
+	_, err := splitCommand(`cc "-c foo.c`)
+	assert.Error(t, err)
+	_, err = splitCommand(`cc '-c foo.c`)
+	assert.Error(t, err)
+}
+
+func TestPrewarmUnits(t *testing.T) {
+	db := `[
+  {
+    "directory": "/src/build",
+    "command": "/usr/bin/cc -I../include -iquote gen -include config.h -o foo.o -c ../foo.c",
+    "file": "../foo.c"
+  },
+  {
+    "directory": "/src/build",
+    "arguments": ["c++", "-isystem", "/opt/inc", "-c", "/src/bar.cc", "-o", "bar.o"],
+    "file": "/src/bar.cc"
+  },
+  {
+    "directory": "/src/build",
+    "command": "cc -o prog foo.o bar.o",
+    "file": "prog"
+  }
+]`
+	units, err := prewarmUnits(&DefaultConfig, []byte(db))
+	require.NoError(t, err)
+	assert.Equal(t, []daemon.ScanIncludesArgs{
+		{
+			Inputs:  []string{"/src/foo.c", "/src/build/config.h"},
+			Quote:   []string{"/src/build/gen", "/src/include"},
+			Bracket: []string{"/src/include"},
+		},
+		{
+			Inputs:  []string{"/src/bar.cc"},
+			Quote:   []string{"/opt/inc"},
+			Bracket: []string{"/opt/inc"},
+		},
+	}, units)
+
+	_, err = prewarmUnits(&DefaultConfig, []byte("{"))
+	assert.Error(t, err)
+}
@@ -16,32 +16,177 @@ package main
 
 import (
 	"log"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
 )
 
 type Config struct {
 	Verbose         bool
+	Explain         bool
 	Local           bool
 	RemoteAssemble  bool
+	RemoteLink      bool
 	FullPreprocess  bool
 	Function        string
 	LocalPreprocess bool
+	ScanIncludes    bool
+	NoDepsCache     bool
+	NoStage         bool
 	BuildID         string
+	Fallback        bool
+	Cache           bool
+	Stream          bool
+	Reproducible    bool
+	// Transfer large inputs and outputs between llamacc and the
+	// object store directly, instead of through the daemon
+	Direct bool
+	// Leave object files and archives in the object store, writing
+	// reference files locally in their place
+	RemoteObjects bool
+	// The fraction of remote compilations to repeat locally, to
+	// check that they produce identical output
+	Verify float64
+	// A compilation database (compile_commands.json) listing the
+	// build's translation units, whose headers the daemon uploads
+	// ahead of time
+	CompileDB string
+
+	// What to do with dependencies in directories outside the
+	// project
+	PathMaps []PathMap
+
+	// Minimum function memory (in MB) and timeout
+	Memory  int64
+	Timeout time.Duration
+
+	// Race compilations of sources no larger than this many
+	// bytes locally against the remote compilation
+	Race int64
 
 	LocalCC  string
 	LocalCXX string
+
+	RemoteCC  string
+	RemoteCXX string
+	Target    string
+
+	// The compilers to use for arguments in the dialect of MSVC's
+	// cl.exe
+	LocalCL  string
+	RemoteCL string
+
+	// The archivers to use when invoked as ar or ranlib
+	LocalAR      string
+	RemoteAR     string
+	LocalRanlib  string
+	RemoteRanlib string
+
+	// The CUDA compiler drivers to use when invoked as nvcc
+	LocalNVCC  string
+	RemoteNVCC string
+	// Compile CUDA sources entirely on Lambda, which needs the
+	// CUDA toolkit in the function's image, rather than only
+	// their host code
+	RemoteCUDA bool
+
+	// The Fortran compilers
+	LocalFC  string
+	RemoteFC string
+
+	// "cc", "c++", "cl", "ar", "ranlib", "nvcc" or "fortran"; if empty, we pick based on the name we were
+	// invoked as
+	Driver string
+
+	// The include paths the compiler reads from the environment,
+	// which we need to know for dependency detection and to pass
+	// on to the remote compiler.
+	CPath             string
+	CIncludePath      string
+	CPlusIncludePath  string
+	ObjCIncludePath   string
+	ObjCxxIncludePath string
+	// The system include path for cl.exe and clang-cl
+	MSVCIncludePath string
+	// Passed through to the remote compiler, to fix the time it
+	// records in its output
+	SourceDateEpoch string
+	// Set by make; tells us how to reach its jobserver
+	MakeFlags string
+
+	// If non-zero, give our make jobserver token back while we
+	// compile remotely, for up to this many compilations at once
+	// across all llamacc processes
+	Jobserver int
+
+	// What to do if the local compiler differs from the one
+	// recorded on the function: "warn", "error", or "off"
+	ToolchainCheck string
+
+	// If set, overrides the daemon's result-cache namespaces
+	CacheNamespaces *daemon.CacheConfig
+
+	// A sysroot to compile and link against, unless the command
+	// line names one, which we upload as a tree
+	Sysroot string
 }
 
 var DefaultConfig = Config{
-	Function: "gcc",
-	LocalCC:  "cc",
-	LocalCXX: "c++",
+	Function:  "gcc",
+	LocalCC:   "cc",
+	LocalCXX:  "c++",
+	RemoteCC:  "cc",
+	RemoteCXX: "c++",
+	LocalCL:   "clang-cl",
+	RemoteCL:  "clang-cl",
+
+	LocalAR:      "ar",
+	RemoteAR:     "ar",
+	LocalRanlib:  "ranlib",
+	RemoteRanlib: "ranlib",
+
+	LocalNVCC:  "nvcc",
+	RemoteNVCC: "nvcc",
+
+	LocalFC:  "gfortran",
+	RemoteFC: "gfortran",
+
+	ToolchainCheck: "warn",
+}
+
+// projectEnv returns the settings from the project's .llamarc, if
+// any, as environment variables, for the real environment to
+// override.
+func projectEnv() []string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	proj, err := cli.FindProjectConfig(wd)
+	if err != nil {
+		log.Printf("llamacc: %s", err.Error())
+		return nil
+	}
+	if proj == nil {
+		return nil
+	}
+	env, err := proj.LlamaccEnv()
+	if err != nil {
+		log.Printf("llamacc: %s", err.Error())
+	}
+	return env
 }
 
 func ParseConfig(env []string) Config {
 	out := DefaultConfig
 	for _, ev := range env {
 		if !strings.HasPrefix(ev, "LLAMACC_") {
+			parseCompilerEnv(&out, ev)
 			continue
 		}
 		var eq = strings.IndexRune(ev, '=')
@@ -53,25 +198,333 @@ func ParseConfig(env []string) Config {
 		switch key {
 		case "VERBOSE":
 			out.Verbose = val != ""
+		case "EXPLAIN":
+			out.Explain = val != ""
 		case "LOCAL":
 			out.Local = val != ""
 		case "REMOTE_ASSEMBLE":
 			out.RemoteAssemble = val != ""
+		case "REMOTE_LINK":
+			out.RemoteLink = val != ""
 		case "FUNCTION":
 			out.Function = val
 		case "FULL_PREPROCESS":
 			out.FullPreprocess = val != ""
 		case "LOCAL_PREPROCESS":
 			out.LocalPreprocess = val != ""
+		case "SCAN_INCLUDES":
+			out.ScanIncludes = val != ""
+		case "NO_DEPS_CACHE":
+			out.NoDepsCache = val != ""
+		case "NO_STAGE":
+			out.NoStage = val != ""
 		case "BUILD_ID":
 			out.BuildID = val
+		case "FALLBACK":
+			out.Fallback = val != ""
+		case "CACHE":
+			out.Cache = val != ""
+		case "CACHE_NAMESPACE":
+			if err := daemon.ValidateNamespace(val); err != nil {
+				log.Printf("llamacc: bad %s: %s", ev, err.Error())
+			} else {
+				out.cacheNamespaces().Namespace = val
+			}
+		case "CACHE_SHARED":
+			shared := []string{}
+			for _, ns := range strings.Split(val, ",") {
+				if ns == "" {
+					continue
+				}
+				if err := daemon.ValidateNamespace(ns); err != nil {
+					log.Printf("llamacc: bad %s: %s", ev, err.Error())
+					continue
+				}
+				shared = append(shared, ns)
+			}
+			out.cacheNamespaces().Shared = shared
+		case "CACHE_POLICY":
+			policy, err := daemon.ParseCachePolicy(val)
+			if err != nil {
+				log.Printf("llamacc: bad %s: expected read-write, read-only, write-only, or bypass", ev)
+			} else {
+				out.cacheNamespaces().Policy = policy
+			}
+		case "STREAM":
+			out.Stream = val != ""
+		case "DIRECT":
+			out.Direct = val != ""
+		case "REMOTE_OBJECTS":
+			out.RemoteObjects = val != ""
+		case "COMPILE_DB":
+			out.CompileDB = val
+		case "REPRODUCIBLE":
+			out.Reproducible = val != ""
+		case "VERIFY":
+			frac, err := strconv.ParseFloat(val, 64)
+			if err != nil || frac < 0 || frac > 1 {
+				log.Printf("llamacc: bad %s: expected a fraction between 0 and 1", ev)
+			} else {
+				out.Verify = frac
+			}
+		case "PATH_MAP":
+			maps, err := parsePathMaps(val)
+			if err != nil {
+				log.Printf("llamacc: bad LLAMACC_PATH_MAP: %s", err.Error())
+			} else {
+				out.PathMaps = maps
+			}
 		case "LOCAL_CC":
 			out.LocalCC = val
 		case "LOCAL_CXX":
 			out.LocalCXX = val
+		case "REMOTE_CC":
+			out.RemoteCC = val
+		case "REMOTE_CXX":
+			out.RemoteCXX = val
+		case "LOCAL_CL":
+			out.LocalCL = val
+		case "REMOTE_CL":
+			out.RemoteCL = val
+		case "LOCAL_AR":
+			out.LocalAR = val
+		case "REMOTE_AR":
+			out.RemoteAR = val
+		case "LOCAL_RANLIB":
+			out.LocalRanlib = val
+		case "REMOTE_RANLIB":
+			out.RemoteRanlib = val
+		case "LOCAL_NVCC":
+			out.LocalNVCC = val
+		case "REMOTE_NVCC":
+			out.RemoteNVCC = val
+		case "LOCAL_FC":
+			out.LocalFC = val
+		case "REMOTE_FC":
+			out.RemoteFC = val
+		case "REMOTE_CUDA":
+			out.RemoteCUDA = val != ""
+		case "TARGET":
+			out.Target = val
+		case "SYSROOT":
+			if val == "" {
+				out.Sysroot = ""
+			} else if !filepath.IsAbs(val) {
+				log.Printf("llamacc: bad %s: expected an absolute path", ev)
+			} else {
+				out.Sysroot = filepath.Clean(val)
+			}
+		case "DRIVER":
+			switch val {
+			case "cc", "c", "gcc":
+				out.Driver = "cc"
+			case "c++", "cxx", "g++":
+				out.Driver = "c++"
+			case "cl", "clang-cl":
+				out.Driver = "cl"
+			case "fortran", "gfortran":
+				out.Driver = "fortran"
+			case "ar", "ranlib", "nvcc":
+				out.Driver = val
+			default:
+				log.Printf("llamacc: bad %s: expected cc, c++, cl, ar, ranlib, nvcc, or fortran", ev)
+			}
+		case "MEMORY":
+			mem, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				log.Printf("llamacc: bad %s: %s", ev, err.Error())
+			}
+			out.Memory = mem
+		case "RACE":
+			size, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				log.Printf("llamacc: bad %s: %s", ev, err.Error())
+			}
+			out.Race = size
+		case "JOBSERVER":
+			slots, err := strconv.Atoi(val)
+			if err != nil {
+				log.Printf("llamacc: bad %s: %s", ev, err.Error())
+			}
+			out.Jobserver = slots
+		case "TOOLCHAIN_CHECK":
+			switch val {
+			case "warn", "error", "off":
+				out.ToolchainCheck = val
+			default:
+				log.Printf("llamacc: bad %s: expected warn, error, or off", ev)
+			}
+		case "TIMEOUT":
+			timeout, err := time.ParseDuration(val)
+			if err != nil {
+				log.Printf("llamacc: bad %s: %s", ev, err.Error())
+			}
+			out.Timeout = timeout
 		default:
 			log.Printf("llamacc: unknown env var: %s", ev)
 		}
 	}
 	return out
 }
+
+func (c *Config) cacheNamespaces() *daemon.CacheConfig {
+	if c.CacheNamespaces == nil {
+		c.CacheNamespaces = &daemon.CacheConfig{}
+	}
+	return c.CacheNamespaces
+}
+
+// parseCompilerEnv records the environment variables the compiler
+// itself reads that we need to know about.
+func parseCompilerEnv(cfg *Config, ev string) {
+	eq := strings.IndexRune(ev, '=')
+	if eq < 0 {
+		return
+	}
+	val := ev[eq+1:]
+	switch ev[:eq] {
+	case "CPATH":
+		cfg.CPath = val
+	case "C_INCLUDE_PATH":
+		cfg.CIncludePath = val
+	case "CPLUS_INCLUDE_PATH":
+		cfg.CPlusIncludePath = val
+	case "OBJC_INCLUDE_PATH":
+		cfg.ObjCIncludePath = val
+	case "OBJCPLUS_INCLUDE_PATH":
+		cfg.ObjCxxIncludePath = val
+	case "INCLUDE":
+		cfg.MSVCIncludePath = val
+	case "SOURCE_DATE_EPOCH":
+		cfg.SourceDateEpoch = val
+	case "MAKEFLAGS":
+		cfg.MakeFlags = val
+	}
+}
+
+// IsCxx returns true if we should behave as the C++ compiler driver
+// when invoked as `argv0`. We recognize names like `llamac++`,
+// `llamacxx`, `llamac++-10`, and `llamac++.exe`.
+func (cfg *Config) IsCxx(argv0 string) bool {
+	if cfg.Driver != "" {
+		return cfg.Driver == "c++"
+	}
+	name := driverName(argv0)
+	return strings.HasSuffix(name, "++") || strings.HasSuffix(name, "cxx")
+}
+
+// driverName returns the name we were invoked as, without any
+// directory, `.exe` extension, or version suffix.
+func driverName(argv0 string) string {
+	name := filepath.Base(argv0)
+	if ext := filepath.Ext(name); strings.EqualFold(ext, ".exe") {
+		name = name[:len(name)-len(ext)]
+	}
+	name = strings.TrimRight(name, "0123456789.")
+	return strings.TrimSuffix(name, "-")
+}
+
+// IsCl returns true if we should parse arguments in the dialect of
+// MSVC's cl.exe when invoked as `argv0`. We recognize names like
+// `llamacl`, `llamacc-cl`, and `llamacl.exe`.
+func (cfg *Config) IsCl(argv0 string) bool {
+	if cfg.Driver != "" {
+		return cfg.Driver == "cl"
+	}
+	return strings.HasSuffix(driverName(argv0), "cl")
+}
+
+// IsNVCC returns true if we should behave as nvcc when invoked as
+// `argv0`. We recognize names like `llamanvcc` and `llama-nvcc`.
+func (cfg *Config) IsNVCC(argv0 string) bool {
+	if cfg.Driver != "" {
+		return cfg.Driver == "nvcc"
+	}
+	return strings.HasSuffix(driverName(argv0), "nvcc")
+}
+
+// IsFortran returns true if we should behave as gfortran when
+// invoked as `argv0`. We recognize names like `llamafort` and
+// `llama-gfortran`.
+func (cfg *Config) IsFortran(argv0 string) bool {
+	if cfg.Driver != "" {
+		return cfg.Driver == "fortran"
+	}
+	name := driverName(argv0)
+	return strings.HasSuffix(name, "fort") || strings.HasSuffix(name, "fortran")
+}
+
+// Archiver returns "ar" or "ranlib" if we should behave as that tool
+// when invoked as `argv0`, and "" otherwise. We recognize names like
+// `llama-ar`, `llamaranlib`, and `llama-ar.exe`.
+func (cfg *Config) Archiver(argv0 string) string {
+	if cfg.Driver != "" {
+		if cfg.Driver == "ar" || cfg.Driver == "ranlib" {
+			return cfg.Driver
+		}
+		return ""
+	}
+	name := driverName(argv0)
+	switch {
+	case strings.HasSuffix(name, "ranlib"):
+		return "ranlib"
+	case strings.HasSuffix(name, "ar"):
+		return "ar"
+	}
+	return ""
+}
+
+// splitIncludePath splits a search path from the environment, which
+// is separated by `;` on Windows. As with GCC, an empty element means
+// the current directory.
+func splitIncludePath(val string) []string {
+	if val == "" {
+		return nil
+	}
+	dirs := strings.Split(val, string(filepath.ListSeparator))
+	for i, dir := range dirs {
+		if dir == "" {
+			dirs[i] = "."
+		}
+	}
+	return dirs
+}
+
+// EnvIncludes returns the include options equivalent to the search
+// path the compiler reads from the environment when compiling `lang`.
+// CPATH is searched as if by `-I`, after any directories from the
+// command line, and the language-specific variables as if by
+// `-isystem`.
+func (cfg *Config) EnvIncludes(lang Lang) []Include {
+	var out []Include
+	for _, dir := range splitIncludePath(cfg.CPath) {
+		out = append(out, Include{"-I", dir})
+	}
+	var sys string
+	switch lang {
+	case LangC, LangCHeader:
+		sys = cfg.CIncludePath
+	case LangCxx, LangCxxHeader:
+		sys = cfg.CPlusIncludePath
+	case LangObjC, LangObjCHeader:
+		sys = cfg.ObjCIncludePath
+	case LangObjCxx, LangObjCxxHeader:
+		sys = cfg.ObjCxxIncludePath
+	}
+	for _, dir := range splitIncludePath(sys) {
+		out = append(out, Include{"-isystem", dir})
+	}
+	return out
+}
+
+// MSVCIncludes returns the include options equivalent to the system
+// include path clang-cl reads from the INCLUDE environment variable.
+// The remote compiler has no such headers of its own, so we upload
+// them and pass it their directories explicitly.
+func (cfg *Config) MSVCIncludes() []Include {
+	var out []Include
+	for _, dir := range splitIncludePath(cfg.MSVCIncludePath) {
+		out = append(out, Include{"-imsvc", dir})
+	}
+	return out
+}
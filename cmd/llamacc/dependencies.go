@@ -20,9 +20,12 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
 	"github.com/nelhage/llama/tracing"
 )
 
@@ -30,11 +33,83 @@ func detectDependencies(ctx context.Context, client *daemon.Client, cfg *Config,
 	_, span := tracing.StartSpan(ctx, "detect_dependencies")
 	defer span.End()
 
-	var preprocessor exec.Cmd
+	if comp.Language == LangAssembler {
+		// Plain assembly isn't preprocessed, so it has no
+		// headers. We don't follow `.include` directives.
+		return nil, nil
+	}
+
+	if comp.Language.IsFortran() {
+		// We already scanned the source; see
+		// checkFortranSupported
+		deplist := append(comp.Fortran.Includes, fortranModuleDeps(comp)...)
+		span.AddField("count", len(deplist))
+		return deplist, nil
+	}
+
 	ccpath, err := exec.LookPath(comp.LocalCompiler(cfg))
 	if err != nil {
 		return nil, err
 	}
+
+	if comp.MSVC {
+		// The remote compiler has no system headers, so we
+		// upload everything.
+		deplist, err := runShowIncludes(cfg, ccpath, comp)
+		if err != nil {
+			return nil, err
+		}
+		span.AddField("count", len(deplist))
+		return deplist, nil
+	}
+
+	// Ask for the compiler's own include path while we find the
+	// headers
+	includePath := make(chan includePathResult, 1)
+	go func() {
+		reply, err := client.GetCompilerIncludePath(&daemon.GetCompilerIncludePathArgs{
+			Compiler: ccpath,
+			Language: comp.driverLanguage(),
+		})
+		includePath <- includePathResult{reply, err}
+	}()
+
+	var deplist []string
+	if cfg.ScanIncludes && !comp.ReadsStdin() {
+		deplist, err = scanIncludes(client, comp)
+		if err != nil && cfg.Verbose {
+			log.Printf("[llamacc] scanning includes: %s; falling back to cpp -M", err.Error())
+		}
+		span.AddField("scanned", err == nil)
+	}
+	if deplist == nil {
+		deplist, err = runMakeDeps(client, cfg, ccpath, comp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	system := <-includePath
+	if system.err != nil {
+		return nil, system.err
+	}
+
+	deplist = removePaths(deplist, system.reply.Paths)
+
+	span.AddField("count", len(deplist))
+	return deplist, nil
+}
+
+type includePathResult struct {
+	reply *daemon.GetCompilerIncludePathReply
+	err   error
+}
+
+// runMakeDeps asks the preprocessor for the headers `comp` includes,
+// unless the daemon's dependency cache already knows them from an
+// identical compilation.
+func runMakeDeps(client *daemon.Client, cfg *Config, ccpath string, comp *Compilation) ([]string, error) {
+	var preprocessor exec.Cmd
 	preprocessor.Path = ccpath
 	preprocessor.Args = []string{comp.LocalCompiler(cfg)}
 	preprocessor.Args = append(preprocessor.Args, comp.UnknownArgs...)
@@ -46,32 +121,84 @@ func detectDependencies(ctx context.Context, client *daemon.Client, cfg *Config,
 		preprocessor.Args = append(preprocessor.Args, opt.Opt)
 		preprocessor.Args = append(preprocessor.Args, opt.Path)
 	}
-	preprocessor.Args = append(preprocessor.Args, "-M", "-MF", "-", comp.Input)
+	if comp.Sysroot != "" {
+		preprocessor.Args = append(preprocessor.Args, "--sysroot="+comp.Sysroot)
+	}
+	preprocessor.Args = append(preprocessor.Args, "-M", "-MF", "-", "-x", comp.driverLanguage(), comp.Input)
+	if comp.ReadsStdin() {
+		preprocessor.Stdin = bytes.NewReader(comp.Stdin)
+	}
 	var deps bytes.Buffer
 	preprocessor.Stdout = &deps
 	preprocessor.Stderr = os.Stderr
+
+	var key *daemon.DepsKey
+	if client != nil && !cfg.NoDepsCache {
+		var err error
+		if key, err = depsKey(cfg, ccpath, comp, preprocessor.Args); err != nil {
+			return nil, err
+		}
+		reply, err := client.LookupDeps(&daemon.LookupDepsArgs{Key: *key, Stage: cfg.stages()})
+		if err == nil && reply.Found {
+			return reply.Deps, nil
+		}
+		if err != nil && cfg.Verbose {
+			log.Printf("[llamacc] looking up dependencies: %s", err.Error())
+		}
+	}
+
 	if cfg.Verbose {
 		log.Printf("run cpp -MM: %q", preprocessor.Args)
 	}
-	span.AddField("argc", len(preprocessor.Args))
 	if err := preprocessor.Run(); err != nil {
 		return nil, err
 	}
 
-	includePath, err := client.GetCompilerIncludePath(&daemon.GetCompilerIncludePathArgs{
-		Compiler: ccpath,
-		Language: string(comp.Language),
-	})
+	deplist, err := parseMakeDeps(deps.Bytes())
 	if err != nil {
 		return nil, err
 	}
+	if key != nil {
+		_, err := client.RecordDeps(&daemon.RecordDepsArgs{Key: *key, Deps: deplist})
+		if err != nil && cfg.Verbose {
+			log.Printf("[llamacc] recording dependencies: %s", err.Error())
+		}
+	}
+	return deplist, nil
+}
 
-	deplist, err := parseMakeDeps(deps.Bytes())
-
-	deplist = removePaths(deplist, includePath.Paths)
-
-	span.AddField("count", len(deplist))
-	return deplist, err
+// depsKey describes everything that determines the preprocessor's
+// output for `comp`, for the daemon's dependency cache.
+func depsKey(cfg *Config, ccpath string, comp *Compilation, args []string) (*daemon.DepsKey, error) {
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return nil, err
+	}
+	key := daemon.DepsKey{
+		Compiler: toAbs(ccpath, wd),
+		Args:     args,
+		Dir:      wd,
+		Env: []string{
+			"CPATH=" + cfg.CPath,
+			"C_INCLUDE_PATH=" + cfg.CIncludePath,
+			"CPLUS_INCLUDE_PATH=" + cfg.CPlusIncludePath,
+			"OBJC_INCLUDE_PATH=" + cfg.ObjCIncludePath,
+			"OBJCPLUS_INCLUDE_PATH=" + cfg.ObjCxxIncludePath,
+		},
+	}
+	if comp.ReadsStdin() {
+		key.Input = "-"
+		key.Stdin = comp.Stdin
+	} else {
+		key.Input = toAbs(comp.Input, wd)
+		key.Search = append(key.Search, filepath.Dir(key.Input))
+	}
+	quote, _ := includeSearchPath(comp, wd)
+	key.Search = append(key.Search, quote...)
+	for _, inc := range cfg.EnvIncludes(comp.Language) {
+		key.Search = append(key.Search, toAbs(inc.Path, wd))
+	}
+	return &key, nil
 }
 
 func removePaths(paths []string, remove []string) []string {
@@ -79,7 +206,7 @@ func removePaths(paths []string, remove []string) []string {
 outer:
 	for in := 0; in != len(paths); in++ {
 		for _, pfx := range remove {
-			if strings.HasPrefix(paths[in], pfx) {
+			if hasPathPrefix(paths[in], pfx) {
 				continue outer
 			}
 		}
@@ -89,27 +216,46 @@ outer:
 	return paths[:out]
 }
 
+// hasPathPrefix returns true if `p` starts with `pfx`. On Windows,
+// paths are compared case-insensitively, and either slash will do.
+func hasPathPrefix(p, pfx string) bool {
+	if runtime.GOOS == "windows" {
+		p = strings.ToLower(filepath.ToSlash(p))
+		pfx = strings.ToLower(filepath.ToSlash(pfx))
+	}
+	return strings.HasPrefix(p, pfx)
+}
+
+func isMakeSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
 func parseMakeDeps(buf []byte) ([]string, error) {
 	var deps []string
 	i := 0
-	// Skip the target
-	for i < len(buf) && buf[i] != ':' {
+	// Skip the target. Windows paths contain colons after the
+	// drive letter, so only a colon followed by whitespace ends
+	// it.
+	for i < len(buf) && !(buf[i] == ':' && (i+1 == len(buf) || isMakeSpace(buf[i+1]))) {
 		i++
 	}
 	i++
 
 	var dep []byte
+	emit := func() {
+		if len(dep) > 0 {
+			deps = append(deps, hostPath(string(dep)))
+		}
+		dep = dep[:0]
+	}
 	for i < len(buf) {
-		if buf[i] == ' ' || buf[i] == '\n' {
-			if len(dep) > 0 {
-				deps = append(deps, string(dep))
-			}
-			dep = dep[:0]
+		if isMakeSpace(buf[i]) {
+			emit()
 			i++
 			continue
 		}
 		if buf[i] == '\\' && i+1 < len(buf) {
-			if buf[i+1] == '\n' {
+			if buf[i+1] == '\n' || buf[i+1] == '\r' {
 				i++
 				continue
 			}
@@ -122,9 +268,17 @@ func parseMakeDeps(buf []byte) ([]string, error) {
 		dep = append(dep, buf[i])
 		i++
 	}
-	if len(dep) > 0 {
-		deps = append(deps, string(dep))
-	}
+	emit()
 
 	return deps, nil
 }
+
+// hostPath converts a path from a compiler's dependency output to
+// one we can open. On Windows, MSYS2 and Cygwin compilers report
+// paths like `/c/src/foo.h`.
+func hostPath(p string) string {
+	if runtime.GOOS == "windows" {
+		return files.FromMSYS(p)
+	}
+	return p
+}
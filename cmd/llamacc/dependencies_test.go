@@ -42,6 +42,14 @@ func TestParseMakeDeps(t *testing.T) {
 			`foo.o: a\b.c foo\\bar.h`,
 			[]string{"a\\b.c", "foo\\bar.h"},
 		},
+		{
+			"C:/src/foo.o: C:/src/foo.c \\\r\n C:/src/foo.h\r\n",
+			[]string{"C:/src/foo.c", "C:/src/foo.h"},
+		},
+		{
+			"foo.o:\tfoo.c\tfoo.h",
+			[]string{"foo.c", "foo.h"},
+		},
 	}
 	for _, tc := range cases {
 		got, err := parseMakeDeps([]byte(tc.Src))
@@ -51,3 +59,24 @@ func TestParseMakeDeps(t *testing.T) {
 		assert.Equal(t, tc.Deps, got)
 	}
 }
+
+func TestLocalizeDeps(t *testing.T) {
+	deps := []byte("_root/c/src/foo.o: _root/c/src/foo.c _root/d/inc/foo.h\n")
+	assert.Equal(t, "/c/src/foo.o: /c/src/foo.c /d/inc/foo.h\n", string(localizeDeps(deps, false)))
+	assert.Equal(t, "C:/src/foo.o: C:/src/foo.c D:/inc/foo.h\n", string(localizeDeps(deps, true)))
+}
+
+func TestIncludeSearchPath(t *testing.T) {
+	comp := Compilation{
+		Includes: []Include{
+			{"-I", "inc"},
+			{"-idirafter", "/after"},
+			{"-iquote", "quoted"},
+			{"-include", "config.h"},
+			{"-isystem", "/sys"},
+		},
+	}
+	quote, bracket := includeSearchPath(&comp, "/src")
+	assert.Equal(t, []string{"/src/quoted", "/src/inc", "/sys", "/after"}, quote)
+	assert.Equal(t, []string{"/src/inc", "/sys", "/after"}, bracket)
+}
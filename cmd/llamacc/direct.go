@@ -0,0 +1,60 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+)
+
+// setStdin passes `data` as the remote command's standard input. With
+// LLAMACC_DIRECT, we store it ourselves if it's large enough to go in
+// the object store at all, and fall back to passing it through the
+// daemon if we can't.
+func setStdin(ctx context.Context, client *daemon.Client, cfg *Config, args *daemon.InvokeWithFilesArgs, data []byte) {
+	if cfg.Direct {
+		blob, err := files.NewBlob(ctx, client.NewDirectStore(), data)
+		if err == nil {
+			args.StdinBlob = blob
+			return
+		}
+		if cfg.Verbose {
+			log.Printf("[llamacc] storing input directly: %s", err.Error())
+		}
+	}
+	args.Stdin = data
+}
+
+// fetchDirect fetches the outputs the daemon left for us, straight
+// from the object store, and writes them out.
+func fetchDirect(client *daemon.Client, outputs protocol.FileList) error {
+	var gets []store.GetRequest
+	for _, f := range outputs {
+		gets = files.AppendGet(gets, &f.Blob)
+	}
+	client.NewDirectStore().GetObjects(context.Background(), gets)
+	for _, f := range outputs {
+		var err error
+		if err, gets = files.FetchFile(&f.File, f.Path, gets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
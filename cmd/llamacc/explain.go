@@ -0,0 +1,137 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/server"
+)
+
+// explain describes what llamacc would do with argv, without doing
+// it, for LLAMACC_EXPLAIN. `mode` names the kind of command we took
+// argv for, `comp` is its parse if it was a compilation, and `local`
+// is why we would run it locally, or nil if we'd run it remotely.
+func explain(w io.Writer, cfg *Config, argv []string, mode string, comp *Compilation, local error) error {
+	fmt.Fprintf(w, "command: %q\n", argv)
+	fmt.Fprintf(w, "mode: %s\n", mode)
+	if comp != nil {
+		explainCompilation(w, cfg, comp)
+	}
+	if local != nil {
+		fmt.Fprintf(w, "run: local (%s)\n", local.Error())
+		fmt.Fprintf(w, "local command: %q\n", append([]string{localCompiler(cfg, argv[0])}, argv[1:]...))
+		return nil
+	}
+	fmt.Fprintf(w, "run: remote, function %s\n", cfg.Function)
+	if comp == nil || cfg.LocalPreprocess {
+		return nil
+	}
+
+	ctx := context.Background()
+	client, err := server.DialWithAutostart(ctx, cli.SocketPath(), server.LlamaCCPath)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	construct := constructRemotePreprocessInvoke
+	if comp.MSVC {
+		construct = constructRemoteCLInvoke
+	}
+	args, err := construct(ctx, client, cfg, comp)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "remote command: %q\n", args.Args)
+	for _, out := range args.Outputs {
+		fmt.Fprintf(w, "output: %s\n", out.Local.Path)
+	}
+	return explainUploads(w, client, args)
+}
+
+func explainCompilation(w io.Writer, cfg *Config, comp *Compilation) {
+	fmt.Fprintf(w, "language: %s\n", comp.Language)
+	input, output := comp.Input, comp.Output
+	if comp.ReadsStdin() {
+		// We don't consume stdin just to explain
+		input = "stdin (dependencies not scanned)"
+	}
+	if comp.WritesStdout() {
+		output = "stdout"
+	}
+	fmt.Fprintf(w, "input: %s\n", input)
+	fmt.Fprintf(w, "output: %s\n", output)
+	if comp.MSVC {
+		fmt.Fprintf(w, "dialect: cl.exe\n")
+	}
+	if comp.Sysroot != "" {
+		fmt.Fprintf(w, "sysroot: %s\n", comp.Sysroot)
+	}
+	if comp.Analyze.Enabled {
+		format := comp.Analyze.Format
+		if format == "" {
+			format = "plist"
+		}
+		fmt.Fprintf(w, "analyze: %s\n", format)
+	}
+	if cfg.LocalPreprocess {
+		fmt.Fprintf(w, "preprocess: local\n")
+	} else {
+		fmt.Fprintf(w, "preprocess: remote\n")
+	}
+	if len(comp.UnknownArgs) > 0 {
+		fmt.Fprintf(w, "passed through: %s\n", strings.Join(comp.UnknownArgs, " "))
+	}
+}
+
+// explainUploads lists the files a remote compilation would send,
+// and whether the object store already holds each.
+func explainUploads(w io.Writer, client *daemon.Client, args *daemon.InvokeWithFilesArgs) error {
+	var paths []string
+	for _, f := range args.Files {
+		if f.Local.Path != "" {
+			paths = append(paths, f.Local.Path)
+		}
+	}
+	reply, err := client.CheckUploads(&daemon.CheckUploadsArgs{Paths: paths})
+	if err != nil {
+		return err
+	}
+	var total int64
+	var uploads int
+	for _, f := range reply.Files {
+		var status string
+		switch {
+		case f.Err != "":
+			status = "error: " + f.Err
+		case f.Inline:
+			status = "inline"
+		case f.Upload > 0:
+			status = fmt.Sprintf("upload %d bytes", f.Upload)
+			total += f.Upload
+			uploads++
+		default:
+			status = "cached"
+		}
+		fmt.Fprintf(w, "file: %s (%s)\n", f.Path, status)
+	}
+	fmt.Fprintf(w, "uploads: %d of %d files, %d bytes\n", uploads, len(reply.Files), total)
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainLocal(t *testing.T) {
+	cfg := ParseConfig([]string{"LLAMACC_EXPLAIN=1", "LLAMACC_LOCAL_CXX=g++-10"})
+	argv := []string{"c++", "-c", "-O2", "-fsomething", "foo.cc", "-o", "foo.o"}
+	comp, err := ParseCompile(&cfg, argv)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, explain(&buf, &cfg, argv, "compile", &comp, errors.New("LLAMACC_LOCAL set")))
+	out := buf.String()
+	assert.Contains(t, out, "mode: compile\n")
+	assert.Contains(t, out, "language: c++\n")
+	assert.Contains(t, out, "input: foo.cc\n")
+	assert.Contains(t, out, "output: foo.o\n")
+	assert.Contains(t, out, "preprocess: remote\n")
+	assert.Contains(t, out, "passed through: -O2 -fsomething\n")
+	assert.Contains(t, out, "run: local (LLAMACC_LOCAL set)\n")
+	assert.Contains(t, out, `local command: ["g++-10" "-c" "-O2"`)
+}
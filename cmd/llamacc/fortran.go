@@ -0,0 +1,256 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// Fortran
+//
+// gfortran compiles Fortran the same way GCC compiles C, except
+// that Fortran modules couple translation units: compiling a source
+// which defines module M writes `m.mod` (and, for modules with
+// submodules, `m.smod`), which every source that says `use m` then
+// reads. The preprocessor knows nothing about modules, so instead of
+// running `-M`, we scan sources ourselves for the modules they
+// define and use, and the files they `include`; like the include
+// scanner, we look at the source as written, so we don't notice
+// conditional compilation and may upload a little too much.
+//
+// gfortran writes module files to the directory named by `-J`, or
+// its working directory, and looks for them there, in its working
+// directory, and along the `-I` path.
+
+// FortranFlags records a Fortran compilation's module directory,
+// and what we found scanning its source
+type FortranFlags struct {
+	// The directory given with -J, if any
+	ModDir string
+	// The module files the source writes: `m.mod`, `m.smod` and
+	// `m@sub.smod`
+	Writes []string
+	// The module files it reads
+	Reads []string
+	// The files it includes, with `include` or `#include`
+	Includes []string
+}
+
+// IsFortran returns true if `l` is a dialect of Fortran, which we
+// compile with gfortran.
+func (l Lang) IsFortran() bool {
+	switch l {
+	case LangFortran77, LangFortran77Cpp, LangFortran, LangFortranCpp:
+		return true
+	}
+	return false
+}
+
+var (
+	fortranModule    = regexp.MustCompile(`(?im)^[ \t]*module[ \t]+(\w+)[ \t]*(?:!.*)?$`)
+	fortranSubmodule = regexp.MustCompile(`(?im)^[ \t]*submodule[ \t]*\([ \t]*(\w+)[ \t]*(?::[ \t]*(\w+)[ \t]*)?\)[ \t]*(\w+)`)
+	fortranUse       = regexp.MustCompile(`(?im)^[ \t]*use\b(?:[ \t]*,[ \t]*(\w+))?[ \t]*(?:::)?[ \t]*(\w+)`)
+	fortranInclude   = regexp.MustCompile(`(?im)^[ \t]*(?:include[ \t]*['"]([^'"\n]+)['"]|#[ \t]*include[ \t]*["<]([^">\n]+)[">])`)
+)
+
+// A fortranSource is what scanFortranSource finds in one file
+type fortranSource struct {
+	writes   []string
+	reads    []string
+	includes []string
+}
+
+// scanFortranSource finds the module definitions, module uses, and
+// includes in `src`. Module names are case-insensitive, and gfortran
+// names their files in lower case.
+func scanFortranSource(src []byte) fortranSource {
+	var out fortranSource
+	defined := make(map[string]bool)
+	for _, m := range fortranModule.FindAllSubmatch(src, -1) {
+		name := strings.ToLower(string(m[1]))
+		if name == "procedure" {
+			continue
+		}
+		defined[name] = true
+		out.writes = append(out.writes, name+".mod", name+".smod")
+	}
+	for _, m := range fortranSubmodule.FindAllSubmatch(src, -1) {
+		ancestor := strings.ToLower(string(m[1]))
+		name := strings.ToLower(string(m[3]))
+		out.writes = append(out.writes, ancestor+"@"+name+".smod")
+		out.reads = append(out.reads, ancestor+".smod")
+		if parent := strings.ToLower(string(m[2])); parent != "" {
+			out.reads = append(out.reads, ancestor+"@"+parent+".smod")
+		}
+	}
+	for _, m := range fortranUse.FindAllSubmatch(src, -1) {
+		name := strings.ToLower(string(m[2]))
+		if strings.EqualFold(string(m[1]), "intrinsic") || defined[name] {
+			continue
+		}
+		out.reads = append(out.reads, name+".mod")
+	}
+	for _, m := range fortranInclude.FindAllSubmatch(src, -1) {
+		inc := string(m[1])
+		if inc == "" {
+			inc = string(m[2])
+		}
+		out.includes = append(out.includes, inc)
+	}
+	return out
+}
+
+// maxFortranIncludeDepth bounds how deeply we follow includes, in
+// case a file includes itself
+const maxFortranIncludeDepth = 200
+
+// scanFortran scans the input, and the files it includes, recording
+// what it finds in c.Fortran. gfortran looks for included files
+// beside the including file, and then along the `-I` path.
+func (c *Compilation) scanFortran() error {
+	var dirs []string
+	for _, inc := range c.Includes {
+		if inc.Opt == "-I" {
+			dirs = append(dirs, inc.Path)
+		}
+	}
+	seen := make(map[string]bool)
+	var scan func(path string, depth int) error
+	scan = func(path string, depth int) error {
+		if depth > maxFortranIncludeDepth {
+			return fmt.Errorf("%s: includes nested too deeply", path)
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found := scanFortranSource(src)
+		c.Fortran.Writes = append(c.Fortran.Writes, found.writes...)
+		c.Fortran.Reads = append(c.Fortran.Reads, found.reads...)
+		for _, inc := range found.includes {
+			for _, dir := range append([]string{filepath.Dir(path)}, dirs...) {
+				p := filepath.Join(dir, inc)
+				if filepath.IsAbs(inc) {
+					p = inc
+				}
+				if !isFile(p) {
+					continue
+				}
+				if !seen[p] {
+					seen[p] = true
+					c.Fortran.Includes = append(c.Fortran.Includes, p)
+					if err := scan(p, depth+1); err != nil {
+						return err
+					}
+				}
+				break
+			}
+		}
+		return nil
+	}
+	return scan(c.Input, 0)
+}
+
+// fortranModuleDeps returns the module files `comp` reads which are
+// in its module directory or along its `-I` path. Those in the
+// working directory are handled by addFortranModules, since the
+// remote compiler runs elsewhere.
+func fortranModuleDeps(comp *Compilation) []string {
+	var dirs []string
+	if comp.Fortran.ModDir != "" {
+		dirs = append(dirs, comp.Fortran.ModDir)
+	}
+	for _, inc := range comp.Includes {
+		if inc.Opt == "-I" {
+			dirs = append(dirs, inc.Path)
+		}
+	}
+	var out []string
+	for _, mod := range comp.Fortran.Reads {
+		if isFile(mod) {
+			continue
+		}
+		for _, dir := range dirs {
+			if p := filepath.Join(dir, mod); isFile(p) {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// addFortranModules adds the module files `comp` writes, and those it
+// reads from the working directory, to `args`, and returns the
+// arguments the remote compiler needs to put them in the right
+// place. `cwd` and `rpath` are as for addModules.
+func addFortranModules(args *daemon.InvokeWithFilesArgs, comp *Compilation, wd string, cwd func(string) string, rpath func(string) string) []string {
+	if !comp.Language.IsFortran() {
+		return nil
+	}
+	inWd := func(mod string) files.Mapped {
+		return files.Mapped{
+			Local:  files.LocalFile{Path: filepath.Join(wd, mod)},
+			Remote: cwd(mod),
+		}
+	}
+	for _, mod := range comp.Fortran.Reads {
+		if f := inWd(mod); isFile(f.Local.Path) {
+			args.Files = args.Files.Append(f)
+		}
+	}
+	dir := comp.Fortran.ModDir
+	for _, mod := range comp.Fortran.Writes {
+		if dir == "" {
+			args.Outputs = args.Outputs.Append(inWd(mod))
+		} else {
+			args.Outputs = args.Outputs.Append(remap(filepath.Join(dir, mod), wd))
+		}
+	}
+	if dir == "" {
+		return nil
+	}
+	return []string{"-J", rpath(dir)}
+}
+
+// checkFortranSupported returns an error if we can't compile `comp`
+// remotely, and otherwise scans its source.
+func checkFortranSupported(cfg *Config, comp *Compilation) error {
+	if !comp.Language.IsFortran() {
+		return nil
+	}
+	if cfg.LocalPreprocess {
+		return errors.New("Fortran source, and LLAMACC_LOCAL_PREPROCESS set")
+	}
+	if comp.ReadsStdin() {
+		return errors.New("Fortran from stdin is not supported remotely")
+	}
+	if err := comp.scanFortran(); err != nil {
+		if os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("scanning Fortran source: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanFortranSource(t *testing.T) {
+	src := `
+module Geometry
+  use, intrinsic :: iso_c_binding
+  use points, only: point
+  use :: shapes
+  implicit none
+  include 'consts.inc'
+  interface
+    module function area(s) result(a)
+    end function
+  end interface
+contains
+  module procedure perimeter
+  end procedure
+end module Geometry
+
+program main
+  use geometry
+  ! use commented
+  user_count = 1
+end program
+#include "config.h"
+`
+	got := scanFortranSource([]byte(src))
+	assert.Equal(t, fortranSource{
+		writes:   []string{"geometry.mod", "geometry.smod"},
+		reads:    []string{"points.mod", "shapes.mod"},
+		includes: []string{"consts.inc", "config.h"},
+	}, got)
+
+	got = scanFortranSource([]byte("submodule (geometry) areas\nend submodule\nsubmodule (geometry:areas) circles\n"))
+	assert.Equal(t, fortranSource{
+		writes: []string{"geometry@areas.smod", "geometry@circles.smod"},
+		reads:  []string{"geometry.smod", "geometry.smod", "geometry@areas.smod"},
+	}, got)
+}
+
+func TestParseCompileFortran(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{
+		"gfortran", "-O2", "-J", "mods", "-Iinclude", "-c", "src/solver.F90", "-o", "obj/solver.o",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, LangFortranCpp, comp.Language)
+	assert.Equal(t, "mods", comp.Fortran.ModDir)
+	assert.NotContains(t, comp.RemoteArgs, "-J")
+	assert.Equal(t, "gfortran", comp.LocalCompiler(&DefaultConfig))
+	assert.Equal(t, "gfortran", comp.RemoteCompiler(&DefaultConfig))
+
+	comp, err = ParseCompile(&DefaultConfig, []string{"llamafort", "-c", "legacy.f"})
+	require.NoError(t, err)
+	assert.Equal(t, LangFortran77, comp.Language)
+	assert.Equal(t, "legacy.o", comp.Output)
+}
+
+func TestIsFortran(t *testing.T) {
+	for _, name := range []string{"llamafort", "/usr/bin/llama-gfortran", "llamagfortran-12"} {
+		assert.True(t, DefaultConfig.IsFortran(name), name)
+		assert.False(t, DefaultConfig.IsCxx(name), name)
+		assert.Equal(t, "", DefaultConfig.Archiver(name), name)
+	}
+	assert.False(t, DefaultConfig.IsFortran("llamacc"))
+	cfg := ParseConfig([]string{"LLAMACC_DRIVER=gfortran"})
+	assert.True(t, cfg.IsFortran("llamacc"))
+}
+
+func TestScanFortran(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "include"), 0755))
+	write := func(name, src string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(p, []byte(src), 0644))
+		return p
+	}
+	src := write("solver.f90", "module solver\n  include 'params.inc'\n  include 'missing.inc'\nend module\n")
+	params := write("include/params.inc", "use linalg\ninclude 'sizes.inc'\n")
+	sizes := write("include/sizes.inc", "include 'params.inc'\n")
+
+	comp := Compilation{
+		Language: LangFortran,
+		Input:    src,
+		Includes: []Include{{"-I", filepath.Join(dir, "include")}},
+	}
+	require.NoError(t, comp.scanFortran())
+	assert.Equal(t, []string{params, sizes}, comp.Fortran.Includes)
+	assert.Equal(t, []string{"solver.mod", "solver.smod"}, comp.Fortran.Writes)
+	assert.Equal(t, []string{"linalg.mod"}, comp.Fortran.Reads)
+}
+
+func TestAddFortranModules(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix paths")
+	}
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "linalg.mod"), nil, 0644))
+
+	comp := Compilation{
+		Language: LangFortran,
+		Fortran: FortranFlags{
+			Writes: []string{"solver.mod"},
+			Reads:  []string{"linalg.mod", "missing.mod"},
+		},
+	}
+	var args daemon.InvokeWithFilesArgs
+	cwd := func(p string) string { return p }
+	out := addFortranModules(&args, &comp, dir, cwd, nil)
+	assert.Empty(t, out)
+	assert.Equal(t, files.List{{
+		Local:  files.LocalFile{Path: filepath.Join(dir, "solver.mod")},
+		Remote: "solver.mod",
+	}}, args.Outputs)
+	assert.Equal(t, files.List{{
+		Local:  files.LocalFile{Path: filepath.Join(dir, "linalg.mod")},
+		Remote: "linalg.mod",
+	}}, args.Files)
+
+	comp.Fortran.ModDir = "mods"
+	args = daemon.InvokeWithFilesArgs{}
+	rpath := func(p string) string { return toRemote(p, "/src") }
+	out = addFortranModules(&args, &comp, "/src", cwd, rpath)
+	assert.Equal(t, []string{"-J", "_root/src/mods"}, out)
+	assert.Equal(t, files.List{remap("mods/solver.mod", "/src")}, args.Outputs)
+}
+
+func TestCheckFortranSupported(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.LocalPreprocess = true
+	assert.Error(t, checkFortranSupported(&cfg, &Compilation{Language: LangFortran, Input: "x.f90"}))
+	assert.Error(t, checkFortranSupported(&DefaultConfig, &Compilation{Language: LangFortran, Input: "-"}))
+	assert.NoError(t, checkFortranSupported(&cfg, &Compilation{Language: LangC, Input: "x.c"}))
+}
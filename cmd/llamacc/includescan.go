@@ -0,0 +1,63 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// includeSearchPath returns the directories the compiler will search
+// for "quoted" and <bracketed> includes, in order, not counting the
+// compiler's built-in directories.
+func includeSearchPath(comp *Compilation, wd string) (quote, bracket []string) {
+	var after []string
+	for _, inc := range comp.Includes {
+		switch inc.Opt {
+		case "-iquote":
+			quote = append(quote, toAbs(inc.Path, wd))
+		case "-I", "-isystem":
+			bracket = append(bracket, toAbs(inc.Path, wd))
+		case "-idirafter":
+			after = append(after, toAbs(inc.Path, wd))
+		}
+	}
+	bracket = append(bracket, after...)
+	quote = append(quote, bracket...)
+	return quote, bracket
+}
+
+// scanIncludes asks the daemon's include server for the input file
+// and every header it may (transitively) include, without running
+// the preprocessor. See the daemon's includeIndex.
+func scanIncludes(client *daemon.Client, comp *Compilation) ([]string, error) {
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return nil, err
+	}
+	var args daemon.ScanIncludesArgs
+	args.Quote, args.Bracket = includeSearchPath(comp, wd)
+	args.Inputs = append(args.Inputs, toAbs(comp.Input, wd))
+	for _, inc := range comp.Includes {
+		if inc.Opt == "-include" {
+			args.Inputs = append(args.Inputs, toAbs(inc.Path, wd))
+		}
+	}
+	reply, err := client.ScanIncludes(&args)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Deps, nil
+}
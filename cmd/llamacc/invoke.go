@@ -0,0 +1,130 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/logging"
+)
+
+// invokeRemote executes `args` via the daemon and copies the
+// command's output to `stdout` and our stderr. If streaming is enabled,
+// output is printed as the remote command produces it.
+func invokeRemote(client *daemon.Client, cfg *Config, args *daemon.InvokeWithFilesArgs, stdout io.Writer) (*daemon.InvokeWithFilesReply, error) {
+	args.Memory = cfg.Memory
+	args.Timeout = cfg.Timeout
+	args.Build = cfg.BuildID
+	if args.Build == "" {
+		args.Build = daemon.DefaultBuild
+	}
+	args.DirectOutputs = cfg.Direct
+	var stream *daemon.OutputStream
+	if cfg.Stream {
+		stream = client.StartStream(stdout, os.Stderr)
+		args.Stream = stream.ID
+	}
+	reclaim := func() {}
+	if args.DropSemaphore {
+		reclaim = processJobserver(cfg).lend()
+	}
+	out, err := client.InvokeWithFiles(args)
+	reclaim()
+	var wroteOut, wroteErr int
+	if stream != nil {
+		wroteOut, wroteErr = stream.Stop()
+	}
+	if err != nil {
+		return nil, &invokeError{err}
+	}
+	if len(out.DirectOutputs) > 0 {
+		if err := fetchDirect(client, out.DirectOutputs); err != nil {
+			return nil, &invokeError{err}
+		}
+	}
+	logging.Record(context.Background(), "invocation",
+		"invocation_id", out.InvocationID,
+		"function", args.Function,
+		"exit_status", out.ExitStatus,
+		"cached", out.Cached,
+		"e2e", out.Timing.E2E,
+		"upload", out.Timing.Upload,
+		"queue", out.Timing.Queue,
+		"invoke", out.Timing.Invoke,
+		"fetch", out.Timing.Fetch,
+		"cold_start", out.Timing.Remote.ColdStart,
+		"remote_init", out.Timing.Remote.Init,
+		"remote_exec", out.Timing.Remote.Exec,
+	)
+	if cfg.Verbose {
+		var desc string
+		if len(args.Outputs) > 0 {
+			desc = " " + args.Outputs[0].Local.Path
+		}
+		log.Printf("[llamacc] timing%s: %s", desc, formatTiming(&out.Timing, out.Cached))
+	}
+	if wroteOut < len(out.Stdout) {
+		stdout.Write(out.Stdout[wroteOut:])
+	}
+	if wroteErr < len(out.Stderr) {
+		os.Stderr.Write(out.Stderr[wroteErr:])
+	}
+	if out.InvokeErr != "" {
+		return out, &invokeError{fmt.Errorf("invoke: %s", out.InvokeErr)}
+	}
+	if out.ExitStatus != 0 {
+		return out, fmt.Errorf("invoke: exit %d", out.ExitStatus)
+	}
+	return out, nil
+}
+
+// formatTiming breaks down where an invocation's time went, from
+// waiting in the daemon's queue, through Lambda startup and the
+// remote command, to fetching its outputs
+func formatTiming(t *daemon.Timing, cached bool) string {
+	ms := func(d time.Duration) string {
+		if d < 0 {
+			d = 0
+		}
+		return d.Round(time.Millisecond).String()
+	}
+	var parts []string
+	add := func(phase string, d time.Duration) {
+		parts = append(parts, fmt.Sprintf("%s %s", phase, ms(d)))
+	}
+	add("upload", t.Upload)
+	if cached {
+		parts = append(parts, "cached")
+	} else {
+		add("queue", t.Queue)
+		if t.Remote.ColdStart {
+			add("cold start", t.Remote.Init)
+		}
+		add("input fetch", t.Remote.Fetch)
+		add("exec", t.Remote.Exec)
+		add("output upload", t.Remote.Upload)
+		add("network", t.Invoke-t.Remote.E2E-t.Remote.Init)
+	}
+	add("download", t.Fetch)
+	add("total", t.E2E)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTiming(t *testing.T) {
+	timing := daemon.Timing{
+		E2E:    1500 * time.Millisecond,
+		Upload: 20 * time.Millisecond,
+		Queue:  100 * time.Millisecond,
+		Invoke: 1300 * time.Millisecond,
+		Fetch:  80 * time.Millisecond,
+		Remote: protocol.Timing{
+			ColdStart: true,
+			Init:      200 * time.Millisecond,
+			E2E:       1050 * time.Millisecond,
+			Fetch:     30 * time.Millisecond,
+			Exec:      1000 * time.Millisecond,
+			Upload:    20 * time.Millisecond,
+		},
+	}
+	assert.Equal(t,
+		"upload 20ms, queue 100ms, cold start 200ms, input fetch 30ms, exec 1s, output upload 20ms, network 50ms, download 80ms, total 1.5s",
+		formatTiming(&timing, false))
+
+	timing.Remote.ColdStart = false
+	timing.Remote.Init = 0
+	timing.Invoke = 1000 * time.Millisecond
+	assert.Equal(t,
+		"upload 20ms, queue 100ms, input fetch 30ms, exec 1s, output upload 20ms, network 0s, download 80ms, total 1.5s",
+		formatTiming(&timing, false))
+
+	assert.Equal(t,
+		"upload 20ms, cached, download 80ms, total 1.5s",
+		formatTiming(&timing, true))
+}
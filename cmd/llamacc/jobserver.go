@@ -0,0 +1,163 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+)
+
+// parseJobserverAuth finds the jobserver GNU make passes its children
+// in MAKEFLAGS: "fifo:PATH" for make 4.4's named pipe, or "R,W" for
+// the file descriptors of an anonymous pipe. It returns "" if there
+// is none.
+func parseJobserverAuth(makeflags string) string {
+	var auth string
+	for _, word := range strings.Fields(makeflags) {
+		if word == "--" {
+			// Variable definitions follow
+			break
+		}
+		for _, opt := range []string{"--jobserver-auth=", "--jobserver-fds="} {
+			if strings.HasPrefix(word, opt) {
+				auth = word[len(opt):]
+			}
+		}
+	}
+	return auth
+}
+
+// A jobserver is our connection to make's jobserver. make holds a
+// token for each job it runs; we give ours back while the
+// compilation runs remotely, so that make can run another job
+// locally, and take one again before we do any more local work.
+type jobserver struct {
+	r, w *os.File
+	// The number of tokens all llamacc processes may give back
+	// at once, which bounds the compilations running remotely
+	slots   int
+	slotDir string
+	verbose bool
+}
+
+// openJobserver connects to make's jobserver, if LLAMACC_JOBSERVER is
+// set and make passed us one. It returns nil otherwise, or if the
+// jobserver can't be reached.
+func openJobserver(cfg *Config) *jobserver {
+	if cfg.Jobserver <= 0 {
+		return nil
+	}
+	auth := parseJobserverAuth(cfg.MakeFlags)
+	if auth == "" {
+		return nil
+	}
+	r, w, err := openJobserverAuth(auth)
+	if err != nil {
+		if cfg.Verbose {
+			log.Printf("[llamacc] not using jobserver %q: %s", auth, err.Error())
+		}
+		return nil
+	}
+	return &jobserver{
+		r: r, w: w,
+		slots:   cfg.Jobserver,
+		slotDir: filepath.Join(cli.ConfigDir(), "jobserver"),
+		verbose: cfg.Verbose,
+	}
+}
+
+var (
+	jobserverOnce sync.Once
+	theJobserver  *jobserver
+)
+
+// processJobserver returns our connection to make's jobserver,
+// connecting on first use. We must only wrap make's file descriptors
+// in an *os.File once, since closing one closes the descriptor.
+func processJobserver(cfg *Config) *jobserver {
+	jobserverOnce.Do(func() {
+		theJobserver = openJobserver(cfg)
+	})
+	return theJobserver
+}
+
+// lend gives a token back to make, if there is a slot free to run
+// one more compilation remotely, and returns a function that waits
+// for a token to replace it. It must be called before we exit, or
+// make's pool of tokens grows for the rest of the build. lend is a
+// no-op on a nil *jobserver.
+func (js *jobserver) lend() (reclaim func()) {
+	if js == nil {
+		return func() {}
+	}
+	slot, err := lockSlot(js.slotDir, js.slots)
+	if err != nil || slot == nil {
+		if err != nil && js.verbose {
+			log.Printf("[llamacc] jobserver: %s", err.Error())
+		}
+		return func() {}
+	}
+	if _, err := js.w.Write([]byte{'+'}); err != nil {
+		slot.Close()
+		if js.verbose {
+			log.Printf("[llamacc] jobserver: returning token: %s", err.Error())
+		}
+		return func() {}
+	}
+	return func() {
+		var token [1]byte
+		_, err := js.r.Read(token[:])
+		slot.Close()
+		if err != nil {
+			// We can't go on without a token, or make
+			// would run one job too many
+			fmt.Fprintf(os.Stderr, "[llamacc] jobserver: taking a token: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+// lockSlot takes one of `n` slots, each a file in `dir` that we hold
+// locked. The lock is released when the returned file is closed, or
+// when we exit. It returns nil if every slot is taken.
+func lockSlot(dir string, n int) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	start := os.Getpid() % n
+	for i := 0; i < n; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("slot.%d", (start+i)%n))
+		f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			return f, nil
+		}
+		f.Close()
+	}
+	return nil, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// openJobserverAuth opens the jobserver described by a
+// --jobserver-auth argument
+func openJobserverAuth(auth string) (r, w *os.File, err error) {
+	if strings.HasPrefix(auth, "fifo:") {
+		f, err := os.OpenFile(auth[len("fifo:"):], os.O_RDWR, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f, nil
+	}
+	fds := strings.Split(auth, ",")
+	if len(fds) != 2 {
+		return nil, nil, fmt.Errorf("bad jobserver %q", auth)
+	}
+	var files [2]*os.File
+	for i, s := range fds {
+		fd, err := strconv.Atoi(s)
+		if err != nil || fd < 0 {
+			return nil, nil, fmt.Errorf("bad jobserver %q", auth)
+		}
+		// make only leaves the pipe open for commands it
+		// knows to be recursive; otherwise the descriptor is
+		// closed, or is something else entirely.
+		var st syscall.Stat_t
+		if err := syscall.Fstat(fd, &st); err != nil {
+			return nil, nil, err
+		}
+		if st.Mode&syscall.S_IFMT != syscall.S_IFIFO {
+			return nil, nil, errors.New("jobserver file descriptor is not a pipe; is the rule marked with '+'?")
+		}
+		files[i] = os.NewFile(uintptr(fd), fmt.Sprintf("jobserver-%d", fd))
+	}
+	return files[0], files[1], nil
+}
+
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
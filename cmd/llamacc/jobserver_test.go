@@ -0,0 +1,107 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJobserverAuth(t *testing.T) {
+	cases := []struct {
+		makeflags string
+		want      string
+	}{
+		{"", ""},
+		{"-j8", ""},
+		{" -j8 --jobserver-auth=3,4", "3,4"},
+		{"-j8 --jobserver-fds=5,6 -j", "5,6"},
+		{"-j8 --jobserver-auth=fifo:/tmp/GMfifo123", "fifo:/tmp/GMfifo123"},
+		// make 4.2 passes both; the last wins
+		{"--jobserver-fds=3,4 --jobserver-auth=5,6", "5,6"},
+		{"-j8 -- CFLAGS=--jobserver-auth=7,8", ""},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, parseJobserverAuth(tc.makeflags), "%q", tc.makeflags)
+	}
+
+	cfg := ParseConfig([]string{"LLAMACC_JOBSERVER=200", "MAKEFLAGS=-j8 --jobserver-auth=3,4"})
+	assert.Equal(t, 200, cfg.Jobserver)
+	assert.Equal(t, "3,4", parseJobserverAuth(cfg.MakeFlags))
+}
+
+func TestJobserverLend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the jobserver is not supported on Windows")
+	}
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	js := &jobserver{r: r, w: w, slots: 1, slotDir: t.TempDir()}
+
+	reclaim := js.lend()
+	// The only slot is taken, so this keeps its token
+	js.lend()()
+	// Take back the token we gave
+	reclaim()
+
+	// The pipe is empty again, and the slot free
+	_, err = w.Write([]byte{'x'})
+	require.NoError(t, err)
+	var token [1]byte
+	_, err = r.Read(token[:])
+	require.NoError(t, err)
+	assert.Equal(t, byte('x'), token[0])
+
+	slot, err := lockSlot(js.slotDir, 1)
+	require.NoError(t, err)
+	require.NotNil(t, slot)
+	slot.Close()
+
+	// A nil jobserver does nothing
+	var none *jobserver
+	none.lend()()
+}
+
+func TestLockSlot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the jobserver is not supported on Windows")
+	}
+	dir := t.TempDir()
+	a, err := lockSlot(dir, 2)
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	b, err := lockSlot(dir, 2)
+	require.NoError(t, err)
+	require.NotNil(t, b)
+	assert.NotEqual(t, a.Name(), b.Name())
+
+	none, err := lockSlot(dir, 2)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	a.Close()
+	again, err := lockSlot(dir, 2)
+	require.NoError(t, err)
+	require.NotNil(t, again)
+	assert.Equal(t, a.Name(), again.Name())
+	again.Close()
+	b.Close()
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// openJobserverAuth fails on Windows, where make's jobserver is a
+// named semaphore rather than a pipe.
+func openJobserverAuth(auth string) (r, w *os.File, err error) {
+	return nil, nil, errors.New("the jobserver is not supported on Windows")
+}
+
+func tryLock(f *os.File) (bool, error) {
+	return false, errors.New("not supported on Windows")
+}
@@ -0,0 +1,256 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/tracing"
+)
+
+var linkInputExts = map[string]bool{
+	".o":  true,
+	".a":  true,
+	".so": true,
+	".lo": true,
+}
+
+// These set the address of a section, rather than naming a linker
+// script with -T
+var sectionAddressOpts = []string{
+	"-Ttext=", "-Tdata=", "-Tbss=",
+	"-Ttext-segment=", "-Trodata-segment=", "-Tldata-segment=",
+}
+
+func isSectionAddress(arg string) bool {
+	for _, opt := range sectionAddressOpts {
+		if strings.HasPrefix(arg, opt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Library directories under these prefixes are assumed to be
+// provided by the remote image, and are not uploaded.
+var systemLibPrefixes = []string{"/usr/", "/lib/", "/lib64/"}
+
+type LinkArg struct {
+	Opt  string
+	Path string
+}
+
+type Link struct {
+	Driver  string
+	Output  string
+	Args    []LinkArg
+	LibDirs []string
+	Libs    []string
+	// Local files which must be uploaded to perform the link
+	Inputs []string
+	// The sysroot, from `--sysroot` or LLAMACC_SYSROOT
+	Sysroot string
+}
+
+// ParseLink parses `argv` as an invocation of the compiler driver to
+// link a binary or shared library out of already-compiled objects. It
+// returns an error if `argv` does anything other than link.
+func ParseLink(cfg *Config, argv []string) (Link, error) {
+	var out Link
+	out.Driver = "cc"
+	if cfg.IsCxx(argv[0]) {
+		out.Driver = "c++"
+	} else if cfg.IsFortran(argv[0]) {
+		out.Driver = "fortran"
+	}
+	args, err := expandResponseFiles(argv[1:])
+	if err != nil {
+		return out, err
+	}
+	args = rewriteWp(args)
+
+	i := 0
+	for i < len(args) {
+		arg := args[i]
+		i++
+		switch {
+		case arg == "-c" || arg == "-S" || arg == "-E" || arg == "-M" || arg == "-MM":
+			return out, fmt.Errorf("%s given", arg)
+		case arg == "-x":
+			return out, errors.New("-x given")
+		case isSectionAddress(arg):
+			out.Args = append(out.Args, LinkArg{Opt: arg})
+		case strings.HasPrefix(arg, "-o") || strings.HasPrefix(arg, "-L") ||
+			strings.HasPrefix(arg, "-l") || strings.HasPrefix(arg, "-T"):
+			flag := arg[:2]
+			flagArg, eat := eatArg(args[i-1:], flag)
+			if eat {
+				i++
+				if i > len(args) {
+					return out, fmt.Errorf("%s: expected arg", flag)
+				}
+			}
+			switch flag {
+			case "-o":
+				if out.Output != "" {
+					return out, fmt.Errorf("multiple outputs: %s, %s", out.Output, flagArg)
+				}
+				out.Output = flagArg
+			case "-L":
+				out.LibDirs = append(out.LibDirs, flagArg)
+				out.Args = append(out.Args, LinkArg{Opt: "-L", Path: flagArg})
+			case "-l":
+				out.Libs = append(out.Libs, flagArg)
+				out.Args = append(out.Args, LinkArg{Opt: "-l" + flagArg})
+			case "-T":
+				out.Inputs = append(out.Inputs, flagArg)
+				out.Args = append(out.Args, LinkArg{Opt: "-T", Path: flagArg})
+			}
+		case arg == "--sysroot":
+			if i >= len(args) {
+				return out, errors.New("--sysroot: expected arg")
+			}
+			out.Sysroot = args[i]
+			i++
+		case strings.HasPrefix(arg, "--sysroot="):
+			out.Sysroot = arg[len("--sysroot="):]
+		case strings.HasPrefix(arg, "-"):
+			out.Args = append(out.Args, LinkArg{Opt: arg})
+		case linkInputExts[filepath.Ext(arg)]:
+			out.Inputs = append(out.Inputs, arg)
+			out.Args = append(out.Args, LinkArg{Path: arg})
+		default:
+			return out, fmt.Errorf("unsupported link input: %s", arg)
+		}
+	}
+
+	if len(out.Inputs) == 0 {
+		return out, errors.New("no link inputs detected")
+	}
+	if out.Output == "" {
+		out.Output = "a.out"
+	}
+	if out.Sysroot == "" {
+		out.Sysroot = cfg.Sysroot
+	}
+	out.Inputs = append(out.Inputs, resolveLibs(out.LibDirs, out.Libs)...)
+	return out, nil
+}
+
+func isSystemLibDir(dir string) bool {
+	for _, pfx := range systemLibPrefixes {
+		if strings.HasPrefix(dir+"/", pfx) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLibs finds the local files that satisfy `-l` options out of
+// any user-specified library directories. Libraries that aren't found
+// are assumed to be present in the remote image.
+func resolveLibs(dirs []string, libs []string) []string {
+	var out []string
+	for _, lib := range libs {
+		var names []string
+		if strings.HasPrefix(lib, ":") {
+			names = []string{lib[1:]}
+		} else {
+			names = []string{"lib" + lib + ".so", "lib" + lib + ".a"}
+		}
+	search:
+		for _, dir := range dirs {
+			if isSystemLibDir(dir) {
+				continue
+			}
+			for _, name := range names {
+				candidate := filepath.Join(dir, name)
+				if st, err := os.Stat(candidate); err == nil && st.Mode().IsRegular() {
+					out = append(out, candidate)
+					break search
+				}
+			}
+		}
+	}
+	return out
+}
+
+func runLlamaLink(cfg *Config, link *Link) error {
+	return runRemote(cfg, func(ctx context.Context, client *daemon.Client) error {
+		return buildRemoteLink(ctx, client, cfg, link)
+	})
+}
+
+func buildRemoteLink(ctx context.Context, client *daemon.Client, cfg *Config, link *Link) error {
+	ctx, span := tracing.StartSpan(ctx, "link")
+	defer span.End()
+	span.AddField("inputs", len(link.Inputs))
+
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return err
+	}
+
+	args := daemon.InvokeWithFilesArgs{
+		Function:      cfg.Function,
+		DropSemaphore: true,
+		Trace:         tracing.PropagationFromContext(ctx),
+	}
+	rpath := func(p string) string { return toRemote(p, wd) }
+	inputs := link.Inputs
+	var sysroot string
+	if link.Sysroot != "" {
+		sysroot, inputs = cfg.shipSysroot(&args, link.Sysroot, wd, rpath, inputs)
+	}
+	for _, in := range inputs {
+		args.Files = args.Files.Append(remap(in, wd))
+	}
+	args.Outputs = args.Outputs.Append(remap(link.Output, wd))
+
+	args.Args = []string{cfg.RemoteCC}
+	switch link.Driver {
+	case "c++":
+		args.Args[0] = cfg.RemoteCXX
+	case "fortran":
+		args.Args[0] = cfg.RemoteFC
+	}
+	args.Args = append(args.Args, cfg.TargetArgs()...)
+	if sysroot != "" {
+		args.Args = append(args.Args, "--sysroot="+sysroot)
+	}
+	for _, arg := range link.Args {
+		if arg.Opt != "" {
+			args.Args = append(args.Args, arg.Opt)
+		}
+		if arg.Path != "" {
+			args.Args = append(args.Args, toRemote(arg.Path, wd))
+		}
+	}
+	args.Args = append(args.Args, "-o", toRemote(link.Output, wd))
+	if cfg.Verbose {
+		log.Printf("[llamacc] linking remotely: %#v", args)
+	}
+
+	_, err = invokeRemote(client, cfg, &args, os.Stdout)
+	return err
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		argv []string
+		out  Link
+		err  bool
+	}{
+		{
+			[]string{"cc", "-o", "hello", "hello.o", "util.a", "-lm"},
+			Link{
+				Driver: "cc",
+				Output: "hello",
+				Args: []LinkArg{
+					{Path: "hello.o"},
+					{Path: "util.a"},
+					{Opt: "-lm"},
+				},
+				Libs:   []string{"m"},
+				Inputs: []string{"hello.o", "util.a"},
+			},
+			false,
+		},
+		{
+			[]string{"c++", "-fuse-ld=lld", "-Wl,--gc-sections", "-Lbuild/lib", "main.o"},
+			Link{
+				Driver: "c++",
+				Output: "a.out",
+				Args: []LinkArg{
+					{Opt: "-fuse-ld=lld"},
+					{Opt: "-Wl,--gc-sections"},
+					{Opt: "-L", Path: "build/lib"},
+					{Path: "main.o"},
+				},
+				LibDirs: []string{"build/lib"},
+				Inputs:  []string{"main.o"},
+			},
+			false,
+		},
+		{
+			[]string{"cc", "-ofirmware.elf", "-Ttext=0x1000", "-Tbss=0x8000", "-Ttext-segment=0x400000", "-T", "boot.ld", "-Tsections.ld", "start.o"},
+			Link{
+				Driver: "cc",
+				Output: "firmware.elf",
+				Args: []LinkArg{
+					{Opt: "-Ttext=0x1000"},
+					{Opt: "-Tbss=0x8000"},
+					{Opt: "-Ttext-segment=0x400000"},
+					{Opt: "-T", Path: "boot.ld"},
+					{Opt: "-T", Path: "sections.ld"},
+					{Path: "start.o"},
+				},
+				Inputs: []string{"boot.ld", "sections.ld", "start.o"},
+			},
+			false,
+		},
+		{
+			[]string{"cc", "-c", "hello.c"},
+			Link{},
+			true,
+		},
+		{
+			[]string{"cc", "-o", "hello", "hello.c"},
+			Link{},
+			true,
+		},
+		{
+			[]string{"cc", "-lm"},
+			Link{},
+			true,
+		},
+		{
+			[]string{"cc", "main.o", "-l", "z", "-o"},
+			Link{},
+			true,
+		},
+	}
+	for i, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := ParseLink(&DefaultConfig, tc.argv)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &tc.out, &got)
+		})
+	}
+}
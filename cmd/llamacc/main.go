@@ -18,35 +18,61 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path"
-	"strings"
+	"path/filepath"
+	"regexp"
+	"runtime"
 
 	"context"
 
 	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
 	"github.com/nelhage/llama/daemon"
 	"github.com/nelhage/llama/daemon/server"
 	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/logging"
+	protofiles "github.com/nelhage/llama/protocol/files"
 	"github.com/nelhage/llama/tracing"
 )
 
-func runLlamaCC(cfg *Config, comp *Compilation) error {
+// invokeError indicates that we were unable to execute the
+// compilation remotely, as opposed to the compiler itself reporting
+// an error. These are the errors that LLAMACC_FALLBACK recovers from.
+type invokeError struct {
+	err error
+}
+
+func (e *invokeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invokeError) Unwrap() error {
+	return e.err
+}
+
+func runRemote(cfg *Config, build func(context.Context, *daemon.Client) error) error {
 	var err error
 	ctx := context.Background()
 	mt := tracing.NewMemoryTracer(ctx)
 	ctx = tracing.WithTracer(ctx, mt)
-	ctx, span := tracing.StartSpan(ctx, "llamacc")
+	var parent *tracing.Propagation
+	if tp := os.Getenv(tracing.TraceparentEnv); tp != "" {
+		// Continue a trace started by an instrumented build system
+		parent, _ = tracing.ParseTraceparent(tp)
+	}
+	ctx, span := tracing.StartPropagatedSpan(ctx, "llamacc", parent)
 	if cfg.BuildID != "" {
 		span.AddField("global.build_id", cfg.BuildID)
 	}
 
 	client, err := server.DialWithAutostart(ctx, cli.SocketPath(), server.LlamaCCPath)
 	if err != nil {
-		return err
+		return &invokeError{err}
 	}
 	defer client.Close()
 
@@ -55,22 +81,41 @@ func runLlamaCC(cfg *Config, comp *Compilation) error {
 		client.TraceSpans(&daemon.TraceSpansArgs{Spans: mt.Close()})
 	}()
 
-	if cfg.LocalPreprocess {
-		return buildLocalPreprocess(ctx, client, cfg, comp)
-	} else {
-		return buildRemotePreprocess(ctx, client, cfg, comp)
+	err = build(ctx, client)
+	var ie *invokeError
+	if cfg.Fallback && errors.As(err, &ie) {
+		span.AddField("fallback", true)
+		client.RecordFallback(&daemon.RecordFallbackArgs{})
 	}
+	return err
+}
+
+func runLlamaCC(cfg *Config, comp *Compilation) error {
+	return runRemote(cfg, func(ctx context.Context, client *daemon.Client) error {
+		if err := checkToolchain(client, cfg, comp); err != nil {
+			return err
+		}
+		if cfg.CompileDB != "" {
+			if err := prewarm(client, cfg); err != nil && cfg.Verbose {
+				log.Printf("[llamacc] prewarming from %s: %s", cfg.CompileDB, err.Error())
+			}
+		}
+		if cfg.LocalPreprocess {
+			return buildLocalPreprocess(ctx, client, cfg, comp)
+		}
+		return buildRemotePreprocess(ctx, client, cfg, comp)
+	})
 }
 
 func toAbs(local, wd string) string {
-	if path.IsAbs(local) {
+	if filepath.IsAbs(local) {
 		return local
 	}
-	return path.Join(wd, local)
+	return filepath.Join(wd, local)
 }
 
 func toRemote(local, wd string) string {
-	return path.Join("_root", toAbs(local, wd))
+	return path.Join("_root", files.RemotePath(toAbs(local, wd)))
 }
 
 func remap(local, wd string) files.Mapped {
@@ -83,50 +128,95 @@ func remap(local, wd string) files.Mapped {
 }
 
 func buildRemotePreprocess(ctx context.Context, client *daemon.Client, cfg *Config, comp *Compilation) error {
-	args, err := constructRemotePreprocessInvoke(ctx, client, cfg, comp)
+	construct := constructRemotePreprocessInvoke
+	if comp.MSVC {
+		construct = constructRemoteCLInvoke
+	}
+	args, err := construct(ctx, client, cfg, comp)
 	if err != nil {
 		return err
 	}
 	args.Trace = tracing.PropagationFromContext(ctx)
-	out, err := client.InvokeWithFiles(args)
-	if err != nil {
-		return err
+	if comp.keepsObject() {
+		wd, err := files.WorkingDir()
+		if err != nil {
+			return err
+		}
+		cfg.keepRemote(args, comp.Output, wd)
+	}
+	if cfg.shouldRace(comp) {
+		return raceLocal(ctx, client, cfg, comp, args)
 	}
-	os.Stdout.Write(out.Stdout)
-	os.Stderr.Write(out.Stderr)
-	if out.InvokeErr != "" {
-		return fmt.Errorf("invoke: %s", out.InvokeErr)
+	var stdout io.Writer = os.Stdout
+	if comp.Flag.ShowIncludes != "" {
+		notes := &showIncludesWriter{w: os.Stdout, windows: runtime.GOOS == "windows"}
+		defer notes.Flush()
+		stdout = notes
 	}
-	if out.ExitStatus != 0 {
-		return fmt.Errorf("invoke: exit %d", out.ExitStatus)
+	// Hold an object written to stdout until we know it's
+	// complete, in case we fall back to compiling locally
+	var obj bytes.Buffer
+	if comp.WritesStdout() {
+		stdout = &obj
+	}
+	if _, err := invokeRemote(client, cfg, args, stdout); err != nil {
+		return err
 	}
+	if _, err := os.Stdout.Write(obj.Bytes()); err != nil {
+		return err
+	}
+	return finishRemote(ctx, cfg, comp)
+}
 
+// finishRemote post-processes the outputs of a remote compilation
+// with remote preprocessing
+func finishRemote(ctx context.Context, cfg *Config, comp *Compilation) error {
 	if comp.Flag.MF != "" {
-		return rewriteMF(ctx, comp)
+		if err := rewriteMF(ctx, cfg, comp); err != nil {
+			return err
+		}
+	}
+	if cfg.shouldVerify(comp) {
+		return verifyLocally(cfg, comp)
 	}
-
 	return nil
 }
 
-func rewriteMF(ctx context.Context, comp *Compilation) error {
+func rewriteMF(ctx context.Context, cfg *Config, comp *Compilation) error {
 	tmpMF := comp.Flag.MF + ".tmp"
 	data, err := ioutil.ReadFile(tmpMF)
 	if err != nil {
 		return err
 	}
-	data = bytes.ReplaceAll(data, []byte("_root/"), []byte("/"))
-	if err := ioutil.WriteFile(comp.Flag.MF, data, 0644); err != nil {
+	data = cfg.localizeImagePaths(data)
+	data = localizeDeps(data, runtime.GOOS == "windows")
+	if err := protofiles.WriteFileAtomic(comp.Flag.MF, data, 0644); err != nil {
 		return err
 	}
 	return os.Remove(tmpMF)
 }
 
+var remoteDrive = regexp.MustCompile(`_root/([a-z])/`)
+
+// localizeDeps rewrites the remote paths in a dependency file to
+// local ones. See files.RemotePath: on Windows, `_root/c/src/foo.h`
+// becomes `C:/src/foo.h`.
+func localizeDeps(data []byte, windows bool) []byte {
+	if windows {
+		return remoteDrive.ReplaceAllFunc(data, func(m []byte) []byte {
+			return append(bytes.ToUpper(m[len("_root/"):len("_root/")+1]), ":/"...)
+		})
+	}
+	return bytes.ReplaceAll(data, []byte("_root/"), []byte("/"))
+}
+
 func constructRemotePreprocessInvoke(ctx context.Context, client *daemon.Client, cfg *Config, comp *Compilation) (*daemon.InvokeWithFilesArgs, error) {
 	wd, err := files.WorkingDir()
 	if err != nil {
 		return nil, err
 	}
 
+	stageInputs(client, cfg, comp, wd)
 	deps, err := detectDependencies(ctx, client, cfg, comp)
 	if err != nil {
 		return nil, fmt.Errorf("Detecting dependencies: %w", err)
@@ -135,30 +225,84 @@ func constructRemotePreprocessInvoke(ctx context.Context, client *daemon.Client,
 	args := daemon.InvokeWithFilesArgs{
 		Function:      cfg.Function,
 		DropSemaphore: true,
+		UseCache:      cfg.Cache,
+		Idempotent:    true,
+		Cache:         cfg.CacheNamespaces,
+		Profile:       toAbs(comp.Output, wd),
 	}
 
-	args.Outputs = args.Outputs.Append(remap(comp.Output, wd))
+	if (!comp.Analyze.Enabled || comp.WritesReport()) && !comp.WritesStdout() {
+		args.Outputs = args.Outputs.Append(remap(comp.Output, wd))
+	}
+	args.Outputs = args.Outputs.Append(comp.secondaryOutputs(toRemote(comp.Output, wd), wd)...)
 
 	if comp.Flag.MF != "" {
 		args.Outputs = args.Outputs.Append(remap(comp.Flag.MF+".tmp", wd))
 	}
-	args.Files = args.Files.Append(remap(comp.Input, wd))
-	for _, dep := range deps {
-		args.Files = args.Files.Append(remap(dep, wd))
+	if comp.ReadsStdin() {
+		setStdin(ctx, client, cfg, &args, comp.Stdin)
+	} else {
+		args.Files = args.Files.Append(remap(comp.Input, wd))
+	}
+
+	rpath := func(p string) string { return toRemote(p, wd) }
+	if cfg.reproducible(comp) {
+		rpath = func(p string) string { return wrapperutil.ToRemoteRel(p, wd) }
+	}
+
+	var sysroot string
+	if comp.Sysroot != "" {
+		sysroot, deps = cfg.shipSysroot(&args, comp.Sysroot, wd, rpath, deps)
+	}
+	cfg.addDependencies(&args, deps, wd)
+	for _, pch := range comp.PrecompiledHeaders() {
+		args.Files = args.Files.Append(remap(pch, wd))
+	}
+	for _, aux := range comp.AuxInputs {
+		args.Files = args.Files.Append(remap(aux.Path, wd))
 	}
 
+	args.Env = cfg.remoteEnv()
 	args.Args = []string{comp.RemoteCompiler(cfg)}
+	args.Args = append(args.Args, cfg.TargetArgs()...)
+	if cfg.reproducible(comp) {
+		args.Args = append(args.Args, rootPrefixMap(wd)...)
+	}
 
-	args.Args = append(args.Args, "-I", toRemote(".", wd))
+	args.Args = append(args.Args, "-I", rpath("."))
 	for _, inc := range comp.Includes {
-		args.Args = append(args.Args, inc.Opt, toRemote(inc.Path, wd))
+		args.Args = append(args.Args, inc.Opt, cfg.remoteInclude(inc.Path, wd, rpath))
+	}
+	if sysroot != "" {
+		args.Args = append(args.Args, "--sysroot="+sysroot)
 	}
 	for _, def := range comp.Defs {
 		args.Args = append(args.Args, def.Opt, def.Def)
 	}
-	args.Args = append(args.Args, "-c")
-	args.Args = append(args.Args, "-o", toRemote(comp.Output, wd))
-	args.Args = append(args.Args, toRemote(comp.Input, wd))
+	for _, aux := range comp.AuxInputs {
+		args.Args = append(args.Args, aux.Opt+rpath(aux.Path))
+	}
+	cwd := func(p string) string { return p }
+	if cfg.reproducible(comp) {
+		// The compiler runs in our working directory's
+		// remote counterpart; see reproducibleCommand
+		cwd = func(p string) string { return toRemote(p, wd) }
+	}
+	args.Args = append(args.Args, addModules(&args, comp, wd, cwd, rpath)...)
+	args.Args = append(args.Args, addFortranModules(&args, comp, wd, cwd, rpath)...)
+	// The input may not have the extension the language was
+	// inferred from, if it was given with `-x`
+	args.Args = append(args.Args, "-x", comp.driverLanguage())
+	if comp.Analyze.Enabled {
+		args.Args = append(args.Args, comp.analyzeArgs()...)
+	} else if !comp.IsPCH() && !comp.Modules.Precompile {
+		args.Args = append(args.Args, "-c")
+	}
+	if comp.SaveTemps != "" {
+		args.Args = append(args.Args, "-save-temps=obj")
+	}
+	args.Args = append(args.Args, "-o", ioPath(comp.Output, rpath))
+	args.Args = append(args.Args, ioPath(comp.Input, rpath))
 	if comp.Flag.MD {
 		args.Args = append(args.Args, "-MD")
 	}
@@ -169,9 +313,16 @@ func constructRemotePreprocessInvoke(ctx context.Context, client *daemon.Client,
 		args.Args = append(args.Args, "-MP")
 	}
 	if comp.Flag.MF != "" {
-		args.Args = append(args.Args, "-MF", toRemote(comp.Flag.MF+".tmp", wd))
+		args.Args = append(args.Args, "-MF", rpath(comp.Flag.MF+".tmp"))
+	}
+	for _, mt := range comp.Flag.MT {
+		args.Args = append(args.Args, mt.Opt, mt.Target)
 	}
 	args.Args = append(args.Args, comp.UnknownArgs...)
+	args.Args = append(args.Args, remotePrefixMaps(comp, rpath)...)
+	if cfg.reproducible(comp) {
+		args.Args = reproducibleCommand(args.Args, toRemote(".", wd), wd, comp)
+	}
 	if cfg.Verbose {
 		log.Printf("[llamacc] compiling remotely: %#v", args)
 	}
@@ -188,17 +339,32 @@ func buildLocalPreprocess(ctx context.Context, client *daemon.Client, cfg *Confi
 		return fmt.Errorf("find %s: %w", comp.LocalCompiler(cfg), err)
 	}
 
+	// The assembler doesn't understand the output of
+	// -fdirectives-only, so fully preprocess assembly.
+	directivesOnly := !cfg.FullPreprocess && !comp.IsAssembly()
+
 	var preprocessed bytes.Buffer
-	{
+	if comp.Language == LangAssembler && comp.ReadsStdin() {
+		preprocessed.Write(comp.Stdin)
+	} else if comp.Language == LangAssembler {
+		data, err := ioutil.ReadFile(comp.Input)
+		if err != nil {
+			return err
+		}
+		preprocessed.Write(data)
+	} else {
 		var preprocessor exec.Cmd
 		_, span := tracing.StartSpan(ctx, "preprocess")
 		preprocessor.Path = ccpath
 		preprocessor.Args = []string{comp.LocalCompiler(cfg)}
 		preprocessor.Args = append(preprocessor.Args, comp.LocalArgs...)
-		if !cfg.FullPreprocess {
+		if directivesOnly {
 			preprocessor.Args = append(preprocessor.Args, "-fdirectives-only")
 		}
 		preprocessor.Args = append(preprocessor.Args, "-E", "-o", "-", comp.Input)
+		if comp.ReadsStdin() {
+			preprocessor.Stdin = bytes.NewReader(comp.Stdin)
+		}
 		preprocessor.Stdout = &preprocessed
 		preprocessor.Stderr = os.Stderr
 		if cfg.Verbose {
@@ -211,82 +377,189 @@ func buildLocalPreprocess(ctx context.Context, client *daemon.Client, cfg *Confi
 	}
 
 	args := daemon.InvokeWithFilesArgs{
-		Function: cfg.Function,
-		Outputs: []files.Mapped{
-			{
-				Local:  files.LocalFile{Path: path.Join(wd, comp.Output)},
-				Remote: comp.Output,
-			},
-		},
-		Stdin: preprocessed.Bytes(),
-		Trace: tracing.PropagationFromContext(ctx),
-	}
+		Function:   cfg.Function,
+		Trace:      tracing.PropagationFromContext(ctx),
+		UseCache:   cfg.Cache,
+		Idempotent: true,
+		Cache:      cfg.CacheNamespaces,
+		Profile:    toAbs(comp.Output, wd),
+	}
+	if !comp.WritesStdout() {
+		args.Outputs = args.Outputs.Append(files.Mapped{
+			Local:  files.LocalFile{Path: filepath.Join(wd, comp.Output)},
+			Remote: comp.Output,
+		})
+	}
+	setStdin(ctx, client, cfg, &args, preprocessed.Bytes())
+	if comp.keepsObject() {
+		cfg.keepRemote(&args, args.Outputs[0].Local.Path, wd)
+	}
+	args.Outputs = args.Outputs.Append(comp.secondaryOutputs(comp.Output, wd)...)
 	args.Args = []string{comp.RemoteCompiler(cfg)}
+	args.Args = append(args.Args, cfg.TargetArgs()...)
 	args.Args = append(args.Args, comp.RemoteArgs...)
-	if !cfg.FullPreprocess {
+	for _, aux := range comp.AuxInputs {
+		args.Files = args.Files.Append(remap(aux.Path, wd))
+		args.Args = append(args.Args, aux.Opt+toRemote(aux.Path, wd))
+	}
+	if directivesOnly {
 		args.Args = append(args.Args, "-fdirectives-only", "-fpreprocessed")
 	}
+	for _, m := range comp.PrefixMaps {
+		// The preprocessor has already named every file as
+		// it was named locally
+		args.Args = append(args.Args, m.String())
+	}
 	args.Args = append(args.Args, "-x", comp.PreprocessedLanguage, "-o", comp.Output, "-")
+	if cfg.reproducible(comp) {
+		args.Args = reproducibleCommand(args.Args, ".", wd, comp)
+	}
+	args.Env = cfg.remoteEnv()
 
-	out, err := client.InvokeWithFiles(&args)
-	if err != nil {
+	var stdout io.Writer = os.Stdout
+	var obj bytes.Buffer
+	if comp.WritesStdout() {
+		stdout = &obj
+	}
+	if _, err = invokeRemote(client, cfg, &args, stdout); err != nil {
 		return err
 	}
-	os.Stdout.Write(out.Stdout)
-	os.Stderr.Write(out.Stderr)
-	if out.InvokeErr != "" {
-		return fmt.Errorf("invoke: %s", out.InvokeErr)
+	if _, err := os.Stdout.Write(obj.Bytes()); err != nil {
+		return err
 	}
-	if out.ExitStatus != 0 {
-		return fmt.Errorf("invoke: exit %d", out.ExitStatus)
+	if cfg.shouldVerify(comp) {
+		return verifyLocally(cfg, comp)
 	}
-
 	return nil
 }
 
 func checkSupported(cfg *Config, comp *Compilation) error {
-	if (comp.Language == LangAssembler || comp.Language == LangAssemblerWithCpp) &&
-		!cfg.RemoteAssemble {
+	if comp.IsAssembly() && !cfg.RemoteAssemble {
 		return errors.New("Assembly requested, and LLAMACC_REMOTE_ASSEMBLE unset")
 	}
-	return nil
+	if comp.MSVC && cfg.LocalPreprocess {
+		return errors.New("cl.exe arguments given, and LLAMACC_LOCAL_PREPROCESS set")
+	}
+	if comp.IsPCH() && cfg.LocalPreprocess {
+		return errors.New("Precompiled header requested, and LLAMACC_LOCAL_PREPROCESS set")
+	}
+	if err := checkSecondarySupported(cfg, comp); err != nil {
+		return err
+	}
+	if err := checkAnalyzeSupported(cfg, comp); err != nil {
+		return err
+	}
+	if err := checkStdioSupported(cfg, comp); err != nil {
+		return err
+	}
+	if err := checkFortranSupported(cfg, comp); err != nil {
+		return err
+	}
+	return checkModulesSupported(cfg, comp)
 }
 
 func main() {
-	cfg := ParseConfig(os.Environ())
+	if err := logging.Setup("", "llamacc"); err != nil {
+		fmt.Fprintf(os.Stderr, "[llamacc] %s\n", err.Error())
+	}
+	cfg := ParseConfig(append(projectEnv(), os.Environ()...))
 	var err error
-	var comp Compilation
+	var run func() error
+	// For LLAMACC_EXPLAIN
+	mode := "compile"
+	var parsed *Compilation
 	if cfg.Local {
 		err = errors.New("LLAMACC_LOCAL set")
 	}
-	if err == nil {
-		comp, err = ParseCompile(&cfg, os.Args)
+	nvcc := cfg.IsNVCC(os.Args[0])
+	if err == nil && nvcc {
+		mode = "nvcc"
+		if cfg.RemoteCUDA {
+			var cu CUDACompilation
+			cu, err = ParseNVCC(os.Args)
+			run = func() error { return runLlamaNVCC(&cfg, &cu) }
+		} else {
+			err = errors.New("LLAMACC_REMOTE_CUDA not set")
+		}
 	}
-	if err == nil {
-		err = checkSupported(&cfg, &comp)
+	tool := cfg.Archiver(os.Args[0])
+	if err == nil && tool != "" {
+		mode = tool
+		var arc Archive
+		arc, err = ParseArchive(tool, os.Args)
+		run = func() error { return runLlamaArchive(&cfg, &arc) }
+	}
+	if err == nil && run == nil && cfg.RemoteLink && !cfg.IsCl(os.Args[0]) {
+		if link, lerr := ParseLink(&cfg, os.Args); lerr == nil {
+			mode = "link"
+			run = func() error { return runLlamaLink(&cfg, &link) }
+		}
+	}
+	if err == nil && run == nil {
+		var comp Compilation
+		if cfg.IsCl(os.Args[0]) {
+			comp, err = ParseCompileCL(&cfg, os.Args)
+		} else {
+			comp, err = ParseCompile(&cfg, os.Args)
+		}
+		if err == nil {
+			parsed = &comp
+			err = checkSupported(&cfg, &comp)
+		}
+		if parsed != nil && !cfg.Explain {
+			// We need the source whether we compile
+			// remotely or not
+			if rerr := comp.readStdin(); rerr != nil {
+				fmt.Fprintf(os.Stderr, "[llamacc] reading stdin: %s\n", rerr.Error())
+				os.Exit(1)
+			}
+		}
+		run = func() error { return runLlamaCC(&cfg, &comp) }
+	}
+	if cfg.Explain {
+		if err := explain(os.Stderr, &cfg, os.Args, mode, parsed, err); err != nil {
+			fmt.Fprintf(os.Stderr, "[llamacc] explain: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 	if err == nil {
-		err = runLlamaCC(&cfg, &comp)
-		if err != nil {
+		err = run()
+		var ie *invokeError
+		if cfg.Fallback && errors.As(err, &ie) {
+			fmt.Fprintf(os.Stderr, "[llamacc] remote compilation failed, falling back to local: %s\n", err.Error())
+		} else if err != nil {
 			if ex, ok := err.(*exec.ExitError); ok {
 				os.Exit(ex.ExitCode())
 			}
 			fmt.Fprintf(os.Stderr, "Running llamacc: %s\n", err.Error())
 			os.Exit(1)
+		} else {
+			os.Exit(0)
 		}
-		os.Exit(0)
 	}
 	if cfg.Verbose {
 		log.Printf("[llamacc] compiling locally: %s (%q)", err.Error(), os.Args)
 	}
 
-	cc := cfg.LocalCC
-	if strings.HasSuffix(os.Args[0], "cxx") || strings.HasSuffix(os.Args[0], "c++") {
-		cc = cfg.LocalCXX
+	cc := localCompiler(&cfg, os.Args[0])
+
+	if err := materializeArgs(&cfg, os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "[llamacc] materializing remote objects: %s\n", err.Error())
 	}
 
-	cmd := exec.Command(cc, os.Args[1:]...)
+	cmd := exec.Command(cc, cfg.withSysroot(os.Args)[1:]...)
+	if nvcc && !cfg.Local {
+		// Even when we can't run nvcc remotely, we can
+		// still send it host compilations
+		if err := offloadHostCompiler(&cfg, cmd); err != nil && cfg.Verbose {
+			log.Printf("[llamacc] running nvcc without llamacc as its host compiler: %s", err.Error())
+		}
+	}
 	cmd.Stdin = os.Stdin
+	if parsed != nil && parsed.Stdin != nil {
+		cmd.Stdin = bytes.NewReader(parsed.Stdin)
+	}
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	if err := cmd.Run(); err != nil {
@@ -297,3 +570,23 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// localCompiler returns the compiler or tool to run locally, for a
+// command we were invoked as argv0
+func localCompiler(cfg *Config, argv0 string) string {
+	switch {
+	case cfg.IsNVCC(argv0):
+		return cfg.LocalNVCC
+	case cfg.Archiver(argv0) == "ar":
+		return cfg.LocalAR
+	case cfg.Archiver(argv0) == "ranlib":
+		return cfg.LocalRanlib
+	case cfg.IsCl(argv0):
+		return cfg.LocalCL
+	case cfg.IsCxx(argv0):
+		return cfg.LocalCXX
+	case cfg.IsFortran(argv0):
+		return cfg.LocalFC
+	}
+	return cfg.LocalCC
+}
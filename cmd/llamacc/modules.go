@@ -0,0 +1,260 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// C++20 modules
+//
+// Compiling a module interface unit produces, besides its object
+// file, a binary module interface (BMI) which every translation unit
+// importing the module reads. We fetch the BMIs that remote
+// compilations produce, and upload the BMIs of the modules a
+// translation unit imports along with it.
+//
+// GCC (with -fmodules-ts) writes the BMI for module M to
+// `gcm.cache/M.gcm`, relative to the compiler's working directory, and
+// looks for imported modules in the same place. Clang writes a BMI
+// when asked to with `--precompile` or `-fmodule-output`, and is told
+// where to find imported modules with `-fmodule-file=` or
+// `-fprebuilt-module-path=`.
+
+// ModuleFlags records the options that configure modules
+type ModuleFlags struct {
+	// -fmodules-ts was given; we're using GCC's modules
+	GCC bool
+	// Clang's --precompile: produce only the BMI, as the output
+	Precompile bool
+	// Clang's -fmodule-output, which writes the BMI alongside the
+	// object file
+	Output string
+	// Clang's -fmodule-file=[NAME=]PATH options
+	Files []ModuleFile
+	// Clang's -fprebuilt-module-path= options
+	PrebuiltPaths []string
+}
+
+type ModuleFile struct {
+	// The name of the module, if given
+	Name string
+	Path string
+}
+
+func (f ModuleFile) String() string {
+	if f.Name == "" {
+		return f.Path
+	}
+	return f.Name + "=" + f.Path
+}
+
+// ModuleUnit describes the module declarations in a translation
+// unit's source
+type ModuleUnit struct {
+	// The module, or module partition, whose interface this unit
+	// provides, if any
+	Name string
+	// The modules and partitions this unit imports
+	Imports []string
+}
+
+var (
+	moduleDecl = regexp.MustCompile(`(?m)^[ \t]*(export[ \t]+)?module[ \t]+([\w.]+)(:[\w.]+)?[ \t]*;`)
+	importDecl = regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?import[ \t]+([\w.]+|:[\w.]+|<[^>\n]*>|"[^"\n]*")[ \t]*;`)
+)
+
+var errHeaderUnits = errors.New("header units are not supported remotely")
+
+// scanModuleUnit finds the module declarations in `src`. Like the
+// include scanner, it looks at the source as written, without
+// preprocessing it, so declarations produced by macros or hidden by
+// conditionals will confuse it.
+func scanModuleUnit(src []byte) (ModuleUnit, error) {
+	var unit ModuleUnit
+	var module string
+	if m := moduleDecl.FindSubmatch(src); m != nil {
+		module = string(m[2])
+		partition := string(m[3])
+		switch {
+		case partition != "":
+			// Partitions, whether or not they are
+			// exported, have interfaces of their own
+			unit.Name = module + partition
+		case len(m[1]) > 0:
+			unit.Name = module
+		default:
+			// An implementation unit implicitly imports
+			// its module's interface
+			unit.Imports = append(unit.Imports, module)
+		}
+	}
+	for _, m := range importDecl.FindAllSubmatch(src, -1) {
+		name := string(m[1])
+		switch name[0] {
+		case '<', '"':
+			return unit, errHeaderUnits
+		case ':':
+			if module == "" {
+				return unit, fmt.Errorf("import of partition %s outside of a module", name)
+			}
+			name = module + name
+		}
+		unit.Imports = append(unit.Imports, name)
+	}
+	return unit, nil
+}
+
+// UsesModules returns true if we need to handle modules to compile
+// `c`: if modules are enabled, or it is a module interface unit.
+func (c *Compilation) UsesModules() bool {
+	m := &c.Modules
+	return m.GCC || m.Precompile || m.Output != "" || len(m.Files) > 0 ||
+		len(m.PrebuiltPaths) > 0 || c.Language == LangCxxModule
+}
+
+// scanModules scans the input for module declarations, if it uses
+// modules, and records them in c.Unit.
+func (c *Compilation) scanModules() error {
+	if !c.UsesModules() {
+		return nil
+	}
+	src, err := ioutil.ReadFile(c.Input)
+	if err != nil {
+		return err
+	}
+	c.Unit, err = scanModuleUnit(src)
+	return err
+}
+
+// driverLanguage returns the language to tell the compiler driver
+// the input is in, with `-x`. GCC doesn't understand `-x c++-module`; it
+// recognizes interface units from their contents.
+func (c *Compilation) driverLanguage() string {
+	if c.Language == LangCxxModule && c.Modules.GCC {
+		return string(LangCxx)
+	}
+	return string(c.Language)
+}
+
+// gcmFile returns the path, relative to the compiler's working
+// directory, of the BMI GCC uses for `module`.
+func gcmFile(module string) string {
+	return path.Join("gcm.cache", strings.Replace(module, ":", "-", 1)+".gcm")
+}
+
+// pcmFile returns the name of the BMI clang looks for in a
+// -fprebuilt-module-path directory for `module`.
+func pcmFile(module string) string {
+	return strings.Replace(module, ":", "-", 1) + ".pcm"
+}
+
+// addModules adds the BMIs `comp` produces and consumes to `args`,
+// and returns the arguments the remote compiler needs to find them.
+// `cwd` maps a path relative to our working directory to the path
+// relative to the remote compiler's working directory, and `rpath`
+// maps a local path to how the remote compiler should name it.
+func addModules(args *daemon.InvokeWithFilesArgs, comp *Compilation, wd string, cwd func(string) string, rpath func(string) string) []string {
+	m := &comp.Modules
+	var out []string
+	if m.GCC {
+		out = append(out, "-fmodules-ts")
+		bmi := func(module string) files.Mapped {
+			local := gcmFile(module)
+			return files.Mapped{
+				Local:  files.LocalFile{Path: filepath.Join(wd, local)},
+				Remote: cwd(local),
+			}
+		}
+		if comp.Unit.Name != "" {
+			args.Outputs = args.Outputs.Append(bmi(comp.Unit.Name))
+		}
+		for _, imp := range comp.Unit.Imports {
+			if f := bmi(imp); isFile(f.Local.Path) {
+				args.Files = args.Files.Append(f)
+			}
+		}
+		return out
+	}
+
+	if m.Precompile {
+		out = append(out, "--precompile")
+	}
+	if m.Output != "" {
+		args.Outputs = args.Outputs.Append(remap(m.Output, wd))
+		out = append(out, "-fmodule-output="+rpath(m.Output))
+	}
+	for _, f := range m.Files {
+		args.Files = args.Files.Append(remap(f.Path, wd))
+		out = append(out, "-fmodule-file="+ModuleFile{f.Name, rpath(f.Path)}.String())
+	}
+	for _, dir := range m.PrebuiltPaths {
+		out = append(out, "-fprebuilt-module-path="+rpath(dir))
+		for _, imp := range comp.Unit.Imports {
+			if p := filepath.Join(dir, pcmFile(imp)); isFile(p) {
+				args.Files = args.Files.Append(remap(p, wd))
+			}
+		}
+	}
+	return out
+}
+
+func moduleFileArg(c *Compilation, arg string) (filterWhere, error) {
+	f := ModuleFile{Path: arg}
+	if eq := strings.IndexByte(arg, '='); eq >= 0 {
+		f = ModuleFile{Name: arg[:eq], Path: arg[eq+1:]}
+	}
+	c.Modules.Files = append(c.Modules.Files, f)
+	return filterRemote, nil
+}
+
+// Module mappers and header units name files the compiler reads in
+// ways we can't follow.
+func unsupportedModuleArg(opt string) argSpec {
+	return argSpec{opt, func(c *Compilation, _ string) (filterWhere, error) {
+		return 0, fmt.Errorf("%s: not supported remotely", opt)
+	}, false}
+}
+
+// checkModulesSupported returns an error if we can't compile `comp`
+// remotely because of the modules it uses.
+func checkModulesSupported(cfg *Config, comp *Compilation) error {
+	if !comp.UsesModules() {
+		return nil
+	}
+	if cfg.LocalPreprocess {
+		return errors.New("C++ modules used, and LLAMACC_LOCAL_PREPROCESS set")
+	}
+	if comp.MSVC {
+		return errors.New("C++ modules are not supported with cl.exe")
+	}
+	if err := comp.scanModules(); err != nil {
+		if os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("scanning modules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanModuleUnit(t *testing.T) {
+	tests := []struct {
+		src  string
+		unit ModuleUnit
+		err  bool
+	}{
+		{"int main() {}\n", ModuleUnit{}, false},
+		{
+			"module;\n#include <vector>\nexport module geometry;\nimport std.core;\nexport import :shapes;\n",
+			ModuleUnit{Name: "geometry", Imports: []string{"std.core", "geometry:shapes"}},
+			false,
+		},
+		{
+			"export module geometry:shapes;\nimport :points;\n",
+			ModuleUnit{Name: "geometry:shapes", Imports: []string{"geometry:points"}},
+			false,
+		},
+		{
+			"module geometry;\nimport util;\n",
+			ModuleUnit{Imports: []string{"geometry", "util"}},
+			false,
+		},
+		{"import :shapes;\n", ModuleUnit{}, true},
+		{"export module m;\nimport <iostream>;\n", ModuleUnit{}, true},
+		{"import \"config.h\";\n", ModuleUnit{}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.src, func(t *testing.T) {
+			got, err := scanModuleUnit([]byte(tc.src))
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.unit, got)
+		})
+	}
+}
+
+func TestParseCompileModules(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{
+		"g++", "-std=c++20", "-fmodules-ts", "-c", "geometry.cc", "-o", "geometry.o",
+	})
+	require.NoError(t, err)
+	assert.True(t, comp.Modules.GCC)
+	assert.True(t, comp.UsesModules())
+	assert.NotContains(t, comp.UnknownArgs, "-fmodules-ts")
+
+	comp, err = ParseCompile(&DefaultConfig, []string{
+		"clang++", "-std=c++20", "--precompile", "geometry.cppm",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, LangCxxModule, comp.Language)
+	assert.True(t, comp.Modules.Precompile)
+	assert.Equal(t, "geometry.pcm", comp.Output)
+
+	comp, err = ParseCompile(&DefaultConfig, []string{
+		"clang++", "-std=c++20", "-fmodule-output", "-fmodule-file=util=build/util.pcm",
+		"-fprebuilt-module-path=build", "-c", "geometry.cppm", "-o", "out/geometry.o",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "out/geometry.pcm", comp.Modules.Output)
+	assert.Equal(t, []ModuleFile{{Name: "util", Path: "build/util.pcm"}}, comp.Modules.Files)
+	assert.Equal(t, []string{"build"}, comp.Modules.PrebuiltPaths)
+
+	for _, bad := range []string{"-fmodule-mapper=map.txt", "-fmodule-header"} {
+		_, err = ParseCompile(&DefaultConfig, []string{"g++", "-fmodules-ts", bad, "-c", "geometry.cc"})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestDriverLanguage(t *testing.T) {
+	c := Compilation{Language: LangCxxModule}
+	assert.Equal(t, "c++-module", c.driverLanguage())
+	c.Modules.GCC = true
+	assert.Equal(t, "c++", c.driverLanguage())
+}
+
+func TestAddModulesGCC(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix paths")
+	}
+	dir, err := ioutil.TempDir("", "llamacc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "gcm.cache"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "gcm.cache", "geometry-points.gcm"), nil, 0644))
+
+	comp := Compilation{
+		Modules: ModuleFlags{GCC: true},
+		Unit:    ModuleUnit{Name: "geometry:shapes", Imports: []string{"geometry:points", "std.core"}},
+	}
+	var args daemon.InvokeWithFilesArgs
+	out := addModules(&args, &comp, dir, func(p string) string { return p }, nil)
+	assert.Equal(t, []string{"-fmodules-ts"}, out)
+	assert.Equal(t, files.List{{
+		Local:  files.LocalFile{Path: filepath.Join(dir, "gcm.cache/geometry-shapes.gcm")},
+		Remote: "gcm.cache/geometry-shapes.gcm",
+	}}, args.Outputs)
+	assert.Equal(t, files.List{{
+		Local:  files.LocalFile{Path: filepath.Join(dir, "gcm.cache/geometry-points.gcm")},
+		Remote: "gcm.cache/geometry-points.gcm",
+	}}, args.Files)
+}
+
+func TestAddModulesClang(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix paths")
+	}
+	dir, err := ioutil.TempDir("", "llamacc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "util.pcm"), nil, 0644))
+
+	comp := Compilation{
+		Modules: ModuleFlags{
+			Output:        "geometry.pcm",
+			PrebuiltPaths: []string{dir},
+		},
+		Unit: ModuleUnit{Name: "geometry", Imports: []string{"util", "missing"}},
+	}
+	var args daemon.InvokeWithFilesArgs
+	rpath := func(p string) string { return toRemote(p, "/src") }
+	out := addModules(&args, &comp, "/src", nil, rpath)
+	assert.Equal(t, []string{
+		"-fmodule-output=_root/src/geometry.pcm",
+		"-fprebuilt-module-path=_root" + dir,
+	}, out)
+	assert.Equal(t, files.List{remap("geometry.pcm", "/src")}, args.Outputs)
+	assert.Equal(t, files.List{remap(filepath.Join(dir, "util.pcm"), "/src")}, args.Files)
+}
@@ -0,0 +1,417 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// This file supports the argument dialect of MSVC's `cl.exe`, as
+// accepted by `clang-cl`. We compile with clang-cl on the remote
+// side; there is no way to run cl.exe itself on Lambda.
+
+type clArgKind int
+
+const (
+	// The option takes no value, and must match exactly
+	clFlag clArgKind = iota
+	// The value is appended to the option, as in `/Fofoo.obj`
+	clJoined
+	// The value is appended to the option or is the next
+	// argument, as in `/I inc` or `/Iinc`
+	clJoinedOrSeparate
+)
+
+type clArgSpec struct {
+	name   string
+	kind   clArgKind
+	action func(c *Compilation, val string) error
+}
+
+func clUnsupported(what string) func(c *Compilation, val string) error {
+	return func(c *Compilation, val string) error {
+		return fmt.Errorf("%s is not supported remotely", what)
+	}
+}
+
+func clIncludeArg(name string) clArgSpec {
+	return clArgSpec{name, clJoinedOrSeparate, func(c *Compilation, val string) error {
+		c.Includes = append(c.Includes, Include{"-" + name, val})
+		return nil
+	}}
+}
+
+func clDefArg(name string) clArgSpec {
+	return clArgSpec{name, clJoinedOrSeparate, func(c *Compilation, val string) error {
+		c.Defs = append(c.Defs, Def{"-" + name, val})
+		return nil
+	}}
+}
+
+// clArgSpecs are matched in order against options with their `/` or
+// `-` removed.
+var clArgSpecs = []clArgSpec{
+	{"c", clFlag, func(c *Compilation, _ string) error {
+		c.Flag.C = true
+		return nil
+	}},
+	{"TC", clFlag, func(c *Compilation, _ string) error {
+		c.Language = LangC
+		return nil
+	}},
+	{"TP", clFlag, func(c *Compilation, _ string) error {
+		c.Language = LangCxx
+		return nil
+	}},
+	{"Fo", clJoined, func(c *Compilation, val string) error {
+		if c.Output != "" {
+			return fmt.Errorf("multiple outputs: %s, %s", c.Output, val)
+		}
+		c.Output = val
+		return nil
+	}},
+	{"showIncludes", clJoined, func(c *Compilation, val string) error {
+		// val may be `:user`
+		c.Flag.ShowIncludes = "-showIncludes" + val
+		return nil
+	}},
+	{"E", clFlag, clUnsupported("/E")},
+	{"EP", clFlag, clUnsupported("/EP")},
+	{"P", clFlag, clUnsupported("/P")},
+	{"Zs", clFlag, clUnsupported("/Zs")},
+	{"link", clFlag, clUnsupported("/link")},
+	{"Yc", clJoined, clUnsupported("Creating a precompiled header")},
+	{"Yu", clJoined, clUnsupported("Using a precompiled header")},
+	{"FA", clJoined, clUnsupported("An assembly listing")},
+	{"Fa", clJoined, clUnsupported("An assembly listing")},
+	{"analyze", clJoined, clUnsupported("/analyze")},
+	clIncludeArg("imsvc"),
+	clIncludeArg("I"),
+	clIncludeArg("FI"),
+	clDefArg("D"),
+	clDefArg("U"),
+}
+
+// clInput is a source file, and the language it was explicitly
+// given with `/Tc` or `/Tp`, if any
+type clInput struct {
+	path string
+	lang Lang
+}
+
+// ParseCompileCL parses `argv` as an invocation of `cl.exe` or
+// `clang-cl`, which must compile a single C or C++ source file to an
+// object file.
+func ParseCompileCL(cfg *Config, argv []string) (Compilation, error) {
+	out := Compilation{MSVC: true}
+	args, err := expandResponseFiles(argv[1:])
+	if err != nil {
+		return out, err
+	}
+
+	var inputs []clInput
+	i := 0
+args:
+	for i < len(args) {
+		arg := args[i]
+		i++
+		// On Unix, an absolute path looks like an option
+		isOpt := (strings.HasPrefix(arg, "/") && !(filepath.IsAbs(arg) && isFile(arg))) ||
+			strings.HasPrefix(arg, "-")
+		if !isOpt {
+			if smellsLikeInput(arg) {
+				inputs = append(inputs, clInput{path: arg})
+			} else {
+				out.UnknownArgs = append(out.UnknownArgs, arg)
+			}
+			continue
+		}
+		name := arg[1:]
+		// /Tc and /Tp name an input file of a given language
+		for _, ti := range []struct {
+			opt  string
+			lang Lang
+		}{{"Tc", LangC}, {"Tp", LangCxx}} {
+			if !strings.HasPrefix(name, ti.opt) {
+				continue
+			}
+			path := name[len(ti.opt):]
+			if path == "" {
+				if i == len(args) {
+					return out, fmt.Errorf("%s: expected arg", arg)
+				}
+				path = args[i]
+				i++
+			}
+			inputs = append(inputs, clInput{path, ti.lang})
+			continue args
+		}
+		for _, spec := range clArgSpecs {
+			var val string
+			switch spec.kind {
+			case clFlag:
+				if name != spec.name {
+					continue
+				}
+			case clJoined, clJoinedOrSeparate:
+				if !strings.HasPrefix(name, spec.name) {
+					continue
+				}
+				val = name[len(spec.name):]
+				if spec.name == "Fo" && strings.HasPrefix(val, ":") {
+					// `/Fo: foo.obj`
+					val = val[1:]
+					if val == "" && i < len(args) {
+						val = args[i]
+						i++
+					}
+				}
+				if spec.kind == clJoinedOrSeparate && val == "" {
+					if i == len(args) {
+						return out, fmt.Errorf("%s: expected arg", arg)
+					}
+					val = args[i]
+					i++
+				}
+			}
+			if err := spec.action(&out, val); err != nil {
+				return out, err
+			}
+			continue args
+		}
+		out.UnknownArgs = append(out.UnknownArgs, arg)
+	}
+
+	if len(inputs) == 0 {
+		return out, errors.New("no supported input detected")
+	}
+	if len(inputs) > 1 {
+		return out, fmt.Errorf("multiple inputs given: %s, %s", inputs[0].path, inputs[1].path)
+	}
+	out.Input = inputs[0].path
+	if inputs[0].lang != "" {
+		out.Language = inputs[0].lang
+	}
+	if out.Language == "" {
+		// cl compiles `.c` files as C, and everything else as
+		// C++
+		switch extLangs[filepath.Ext(out.Input)] {
+		case LangC:
+			out.Language = LangC
+		case LangCxx:
+			out.Language = LangCxx
+		default:
+			return out, fmt.Errorf("Unsupported extension: %s", out.Input)
+		}
+	}
+	if !out.Flag.C {
+		return out, errors.New("/c not detected")
+	}
+	obj := replaceExt(filepath.Base(out.Input), ".obj")
+	if out.Output == "" {
+		out.Output = obj
+	} else if strings.HasSuffix(out.Output, "/") || strings.HasSuffix(out.Output, `\`) {
+		// `/Fo` may name a directory
+		out.Output = out.Output + obj
+	}
+	out.Includes = append(out.Includes, cfg.MSVCIncludes()...)
+	return out, nil
+}
+
+// clInputArg returns the argument that passes `input` to clang-cl
+// as a source file in `comp`'s language
+func clInputArg(comp *Compilation, input string) string {
+	if comp.Language == LangC {
+		return "-Tc" + input
+	}
+	return "-Tp" + input
+}
+
+// runShowIncludes runs the local compiler's preprocessor over comp's
+// input with `/showIncludes`, and returns the headers it reports.
+func runShowIncludes(cfg *Config, ccpath string, comp *Compilation) ([]string, error) {
+	var preprocessor exec.Cmd
+	preprocessor.Path = ccpath
+	preprocessor.Args = []string{comp.LocalCompiler(cfg)}
+	preprocessor.Args = append(preprocessor.Args, comp.UnknownArgs...)
+	for _, def := range comp.Defs {
+		preprocessor.Args = append(preprocessor.Args, def.Opt+def.Def)
+	}
+	for _, inc := range comp.Includes {
+		preprocessor.Args = append(preprocessor.Args, inc.Opt, inc.Path)
+	}
+	preprocessor.Args = append(preprocessor.Args, "-E", "-showIncludes", clInputArg(comp, comp.Input))
+	var notes bytes.Buffer
+	preprocessor.Stdout = ioutil.Discard
+	preprocessor.Stderr = &notes
+	if cfg.Verbose {
+		log.Printf("run cl /showIncludes: %q", preprocessor.Args)
+	}
+	err := preprocessor.Run()
+	deps, other := parseShowIncludes(notes.Bytes())
+	os.Stderr.Write(other)
+	if err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+const showIncludesNote = "Note: including file:"
+
+// parseShowIncludes returns the files named by the `/showIncludes`
+// notes in `buf`, once each, and the rest of its lines.
+func parseShowIncludes(buf []byte) ([]string, []byte) {
+	var deps []string
+	var other []byte
+	seen := make(map[string]struct{})
+	for len(buf) > 0 {
+		var line []byte
+		if nl := bytes.IndexByte(buf, '\n'); nl >= 0 {
+			line, buf = buf[:nl+1], buf[nl+1:]
+		} else {
+			line, buf = buf, nil
+		}
+		if !bytes.HasPrefix(line, []byte(showIncludesNote)) {
+			other = append(other, line...)
+			continue
+		}
+		// Nested includes are indented
+		dep := strings.TrimSpace(string(line[len(showIncludesNote):]))
+		if _, ok := seen[dep]; ok || dep == "" {
+			continue
+		}
+		seen[dep] = struct{}{}
+		deps = append(deps, dep)
+	}
+	return deps, other
+}
+
+// localPath returns the local path of `remote`, a path under
+// `_root` as created by toRemote.
+func localPath(remote string, windows bool) string {
+	rel := strings.TrimPrefix(strings.ReplaceAll(remote, `\`, "/"), "_root/")
+	if len(rel) == len(remote) {
+		return remote
+	}
+	if windows && (len(rel) == 1 || (len(rel) > 1 && rel[1] == '/')) {
+		return filepath.FromSlash(strings.ToUpper(rel[:1]) + ":/" + strings.TrimPrefix(rel[1:], "/"))
+	}
+	return "/" + rel
+}
+
+// showIncludesWriter rewrites the paths in the `/showIncludes` notes
+// the remote compiler prints to the local paths of the same files,
+// so that build systems like Ninja can track them.
+type showIncludesWriter struct {
+	w       io.Writer
+	windows bool
+	buf     []byte
+}
+
+func (s *showIncludesWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for {
+		nl := bytes.IndexByte(s.buf, '\n')
+		if nl < 0 {
+			return len(p), nil
+		}
+		if err := s.writeLine(s.buf[:nl+1]); err != nil {
+			return 0, err
+		}
+		s.buf = s.buf[nl+1:]
+	}
+}
+
+func (s *showIncludesWriter) writeLine(line []byte) error {
+	if bytes.HasPrefix(line, []byte(showIncludesNote)) {
+		rest := string(line[len(showIncludesNote):])
+		path := strings.TrimLeft(rest, " ")
+		indent := rest[:len(rest)-len(path)]
+		end := strings.TrimRight(path, "\r\n")
+		newline := path[len(end):]
+		line = []byte(showIncludesNote + indent + localPath(end, s.windows) + newline)
+	}
+	_, err := s.w.Write(line)
+	return err
+}
+
+// Flush writes any incomplete final line
+func (s *showIncludesWriter) Flush() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	err := s.writeLine(s.buf)
+	s.buf = nil
+	return err
+}
+
+func constructRemoteCLInvoke(ctx context.Context, client *daemon.Client, cfg *Config, comp *Compilation) (*daemon.InvokeWithFilesArgs, error) {
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return nil, err
+	}
+
+	stageInputs(client, cfg, comp, wd)
+	deps, err := detectDependencies(ctx, client, cfg, comp)
+	if err != nil {
+		return nil, fmt.Errorf("Detecting dependencies: %w", err)
+	}
+
+	args := daemon.InvokeWithFilesArgs{
+		Function:      cfg.Function,
+		DropSemaphore: true,
+		UseCache:      cfg.Cache,
+		Idempotent:    true,
+		Cache:         cfg.CacheNamespaces,
+		Profile:       toAbs(comp.Output, wd),
+	}
+	args.Outputs = args.Outputs.Append(remap(comp.Output, wd))
+	args.Files = args.Files.Append(remap(comp.Input, wd))
+	cfg.addDependencies(&args, deps, wd)
+
+	args.Args = []string{comp.RemoteCompiler(cfg)}
+	args.Args = append(args.Args, cfg.TargetArgs()...)
+	args.Args = append(args.Args, "-I", toRemote(".", wd))
+	rpath := func(p string) string { return toRemote(p, wd) }
+	for _, inc := range comp.Includes {
+		args.Args = append(args.Args, inc.Opt, cfg.remoteInclude(inc.Path, wd, rpath))
+	}
+	for _, def := range comp.Defs {
+		args.Args = append(args.Args, def.Opt+def.Def)
+	}
+	args.Args = append(args.Args, "-c", "-Fo"+toRemote(comp.Output, wd))
+	if comp.Flag.ShowIncludes != "" {
+		args.Args = append(args.Args, comp.Flag.ShowIncludes)
+	}
+	args.Args = append(args.Args, comp.UnknownArgs...)
+	args.Args = append(args.Args, clInputArg(comp, toRemote(comp.Input, wd)))
+	if cfg.Verbose {
+		log.Printf("[llamacc] compiling remotely: %#v", args)
+	}
+	return &args, nil
+}
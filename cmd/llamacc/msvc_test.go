@@ -0,0 +1,141 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompileCL(t *testing.T) {
+	tests := []struct {
+		argv []string
+		out  Compilation
+		err  bool
+	}{
+		{
+			[]string{
+				"clang-cl", "/nologo", "/c", "/W4", "/DNDEBUG", "/I", "include", "-Iother", "/Fosrc\\foo.obj", "src\\foo.cpp",
+			},
+			Compilation{
+				MSVC:        true,
+				Language:    LangCxx,
+				Input:       "src\\foo.cpp",
+				Output:      "src\\foo.obj",
+				UnknownArgs: []string{"/nologo", "/W4"},
+				Defs:        []Def{{"-D", "NDEBUG"}},
+				Includes:    []Include{{"-I", "include"}, {"-I", "other"}},
+				Flag:        Flags{C: true},
+			},
+			false,
+		},
+		{
+			[]string{
+				"clang-cl", "/c", "/showIncludes", "/MD", "/Fo:", "obj/", "/Tchello.txt",
+			},
+			Compilation{
+				MSVC:        true,
+				Language:    LangC,
+				Input:       "hello.txt",
+				Output:      "obj/hello.obj",
+				UnknownArgs: []string{"/MD"},
+				Flag:        Flags{C: true, ShowIncludes: "-showIncludes"},
+			},
+			false,
+		},
+		{
+			[]string{"clang-cl", "/c", "/TP", "/showIncludes:user", "-FIpch.h", "hello.c"},
+			Compilation{
+				MSVC:     true,
+				Language: LangCxx,
+				Input:    "hello.c",
+				Output:   "hello.obj",
+				Includes: []Include{{"-FI", "pch.h"}},
+				Flag:     Flags{C: true, ShowIncludes: "-showIncludes:user"},
+			},
+			false,
+		},
+		{[]string{"clang-cl", "hello.c"}, Compilation{}, true},
+		{[]string{"clang-cl", "/c", "/E", "hello.c"}, Compilation{}, true},
+		{[]string{"clang-cl", "/c", "/Ycpch.h", "hello.cpp"}, Compilation{}, true},
+		{[]string{"clang-cl", "/c", "a.c", "b.c"}, Compilation{}, true},
+		{[]string{"clang-cl", "/c", "/I"}, Compilation{}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.argv[len(tc.argv)-1], func(t *testing.T) {
+			got, err := ParseCompileCL(&DefaultConfig, tc.argv)
+			if tc.err {
+				assert.Error(t, err, "%q", tc.argv)
+				return
+			}
+			require.NoError(t, err, "%q", tc.argv)
+			assert.Equal(t, tc.out, got)
+		})
+	}
+}
+
+func TestParseCompileCLAbsolutePath(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "Ufoo.c")
+	require.NoError(t, ioutil.WriteFile(src, nil, 0644))
+
+	cfg := ParseConfig([]string{"INCLUDE=/sdk/include"})
+	got, err := ParseCompileCL(&cfg, []string{"clang-cl", "/c", src})
+	require.NoError(t, err)
+	assert.Equal(t, src, got.Input)
+	assert.Equal(t, "Ufoo.obj", got.Output)
+	assert.Equal(t, []Include{{"-imsvc", "/sdk/include"}}, got.Includes)
+}
+
+func TestIsCl(t *testing.T) {
+	assert.True(t, DefaultConfig.IsCl("llamacl"))
+	assert.True(t, DefaultConfig.IsCl(`llamacc-cl.exe`))
+	assert.False(t, DefaultConfig.IsCl("llamacc"))
+	assert.False(t, DefaultConfig.IsCl("llamac++"))
+	cfg := ParseConfig([]string{"LLAMACC_DRIVER=clang-cl"})
+	assert.True(t, cfg.IsCl("llamacc"))
+	assert.False(t, cfg.IsCxx("llamac++"))
+}
+
+func TestParseShowIncludes(t *testing.T) {
+	deps, other := parseShowIncludes([]byte(
+		"Note: including file: C:\\src\\foo.h\r\n" +
+			"Note: including file:  C:\\src\\bar.h\r\n" +
+			"foo.c(3): warning: something\n" +
+			"Note: including file: C:\\src\\foo.h\n"))
+	assert.Equal(t, []string{`C:\src\foo.h`, `C:\src\bar.h`}, deps)
+	assert.Equal(t, "foo.c(3): warning: something\n", string(other))
+}
+
+func TestShowIncludesWriter(t *testing.T) {
+	for _, tc := range []struct {
+		windows bool
+		out     string
+	}{
+		{false, "foo.c\nNote: including file: /c/src/foo.h\nNote: including file:  /usr/include/x.h\r\n"},
+		{true, "foo.c\nNote: including file: C:/src/foo.h\nNote: including file:  /usr/include/x.h\r\n"},
+	} {
+		var buf bytes.Buffer
+		w := &showIncludesWriter{w: &buf, windows: tc.windows}
+		w.Write([]byte("foo.c\nNote: including file: _root/c/src/foo.h\nNote: incl"))
+		w.Write([]byte("uding file:  _root/usr/include/x.h\r\n"))
+		require.NoError(t, w.Flush())
+		assert.Equal(t, tc.out, buf.String())
+	}
+}
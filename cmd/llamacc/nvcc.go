@@ -0,0 +1,361 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/tracing"
+)
+
+// nvcc runs the host compiler itself, for preprocessing and for the
+// host half of each `.cu` file. By default, we run nvcc locally with
+// llamacc as its host compiler, so the host compilations, which are
+// the bulk of the work, go to Lambda, while device compilation stays
+// local. If the function's image has the CUDA toolkit, we can instead
+// run simple compilations there whole.
+
+// A CUDACompilation is an invocation of nvcc which compiles a single
+// `.cu` file, without linking.
+type CUDACompilation struct {
+	// The option selecting what to produce: `-c`, `-dc`, `-ptx`,
+	// `-cubin`, or `-fatbin`
+	Mode     string
+	Input    string
+	Output   string
+	Includes []Include
+	// The host compiler given with -ccbin, if any
+	HostCompiler string
+	// Everything else, passed through unchanged
+	Args []string
+}
+
+// nvccModes maps the options selecting a compilation phase we
+// support to the extension of the output they produce by default
+var nvccModes = map[string]string{
+	"-c":         ".o",
+	"--compile":  ".o",
+	"-dc":        ".o",
+	"--device-c": ".o",
+	"-ptx":       ".ptx",
+	"--ptx":      ".ptx",
+	"-cubin":     ".cubin",
+	"--cubin":    ".cubin",
+	"-fatbin":    ".fatbin",
+	"--fatbin":   ".fatbin",
+	"-dw":        ".o",
+	"--device-w": ".o",
+}
+
+// nvccValueArgs are the options we pass through that take a value,
+// which may be the next argument
+var nvccValueArgs = map[string]bool{
+	"-Xcompiler": true, "--compiler-options": true,
+	"-Xptxas": true, "--ptxas-options": true,
+	"-Xfatbin": true, "--fatbin-options": true,
+	"-Xnvlink": true, "--nvlink-options": true,
+	"-Xlinker": true, "--linker-options": true,
+	"-arch": true, "--gpu-architecture": true,
+	"-code": true, "--gpu-code": true,
+	"-gencode": true, "--generate-code": true,
+	"-std": true, "--std": true,
+	"-x": true, "--x": true,
+	"-D": true, "--define-macro": true,
+	"-U": true, "--undefine-macro": true,
+	"-rdc": true, "--relocatable-device-code": true,
+	"-maxrregcount": true, "--maxrregcount": true,
+	"-t": true, "--threads": true,
+	"-m": true, "--machine": true,
+	"-default-stream": true, "--default-stream": true,
+	"-diag-suppress": true, "--diag-suppress": true,
+	"-diag-warn": true, "--diag-warn": true,
+	"-diag-error": true, "--diag-error": true,
+	"-Werror": true, "--Werror": true,
+}
+
+// nvccUnsupported are the options which produce outputs other than
+// the one we know about, read inputs we wouldn't upload, or link
+var nvccUnsupported = map[string]bool{
+	"-M": true, "-MM": true, "-MD": true, "-MMD": true,
+	"-MF": true, "-MT": true, "-MP": true,
+	"--generate-dependencies":                        true,
+	"--generate-nonsystem-dependencies":              true,
+	"--generate-dependencies-with-compile":           true,
+	"--generate-nonsystem-dependencies-with-compile": true,
+	"--dependency-output":                            true,
+	"--dependency-target-name":                       true,
+	"-run":                                           true, "--run": true,
+	"-lib": true, "--lib": true,
+	"-dlink": true, "--device-link": true,
+	"-link": true, "--link": true,
+	"-keep": true, "--keep": true,
+	"-keep-dir": true, "--keep-dir": true,
+	"-odir": true, "--output-directory": true,
+	"-save-temps": true, "--save-temps": true,
+	"-optf": true, "--options-file": true,
+	"-L": true, "--library-path": true,
+	"-l": true, "--library": true,
+	"-E": true, "--preprocess": true,
+	"-dryrun": true, "--dryrun": true,
+}
+
+// ParseNVCC parses `argv` as an invocation of nvcc. Only invocations
+// which compile a single `.cu` file to a single output are supported.
+func ParseNVCC(argv []string) (CUDACompilation, error) {
+	var out CUDACompilation
+	args := argv[1:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		opt, val, hasVal := arg, "", false
+		if eq := strings.IndexByte(arg, '='); eq > 0 && strings.HasPrefix(arg, "-") {
+			opt, val, hasVal = arg[:eq], arg[eq+1:], true
+		}
+		value := func() (string, error) {
+			if hasVal {
+				return val, nil
+			}
+			if i+1 == len(args) {
+				return "", fmt.Errorf("missing argument to %s", opt)
+			}
+			i++
+			return args[i], nil
+		}
+		var err error
+		switch {
+		case opt == "-o" || opt == "--output-file":
+			out.Output, err = value()
+		case opt == "-ccbin" || opt == "--compiler-bindir":
+			out.HostCompiler, err = value()
+		case opt == "-I" || opt == "--include-path":
+			var dir string
+			dir, err = value()
+			out.Includes = append(out.Includes, Include{"-I", dir})
+		case opt == "-isystem" || opt == "--system-include":
+			var dir string
+			dir, err = value()
+			out.Includes = append(out.Includes, Include{"-isystem", dir})
+		case opt == "-include" || opt == "--pre-include":
+			var file string
+			file, err = value()
+			out.Includes = append(out.Includes, Include{"-include", file})
+		case nvccUnsupported[opt]:
+			return out, fmt.Errorf("unsupported option: %s", arg)
+		case nvccModes[opt] != "":
+			if out.Mode != "" {
+				return out, fmt.Errorf("multiple phases: %s, %s", out.Mode, arg)
+			}
+			out.Mode = opt
+		case nvccValueArgs[opt]:
+			if hasVal {
+				out.Args = append(out.Args, arg)
+			} else {
+				var v string
+				v, err = value()
+				out.Args = append(out.Args, opt, v)
+			}
+		case strings.HasPrefix(arg, "-I"):
+			out.Includes = append(out.Includes, Include{"-I", arg[2:]})
+		case strings.HasPrefix(arg, "-"):
+			out.Args = append(out.Args, arg)
+		case out.Input != "":
+			return out, fmt.Errorf("multiple inputs: %s, %s", out.Input, arg)
+		default:
+			out.Input = arg
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+	if out.Mode == "" {
+		return out, errors.New("not compiling (no -c or equivalent)")
+	}
+	if out.Input == "" {
+		return out, errors.New("no input file")
+	}
+	if filepath.Ext(out.Input) != ".cu" {
+		return out, fmt.Errorf("not a CUDA source: %s", out.Input)
+	}
+	if out.Output == "" {
+		out.Output = replaceExt(filepath.Base(out.Input), nvccModes[out.Mode])
+	}
+	return out, nil
+}
+
+// hostCompiler returns the host compiler nvcc runs locally
+func (cu *CUDACompilation) hostCompiler(cfg *Config) string {
+	if cu.HostCompiler != "" {
+		return cu.HostCompiler
+	}
+	return cfg.LocalCXX
+}
+
+// offloadHostCompiler rewrites `cmd`, which runs the local nvcc, to
+// use llamacc as its host compiler, in turn running the compiler nvcc
+// would have used. It returns an error, leaving `cmd` alone, if it
+// can't.
+func offloadHostCompiler(cfg *Config, cmd *exec.Cmd) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	host := cfg.LocalCXX
+	var args []string
+	for i := 1; i < len(cmd.Args); i++ {
+		arg := cmd.Args[i]
+		switch {
+		case arg == "-ccbin" || arg == "--compiler-bindir":
+			if i+1 == len(cmd.Args) {
+				return fmt.Errorf("missing argument to %s", arg)
+			}
+			i++
+			host = cmd.Args[i]
+		case strings.HasPrefix(arg, "-ccbin=") || strings.HasPrefix(arg, "--compiler-bindir="):
+			host = arg[strings.IndexByte(arg, '=')+1:]
+		default:
+			args = append(args, arg)
+		}
+	}
+	if st, err := os.Stat(host); err == nil && st.IsDir() {
+		// nvcc picks a compiler from the directory itself
+		return fmt.Errorf("-ccbin names a directory: %s", host)
+	}
+	cmd.Args = append([]string{cmd.Args[0], "-ccbin", self}, args...)
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, "LLAMACC_DRIVER=c++", "LLAMACC_LOCAL_CXX="+host)
+	return nil
+}
+
+func runLlamaNVCC(cfg *Config, cu *CUDACompilation) error {
+	return runRemote(cfg, func(ctx context.Context, client *daemon.Client) error {
+		return buildRemoteNVCC(ctx, client, cfg, cu)
+	})
+}
+
+// detectCUDADependencies lists the headers `cu` includes, other than
+// those in the CUDA toolkit or the host compiler's system include
+// path, which the function's image has its own copies of.
+func detectCUDADependencies(ctx context.Context, client *daemon.Client, cfg *Config, cu *CUDACompilation) ([]string, error) {
+	_, span := tracing.StartSpan(ctx, "detect_dependencies")
+	defer span.End()
+
+	nvccpath, err := exec.LookPath(cfg.LocalNVCC)
+	if err != nil {
+		return nil, err
+	}
+	hostpath, err := exec.LookPath(cu.hostCompiler(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	var preprocessor exec.Cmd
+	preprocessor.Path = nvccpath
+	preprocessor.Args = []string{cfg.LocalNVCC, "-ccbin", hostpath}
+	preprocessor.Args = append(preprocessor.Args, cu.Args...)
+	for _, inc := range cu.Includes {
+		preprocessor.Args = append(preprocessor.Args, inc.Opt, inc.Path)
+	}
+	preprocessor.Args = append(preprocessor.Args, "-M", cu.Input)
+	var deps bytes.Buffer
+	preprocessor.Stdout = &deps
+	preprocessor.Stderr = os.Stderr
+	if cfg.Verbose {
+		log.Printf("run nvcc -M: %q", preprocessor.Args)
+	}
+	if err := preprocessor.Run(); err != nil {
+		return nil, err
+	}
+	deplist, err := parseMakeDeps(deps.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	includePath, err := client.GetCompilerIncludePath(&daemon.GetCompilerIncludePathArgs{
+		Compiler: hostpath,
+		Language: "c++",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(nvccpath); err == nil {
+		nvccpath = real
+	}
+	// The toolkit's headers live alongside its bin/ directory
+	toolkit := filepath.Dir(filepath.Dir(nvccpath))
+	deplist = removePaths(deplist, append(includePath.Paths, toolkit))
+
+	span.AddField("count", len(deplist))
+	return deplist, nil
+}
+
+// buildRemoteNVCC runs `cu` whole on Lambda, with the image's CUDA
+// toolkit and default host compiler.
+func buildRemoteNVCC(ctx context.Context, client *daemon.Client, cfg *Config, cu *CUDACompilation) error {
+	ctx, span := tracing.StartSpan(ctx, "nvcc")
+	defer span.End()
+
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return err
+	}
+
+	deps, err := detectCUDADependencies(ctx, client, cfg, cu)
+	if err != nil {
+		return fmt.Errorf("Detecting dependencies: %w", err)
+	}
+
+	args := daemon.InvokeWithFilesArgs{
+		Function:      cfg.Function,
+		DropSemaphore: true,
+		UseCache:      cfg.Cache,
+		Idempotent:    true,
+		Cache:         cfg.CacheNamespaces,
+		Profile:       toAbs(cu.Output, wd),
+		Trace:         tracing.PropagationFromContext(ctx),
+	}
+	args.Files = args.Files.Append(remap(cu.Input, wd))
+	cfg.addDependencies(&args, deps, wd)
+	args.Outputs = args.Outputs.Append(remap(cu.Output, wd))
+	if nvccModes[cu.Mode] == ".o" {
+		cfg.keepRemote(&args, cu.Output, wd)
+	}
+
+	rpath := func(p string) string { return toRemote(p, wd) }
+	args.Env = cfg.remoteEnv()
+	args.Args = []string{cfg.RemoteNVCC, "-I", rpath(".")}
+	for _, inc := range cu.Includes {
+		args.Args = append(args.Args, inc.Opt, cfg.remoteInclude(inc.Path, wd, rpath))
+	}
+	args.Args = append(args.Args, cu.Args...)
+	args.Args = append(args.Args, cu.Mode, "-o", rpath(cu.Output), rpath(cu.Input))
+	if cfg.Verbose {
+		log.Printf("[llamacc] compiling CUDA remotely: %#v", args)
+	}
+
+	_, err = invokeRemote(client, cfg, &args, os.Stdout)
+	return err
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNVCC(t *testing.T) {
+	tests := []struct {
+		argv []string
+		out  CUDACompilation
+		err  bool
+	}{
+		{
+			[]string{"nvcc", "-c", "-arch", "sm_80", "-Iinclude", "-I", "/opt/inc", "-O2", "kernel.cu", "-o", "out/kernel.o"},
+			CUDACompilation{
+				Mode:     "-c",
+				Input:    "kernel.cu",
+				Output:   "out/kernel.o",
+				Includes: []Include{{"-I", "include"}, {"-I", "/opt/inc"}},
+				Args:     []string{"-arch", "sm_80", "-O2"},
+			},
+			false,
+		},
+		{
+			[]string{"nvcc", "--device-c", "--compiler-bindir=/usr/bin/g++-11", "-Xcompiler", "-fPIC", "--std=c++17", "--system-include", "third_party", "src/a.cu"},
+			CUDACompilation{
+				Mode:         "--device-c",
+				Input:        "src/a.cu",
+				Output:       "a.o",
+				Includes:     []Include{{"-isystem", "third_party"}},
+				HostCompiler: "/usr/bin/g++-11",
+				Args:         []string{"-Xcompiler", "-fPIC", "--std=c++17"},
+			},
+			false,
+		},
+		{
+			[]string{"nvcc", "-ptx", "-ccbin", "clang++", "kernel.cu"},
+			CUDACompilation{
+				Mode:         "-ptx",
+				Input:        "kernel.cu",
+				Output:       "kernel.ptx",
+				HostCompiler: "clang++",
+			},
+			false,
+		},
+		{[]string{"nvcc", "kernel.cu", "-o", "kernel"}, CUDACompilation{}, true},
+		{[]string{"nvcc", "-c", "a.cu", "b.cu"}, CUDACompilation{}, true},
+		{[]string{"nvcc", "-c", "host.cpp"}, CUDACompilation{}, true},
+		{[]string{"nvcc", "-c", "-MD", "kernel.cu"}, CUDACompilation{}, true},
+		{[]string{"nvcc", "-c", "-dc", "kernel.cu"}, CUDACompilation{}, true},
+		{[]string{"nvcc", "-E", "kernel.cu"}, CUDACompilation{}, true},
+		{[]string{"nvcc", "-c", "kernel.cu", "-o"}, CUDACompilation{}, true},
+	}
+	for i, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := ParseNVCC(tc.argv)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &tc.out, &got)
+		})
+	}
+}
+
+func TestIsNVCC(t *testing.T) {
+	assert.True(t, DefaultConfig.IsNVCC("llamanvcc"))
+	assert.True(t, DefaultConfig.IsNVCC("/usr/local/bin/llama-nvcc"))
+	assert.False(t, DefaultConfig.IsNVCC("llamacc"))
+	assert.False(t, DefaultConfig.IsCl("llamanvcc"))
+	assert.False(t, DefaultConfig.IsCxx("llamanvcc"))
+	cfg := ParseConfig([]string{"LLAMACC_DRIVER=nvcc"})
+	assert.True(t, cfg.IsNVCC("llamacc"))
+}
+
+func TestOffloadHostCompiler(t *testing.T) {
+	self, err := os.Executable()
+	require.NoError(t, err)
+	cfg := ParseConfig([]string{"LLAMACC_LOCAL_CXX=clang++"})
+
+	cmd := exec.Command("nvcc", "-c", "kernel.cu")
+	cmd.Env = []string{"PATH=/usr/bin"}
+	require.NoError(t, offloadHostCompiler(&cfg, cmd))
+	assert.Equal(t, []string{"nvcc", "-ccbin", self, "-c", "kernel.cu"}, cmd.Args)
+	assert.Equal(t, []string{"PATH=/usr/bin", "LLAMACC_DRIVER=c++", "LLAMACC_LOCAL_CXX=clang++"}, cmd.Env)
+
+	cmd = exec.Command("nvcc", "-ccbin", "g++-11", "-c", "kernel.cu", "--compiler-bindir=g++-12")
+	cmd.Env = []string{}
+	require.NoError(t, offloadHostCompiler(&cfg, cmd))
+	assert.Equal(t, []string{"nvcc", "-ccbin", self, "-c", "kernel.cu"}, cmd.Args)
+	assert.Equal(t, []string{"LLAMACC_DRIVER=c++", "LLAMACC_LOCAL_CXX=g++-12"}, cmd.Env)
+
+	cmd = exec.Command("nvcc", "-ccbin", os.TempDir(), "-c", "kernel.cu")
+	assert.Error(t, offloadHostCompiler(&cfg, cmd))
+	assert.Equal(t, []string{"nvcc", "-ccbin", os.TempDir(), "-c", "kernel.cu"}, cmd.Args)
+}
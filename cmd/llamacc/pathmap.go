@@ -0,0 +1,149 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// A PathMap tells llamacc what to do with dependencies under an
+// absolute local directory, such as a vendor SDK outside the
+// project. Usually, llamacc uploads each header a compilation uses
+// on its own.
+type PathMap struct {
+	Local string
+	// If empty, upload Local as a directory tree. Otherwise,
+	// Local is already present in the function's image at
+	// Remote, and we upload nothing.
+	Remote string
+}
+
+const pathMapUpload = "upload"
+
+func (m *PathMap) Upload() bool {
+	return m.Remote == ""
+}
+
+func (m PathMap) String() string {
+	if m.Upload() {
+		return m.Local + "=" + pathMapUpload
+	}
+	return m.Local + "=" + m.Remote
+}
+
+// parsePathMaps parses a comma-separated list of LOCAL=REMOTE or
+// LOCAL=upload entries
+func parsePathMaps(val string) ([]PathMap, error) {
+	var out []PathMap
+	for _, ent := range strings.Split(val, ",") {
+		if ent = strings.TrimSpace(ent); ent == "" {
+			continue
+		}
+		eq := strings.LastIndexByte(ent, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("%q: expected LOCAL=REMOTE or LOCAL=%s", ent, pathMapUpload)
+		}
+		m := PathMap{Local: filepath.Clean(ent[:eq]), Remote: ent[eq+1:]}
+		if !filepath.IsAbs(m.Local) {
+			return nil, fmt.Errorf("%q: local path must be absolute", ent)
+		}
+		if m.Remote == pathMapUpload {
+			m.Remote = ""
+		} else if !strings.HasPrefix(m.Remote, "/") {
+			return nil, fmt.Errorf("%q: remote path must be absolute, or %q", ent, pathMapUpload)
+		} else {
+			m.Remote = strings.TrimSuffix(m.Remote, "/")
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// mapFor returns the most specific path map covering the absolute
+// path `p`, and the rest of `p` below its directory, or nil if none
+// does.
+func (cfg *Config) mapFor(p string) (*PathMap, string) {
+	var best *PathMap
+	var rest string
+	for i := range cfg.PathMaps {
+		m := &cfg.PathMaps[i]
+		if !hasPathPrefix(p, m.Local) {
+			continue
+		}
+		tail := p[len(m.Local):]
+		if tail != "" && tail[0] != '/' && tail[0] != filepath.Separator {
+			continue
+		}
+		if best == nil || len(m.Local) > len(best.Local) {
+			best, rest = m, filepath.ToSlash(tail)
+		}
+	}
+	return best, rest
+}
+
+// addDependencies adds the files a compilation depends on to `args`,
+// uploading directories mapped with `upload` as trees, and leaving
+// out those in the function's image.
+func (cfg *Config) addDependencies(args *daemon.InvokeWithFilesArgs, deps []string, wd string) {
+	for _, dep := range deps {
+		m, _ := cfg.mapFor(toAbs(dep, wd))
+		if m == nil {
+			args.Files = args.Files.Append(remap(dep, wd))
+		} else if m.Upload() {
+			addTree(args, m.Local, wd)
+		}
+	}
+}
+
+// addTree adds the directory `dir` to args' trees, unless it is
+// already there.
+func addTree(args *daemon.InvokeWithFilesArgs, dir, wd string) {
+	tree := remap(dir, wd)
+	for _, t := range args.Trees {
+		if t.Local.Path == tree.Local.Path {
+			return
+		}
+	}
+	args.Trees = args.Trees.Append(tree)
+}
+
+// remoteInclude returns the remote path of the include directory
+// `dir`, which is `rpath(dir)` unless it is in the function's image.
+func (cfg *Config) remoteInclude(dir, wd string, rpath func(string) string) string {
+	if m, rest := cfg.mapFor(toAbs(dir, wd)); m != nil && !m.Upload() {
+		return m.Remote + rest
+	}
+	return rpath(dir)
+}
+
+// localizeImagePaths rewrites the remote paths of headers in the
+// function's image, in a dependency file, to their local paths.
+func (cfg *Config) localizeImagePaths(data []byte) []byte {
+	for _, m := range cfg.PathMaps {
+		if !m.Upload() && m.Remote != files.RemotePath(m.Local) {
+			// Only rewrite whole paths: the copy of m.Local
+			// under `_root/` ends the same way.
+			re := regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(m.Remote+"/"))
+			data = re.ReplaceAll(data, []byte("${1}"+filepath.ToSlash(m.Local)+"/"))
+		}
+	}
+	return data
+}
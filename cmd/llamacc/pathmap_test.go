@@ -0,0 +1,84 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePathMaps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix paths")
+	}
+	maps, err := parsePathMaps("/opt/vendor/sdk=upload, /opt/toolchain/=/usr/local/toolchain/")
+	require.NoError(t, err)
+	assert.Equal(t, []PathMap{
+		{Local: "/opt/vendor/sdk"},
+		{Local: "/opt/toolchain", Remote: "/usr/local/toolchain"},
+	}, maps)
+
+	for _, bad := range []string{"/opt/sdk", "opt/sdk=upload", "/opt/sdk=sdk"} {
+		_, err := parsePathMaps(bad)
+		assert.Error(t, err, bad)
+	}
+
+	cfg := ParseConfig([]string{"LLAMACC_PATH_MAP=/opt/sdk=/sdk"})
+	assert.Equal(t, []PathMap{{Local: "/opt/sdk", Remote: "/sdk"}}, cfg.PathMaps)
+}
+
+func TestPathMapDependencies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix paths")
+	}
+	cfg := Config{PathMaps: []PathMap{
+		{Local: "/opt/vendor"},
+		{Local: "/opt/vendor/sdk", Remote: "/sdk"},
+		{Local: "/opt/tc", Remote: "/opt/tc"},
+	}}
+	var args daemon.InvokeWithFilesArgs
+	cfg.addDependencies(&args, []string{
+		"foo.h",
+		"/opt/vendor/lib/a.h",
+		"/opt/vendor/lib/b.h",
+		"/opt/vendor/sdk/include/sdk.h",
+		"/opt/tc/include/stdio.h",
+		"/opt/tcx/x.h",
+	}, "/src")
+	assert.Equal(t, files.List{remap("foo.h", "/src"), remap("/opt/tcx/x.h", "/src")}, args.Files)
+	assert.Equal(t, files.List{remap("/opt/vendor", "/src")}, args.Trees)
+
+	rpath := func(p string) string { return toRemote(p, "/src") }
+	assert.Equal(t, "/sdk/include", cfg.remoteInclude("/opt/vendor/sdk/include", "/src", rpath))
+	assert.Equal(t, "/sdk", cfg.remoteInclude("/opt/vendor/sdk", "/src", rpath))
+	assert.Equal(t, "_root/opt/vendor/lib", cfg.remoteInclude("/opt/vendor/lib", "/src", rpath))
+	assert.Equal(t, "_root/src/include", cfg.remoteInclude("include", "/src", rpath))
+}
+
+func TestLocalizeImagePaths(t *testing.T) {
+	cfg := Config{PathMaps: []PathMap{
+		{Local: "/opt/vendor/sdk", Remote: "/sdk"},
+		{Local: "/opt/tc", Remote: "/opt/tc"},
+	}}
+	deps := "foo.o: foo.c /sdk/include/sdk.h \\\n _root/sdk/x.h /opt/tc/stdio.h\n"
+	assert.Equal(t,
+		"foo.o: foo.c /opt/vendor/sdk/include/sdk.h \\\n _root/sdk/x.h /opt/tc/stdio.h\n",
+		string(cfg.localizeImagePaths([]byte(deps))))
+}
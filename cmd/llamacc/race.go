@@ -0,0 +1,168 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/tracing"
+)
+
+// For small translation units, the round trip to Lambda often takes
+// longer than compiling locally. With LLAMACC_RACE, we compile those
+// both ways at once and take whichever result arrives first.
+//
+// The local compiler writes to temporary files, which we move into
+// place if it wins. The remote compilation writes its outputs
+// directly, so before we use a local result we ask the daemon to
+// cancel the invocation; if it has already begun writing outputs, we
+// wait for it instead.
+
+const raceSuffix = ".llamacc-race"
+
+// shouldRace decides whether to race `comp` locally and remotely
+func (cfg *Config) shouldRace(comp *Compilation) bool {
+	if cfg.Race <= 0 || comp.MSVC || comp.IsPCH() || comp.UsesModules() || comp.Analyze.Enabled {
+		return false
+	}
+	// Both compilations would write the same module files
+	if len(comp.Fortran.Writes) > 0 {
+		return false
+	}
+	if len(comp.SecondaryOutputs()) > 0 || comp.ReadsStdin() || comp.WritesStdout() {
+		return false
+	}
+	st, err := os.Stat(comp.Input)
+	return err == nil && st.Size() <= cfg.Race
+}
+
+func newCancelID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Sprintf("rand: %s", err.Error()))
+	}
+	return "llamacc-" + hex.EncodeToString(id[:])
+}
+
+// raceArgs returns the arguments to compile `comp` locally, writing
+// the output and any dependency file to temporary paths
+func raceArgs(comp *Compilation) []string {
+	var out []string
+	for i := 0; i < len(comp.LocalArgs); i++ {
+		arg := comp.LocalArgs[i]
+		switch {
+		case arg == "-MF" && i+1 < len(comp.LocalArgs):
+			out = append(out, arg, comp.LocalArgs[i+1]+raceSuffix)
+			i++
+		case strings.HasPrefix(arg, "-MF"):
+			out = append(out, arg+raceSuffix)
+		default:
+			out = append(out, arg)
+		}
+	}
+	return append(out, "-c", "-o", comp.Output+raceSuffix, comp.Input)
+}
+
+// raceLocal invokes `args` and compiles `comp` locally at the same
+// time, using whichever result arrives first
+func raceLocal(ctx context.Context, client *daemon.Client, cfg *Config, comp *Compilation, args *daemon.InvokeWithFilesArgs) error {
+	ctx, span := tracing.StartSpan(ctx, "race")
+	defer span.End()
+
+	temps := []string{comp.Output + raceSuffix}
+	if comp.Flag.MF != "" {
+		temps = append(temps, comp.Flag.MF+raceSuffix)
+	}
+	defer func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}()
+
+	// We can only print the remote compiler's output if it wins,
+	// so don't stream it
+	rcfg := *cfg
+	rcfg.Stream = false
+	args.CancelID = newCancelID()
+	remote := make(chan error, 1)
+	go func() {
+		_, err := invokeRemote(client, &rcfg, args, os.Stdout)
+		remote <- err
+	}()
+	useRemote := func(err error) error {
+		span.AddField("winner", "remote")
+		if err != nil {
+			return err
+		}
+		return finishRemote(ctx, cfg, comp)
+	}
+
+	lctx, kill := context.WithCancel(ctx)
+	defer kill()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(lctx, comp.LocalCompiler(cfg), raceArgs(comp)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return useRemote(<-remote)
+	}
+	local := make(chan error, 1)
+	go func() { local <- cmd.Wait() }()
+	useLocal := func(err error) error {
+		span.AddField("winner", "local")
+		os.Stdout.Write(stdout.Bytes())
+		os.Stderr.Write(stderr.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(comp.Output+raceSuffix, comp.Output); err != nil {
+			return err
+		}
+		if comp.Flag.MF != "" {
+			return os.Rename(comp.Flag.MF+raceSuffix, comp.Flag.MF)
+		}
+		return nil
+	}
+
+	select {
+	case err := <-remote:
+		var ie *invokeError
+		if errors.As(err, &ie) {
+			// We couldn't compile remotely, so wait for the
+			// local compiler
+			return useLocal(<-local)
+		}
+		kill()
+		<-local
+		return useRemote(err)
+	case err := <-local:
+		reply, cerr := client.CancelInvocation(&daemon.CancelInvocationArgs{CancelID: args.CancelID})
+		if cerr != nil || !reply.Cancelled {
+			// The remote compilation is already writing
+			// its outputs
+			return useRemote(<-remote)
+		}
+		return useLocal(err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRaceArgs(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{"cc", "-MD", "-O2", "-nostdinc", "-Iinclude", "-c", "foo.c", "-o", "obj/foo.o"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-MD", "-O2", "-nostdinc", "-Iinclude", "-MF", "obj/foo.d.llamacc-race", "-MT", "obj/foo.o",
+		"-c", "-o", "obj/foo.o.llamacc-race", "foo.c",
+	}, raceArgs(&comp))
+
+	comp, err = ParseCompile(&DefaultConfig, []string{"cc", "-MMD", "-MFfoo.d", "-c", "foo.c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-MMD", "-MFfoo.d.llamacc-race", "-MT", "foo.o",
+		"-c", "-o", "foo.o.llamacc-race", "foo.c",
+	}, raceArgs(&comp))
+}
+
+func TestShouldRace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llamacc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	small := filepath.Join(dir, "small.c")
+	big := filepath.Join(dir, "big.c")
+	require.NoError(t, ioutil.WriteFile(small, make([]byte, 100), 0644))
+	require.NoError(t, ioutil.WriteFile(big, make([]byte, 10000), 0644))
+
+	cfg := DefaultConfig
+	cfg.Race = 1000
+	for _, tc := range []struct {
+		argv []string
+		race bool
+	}{
+		{[]string{"cc", "-c", small}, true},
+		{[]string{"cc", "-c", big}, false},
+		{[]string{"cc", "-gsplit-dwarf", "-c", small}, false},
+		{[]string{"cc", "-c", filepath.Join(dir, "missing.c")}, false},
+	} {
+		comp, err := ParseCompile(&cfg, tc.argv)
+		require.NoError(t, err)
+		assert.Equal(t, tc.race, cfg.shouldRace(&comp), "%q", tc.argv)
+	}
+
+	comp, err := ParseCompile(&DefaultConfig, []string{"cc", "-c", small})
+	require.NoError(t, err)
+	assert.False(t, DefaultConfig.shouldRace(&comp))
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/server"
+	"github.com/nelhage/llama/files"
+	protofiles "github.com/nelhage/llama/protocol/files"
+)
+
+// With LLAMACC_REMOTE_OBJECTS, object files and archives we build
+// remotely are never downloaded. The daemon writes a reference file
+// in place of each, which a later remote link or `ar` uploads as the
+// object it refers to. Anything we run locally gets the real
+// contents first; see materializeArgs.
+
+// keepRemote asks the daemon to leave `output`, which `args`
+// produces, in the object store, if LLAMACC_REMOTE_OBJECTS is set
+func (cfg *Config) keepRemote(args *daemon.InvokeWithFilesArgs, output, wd string) {
+	// Verification compares the remote output byte-for-byte
+	if !cfg.RemoteObjects || cfg.Verify > 0 {
+		return
+	}
+	args.RefOutputs = append(args.RefOutputs, toAbs(output, wd))
+}
+
+// keepsObject returns true if `comp` produces an object file, which
+// LLAMACC_REMOTE_OBJECTS can leave remote
+func (comp *Compilation) keepsObject() bool {
+	return comp.Flag.C && !comp.IsPCH() && !comp.Analyze.Enabled && !comp.WritesStdout()
+}
+
+// materializeArgs downloads the contents of any reference files
+// among the inputs named by `argv`, so a local tool can read them.
+func materializeArgs(cfg *Config, argv []string) error {
+	if !cfg.RemoteObjects {
+		return nil
+	}
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return err
+	}
+	args, err := expandResponseFiles(argv[1:])
+	if err != nil {
+		args = argv[1:]
+	}
+	if link, err := ParseLink(cfg, argv); err == nil {
+		args = append(args, link.Inputs...)
+	}
+	var refs []string
+	for _, arg := range args {
+		if protofiles.IsRef(arg) {
+			refs = append(refs, toAbs(arg, wd))
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	client, err := server.DialWithAutostart(context.Background(), cli.SocketPath(), server.LlamaCCPath)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if cfg.Verbose {
+		log.Printf("[llamacc] materializing %q", refs)
+	}
+	_, err = client.Materialize(&daemon.MaterializeArgs{Paths: refs})
+	return err
+}
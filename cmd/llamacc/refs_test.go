@@ -0,0 +1,46 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepRemote(t *testing.T) {
+	var args daemon.InvokeWithFilesArgs
+	DefaultConfig.keepRemote(&args, "hello.o", "/src")
+	assert.Empty(t, args.RefOutputs)
+
+	cfg := ParseConfig([]string{"LLAMACC_REMOTE_OBJECTS=1"})
+	cfg.keepRemote(&args, "hello.o", "/src")
+	cfg.keepRemote(&args, "/build/libhello.a", "/src")
+	assert.Equal(t, []string{"/src/hello.o", "/build/libhello.a"}, args.RefOutputs)
+
+	cfg = ParseConfig([]string{"LLAMACC_REMOTE_OBJECTS=1", "LLAMACC_VERIFY=0.5"})
+	args = daemon.InvokeWithFilesArgs{}
+	cfg.keepRemote(&args, "hello.o", "/src")
+	assert.Empty(t, args.RefOutputs)
+
+	comp, err := ParseCompile(&DefaultConfig, []string{"cc", "-c", "hello.c", "-o", "hello.o"})
+	require.NoError(t, err)
+	assert.True(t, comp.keepsObject())
+	comp, err = ParseCompile(&DefaultConfig, []string{"cc", "-c", "hello.h", "-o", "hello.h.gch"})
+	require.NoError(t, err)
+	assert.False(t, comp.keepsObject())
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+)
+
+// Remote compilations run in a fresh temporary directory, with the
+// input files under `_root/`, so the paths the compiler records in
+// its output -- `__FILE__`, debug info and the compilation directory
+// -- differ from a local build, and from one remote build to the
+// next. With LLAMACC_REPRODUCIBLE, we run the compiler in the copy
+// of the working directory under `_root/`, name files relative to it
+// just as they were named locally, and map the remaining paths back
+// to their local equivalents.
+
+// rootPrefixMap maps the paths wrapperutil.ToRemoteRel produces for
+// absolute local paths back to the local paths
+func rootPrefixMap(wd string) []string {
+	prefix := wrapperutil.RootPrefix(wd)
+	if prefix == "" {
+		return nil
+	}
+	return []string{"-ffile-prefix-map=" + prefix + "=/"}
+}
+
+// reproducible reports whether to compile `comp` as
+// LLAMACC_REPRODUCIBLE does. Split DWARF always needs to be: the
+// object names its `.dwo` file relative to the compilation directory,
+// and both must match the local build for dwp and debuggers to find
+// it.
+func (cfg *Config) reproducible(comp *Compilation) bool {
+	return cfg.Reproducible || comp.Flag.SplitDwarf
+}
+
+// remotePrefixMaps returns the user's prefix maps, with each OLD
+// prefix rewritten by `rpath` to the path the remote compiler sees.
+func remotePrefixMaps(comp *Compilation, rpath func(string) string) []string {
+	var out []string
+	for _, m := range comp.PrefixMaps {
+		old := rpath(m.Old)
+		if strings.HasSuffix(m.Old, "/") && !strings.HasSuffix(old, "/") {
+			old += "/"
+		}
+		out = append(out, m.Opt+old+"="+m.New)
+	}
+	return out
+}
+
+// mapPrefix applies the last of `maps` to match `p`, as GCC and
+// clang do.
+func mapPrefix(p string, maps []PrefixMap) string {
+	for i := len(maps) - 1; i >= 0; i-- {
+		m := maps[i]
+		if m.Opt == "-fmacro-prefix-map=" || m.Opt == "-fprofile-prefix-map=" {
+			continue
+		}
+		if strings.HasPrefix(p, m.Old) {
+			return m.New + p[len(m.Old):]
+		}
+	}
+	return p
+}
+
+// reproducibleCommand wraps a remote compiler command line to run in
+// `dir`, relative to the job's root, and to record `wd` -- after any
+// of the user's prefix maps -- as its compilation directory. Only the
+// shell knows the job's root, so it adds that prefix map itself.
+func reproducibleCommand(argv []string, dir, wd string, comp *Compilation) []string {
+	const script = `cd "$1" && wd=$2 && shift 2 && exec "$@" "-fdebug-prefix-map=$PWD=$wd"`
+	out := []string{"sh", "-c", script, "llamacc", dir, mapPrefix(wd, comp.PrefixMaps)}
+	return append(out, argv...)
+}
+
+// remoteEnv returns the environment to pass SOURCE_DATE_EPOCH
+// through to the remote compiler, which uses it in place of the
+// current time for `__DATE__` and `__TIME__`.
+func (cfg *Config) remoteEnv() []string {
+	if cfg.SourceDateEpoch == "" {
+		return nil
+	}
+	return []string{"SOURCE_DATE_EPOCH=" + cfg.SourceDateEpoch}
+}
+
+// shouldVerify decides whether to check this compilation against a
+// local one; see LLAMACC_VERIFY.
+func (cfg *Config) shouldVerify(comp *Compilation) bool {
+	if cfg.Verify <= 0 || comp.MSVC || comp.IsPCH() || comp.Analyze.Enabled {
+		return false
+	}
+	if comp.ReadsStdin() || comp.WritesStdout() {
+		return false
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())))
+	return rng.Float64() < cfg.Verify
+}
+
+// verifyLocally compiles `comp` locally, and compares the result with
+// the remote compiler's output. If they differ, it keeps the local
+// output alongside the remote one for inspection, and fails.
+func verifyLocally(cfg *Config, comp *Compilation) error {
+	local := comp.Output + ".llamacc-verify"
+	var argv []string
+	argv = append(argv, comp.UnknownArgs...)
+	for _, def := range comp.Defs {
+		argv = append(argv, def.Opt, def.Def)
+	}
+	for _, inc := range comp.Includes {
+		argv = append(argv, inc.Opt, inc.Path)
+	}
+	for _, aux := range comp.AuxInputs {
+		argv = append(argv, aux.Opt+aux.Path)
+	}
+	for _, m := range comp.PrefixMaps {
+		argv = append(argv, m.String())
+	}
+	argv = append(argv, "-x", string(comp.Language), "-c", "-o", local, comp.Input)
+
+	cmd := exec.Command(comp.LocalCompiler(cfg), argv...)
+	cmd.Env = os.Environ()
+	cmd.Stderr = ioutil.Discard
+	if cfg.Verbose {
+		log.Printf("[llamacc] verifying locally: %q", cmd.Args)
+	}
+	if err := cmd.Run(); err != nil {
+		os.Remove(local)
+		return fmt.Errorf("verifying %s: local compilation failed: %w", comp.Output, err)
+	}
+	want, err := ioutil.ReadFile(local)
+	if err != nil {
+		return err
+	}
+	got, err := ioutil.ReadFile(comp.Output)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("verifying %s: remote output differs from local output (kept in %s)", comp.Output, local)
+	}
+	return os.Remove(local)
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrefixMaps(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{
+		"cc", "-ffile-prefix-map=/home/me/src/=", "-fdebug-prefix-map=/opt=/usr", "-c", "foo.c",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []PrefixMap{
+		{"-ffile-prefix-map=", "/home/me/src/", ""},
+		{"-fdebug-prefix-map=", "/opt", "/usr"},
+	}, comp.PrefixMaps)
+	assert.Equal(t, []string{"-c"}, comp.RemoteArgs)
+
+	_, err = ParseCompile(&DefaultConfig, []string{"cc", "-ffile-prefix-map=nope", "-c", "foo.c"})
+	assert.Error(t, err)
+}
+
+func TestToRemoteRel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("LLAMACC_REPRODUCIBLE is not supported on Windows hosts")
+	}
+	wd := "/home/me/src"
+	assert.Equal(t, "foo.c", wrapperutil.ToRemoteRel("foo.c", wd))
+	assert.Equal(t, "../include/foo.h", wrapperutil.ToRemoteRel("./../include/foo.h", wd))
+	assert.Equal(t, "../../../usr/include/foo.h", wrapperutil.ToRemoteRel("/usr/include/foo.h", wd))
+	assert.Equal(t, []string{"-ffile-prefix-map=../../../=/"}, rootPrefixMap(wd))
+	assert.Nil(t, rootPrefixMap("/"))
+
+	comp := Compilation{PrefixMaps: []PrefixMap{
+		{"-ffile-prefix-map=", "/home/me/", "/src/"},
+		{"-fmacro-prefix-map=", "lib", "vendor/lib"},
+	}}
+	assert.Equal(t, []string{
+		"-ffile-prefix-map=../../../home/me/=/src/",
+		"-fmacro-prefix-map=lib=vendor/lib",
+	}, remotePrefixMaps(&comp, func(p string) string { return wrapperutil.ToRemoteRel(p, wd) }))
+	assert.Equal(t, []string{
+		"-ffile-prefix-map=_root/home/me/=/src/",
+		"-fmacro-prefix-map=_root/home/me/src/lib=vendor/lib",
+	}, remotePrefixMaps(&comp, func(p string) string { return toRemote(p, wd) }))
+}
+
+func TestMapPrefix(t *testing.T) {
+	maps := []PrefixMap{
+		{"-ffile-prefix-map=", "/home/", "/h/"},
+		{"-fdebug-prefix-map=", "/home/me/", "/me/"},
+		{"-fmacro-prefix-map=", "/home/me/src", "/src"},
+	}
+	assert.Equal(t, "/me/src", mapPrefix("/home/me/src", maps))
+	assert.Equal(t, "/h/you", mapPrefix("/home/you", maps))
+	assert.Equal(t, "/opt", mapPrefix("/opt", maps))
+}
+
+func TestReproducibleCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "_root/home/me/src"), 0755))
+	comp := Compilation{PrefixMaps: []PrefixMap{{"-fdebug-prefix-map=", "/home/me", "/me"}}}
+	argv := reproducibleCommand([]string{"echo", "cc", "-c", "foo.c"}, "_root/home/me/src", "/home/me/src", &comp)
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = root
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	// The shell may resolve symlinks in the temporary directory
+	words := strings.Fields(string(out))
+	require.Len(t, words, 4)
+	assert.Equal(t, []string{"cc", "-c", "foo.c"}, words[:3])
+	assert.True(t, strings.HasPrefix(words[3], "-fdebug-prefix-map=/"), words[3])
+	assert.True(t, strings.HasSuffix(words[3], "/_root/home/me/src=/me/src"), words[3])
+}
+
+func TestReproducibleConfig(t *testing.T) {
+	cfg := ParseConfig([]string{
+		"LLAMACC_REPRODUCIBLE=1",
+		"LLAMACC_VERIFY=0.25",
+		"SOURCE_DATE_EPOCH=1600000000",
+	})
+	assert.True(t, cfg.Reproducible)
+	assert.Equal(t, 0.25, cfg.Verify)
+	assert.Equal(t, []string{"SOURCE_DATE_EPOCH=1600000000"}, cfg.remoteEnv())
+	assert.Nil(t, DefaultConfig.remoteEnv())
+
+	cfg = ParseConfig([]string{"LLAMACC_VERIFY=2"})
+	assert.Equal(t, 0.0, cfg.Verify)
+	assert.False(t, cfg.shouldVerify(&Compilation{}))
+	cfg.Verify = 1
+	assert.True(t, cfg.shouldVerify(&Compilation{}))
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/nelhage/llama/files"
+)
+
+// A SecondaryOutput is a file the compiler may write besides its
+// output, such as a `-save-temps` intermediate or a `.gcno` notes
+// file. We arrange for the remote compiler to write each one beside
+// the output, with extension Ext, and fetch back whichever it does
+// write.
+type SecondaryOutput struct {
+	// Where the local compiler would have written the file
+	Path string
+	Ext  string
+}
+
+// saveTempsExts maps languages to the extension of the preprocessed
+// source `-save-temps` keeps
+var saveTempsExts = map[Lang]string{
+	LangC:      ".i",
+	LangCxx:    ".ii",
+	LangObjC:   ".mi",
+	LangObjCxx: ".mii",
+}
+
+// SecondaryOutputs returns the files, besides Output, which this
+// compilation may write.
+//
+// `-save-temps` (or `-save-temps=cwd`) keeps intermediates in the
+// working directory, named after the input, and `-save-temps=obj`
+// keeps them beside the output, named after it; GCC keeps the
+// preprocessed source and assembly, and clang also keeps bitcode.
+// `-ftest-coverage` writes a notes file, and `-gsplit-dwarf` debug
+// info, beside the output.
+func (c *Compilation) SecondaryOutputs() []SecondaryOutput {
+	if c.Analyze.Enabled {
+		// The analyzer writes only its report
+		return nil
+	}
+	var out []SecondaryOutput
+	if c.SaveTemps != "" {
+		exts := []string{".s", ".bc"}
+		if ext, ok := saveTempsExts[c.Language]; ok {
+			exts = append([]string{ext}, exts...)
+		}
+		for _, ext := range exts {
+			local := replaceExt(filepath.Base(c.Input), ext)
+			if c.SaveTemps == "obj" {
+				local = replaceExt(c.Output, ext)
+			}
+			out = append(out, SecondaryOutput{Path: local, Ext: ext})
+		}
+	}
+	if c.Flag.TestCoverage {
+		out = append(out, SecondaryOutput{Path: replaceExt(c.Output, ".gcno"), Ext: ".gcno"})
+	}
+	if c.Flag.SplitDwarf {
+		out = append(out, SecondaryOutput{Path: replaceExt(c.Output, ".dwo"), Ext: ".dwo"})
+	}
+	return out
+}
+
+// secondaryOutputs maps this compilation's secondary outputs, given
+// the path the remote compiler writes the output to
+func (c *Compilation) secondaryOutputs(remoteOutput, wd string) files.List {
+	var out files.List
+	for _, sec := range c.SecondaryOutputs() {
+		out = out.Append(files.Mapped{
+			Local:  files.LocalFile{Path: toAbs(sec.Path, wd)},
+			Remote: replaceExt(remoteOutput, sec.Ext),
+		})
+	}
+	return out
+}
+
+func checkSecondarySupported(cfg *Config, comp *Compilation) error {
+	if comp.SaveTemps == "" {
+		return nil
+	}
+	if cfg.LocalPreprocess {
+		return errors.New("-save-temps given, and LLAMACC_LOCAL_PREPROCESS set")
+	}
+	if comp.MSVC || comp.IsPCH() || comp.Modules.Precompile {
+		return errors.New("-save-temps is only supported for object files")
+	}
+	return nil
+}
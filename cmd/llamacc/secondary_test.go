@@ -0,0 +1,127 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecondaryOutputs(t *testing.T) {
+	cases := []struct {
+		argv []string
+		out  []SecondaryOutput
+		err  bool
+	}{
+		{
+			[]string{"cc", "-c", "src/foo.c", "-o", "obj/foo.o"},
+			nil,
+			false,
+		},
+		{
+			[]string{"cc", "-save-temps", "-c", "src/foo.c", "-o", "obj/foo.o"},
+			[]SecondaryOutput{
+				{"foo.i", ".i"},
+				{"foo.s", ".s"},
+				{"foo.bc", ".bc"},
+			},
+			false,
+		},
+		{
+			[]string{"c++", "-save-temps=obj", "-c", "src/foo.cc", "-o", "obj/bar.o"},
+			[]SecondaryOutput{
+				{"obj/bar.ii", ".ii"},
+				{"obj/bar.s", ".s"},
+				{"obj/bar.bc", ".bc"},
+			},
+			false,
+		},
+		{
+			[]string{"cc", "-ftest-coverage", "-c", "src/foo.c", "-o", "obj/foo.o"},
+			[]SecondaryOutput{
+				{"obj/foo.gcno", ".gcno"},
+			},
+			false,
+		},
+		{
+			[]string{"cc", "-g", "-gsplit-dwarf", "-c", "src/foo.c", "-o", "obj/foo.o"},
+			[]SecondaryOutput{
+				{"obj/foo.dwo", ".dwo"},
+			},
+			false,
+		},
+		{
+			[]string{"clang", "-g", "-gsplit-dwarf=split", "-c", "src/foo.c"},
+			[]SecondaryOutput{
+				{"src/foo.dwo", ".dwo"},
+			},
+			false,
+		},
+		{
+			[]string{"clang", "-g", "-gsplit-dwarf=single", "-c", "src/foo.c"},
+			nil,
+			false,
+		},
+		{
+			[]string{"cc", "-save-temps=elsewhere", "-c", "src/foo.c"},
+			nil,
+			true,
+		},
+	}
+	for _, tc := range cases {
+		comp, err := ParseCompile(&DefaultConfig, tc.argv)
+		if tc.err {
+			assert.Error(t, err, "%q", tc.argv)
+			continue
+		}
+		if assert.NoError(t, err, "%q", tc.argv) {
+			assert.Equal(t, tc.out, comp.SecondaryOutputs(), "%q", tc.argv)
+		}
+	}
+}
+
+func TestSecondaryOutputsRemote(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{"cc", "-save-temps", "-ftest-coverage", "-c", "src/foo.c", "-o", "obj/foo.o"})
+	require.NoError(t, err)
+	assert.NotContains(t, comp.RemoteArgs, "-save-temps")
+	assert.Contains(t, comp.RemoteArgs, "-ftest-coverage")
+
+	assert.Equal(t, files.List{
+		{Local: files.LocalFile{Path: "/src/foo.i"}, Remote: "_root/src/obj/foo.i"},
+		{Local: files.LocalFile{Path: "/src/foo.s"}, Remote: "_root/src/obj/foo.s"},
+		{Local: files.LocalFile{Path: "/src/foo.bc"}, Remote: "_root/src/obj/foo.bc"},
+		{Local: files.LocalFile{Path: "/src/obj/foo.gcno"}, Remote: "_root/src/obj/foo.gcno"},
+	}, comp.secondaryOutputs("_root/src/obj/foo.o", "/src"))
+
+	cfg := DefaultConfig
+	cfg.LocalPreprocess = true
+	assert.Error(t, checkSupported(&cfg, &comp))
+	cfg.LocalPreprocess = false
+	assert.NoError(t, checkSupported(&cfg, &comp))
+}
+
+func TestSplitDwarfReproducible(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{"cc", "-g", "-gsplit-dwarf", "-c", "foo.c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-g", "-gsplit-dwarf", "-c"}, comp.RemoteArgs)
+	assert.True(t, DefaultConfig.reproducible(&comp))
+
+	comp, err = ParseCompile(&DefaultConfig, []string{"cc", "-g", "-c", "foo.c"})
+	require.NoError(t, err)
+	assert.False(t, DefaultConfig.reproducible(&comp))
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+
+	"github.com/nelhage/llama/daemon"
+)
+
+// stages reports whether we ask the daemon to start uploading a
+// compilation's inputs while we are still looking for the rest of
+// them. We don't when only explaining what we would do.
+func (cfg *Config) stages() bool {
+	return !cfg.NoStage && !cfg.Explain
+}
+
+// stageInputs asks the daemon to start uploading the inputs of
+// `comp` we know before detecting its dependencies -- the source,
+// precompiled headers and auxiliary inputs -- so that reading,
+// hashing and uploading them overlaps with running the preprocessor.
+// The daemon similarly stages the headers the source needed last
+// time, when the dependency cache misses; see runMakeDeps.
+func stageInputs(client *daemon.Client, cfg *Config, comp *Compilation, wd string) {
+	if !cfg.stages() {
+		return
+	}
+	paths := stagePaths(comp, wd)
+	if len(paths) == 0 {
+		return
+	}
+	if _, err := client.Stage(&daemon.StageArgs{Paths: paths}); err != nil && cfg.Verbose {
+		log.Printf("[llamacc] staging inputs: %s", err.Error())
+	}
+}
+
+func stagePaths(comp *Compilation, wd string) []string {
+	var paths []string
+	if !comp.ReadsStdin() {
+		paths = append(paths, toAbs(comp.Input, wd))
+	}
+	for _, pch := range comp.PrecompiledHeaders() {
+		paths = append(paths, toAbs(pch, wd))
+	}
+	for _, aux := range comp.AuxInputs {
+		paths = append(paths, toAbs(aux.Path, wd))
+	}
+	return paths
+}
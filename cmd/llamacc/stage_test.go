@@ -0,0 +1,44 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagePaths(t *testing.T) {
+	wd := t.TempDir()
+	cfg := DefaultConfig
+	comp, err := ParseCompile(&cfg, []string{"cc", "-fprofile-instr-use=app.profdata", "-c", "src/hello.c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(wd, "src", "hello.c"),
+		filepath.Join(wd, "app.profdata"),
+	}, stagePaths(&comp, wd))
+
+	comp, err = ParseCompile(&cfg, []string{"cc", "-x", "c", "-c", "-", "-o", "out.o"})
+	require.NoError(t, err)
+	assert.Empty(t, stagePaths(&comp, wd), "stdin isn't a file")
+
+	assert.True(t, cfg.stages())
+	cfg = ParseConfig([]string{"LLAMACC_NO_STAGE=1"})
+	assert.False(t, cfg.stages())
+	cfg = ParseConfig([]string{"LLAMACC_EXPLAIN=1"})
+	assert.False(t, cfg.stages())
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// `-` names stdin as the input, or stdout as the output, as it does
+// for GCC and Clang. We read the source up front and send it as the
+// remote compiler's stdin; an object written to stdout comes back as
+// the invocation's stdout.
+
+// ReadsStdin returns true if `comp` reads its source from stdin
+func (c *Compilation) ReadsStdin() bool {
+	return c.Input == "-"
+}
+
+// WritesStdout returns true if `comp` writes its output to stdout
+func (c *Compilation) WritesStdout() bool {
+	return c.Output == "-"
+}
+
+// readStdin reads the source of a compilation from stdin, so that
+// we still have it if we end up compiling locally
+func (c *Compilation) readStdin() error {
+	if !c.ReadsStdin() || c.Stdin != nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	c.Stdin = data
+	return nil
+}
+
+// ioPath returns the name the remote compiler should use for `p`,
+// which may be `-`
+func ioPath(p string, rpath func(string) string) string {
+	if p == "-" {
+		return p
+	}
+	return rpath(p)
+}
+
+func checkStdioSupported(cfg *Config, comp *Compilation) error {
+	if comp.ReadsStdin() && (comp.IsPCH() || comp.UsesModules()) {
+		return errors.New("precompiled headers and modules can't be read from stdin")
+	}
+	if !comp.WritesStdout() {
+		return nil
+	}
+	if comp.Flag.MF != "" || comp.SaveTemps != "" || len(comp.SecondaryOutputs()) > 0 {
+		return errors.New("-o - given, with other outputs named after it")
+	}
+	if comp.IsPCH() || comp.UsesModules() || comp.Analyze.Enabled {
+		return errors.New("-o - is only supported for object files")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompileStdio(t *testing.T) {
+	comp, err := ParseCompile(&DefaultConfig, []string{"cc", "-x", "c", "-c", "-", "-o", "-"})
+	require.NoError(t, err)
+	assert.Equal(t, LangC, comp.Language)
+	assert.True(t, comp.ReadsStdin())
+	assert.True(t, comp.WritesStdout())
+	assert.Empty(t, comp.UnknownArgs)
+	assert.False(t, comp.keepsObject())
+	assert.False(t, (&Config{Race: 1 << 20}).shouldRace(&comp))
+	require.NoError(t, checkSupported(&DefaultConfig, &comp))
+
+	comp, err = ParseCompile(&DefaultConfig, []string{"cc", "-c", "-xc++", "-", "-o", "stdin.o"})
+	require.NoError(t, err)
+	assert.Equal(t, LangCxx, comp.Language)
+	assert.True(t, comp.ReadsStdin())
+	assert.False(t, comp.WritesStdout())
+	assert.True(t, comp.keepsObject())
+
+	comp, err = ParseCompile(&DefaultConfig, []string{"cc", "-c", "main.c", "-o", "-"})
+	require.NoError(t, err)
+	assert.False(t, comp.ReadsStdin())
+	assert.True(t, comp.WritesStdout())
+
+	_, err = ParseCompile(&DefaultConfig, []string{"cc", "-c", "-"})
+	assert.Error(t, err, "stdin has no extension to infer a language from")
+	_, err = ParseCompile(&DefaultConfig, []string{"cc", "-x", "c", "-c", "-", "main.c"})
+	assert.Error(t, err)
+
+	for _, argv := range [][]string{
+		{"cc", "-c", "main.c", "-o", "-", "-MD"},
+		{"cc", "-c", "main.c", "-o", "-", "-gsplit-dwarf"},
+		{"cc", "-x", "c-header", "-", "-o", "-"},
+		{"cc", "-x", "c-header", "-", "-o", "stdin.h.gch"},
+	} {
+		comp, err = ParseCompile(&DefaultConfig, argv)
+		require.NoError(t, err, "%q", argv)
+		assert.Error(t, checkSupported(&DefaultConfig, &comp), "%q", argv)
+	}
+}
+
+func TestIOPath(t *testing.T) {
+	rpath := func(p string) string { return "_root/src/" + p }
+	assert.Equal(t, "-", ioPath("-", rpath))
+	assert.Equal(t, "_root/src/main.o", ioPath("main.o", rpath))
+}
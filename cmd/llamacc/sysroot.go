@@ -0,0 +1,83 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/daemon"
+)
+
+// sysrootArg parses `--sysroot=DIR` or `--sysroot DIR`. We pass the
+// local compiler the option as given, and the remote compiler the
+// remote path of DIR; see shipSysroot.
+func sysrootArg(opt string) argSpec {
+	return argSpec{opt, func(c *Compilation, arg string) (filterWhere, error) {
+		c.Sysroot = arg
+		return filterRemote, nil
+	}, true}
+}
+
+// defaultSysroot makes `comp` use LLAMACC_SYSROOT, if it is set and
+// the command line doesn't name a sysroot itself.
+func (cfg *Config) defaultSysroot(comp *Compilation) {
+	if comp.Sysroot != "" || cfg.Sysroot == "" {
+		return
+	}
+	comp.Sysroot = cfg.Sysroot
+	comp.LocalArgs = append(comp.LocalArgs, "--sysroot="+cfg.Sysroot)
+}
+
+// shipSysroot arranges for the remote compiler to see the sysroot
+// `dir`, and returns its remote path. If a path map places `dir` in
+// the function's image, that is where it is. Otherwise, we upload it
+// as a tree, which the daemon uploads once and then only updates,
+// and leave out any of `deps` inside it, which the tree covers.
+func (cfg *Config) shipSysroot(args *daemon.InvokeWithFilesArgs, dir, wd string, rpath func(string) string, deps []string) (string, []string) {
+	abs := toAbs(dir, wd)
+	m, rest := cfg.mapFor(abs)
+	if m != nil && !m.Upload() {
+		return m.Remote + rest, deps
+	}
+	if m != nil {
+		addTree(args, m.Local, wd)
+	} else {
+		addTree(args, abs, wd)
+	}
+	var out []string
+	for _, dep := range deps {
+		if !hasPathPrefix(toAbs(dep, wd), abs+string(filepath.Separator)) {
+			out = append(out, dep)
+		}
+	}
+	return rpath(dir), out
+}
+
+// withSysroot adds LLAMACC_SYSROOT to `argv`, an invocation of the C,
+// C++ or Fortran compiler driver we run locally, unless it names a
+// sysroot itself, so that local compilations and links see the same
+// headers and libraries as remote ones.
+func (cfg *Config) withSysroot(argv []string) []string {
+	if cfg.Sysroot == "" || cfg.IsNVCC(argv[0]) || cfg.IsCl(argv[0]) || cfg.Archiver(argv[0]) != "" {
+		return argv
+	}
+	for _, arg := range argv[1:] {
+		if arg == "--sysroot" || strings.HasPrefix(arg, "--sysroot=") {
+			return argv
+		}
+	}
+	return append([]string{argv[0], "--sysroot=" + cfg.Sysroot}, argv[1:]...)
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSysroot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix paths")
+	}
+	cfg := DefaultConfig
+	for _, argv := range [][]string{
+		{"cc", "--sysroot=/opt/arm", "-c", "hello.c"},
+		{"cc", "--sysroot", "/opt/arm", "-c", "hello.c"},
+	} {
+		comp, err := ParseCompile(&cfg, argv)
+		require.NoError(t, err, argv)
+		assert.Equal(t, "/opt/arm", comp.Sysroot)
+		assert.Empty(t, comp.UnknownArgs)
+		assert.Subset(t, comp.LocalArgs, argv[1:len(argv)-2])
+	}
+
+	cfg = ParseConfig([]string{"LLAMACC_SYSROOT=/opt/sysroots/arm64/"})
+	assert.Equal(t, "/opt/sysroots/arm64", cfg.Sysroot)
+	comp, err := ParseCompile(&cfg, []string{"cc", "-c", "hello.c"})
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/sysroots/arm64", comp.Sysroot)
+	assert.Contains(t, comp.LocalArgs, "--sysroot=/opt/sysroots/arm64")
+
+	comp, err = ParseCompile(&cfg, []string{"cc", "--sysroot=/opt/other", "-c", "hello.c"})
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/other", comp.Sysroot, "the command line wins")
+	assert.NotContains(t, comp.LocalArgs, "--sysroot=/opt/sysroots/arm64")
+
+	assert.Equal(t, "", ParseConfig([]string{"LLAMACC_SYSROOT=sysroot"}).Sysroot)
+}
+
+func TestShipSysroot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix paths")
+	}
+	rpath := func(p string) string { return toRemote(p, "/src") }
+	deps := []string{"foo.h", "/opt/arm/usr/include/stdio.h", "/opt/arm64/x.h"}
+
+	var cfg Config
+	var args daemon.InvokeWithFilesArgs
+	remote, rest := cfg.shipSysroot(&args, "/opt/arm", "/src", rpath, deps)
+	assert.Equal(t, "_root/opt/arm", remote)
+	assert.Equal(t, []string{"foo.h", "/opt/arm64/x.h"}, rest)
+	assert.Equal(t, files.List{remap("/opt/arm", "/src")}, args.Trees)
+
+	// An enclosing upload map uploads the whole map, once
+	cfg = Config{PathMaps: []PathMap{{Local: "/opt"}}}
+	args = daemon.InvokeWithFilesArgs{}
+	_, rest = cfg.shipSysroot(&args, "/opt/arm", "/src", rpath, deps)
+	cfg.addDependencies(&args, rest, "/src")
+	assert.Equal(t, files.List{remap("/opt", "/src")}, args.Trees)
+	assert.Equal(t, files.List{remap("foo.h", "/src")}, args.Files)
+
+	// A sysroot in the function's image isn't uploaded
+	cfg = Config{PathMaps: []PathMap{{Local: "/opt/arm", Remote: "/sysroot"}}}
+	args = daemon.InvokeWithFilesArgs{}
+	remote, rest = cfg.shipSysroot(&args, "/opt/arm", "/src", rpath, deps)
+	assert.Equal(t, "/sysroot", remote)
+	assert.Equal(t, deps, rest)
+	assert.Empty(t, args.Trees)
+}
+
+func TestWithSysroot(t *testing.T) {
+	cfg := DefaultConfig
+	argv := []string{"cc", "-o", "hello", "hello.o"}
+	assert.Equal(t, argv, cfg.withSysroot(argv))
+
+	cfg.Sysroot = "/opt/arm"
+	assert.Equal(t, []string{"cc", "--sysroot=/opt/arm", "-o", "hello", "hello.o"}, cfg.withSysroot(argv))
+	assert.Equal(t, []string{"ar", "rcs", "lib.a"}, cfg.withSysroot([]string{"ar", "rcs", "lib.a"}))
+	own := []string{"cc", "--sysroot", "/opt/x86", "-c", "hello.c"}
+	assert.Equal(t, own, cfg.withSysroot(own))
+
+	link, err := ParseLink(&cfg, argv)
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/arm", link.Sysroot)
+	link, err = ParseLink(&cfg, []string{"cc", "--sysroot", "/opt/x86", "hello.o"})
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/x86", link.Sysroot)
+	assert.Equal(t, []LinkArg{{Path: "hello.o"}}, link.Args)
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/nelhage/llama/daemon"
+)
+
+// checkToolchain compares the local compiler with the toolchain
+// recorded on the function, if it records one, since objects from
+// a different compiler version or target may link but misbehave.
+// It warns once per build about a mismatch, or, if configured to,
+// fails every compilation.
+func checkToolchain(client *daemon.Client, cfg *Config, comp *Compilation) error {
+	if cfg.ToolchainCheck == "off" || comp.MSVC {
+		return nil
+	}
+	ccpath, err := exec.LookPath(comp.LocalCompiler(cfg))
+	if err != nil {
+		// We'll report this when we try to preprocess
+		return nil
+	}
+	if ccpath, err = filepath.Abs(ccpath); err != nil {
+		return nil
+	}
+	reply, err := client.CheckToolchain(&daemon.CheckToolchainArgs{
+		Function: cfg.Function,
+		Compiler: ccpath,
+		Target:   cfg.Target,
+	})
+	if err != nil {
+		if cfg.Verbose {
+			log.Printf("[llamacc] checking toolchain: %s", err.Error())
+		}
+		return nil
+	}
+	if reply.Mismatch == "" {
+		return nil
+	}
+	msg := fmt.Sprintf("%s doesn't match function %s: %s", ccpath, cfg.Function, reply.Mismatch)
+	if cfg.ToolchainCheck == "error" {
+		return fmt.Errorf("toolchain mismatch: %s", msg)
+	}
+	if reply.First {
+		fmt.Fprintf(os.Stderr, "[llamacc] warning: %s\n", msg)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "github.com/nelhage/llama/cmd/internal/wrapperutil"
+
+// We upload the tools themselves, so any image with /bin/sh will do;
+// by default, we run them in the one llamacc compiles in.
+var DefaultConfig = wrapperutil.Config{
+	Function: "gcc",
+}
+
+func ParseConfig(env []string) wrapperutil.Config {
+	out := DefaultConfig
+	wrapperutil.ParseEnv("LLAMAGO_", env, out.Set)
+	return out
+}
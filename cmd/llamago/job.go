@@ -0,0 +1,299 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// We upload the tool, the package's sources, and everything else it
+// reads under `_root/`, at their local absolute paths. The tools make
+// source paths absolute before recording them, so we add -trimpath
+// rules to strip the remote job's directory back out; the objects we
+// produce then record the same paths as local ones would.
+//
+// wrapper runs the tool with -trimpath rules for its sources' remote
+// paths, which are only known once the job starts. Its arguments are
+// the rules, relative to the job's directory, then `--`, then the
+// tool's command line.
+const wrapper = `root=$PWD; trim=
+while [ "$1" != -- ]; do trim="$trim;$root/$1"; shift; done
+shift; tool=$1; shift
+exec "$tool" -trimpath "${trim#;}" "$@"`
+
+// toolEnv are the variables which configure what the tools build
+var toolEnv = map[string]bool{
+	"GOOS": true, "GOARCH": true, "GOROOT": true, "GOEXPERIMENT": true,
+	"GO386": true, "GOAMD64": true, "GOARM": true, "GOARM64": true,
+	"GOMIPS": true, "GOMIPS64": true, "GOPPC64": true,
+	"GORISCV64": true, "GOWASM": true,
+}
+
+// remoteTrimPath returns the rules, for the wrapper, which rewrite
+// the remote paths the tool sees as `trimpath` would rewrite their
+// local paths. Paths in GOROOT which no rule matches become
+// `$GOROOT/...`, as the tools do locally, and paths elsewhere get
+// the remote prefix stripped off. A rule with an empty replacement
+// strips the path separator too, so we need one rule for each
+// top-level directory `remote`, the files we upload, live in.
+func remoteTrimPath(trimpath, goroot string, remote files.List, wd string) []string {
+	var rules []string
+	for _, rule := range strings.Split(trimpath, ";") {
+		if rule == "" {
+			continue
+		}
+		prefix, replace := rule, ""
+		if i := strings.Index(rule, "=>"); i >= 0 {
+			prefix, replace = rule[:i], rule[i:]
+		}
+		rules = append(rules, wrapperutil.ToRemote(prefix, wd)+replace)
+	}
+	if goroot != "" {
+		rules = append(rules, wrapperutil.ToRemote(goroot, wd)+"=>$GOROOT")
+	}
+	tops := make(map[string]bool)
+	for _, f := range remote {
+		if elts := strings.SplitN(f.Remote, "/", 3); len(elts) == 3 && elts[0] == wrapperutil.RemoteRoot {
+			tops[elts[1]] = true
+		}
+	}
+	var sorted []string
+	for top := range tops {
+		sorted = append(sorted, top)
+	}
+	sort.Strings(sorted)
+	for _, top := range sorted {
+		rules = append(rules, wrapperutil.RemoteRoot+"/"+top+"=>/"+top)
+	}
+	return rules
+}
+
+// toolGOROOT returns the GOROOT the tool at `path` sees, given the
+// environment `env`
+func toolGOROOT(path string, env []string) string {
+	for _, ev := range env {
+		if strings.HasPrefix(ev, "GOROOT=") {
+			return strings.TrimPrefix(ev, "GOROOT=")
+		}
+	}
+	// $GOROOT/pkg/tool/GOOS_GOARCH/compile
+	dir := filepath.Dir(filepath.Dir(filepath.Dir(path)))
+	if filepath.Base(dir) != "pkg" {
+		return ""
+	}
+	return filepath.Dir(dir)
+}
+
+// rewriteImportCfg rewrites the package files an importcfg names to
+// their remote paths, and returns them
+func rewriteImportCfg(data []byte, wd string) ([]byte, []string) {
+	var out bytes.Buffer
+	var packages []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if rest := strings.TrimPrefix(line, "packagefile "); rest != line {
+			if eq := strings.IndexByte(rest, '='); eq >= 0 {
+				file := strings.TrimRight(rest[eq+1:], "\r\n")
+				packages = append(packages, file)
+				line = "packagefile " + rest[:eq+1] + wrapperutil.ToRemote(file, wd) + rest[eq+1+len(file):]
+			}
+		}
+		out.WriteString(line)
+	}
+	return out.Bytes(), packages
+}
+
+// embedCfg is the format of the compiler's -embedcfg file
+type embedCfg struct {
+	Patterns map[string][]string
+	Files    map[string]string
+}
+
+// rewriteEmbedCfg rewrites the files an embedcfg names to their
+// remote paths, and returns them
+func rewriteEmbedCfg(data []byte, wd string) ([]byte, []string, error) {
+	var cfg embedCfg
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, err
+	}
+	var embedded []string
+	for name, file := range cfg.Files {
+		embedded = append(embedded, file)
+		cfg.Files[name] = wrapperutil.ToRemote(file, wd)
+	}
+	out, err := json.Marshal(&cfg)
+	return out, embedded, err
+}
+
+var includeRe = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*include[ \t]*"([^"]+)"`)
+
+// asmIncludes returns the headers the assembly source `src` includes,
+// directly or not, searching, as the assembler does, the source's
+// directory and then `dirs`.
+func asmIncludes(src string, dirs []string) ([]string, error) {
+	search := append([]string{filepath.Dir(src)}, dirs...)
+	var out []string
+	seen := make(map[string]bool)
+	queue := []string{src}
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range includeRe.FindAllSubmatch(data, -1) {
+			name := string(m[1])
+			found := ""
+			for _, dir := range search {
+				if p := filepath.Join(dir, name); fileExists(p) {
+					found = p
+					break
+				}
+			}
+			if found == "" {
+				return nil, fmt.Errorf("%s: can't find #include %q", file, name)
+			}
+			if !seen[found] {
+				seen[found] = true
+				out = append(out, found)
+				queue = append(queue, found)
+			}
+		}
+	}
+	return out, nil
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.Mode().IsRegular()
+}
+
+// buildInvocation constructs the invocation that runs `tool` remotely
+// in `wd`, with the environment `env`.
+func buildInvocation(cfg *wrapperutil.Config, tool *Tool, env []string, wd string) (*daemon.InvokeWithFilesArgs, error) {
+	args := daemon.InvokeWithFilesArgs{
+		Function:      cfg.Function,
+		Memory:        cfg.Memory,
+		Timeout:       cfg.Timeout,
+		UseCache:      cfg.Cache,
+		Idempotent:    true,
+		DropSemaphore: true,
+		Profile:       wrapperutil.ToAbs(tool.Output(), wd),
+	}
+
+	uploaded := make(map[string]bool)
+	upload := func(local string) {
+		if abs := wrapperutil.ToAbs(local, wd); !uploaded[abs] {
+			uploaded[abs] = true
+			args.Files = args.Files.Append(wrapperutil.Remap(abs, wd))
+		}
+	}
+	// The tool from the local toolchain is the one sure to
+	// produce objects the local linker accepts
+	upload(tool.Path)
+	for _, src := range tool.Sources {
+		upload(src)
+	}
+	for _, in := range tool.Inputs {
+		upload(in.Path)
+	}
+
+	rpath := func(p string) string { return wrapperutil.ToRemote(p, wd) }
+	toolArgs := []string{rpath(tool.Path)}
+	if tool.ImportCfg != "" {
+		data, err := ioutil.ReadFile(tool.ImportCfg)
+		if err != nil {
+			return nil, err
+		}
+		data, packages := rewriteImportCfg(data, wd)
+		for _, p := range packages {
+			upload(p)
+		}
+		args.Files = args.Files.Append(files.Mapped{
+			Local:  files.LocalFile{Bytes: data, Mode: 0644},
+			Remote: rpath(tool.ImportCfg),
+		})
+		toolArgs = append(toolArgs, "-importcfg", rpath(tool.ImportCfg))
+	}
+	if tool.EmbedCfg != "" {
+		data, err := ioutil.ReadFile(tool.EmbedCfg)
+		if err != nil {
+			return nil, err
+		}
+		data, embedded, err := rewriteEmbedCfg(data, wd)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range embedded {
+			upload(f)
+		}
+		args.Files = args.Files.Append(files.Mapped{
+			Local:  files.LocalFile{Bytes: data, Mode: 0644},
+			Remote: rpath(tool.EmbedCfg),
+		})
+		toolArgs = append(toolArgs, "-embedcfg", rpath(tool.EmbedCfg))
+	}
+	var includeDirs []string
+	for _, dir := range tool.IncludeDirs {
+		includeDirs = append(includeDirs, wrapperutil.ToAbs(dir, wd))
+		toolArgs = append(toolArgs, "-I", rpath(dir))
+	}
+	if tool.Name == "asm" {
+		for _, src := range tool.Sources {
+			hdrs, err := asmIncludes(wrapperutil.ToAbs(src, wd), includeDirs)
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range hdrs {
+				upload(h)
+			}
+		}
+	}
+	for _, in := range tool.Inputs {
+		toolArgs = append(toolArgs, "-"+in.Name, rpath(in.Path))
+	}
+	for _, out := range tool.Outputs {
+		args.Outputs = args.Outputs.Append(wrapperutil.Remap(out.Path, wd))
+		toolArgs = append(toolArgs, "-"+out.Name, rpath(out.Path))
+	}
+	toolArgs = append(toolArgs, tool.Args...)
+	for _, src := range tool.Sources {
+		toolArgs = append(toolArgs, rpath(src))
+	}
+
+	for _, ev := range env {
+		if eq := strings.IndexByte(ev, '='); eq >= 0 && toolEnv[ev[:eq]] {
+			args.Env = append(args.Env, ev)
+		}
+	}
+
+	args.Args = []string{"/bin/sh", "-c", wrapper, "llamago"}
+	args.Args = append(args.Args, remoteTrimPath(tool.TrimPath, toolGOROOT(tool.Path, env), args.Files, wd)...)
+	args.Args = append(args.Args, "--")
+	args.Args = append(args.Args, toolArgs...)
+	return &args, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg := ParseConfig([]string{
+		"PATH=/bin",
+		"LLAMAGO_FUNCTION=go",
+		"LLAMAGO_FALLBACK=1",
+		"LLAMAGO_TIMEOUT=5m",
+	})
+	assert.Equal(t, "go", cfg.Function)
+	assert.True(t, cfg.Fallback)
+	assert.Equal(t, 5*time.Minute, cfg.Timeout)
+}
+
+func TestRemoteTrimPath(t *testing.T) {
+	uploaded := files.List{
+		wrapperutil.Remap("/usr/local/go/src/fmt/print.go", "/src"),
+		wrapperutil.Remap("/src/pkg/main.go", "/src"),
+		wrapperutil.Remap("/tmp/go-build1/b001/importcfg", "/src"),
+		wrapperutil.Remap("/src/pkg/util.go", "/src"),
+	}
+	assert.Equal(t, []string{
+		"_root/tmp/go-build1/b001=>",
+		"_root/src/pkg=>example.com/pkg",
+		"_root/src/vendor",
+		"_root/usr/local/go=>$GOROOT",
+		"_root/src=>/src",
+		"_root/tmp=>/tmp",
+		"_root/usr=>/usr",
+	}, remoteTrimPath("/tmp/go-build1/b001=>;/src/pkg=>example.com/pkg;vendor", "/usr/local/go", uploaded, "/src"))
+	assert.Equal(t, []string(nil), remoteTrimPath("", "", nil, "/src"))
+}
+
+func TestToolGOROOT(t *testing.T) {
+	assert.Equal(t, "/usr/local/go", toolGOROOT("/usr/local/go/pkg/tool/linux_amd64/compile", nil))
+	assert.Equal(t, "/opt/go", toolGOROOT("/usr/local/go/pkg/tool/linux_amd64/compile", []string{"GOROOT=/opt/go"}))
+	assert.Equal(t, "", toolGOROOT("/usr/bin/compile", nil))
+}
+
+func TestAsmIncludes(t *testing.T) {
+	dir := t.TempDir()
+	inc := filepath.Join(dir, "include")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pkg", "cgo"), 0755))
+	require.NoError(t, os.MkdirAll(inc, 0755))
+	write := func(file, data string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, file), []byte(data), 0644))
+	}
+	write("pkg/add_amd64.s", "#include \"textflag.h\"\n  #include \"cgo/abi.h\"\nTEXT ·add(SB),NOSPLIT,$0\n")
+	write("pkg/cgo/abi.h", "#include \"funcdata.h\"\n")
+	write("include/textflag.h", "")
+	write("include/funcdata.h", "#include \"textflag.h\"\n")
+
+	hdrs, err := asmIncludes(filepath.Join(dir, "pkg", "add_amd64.s"), []string{inc})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(inc, "textflag.h"),
+		filepath.Join(dir, "pkg", "cgo", "abi.h"),
+		filepath.Join(inc, "funcdata.h"),
+	}, hdrs)
+
+	_, err = asmIncludes(filepath.Join(dir, "pkg", "add_amd64.s"), nil)
+	assert.Error(t, err)
+}
+
+func TestRewriteImportCfg(t *testing.T) {
+	data, packages := rewriteImportCfg([]byte(`# import config
+packagefile fmt=/home/me/.cache/go-build/ab/abcd-d
+importmap golang.org/x/net=vendor/golang.org/x/net
+packagefile example.com/lib=/tmp/go-build1/b002/_pkg_.a
+`), "/src")
+	assert.Equal(t, `# import config
+packagefile fmt=_root/home/me/.cache/go-build/ab/abcd-d
+importmap golang.org/x/net=vendor/golang.org/x/net
+packagefile example.com/lib=_root/tmp/go-build1/b002/_pkg_.a
+`, string(data))
+	assert.Equal(t, []string{"/home/me/.cache/go-build/ab/abcd-d", "/tmp/go-build1/b002/_pkg_.a"}, packages)
+}
+
+func TestRewriteEmbedCfg(t *testing.T) {
+	data, embedded, err := rewriteEmbedCfg([]byte(`{"Patterns":{"static/*":["static/a.txt"]},"Files":{"static/a.txt":"/src/pkg/static/a.txt"}}`), "/src")
+	require.NoError(t, err)
+	var cfg embedCfg
+	require.NoError(t, json.Unmarshal(data, &cfg))
+	assert.Equal(t, embedCfg{
+		Patterns: map[string][]string{"static/*": {"static/a.txt"}},
+		Files:    map[string]string{"static/a.txt": "_root/src/pkg/static/a.txt"},
+	}, cfg)
+	assert.Equal(t, []string{"/src/pkg/static/a.txt"}, embedded)
+
+	_, _, err = rewriteEmbedCfg([]byte("{"), "/src")
+	assert.Error(t, err)
+}
+
+func TestBuildInvocation(t *testing.T) {
+	wd := t.TempDir()
+	work := filepath.Join(wd, "b001")
+	require.NoError(t, os.MkdirAll(work, 0755))
+	for _, f := range []string{"compile", "main.go", "lib.a", "b001/go_asm.h", "b001/symabis", "add_amd64.s"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(wd, f), nil, 0755))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(work, "importcfg"),
+		[]byte("packagefile lib="+filepath.Join(wd, "lib.a")+"\n"), 0644))
+
+	tool, err := ParseTool([]string{
+		filepath.Join(wd, "compile"), "-o", filepath.Join(work, "_pkg_.a"),
+		"-trimpath", work + "=>", "-p", "main",
+		"-importcfg", filepath.Join(work, "importcfg"),
+		"-symabis", filepath.Join(work, "symabis"), "./main.go",
+	})
+	require.NoError(t, err)
+	cfg := DefaultConfig
+	args, err := buildInvocation(&cfg, &tool, []string{"HOME=/home/me", "GOARCH=arm64", "GOAMD64=v3"}, wd)
+	require.NoError(t, err)
+
+	rwd := wrapperutil.ToRemote(wd, wd)
+	assert.Equal(t, []string{
+		"/bin/sh", "-c", wrapper, "llamago",
+		rwd + "/b001=>", "_root/" + strings.Split(wd, "/")[1] + "=>/" + strings.Split(wd, "/")[1], "--",
+		rwd + "/compile",
+		"-importcfg", rwd + "/b001/importcfg",
+		"-symabis", rwd + "/b001/symabis",
+		"-o", rwd + "/b001/_pkg_.a",
+		"-p", "main",
+		rwd + "/main.go",
+	}, args.Args)
+	assert.Equal(t, files.List{
+		wrapperutil.Remap(filepath.Join(wd, "compile"), wd),
+		wrapperutil.Remap(filepath.Join(wd, "main.go"), wd),
+		wrapperutil.Remap(filepath.Join(work, "symabis"), wd),
+		wrapperutil.Remap(filepath.Join(wd, "lib.a"), wd),
+		{
+			Local:  files.LocalFile{Bytes: []byte("packagefile lib=" + rwd + "/lib.a\n"), Mode: 0644},
+			Remote: rwd + "/b001/importcfg",
+		},
+	}, args.Files)
+	assert.Equal(t, files.List{wrapperutil.Remap(filepath.Join(work, "_pkg_.a"), wd)}, args.Outputs)
+	assert.Equal(t, []string{"GOARCH=arm64", "GOAMD64=v3"}, args.Env)
+
+	tool, err = ParseTool([]string{
+		filepath.Join(wd, "asm"), "-o", filepath.Join(work, "add.o"),
+		"-I", work, "./add_amd64.s",
+	})
+	require.NoError(t, err)
+	args, err = buildInvocation(&cfg, &tool, nil, wd)
+	require.NoError(t, err)
+	assert.NotContains(t, args.Files, wrapperutil.Remap(filepath.Join(work, "go_asm.h"), wd))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "add_amd64.s"), []byte("#include \"go_asm.h\"\n"), 0644))
+	args, err = buildInvocation(&cfg, &tool, nil, wd)
+	require.NoError(t, err)
+	assert.Contains(t, args.Files, wrapperutil.Remap(filepath.Join(work, "go_asm.h"), wd))
+}
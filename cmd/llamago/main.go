@@ -0,0 +1,60 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/files"
+)
+
+func runRemote(cfg *wrapperutil.Config, argv []string) (int, error) {
+	if runtime.GOOS != "linux" {
+		// We run the local toolchain's own binaries remotely
+		return 0, fmt.Errorf("the toolchain doesn't run on Lambda: %s", runtime.GOOS)
+	}
+	tool, err := ParseTool(argv)
+	if err != nil {
+		return 0, err
+	}
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return 0, err
+	}
+	args, err := buildInvocation(cfg, &tool, os.Environ(), wd)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Verbose {
+		log.Printf("[llamago] running %s remotely: %q", tool.Name, args.Args)
+	}
+	return wrapperutil.Invoke(args, true)
+}
+
+func main() {
+	cfg := ParseConfig(os.Environ())
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: go build -toolexec llamago ...\n")
+		os.Exit(2)
+	}
+	argv := os.Args[1:]
+	wrapperutil.Main("llamago", &cfg, argv, func() (int, error) {
+		return runRemote(&cfg, argv)
+	})
+}
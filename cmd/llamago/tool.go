@@ -0,0 +1,183 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// A Tool is an invocation of `go tool compile` or `go tool asm`, as
+// the go command runs it, which builds one package's object from its
+// sources.
+type Tool struct {
+	// "compile" or "asm"
+	Name string
+	// The tool's executable
+	Path    string
+	Sources []string
+	// The files the tool writes, with the flags naming them
+	Outputs []Flag
+	// Other files the tool reads, with the flags naming them
+	Inputs    []Flag
+	ImportCfg string
+	EmbedCfg  string
+	// Include directories, from -I
+	IncludeDirs []string
+	TrimPath    string
+	// Everything else, passed through unchanged
+	Args []string
+}
+
+// A Flag is a flag which names a file
+type Flag struct {
+	Name string
+	Path string
+}
+
+// toolValueFlags are the flags of each tool which take a value,
+// which may be the next argument
+var toolValueFlags = map[string]map[string]bool{
+	"compile": {
+		"o": true, "p": true, "lang": true, "goversion": true,
+		"buildid": true, "importcfg": true, "embedcfg": true,
+		"symabis": true, "asmhdr": true, "linkobj": true,
+		"trimpath": true, "D": true, "I": true, "c": true,
+		"pgoprofile": true, "spectre": true, "coveragecfg": true,
+		"installsuffix": true, "d": true, "env": true, "json": true,
+		"bench": true, "cpuprofile": true, "memprofile": true,
+		"memprofilerate": true, "mutexprofile": true,
+		"blockprofile": true, "traceprofile": true,
+		"importmap": true, "gendwarfinl": true,
+	},
+	"asm": {
+		"o": true, "p": true, "trimpath": true, "I": true, "D": true,
+		"spectre": true,
+	},
+}
+
+// toolUnsupported are the flags which write files other than the
+// ones we know about, or read files we wouldn't upload
+var toolUnsupported = map[string]bool{
+	"V": true, "json": true, "bench": true, "cpuprofile": true,
+	"memprofile": true, "mutexprofile": true, "blockprofile": true,
+	"traceprofile": true, "coveragecfg": true, "importmap": true,
+}
+
+// toolSourceExt is the extension of each tool's source files
+var toolSourceExt = map[string]string{
+	"compile": ".go",
+	"asm":     ".s",
+}
+
+// toolName returns the name of the tool at `path`, like "compile"
+func toolName(path string) string {
+	name := filepath.Base(path)
+	if ext := filepath.Ext(name); strings.EqualFold(ext, ".exe") {
+		name = name[:len(name)-len(ext)]
+	}
+	return name
+}
+
+// ParseTool parses `argv`, the command line the go command passes
+// its -toolexec program. Only compile and asm invocations which build
+// a package from source are supported; the go command runs others,
+// like the linker and `compile -V=full`, which it uses to identify
+// the toolchain, locally.
+func ParseTool(argv []string) (Tool, error) {
+	out := Tool{Name: toolName(argv[0]), Path: argv[0]}
+	valueFlags, ok := toolValueFlags[out.Name]
+	if !ok {
+		return out, fmt.Errorf("unsupported tool: %s", out.Name)
+	}
+	args := argv[1:]
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			// Like the flag package, we stop at the first
+			// argument that isn't a flag
+			break
+		}
+		name, val, hasVal := strings.TrimLeft(arg, "-"), "", false
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			name, val, hasVal = name[:eq], name[eq+1:], true
+		}
+		if toolUnsupported[name] {
+			return out, fmt.Errorf("unsupported flag: %s", arg)
+		}
+		if !valueFlags[name] {
+			out.Args = append(out.Args, arg)
+			continue
+		}
+		if !hasVal {
+			if i+1 == len(args) {
+				return out, fmt.Errorf("missing argument to %s", arg)
+			}
+			i++
+			val = args[i]
+		}
+		switch name {
+		case "o", "linkobj", "asmhdr":
+			out.Outputs = append(out.Outputs, Flag{name, val})
+		case "symabis", "pgoprofile":
+			out.Inputs = append(out.Inputs, Flag{name, val})
+		case "importcfg":
+			out.ImportCfg = val
+		case "embedcfg":
+			out.EmbedCfg = val
+		case "I":
+			out.IncludeDirs = append(out.IncludeDirs, val)
+		case "trimpath":
+			out.TrimPath = val
+		default:
+			out.Args = append(out.Args, "-"+name, val)
+		}
+	}
+	for _, src := range args[i:] {
+		if filepath.Ext(src) != toolSourceExt[out.Name] {
+			return out, fmt.Errorf("unsupported input: %s", src)
+		}
+		out.Sources = append(out.Sources, src)
+	}
+	if len(out.Sources) == 0 {
+		return out, errors.New("no source files")
+	}
+	if out.Output() == "" {
+		return out, errors.New("no -o")
+	}
+	if out.Name == "compile" && out.ImportCfg == "" {
+		// Without it, the compiler looks for packages in
+		// GOROOT and GOPATH, which we can't follow
+		return out, errors.New("no -importcfg")
+	}
+	return out, nil
+}
+
+// Output returns the tool's main output, from -o
+func (t *Tool) Output() string {
+	for _, f := range t.Outputs {
+		if f.Name == "o" {
+			return f.Path
+		}
+	}
+	return ""
+}
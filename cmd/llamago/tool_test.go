@@ -0,0 +1,98 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTool(t *testing.T) {
+	tests := []struct {
+		argv []string
+		out  Tool
+		err  bool
+	}{
+		{
+			[]string{
+				"/usr/lib/go/pkg/tool/linux_amd64/compile",
+				"-o", "/tmp/go-build1/b001/_pkg_.a", "-trimpath", "/tmp/go-build1/b001=>",
+				"-p", "main", "-lang=go1.21", "-complete", "-buildid", "abc/abc",
+				"-goversion", "go1.21.0", "-c=4", "-nolocalimports",
+				"-importcfg", "/tmp/go-build1/b001/importcfg", "-pack",
+				"-asmhdr", "/tmp/go-build1/b001/go_asm.h",
+				"-symabis", "/tmp/go-build1/b001/symabis",
+				"./main.go", "./util.go",
+			},
+			Tool{
+				Name:    "compile",
+				Path:    "/usr/lib/go/pkg/tool/linux_amd64/compile",
+				Sources: []string{"./main.go", "./util.go"},
+				Outputs: []Flag{
+					{"o", "/tmp/go-build1/b001/_pkg_.a"},
+					{"asmhdr", "/tmp/go-build1/b001/go_asm.h"},
+				},
+				Inputs:    []Flag{{"symabis", "/tmp/go-build1/b001/symabis"}},
+				ImportCfg: "/tmp/go-build1/b001/importcfg",
+				TrimPath:  "/tmp/go-build1/b001=>",
+				Args: []string{
+					"-p", "main", "-lang", "go1.21", "-complete", "-buildid", "abc/abc",
+					"-goversion", "go1.21.0", "-c", "4", "-nolocalimports", "-pack",
+				},
+			},
+			false,
+		},
+		{
+			[]string{
+				"/usr/lib/go/pkg/tool/linux_amd64/asm", "-p", "main",
+				"-trimpath", "/tmp/go-build1/b001=>", "-I", "/tmp/go-build1/b001/",
+				"-I", "/usr/lib/go/pkg/include", "-D", "GOOS_linux", "-gensymabis",
+				"-o", "/tmp/go-build1/b001/symabis", "./add_amd64.s",
+			},
+			Tool{
+				Name:        "asm",
+				Path:        "/usr/lib/go/pkg/tool/linux_amd64/asm",
+				Sources:     []string{"./add_amd64.s"},
+				Outputs:     []Flag{{"o", "/tmp/go-build1/b001/symabis"}},
+				IncludeDirs: []string{"/tmp/go-build1/b001/", "/usr/lib/go/pkg/include"},
+				TrimPath:    "/tmp/go-build1/b001=>",
+				Args:        []string{"-p", "main", "-D", "GOOS_linux", "-gensymabis"},
+			},
+			false,
+		},
+		{[]string{"compile", "-V=full"}, Tool{}, true},
+		{[]string{"link", "-o", "a.out", "_pkg_.a"}, Tool{}, true},
+		{[]string{"compile", "-o", "_pkg_.a", "main.go"}, Tool{}, true},
+		{[]string{"compile", "-importcfg", "importcfg", "main.go"}, Tool{}, true},
+		{[]string{"compile", "-o", "_pkg_.a", "-importcfg", "importcfg", "-json", "0,x.json", "main.go"}, Tool{}, true},
+		{[]string{"asm", "-o", "x.o", "x.go"}, Tool{}, true},
+		{[]string{"asm", "-o"}, Tool{}, true},
+	}
+	for i, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := ParseTool(tc.argv)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &tc.out, &got)
+		})
+	}
+}
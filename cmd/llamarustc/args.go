@@ -0,0 +1,265 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// A Compilation is an invocation of rustc, as cargo runs it, which
+// builds a library crate.
+type Compilation struct {
+	Input     string
+	CrateName string
+	CrateType string
+	OutDir    string
+	// The suffix cargo gives output file names, from
+	// `-C extra-filename`
+	ExtraFilename string
+	Emit          []Emit
+	Externs       []Extern
+	LibDirs       []LibDir
+	// Everything else, passed through unchanged
+	Args []string
+}
+
+// An Emit is an output kind given to `--emit`, with the path to
+// write it to, if given
+type Emit struct {
+	Kind string
+	Path string
+}
+
+// An Extern is a crate given with `--extern`. Name includes any
+// modifiers, like `priv:`; Path is empty for crates in the sysroot.
+type Extern struct {
+	Name string
+	Path string
+}
+
+// A LibDir is a directory given with `-L` which rustc searches for
+// crates
+type LibDir struct {
+	// "dependency", "crate", or "all"
+	Kind string
+	Path string
+}
+
+// rustcValueOpts are the long options which take a value, which may
+// be the next argument
+var rustcValueOpts = map[string]bool{
+	"--crate-name": true, "--crate-type": true, "--edition": true,
+	"--emit": true, "--out-dir": true, "--extern": true,
+	"--cfg": true, "--check-cfg": true, "--cap-lints": true,
+	"--error-format": true, "--json": true, "--target": true,
+	"--print": true, "--explain": true, "--sysroot": true,
+	"--color": true, "--diagnostic-width": true,
+	"--remap-path-prefix": true, "--force-warn": true,
+	"--codegen": true, "--warn": true, "--allow": true,
+	"--deny": true, "--forbid": true, "--env-set": true,
+}
+
+// rustcShortOpts are the short options which take a value, either
+// attached or as the next argument
+const rustcShortOpts = "CZLlAWDFo"
+
+// rustcAliases maps long options to the short options we handle
+// them as
+var rustcAliases = map[string]string{
+	"--codegen": "-C",
+	"--warn":    "-W",
+	"--allow":   "-A",
+	"--deny":    "-D",
+	"--forbid":  "-F",
+}
+
+// rustcEmitFiles maps the output kinds we support to the prefix and
+// extension of the file they produce in the output directory.
+// Notably, `link` produces an rlib, because we only compile library
+// crates.
+var rustcEmitFiles = map[string][2]string{
+	"dep-info": {"", ".d"},
+	"metadata": {"lib", ".rmeta"},
+	"link":     {"lib", ".rlib"},
+}
+
+// ParseRustc parses `argv`, a rustc command line as cargo passes it
+// to RUSTC_WRAPPER. Only compilations of a single library crate to
+// rlib and rmeta files are supported; in particular, anything that
+// links, build scripts and procedural macros among them, is not.
+func ParseRustc(argv []string) (Compilation, error) {
+	var out Compilation
+	args := argv[1:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		opt, val, hasVal := arg, "", false
+		switch {
+		case arg == "-":
+			return out, errors.New("reading source from stdin")
+		case strings.HasPrefix(arg, "@"):
+			return out, fmt.Errorf("unsupported argument file: %s", arg)
+		case strings.HasPrefix(arg, "--"):
+			if eq := strings.IndexByte(arg, '='); eq > 0 {
+				opt, val, hasVal = arg[:eq], arg[eq+1:], true
+			}
+			if !rustcValueOpts[opt] {
+				opt, val, hasVal = arg, "", false
+				break
+			}
+			if !hasVal {
+				if i+1 == len(args) {
+					return out, fmt.Errorf("missing argument to %s", opt)
+				}
+				i++
+				val, hasVal = args[i], true
+			}
+			if short, ok := rustcAliases[opt]; ok {
+				opt = short
+			}
+		case len(arg) >= 2 && arg[0] == '-' && strings.IndexByte(rustcShortOpts, arg[1]) >= 0:
+			opt, val, hasVal = arg[:2], arg[2:], true
+			if val == "" {
+				if i+1 == len(args) {
+					return out, fmt.Errorf("missing argument to %s", opt)
+				}
+				i++
+				val = args[i]
+			}
+		}
+		if err := out.parseOpt(arg, opt, val, hasVal); err != nil {
+			return out, err
+		}
+	}
+	if out.Input == "" {
+		return out, errors.New("no input file")
+	}
+	if out.CrateType != "lib" && out.CrateType != "rlib" {
+		return out, errors.New("not a library crate")
+	}
+	if out.CrateName == "" {
+		return out, errors.New("no crate name")
+	}
+	if len(out.Emit) == 0 {
+		out.Emit = []Emit{{Kind: "link"}}
+	}
+	return out, nil
+}
+
+func (c *Compilation) parseOpt(arg, opt, val string, hasVal bool) error {
+	switch opt {
+	case "--crate-name":
+		c.CrateName = val
+	case "--crate-type":
+		if c.CrateType != "" {
+			return fmt.Errorf("multiple crate types: %s, %s", c.CrateType, val)
+		}
+		c.CrateType = val
+	case "--emit":
+		for _, kind := range strings.Split(val, ",") {
+			var e Emit
+			e.Kind = kind
+			if eq := strings.IndexByte(kind, '='); eq >= 0 {
+				e.Kind, e.Path = kind[:eq], kind[eq+1:]
+			}
+			if _, ok := rustcEmitFiles[e.Kind]; !ok {
+				return fmt.Errorf("unsupported output kind: %s", kind)
+			}
+			c.Emit = append(c.Emit, e)
+		}
+	case "--out-dir":
+		c.OutDir = val
+	case "--extern":
+		e := Extern{Name: val}
+		if eq := strings.IndexByte(val, '='); eq >= 0 {
+			e.Name, e.Path = val[:eq], val[eq+1:]
+		}
+		c.Externs = append(c.Externs, e)
+	case "-L":
+		kind, dir := "all", val
+		if eq := strings.IndexByte(val, '='); eq >= 0 {
+			kind, dir = val[:eq], val[eq+1:]
+		}
+		switch kind {
+		case "dependency", "crate", "all":
+			c.LibDirs = append(c.LibDirs, LibDir{kind, dir})
+		default:
+			// Native libraries only matter when linking
+			c.Args = append(c.Args, opt, val)
+		}
+	case "-l":
+		if strings.HasPrefix(val, "static") {
+			// Bundled into the rlib, so we'd need to upload
+			// it
+			return fmt.Errorf("unsupported static library: %s", val)
+		}
+		c.Args = append(c.Args, opt, val)
+	case "-C":
+		switch {
+		case strings.HasPrefix(val, "incremental="):
+			// The remote compiler starts afresh every time
+		case strings.HasPrefix(val, "extra-filename="):
+			c.ExtraFilename = strings.TrimPrefix(val, "extra-filename=")
+			c.Args = append(c.Args, opt, val)
+		default:
+			c.Args = append(c.Args, opt, val)
+		}
+	case "-o", "--print", "--explain", "--sysroot", "--test", "--version", "-V", "-vV", "--help", "-h":
+		return fmt.Errorf("unsupported option: %s", arg)
+	case "--target":
+		if strings.HasSuffix(val, ".json") {
+			return fmt.Errorf("unsupported custom target: %s", val)
+		}
+		c.Args = append(c.Args, opt, val)
+	default:
+		switch {
+		case hasVal:
+			c.Args = append(c.Args, opt, val)
+		case strings.HasPrefix(arg, "-"):
+			c.Args = append(c.Args, arg)
+		case c.Input != "":
+			return fmt.Errorf("multiple inputs: %s, %s", c.Input, arg)
+		default:
+			c.Input = arg
+		}
+	}
+	return nil
+}
+
+// Output returns the file `e` is written to
+func (c *Compilation) Output(e Emit) string {
+	if e.Path != "" {
+		return e.Path
+	}
+	dir := c.OutDir
+	if dir == "" {
+		dir = "."
+	}
+	file := rustcEmitFiles[e.Kind]
+	return filepath.Join(dir, file[0]+c.CrateName+c.ExtraFilename+file[1])
+}
+
+// DepInfo returns the dep-info file cargo asked for, or "" if it
+// asked for none
+func (c *Compilation) DepInfo() string {
+	for _, e := range c.Emit {
+		if e.Kind == "dep-info" {
+			return c.Output(e)
+		}
+	}
+	return ""
+}
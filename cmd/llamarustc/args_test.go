@@ -0,0 +1,111 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRustc(t *testing.T) {
+	tests := []struct {
+		argv []string
+		out  Compilation
+		err  bool
+	}{
+		{
+			[]string{
+				"rustc", "--crate-name", "foo", "--edition=2021", "src/lib.rs",
+				"--error-format=json", "--json=diagnostic-rendered-ansi,artifacts",
+				"--crate-type", "lib", "--emit=dep-info,metadata,link",
+				"-C", "debuginfo=2", "-C", "metadata=0123", "-C", "extra-filename=-0123",
+				"--out-dir", "/p/target/debug/deps",
+				"-C", "incremental=/p/target/debug/incremental",
+				"-L", "dependency=/p/target/debug/deps",
+				"--extern", "bar=/p/target/debug/deps/libbar-4567.rmeta",
+				"--extern", "proc_macro",
+				"-Lnative=/usr/lib/foo", "--cap-lints", "allow", "-Dwarnings",
+			},
+			Compilation{
+				Input:         "src/lib.rs",
+				CrateName:     "foo",
+				CrateType:     "lib",
+				OutDir:        "/p/target/debug/deps",
+				ExtraFilename: "-0123",
+				Emit:          []Emit{{Kind: "dep-info"}, {Kind: "metadata"}, {Kind: "link"}},
+				Externs: []Extern{
+					{"bar", "/p/target/debug/deps/libbar-4567.rmeta"},
+					{"proc_macro", ""},
+				},
+				LibDirs: []LibDir{{"dependency", "/p/target/debug/deps"}},
+				Args: []string{
+					"--edition", "2021",
+					"--error-format", "json", "--json", "diagnostic-rendered-ansi,artifacts",
+					"-C", "debuginfo=2", "-C", "metadata=0123", "-C", "extra-filename=-0123",
+					"-L", "native=/usr/lib/foo", "--cap-lints", "allow", "-D", "warnings",
+				},
+			},
+			false,
+		},
+		{
+			[]string{"rustc", "--crate-name=baz", "--crate-type=rlib", "lib.rs", "--emit=metadata=out/baz.rmeta", "-v", "--codegen", "opt-level=3"},
+			Compilation{
+				Input:     "lib.rs",
+				CrateName: "baz",
+				CrateType: "rlib",
+				Emit:      []Emit{{"metadata", "out/baz.rmeta"}},
+				Args:      []string{"-v", "-C", "opt-level=3"},
+			},
+			false,
+		},
+		{[]string{"rustc", "-vV"}, Compilation{}, true},
+		{[]string{"rustc", "-", "--crate-name", "___", "--print=file-names"}, Compilation{}, true},
+		{[]string{"rustc", "--crate-name", "build_script_build", "build.rs", "--crate-type", "bin"}, Compilation{}, true},
+		{[]string{"rustc", "--crate-name", "derive", "src/lib.rs", "--crate-type", "proc-macro"}, Compilation{}, true},
+		{[]string{"rustc", "--crate-name", "foo", "src/lib.rs", "--crate-type", "lib", "--emit=llvm-ir"}, Compilation{}, true},
+		{[]string{"rustc", "--crate-name", "foo", "src/lib.rs", "--crate-type", "lib", "-l", "static=foo"}, Compilation{}, true},
+		{[]string{"rustc", "--crate-name", "foo", "src/lib.rs", "--crate-type", "lib", "--test"}, Compilation{}, true},
+		{[]string{"rustc", "--crate-name", "foo", "src/lib.rs", "--crate-type", "lib", "-o", "foo.rlib"}, Compilation{}, true},
+		{[]string{"rustc", "@args.txt"}, Compilation{}, true},
+		{[]string{"rustc", "--crate-name", "foo", "a.rs", "b.rs", "--crate-type", "lib"}, Compilation{}, true},
+	}
+	for i, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := ParseRustc(tc.argv)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &tc.out, &got)
+		})
+	}
+}
+
+func TestOutput(t *testing.T) {
+	comp := Compilation{CrateName: "foo", ExtraFilename: "-0123", OutDir: "deps"}
+	assert.Equal(t, filepath.Join("deps", "foo-0123.d"), comp.Output(Emit{Kind: "dep-info"}))
+	assert.Equal(t, filepath.Join("deps", "libfoo-0123.rmeta"), comp.Output(Emit{Kind: "metadata"}))
+	assert.Equal(t, filepath.Join("deps", "libfoo-0123.rlib"), comp.Output(Emit{Kind: "link"}))
+	assert.Equal(t, "x.rlib", comp.Output(Emit{"link", "x.rlib"}))
+	assert.Equal(t, "", comp.DepInfo())
+	comp.Emit = []Emit{{Kind: "metadata"}, {Kind: "dep-info"}}
+	assert.Equal(t, filepath.Join("deps", "foo-0123.d"), comp.DepInfo())
+}
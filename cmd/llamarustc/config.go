@@ -0,0 +1,50 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "github.com/nelhage/llama/cmd/internal/wrapperutil"
+
+type Config struct {
+	wrapperutil.Config
+
+	// The rustc to run remotely
+	RemoteRustc string
+	// Crates to always compile locally, such as those whose
+	// procedural macros read files rustc doesn't know about
+	LocalCrates []string
+}
+
+// We compile in the "rustc" function by default, whose image needs
+// the same version of rustc as we run locally.
+var DefaultConfig = Config{
+	Config:      wrapperutil.Config{Function: "rustc"},
+	RemoteRustc: "rustc",
+}
+
+func ParseConfig(env []string) Config {
+	out := DefaultConfig
+	wrapperutil.ParseEnv("LLAMARUSTC_", env, func(key, val string) (bool, error) {
+		switch key {
+		case "REMOTE_RUSTC":
+			out.RemoteRustc = val
+		case "LOCAL_CRATES":
+			out.LocalCrates = wrapperutil.SplitList(val)
+		default:
+			return out.Config.Set(key, val)
+		}
+		return true, nil
+	})
+	return out
+}
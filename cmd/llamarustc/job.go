@@ -0,0 +1,287 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// We upload the crate's sources and dependencies under `_root/`, at
+// their local absolute paths, and remap that prefix back out of the
+// paths rustc records in the crate, so that diagnostics, panics, and
+// debug info name the local files.
+//
+// wrapper points the variables holding paths which a crate may
+// include files relative to, like OUT_DIR, at their remote copies,
+// which have absolute paths only known once the job starts. Its
+// arguments are the variables, each as NAME=/local/path, then `--`,
+// then rustc's command line.
+const wrapper = `root=$PWD/_root
+while [ "$1" != -- ]; do export "${1%%=*}=$root${1#*=}"; shift; done
+shift; exec "$@" "--remap-path-prefix=$root=/"`
+
+// pathEnv are the variables cargo sets to local paths
+var pathEnv = map[string]bool{
+	"OUT_DIR":             true,
+	"CARGO_MANIFEST_DIR":  true,
+	"CARGO_MANIFEST_PATH": true,
+}
+
+// A depInfo is what we learn from the dep-info file rustc writes
+type depInfo struct {
+	// The source files the crate reads, including those named
+	// by `include!` and friends
+	Files []string
+	// The environment variables the crate reads with `env!` and
+	// `option_env!`
+	Env []string
+}
+
+func parseDepInfo(data []byte) depInfo {
+	var out depInfo
+	seen := make(map[string]bool)
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	scan.Buffer(nil, len(data)+1)
+	for scan.Scan() {
+		line := strings.TrimSuffix(scan.Text(), "\r")
+		switch {
+		case strings.HasPrefix(line, "# env-dep:"):
+			name := strings.TrimPrefix(line, "# env-dep:")
+			if eq := strings.IndexByte(name, '='); eq >= 0 {
+				name = name[:eq]
+			}
+			out.Env = append(out.Env, name)
+		case strings.HasPrefix(line, "#"):
+		case strings.HasSuffix(line, ":") && !strings.Contains(line, ": "):
+			// Each file is also listed as a target of its own,
+			// with no dependencies
+			file := strings.ReplaceAll(strings.TrimSuffix(line, ":"), `\ `, " ")
+			if !seen[file] {
+				seen[file] = true
+				out.Files = append(out.Files, file)
+			}
+		}
+	}
+	return out
+}
+
+// scanArgs returns the arguments to the local rustc which write
+// the crate's dep-info to `depfile`, without compiling it
+func (c *Compilation) scanArgs(depfile string) []string {
+	args := []string{c.Input, "--crate-name", c.CrateName, "--crate-type", c.CrateType}
+	for _, e := range c.Externs {
+		if e.Path == "" {
+			args = append(args, "--extern", e.Name)
+		} else {
+			args = append(args, "--extern", e.Name+"="+e.Path)
+		}
+	}
+	for _, l := range c.LibDirs {
+		args = append(args, "-L", l.Kind+"="+l.Path)
+	}
+	args = append(args, c.Args...)
+	return append(args, "--emit=dep-info="+depfile)
+}
+
+// scanDependencies runs the local rustc, `rustc`, to write the
+// crate's dep-info to `depfile`, and parses it. This expands macros,
+// but stops well short of type checking and code generation.
+func scanDependencies(rustc string, comp *Compilation, depfile string) (depInfo, error) {
+	cmd := exec.Command(rustc, comp.scanArgs(depfile)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return depInfo{}, fmt.Errorf("scanning dependencies: %w: %s", err, stderr.Bytes())
+	}
+	data, err := ioutil.ReadFile(depfile)
+	if err != nil {
+		return depInfo{}, err
+	}
+	return parseDepInfo(data), nil
+}
+
+// crateFiles returns the crates in `dir` that rustc may load when
+// resolving the dependencies of the crates named with `--extern`.
+// Compiling a library only needs their metadata.
+func crateFiles(dir string) ([]string, error) {
+	ents, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	meta := make(map[string]bool)
+	for _, ent := range ents {
+		if filepath.Ext(ent.Name()) == ".rmeta" {
+			meta[strings.TrimSuffix(ent.Name(), ".rmeta")] = true
+		}
+	}
+	var out []string
+	for _, ent := range ents {
+		name := ent.Name()
+		switch filepath.Ext(name) {
+		case ".rmeta":
+		case ".rlib":
+			if meta[strings.TrimSuffix(name, ".rlib")] {
+				continue
+			}
+		default:
+			continue
+		}
+		out = append(out, filepath.Join(dir, name))
+	}
+	return out, nil
+}
+
+// passEnv returns true if the remote compiler needs the variable
+// `name` from our environment
+func passEnv(name string, deps *depInfo) bool {
+	if strings.HasPrefix(name, "CARGO_") || name == "RUSTC_BOOTSTRAP" {
+		return true
+	}
+	for _, dep := range deps.Env {
+		if dep == name {
+			return true
+		}
+	}
+	return false
+}
+
+// buildInvocation constructs the invocation that compiles `comp`
+// remotely, given the dependencies `deps` its dep-info lists, the
+// environment `env` cargo ran us with, and our working directory.
+// The dep-info itself was already written by the scan, so we don't
+// ask the remote compiler for it.
+func buildInvocation(cfg *Config, comp *Compilation, deps *depInfo, env []string, wd string) (*daemon.InvokeWithFilesArgs, error) {
+	args := daemon.InvokeWithFilesArgs{
+		Function:      cfg.Function,
+		Memory:        cfg.Memory,
+		Timeout:       cfg.Timeout,
+		UseCache:      cfg.Cache,
+		Idempotent:    true,
+		DropSemaphore: true,
+	}
+
+	uploaded := make(map[string]bool)
+	upload := func(local string) {
+		if abs := wrapperutil.ToAbs(local, wd); !uploaded[abs] {
+			uploaded[abs] = true
+			args.Files = args.Files.Append(wrapperutil.Remap(abs, wd))
+		}
+	}
+	upload(comp.Input)
+	for _, f := range deps.Files {
+		upload(f)
+	}
+	for _, e := range comp.Externs {
+		if e.Path != "" {
+			upload(e.Path)
+		}
+	}
+	for _, l := range comp.LibDirs {
+		crates, err := crateFiles(wrapperutil.ToAbs(l.Path, wd))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range crates {
+			upload(c)
+		}
+	}
+
+	var paths []string
+	for _, ev := range env {
+		eq := strings.IndexByte(ev, '=')
+		if eq < 0 {
+			continue
+		}
+		name, val := ev[:eq], ev[eq+1:]
+		switch {
+		case pathEnv[name]:
+			paths = append(paths, name+"="+files.RemotePath(wrapperutil.ToAbs(val, wd)))
+			if name == "CARGO_MANIFEST_DIR" {
+				// Procedural macros commonly read the
+				// manifest, behind rustc's back
+				if manifest := filepath.Join(val, "Cargo.toml"); fileExists(manifest) {
+					upload(manifest)
+				}
+			}
+		case passEnv(name, deps):
+			args.Env = append(args.Env, ev)
+		}
+	}
+
+	rpath := func(p string) string { return wrapperutil.ToRemote(p, wd) }
+	outDir := comp.OutDir
+	if outDir == "" {
+		outDir = "."
+	}
+	var emit []string
+	for _, e := range comp.Emit {
+		if e.Kind == "dep-info" {
+			continue
+		}
+		out := comp.Output(e)
+		if args.Profile == "" {
+			args.Profile = wrapperutil.ToAbs(out, wd)
+		}
+		args.Outputs = args.Outputs.Append(wrapperutil.Remap(out, wd))
+		if e.Path != "" {
+			emit = append(emit, e.Kind+"="+rpath(e.Path))
+		} else {
+			emit = append(emit, e.Kind)
+		}
+	}
+
+	args.Args = []string{"/bin/sh", "-c", wrapper, "llamarustc"}
+	args.Args = append(args.Args, paths...)
+	args.Args = append(args.Args, "--", cfg.RemoteRustc, rpath(comp.Input),
+		"--crate-name", comp.CrateName,
+		"--crate-type", comp.CrateType,
+		"--emit="+strings.Join(emit, ","),
+		"--out-dir", rpath(outDir),
+	)
+	for _, e := range comp.Externs {
+		if e.Path == "" {
+			args.Args = append(args.Args, "--extern", e.Name)
+		} else {
+			args.Args = append(args.Args, "--extern", e.Name+"="+rpath(e.Path))
+		}
+	}
+	for _, l := range comp.LibDirs {
+		args.Args = append(args.Args, "-L", l.Kind+"="+rpath(l.Path))
+	}
+	args.Args = append(args.Args, comp.Args...)
+	// Later mappings take precedence, so paths in the working
+	// directory come out relative, as cargo gives them
+	args.Args = append(args.Args,
+		"--remap-path-prefix="+wrapperutil.RemoteRoot+"=/",
+		"--remap-path-prefix="+rpath(wd)+"=",
+	)
+	return &args, nil
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.Mode().IsRegular()
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg := ParseConfig([]string{
+		"PATH=/bin",
+		"LLAMARUSTC_FUNCTION=rust-1.70",
+		"LLAMARUSTC_LOCAL_CRATES=sqlx_macros, openssl_sys",
+		"LLAMARUSTC_TIMEOUT=5m",
+	})
+	assert.Equal(t, "rust-1.70", cfg.Function)
+	assert.Equal(t, "rustc", cfg.RemoteRustc)
+	assert.Equal(t, []string{"sqlx_macros", "openssl_sys"}, cfg.LocalCrates)
+	assert.Equal(t, 5*time.Minute, cfg.Timeout)
+}
+
+func TestParseDepInfo(t *testing.T) {
+	data := []byte(`/p/target/debug/deps/foo-0123.d: src/lib.rs src/my\ mod.rs /p/target/debug/build/foo-89ab/out/gen.rs
+
+/p/target/debug/deps/libfoo-0123.rmeta: src/lib.rs src/my\ mod.rs /p/target/debug/build/foo-89ab/out/gen.rs
+
+src/lib.rs:
+src/my\ mod.rs:
+/p/target/debug/build/foo-89ab/out/gen.rs:
+
+# env-dep:CARGO_PKG_VERSION=0.1.0
+# env-dep:OUT_DIR=/p/target/debug/build/foo-89ab/out
+# env-dep:FOO_FEATURES
+`)
+	deps := parseDepInfo(data)
+	assert.Equal(t, []string{"src/lib.rs", "src/my mod.rs", "/p/target/debug/build/foo-89ab/out/gen.rs"}, deps.Files)
+	assert.Equal(t, []string{"CARGO_PKG_VERSION", "OUT_DIR", "FOO_FEATURES"}, deps.Env)
+}
+
+func TestBuildInvocation(t *testing.T) {
+	wd := t.TempDir()
+	deps := filepath.Join(wd, "target", "deps")
+	require.NoError(t, os.MkdirAll(deps, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(wd, "src"), 0755))
+	for _, f := range []string{
+		"Cargo.toml", "src/lib.rs",
+		"target/deps/libbar-1.rmeta", "target/deps/libbar-1.rlib",
+		"target/deps/libbaz-2.rlib", "target/deps/bar-1.d",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(wd, f), nil, 0644))
+	}
+
+	comp, err := ParseRustc([]string{
+		"rustc", "--crate-name", "foo", "src/lib.rs", "--crate-type", "lib",
+		"--emit=dep-info,metadata,link", "-C", "extra-filename=-0",
+		"--out-dir", deps, "-L", "dependency=" + deps,
+		"--extern", "bar=" + filepath.Join(deps, "libbar-1.rmeta"),
+	})
+	require.NoError(t, err)
+	cfg := DefaultConfig
+	cfg.Memory = 2048
+	args, err := buildInvocation(&cfg, &comp, &depInfo{
+		Files: []string{"src/lib.rs"},
+		Env:   []string{"FOO_FEATURES"},
+	}, []string{
+		"HOME=/home/me",
+		"CARGO_PKG_NAME=foo",
+		"FOO_FEATURES=fast",
+		"CARGO_MANIFEST_DIR=" + wd,
+		"OUT_DIR=" + filepath.Join(wd, "target", "out"),
+	}, wd)
+	require.NoError(t, err)
+
+	rwd := wrapperutil.ToRemote(wd, wd)
+	rdeps := rwd + "/target/deps"
+	assert.Equal(t, []string{
+		"/bin/sh", "-c", wrapper, "llamarustc",
+		"CARGO_MANIFEST_DIR=" + files.RemotePath(wd),
+		"OUT_DIR=" + files.RemotePath(filepath.Join(wd, "target", "out")),
+		"--", "rustc", rwd + "/src/lib.rs",
+		"--crate-name", "foo", "--crate-type", "lib",
+		"--emit=metadata,link", "--out-dir", rdeps,
+		"--extern", "bar=" + rdeps + "/libbar-1.rmeta",
+		"-L", "dependency=" + rdeps,
+		"-C", "extra-filename=-0",
+		"--remap-path-prefix=_root=/",
+		"--remap-path-prefix=" + rwd + "=",
+	}, args.Args)
+	assert.Equal(t, files.List{
+		wrapperutil.Remap(filepath.Join(wd, "src", "lib.rs"), wd),
+		wrapperutil.Remap(filepath.Join(deps, "libbar-1.rmeta"), wd),
+		wrapperutil.Remap(filepath.Join(deps, "libbaz-2.rlib"), wd),
+		wrapperutil.Remap(filepath.Join(wd, "Cargo.toml"), wd),
+	}, args.Files)
+	assert.Equal(t, files.List{
+		wrapperutil.Remap(filepath.Join(deps, "libfoo-0.rmeta"), wd),
+		wrapperutil.Remap(filepath.Join(deps, "libfoo-0.rlib"), wd),
+	}, args.Outputs)
+	assert.Equal(t, []string{"CARGO_PKG_NAME=foo", "FOO_FEATURES=fast"}, args.Env)
+	assert.Equal(t, filepath.Join(deps, "libfoo-0.rmeta"), args.Profile)
+	assert.Equal(t, int64(2048), args.Memory)
+
+	comp.LibDirs = []LibDir{{"dependency", filepath.Join(wd, "missing")}}
+	_, err = buildInvocation(&cfg, &comp, &depInfo{}, nil, wd)
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/files"
+)
+
+// runRemote compiles `comp` on Lambda, after writing its dep-info
+// locally with `rustc`.
+func runRemote(cfg *Config, rustc string, comp *Compilation) (int, error) {
+	if isLocalCrate(cfg, comp.CrateName) {
+		return 0, fmt.Errorf("%s is in LLAMARUSTC_LOCAL_CRATES", comp.CrateName)
+	}
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return 0, err
+	}
+
+	depfile := comp.DepInfo()
+	if depfile == "" {
+		dir, err := ioutil.TempDir("", "llamarustc")
+		if err != nil {
+			return 0, err
+		}
+		defer os.RemoveAll(dir)
+		depfile = filepath.Join(dir, comp.CrateName+".d")
+	}
+	deps, err := scanDependencies(rustc, comp, depfile)
+	if err != nil {
+		return 0, err
+	}
+	args, err := buildInvocation(cfg, comp, &deps, os.Environ(), wd)
+	if err != nil {
+		return 0, err
+	}
+	if len(args.Outputs) == 0 {
+		// Cargo only wanted the dep-info
+		return 0, nil
+	}
+	if cfg.Verbose {
+		log.Printf("[llamarustc] compiling %s remotely: %q", comp.CrateName, args.Args)
+	}
+
+	return wrapperutil.Invoke(args, false)
+}
+
+func isLocalCrate(cfg *Config, name string) bool {
+	for _, c := range cfg.LocalCrates {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	cfg := ParseConfig(os.Environ())
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: llamarustc RUSTC [ARGS...]\n")
+		os.Exit(2)
+	}
+	argv := os.Args[1:]
+	wrapperutil.Main("llamarustc", &cfg.Config, argv, func() (int, error) {
+		comp, err := ParseRustc(argv)
+		if err != nil {
+			return 0, err
+		}
+		return runRemote(&cfg, argv[0], &comp)
+	})
+}
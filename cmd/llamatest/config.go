@@ -0,0 +1,74 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+)
+
+type Config struct {
+	Verbose bool
+	Local   bool
+	Stream  bool
+	// The function to run tests in. By default, the one llamacc
+	// compiles in, since it has the compiler's runtime libraries.
+	Function string
+
+	// Files and directories the test reads, and additional
+	// result files it writes
+	Data    []string
+	Outputs []string
+
+	// Minimum function memory (in MB)
+	Memory int64
+	// The longest a test may run; we kill it after this long
+	Timeout time.Duration
+}
+
+var DefaultConfig = Config{
+	Function: "gcc",
+}
+
+func ParseConfig(env []string) Config {
+	out := DefaultConfig
+	wrapperutil.ParseEnv("LLAMATEST_", env, func(key, val string) (bool, error) {
+		var err error
+		switch key {
+		case "VERBOSE":
+			out.Verbose = val != ""
+		case "LOCAL":
+			out.Local = val != ""
+		case "STREAM":
+			out.Stream = val != ""
+		case "FUNCTION":
+			out.Function = val
+		case "DATA":
+			out.Data = wrapperutil.SplitList(val)
+		case "OUTPUTS":
+			out.Outputs = wrapperutil.SplitList(val)
+		case "MEMORY":
+			out.Memory, err = strconv.ParseInt(val, 10, 64)
+		case "TIMEOUT":
+			out.Timeout, err = time.ParseDuration(val)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+	return out
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// Tests run in a fresh directory on Lambda. We upload the test
+// binary and its data under `_root/`, at their local absolute paths,
+// and run the test in the copy of the working directory there, so
+// the relative paths it uses work just as they do locally.
+//
+// wrapper creates the directories results will be written to, and
+// then runs the test in the remote copy of the working directory.
+// Its arguments are the number of directories, the directories, the
+// working directory, and then the test's command line.
+const wrapper = `n=$1; shift
+while [ "$n" -gt 0 ]; do mkdir -p "$1" || exit 125; shift; n=$((n-1)); done
+cd "$1" && shift && exec "$@"`
+
+// gtestOutput parses a --gtest_output flag or GTEST_OUTPUT value,
+// like `xml:results/`, for the test binary `exe`. It returns the
+// file the test will write its results to, and the value to pass to
+// the remote test so that it writes it to the same relative path.
+func gtestOutput(spec, exe, wd string) (local, remote string) {
+	format, dest := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		format, dest = spec[:i], spec[i+1:]
+	}
+	if format != "xml" && format != "json" {
+		return "", spec
+	}
+	if dest == "" {
+		dest = "test_detail." + format
+	} else if strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(filepath.Separator)) {
+		name := filepath.Base(exe)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		dest = filepath.Join(dest, name+"."+format)
+	}
+	return dest, format + ":" + wrapperutil.ToRemoteRel(dest, wd)
+}
+
+// buildInvocation constructs the invocation that runs the test
+// command `argv` in `wd`, with the environment `env`.
+func buildInvocation(cfg *Config, argv, env []string, wd string) (*daemon.InvokeWithFilesArgs, error) {
+	args := daemon.InvokeWithFilesArgs{
+		Function:  cfg.Function,
+		Memory:    cfg.Memory,
+		Timeout:   cfg.Timeout,
+		TimeLimit: cfg.Timeout,
+	}
+
+	exe := argv[0]
+	if !strings.ContainsRune(exe, filepath.Separator) && !strings.ContainsRune(exe, '/') {
+		// Search $PATH, like a shell
+		var err error
+		if exe, err = exec.LookPath(exe); err != nil {
+			return nil, err
+		}
+	}
+	args.Files = args.Files.Append(wrapperutil.Remap(exe, wd))
+	remoteExe := wrapperutil.ToRemoteRel(exe, wd)
+	if !strings.ContainsRune(remoteExe, '/') {
+		remoteExe = "./" + remoteExe
+	}
+
+	for _, d := range cfg.Data {
+		mapped := wrapperutil.Remap(d, wd)
+		st, err := os.Stat(mapped.Local.Path)
+		if err != nil {
+			return nil, fmt.Errorf("data: %w", err)
+		}
+		if st.IsDir() {
+			args.Trees = args.Trees.Append(mapped)
+		} else {
+			args.Files = args.Files.Append(mapped)
+		}
+	}
+
+	outputs := append([]string(nil), cfg.Outputs...)
+	testArgs := []string{remoteExe}
+	for _, arg := range argv[1:] {
+		if strings.HasPrefix(arg, "--gtest_output=") {
+			local, remote := gtestOutput(strings.TrimPrefix(arg, "--gtest_output="), exe, wd)
+			if local != "" {
+				outputs = append(outputs, local)
+			}
+			arg = "--gtest_output=" + remote
+		}
+		testArgs = append(testArgs, arg)
+	}
+	for _, ev := range env {
+		switch {
+		case strings.HasPrefix(ev, "GTEST_OUTPUT="):
+			local, remote := gtestOutput(strings.TrimPrefix(ev, "GTEST_OUTPUT="), exe, wd)
+			if local != "" {
+				outputs = append(outputs, local)
+			}
+			args.Env = append(args.Env, "GTEST_OUTPUT="+remote)
+		case strings.HasPrefix(ev, "GTEST_SHARD_STATUS_FILE="):
+			// A local path the test would touch
+		case strings.HasPrefix(ev, "GTEST_"):
+			// Test filters, repeats, and sharding
+			args.Env = append(args.Env, ev)
+		}
+	}
+
+	remoteWd := path.Join(wrapperutil.RemoteRoot, files.RemotePath(wd))
+	dirs := []string{remoteWd}
+	for _, out := range outputs {
+		mapped := wrapperutil.Remap(out, wd)
+		args.Outputs = args.Outputs.Append(mapped)
+		dirs = append(dirs, path.Dir(mapped.Remote))
+	}
+
+	args.Args = []string{"/bin/sh", "-c", wrapper, "llamatest", strconv.Itoa(len(dirs))}
+	args.Args = append(args.Args, dirs...)
+	args.Args = append(args.Args, remoteWd)
+	args.Args = append(args.Args, testArgs...)
+	return &args, nil
+}
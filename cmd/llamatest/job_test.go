@@ -0,0 +1,100 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg := ParseConfig([]string{
+		"PATH=/bin",
+		"LLAMATEST_FUNCTION=tests",
+		"LLAMATEST_DATA=testdata, fixtures/a.json",
+		"LLAMATEST_TIMEOUT=5m",
+	})
+	assert.Equal(t, "tests", cfg.Function)
+	assert.Equal(t, []string{"testdata", "fixtures/a.json"}, cfg.Data)
+	assert.Equal(t, 5*time.Minute, cfg.Timeout)
+}
+
+func TestGtestOutput(t *testing.T) {
+	cases := []struct {
+		spec          string
+		local, remote string
+	}{
+		{"xml", "test_detail.xml", "xml:test_detail.xml"},
+		{"xml:out.xml", "out.xml", "xml:out.xml"},
+		{"json:results/", "results/unit_test.json", "json:results/unit_test.json"},
+		{"xml:/tmp/out.xml", "/tmp/out.xml", "xml:../../tmp/out.xml"},
+		{"yaml:out.yaml", "", "yaml:out.yaml"},
+	}
+	for _, tc := range cases {
+		local, remote := gtestOutput(tc.spec, "bin/unit_test", "/src/build")
+		assert.Equal(t, tc.local, local, tc.spec)
+		assert.Equal(t, tc.remote, remote, tc.spec)
+	}
+}
+
+func TestBuildInvocation(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(wd, "testdata"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "unit_test"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "golden.txt"), []byte("golden\n"), 0644))
+
+	cfg := DefaultConfig
+	cfg.Data = []string{"testdata", "golden.txt"}
+	cfg.Outputs = []string{"logs/trace.json"}
+	cfg.Timeout = time.Minute
+	args, err := buildInvocation(&cfg,
+		[]string{"./unit_test", "--gtest_output=xml:report.xml", "--gtest_filter=Foo.*"},
+		[]string{"HOME=/home/me", "GTEST_SHUFFLE=1", "GTEST_SHARD_STATUS_FILE=/tmp/status"},
+		wd)
+	require.NoError(t, err)
+
+	remoteWd := wrapperutil.ToRemote(wd, wd)
+	assert.Equal(t, []string{
+		"/bin/sh", "-c", wrapper, "llamatest", "3",
+		remoteWd, remoteWd + "/logs", remoteWd,
+		remoteWd,
+		"./unit_test", "--gtest_output=xml:report.xml", "--gtest_filter=Foo.*",
+	}, args.Args)
+	assert.Equal(t, files.List{
+		{Local: files.LocalFile{Path: filepath.Join(wd, "unit_test")}, Remote: remoteWd + "/unit_test"},
+		{Local: files.LocalFile{Path: filepath.Join(wd, "golden.txt")}, Remote: remoteWd + "/golden.txt"},
+	}, args.Files)
+	assert.Equal(t, files.List{
+		{Local: files.LocalFile{Path: filepath.Join(wd, "testdata")}, Remote: remoteWd + "/testdata"},
+	}, args.Trees)
+	assert.Equal(t, files.List{
+		{Local: files.LocalFile{Path: filepath.Join(wd, "logs/trace.json")}, Remote: remoteWd + "/logs/trace.json"},
+		{Local: files.LocalFile{Path: filepath.Join(wd, "report.xml")}, Remote: remoteWd + "/report.xml"},
+	}, args.Outputs)
+	assert.Equal(t, []string{"GTEST_SHUFFLE=1"}, args.Env)
+	assert.Equal(t, time.Minute, args.TimeLimit)
+
+	cfg.Data = []string{"missing"}
+	_, err = buildInvocation(&cfg, []string{"./unit_test"}, nil, wd)
+	assert.Error(t, err)
+}
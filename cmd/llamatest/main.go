@@ -0,0 +1,92 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/rpc"
+	"os"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/server"
+	"github.com/nelhage/llama/files"
+)
+
+func runRemote(cfg *Config, argv []string) (int, error) {
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return 0, err
+	}
+	args, err := buildInvocation(cfg, argv, os.Environ(), wd)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Verbose {
+		log.Printf("[llamatest] invoking %s: %q", args.Function, args.Args)
+	}
+
+	ctx := context.Background()
+	client, err := server.DialWithAutostart(ctx, cli.SocketPath(), rpc.DefaultRPCPath)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	var stream *daemon.OutputStream
+	if cfg.Stream {
+		stream = client.StartStream(os.Stdout, os.Stderr)
+		args.Stream = stream.ID
+	}
+	out, err := client.InvokeWithFiles(args)
+	var wroteOut, wroteErr int
+	if stream != nil {
+		wroteOut, wroteErr = stream.Stop()
+	}
+	if err != nil {
+		return 0, err
+	}
+	if wroteOut < len(out.Stdout) {
+		os.Stdout.Write(out.Stdout[wroteOut:])
+	}
+	if wroteErr < len(out.Stderr) {
+		os.Stderr.Write(out.Stderr[wroteErr:])
+	}
+	if out.InvokeErr != "" {
+		return 0, fmt.Errorf("invoke: %s", out.InvokeErr)
+	}
+	return out.ExitStatus, nil
+}
+
+func main() {
+	cfg := ParseConfig(os.Environ())
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: llamatest TEST [ARGS...]\n")
+		os.Exit(2)
+	}
+	run := runRemote
+	if cfg.Local {
+		run = func(_ *Config, argv []string) (int, error) { return wrapperutil.RunLocal(argv) }
+	}
+	status, err := run(&cfg, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Running llamatest: %s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(status)
+}
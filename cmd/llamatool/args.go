@@ -0,0 +1,179 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+)
+
+// What an argument names
+type argKind int
+
+const (
+	argLiteral argKind = iota
+	argInput
+	argInputDir
+	argOutput
+	argOutputDir
+)
+
+// An Arg is one argument of a tool's command line. For arguments
+// naming files, Prefix is what precedes the path within the
+// argument, such as "--cpp_out=" or "-I", and Value the path;
+// otherwise Value is the argument itself.
+type Arg struct {
+	Kind   argKind
+	Prefix string
+	Value  string
+}
+
+// A Job is a run of a tool, parsed according to its ToolSpec
+type Job struct {
+	// The tool, as named on the command line
+	Tool string
+	Args []Arg
+}
+
+// toolName returns the name a tool's spec is found under, like
+// "protoc"
+func toolName(path string) string {
+	name := filepath.Base(path)
+	if ext := filepath.Ext(name); strings.EqualFold(ext, ".exe") {
+		name = name[:len(name)-len(ext)]
+	}
+	return name
+}
+
+// matchFlag reports whether `arg` is the flag `flag`, returning the
+// part of `arg` before its value and whether the value is joined to
+// it, rather than being the next argument.
+func matchFlag(arg, flag string) (prefix string, joined bool, ok bool) {
+	switch {
+	case arg == flag:
+		return "", false, true
+	case strings.HasPrefix(arg, flag+"="):
+		return flag + "=", true, true
+	}
+	return "", false, false
+}
+
+// matchShortFlag matches a single-letter flag joined to its value,
+// like "-Isrc"
+func matchShortFlag(arg, flag string) (prefix string, ok bool) {
+	if len(flag) == 2 && flag[0] == '-' && flag[1] != '-' &&
+		len(arg) > 2 && strings.HasPrefix(arg, flag) {
+		return flag, true
+	}
+	return "", false
+}
+
+// kindFlags are the flags which name one kind of argument
+type kindFlags struct {
+	kind  argKind
+	flags []string
+}
+
+// specFlags returns the flags `spec` describes
+func specFlags(spec *cli.ToolSpec) []kindFlags {
+	return []kindFlags{
+		{argOutput, spec.Outputs},
+		{argOutputDir, spec.OutputDirs},
+		{argInput, spec.Inputs},
+		{argInputDir, spec.InputDirs},
+	}
+}
+
+// matchArg finds the flag among `flags` which `arg` is
+func matchArg(flags []kindFlags, arg string) (kind argKind, prefix string, joined bool, ok bool) {
+	for _, k := range flags {
+		for _, flag := range k.flags {
+			if prefix, joined, ok := matchFlag(arg, flag); ok {
+				return k.kind, prefix, joined, true
+			}
+		}
+	}
+	// Only once no flag matches exactly, so that "-o" doesn't
+	// claim "-output"
+	for _, k := range flags {
+		for _, flag := range k.flags {
+			if prefix, ok := matchShortFlag(arg, flag); ok {
+				return k.kind, prefix, true, true
+			}
+		}
+	}
+	return argLiteral, "", false, false
+}
+
+// ParseJob parses `argv`, a tool's command line, run in `wd`,
+// according to `spec`.
+func ParseJob(spec *cli.ToolSpec, argv []string, wd string) (*Job, error) {
+	job := &Job{Tool: argv[0]}
+	flags := specFlags(spec)
+	args := argv[1:]
+	flagsDone := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !flagsDone && arg == "--" {
+			flagsDone = true
+			job.Args = append(job.Args, Arg{Kind: argLiteral, Value: arg})
+			continue
+		}
+		if !flagsDone && strings.HasPrefix(arg, "-") && arg != "-" {
+			kind, prefix, joined, ok := matchArg(flags, arg)
+			if !ok {
+				job.Args = append(job.Args, Arg{Kind: argLiteral, Value: arg})
+				continue
+			}
+			if joined {
+				if len(arg) == len(prefix) {
+					return nil, fmt.Errorf("empty argument to %s", arg)
+				}
+				job.Args = append(job.Args, Arg{Kind: kind, Prefix: prefix, Value: arg[len(prefix):]})
+				continue
+			}
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("missing argument to %s", arg)
+			}
+			i++
+			job.Args = append(job.Args,
+				Arg{Kind: argLiteral, Value: arg},
+				Arg{Kind: kind, Value: args[i]})
+			continue
+		}
+		if st, err := os.Stat(wrapperutil.ToAbs(arg, wd)); err == nil && st.Mode().IsRegular() {
+			job.Args = append(job.Args, Arg{Kind: argInput, Value: arg})
+		} else {
+			job.Args = append(job.Args, Arg{Kind: argLiteral, Value: arg})
+		}
+	}
+	return job, nil
+}
+
+// Outputs returns the files and directories the job writes
+func (j *Job) Outputs() []Arg {
+	var out []Arg
+	for _, a := range j.Args {
+		if a.Kind == argOutput || a.Kind == argOutputDir {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var protoc = cli.ToolSpec{
+	InputDirs:  []string{"-I", "--proto_path"},
+	OutputDirs: []string{"--cpp_out", "--python_out"},
+}
+
+var moc = cli.ToolSpec{
+	Inputs:  []string{"--include"},
+	Outputs: []string{"-o"},
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg := ParseConfig([]string{
+		"PATH=/bin",
+		"LLAMATOOL_FUNCTION=codegen",
+		"LLAMATOOL_FALLBACK=1",
+		"LLAMATOOL_TIMEOUT=5m",
+	})
+	assert.Equal(t, "codegen", cfg.Function)
+	assert.True(t, cfg.Fallback)
+	assert.Equal(t, 5*time.Minute, cfg.Timeout)
+}
+
+func TestToolName(t *testing.T) {
+	assert.Equal(t, "protoc", toolName("/usr/bin/protoc"))
+	assert.Equal(t, "moc", toolName(`moc.EXE`))
+	assert.Equal(t, "bison", toolName("bison"))
+}
+
+func TestParseJob(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "a.proto"), []byte("syntax = \"proto3\";"), 0644))
+
+	job, err := ParseJob(&protoc, []string{"protoc", "-Iproto", "--proto_path", "/usr/include", "--cpp_out=gen", "a.proto", "b.proto"}, wd)
+	require.NoError(t, err)
+	assert.Equal(t, "protoc", job.Tool)
+	assert.Equal(t, []Arg{
+		{Kind: argInputDir, Prefix: "-I", Value: "proto"},
+		{Kind: argLiteral, Value: "--proto_path"},
+		{Kind: argInputDir, Value: "/usr/include"},
+		{Kind: argOutputDir, Prefix: "--cpp_out=", Value: "gen"},
+		{Kind: argInput, Value: "a.proto"},
+		// Doesn't exist, so isn't uploaded
+		{Kind: argLiteral, Value: "b.proto"},
+	}, job.Args)
+	assert.Equal(t, []Arg{
+		{Kind: argOutputDir, Prefix: "--cpp_out=", Value: "gen"},
+	}, job.Outputs())
+}
+
+func TestParseJobFlags(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "-o"), nil, 0644))
+
+	job, err := ParseJob(&moc, []string{"moc", "-omoc_w.cpp", "--include=w.h", "--", "-o"}, wd)
+	require.NoError(t, err)
+	assert.Equal(t, []Arg{
+		{Kind: argOutput, Prefix: "-o", Value: "moc_w.cpp"},
+		{Kind: argInput, Prefix: "--include=", Value: "w.h"},
+		{Kind: argLiteral, Value: "--"},
+		{Kind: argInput, Value: "-o"},
+	}, job.Args)
+
+	spec := cli.ToolSpec{Outputs: []string{"-o"}, Inputs: []string{"-output"}}
+	job, err = ParseJob(&spec, []string{"tool", "-output", "in", "-oout"}, wd)
+	require.NoError(t, err)
+	assert.Equal(t, []Arg{
+		// Not "-o" joined to "utput"
+		{Kind: argLiteral, Value: "-output"},
+		{Kind: argInput, Value: "in"},
+		{Kind: argOutput, Prefix: "-o", Value: "out"},
+	}, job.Args)
+
+	_, err = ParseJob(&moc, []string{"moc", "w.h", "-o"}, wd)
+	assert.Error(t, err)
+	_, err = ParseJob(&moc, []string{"moc", "w.h", "--include="}, wd)
+	assert.Error(t, err)
+}
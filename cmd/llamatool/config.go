@@ -0,0 +1,29 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "github.com/nelhage/llama/cmd/internal/wrapperutil"
+
+// DefaultFunction is the function we run tools in if neither the
+// environment nor their spec names one
+const DefaultFunction = "gcc"
+
+// ParseConfig reads our configuration from LLAMATOOL_ variables. A
+// function it names overrides the tool's spec's.
+func ParseConfig(env []string) wrapperutil.Config {
+	var out wrapperutil.Config
+	wrapperutil.ParseEnv("LLAMATOOL_", env, out.Set)
+	return out
+}
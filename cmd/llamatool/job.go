@@ -0,0 +1,177 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+)
+
+// wrapper creates the directories the tool writes into, and runs it.
+// Its arguments are the directories, then `--`, then the tool's
+// command line.
+const wrapper = `while [ "$1" != -- ]; do mkdir -p "$1"; shift; done
+shift; exec "$@"`
+
+// inDir reports whether `abs` is `dir` or inside it
+func inDir(abs, dir string) bool {
+	rel, err := filepath.Rel(dir, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// toRemote returns the path, relative to the job's directory, at
+// which we upload `local`. The tool runs in a copy of the working
+// directory, so files in it keep their relative paths, and arguments
+// naming them needn't change. We upload files elsewhere under
+// `_root/`, at their local absolute paths.
+func toRemote(local, wd string) string {
+	abs := wrapperutil.ToAbs(local, wd)
+	if inDir(abs, wd) {
+		rel, _ := filepath.Rel(wd, abs)
+		return filepath.ToSlash(rel)
+	}
+	return path.Join(wrapperutil.RemoteRoot, files.RemotePath(abs))
+}
+
+// buildInvocation constructs the invocation that runs `job`, which
+// `spec` describes, remotely in `wd`.
+func buildInvocation(cfg *wrapperutil.Config, spec *cli.ToolSpec, job *Job, wd string) (*daemon.InvokeWithFilesArgs, error) {
+	args := daemon.InvokeWithFilesArgs{
+		Function:      cfg.Function,
+		Memory:        cfg.Memory,
+		Timeout:       cfg.Timeout,
+		UseCache:      cfg.Cache,
+		Idempotent:    true,
+		DropSemaphore: true,
+	}
+	if args.Function == "" {
+		args.Function = spec.Function
+	}
+	if args.Function == "" {
+		args.Function = DefaultFunction
+	}
+
+	var trees []string
+	for _, a := range job.Args {
+		if a.Kind == argInputDir {
+			abs := wrapperutil.ToAbs(a.Value, wd)
+			trees = append(trees, abs)
+			args.Trees = args.Trees.Append(files.Mapped{
+				Local:  files.LocalFile{Path: abs},
+				Remote: toRemote(abs, wd),
+			})
+		}
+	}
+	uploaded := make(map[string]bool)
+	upload := func(local string) {
+		abs := wrapperutil.ToAbs(local, wd)
+		if uploaded[abs] {
+			return
+		}
+		uploaded[abs] = true
+		for _, dir := range trees {
+			if inDir(abs, dir) {
+				// Already uploaded with the directory
+				return
+			}
+		}
+		args.Files = args.Files.Append(files.Mapped{
+			Local:  files.LocalFile{Path: abs},
+			Remote: toRemote(abs, wd),
+		})
+	}
+
+	// Tools which write files they don't name, or write into
+	// directories, have us fetch everything they change in the
+	// working directory, so their outputs must be in it
+	changes := spec.Changes
+	for _, a := range job.Outputs() {
+		if a.Kind == argOutputDir {
+			changes = true
+		}
+	}
+	var mkdirs []string
+	mkdir := func(dir string) {
+		if dir != "." {
+			mkdirs = append(mkdirs, dir)
+		}
+	}
+	var toolArgs []string
+	for _, a := range job.Args {
+		if a.Kind == argLiteral {
+			toolArgs = append(toolArgs, a.Value)
+			continue
+		}
+		remote := toRemote(a.Value, wd)
+		toolArgs = append(toolArgs, a.Prefix+remote)
+		switch a.Kind {
+		case argInput:
+			upload(a.Value)
+		case argOutput, argOutputDir:
+			abs := wrapperutil.ToAbs(a.Value, wd)
+			if changes && !inDir(abs, wd) {
+				return nil, fmt.Errorf("output %s is outside the working directory", a.Value)
+			}
+			if a.Kind == argOutputDir {
+				mkdir(remote)
+				continue
+			}
+			mkdir(path.Dir(remote))
+			if args.Profile == "" {
+				args.Profile = abs
+			}
+			if !changes {
+				args.Outputs = args.Outputs.Append(files.Mapped{
+					Local:  files.LocalFile{Path: abs},
+					Remote: remote,
+				})
+			}
+		}
+	}
+	if changes {
+		args.ChangesDir = wd
+	}
+
+	tool := toolName(job.Tool)
+	if spec.Upload {
+		local, err := exec.LookPath(job.Tool)
+		if err != nil {
+			return nil, err
+		}
+		upload(local)
+		tool = toRemote(local, wd)
+		if !strings.Contains(tool, "/") {
+			tool = "./" + tool
+		}
+	}
+
+	if len(mkdirs) == 0 {
+		args.Args = append([]string{tool}, toolArgs...)
+		return &args, nil
+	}
+	args.Args = []string{"/bin/sh", "-c", wrapper, "llamatool"}
+	args.Args = append(args.Args, mkdirs...)
+	args.Args = append(args.Args, "--", tool)
+	args.Args = append(args.Args, toolArgs...)
+	return &args, nil
+}
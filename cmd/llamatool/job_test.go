@@ -0,0 +1,112 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func remotes(list files.List) []string {
+	var out []string
+	for _, f := range list {
+		out = append(out, f.Remote)
+	}
+	return out
+}
+
+func TestToRemote(t *testing.T) {
+	assert.Equal(t, "gen/a.pb.h", toRemote("gen/a.pb.h", "/src"))
+	assert.Equal(t, "gen", toRemote("/src/gen", "/src"))
+	assert.Equal(t, ".", toRemote("/src", "/src"))
+	assert.Equal(t, "_root/usr/include", toRemote("/usr/include", "/src"))
+	assert.Equal(t, "_root/srcgen/a.proto", toRemote("../srcgen/a.proto", "/src"))
+}
+
+func TestBuildInvocationOutputDir(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "a.proto"), nil, 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(wd, "proto"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "proto", "b.proto"), nil, 0644))
+
+	job, err := ParseJob(&protoc, []string{"protoc", "-Iproto", "--cpp_out=gen", "a.proto", "proto/b.proto"}, wd)
+	require.NoError(t, err)
+	args, err := buildInvocation(&wrapperutil.Config{}, &protoc, job, wd)
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultFunction, args.Function)
+	assert.Equal(t, wd, args.ChangesDir)
+	assert.Empty(t, args.Outputs)
+	assert.Equal(t, []string{"proto"}, remotes(args.Trees))
+	// proto/b.proto comes with its directory
+	assert.Equal(t, []string{"a.proto"}, remotes(args.Files))
+	assert.Equal(t, []string{
+		"/bin/sh", "-c", wrapper, "llamatool", "gen", "--",
+		"protoc", "-Iproto", "--cpp_out=gen", "a.proto", "proto/b.proto",
+	}, args.Args)
+}
+
+func TestBuildInvocationOutputs(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wd, "w.h"), nil, 0644))
+
+	spec := moc
+	spec.Function = "qt"
+	job, err := ParseJob(&spec, []string{"/usr/bin/moc", "w.h", "-o", "moc_w.cpp"}, wd)
+	require.NoError(t, err)
+	args, err := buildInvocation(&wrapperutil.Config{}, &spec, job, wd)
+	require.NoError(t, err)
+
+	assert.Equal(t, "qt", args.Function)
+	assert.Equal(t, "", args.ChangesDir)
+	assert.Equal(t, filepath.Join(wd, "moc_w.cpp"), args.Profile)
+	assert.Equal(t, []string{"moc_w.cpp"}, remotes(args.Outputs))
+	assert.Equal(t, []string{"w.h"}, remotes(args.Files))
+	// The output's directory exists, so we run moc directly
+	assert.Equal(t, []string{"moc", "w.h", "-o", "moc_w.cpp"}, args.Args)
+
+	args, err = buildInvocation(&wrapperutil.Config{Function: "override"}, &spec, job, wd)
+	require.NoError(t, err)
+	assert.Equal(t, "override", args.Function)
+}
+
+func TestBuildInvocationChanges(t *testing.T) {
+	wd := t.TempDir()
+	bison := cli.ToolSpec{Outputs: []string{"-o"}, Changes: true}
+
+	job, err := ParseJob(&bison, []string{"bison", "-d", "-o", "out/parse.c", "parse.y"}, wd)
+	require.NoError(t, err)
+	args, err := buildInvocation(&wrapperutil.Config{}, &bison, job, wd)
+	require.NoError(t, err)
+	assert.Equal(t, wd, args.ChangesDir)
+	assert.Empty(t, args.Outputs)
+	assert.Equal(t, []string{
+		"/bin/sh", "-c", wrapper, "llamatool", "out", "--",
+		"bison", "-d", "-o", "out/parse.c", "parse.y",
+	}, args.Args)
+
+	job, err = ParseJob(&bison, []string{"bison", "-o", "/elsewhere/parse.c", "parse.y"}, wd)
+	require.NoError(t, err)
+	_, err = buildInvocation(&wrapperutil.Config{}, &bison, job, wd)
+	assert.Error(t, err)
+}
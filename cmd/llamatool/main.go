@@ -0,0 +1,83 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/nelhage/llama/cmd/internal/cli"
+	"github.com/nelhage/llama/cmd/internal/wrapperutil"
+	"github.com/nelhage/llama/files"
+)
+
+// findSpec returns the spec for the tool `argv` runs, from the
+// global and project configs.
+func findSpec(argv []string, wd string) (*cli.ToolSpec, error) {
+	cfg, err := cli.ReadConfig(cli.ConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	project, err := cli.FindProjectConfig(wd)
+	if err != nil {
+		return nil, fmt.Errorf("reading project config: %w", err)
+	}
+	name := toolName(argv[0])
+	spec, ok := cli.ToolSpecs(cfg, project)[name]
+	if !ok {
+		return nil, fmt.Errorf("no spec for %s in tools", name)
+	}
+	if spec.Upload && runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("the local %s doesn't run on Lambda: %s", name, runtime.GOOS)
+	}
+	return &spec, nil
+}
+
+func runRemote(cfg *wrapperutil.Config, argv []string) (int, error) {
+	wd, err := files.WorkingDir()
+	if err != nil {
+		return 0, err
+	}
+	spec, err := findSpec(argv, wd)
+	if err != nil {
+		return 0, err
+	}
+	job, err := ParseJob(spec, argv, wd)
+	if err != nil {
+		return 0, err
+	}
+	args, err := buildInvocation(cfg, spec, job, wd)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Verbose {
+		log.Printf("[llamatool] running %s remotely: %q", job.Tool, args.Args)
+	}
+	return wrapperutil.Invoke(args, true)
+}
+
+func main() {
+	cfg := ParseConfig(os.Environ())
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: llamatool TOOL ARGS...\n")
+		os.Exit(2)
+	}
+	argv := os.Args[1:]
+	wrapperutil.Main("llamatool", &cfg, argv, func() (int, error) {
+		return runRemote(&cfg, argv)
+	})
+}
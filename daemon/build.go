@@ -0,0 +1,154 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultBuild is the build session llamacc records invocations in
+// when LLAMACC_BUILD_ID isn't set
+const DefaultBuild = "default"
+
+// MaxSlowest is how many of the slowest invocations a BuildReport
+// lists
+const MaxSlowest = 10
+
+// A BuildReport summarizes a build session
+type BuildReport struct {
+	Build   string
+	Started time.Time
+	Ended   time.Time
+
+	Invocations uint64
+	Failures    uint64
+	CacheHits   uint64
+	CacheMisses uint64
+
+	// The time commands spent running remotely, and the time
+	// they would have spent running locally, counting cache hits
+	RemoteCPU time.Duration
+	LocalCPU  time.Duration
+	// The parallelism with which we estimate the build would have
+	// run locally
+	LocalCores int
+
+	// The slowest invocations, slowest first
+	Slowest []BuildInvocation
+}
+
+// A BuildInvocation is one invocation in a build session
+type BuildInvocation struct {
+	Description string
+	E2E         time.Duration
+	Remote      time.Duration
+	Cached      bool
+}
+
+// Wall returns the session's wall time
+func (r *BuildReport) Wall() time.Duration {
+	return r.Ended.Sub(r.Started)
+}
+
+// EstimatedLocal estimates how long the build would have taken
+// locally, if every command had run on one of LocalCores cores
+func (r *BuildReport) EstimatedLocal() time.Duration {
+	if r.LocalCores <= 0 {
+		return r.LocalCPU
+	}
+	return r.LocalCPU / time.Duration(r.LocalCores)
+}
+
+// Saved returns how much wall time building remotely saved. It's
+// negative if the build would have been faster locally.
+func (r *BuildReport) Saved() time.Duration {
+	return r.EstimatedLocal() - r.Wall()
+}
+
+// CacheHitRate returns the fraction of cacheable invocations which
+// hit the result cache
+func (r *BuildReport) CacheHitRate() float64 {
+	if r.CacheHits+r.CacheMisses == 0 {
+		return 0
+	}
+	return float64(r.CacheHits) / float64(r.CacheHits+r.CacheMisses)
+}
+
+// Record adds an invocation to the report
+func (r *BuildReport) Record(inv BuildInvocation, cacheable, failed bool) {
+	r.Invocations++
+	if failed {
+		r.Failures++
+	}
+	if cacheable {
+		if inv.Cached {
+			r.CacheHits++
+		} else {
+			r.CacheMisses++
+		}
+	}
+	if !inv.Cached {
+		r.RemoteCPU += inv.Remote
+	}
+	r.LocalCPU += inv.Remote
+
+	i := len(r.Slowest)
+	for i > 0 && r.Slowest[i-1].E2E < inv.E2E {
+		i--
+	}
+	if i >= MaxSlowest {
+		return
+	}
+	r.Slowest = append(r.Slowest, BuildInvocation{})
+	copy(r.Slowest[i+1:], r.Slowest[i:])
+	r.Slowest[i] = inv
+	if len(r.Slowest) > MaxSlowest {
+		r.Slowest = r.Slowest[:MaxSlowest]
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Millisecond)
+}
+
+// WriteText writes the report in human-readable form
+func (r *BuildReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Build %s: %s to %s\n", r.Build,
+		r.Started.Format(time.RFC3339), r.Ended.Format(time.RFC3339))
+	fmt.Fprintf(tw, "  Invocations\t%d\t(%d failed)\n", r.Invocations, r.Failures)
+	fmt.Fprintf(tw, "  Remote CPU\t%s\n", round(r.RemoteCPU))
+	fmt.Fprintf(tw, "  Wall time\t%s\n", round(r.Wall()))
+	fmt.Fprintf(tw, "  Estimated local\t%s\t(%d cores)\n", round(r.EstimatedLocal()), r.LocalCores)
+	fmt.Fprintf(tw, "  Time saved\t%s\n", round(r.Saved()))
+	if r.CacheHits+r.CacheMisses > 0 {
+		fmt.Fprintf(tw, "  Cache hit rate\t%.1f%%\t(%d/%d)\n",
+			100*r.CacheHitRate(), r.CacheHits, r.CacheHits+r.CacheMisses)
+	}
+	if len(r.Slowest) > 0 {
+		fmt.Fprintf(tw, "Slowest:\n")
+		for _, inv := range r.Slowest {
+			desc := inv.Description
+			if inv.Cached {
+				desc += " (cached)"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\n", round(inv.E2E), desc)
+		}
+	}
+	return tw.Flush()
+}
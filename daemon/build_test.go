@@ -0,0 +1,66 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildReport(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	r := BuildReport{
+		Build:      "ci-42",
+		Started:    start,
+		Ended:      start.Add(time.Minute),
+		LocalCores: 4,
+	}
+	for i := 1; i <= 12; i++ {
+		r.Record(BuildInvocation{
+			Description: fmt.Sprintf("obj/%d.o", i),
+			E2E:         time.Duration(i) * time.Second,
+			Remote:      time.Duration(i) * time.Second,
+			Cached:      i%4 == 0,
+		}, true, i == 7)
+	}
+	r.Record(BuildInvocation{Description: "link", E2E: 500 * time.Millisecond, Remote: 10 * time.Second}, false, false)
+
+	assert.Equal(t, uint64(13), r.Invocations)
+	assert.Equal(t, uint64(1), r.Failures)
+	assert.Equal(t, uint64(3), r.CacheHits)
+	assert.Equal(t, uint64(9), r.CacheMisses)
+	assert.InDelta(t, 0.25, r.CacheHitRate(), 1e-9)
+	// 1..12 is 78s, of which 4, 8 and 12 were cached
+	assert.Equal(t, 88*time.Second, r.LocalCPU)
+	assert.Equal(t, 64*time.Second, r.RemoteCPU)
+	assert.Equal(t, 22*time.Second, r.EstimatedLocal())
+	assert.Equal(t, -38*time.Second, r.Saved())
+
+	require.Len(t, r.Slowest, MaxSlowest)
+	assert.Equal(t, "obj/12.o", r.Slowest[0].Description)
+	assert.Equal(t, "obj/3.o", r.Slowest[MaxSlowest-1].Description)
+
+	var buf strings.Builder
+	require.NoError(t, r.WriteText(&buf))
+	text := buf.String()
+	assert.Contains(t, text, "Build ci-42: 2020-10-01T12:00:00Z to 2020-10-01T12:01:00Z\n")
+	assert.Contains(t, text, "Cache hit rate   25.0%  (3/12)\n")
+	assert.Contains(t, text, "  12s  obj/12.o (cached)\n  11s  obj/11.o\n")
+}
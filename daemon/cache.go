@@ -0,0 +1,200 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// A CacheConfig divides the result cache into namespaces, so that
+// several users or projects can share one object store. Objects are
+// named by their contents, and so are always shared; only the
+// result-cache entries, which map an invocation to its outputs, are
+// kept apart.
+type CacheConfig struct {
+	// The namespace to look results up in first, and to record
+	// them in. Results recorded without a namespace are under
+	// ResultCachePrefix itself.
+	Namespace string `json:"namespace,omitempty"`
+	// Namespaces to also look results up in, in order, but never
+	// to record them in, such as one that CI populates
+	Shared []string `json:"shared,omitempty"`
+	// Whether to look results up, record them, both, or
+	// neither
+	Policy CachePolicy `json:"policy,omitempty"`
+}
+
+// A CachePolicy says whether to read from a cache, write to it, or
+// both. CI can use CacheWriteOnly to repopulate a cache without
+// trusting what is already in it, and someone experimenting with a
+// modified compiler can use CacheBypass, or CacheReadOnly, to keep
+// its results out of a shared cache.
+type CachePolicy string
+
+const (
+	// Look results up, and record new ones. This is the default.
+	CacheReadWrite CachePolicy = "read-write"
+	// Look results up, but never record them
+	CacheReadOnly CachePolicy = "read-only"
+	// Record results, but never look them up
+	CacheWriteOnly CachePolicy = "write-only"
+	// Neither look results up nor record them
+	CacheBypass CachePolicy = "bypass"
+)
+
+// ParseCachePolicy parses the name of a CachePolicy.
+func ParseCachePolicy(s string) (CachePolicy, error) {
+	switch p := CachePolicy(s); p {
+	case CacheReadWrite, CacheReadOnly, CacheWriteOnly, CacheBypass:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown cache policy %q: expected read-write, read-only, write-only, or bypass", s)
+}
+
+// Set implements flag.Value
+func (p *CachePolicy) Set(s string) error {
+	v, err := ParseCachePolicy(s)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+func (p CachePolicy) String() string {
+	if p == "" {
+		return string(CacheReadWrite)
+	}
+	return string(p)
+}
+
+// Reads reports whether p allows looking results up.
+func (p CachePolicy) Reads() bool {
+	return p == "" || p == CacheReadWrite || p == CacheReadOnly
+}
+
+// Writes reports whether p allows recording results.
+func (p CachePolicy) Writes() bool {
+	return p == "" || p == CacheReadWrite || p == CacheWriteOnly
+}
+
+// Restrict returns the policy that allows only what both p and `o`
+// allow.
+func (p CachePolicy) Restrict(o CachePolicy) CachePolicy {
+	reads, writes := p.Reads() && o.Reads(), p.Writes() && o.Writes()
+	switch {
+	case reads && writes:
+		return CacheReadWrite
+	case reads:
+		return CacheReadOnly
+	case writes:
+		return CacheWriteOnly
+	}
+	return CacheBypass
+}
+
+var namespaceRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateNamespace checks that `ns` can be used as a component of a
+// store key.
+func ValidateNamespace(ns string) error {
+	if !namespaceRE.MatchString(ns) {
+		return fmt.Errorf("bad cache namespace %q: expected letters, digits, '.', '_' and '-'", ns)
+	}
+	return nil
+}
+
+// Validate checks c's policy, and every namespace c names.
+func (c CacheConfig) Validate() error {
+	if c.Policy != "" {
+		if _, err := ParseCachePolicy(string(c.Policy)); err != nil {
+			return err
+		}
+	}
+	if c.Namespace != "" {
+		if err := ValidateNamespace(c.Namespace); err != nil {
+			return err
+		}
+	}
+	for _, ns := range c.Shared {
+		if err := ValidateNamespace(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Override returns c with the settings in `o`, if it is non-nil,
+// replacing c's. o's policy can only restrict c's, so that clients
+// can't opt back in to writing to a read-only cache.
+func (c CacheConfig) Override(o *CacheConfig) CacheConfig {
+	if o == nil {
+		return c
+	}
+	out := c
+	if o.Namespace != "" {
+		out.Namespace = o.Namespace
+	}
+	if o.Shared != nil {
+		out.Shared = o.Shared
+	}
+	if o.Policy != "" {
+		out.Policy = c.Policy.Restrict(o.Policy)
+	}
+	return out
+}
+
+// Readable reports whether results may be looked up.
+func (c CacheConfig) Readable() bool {
+	return c.Policy.Reads()
+}
+
+// Writable reports whether results may be recorded in c's namespace:
+// c's policy allows writes, and the namespace isn't one of the shared
+// ones.
+func (c CacheConfig) Writable() bool {
+	if !c.Policy.Writes() {
+		return false
+	}
+	for _, ns := range c.Shared {
+		if ns == c.Namespace {
+			return false
+		}
+	}
+	return true
+}
+
+// ResultKey returns the store key for the result-cache entry `hash`
+// in namespace `ns`.
+func ResultKey(ns, hash string) string {
+	return path.Join(ResultCachePrefix, ns, hash)
+}
+
+// LookupKeys returns the keys under which to look up the entry
+// `hash`, in order: c's own namespace, then the shared ones.
+func (c CacheConfig) LookupKeys(hash string) []string {
+	keys := []string{ResultKey(c.Namespace, hash)}
+	seen := map[string]bool{c.Namespace: true}
+	for _, ns := range c.Shared {
+		if seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		keys = append(keys, ResultKey(ns, hash))
+	}
+	return keys
+}
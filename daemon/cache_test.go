@@ -0,0 +1,83 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheConfig(t *testing.T) {
+	var none CacheConfig
+	assert.Equal(t, []string{"results/abcd"}, none.LookupKeys("abcd"))
+	assert.True(t, none.Writable())
+
+	cfg := CacheConfig{Namespace: "alice", Shared: []string{"ci", "alice", "release"}}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"results/alice/abcd", "results/ci/abcd", "results/release/abcd"}, cfg.LookupKeys("abcd"))
+	assert.False(t, cfg.Writable(), "alice is also shared")
+
+	cfg = CacheConfig{Namespace: "alice", Shared: []string{"ci"}}
+	assert.True(t, cfg.Writable())
+	assert.Equal(t, "results/alice/abcd", ResultKey(cfg.Namespace, "abcd"))
+
+	over := cfg.Override(&CacheConfig{Namespace: "proj"})
+	assert.Equal(t, CacheConfig{Namespace: "proj", Shared: []string{"ci"}}, over)
+	over = cfg.Override(&CacheConfig{Shared: []string{}})
+	assert.Empty(t, over.Shared)
+	assert.Equal(t, cfg, cfg.Override(nil))
+
+	ro := CacheConfig{Policy: CacheReadOnly}
+	assert.False(t, ro.Override(&CacheConfig{Namespace: "proj"}).Writable(), "clients can't make a read-only cache writable")
+	assert.False(t, ro.Override(&CacheConfig{Policy: CacheReadWrite}).Writable())
+	assert.False(t, cfg.Override(&CacheConfig{Policy: CacheReadOnly}).Writable())
+	assert.Equal(t, CacheBypass, ro.Override(&CacheConfig{Policy: CacheWriteOnly}).Policy)
+
+	for _, bad := range []string{"../x", "a/b", "", ".hidden", "a b"} {
+		assert.Error(t, ValidateNamespace(bad), bad)
+	}
+	assert.Error(t, CacheConfig{Shared: []string{"ok", "not/ok"}}.Validate())
+}
+
+func TestCachePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy        CachePolicy
+		reads, writes bool
+	}{
+		{"", true, true},
+		{CacheReadWrite, true, true},
+		{CacheReadOnly, true, false},
+		{CacheWriteOnly, false, true},
+		{CacheBypass, false, false},
+	} {
+		assert.Equal(t, tc.reads, tc.policy.Reads(), tc.policy)
+		assert.Equal(t, tc.writes, tc.policy.Writes(), tc.policy)
+		assert.Equal(t, tc.policy.String(), CachePolicy("").Restrict(tc.policy).String())
+		assert.Equal(t, CacheBypass, CacheBypass.Restrict(tc.policy))
+	}
+	assert.Equal(t, CacheReadOnly, CacheReadOnly.Restrict(CacheReadWrite))
+	assert.Equal(t, CacheWriteOnly, CacheReadWrite.Restrict(CacheWriteOnly))
+
+	var p CachePolicy
+	assert.NoError(t, p.Set("write-only"))
+	assert.Equal(t, CacheWriteOnly, p)
+	assert.Error(t, p.Set("readonly"))
+	assert.Error(t, CacheConfig{Policy: "sometimes"}.Validate())
+
+	wo := CacheConfig{Policy: CacheWriteOnly}
+	assert.False(t, wo.Readable())
+	assert.True(t, wo.Writable())
+}
@@ -42,6 +42,12 @@ func (c *Client) InvokeWithFiles(in *InvokeWithFilesArgs) (*InvokeWithFilesReply
 	return &out, err
 }
 
+func (c *Client) Invoke(in *InvokeArgs) (*InvokeReply, error) {
+	var out InvokeReply
+	err := c.conn.Call("Daemon.Invoke", in, &out)
+	return &out, err
+}
+
 func (c *Client) GetDaemonStats(in *StatsArgs) (*StatsReply, error) {
 	var out StatsReply
 	err := c.conn.Call("Daemon.GetDaemonStats", in, &out)
@@ -59,3 +65,93 @@ func (c *Client) GetCompilerIncludePath(in *GetCompilerIncludePathArgs) (*GetCom
 	err := c.conn.Call("Daemon.GetCompilerIncludePath", in, &out)
 	return &out, err
 }
+
+func (c *Client) ScanIncludes(in *ScanIncludesArgs) (*ScanIncludesReply, error) {
+	var out ScanIncludesReply
+	err := c.conn.Call("Daemon.ScanIncludes", in, &out)
+	return &out, err
+}
+
+func (c *Client) LookupDeps(in *LookupDepsArgs) (*LookupDepsReply, error) {
+	var out LookupDepsReply
+	err := c.conn.Call("Daemon.LookupDeps", in, &out)
+	return &out, err
+}
+
+func (c *Client) RecordDeps(in *RecordDepsArgs) (*RecordDepsReply, error) {
+	var out RecordDepsReply
+	err := c.conn.Call("Daemon.RecordDeps", in, &out)
+	return &out, err
+}
+
+func (c *Client) CheckToolchain(in *CheckToolchainArgs) (*CheckToolchainReply, error) {
+	var out CheckToolchainReply
+	err := c.conn.Call("Daemon.CheckToolchain", in, &out)
+	return &out, err
+}
+
+func (c *Client) Prewarm(in *PrewarmArgs) (*PrewarmReply, error) {
+	var out PrewarmReply
+	err := c.conn.Call("Daemon.Prewarm", in, &out)
+	return &out, err
+}
+
+func (c *Client) RecordFallback(in *RecordFallbackArgs) (*RecordFallbackReply, error) {
+	var out RecordFallbackReply
+	err := c.conn.Call("Daemon.RecordFallback", in, &out)
+	return &out, err
+}
+
+func (c *Client) ReadStream(in *ReadStreamArgs) (*ReadStreamReply, error) {
+	var out ReadStreamReply
+	err := c.conn.Call("Daemon.ReadStream", in, &out)
+	return &out, err
+}
+
+func (c *Client) Status(in *StatusArgs) (*StatusReply, error) {
+	var out StatusReply
+	err := c.conn.Call("Daemon.Status", in, &out)
+	return &out, err
+}
+
+func (c *Client) BeginBuild(in *BeginBuildArgs) (*BeginBuildReply, error) {
+	var out BeginBuildReply
+	err := c.conn.Call("Daemon.BeginBuild", in, &out)
+	return &out, err
+}
+
+func (c *Client) EndBuild(in *EndBuildArgs) (*EndBuildReply, error) {
+	var out EndBuildReply
+	err := c.conn.Call("Daemon.EndBuild", in, &out)
+	return &out, err
+}
+
+func (c *Client) Presign(in *PresignArgs) (*PresignReply, error) {
+	var out PresignReply
+	err := c.conn.Call("Daemon.Presign", in, &out)
+	return &out, err
+}
+
+func (c *Client) Materialize(in *MaterializeArgs) (*MaterializeReply, error) {
+	var out MaterializeReply
+	err := c.conn.Call("Daemon.Materialize", in, &out)
+	return &out, err
+}
+
+func (c *Client) CheckUploads(in *CheckUploadsArgs) (*CheckUploadsReply, error) {
+	var out CheckUploadsReply
+	err := c.conn.Call("Daemon.CheckUploads", in, &out)
+	return &out, err
+}
+
+func (c *Client) Stage(in *StageArgs) (*StageReply, error) {
+	var out StageReply
+	err := c.conn.Call("Daemon.Stage", in, &out)
+	return &out, err
+}
+
+func (c *Client) CancelInvocation(in *CancelInvocationArgs) (*CancelInvocationReply, error) {
+	var out CancelInvocationReply
+	err := c.conn.Call("Daemon.CancelInvocation", in, &out)
+	return &out, err
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import "github.com/nelhage/llama/protocol"
+
+// Pricing describes the AWS prices, in US dollars, used to estimate
+// what a build costs.
+type Pricing struct {
+	// Per GB-second of Lambda execution
+	LambdaGBSecond float64 `json:"lambda_gb_second,omitempty"`
+	// Per Lambda invocation
+	LambdaRequest float64 `json:"lambda_request,omitempty"`
+	// Per S3 PUT, and per GET
+	S3Write float64 `json:"s3_write,omitempty"`
+	S3Read  float64 `json:"s3_read,omitempty"`
+	// Per GB transferred out of S3 to the internet
+	S3XferOutGB float64 `json:"s3_xfer_out_gb,omitempty"`
+}
+
+// DefaultPricing is the us-east-1 list price for x86_64 functions
+var DefaultPricing = Pricing{
+	LambdaGBSecond: 0.0000166667,
+	LambdaRequest:  0.20 / 1000000,
+	S3Write:        0.005 / 1000,
+	S3Read:         0.0004 / 1000,
+	S3XferOutGB:    0.09,
+}
+
+// PricingFor returns the default pricing for functions of the given
+// architecture. Graviton functions are billed at a lower rate.
+func PricingFor(arch string) Pricing {
+	p := DefaultPricing
+	if arch == "arm64" {
+		p.LambdaGBSecond = 0.0000133334
+	}
+	return p
+}
+
+// Cost is an estimate, in US dollars, of what some usage cost
+type Cost struct {
+	LambdaCompute  float64
+	LambdaRequests float64
+	S3Writes       float64
+	S3Reads        float64
+	S3XferOut      float64
+}
+
+func (c *Cost) Total() float64 {
+	return c.LambdaCompute + c.LambdaRequests + c.S3Writes + c.S3Reads + c.S3XferOut
+}
+
+// GBSeconds converts Lambda usage into the units Lambda is billed in
+func GBSeconds(u *protocol.UsageMetrics) float64 {
+	return float64(u.Lambda_MB_Millis) / (1024 * 1000)
+}
+
+// Cost estimates the cost of `u` under this pricing
+func (p *Pricing) Cost(u *protocol.UsageMetrics) Cost {
+	return Cost{
+		LambdaCompute:  GBSeconds(u) * p.LambdaGBSecond,
+		LambdaRequests: float64(u.Lambda_Requests) * p.LambdaRequest,
+		S3Writes:       float64(u.S3_Write_Requests) * p.S3Write,
+		S3Reads:        float64(u.S3_Read_Requests) * p.S3Read,
+		S3XferOut:      float64(u.S3_Xfer_Out) / (1024 * 1024 * 1024) * p.S3XferOutGB,
+	}
+}
+
+// A Budget limits what the daemon will spend in a session: from when
+// it starts, or its statistics were last reset, until it exits.
+type Budget struct {
+	// Once the session's estimated cost reaches this many
+	// dollars, allow at most ThrottleConcurrency invocations
+	// (default 1) at a time.
+	ThrottleCost        float64 `json:"throttle_cost,omitempty"`
+	ThrottleConcurrency int64   `json:"throttle_concurrency,omitempty"`
+	// Once the session's estimated cost reaches this many
+	// dollars, refuse to invoke functions at all.
+	MaxCost float64 `json:"max_cost,omitempty"`
+}
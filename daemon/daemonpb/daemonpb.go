@@ -0,0 +1,60 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package daemonpb implements the Llama daemon's gRPC API, which is
+// defined in daemon.proto. The messages and service stubs are
+// generated; run "go generate" after changing daemon.proto, with
+// protoc, protoc-gen-go and protoc-gen-go-grpc on your PATH.
+package daemonpb
+
+//go:generate protoc -I../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative daemon/daemonpb/daemon.proto
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+)
+
+// Supports reports whether the daemon supports clients of protocol
+// version `v`
+func (m *VersionReply) Supports(v uint32) bool {
+	return m.MinProtocolVersion <= v && v <= m.ProtocolVersion
+}
+
+// Client is a client of the Daemon service
+type Client struct {
+	DaemonClient
+	conn *grpc.ClientConn
+}
+
+// Dial connects to the daemon's gRPC socket at `sockPath`
+func Dial(ctx context.Context, sockPath string) (*Client, error) {
+	conn, err := grpc.DialContext(ctx, "unix:"+sockPath,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sockPath)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{NewDaemonClient(conn), conn}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemonpb
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type fakeDaemon struct {
+	UnimplementedDaemonServer
+}
+
+func (fakeDaemon) GetVersion(_ context.Context, in *VersionRequest) (*VersionReply, error) {
+	return &VersionReply{DaemonVersion: "test", ProtocolVersion: in.ProtocolVersion}, nil
+}
+func (fakeDaemon) Invoke(_ context.Context, in *InvokeRequest) (*InvokeReply, error) {
+	return &InvokeReply{Stdout: []byte(strings.Join(in.Args, " ")), ExitStatus: 3}, nil
+}
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	sock := filepath.Join(t.TempDir(), "grpc.sock")
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	RegisterDaemonServer(srv, fakeDaemon{})
+	reflection.Register(srv)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	client, err := Dial(ctx, sock)
+	require.NoError(t, err)
+	defer client.Close()
+
+	v, err := client.GetVersion(ctx, &VersionRequest{ProtocolVersion: 7})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&VersionReply{DaemonVersion: "test", ProtocolVersion: 7}, v), v)
+	assert.True(t, v.Supports(7))
+	assert.False(t, v.Supports(8))
+
+	out, err := client.Invoke(ctx, &InvokeRequest{Args: []string{"echo", "hi"}})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&InvokeReply{Stdout: []byte("echo hi"), ExitStatus: 3}, out), out)
+
+	// Methods the server doesn't implement fail cleanly
+	_, err = client.Ping(ctx, &PingRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	// Reflection describes the service
+	conn, err := grpc.DialContext(ctx, "unix:"+sock, grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		}))
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+	assert.Contains(t, services, "llama.daemon.v1.Daemon")
+
+	require.NoError(t, stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "llama.daemon.v1.Daemon"},
+	}))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	fds := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+	require.Len(t, fds, 1)
+	var fd descriptorpb.FileDescriptorProto
+	require.NoError(t, proto.Unmarshal(fds[0], &fd))
+	assert.Equal(t, "daemon/daemonpb/daemon.proto", fd.GetName())
+	assert.Equal(t, "Invoke", fd.GetService()[0].GetMethod()[2].GetName())
+}
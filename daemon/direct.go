@@ -0,0 +1,51 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/store/s3store"
+)
+
+// ErrNoDirect is returned by direct stores when the daemon's object
+// store can't presign requests
+var ErrNoDirect = errors.New("the daemon's object store does not support direct transfers")
+
+type presigner struct {
+	c *Client
+}
+
+func (p presigner) Presign(ctx context.Context, puts, gets []string) ([]store.PresignedRequest, []store.PresignedRequest, error) {
+	out, err := p.c.Presign(&PresignArgs{Put: puts, Get: gets})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !out.Supported {
+		return nil, nil, ErrNoDirect
+	}
+	return out.Put, out.Get, nil
+}
+
+// NewDirectStore returns a store which transfers objects to and from
+// the daemon's object store directly, with requests the daemon
+// presigns, so that their contents needn't pass through the daemon.
+// Its operations fail with ErrNoDirect if the daemon's store doesn't
+// support that.
+func (c *Client) NewDirectStore() store.Store {
+	return s3store.NewDirect(presigner{c})
+}
@@ -0,0 +1,215 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package distcc implements the server side of version 1 of the
+// distcc protocol, which stock distcc clients speak when they
+// preprocess locally and send the preprocessed source to a server
+// to compile.
+//
+// A request consists of a series of tokens, each a four-character
+// name followed by a value in eight hex digits. Some tokens are
+// followed by a string of that many bytes.
+package distcc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const ProtocolVersion = 1
+
+// DefaultPort is the port distcc clients connect to by default
+const DefaultPort = 3632
+
+// Limits on what we'll accept from a client, to avoid allocating
+// unbounded memory on a malformed request
+const (
+	maxArgs   = 1 << 16
+	maxString = 1 << 30
+)
+
+// A Job is a compilation requested by a client
+type Job struct {
+	// The compiler command line, including the compiler itself.
+	// It names the original source file and output, which the
+	// server substitutes its own paths for.
+	Args []string
+	// The preprocessed source
+	Source []byte
+}
+
+// A Result is the outcome of a compilation, to send back to the
+// client
+type Result struct {
+	ExitStatus int
+	Stdout     []byte
+	Stderr     []byte
+	// The object file, if the compilation succeeded
+	Object []byte
+}
+
+func readToken(r *bufio.Reader, want string) (int, error) {
+	var buf [12]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, fmt.Errorf("reading %s: %w", want, err)
+	}
+	if name := string(buf[:4]); name != want {
+		return 0, fmt.Errorf("expected %s, got %q", want, name)
+	}
+	val, err := strconv.ParseUint(string(buf[4:]), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s: bad value %q", want, buf[4:])
+	}
+	return int(val), nil
+}
+
+func readString(r *bufio.Reader, want string) ([]byte, error) {
+	n, err := readToken(r, want)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxString {
+		return nil, fmt.Errorf("%s: %d bytes is too large", want, n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", want, err)
+	}
+	return buf, nil
+}
+
+// ReadJob reads a request from a client
+func ReadJob(r *bufio.Reader) (*Job, error) {
+	version, err := readToken(r, "DIST")
+	if err != nil {
+		return nil, err
+	}
+	if version != ProtocolVersion {
+		return nil, fmt.Errorf("unsupported distcc protocol version %d (compression and pump mode are not supported)", version)
+	}
+	argc, err := readToken(r, "ARGC")
+	if err != nil {
+		return nil, err
+	}
+	if argc == 0 || argc > maxArgs {
+		return nil, fmt.Errorf("bad ARGC: %d", argc)
+	}
+	var job Job
+	for i := 0; i < argc; i++ {
+		arg, err := readString(r, "ARGV")
+		if err != nil {
+			return nil, err
+		}
+		job.Args = append(job.Args, string(arg))
+	}
+	if job.Source, err = readString(r, "DOTI"); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func writeToken(w io.Writer, name string, val int) error {
+	_, err := fmt.Fprintf(w, "%s%08x", name, val)
+	return err
+}
+
+func writeString(w io.Writer, name string, data []byte) error {
+	if err := writeToken(w, name, len(data)); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// WriteResult writes the response to a request
+func WriteResult(w io.Writer, res *Result) error {
+	if err := writeToken(w, "DONE", ProtocolVersion); err != nil {
+		return err
+	}
+	// The status is a wait(2) status
+	if err := writeToken(w, "STAT", (res.ExitStatus&0xff)<<8); err != nil {
+		return err
+	}
+	if err := writeString(w, "SERR", res.Stderr); err != nil {
+		return err
+	}
+	if err := writeString(w, "SOUT", res.Stdout); err != nil {
+		return err
+	}
+	var obj []byte
+	if res.ExitStatus == 0 {
+		obj = res.Object
+	}
+	return writeString(w, "DOTO", obj)
+}
+
+// preprocessedLangs maps the extensions of source files to the
+// language of their preprocessed output
+var preprocessedLangs = map[string]string{
+	".c":   "cpp-output",
+	".i":   "cpp-output",
+	".cc":  "c++-cpp-output",
+	".cpp": "c++-cpp-output",
+	".cxx": "c++-cpp-output",
+	".cp":  "c++-cpp-output",
+	".c++": "c++-cpp-output",
+	".C":   "c++-cpp-output",
+	".CPP": "c++-cpp-output",
+	".ii":  "c++-cpp-output",
+	".m":   "objective-c-cpp-output",
+	".mi":  "objective-c-cpp-output",
+	".mm":  "objective-c++-cpp-output",
+	".M":   "objective-c++-cpp-output",
+	".mii": "objective-c++-cpp-output",
+}
+
+// Command returns the command line to compile the job, reading the
+// preprocessed source from stdin and writing the object to `output`.
+func (j *Job) Command(output string) ([]string, error) {
+	if len(j.Args) == 0 {
+		return nil, errors.New("empty command line")
+	}
+	out := []string{path.Base(j.Args[0])}
+	var lang string
+	for i := 1; i < len(j.Args); i++ {
+		arg := j.Args[i]
+		switch {
+		case arg == "-o":
+			// Skip the original output
+			i++
+		case strings.HasPrefix(arg, "-o"):
+		case strings.HasPrefix(arg, "-"):
+			out = append(out, arg)
+		default:
+			l, ok := preprocessedLangs[path.Ext(arg)]
+			if !ok {
+				out = append(out, arg)
+				continue
+			}
+			if lang != "" {
+				return nil, fmt.Errorf("multiple inputs: %q", j.Args)
+			}
+			lang = l
+		}
+	}
+	if lang == "" {
+		return nil, fmt.Errorf("no input found: %q", j.Args)
+	}
+	return append(out, "-x", lang, "-o", output, "-"), nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distcc
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadJob(t *testing.T) {
+	// As sent by `distcc gcc -O2 -c hello.c -o hello.o`
+	req := "DIST00000001" +
+		"ARGC00000006" +
+		"ARGV00000003gcc" +
+		"ARGV00000003-O2" +
+		"ARGV00000002-c" +
+		"ARGV00000007hello.c" +
+		"ARGV00000002-o" +
+		"ARGV00000007hello.o" +
+		"DOTI0000000cint main();\n"
+	job, err := ReadJob(bufio.NewReader(strings.NewReader(req)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gcc", "-O2", "-c", "hello.c", "-o", "hello.o"}, job.Args)
+	assert.Equal(t, "int main();\n", string(job.Source))
+
+	_, err = ReadJob(bufio.NewReader(strings.NewReader("DIST00000002")))
+	assert.Error(t, err)
+	_, err = ReadJob(bufio.NewReader(strings.NewReader(req[:len(req)-4])))
+	assert.Error(t, err)
+	_, err = ReadJob(bufio.NewReader(strings.NewReader("DIST00000001ARGX00000001")))
+	assert.Error(t, err)
+}
+
+func TestWriteResult(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteResult(&buf, &Result{
+		Stderr: []byte("warning\n"),
+		Object: []byte("ELF"),
+	}))
+	assert.Equal(t, "DONE00000001STAT00000000SERR00000008warning\nSOUT00000000DOTO00000003ELF", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteResult(&buf, &Result{
+		ExitStatus: 1,
+		Stderr:     []byte("error\n"),
+		Object:     []byte("ELF"),
+	}))
+	assert.Equal(t, "DONE00000001STAT00000100SERR00000006error\nSOUT00000000DOTO00000000", buf.String())
+}
+
+func TestCommand(t *testing.T) {
+	cases := []struct {
+		args []string
+		cmd  []string
+		err  bool
+	}{
+		{
+			[]string{"gcc", "-O2", "-c", "hello.c", "-o", "hello.o"},
+			[]string{"gcc", "-O2", "-c", "-x", "cpp-output", "-o", "out.o", "-"},
+			false,
+		},
+		{
+			[]string{"/usr/bin/g++", "-std=c++17", "-c", "-ohello.o", "src/hello.cc"},
+			[]string{"g++", "-std=c++17", "-c", "-x", "c++-cpp-output", "-o", "out.o", "-"},
+			false,
+		},
+		{
+			[]string{"gcc", "-c", "a.c", "b.c"},
+			nil,
+			true,
+		},
+		{
+			[]string{"gcc", "-c"},
+			nil,
+			true,
+		},
+	}
+	for _, tc := range cases {
+		job := Job{Args: tc.args}
+		cmd, err := job.Command("out.o")
+		if tc.err {
+			assert.Error(t, err, "%q", tc.args)
+		} else if assert.NoError(t, err, "%q", tc.args) {
+			assert.Equal(t, tc.cmd, cmd)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package icecc implements enough of the icecream protocol for a
+// compile server to join an icecream scheduler and accept jobs from
+// icecream clients.
+//
+// We speak only protocol version 21, the oldest which current
+// schedulers and clients still accept, so that we need not track the
+// protocol's later revisions. Each connection starts with both sides
+// exchanging their versions and then confirming the lower of the
+// two. After that, each message is a big-endian length, followed by
+// a body made of a message type and its fields: big-endian 32-bit
+// integers, NUL-terminated strings prefixed with their length, and
+// LZO1X-compressed data prefixed with its uncompressed and compressed
+// lengths.
+package icecc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the version of the icecream protocol we speak
+const ProtocolVersion = 21
+
+// DefaultSchedulerPort is the port on which schedulers listen for
+// compile servers
+const DefaultSchedulerPort = 8765
+
+// DefaultPort is the port compile servers listen on for clients
+const DefaultPort = 10245
+
+// Limits on what we'll accept from a peer, to avoid allocating
+// unbounded memory on a malformed message
+const (
+	maxMessage = 1 << 30
+	maxList    = 1 << 16
+)
+
+// ChunkSize is the largest FileChunk SendFile sends
+const ChunkSize = 100000
+
+// A Conn is a connection to an icecream peer
+type Conn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// Negotiate performs the version handshake on `rw`, returning a Conn
+// ready to exchange messages
+func Negotiate(rw io.ReadWriter) (*Conn, error) {
+	c := &Conn{r: bufio.NewReader(rw), w: bufio.NewWriter(rw)}
+	if err := c.sendVersion(ProtocolVersion); err != nil {
+		return nil, err
+	}
+	theirs, err := c.readVersion()
+	if err != nil {
+		return nil, err
+	}
+	if theirs < ProtocolVersion {
+		return nil, fmt.Errorf("peer speaks protocol %d; need at least %d", theirs, ProtocolVersion)
+	}
+	if err := c.sendVersion(ProtocolVersion); err != nil {
+		return nil, err
+	}
+	confirmed, err := c.readVersion()
+	if err != nil {
+		return nil, err
+	}
+	if confirmed != ProtocolVersion {
+		return nil, fmt.Errorf("peer confirmed protocol %d, not %d", confirmed, ProtocolVersion)
+	}
+	return c, nil
+}
+
+func (c *Conn) sendVersion(v uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	if _, err := c.w.Write(buf[:]); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *Conn) readVersion() (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(c.r, buf[:]); err != nil {
+		return 0, fmt.Errorf("reading protocol version: %w", err)
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+// Send writes a message to the peer
+func (c *Conn) Send(m Msg) error {
+	e := encoder{buf: make([]byte, 4, 64)}
+	e.u32(uint32(m.Type()))
+	m.encode(&e)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	if _, err := c.w.Write(e.buf); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// Recv reads the next message from the peer
+func (c *Conn) Recv() (Msg, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n < 4 || n > maxMessage {
+		return nil, fmt.Errorf("bad message length %d", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	d := decoder{buf: body}
+	m := newMsg(MsgType(d.u32()))
+	if err := m.decode(&d); err != nil {
+		return nil, fmt.Errorf("decoding message %c: %w", m.Type(), err)
+	}
+	return m, nil
+}
+
+// SendFile sends `data` as a series of FileChunks, followed by an End
+func (c *Conn) SendFile(data []byte) error {
+	for len(data) > 0 {
+		n := len(data)
+		if n > ChunkSize {
+			n = ChunkSize
+		}
+		if err := c.Send(&FileChunk{Data: data[:n]}); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return c.Send(&End{})
+}
+
+// RecvFile reads FileChunks up to the next End, returning their
+// concatenated contents
+func (c *Conn) RecvFile() ([]byte, error) {
+	var data []byte
+	for {
+		m, err := c.Recv()
+		if err != nil {
+			return nil, err
+		}
+		switch m := m.(type) {
+		case *FileChunk:
+			if len(data)+len(m.Data) > maxMessage {
+				return nil, errors.New("file too large")
+			}
+			data = append(data, m.Data...)
+		case *End:
+			return data, nil
+		default:
+			return nil, fmt.Errorf("expected file chunk, got message %c", m.Type())
+		}
+	}
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u32(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.u32(1)
+	} else {
+		e.u32(0)
+	}
+}
+
+func (e *encoder) str(s string) {
+	e.u32(uint32(len(s) + 1))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) strs(ss []string) {
+	e.u32(uint32(len(ss)))
+	for _, s := range ss {
+		e.str(s)
+	}
+}
+
+func (e *encoder) compressed(data []byte) {
+	lzo := lzoLiteral(data)
+	e.u32(uint32(len(data)))
+	e.u32(uint32(len(lzo)))
+	e.buf = append(e.buf, lzo...)
+}
+
+// decoder reads fields from a message body. The first error sticks,
+// and further reads return zero values.
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+var errShort = errors.New("message truncated")
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf)-d.pos {
+		d.err = errShort
+		return nil
+	}
+	d.pos += n
+	return d.buf[d.pos-n : d.pos]
+}
+
+func (d *decoder) u32() uint32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) bool() bool {
+	return d.u32() != 0
+}
+
+func (d *decoder) str() string {
+	n := int(d.u32())
+	if n == 0 {
+		return ""
+	}
+	b := d.take(n)
+	if b == nil {
+		return ""
+	}
+	if b[n-1] != 0 {
+		d.err = errors.New("string not NUL-terminated")
+		return ""
+	}
+	return string(b[:n-1])
+}
+
+func (d *decoder) strs() []string {
+	n := d.u32()
+	if n > maxList {
+		d.err = fmt.Errorf("list too long: %d", n)
+		return nil
+	}
+	var out []string
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		out = append(out, d.str())
+	}
+	return out
+}
+
+func (d *decoder) compressed() []byte {
+	size := d.u32()
+	clen := d.u32()
+	if d.err != nil {
+		return nil
+	}
+	if size > maxMessage {
+		d.err = fmt.Errorf("chunk too large: %d", size)
+		return nil
+	}
+	if size == 0 && clen == 0 {
+		return nil
+	}
+	lzo := d.take(int(clen))
+	if d.err != nil {
+		return nil
+	}
+	out, err := lzoDecompress(lzo, int(size))
+	if err != nil {
+		d.err = err
+	}
+	return out
+}
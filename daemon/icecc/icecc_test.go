@@ -0,0 +1,228 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icecc
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLZO(t *testing.T) {
+	cases := []struct {
+		name string
+		lzo  []byte
+		out  string
+	}{
+		{"empty", []byte{0x11, 0, 0}, ""},
+		{"short literal", []byte{20, 'a', 'b', 'c', 0x11, 0, 0}, "abc"},
+		// "abc", then an M2 match of length 3 at distance 3
+		{"M2 match", []byte{20, 'a', 'b', 'c', 72, 0, 0x11, 0, 0}, "abcabc"},
+		// "abc", then an M3 match of length 9 at distance 3
+		{"M3 match", []byte{20, 'a', 'b', 'c', 39, 8, 0, 0x11, 0, 0}, "abcabcabcabc"},
+		// "abc", a match of length 3 followed by two literals,
+		// then a 2-byte M1 match at distance 2
+		{"M1 match", []byte{20, 'a', 'b', 'c', 74, 0, 'x', 'y', 4, 0, 0x11, 0, 0}, "abcabcxyxy"},
+	}
+	for _, tc := range cases {
+		out, err := lzoDecompress(tc.lzo, len(tc.out))
+		if assert.NoError(t, err, tc.name) {
+			assert.Equal(t, tc.out, string(out), tc.name)
+		}
+	}
+
+	_, err := lzoDecompress([]byte{20, 'a', 'b', 'c'}, 3)
+	assert.Error(t, err, "missing EOF")
+	_, err = lzoDecompress([]byte{20, 'a', 'b', 'c', 72, 1, 0x11, 0, 0}, 6)
+	assert.Error(t, err, "distance out of range")
+	_, err = lzoDecompress([]byte{20, 'a', 'b', 'c', 0x11, 0, 0}, 2)
+	assert.Error(t, err, "too long")
+
+	for _, n := range []int{0, 1, 3, 4, 238, 239, 255 + 18, 255 + 19, 100000} {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+		out, err := lzoDecompress(lzoLiteral(data), n)
+		if assert.NoError(t, err, "n=%d", n) {
+			assert.Equal(t, data, out, "n=%d", n)
+		}
+	}
+}
+
+func TestCodec(t *testing.T) {
+	msgs := []Msg{
+		&Ping{},
+		&Login{
+			Port:    DefaultPort,
+			MaxKids: 1000,
+			Envs: []Env{
+				{Platform: "x86_64", Version: "abc123"},
+			},
+			NodeName:       "llama",
+			HostPlatform:   "x86_64",
+			ChrootPossible: true,
+		},
+		&Stats{Load: 10, FreeMem: 1 << 20},
+		&ConfCS{MaxSchedulerPong: 3, MaxSchedulerPing: 20},
+		&CompileFile{
+			Lang:        LangCXX,
+			JobID:       42,
+			RemoteFlags: []string{"-O2"},
+			RestFlags:   []string{"-Wall"},
+			EnvVersion:  "abc123",
+			Target:      "x86_64",
+		},
+		&FileChunk{Data: []byte("int main() {}\n")},
+		&CompileResult{Stderr: "warning\n", Status: 1},
+		&JobBegin{JobID: 42, StartTime: 1600000000},
+		&JobDone{JobID: 42, RealMsec: 1500, OutUncompressed: 1024},
+		&TransferEnv{Name: "env.tar.gz", Target: "x86_64"},
+		&Unknown{MsgType: MsgText, Body: []byte{0, 0, 0, 1, 'x', 0}},
+		&End{},
+	}
+	var buf bytes.Buffer
+	c := &Conn{r: bufio.NewReader(&buf), w: bufio.NewWriter(&buf)}
+	for _, m := range msgs {
+		require.NoError(t, c.Send(m))
+	}
+	for _, want := range msgs {
+		got, err := c.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// A Ping is a four-byte body holding just its type
+	buf.Reset()
+	require.NoError(t, c.Send(&Ping{}))
+	assert.Equal(t, []byte{0, 0, 0, 4, 0, 0, 0, 'B'}, buf.Bytes())
+
+	// Truncated messages are rejected
+	buf.Reset()
+	buf.Write([]byte{0, 0, 0, 8, 0, 0, 0, byte(MsgJobBegin), 0, 0, 0, 1})
+	_, err := c.Recv()
+	assert.Error(t, err)
+}
+
+func TestFile(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), ChunkSize/4)
+	var buf bytes.Buffer
+	c := &Conn{w: bufio.NewWriter(&buf), r: bufio.NewReader(&buf)}
+	require.NoError(t, c.SendFile(data))
+	got, err := c.RecvFile()
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	require.NoError(t, c.SendFile(nil))
+	got, err = c.RecvFile()
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+// tcpPipe returns both ends of a TCP connection, which unlike
+// net.Pipe buffers the versions both sides send before reading.
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	a, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	b, err := l.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+func TestNegotiate(t *testing.T) {
+	type result struct {
+		c   *Conn
+		err error
+	}
+	a, b := tcpPipe(t)
+	done := make(chan result)
+	go func() {
+		c, err := Negotiate(a)
+		done <- result{c, err}
+	}()
+	cb, err := Negotiate(b)
+	require.NoError(t, err)
+	ra := <-done
+	require.NoError(t, ra.err)
+
+	go func() {
+		done <- result{err: ra.c.Send(&JobBegin{JobID: 7})}
+	}()
+	m, err := cb.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, &JobBegin{JobID: 7}, m)
+	require.NoError(t, (<-done).err)
+
+	// A newer peer settles on our version
+	a, b = tcpPipe(t)
+	go func() {
+		c, err := Negotiate(a)
+		done <- result{c, err}
+	}()
+	peer := &Conn{r: bufio.NewReader(b), w: bufio.NewWriter(b)}
+	require.NoError(t, peer.sendVersion(ProtocolVersion+10))
+	v, err := peer.readVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(ProtocolVersion), v)
+	v, err = peer.readVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(ProtocolVersion), v)
+	require.NoError(t, peer.sendVersion(ProtocolVersion))
+	require.NoError(t, (<-done).err)
+
+	// An older peer is refused
+	a, b = tcpPipe(t)
+	go func() {
+		c, err := Negotiate(a)
+		done <- result{c, err}
+		a.Close()
+	}()
+	peer = &Conn{r: bufio.NewReader(b), w: bufio.NewWriter(b)}
+	_, err = peer.readVersion()
+	require.NoError(t, err)
+	require.NoError(t, peer.sendVersion(ProtocolVersion-1))
+	assert.Error(t, (<-done).err)
+}
+
+func TestCommand(t *testing.T) {
+	job := CompileFile{
+		Lang:        LangCXX,
+		RemoteFlags: []string{"-O2", "-g"},
+		RestFlags:   []string{"-Wall"},
+	}
+	cmd, err := job.Command("out.o")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c++", "-O2", "-g", "-Wall", "-c", "-x", "c++-cpp-output", "-o", "out.o", "-"}, cmd)
+
+	job.Lang = LangC
+	cmd, err = job.Command("out.o")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cc", "-O2", "-g", "-Wall", "-c", "-x", "cpp-output", "-o", "out.o", "-"}, cmd)
+
+	job.Lang = 7
+	_, err = job.Command("out.o")
+	assert.Error(t, err)
+}
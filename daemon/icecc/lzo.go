@@ -0,0 +1,223 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icecc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Icecream compresses file chunks with LZO1X. We decompress whatever
+// a client sends, but only ever compress by emitting the input as a
+// single literal run, which every LZO1X decoder accepts; the objects
+// we return are small next to the cost of the compilation.
+
+var errLZOCorrupt = errors.New("corrupt LZO1X data")
+
+// lzoEOF is the end-of-stream marker: an M4 match with distance 0
+var lzoEOF = []byte{0x11, 0, 0}
+
+// lzoLiteral encodes `src` as LZO1X data which decompresses to it
+func lzoLiteral(src []byte) []byte {
+	n := len(src)
+	out := make([]byte, 0, n+n/255+8)
+	switch {
+	case n == 0:
+	case n <= 238:
+		out = append(out, byte(17+n))
+	default:
+		// A zero instruction, then the length beyond 18 in
+		// base 255, with zero bytes for each whole 255
+		out = append(out, 0)
+		ext := n - 18
+		for ext > 255 {
+			out = append(out, 0)
+			ext -= 255
+		}
+		out = append(out, byte(ext))
+	}
+	out = append(out, src...)
+	return append(out, lzoEOF...)
+}
+
+// lzoDecompress decompresses LZO1X data which decompresses to
+// exactly `size` bytes.
+func lzoDecompress(src []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	ip := 0
+	next := func() (int, error) {
+		if ip >= len(src) {
+			return 0, errLZOCorrupt
+		}
+		ip++
+		return int(src[ip-1]), nil
+	}
+	// extend reads the extended length which follows an
+	// instruction whose length field is zero
+	extend := func(base int) (int, error) {
+		n := 0
+		for {
+			b, err := next()
+			if err != nil {
+				return 0, err
+			}
+			if b != 0 {
+				return n + base + b, nil
+			}
+			n += 255
+			if n > size {
+				return 0, errLZOCorrupt
+			}
+		}
+	}
+	literals := func(n int) error {
+		if ip+n > len(src) || len(out)+n > size {
+			return errLZOCorrupt
+		}
+		out = append(out, src[ip:ip+n]...)
+		ip += n
+		return nil
+	}
+	copyMatch := func(dist, n int) error {
+		pos := len(out) - dist
+		if pos < 0 || len(out)+n > size {
+			return errLZOCorrupt
+		}
+		// Matches may overlap their own output, so copy
+		// byte by byte
+		for i := 0; i < n; i++ {
+			out = append(out, out[pos+i])
+		}
+		return nil
+	}
+	le16 := func() (int, error) {
+		if ip+2 > len(src) {
+			return 0, errLZOCorrupt
+		}
+		ip += 2
+		return int(binary.LittleEndian.Uint16(src[ip-2:])), nil
+	}
+
+	// The decoder is a state machine: after a literal run, a
+	// short instruction is a 3-byte match; after a match, the low
+	// bits of its last distance byte give the number of literals
+	// (0-3) which follow it.
+	const (
+		stateLiteral = iota
+		stateFirstMatch
+		stateMatch
+	)
+	var t int
+	var err error
+	state := stateLiteral
+	if len(src) > 0 && src[0] > 17 {
+		ip++
+		t = int(src[0]) - 17
+		if err := literals(t); err != nil {
+			return nil, err
+		}
+		if t < 4 {
+			state = stateMatch
+		} else {
+			state = stateFirstMatch
+		}
+	}
+	for {
+		if t, err = next(); err != nil {
+			return nil, err
+		}
+		if state == stateLiteral && t < 16 {
+			if t == 0 {
+				if t, err = extend(15); err != nil {
+					return nil, err
+				}
+			}
+			if err := literals(t + 3); err != nil {
+				return nil, err
+			}
+			state = stateFirstMatch
+			continue
+		}
+		var dist, length int
+		switch {
+		case t >= 64:
+			b, err := next()
+			if err != nil {
+				return nil, err
+			}
+			dist = 1 + ((t >> 2) & 7) + b<<3
+			length = (t >> 5) + 1
+		case t >= 32:
+			length = t & 31
+			if length == 0 {
+				if length, err = extend(31); err != nil {
+					return nil, err
+				}
+			}
+			length += 2
+			d, err := le16()
+			if err != nil {
+				return nil, err
+			}
+			dist = 1 + d>>2
+		case t >= 16:
+			length = t & 7
+			if length == 0 {
+				if length, err = extend(7); err != nil {
+					return nil, err
+				}
+			}
+			length += 2
+			d, err := le16()
+			if err != nil {
+				return nil, err
+			}
+			dist = (t&8)<<11 + d>>2
+			if dist == 0 {
+				if len(out) != size || ip != len(src) {
+					return nil, errLZOCorrupt
+				}
+				return out, nil
+			}
+			dist += 0x4000
+		default:
+			b, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if state == stateFirstMatch {
+				dist = 1 + 0x800 + t>>2 + b<<2
+				length = 3
+			} else {
+				dist = 1 + t>>2 + b<<2
+				length = 2
+			}
+		}
+		if err := copyMatch(dist, length); err != nil {
+			return nil, err
+		}
+		// The literals following a match are counted by the
+		// low bits of the byte before the one we last read
+		n := int(src[ip-2]) & 3
+		if n == 0 {
+			state = stateLiteral
+			continue
+		}
+		if err := literals(n); err != nil {
+			return nil, err
+		}
+		state = stateMatch
+	}
+}
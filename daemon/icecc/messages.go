@@ -0,0 +1,385 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icecc
+
+import "fmt"
+
+// MsgType identifies an icecream message
+type MsgType uint32
+
+const (
+	MsgUnknown MsgType = 'A' + iota
+	MsgPing
+	MsgEnd
+	MsgTimeout
+	MsgGetNativeEnv
+	MsgNativeEnv
+	MsgGetCS
+	MsgUseCS
+	MsgCompileFile
+	MsgFileChunk
+	MsgCompileResult
+	MsgJobBegin
+	MsgJobDone
+	MsgJobLocalBegin
+	MsgJobLocalDone
+	MsgLogin
+	MsgStats
+	MsgMonLogin
+	MsgMonGetCS
+	MsgMonJobBegin
+	MsgMonJobDone
+	MsgMonLocalJobBegin
+	MsgMonStats
+	MsgTransferEnv
+	MsgText
+	MsgStatusText
+	MsgGetInternals
+	MsgConfCS
+)
+
+// Msg is a message which may be sent over a Conn
+type Msg interface {
+	Type() MsgType
+	encode(e *encoder)
+	decode(d *decoder) error
+}
+
+// Language is the source language of a CompileFile job
+type Language uint32
+
+const (
+	LangC Language = iota
+	LangCXX
+	LangObjC
+)
+
+// PreprocessedLang returns the `-x` argument naming
+// already-preprocessed source in language `l`.
+func (l Language) PreprocessedLang() (string, error) {
+	switch l {
+	case LangC:
+		return "cpp-output", nil
+	case LangCXX:
+		return "c++-cpp-output", nil
+	case LangObjC:
+		return "objective-c-cpp-output", nil
+	}
+	return "", fmt.Errorf("unknown language %d", l)
+}
+
+type Ping struct{}
+
+func (*Ping) Type() MsgType         { return MsgPing }
+func (*Ping) encode(*encoder)       {}
+func (*Ping) decode(*decoder) error { return nil }
+
+type End struct{}
+
+func (*End) Type() MsgType         { return MsgEnd }
+func (*End) encode(*encoder)       {}
+func (*End) decode(*decoder) error { return nil }
+
+// Login registers a compile server with the scheduler
+type Login struct {
+	Port           uint32
+	MaxKids        uint32
+	Envs           []Env
+	NodeName       string
+	HostPlatform   string
+	ChrootPossible bool
+}
+
+// Env is a compiler environment installed on a node, identified by
+// its target platform and version hash
+type Env struct {
+	Platform string
+	Version  string
+}
+
+func (*Login) Type() MsgType { return MsgLogin }
+func (m *Login) encode(e *encoder) {
+	e.u32(m.Port)
+	e.u32(m.MaxKids)
+	e.u32(uint32(len(m.Envs)))
+	for _, env := range m.Envs {
+		e.str(env.Platform)
+		e.str(env.Version)
+	}
+	e.str(m.NodeName)
+	e.str(m.HostPlatform)
+	e.bool(m.ChrootPossible)
+}
+func (m *Login) decode(d *decoder) error {
+	m.Port = d.u32()
+	m.MaxKids = d.u32()
+	n := d.u32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		m.Envs = append(m.Envs, Env{Platform: d.str(), Version: d.str()})
+	}
+	m.NodeName = d.str()
+	m.HostPlatform = d.str()
+	m.ChrootPossible = d.bool()
+	return d.err
+}
+
+// Stats reports a node's load to the scheduler. Load is in
+// thousandths of the node's capacity.
+type Stats struct {
+	Load      uint32
+	LoadAvg1  uint32
+	LoadAvg5  uint32
+	LoadAvg10 uint32
+	FreeMem   uint32
+}
+
+func (*Stats) Type() MsgType { return MsgStats }
+func (m *Stats) encode(e *encoder) {
+	e.u32(m.Load)
+	e.u32(m.LoadAvg1)
+	e.u32(m.LoadAvg5)
+	e.u32(m.LoadAvg10)
+	e.u32(m.FreeMem)
+}
+func (m *Stats) decode(d *decoder) error {
+	m.Load = d.u32()
+	m.LoadAvg1 = d.u32()
+	m.LoadAvg5 = d.u32()
+	m.LoadAvg10 = d.u32()
+	m.FreeMem = d.u32()
+	return d.err
+}
+
+// ConfCS is sent by the scheduler in reply to a Login
+type ConfCS struct {
+	MaxSchedulerPong uint32
+	MaxSchedulerPing uint32
+	BenchSource      string
+}
+
+func (*ConfCS) Type() MsgType { return MsgConfCS }
+func (m *ConfCS) encode(e *encoder) {
+	e.u32(m.MaxSchedulerPong)
+	e.u32(m.MaxSchedulerPing)
+	e.str(m.BenchSource)
+}
+func (m *ConfCS) decode(d *decoder) error {
+	m.MaxSchedulerPong = d.u32()
+	m.MaxSchedulerPing = d.u32()
+	m.BenchSource = d.str()
+	return d.err
+}
+
+// CompileFile starts a job on a compile server. The preprocessed
+// source follows as FileChunks, terminated by an End.
+type CompileFile struct {
+	Lang        Language
+	JobID       uint32
+	RemoteFlags []string
+	RestFlags   []string
+	EnvVersion  string
+	Target      string
+}
+
+// Command returns the compiler command line to run job `m`, reading
+// the preprocessed source from stdin and writing the object to
+// `output`. Clients strip `-c` and the input and output paths from
+// the flags they send.
+func (m *CompileFile) Command(output string) ([]string, error) {
+	lang, err := m.Lang.PreprocessedLang()
+	if err != nil {
+		return nil, err
+	}
+	compiler := "cc"
+	if m.Lang == LangCXX {
+		compiler = "c++"
+	}
+	cmd := []string{compiler}
+	cmd = append(cmd, m.RemoteFlags...)
+	cmd = append(cmd, m.RestFlags...)
+	return append(cmd, "-c", "-x", lang, "-o", output, "-"), nil
+}
+
+func (*CompileFile) Type() MsgType { return MsgCompileFile }
+func (m *CompileFile) encode(e *encoder) {
+	e.u32(uint32(m.Lang))
+	e.u32(m.JobID)
+	e.strs(m.RemoteFlags)
+	e.strs(m.RestFlags)
+	e.str(m.EnvVersion)
+	e.str(m.Target)
+}
+func (m *CompileFile) decode(d *decoder) error {
+	m.Lang = Language(d.u32())
+	m.JobID = d.u32()
+	m.RemoteFlags = d.strs()
+	m.RestFlags = d.strs()
+	m.EnvVersion = d.str()
+	m.Target = d.str()
+	return d.err
+}
+
+// FileChunk carries part of a file: source, object, or a compiler
+// environment tarball.
+type FileChunk struct {
+	Data []byte
+}
+
+func (*FileChunk) Type() MsgType { return MsgFileChunk }
+func (m *FileChunk) encode(e *encoder) {
+	e.compressed(m.Data)
+}
+func (m *FileChunk) decode(d *decoder) error {
+	m.Data = d.compressed()
+	return d.err
+}
+
+// CompileResult reports the outcome of a job. On success, the object
+// file follows as FileChunks, terminated by an End.
+type CompileResult struct {
+	Stderr      string
+	Stdout      string
+	Status      uint32
+	OutOfMemory bool
+}
+
+func (*CompileResult) Type() MsgType { return MsgCompileResult }
+func (m *CompileResult) encode(e *encoder) {
+	e.str(m.Stderr)
+	e.str(m.Stdout)
+	e.u32(m.Status)
+	e.bool(m.OutOfMemory)
+}
+func (m *CompileResult) decode(d *decoder) error {
+	m.Stderr = d.str()
+	m.Stdout = d.str()
+	m.Status = d.u32()
+	m.OutOfMemory = d.bool()
+	return d.err
+}
+
+// JobBegin tells the scheduler that a compile server has started a job
+type JobBegin struct {
+	JobID     uint32
+	StartTime uint32
+}
+
+func (*JobBegin) Type() MsgType { return MsgJobBegin }
+func (m *JobBegin) encode(e *encoder) {
+	e.u32(m.JobID)
+	e.u32(m.StartTime)
+}
+func (m *JobBegin) decode(d *decoder) error {
+	m.JobID = d.u32()
+	m.StartTime = d.u32()
+	return d.err
+}
+
+// JobDone tells the scheduler that a compile server has finished a job
+type JobDone struct {
+	JobID           uint32
+	ExitCode        uint32
+	RealMsec        uint32
+	UserMsec        uint32
+	SysMsec         uint32
+	PageFaults      uint32
+	InCompressed    uint32
+	InUncompressed  uint32
+	OutCompressed   uint32
+	OutUncompressed uint32
+	Flags           uint32
+}
+
+func (*JobDone) Type() MsgType { return MsgJobDone }
+func (m *JobDone) encode(e *encoder) {
+	for _, v := range m.fields() {
+		e.u32(*v)
+	}
+}
+func (m *JobDone) decode(d *decoder) error {
+	for _, v := range m.fields() {
+		*v = d.u32()
+	}
+	return d.err
+}
+func (m *JobDone) fields() []*uint32 {
+	return []*uint32{
+		&m.JobID, &m.ExitCode, &m.RealMsec, &m.UserMsec, &m.SysMsec,
+		&m.PageFaults, &m.InCompressed, &m.InUncompressed,
+		&m.OutCompressed, &m.OutUncompressed, &m.Flags,
+	}
+}
+
+// TransferEnv precedes a compiler environment tarball, sent as
+// FileChunks and terminated by an End.
+type TransferEnv struct {
+	Name   string
+	Target string
+}
+
+func (*TransferEnv) Type() MsgType { return MsgTransferEnv }
+func (m *TransferEnv) encode(e *encoder) {
+	e.str(m.Name)
+	e.str(m.Target)
+}
+func (m *TransferEnv) decode(d *decoder) error {
+	m.Name = d.str()
+	m.Target = d.str()
+	return d.err
+}
+
+// Unknown holds a message of a type this package does not decode
+type Unknown struct {
+	MsgType MsgType
+	Body    []byte
+}
+
+func (m *Unknown) Type() MsgType { return m.MsgType }
+func (m *Unknown) encode(e *encoder) {
+	e.buf = append(e.buf, m.Body...)
+}
+func (m *Unknown) decode(d *decoder) error {
+	m.Body = append([]byte(nil), d.buf[d.pos:]...)
+	d.pos = len(d.buf)
+	return nil
+}
+
+func newMsg(t MsgType) Msg {
+	switch t {
+	case MsgPing:
+		return &Ping{}
+	case MsgEnd:
+		return &End{}
+	case MsgLogin:
+		return &Login{}
+	case MsgStats:
+		return &Stats{}
+	case MsgConfCS:
+		return &ConfCS{}
+	case MsgCompileFile:
+		return &CompileFile{}
+	case MsgFileChunk:
+		return &FileChunk{}
+	case MsgCompileResult:
+		return &CompileResult{}
+	case MsgJobBegin:
+		return &JobBegin{}
+	case MsgJobDone:
+		return &JobDone{}
+	case MsgTransferEnv:
+		return &TransferEnv{}
+	}
+	return &Unknown{MsgType: t}
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import "time"
+
+// Packing configures how the daemon packs invocations of the same
+// function that arrive close together into a single Lambda request.
+// Packing spreads the overhead of each request, and of cold starts,
+// across many small jobs, at the cost of running them on one
+// instance.
+type Packing struct {
+	// The most jobs to pack into one request. Packing is off
+	// unless this is at least 2.
+	MaxJobs int
+	// How long to hold a job waiting for others to join it
+	// (default: DefaultPackWindow)
+	Window time.Duration
+	// How many of a pack's jobs the function runs at once; zero
+	// or one runs them one after another
+	Parallelism int
+}
+
+const DefaultPackWindow = 10 * time.Millisecond
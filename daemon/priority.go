@@ -0,0 +1,101 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import "fmt"
+
+// Priority is the scheduling class of an invocation. When the daemon
+// is at its concurrency limit, queued invocations are started in
+// priority order, and first-come first-served within a class.
+type Priority int
+
+const (
+	// Interactive invocations are the ones someone is waiting
+	// on, such as the compilations in an incremental build. This
+	// is the default.
+	PriorityInteractive Priority = iota
+	// Batch invocations are throughput-oriented background work,
+	// such as `llama xargs`.
+	PriorityBatch
+
+	NumPriorities = int(PriorityBatch) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBatch:
+		return "batch"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+func ParsePriority(s string) (Priority, error) {
+	for p := Priority(0); int(p) < NumPriorities; p++ {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %q: expected interactive or batch", s)
+}
+
+// Set implements flag.Value
+func (p *Priority) Set(s string) error {
+	v, err := ParsePriority(s)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// Schedule configures how many invocations the daemon runs at once.
+// A zero limit is unlimited.
+type Schedule struct {
+	// The most invocations to run at once across all classes
+	Concurrency int64 `json:"concurrency,omitempty"`
+	// The most invocations to run at once from each class.
+	// Limiting Batch below Concurrency reserves the difference
+	// for interactive work.
+	Interactive int64 `json:"interactive,omitempty"`
+	Batch       int64 `json:"batch,omitempty"`
+
+	// By default, the daemon treats Concurrency as a ceiling, and
+	// finds the concurrency Lambda will actually give it by
+	// ramping up while invocations succeed and backing off when
+	// they are throttled. Fixed disables that, and always allows
+	// Concurrency invocations. An unlimited Concurrency is never
+	// tuned.
+	Fixed bool `json:"fixed,omitempty"`
+}
+
+// DefaultSchedule stays within Lambda's default account concurrency
+// limit of 1000, and always leaves some of it for interactive work.
+var DefaultSchedule = Schedule{
+	Concurrency: 1000,
+	Batch:       800,
+}
+
+// Limit returns the concurrency limit for class `p`
+func (s *Schedule) Limit(p Priority) int64 {
+	switch p {
+	case PriorityInteractive:
+		return s.Interactive
+	case PriorityBatch:
+		return s.Batch
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reapi holds the parts of the Bazel Remote Execution API
+// (REAPI) that the daemon serves, and helpers shared by llama's REAPI
+// and HTTP cache frontends. The messages and service stubs are
+// generated from remote_execution.proto and semver.proto; run "go
+// generate" after changing them, with protoc, protoc-gen-go and
+// protoc-gen-go-grpc on your PATH, and the googleapis protos on
+// protoc's include path.
+package reapi
+
+//go:generate protoc -I../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative daemon/reapi/semver.proto daemon/reapi/remote_execution.proto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// CASKey returns the store key under which we keep the blob with
+// SHA-256 `hash`
+func CASKey(hash string) string {
+	return path.Join("bazel", "cas", hash)
+}
+
+// ACKey returns the store key under which we keep the ActionResult
+// for the action with SHA-256 `hash`
+func ACKey(hash string) string {
+	return path.Join("bazel", "ac", hash)
+}
+
+// ValidHash reports whether `hash` is a SHA-256 hash, in lower-case
+// hex
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 || strings.ToLower(hash) != hash {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// DigestOf returns the digest of `data`
+func DigestOf(data []byte) *Digest {
+	sum := sha256.Sum256(data)
+	return &Digest{Hash: hex.EncodeToString(sum[:]), SizeBytes: int64(len(data))}
+}
+
+// CheckDigest returns an error unless `d` is a well-formed SHA-256
+// digest
+func CheckDigest(d *Digest) error {
+	if d == nil {
+		return errors.New("missing digest")
+	}
+	if !ValidHash(d.Hash) {
+		return fmt.Errorf("bad SHA-256 hash %q", d.Hash)
+	}
+	if d.SizeBytes < 0 {
+		return fmt.Errorf("bad size %d", d.SizeBytes)
+	}
+	return nil
+}
+
+// Matches reports whether `data` is the blob with digest `d`
+func (d *Digest) Matches(data []byte) bool {
+	got := DigestOf(data)
+	return got.Hash == d.Hash && got.SizeBytes == d.SizeBytes
+}
+
+// ErrCompressed is returned for ByteStream resources naming
+// compressed blobs, which we don't support
+var ErrCompressed = errors.New("compressed blobs are not supported")
+
+// ParseReadResource parses the name of a ByteStream resource to read,
+// "[INSTANCE/]blobs/HASH/SIZE", returning the blob's digest
+func ParseReadResource(name string) (*Digest, error) {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		if p == "compressed-blobs" {
+			return nil, ErrCompressed
+		}
+		if p == "blobs" && i+3 == len(parts) {
+			return parseDigest(parts[i+1], parts[i+2])
+		}
+	}
+	return nil, fmt.Errorf("bad resource name %q", name)
+}
+
+// ParseWriteResource parses the name of a ByteStream resource to
+// write, "[INSTANCE/]uploads/UUID/blobs/HASH/SIZE[/METADATA]",
+// returning the blob's digest
+func ParseWriteResource(name string) (*Digest, error) {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		if p != "uploads" || i+4 >= len(parts) {
+			continue
+		}
+		switch parts[i+2] {
+		case "blobs":
+			return parseDigest(parts[i+3], parts[i+4])
+		case "compressed-blobs":
+			return nil, ErrCompressed
+		}
+	}
+	return nil, fmt.Errorf("bad resource name %q", name)
+}
+
+func parseDigest(hash, size string) (*Digest, error) {
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad size %q", size)
+	}
+	d := &Digest{Hash: hash, SizeBytes: n}
+	if err := CheckDigest(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
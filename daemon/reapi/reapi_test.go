@@ -0,0 +1,75 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reapi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceNames(t *testing.T) {
+	hash := DigestOf([]byte("x")).Hash
+	reads := map[string]bool{
+		"blobs/" + hash + "/1":                  true,
+		"main/blobs/" + hash + "/1":             true,
+		"a/b/blobs/" + hash + "/12":             true,
+		"blobs/" + hash:                         false,
+		"blobs/" + hash + "/-1":                 false,
+		"blobs/nothex/1":                        false,
+		"blobs/" + strings.ToUpper(hash) + "/1": false,
+		"uploads/u/blobs/" + hash + "/1/extra":  false,
+	}
+	for name, ok := range reads {
+		_, err := ParseReadResource(name)
+		assert.Equal(t, ok, err == nil, name)
+	}
+	d, err := ParseReadResource("main/blobs/" + hash + "/12")
+	assert.NoError(t, err)
+	assert.Equal(t, &Digest{Hash: hash, SizeBytes: 12}, d)
+
+	writes := map[string]bool{
+		"uploads/u/blobs/" + hash + "/1":          true,
+		"main/uploads/u/blobs/" + hash + "/1":     true,
+		"uploads/u/blobs/" + hash + "/1/metadata": true,
+		"uploads/blobs/" + hash + "/1":            false,
+		"blobs/" + hash + "/1":                    false,
+	}
+	for name, ok := range writes {
+		_, err := ParseWriteResource(name)
+		assert.Equal(t, ok, err == nil, name)
+	}
+
+	_, err = ParseReadResource("compressed-blobs/zstd/" + hash + "/1")
+	assert.Equal(t, ErrCompressed, err)
+	_, err = ParseWriteResource("uploads/u/compressed-blobs/zstd/" + hash + "/1")
+	assert.Equal(t, ErrCompressed, err)
+}
+
+func TestDigest(t *testing.T) {
+	d := DigestOf([]byte("hello"))
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", d.Hash)
+	assert.Equal(t, int64(5), d.SizeBytes)
+	assert.True(t, d.Matches([]byte("hello")))
+	assert.False(t, d.Matches([]byte("hellO")))
+	assert.False(t, (&Digest{Hash: d.Hash, SizeBytes: 4}).Matches([]byte("hello")))
+	assert.NoError(t, CheckDigest(d))
+	assert.Error(t, CheckDigest(nil))
+	assert.Error(t, CheckDigest(&Digest{Hash: "abc"}))
+
+	assert.Equal(t, "bazel/cas/"+d.Hash, CASKey(d.Hash))
+	assert.Equal(t, "bazel/ac/"+d.Hash, ACKey(d.Hash))
+}
@@ -0,0 +1,640 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package reapi
+
+import (
+	context "context"
+	longrunning "google.golang.org/genproto/googleapis/longrunning"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// ExecutionClient is the client API for Execution service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ExecutionClient interface {
+	// Execute an action remotely. The server streams
+	// [Operations][google.longrunning.Operation] describing the
+	// action's progress, the last of which carries an
+	// [ExecuteResponse][build.bazel.remote.execution.v2.ExecuteResponse].
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (Execution_ExecuteClient, error)
+	// Wait for an execution operation to complete. When the client initially
+	// makes the request, the server immediately responds with the current status
+	// of the execution.
+	WaitExecution(ctx context.Context, in *WaitExecutionRequest, opts ...grpc.CallOption) (Execution_WaitExecutionClient, error)
+}
+
+type executionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExecutionClient(cc grpc.ClientConnInterface) ExecutionClient {
+	return &executionClient{cc}
+}
+
+func (c *executionClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (Execution_ExecuteClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Execution_serviceDesc.Streams[0], "/build.bazel.remote.execution.v2.Execution/Execute", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &executionExecuteClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Execution_ExecuteClient interface {
+	Recv() (*longrunning.Operation, error)
+	grpc.ClientStream
+}
+
+type executionExecuteClient struct {
+	grpc.ClientStream
+}
+
+func (x *executionExecuteClient) Recv() (*longrunning.Operation, error) {
+	m := new(longrunning.Operation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *executionClient) WaitExecution(ctx context.Context, in *WaitExecutionRequest, opts ...grpc.CallOption) (Execution_WaitExecutionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Execution_serviceDesc.Streams[1], "/build.bazel.remote.execution.v2.Execution/WaitExecution", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &executionWaitExecutionClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Execution_WaitExecutionClient interface {
+	Recv() (*longrunning.Operation, error)
+	grpc.ClientStream
+}
+
+type executionWaitExecutionClient struct {
+	grpc.ClientStream
+}
+
+func (x *executionWaitExecutionClient) Recv() (*longrunning.Operation, error) {
+	m := new(longrunning.Operation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExecutionServer is the server API for Execution service.
+// All implementations must embed UnimplementedExecutionServer
+// for forward compatibility
+type ExecutionServer interface {
+	// Execute an action remotely. The server streams
+	// [Operations][google.longrunning.Operation] describing the
+	// action's progress, the last of which carries an
+	// [ExecuteResponse][build.bazel.remote.execution.v2.ExecuteResponse].
+	Execute(*ExecuteRequest, Execution_ExecuteServer) error
+	// Wait for an execution operation to complete. When the client initially
+	// makes the request, the server immediately responds with the current status
+	// of the execution.
+	WaitExecution(*WaitExecutionRequest, Execution_WaitExecutionServer) error
+	mustEmbedUnimplementedExecutionServer()
+}
+
+// UnimplementedExecutionServer must be embedded to have forward compatible implementations.
+type UnimplementedExecutionServer struct {
+}
+
+func (UnimplementedExecutionServer) Execute(*ExecuteRequest, Execution_ExecuteServer) error {
+	return status.Errorf(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedExecutionServer) WaitExecution(*WaitExecutionRequest, Execution_WaitExecutionServer) error {
+	return status.Errorf(codes.Unimplemented, "method WaitExecution not implemented")
+}
+func (UnimplementedExecutionServer) mustEmbedUnimplementedExecutionServer() {}
+
+// UnsafeExecutionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ExecutionServer will
+// result in compilation errors.
+type UnsafeExecutionServer interface {
+	mustEmbedUnimplementedExecutionServer()
+}
+
+func RegisterExecutionServer(s grpc.ServiceRegistrar, srv ExecutionServer) {
+	s.RegisterService(&_Execution_serviceDesc, srv)
+}
+
+func _Execution_Execute_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExecutionServer).Execute(m, &executionExecuteServer{stream})
+}
+
+type Execution_ExecuteServer interface {
+	Send(*longrunning.Operation) error
+	grpc.ServerStream
+}
+
+type executionExecuteServer struct {
+	grpc.ServerStream
+}
+
+func (x *executionExecuteServer) Send(m *longrunning.Operation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Execution_WaitExecution_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WaitExecutionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExecutionServer).WaitExecution(m, &executionWaitExecutionServer{stream})
+}
+
+type Execution_WaitExecutionServer interface {
+	Send(*longrunning.Operation) error
+	grpc.ServerStream
+}
+
+type executionWaitExecutionServer struct {
+	grpc.ServerStream
+}
+
+func (x *executionWaitExecutionServer) Send(m *longrunning.Operation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Execution_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "build.bazel.remote.execution.v2.Execution",
+	HandlerType: (*ExecutionServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Execute",
+			Handler:       _Execution_Execute_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WaitExecution",
+			Handler:       _Execution_WaitExecution_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "daemon/reapi/remote_execution.proto",
+}
+
+// ActionCacheClient is the client API for ActionCache service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ActionCacheClient interface {
+	// Retrieve a cached execution result.
+	GetActionResult(ctx context.Context, in *GetActionResultRequest, opts ...grpc.CallOption) (*ActionResult, error)
+	// Upload a new execution result.
+	UpdateActionResult(ctx context.Context, in *UpdateActionResultRequest, opts ...grpc.CallOption) (*ActionResult, error)
+}
+
+type actionCacheClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewActionCacheClient(cc grpc.ClientConnInterface) ActionCacheClient {
+	return &actionCacheClient{cc}
+}
+
+func (c *actionCacheClient) GetActionResult(ctx context.Context, in *GetActionResultRequest, opts ...grpc.CallOption) (*ActionResult, error) {
+	out := new(ActionResult)
+	err := c.cc.Invoke(ctx, "/build.bazel.remote.execution.v2.ActionCache/GetActionResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *actionCacheClient) UpdateActionResult(ctx context.Context, in *UpdateActionResultRequest, opts ...grpc.CallOption) (*ActionResult, error) {
+	out := new(ActionResult)
+	err := c.cc.Invoke(ctx, "/build.bazel.remote.execution.v2.ActionCache/UpdateActionResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ActionCacheServer is the server API for ActionCache service.
+// All implementations must embed UnimplementedActionCacheServer
+// for forward compatibility
+type ActionCacheServer interface {
+	// Retrieve a cached execution result.
+	GetActionResult(context.Context, *GetActionResultRequest) (*ActionResult, error)
+	// Upload a new execution result.
+	UpdateActionResult(context.Context, *UpdateActionResultRequest) (*ActionResult, error)
+	mustEmbedUnimplementedActionCacheServer()
+}
+
+// UnimplementedActionCacheServer must be embedded to have forward compatible implementations.
+type UnimplementedActionCacheServer struct {
+}
+
+func (UnimplementedActionCacheServer) GetActionResult(context.Context, *GetActionResultRequest) (*ActionResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetActionResult not implemented")
+}
+func (UnimplementedActionCacheServer) UpdateActionResult(context.Context, *UpdateActionResultRequest) (*ActionResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateActionResult not implemented")
+}
+func (UnimplementedActionCacheServer) mustEmbedUnimplementedActionCacheServer() {}
+
+// UnsafeActionCacheServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ActionCacheServer will
+// result in compilation errors.
+type UnsafeActionCacheServer interface {
+	mustEmbedUnimplementedActionCacheServer()
+}
+
+func RegisterActionCacheServer(s grpc.ServiceRegistrar, srv ActionCacheServer) {
+	s.RegisterService(&_ActionCache_serviceDesc, srv)
+}
+
+func _ActionCache_GetActionResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetActionResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ActionCacheServer).GetActionResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/build.bazel.remote.execution.v2.ActionCache/GetActionResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ActionCacheServer).GetActionResult(ctx, req.(*GetActionResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ActionCache_UpdateActionResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateActionResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ActionCacheServer).UpdateActionResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/build.bazel.remote.execution.v2.ActionCache/UpdateActionResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ActionCacheServer).UpdateActionResult(ctx, req.(*UpdateActionResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ActionCache_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "build.bazel.remote.execution.v2.ActionCache",
+	HandlerType: (*ActionCacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetActionResult",
+			Handler:    _ActionCache_GetActionResult_Handler,
+		},
+		{
+			MethodName: "UpdateActionResult",
+			Handler:    _ActionCache_UpdateActionResult_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "daemon/reapi/remote_execution.proto",
+}
+
+// ContentAddressableStorageClient is the client API for ContentAddressableStorage service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ContentAddressableStorageClient interface {
+	// Determine if blobs are present in the CAS.
+	FindMissingBlobs(ctx context.Context, in *FindMissingBlobsRequest, opts ...grpc.CallOption) (*FindMissingBlobsResponse, error)
+	// Upload many blobs at once.
+	BatchUpdateBlobs(ctx context.Context, in *BatchUpdateBlobsRequest, opts ...grpc.CallOption) (*BatchUpdateBlobsResponse, error)
+	// Download many blobs at once.
+	BatchReadBlobs(ctx context.Context, in *BatchReadBlobsRequest, opts ...grpc.CallOption) (*BatchReadBlobsResponse, error)
+	// Fetch the entire directory tree rooted at a node.
+	GetTree(ctx context.Context, in *GetTreeRequest, opts ...grpc.CallOption) (ContentAddressableStorage_GetTreeClient, error)
+}
+
+type contentAddressableStorageClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewContentAddressableStorageClient(cc grpc.ClientConnInterface) ContentAddressableStorageClient {
+	return &contentAddressableStorageClient{cc}
+}
+
+func (c *contentAddressableStorageClient) FindMissingBlobs(ctx context.Context, in *FindMissingBlobsRequest, opts ...grpc.CallOption) (*FindMissingBlobsResponse, error) {
+	out := new(FindMissingBlobsResponse)
+	err := c.cc.Invoke(ctx, "/build.bazel.remote.execution.v2.ContentAddressableStorage/FindMissingBlobs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contentAddressableStorageClient) BatchUpdateBlobs(ctx context.Context, in *BatchUpdateBlobsRequest, opts ...grpc.CallOption) (*BatchUpdateBlobsResponse, error) {
+	out := new(BatchUpdateBlobsResponse)
+	err := c.cc.Invoke(ctx, "/build.bazel.remote.execution.v2.ContentAddressableStorage/BatchUpdateBlobs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contentAddressableStorageClient) BatchReadBlobs(ctx context.Context, in *BatchReadBlobsRequest, opts ...grpc.CallOption) (*BatchReadBlobsResponse, error) {
+	out := new(BatchReadBlobsResponse)
+	err := c.cc.Invoke(ctx, "/build.bazel.remote.execution.v2.ContentAddressableStorage/BatchReadBlobs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contentAddressableStorageClient) GetTree(ctx context.Context, in *GetTreeRequest, opts ...grpc.CallOption) (ContentAddressableStorage_GetTreeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ContentAddressableStorage_serviceDesc.Streams[0], "/build.bazel.remote.execution.v2.ContentAddressableStorage/GetTree", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &contentAddressableStorageGetTreeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ContentAddressableStorage_GetTreeClient interface {
+	Recv() (*GetTreeResponse, error)
+	grpc.ClientStream
+}
+
+type contentAddressableStorageGetTreeClient struct {
+	grpc.ClientStream
+}
+
+func (x *contentAddressableStorageGetTreeClient) Recv() (*GetTreeResponse, error) {
+	m := new(GetTreeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ContentAddressableStorageServer is the server API for ContentAddressableStorage service.
+// All implementations must embed UnimplementedContentAddressableStorageServer
+// for forward compatibility
+type ContentAddressableStorageServer interface {
+	// Determine if blobs are present in the CAS.
+	FindMissingBlobs(context.Context, *FindMissingBlobsRequest) (*FindMissingBlobsResponse, error)
+	// Upload many blobs at once.
+	BatchUpdateBlobs(context.Context, *BatchUpdateBlobsRequest) (*BatchUpdateBlobsResponse, error)
+	// Download many blobs at once.
+	BatchReadBlobs(context.Context, *BatchReadBlobsRequest) (*BatchReadBlobsResponse, error)
+	// Fetch the entire directory tree rooted at a node.
+	GetTree(*GetTreeRequest, ContentAddressableStorage_GetTreeServer) error
+	mustEmbedUnimplementedContentAddressableStorageServer()
+}
+
+// UnimplementedContentAddressableStorageServer must be embedded to have forward compatible implementations.
+type UnimplementedContentAddressableStorageServer struct {
+}
+
+func (UnimplementedContentAddressableStorageServer) FindMissingBlobs(context.Context, *FindMissingBlobsRequest) (*FindMissingBlobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindMissingBlobs not implemented")
+}
+func (UnimplementedContentAddressableStorageServer) BatchUpdateBlobs(context.Context, *BatchUpdateBlobsRequest) (*BatchUpdateBlobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchUpdateBlobs not implemented")
+}
+func (UnimplementedContentAddressableStorageServer) BatchReadBlobs(context.Context, *BatchReadBlobsRequest) (*BatchReadBlobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchReadBlobs not implemented")
+}
+func (UnimplementedContentAddressableStorageServer) GetTree(*GetTreeRequest, ContentAddressableStorage_GetTreeServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetTree not implemented")
+}
+func (UnimplementedContentAddressableStorageServer) mustEmbedUnimplementedContentAddressableStorageServer() {
+}
+
+// UnsafeContentAddressableStorageServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ContentAddressableStorageServer will
+// result in compilation errors.
+type UnsafeContentAddressableStorageServer interface {
+	mustEmbedUnimplementedContentAddressableStorageServer()
+}
+
+func RegisterContentAddressableStorageServer(s grpc.ServiceRegistrar, srv ContentAddressableStorageServer) {
+	s.RegisterService(&_ContentAddressableStorage_serviceDesc, srv)
+}
+
+func _ContentAddressableStorage_FindMissingBlobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindMissingBlobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContentAddressableStorageServer).FindMissingBlobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/build.bazel.remote.execution.v2.ContentAddressableStorage/FindMissingBlobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContentAddressableStorageServer).FindMissingBlobs(ctx, req.(*FindMissingBlobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContentAddressableStorage_BatchUpdateBlobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchUpdateBlobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContentAddressableStorageServer).BatchUpdateBlobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/build.bazel.remote.execution.v2.ContentAddressableStorage/BatchUpdateBlobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContentAddressableStorageServer).BatchUpdateBlobs(ctx, req.(*BatchUpdateBlobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContentAddressableStorage_BatchReadBlobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchReadBlobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContentAddressableStorageServer).BatchReadBlobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/build.bazel.remote.execution.v2.ContentAddressableStorage/BatchReadBlobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContentAddressableStorageServer).BatchReadBlobs(ctx, req.(*BatchReadBlobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContentAddressableStorage_GetTree_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetTreeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ContentAddressableStorageServer).GetTree(m, &contentAddressableStorageGetTreeServer{stream})
+}
+
+type ContentAddressableStorage_GetTreeServer interface {
+	Send(*GetTreeResponse) error
+	grpc.ServerStream
+}
+
+type contentAddressableStorageGetTreeServer struct {
+	grpc.ServerStream
+}
+
+func (x *contentAddressableStorageGetTreeServer) Send(m *GetTreeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _ContentAddressableStorage_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "build.bazel.remote.execution.v2.ContentAddressableStorage",
+	HandlerType: (*ContentAddressableStorageServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "FindMissingBlobs",
+			Handler:    _ContentAddressableStorage_FindMissingBlobs_Handler,
+		},
+		{
+			MethodName: "BatchUpdateBlobs",
+			Handler:    _ContentAddressableStorage_BatchUpdateBlobs_Handler,
+		},
+		{
+			MethodName: "BatchReadBlobs",
+			Handler:    _ContentAddressableStorage_BatchReadBlobs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetTree",
+			Handler:       _ContentAddressableStorage_GetTree_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "daemon/reapi/remote_execution.proto",
+}
+
+// CapabilitiesClient is the client API for Capabilities service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CapabilitiesClient interface {
+	// GetCapabilities returns the server capabilities configuration of the
+	// remote endpoint.
+	GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*ServerCapabilities, error)
+}
+
+type capabilitiesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCapabilitiesClient(cc grpc.ClientConnInterface) CapabilitiesClient {
+	return &capabilitiesClient{cc}
+}
+
+func (c *capabilitiesClient) GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*ServerCapabilities, error) {
+	out := new(ServerCapabilities)
+	err := c.cc.Invoke(ctx, "/build.bazel.remote.execution.v2.Capabilities/GetCapabilities", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CapabilitiesServer is the server API for Capabilities service.
+// All implementations must embed UnimplementedCapabilitiesServer
+// for forward compatibility
+type CapabilitiesServer interface {
+	// GetCapabilities returns the server capabilities configuration of the
+	// remote endpoint.
+	GetCapabilities(context.Context, *GetCapabilitiesRequest) (*ServerCapabilities, error)
+	mustEmbedUnimplementedCapabilitiesServer()
+}
+
+// UnimplementedCapabilitiesServer must be embedded to have forward compatible implementations.
+type UnimplementedCapabilitiesServer struct {
+}
+
+func (UnimplementedCapabilitiesServer) GetCapabilities(context.Context, *GetCapabilitiesRequest) (*ServerCapabilities, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCapabilities not implemented")
+}
+func (UnimplementedCapabilitiesServer) mustEmbedUnimplementedCapabilitiesServer() {}
+
+// UnsafeCapabilitiesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CapabilitiesServer will
+// result in compilation errors.
+type UnsafeCapabilitiesServer interface {
+	mustEmbedUnimplementedCapabilitiesServer()
+}
+
+func RegisterCapabilitiesServer(s grpc.ServiceRegistrar, srv CapabilitiesServer) {
+	s.RegisterService(&_Capabilities_serviceDesc, srv)
+}
+
+func _Capabilities_GetCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CapabilitiesServer).GetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/build.bazel.remote.execution.v2.Capabilities/GetCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CapabilitiesServer).GetCapabilities(ctx, req.(*GetCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Capabilities_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "build.bazel.remote.execution.v2.Capabilities",
+	HandlerType: (*CapabilitiesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCapabilities",
+			Handler:    _Capabilities_GetCapabilities_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "daemon/reapi/remote_execution.proto",
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"golang.org/x/sync/semaphore"
+)
+
+var ErrOverBudget = errors.New("over budget")
+
+type budget struct {
+	limits   daemon.Budget
+	pricing  daemon.Pricing
+	throttle *semaphore.Weighted
+}
+
+func newBudget(limits daemon.Budget, pricing daemon.Pricing) budget {
+	concurrency := limits.ThrottleConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return budget{
+		limits:   limits,
+		pricing:  pricing,
+		throttle: semaphore.NewWeighted(concurrency),
+	}
+}
+
+// collectUsage adds the store's usage since we last asked to our
+// statistics
+func (d *Daemon) collectUsage() {
+	var u protocol.UsageMetrics
+	d.store.FetchAWSUsage(&u)
+	atomic.AddUint64(&d.stats.Usage.S3_Write_Requests, u.S3_Write_Requests)
+	atomic.AddUint64(&d.stats.Usage.S3_Read_Requests, u.S3_Read_Requests)
+	atomic.AddUint64(&d.stats.Usage.S3_Xfer_In, u.S3_Xfer_In)
+	atomic.AddUint64(&d.stats.Usage.S3_Xfer_Out, u.S3_Xfer_Out)
+}
+
+// sessionCost returns the estimated cost of everything we've done
+// since the session started
+func (d *Daemon) sessionCost() daemon.Cost {
+	d.collectUsage()
+	u := protocol.UsageMetrics{
+		Lambda_Millis:     atomic.LoadUint64(&d.stats.Usage.Lambda_Millis),
+		Lambda_MB_Millis:  atomic.LoadUint64(&d.stats.Usage.Lambda_MB_Millis),
+		Lambda_Requests:   atomic.LoadUint64(&d.stats.Usage.Lambda_Requests),
+		S3_Write_Requests: atomic.LoadUint64(&d.stats.Usage.S3_Write_Requests),
+		S3_Read_Requests:  atomic.LoadUint64(&d.stats.Usage.S3_Read_Requests),
+		S3_Xfer_In:        atomic.LoadUint64(&d.stats.Usage.S3_Xfer_In),
+		S3_Xfer_Out:       atomic.LoadUint64(&d.stats.Usage.S3_Xfer_Out),
+	}
+	return d.budget.pricing.Cost(&u)
+}
+
+// admit checks the session's spending against our budget before we
+// invoke a function. Once we're over the throttling threshold, it
+// waits for one of a limited number of slots; over the maximum, it
+// refuses. On success, the caller must call the returned function
+// once the invocation is complete.
+func (d *Daemon) admit(ctx context.Context) (func(), error) {
+	limits := &d.budget.limits
+	if limits.MaxCost <= 0 && limits.ThrottleCost <= 0 {
+		return func() {}, nil
+	}
+	spent := d.sessionCost()
+	total := spent.Total()
+	if limits.MaxCost > 0 && total >= limits.MaxCost {
+		atomic.AddUint64(&d.stats.BudgetRefused, 1)
+		return nil, fmt.Errorf("%w: spent an estimated $%.2f of $%.2f",
+			ErrOverBudget, total, limits.MaxCost)
+	}
+	if limits.ThrottleCost > 0 && total >= limits.ThrottleCost {
+		atomic.AddUint64(&d.stats.BudgetThrottled, 1)
+		if err := d.budget.throttle.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+		return func() { d.budget.throttle.Release(1) }, nil
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingCost(t *testing.T) {
+	u := protocol.UsageMetrics{
+		Lambda_MB_Millis:  1024 * 1000 * 1000,
+		Lambda_Requests:   1000000,
+		S3_Write_Requests: 1000,
+		S3_Read_Requests:  1000,
+		S3_Xfer_Out:       1024 * 1024 * 1024,
+	}
+	cost := daemon.DefaultPricing.Cost(&u)
+	assert.InDelta(t, 0.0166667, cost.LambdaCompute, 1e-9)
+	assert.InDelta(t, 0.20, cost.LambdaRequests, 1e-9)
+	assert.InDelta(t, 0.005, cost.S3Writes, 1e-9)
+	assert.InDelta(t, 0.0004, cost.S3Reads, 1e-9)
+	assert.InDelta(t, 0.09, cost.S3XferOut, 1e-9)
+	assert.InDelta(t, 0.3120667, cost.Total(), 1e-9)
+
+	arm := daemon.PricingFor("arm64")
+	assert.Less(t, arm.LambdaGBSecond, daemon.DefaultPricing.LambdaGBSecond)
+}
+
+func TestAdmit(t *testing.T) {
+	d := &Daemon{
+		store: store.InMemory(),
+		budget: newBudget(daemon.Budget{
+			ThrottleCost: 1,
+			MaxCost:      2,
+		}, daemon.Pricing{LambdaRequest: 1}),
+	}
+	ctx := context.Background()
+
+	release, err := d.admit(ctx)
+	require.NoError(t, err)
+	release()
+
+	// Over the throttle threshold, only one invocation may run at
+	// a time
+	d.stats.Usage.Lambda_Requests = 1
+	release, err = d.admit(ctx)
+	require.NoError(t, err)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = d.admit(timeout)
+	assert.Error(t, err)
+	release()
+	release, err = d.admit(ctx)
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, uint64(3), d.stats.BudgetThrottled)
+
+	d.stats.Usage.Lambda_Requests = 2
+	_, err = d.admit(ctx)
+	assert.True(t, errors.Is(err, ErrOverBudget))
+	assert.Equal(t, uint64(1), d.stats.BudgetRefused)
+
+	unlimited := &Daemon{store: store.InMemory()}
+	unlimited.stats.Usage.Lambda_Requests = 1000
+	release, err = unlimited.admit(ctx)
+	require.NoError(t, err)
+	release()
+}
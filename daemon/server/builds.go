@@ -0,0 +1,168 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+)
+
+// buildTracker aggregates statistics for each running build session,
+// and writes a report when one ends
+type buildTracker struct {
+	sync.Mutex
+	// How long a build may go without invocations before we end
+	// it
+	idle time.Duration
+	// Where to write reports, if anywhere
+	dir    string
+	builds map[string]*buildSession
+	now    func() time.Time
+}
+
+type buildSession struct {
+	report daemon.BuildReport
+	last   time.Time
+}
+
+func newBuildTracker(idle time.Duration, dir string) *buildTracker {
+	return &buildTracker{
+		idle:   idle,
+		dir:    dir,
+		builds: make(map[string]*buildSession),
+		now:    time.Now,
+	}
+}
+
+// begin begins build `id`, if it isn't already running
+func (t *buildTracker) begin(id string) *buildSession {
+	t.Lock()
+	defer t.Unlock()
+	return t.beginLocked(id)
+}
+
+func (t *buildTracker) beginLocked(id string) *buildSession {
+	if b, ok := t.builds[id]; ok {
+		return b
+	}
+	now := t.now()
+	b := &buildSession{
+		report: daemon.BuildReport{
+			Build:      id,
+			Started:    now,
+			LocalCores: runtime.NumCPU(),
+		},
+		last: now,
+	}
+	t.builds[id] = b
+	return b
+}
+
+// record adds an invocation to build `id`, beginning it if need be
+func (t *buildTracker) record(id string, inv daemon.BuildInvocation, cacheable, failed bool) {
+	t.Lock()
+	defer t.Unlock()
+	b := t.beginLocked(id)
+	b.report.Record(inv, cacheable, failed)
+	b.last = t.now()
+}
+
+// end ends build `id`, writing and returning its report
+func (t *buildTracker) end(id string) (daemon.BuildReport, bool) {
+	t.Lock()
+	b, ok := t.builds[id]
+	if ok {
+		delete(t.builds, id)
+		b.report.Ended = t.now()
+	}
+	t.Unlock()
+	if !ok {
+		return daemon.BuildReport{}, false
+	}
+	t.write(&b.report)
+	return b.report, true
+}
+
+// expire ends every build which has been idle for longer than the
+// idle timeout, or every build if `all` is set
+func (t *buildTracker) expire(all bool) {
+	t.Lock()
+	var expired []string
+	for id, b := range t.builds {
+		if all || t.now().Sub(b.last) >= t.idle {
+			expired = append(expired, id)
+		}
+	}
+	t.Unlock()
+	for _, id := range expired {
+		t.end(id)
+	}
+}
+
+// run ends idle builds until `done` is closed. Without an idle
+// timeout, builds only end explicitly or when the daemon exits.
+func (t *buildTracker) run(done <-chan struct{}) {
+	if t.idle <= 0 {
+		return
+	}
+	ticker := time.NewTicker(t.idle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			t.expire(false)
+		}
+	}
+}
+
+// reportName returns the file name for a build's report
+func reportName(r *daemon.BuildReport) string {
+	id := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, r.Build)
+	return fmt.Sprintf("%s-%s.txt", r.Started.Format("20060102-150405"), id)
+}
+
+func (t *buildTracker) write(r *daemon.BuildReport) {
+	if t.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		log.Printf("writing build report: %s", err.Error())
+		return
+	}
+	var buf strings.Builder
+	r.WriteText(&buf)
+	path := filepath.Join(t.dir, reportName(r))
+	if err := ioutil.WriteFile(path, []byte(buf.String()), 0644); err != nil {
+		log.Printf("writing build report: %s", err.Error())
+		return
+	}
+	log.Printf("build %s ended after %d invocations; report in %s", r.Build, r.Invocations, path)
+}
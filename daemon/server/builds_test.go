@@ -0,0 +1,58 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama-builds")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	tr := newBuildTracker(time.Minute, dir)
+	tr.now = func() time.Time { return now }
+
+	tr.begin("a")
+	tr.record("b/c", daemon.BuildInvocation{Description: "foo.o", E2E: time.Second}, false, false)
+	now = now.Add(30 * time.Second)
+	tr.record("a", daemon.BuildInvocation{Description: "bar.o", E2E: time.Second}, false, false)
+
+	// b/c has been idle for a minute, but a has not
+	now = now.Add(30 * time.Second)
+	tr.expire(false)
+	assert.Contains(t, tr.builds, "a")
+	assert.NotContains(t, tr.builds, "b/c")
+	data, err := ioutil.ReadFile(filepath.Join(dir, "20201001-120000-b_c.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Build b/c:")
+
+	report, ok := tr.end("a")
+	require.True(t, ok)
+	assert.Equal(t, uint64(1), report.Invocations)
+	assert.Equal(t, time.Minute, report.Wall())
+	_, ok = tr.end("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/tracing"
+	"golang.org/x/crypto/blake2b"
+)
+
+// cacheKeyMaterial is hashed to produce the key for the result
+// cache. Since every input file has already been uploaded to the
+// content-addressed store, or is small enough to be inline, the spec
+// identifies the inputs by hash or by contents, and we identify the
+// toolchain by the function's code hash.
+type cacheKeyMaterial struct {
+	Function string
+	CodeHash string
+	Spec     protocol.InvocationSpec
+}
+
+// How long we trust a function's code hash before asking Lambda
+// again. Until then, results cached against code replaced by `llama
+// update-function` may still be served.
+const codeHashTTL = time.Minute
+
+type fetchedCodeHash struct {
+	fetched time.Time
+	hash    string
+}
+
+// codeHashCache remembers the code hash of each function, which
+// identifies its toolchain in result cache keys.
+type codeHashCache struct {
+	mu     sync.Mutex
+	hashes map[string]fetchedCodeHash
+}
+
+func newCodeHashCache() *codeHashCache {
+	return &codeHashCache{hashes: make(map[string]fetchedCodeHash)}
+}
+
+// codeHash returns the code hash of `function`, calling `fetch` to
+// look it up if we haven't recently.
+func (c *codeHashCache) codeHash(function string, now time.Time, fetch func() (string, error)) (string, error) {
+	c.mu.Lock()
+	ent, ok := c.hashes[function]
+	c.mu.Unlock()
+	if ok && now.Sub(ent.fetched) < codeHashTTL {
+		return ent.hash, nil
+	}
+	hash, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.hashes[function] = fetchedCodeHash{fetched: now, hash: hash}
+	c.mu.Unlock()
+	return hash, nil
+}
+
+func (d *Daemon) functionCodeHash(ctx context.Context, function string) (string, error) {
+	return d.codeHashes.codeHash(function, time.Now(), func() (string, error) {
+		cfg, err := d.lambda.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: &function,
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(cfg.CodeSha256), nil
+	})
+}
+
+// resultCacheHash computes the hash identifying an invocation in the
+// result cache, or returns "" if the invocation can't be cached. See
+// daemon.ResultKey for where entries are stored.
+func (d *Daemon) resultCacheHash(ctx context.Context, args *llama.InvokeArgs) string {
+	if _, ok := d.store.(store.KeyValue); !ok {
+		return ""
+	}
+	if _, ok := d.local[args.Function]; ok {
+		// We have no way to know when whatever a local
+		// function runs changes underneath us.
+		return ""
+	}
+	codeHash, err := d.functionCodeHash(ctx, args.Function)
+	if err != nil {
+		logging.Printf(ctx, "result cache: fetching code hash for %s: %s", args.Function, err.Error())
+		return ""
+	}
+	material := cacheKeyMaterial{
+		Function: args.Function,
+		CodeHash: codeHash,
+		Spec:     args.Spec,
+	}
+	material.Spec.Trace = nil
+	material.Spec.Stream = ""
+	material.Spec.InvocationID = ""
+	// Files are uploaded concurrently, so their order is arbitrary
+	material.Spec.Files = append(protocol.FileList(nil), args.Spec.Files...)
+	sort.Slice(material.Spec.Files, func(i, j int) bool {
+		return material.Spec.Files[i].Path < material.Spec.Files[j].Path
+	})
+	encoded, err := json.Marshal(&material)
+	if err != nil {
+		return ""
+	}
+	sum := blake2b.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupResult returns the first of the entries `keys` in the result
+// cache, or nil if there are none.
+func (d *Daemon) lookupResult(ctx context.Context, keys []string) *protocol.InvocationResponse {
+	ctx, span := tracing.StartSpan(ctx, "result_cache.lookup")
+	defer span.End()
+	for _, key := range keys {
+		data, err := d.store.(store.KeyValue).GetKey(ctx, key)
+		if err != nil {
+			if err != store.ErrNotExists {
+				logging.Printf(ctx, "result cache: get %s: %s", key, err.Error())
+			}
+			continue
+		}
+		var resp protocol.InvocationResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			logging.Printf(ctx, "result cache: decoding %s: %s", key, err.Error())
+			continue
+		}
+		span.AddField("hit", true)
+		span.AddField("key", key)
+		return &resp
+	}
+	span.AddField("hit", false)
+	return nil
+}
+
+func (d *Daemon) storeResult(ctx context.Context, key string, resp *protocol.InvocationResponse) {
+	ctx, span := tracing.StartSpan(ctx, "result_cache.store")
+	defer span.End()
+	entry := protocol.InvocationResponse{
+		ExitStatus: resp.ExitStatus,
+		Stdout:     resp.Stdout,
+		Stderr:     resp.Stderr,
+		Outputs:    resp.Outputs,
+	}
+	for _, out := range entry.Outputs {
+		if out.Err != "" {
+			return
+		}
+	}
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return
+	}
+	if err := d.store.(store.KeyValue).SetKey(ctx, key, data); err != nil {
+		logging.Printf(ctx, "result cache: set %s: %s", key, err.Error())
+		return
+	}
+	if idx, ok := d.store.(store.Indexer); ok && idx.HasIndex() {
+		if err := idx.AddRefs(ctx, entry.Refs(), 1); err != nil {
+			logging.Printf(ctx, "result cache: counting references from %s: %s", key, err.Error())
+		}
+	}
+}
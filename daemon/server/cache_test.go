@@ -0,0 +1,151 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLambda serves GetFunctionConfiguration, reporting whatever
+// code hash it was last given.
+type fakeLambda struct {
+	mu       sync.Mutex
+	codeHash string
+	fetches  int
+}
+
+func (f *fakeLambda) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" || !strings.HasSuffix(r.URL.Path, "/configuration") {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches++
+	json.NewEncoder(w).Encode(&lambda.FunctionConfiguration{
+		FunctionName: aws.String("cc"),
+		CodeSha256:   aws.String(f.codeHash),
+	})
+}
+
+func (f *fakeLambda) set(hash string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.codeHash = hash
+}
+
+func (f *fakeLambda) fetched() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+func TestResultCache(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeLambda{codeHash: "code-v1"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	sess, err := session.NewSession(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-west-2").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	require.NoError(t, err)
+
+	d := &Daemon{
+		ctx:        ctx,
+		store:      store.InMemory(),
+		lambda:     lambda.New(sess),
+		codeHashes: newCodeHashCache(),
+	}
+	cache := daemon.CacheConfig{Policy: daemon.CacheReadWrite}
+	args := llama.InvokeArgs{
+		Function: "cc",
+		Spec: protocol.InvocationSpec{
+			Args: []string{"cc", "-c", "a.c"},
+			Files: protocol.FileList{
+				{Path: "a.c", File: protocol.File{Blob: protocol.Blob{Bytes: []byte("int a;")}}},
+				{Path: "a.h", File: protocol.File{Blob: protocol.Blob{Bytes: []byte("extern int a;")}}},
+			},
+		},
+	}
+
+	hash := d.resultCacheHash(ctx, &args)
+	require.NotEqual(t, "", hash)
+	assert.Nil(t, d.lookupResult(ctx, cache.LookupKeys(hash)), "miss")
+
+	resp := protocol.InvocationResponse{ExitStatus: 0, Stdout: &protocol.Blob{Bytes: []byte("ok")}}
+	d.storeResult(ctx, daemon.ResultKey(cache.Namespace, hash), &resp)
+
+	// Neither file order nor the invocation ID affect the key, and
+	// the code hash is only fetched once
+	reordered := args
+	reordered.Spec.InvocationID = "inv-2"
+	reordered.Spec.Files = protocol.FileList{args.Spec.Files[1], args.Spec.Files[0]}
+	assert.Equal(t, hash, d.resultCacheHash(ctx, &reordered))
+	assert.Equal(t, 1, fake.fetched())
+	if hit := d.lookupResult(ctx, cache.LookupKeys(hash)); assert.NotNil(t, hit, "hit") {
+		assert.Equal(t, resp.Stdout, hit.Stdout)
+	}
+
+	// Once the cached code hash expires, we notice the function's
+	// code changed and stop serving results from the old code
+	fake.set("code-v2")
+	assert.Equal(t, hash, d.resultCacheHash(ctx, &args))
+	d.codeHashes.hashes["cc"] = fetchedCodeHash{
+		fetched: time.Now().Add(-codeHashTTL),
+		hash:    d.codeHashes.hashes["cc"].hash,
+	}
+	updated := d.resultCacheHash(ctx, &args)
+	assert.Equal(t, 2, fake.fetched())
+	assert.NotEqual(t, hash, updated)
+	assert.Nil(t, d.lookupResult(ctx, cache.LookupKeys(updated)), "miss after code change")
+}
+
+func TestResultCacheLambdaError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	sess, err := session.NewSession(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-west-2").
+		WithMaxRetries(0).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	require.NoError(t, err)
+
+	d := &Daemon{
+		store:      store.InMemory(),
+		lambda:     lambda.New(sess),
+		codeHashes: newCodeHashCache(),
+	}
+	args := llama.InvokeArgs{Function: "cc", Spec: protocol.InvocationSpec{Args: []string{"cc"}}}
+	assert.Equal(t, "", d.resultCacheHash(context.Background(), &args), "uncacheable without a code hash")
+}
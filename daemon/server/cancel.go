@@ -0,0 +1,127 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errCancelled = errors.New("invocation cancelled")
+
+// cancelRegistry lets clients abandon invocations whose results they
+// no longer need, such as llamacc when a local compilation wins a
+// race. An invocation can be cancelled until it commits to writing
+// its outputs, so that a client which has cancelled one can safely
+// write the same files itself.
+type cancelRegistry struct {
+	sync.Mutex
+	byID map[string]*cancellable
+}
+
+type cancellable struct {
+	cancel    context.CancelFunc
+	cancelled bool
+	committed bool
+}
+
+// register makes the invocation `id` cancellable, returning a context
+// which is cancelled with it, and a function to call once it is done.
+// If `id` was cancelled before it was registered, the context is
+// already cancelled.
+func (r *cancelRegistry) register(ctx context.Context, id string) (context.Context, *cancellable, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.Lock()
+	defer r.Unlock()
+	if r.byID == nil {
+		r.byID = make(map[string]*cancellable)
+	}
+	c, ok := r.byID[id]
+	if ok && c.cancelled {
+		cancel()
+	}
+	c = &cancellable{cancel: cancel, cancelled: ok && c.cancelled}
+	r.byID[id] = c
+	return ctx, c, func() {
+		r.Lock()
+		defer r.Unlock()
+		if r.byID[id] == c {
+			delete(r.byID, id)
+		}
+		cancel()
+	}
+}
+
+// commit marks an invocation as writing its outputs, and returns
+// false if it has already been cancelled
+func (r *cancelRegistry) commit(c *cancellable) bool {
+	r.Lock()
+	defer r.Unlock()
+	if c.cancelled {
+		return false
+	}
+	c.committed = true
+	return true
+}
+
+func (r *cancelRegistry) isCancelled(c *cancellable) bool {
+	r.Lock()
+	defer r.Unlock()
+	return c.cancelled
+}
+
+// cancel cancels invocation `id`, returning false if it has already
+// committed to writing its outputs. Cancelling an invocation before
+// it is registered leaves a marker which cancels it on arrival.
+func (r *cancelRegistry) cancel(id string) bool {
+	r.Lock()
+	defer r.Unlock()
+	if r.byID == nil {
+		r.byID = make(map[string]*cancellable)
+	}
+	c, ok := r.byID[id]
+	if !ok {
+		r.byID[id] = &cancellable{cancel: func() {}, cancelled: true}
+		return true
+	}
+	return c.cancelLocked()
+}
+
+// cancelWhenDone cancels `c` if `ctx` is done before the returned
+// function is called. Unlike cancel, it leaves no marker behind if
+// the invocation has already finished.
+func (r *cancelRegistry) cancelWhenDone(ctx context.Context, c *cancellable) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Lock()
+			c.cancelLocked()
+			r.Unlock()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+func (c *cancellable) cancelLocked() bool {
+	if c.committed {
+		return false
+	}
+	c.cancelled = true
+	c.cancel()
+	return true
+}
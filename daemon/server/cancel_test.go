@@ -0,0 +1,74 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelRegistry(t *testing.T) {
+	var r cancelRegistry
+
+	// Cancelled before committing
+	ctx, c, done := r.register(context.Background(), "a")
+	assert.True(t, r.cancel("a"))
+	assert.Error(t, ctx.Err())
+	assert.False(t, r.commit(c))
+	assert.True(t, r.isCancelled(c))
+	done()
+
+	// Cancelled after committing
+	ctx, c, done = r.register(context.Background(), "b")
+	assert.True(t, r.commit(c))
+	assert.False(t, r.cancel("b"))
+	assert.NoError(t, ctx.Err())
+	done()
+	assert.Empty(t, r.byID)
+
+	// Cancelled before it arrives
+	assert.True(t, r.cancel("c"))
+	ctx, c, done = r.register(context.Background(), "c")
+	assert.Error(t, ctx.Err())
+	assert.False(t, r.commit(c))
+	done()
+	assert.Empty(t, r.byID)
+}
+
+func TestCancelWhenDone(t *testing.T) {
+	var r cancelRegistry
+
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	ctx, c, done := r.register(context.Background(), "a")
+	stop := r.cancelWhenDone(reqCtx, c)
+	cancelReq()
+	<-ctx.Done()
+	assert.True(t, r.isCancelled(c))
+	stop()
+	done()
+
+	// Once committed, the invocation runs to completion
+	reqCtx, cancelReq = context.WithCancel(context.Background())
+	_, c, done = r.register(context.Background(), "b")
+	stop = r.cancelWhenDone(reqCtx, c)
+	assert.True(t, r.commit(c))
+	cancelReq()
+	stop()
+	assert.False(t, r.isCancelled(c))
+	done()
+	assert.Empty(t, r.byID)
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nelhage/llama/protocol"
+)
+
+// localChanges maps the files a command created or modified, which
+// the runtime returns by their paths relative to the command's
+// working directory, to their places under the local directory
+// `dir`. It refuses paths which would escape `dir`.
+func localChanges(dir string, outputs protocol.FileList) (fetch protocol.FileList, changed []string, bad protocol.FileList) {
+	for _, out := range outputs {
+		p := out.Path
+		if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+			bad = append(bad, out)
+			continue
+		}
+		changed = append(changed, p)
+		out.Path = filepath.Join(dir, filepath.FromSlash(p))
+		fetch = append(fetch, out)
+	}
+	return fetch, changed, bad
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalChanges(t *testing.T) {
+	out := func(p string) protocol.FileAndPath {
+		return protocol.FileAndPath{Path: p, File: protocol.File{Blob: protocol.Blob{Bytes: []byte(p)}}}
+	}
+	dir := filepath.FromSlash("/src/project")
+	fetch, changed, bad := localChanges(dir, protocol.FileList{
+		out("gen/parser.c"),
+		out("README"),
+		out("../escape"),
+		out("/etc/passwd"),
+		out("a/../../b"),
+	})
+	assert.Equal(t, []string{"gen/parser.c", "README"}, changed)
+	assert.Equal(t, protocol.FileList{
+		{Path: filepath.Join(dir, "gen", "parser.c"), File: out("gen/parser.c").File},
+		{Path: filepath.Join(dir, "README"), File: out("README").File},
+	}, fetch)
+	assert.Len(t, bad, 3)
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"golang.org/x/crypto/blake2b"
+)
+
+// depsCache remembers the headers the preprocessor found for each
+// translation unit, so that llamacc can skip `cpp -M` when it
+// compiles the same source the same way again, as in a rebuild after
+// an unrelated change. An entry stays valid until a header it lists
+// changes, or a file appears in or vanishes from a directory the
+// preprocessor searched, which might shadow or remove one of them;
+// like includeIndex, we tell by size and modification time.
+//
+// It also remembers the headers each input file depended on most
+// recently, whatever its contents, so that when an edited source
+// misses the cache we can upload them while the preprocessor runs:
+// most edits change few of a file's includes.
+type depsCache struct {
+	mu      sync.Mutex
+	entries map[string]*depsEntry
+	last    map[string][]string
+}
+
+type depsEntry struct {
+	deps []string
+
+	// Every header, and every directory searched, and their
+	// state when the entry was recorded
+	paths  []string
+	stamps []fileStamp
+}
+
+// The most entries we keep. A full cache drops an arbitrary entry
+// for each new one.
+const maxDepsEntries = 1 << 16
+
+// Files modified this recently may change again within the
+// resolution of their timestamps, so we don't cache entries that
+// list them.
+const depsRacyAge = 2 * time.Second
+
+func newDepsCache() *depsCache {
+	return &depsCache{
+		entries: make(map[string]*depsEntry),
+		last:    make(map[string][]string),
+	}
+}
+
+// key returns the cache key for `k`: a hash of the compiler and its
+// state on disk, the arguments, and the contents of the input.
+func (c *depsCache) key(k *daemon.DepsKey) (string, error) {
+	for _, p := range append([]string{k.Compiler, k.Dir}, k.Search...) {
+		if !filepath.IsAbs(p) {
+			return "", fmt.Errorf("path %q is not absolute", p)
+		}
+	}
+	cc, err := os.Stat(k.Compiler)
+	if err != nil {
+		return "", err
+	}
+	input := k.Stdin
+	if k.Input != "-" {
+		if !filepath.IsAbs(k.Input) {
+			return "", fmt.Errorf("path %q is not absolute", k.Input)
+		}
+		if input, err = ioutil.ReadFile(k.Input); err != nil {
+			return "", err
+		}
+	}
+	inputHash := blake2b.Sum256(input)
+	material := struct {
+		Compiler      string
+		CompilerSize  int64
+		CompilerMtime time.Time
+		Args          []string
+		Dir           string
+		Env           []string
+		Input         string
+		InputHash     string
+	}{
+		k.Compiler, cc.Size(), cc.ModTime(),
+		k.Args, k.Dir, k.Env,
+		k.Input, hex.EncodeToString(inputHash[:]),
+	}
+	encoded, err := json.Marshal(&material)
+	if err != nil {
+		return "", err
+	}
+	sum := blake2b.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (e *depsEntry) valid() bool {
+	for i, p := range e.paths {
+		st, err := os.Stat(p)
+		if err != nil || stampOf(st) != e.stamps[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *depsCache) lookup(key string) ([]string, bool) {
+	c.mu.Lock()
+	ent, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	if !ent.valid() {
+		c.mu.Lock()
+		if c.entries[key] == ent {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		return nil, false
+	}
+	return ent.deps, true
+}
+
+// record adds an entry listing `deps`, found by searching `search`
+// from `dir`. It caches nothing if it can't stat every path involved,
+// or any of them changed too recently to trust.
+func (c *depsCache) record(key string, deps []string, dir string, search []string, now time.Time) {
+	ent := &depsEntry{deps: deps}
+	seen := make(map[string]bool)
+	add := func(p string) bool {
+		if seen[p] {
+			return true
+		}
+		seen[p] = true
+		st, err := os.Stat(p)
+		if err != nil || now.Sub(st.ModTime()) < depsRacyAge {
+			return false
+		}
+		ent.paths = append(ent.paths, p)
+		ent.stamps = append(ent.stamps, stampOf(st))
+		return true
+	}
+	for _, p := range search {
+		if !add(filepath.Clean(p)) {
+			return
+		}
+	}
+	for _, dep := range deps {
+		if !filepath.IsAbs(dep) {
+			dep = filepath.Join(dir, dep)
+		}
+		dep = filepath.Clean(dep)
+		if !add(dep) || !add(filepath.Dir(dep)) {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxDepsEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = ent
+}
+
+// remember records that `input` last depended on `deps`, relative to
+// `dir`.
+func (c *depsCache) remember(input string, deps []string, dir string) {
+	abs := make([]string, len(deps))
+	for i, dep := range deps {
+		if !filepath.IsAbs(dep) {
+			dep = filepath.Join(dir, dep)
+		}
+		abs[i] = filepath.Clean(dep)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.last[input]; !ok && len(c.last) >= maxDepsEntries {
+		for k := range c.last {
+			delete(c.last, k)
+			break
+		}
+	}
+	c.last[input] = abs
+}
+
+// previous returns the headers `input` last depended on, if we know
+// them.
+func (c *depsCache) previous(input string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last[input]
+}
+
+func (d *Daemon) LookupDeps(in *daemon.LookupDepsArgs, out *daemon.LookupDepsReply) error {
+	key, err := d.deps.key(&in.Key)
+	if err != nil {
+		return fmt.Errorf("LookupDeps: %w", err)
+	}
+	out.Deps, out.Found = d.deps.lookup(key)
+	if out.Found {
+		atomic.AddUint64(&d.stats.DepsCacheHits, 1)
+	} else {
+		atomic.AddUint64(&d.stats.DepsCacheMisses, 1)
+		if in.Stage && in.Key.Input != "-" {
+			d.stage(d.deps.previous(in.Key.Input))
+		}
+	}
+	return nil
+}
+
+func (d *Daemon) RecordDeps(in *daemon.RecordDepsArgs, out *daemon.RecordDepsReply) error {
+	key, err := d.deps.key(&in.Key)
+	if err != nil {
+		return fmt.Errorf("RecordDeps: %w", err)
+	}
+	d.deps.record(key, in.Deps, in.Key.Dir, in.Key.Search, time.Now())
+	if in.Key.Input != "-" {
+		d.deps.remember(in.Key.Input, in.Deps, in.Key.Dir)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepsCache(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) { writeTestFile(t, dir, name, body) }
+	write("cc", "")
+	write("main.c", `#include "main.h"
+`)
+	write("main.h", "")
+	write("inc/lib.h", "")
+	write("other/other.h", "")
+
+	old := time.Now().Add(-time.Hour)
+	age := func() {
+		require.NoError(t, filepath.Walk(dir, func(p string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Chtimes(p, old, old)
+		}))
+	}
+	age()
+
+	cache := newDepsCache()
+	k := daemon.DepsKey{
+		Compiler: filepath.Join(dir, "cc"),
+		Args:     []string{"cc", "-Iinc", "-M", "main.c"},
+		Dir:      dir,
+		Input:    filepath.Join(dir, "main.c"),
+		Search:   []string{dir, filepath.Join(dir, "inc")},
+	}
+	key, err := cache.key(&k)
+	require.NoError(t, err)
+
+	_, ok := cache.lookup(key)
+	assert.False(t, ok)
+
+	deps := []string{"main.c", "main.h", "inc/lib.h"}
+	cache.record(key, deps, dir, k.Search, time.Now())
+	got, ok := cache.lookup(key)
+	require.True(t, ok)
+	assert.Equal(t, deps, got)
+
+	other := k
+	other.Args = []string{"cc", "-Iinc", "-DX", "-M", "main.c"}
+	otherKey, err := cache.key(&other)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, otherKey)
+
+	// An unrelated directory changing doesn't matter
+	write("other/new.h", "")
+	_, ok = cache.lookup(key)
+	assert.True(t, ok)
+
+	// A header changing invalidates the entry
+	write("inc/lib.h", "#define LIB 1\n")
+	_, ok = cache.lookup(key)
+	assert.False(t, ok)
+
+	// As does a new file in a directory we searched, which might
+	// shadow a header
+	age()
+	cache.record(key, deps, dir, k.Search, time.Now())
+	_, ok = cache.lookup(key)
+	require.True(t, ok)
+	write("inc/main.h", "")
+	_, ok = cache.lookup(key)
+	assert.False(t, ok)
+
+	// Changing the input changes the key
+	age()
+	write("main.c", "")
+	newKey, err := cache.key(&k)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, newKey)
+}
+
+func TestDepsCachePrevious(t *testing.T) {
+	cache := newDepsCache()
+	dir := filepath.FromSlash("/src")
+	input := filepath.Join(dir, "main.c")
+	assert.Nil(t, cache.previous(input))
+
+	cache.remember(input, []string{"main.c", "inc/../main.h", filepath.Join(dir, "lib.h")}, dir)
+	assert.Equal(t, []string{
+		input,
+		filepath.Join(dir, "main.h"),
+		filepath.Join(dir, "lib.h"),
+	}, cache.previous(input))
+
+	cache.remember(input, []string{"main.c"}, dir)
+	assert.Equal(t, []string{input}, cache.previous(input))
+}
+
+func TestDepsCacheRacy(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.c", "")
+	writeTestFile(t, dir, "main.h", "")
+
+	cache := newDepsCache()
+	cache.record("key", []string{"main.c", "main.h"}, dir, nil, time.Now())
+	_, ok := cache.lookup("key")
+	assert.False(t, ok, "files modified just now shouldn't be cached")
+
+	cache.record("key", []string{"main.c", "main.h"}, dir, nil, time.Now().Add(time.Minute))
+	_, ok = cache.lookup("key")
+	assert.True(t, ok)
+}
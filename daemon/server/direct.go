@@ -0,0 +1,60 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+)
+
+// splitDirect separates the files held in the object store, which a
+// client can fetch directly, from those inline in the response.
+func splitDirect(fl protocol.FileList) (inline, direct protocol.FileList) {
+	for _, f := range fl {
+		if len(f.Refs()) > 0 {
+			direct = append(direct, f)
+		} else {
+			inline = append(inline, f)
+		}
+	}
+	return inline, direct
+}
+
+// Presign presigns requests for clients to transfer objects to and
+// from the object store themselves, if the store supports it.
+func (d *Daemon) Presign(in *daemon.PresignArgs, out *daemon.PresignReply) error {
+	*out = daemon.PresignReply{}
+	ps, ok := d.store.(store.Presigner)
+	if !ok {
+		return nil
+	}
+	out.Supported = true
+	for _, id := range in.Put {
+		req, err := ps.PresignPut(d.ctx, id)
+		if err != nil {
+			return err
+		}
+		out.Put = append(out.Put, req)
+	}
+	for _, id := range in.Get {
+		req, err := ps.PresignGet(d.ctx, id)
+		if err != nil {
+			return err
+		}
+		out.Get = append(out.Get, req)
+	}
+	return nil
+}
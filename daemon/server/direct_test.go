@@ -0,0 +1,70 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// An alias, since a field named Store would hide the Store method
+type memStore = store.Store
+
+type presignStore struct {
+	memStore
+}
+
+func (presignStore) PresignPut(ctx context.Context, id string) (store.PresignedRequest, error) {
+	if id == "have" {
+		return store.PresignedRequest{}, nil
+	}
+	return store.PresignedRequest{URL: "https://put/" + id}, nil
+}
+
+func (presignStore) PresignGet(ctx context.Context, id string) (store.PresignedRequest, error) {
+	return store.PresignedRequest{URL: "https://get/" + id}, nil
+}
+
+func TestPresign(t *testing.T) {
+	d := Daemon{ctx: context.Background(), store: store.InMemory()}
+	var out daemon.PresignReply
+	require.NoError(t, d.Presign(&daemon.PresignArgs{Put: []string{"a"}}, &out))
+	assert.False(t, out.Supported)
+
+	d.store = presignStore{store.InMemory()}
+	require.NoError(t, d.Presign(&daemon.PresignArgs{
+		Put: []string{"a", "have"},
+		Get: []string{"b"},
+	}, &out))
+	assert.True(t, out.Supported)
+	assert.Equal(t, []store.PresignedRequest{{URL: "https://put/a"}, {}}, out.Put)
+	assert.Equal(t, []store.PresignedRequest{{URL: "https://get/b"}}, out.Get)
+}
+
+func TestSplitDirect(t *testing.T) {
+	inline := protocol.FileAndPath{Path: "/out/small.o", File: protocol.File{Blob: protocol.Blob{Bytes: []byte("x")}}}
+	ref := protocol.FileAndPath{Path: "/out/big.o", File: protocol.File{Blob: protocol.Blob{Ref: "abc"}}}
+	chunked := protocol.FileAndPath{Path: "/out/huge.o", File: protocol.File{Blob: protocol.Blob{Chunks: []string{"c1", "c2"}}}}
+
+	in, direct := splitDirect(protocol.FileList{inline, ref, chunked})
+	assert.Equal(t, protocol.FileList{inline}, in)
+	assert.Equal(t, protocol.FileList{ref, chunked}, direct)
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/distcc"
+	"github.com/nelhage/llama/files"
+)
+
+// How long we give a distcc client to send its job, and then to read
+// our reply
+const distccIOTimeout = 5 * time.Minute
+
+type distccServer struct {
+	d        *Daemon
+	function string
+	allow    []*net.IPNet
+	extend   chan<- struct{}
+}
+
+// allowedPeer reports whether we accept jobs from `addr`, given the
+// networks in `allow`. If `allow` is empty, we only accept
+// connections from the local machine.
+func allowedPeer(allow []*net.IPNet, addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	if len(allow) == 0 {
+		return tcp.IP.IsLoopback()
+	}
+	for _, n := range allow {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *distccServer) serve(ctx context.Context, l net.Listener) {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("distcc: accept: %s", err.Error())
+			}
+			return
+		}
+		if !allowedPeer(s.allow, conn.RemoteAddr()) {
+			log.Printf("distcc: rejecting connection from %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+// handle runs a single job. If we fail to run it, we close the
+// connection without a reply, which tells the client to compile
+// locally.
+func (s *distccServer) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	select {
+	case s.extend <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+
+	conn.SetDeadline(time.Now().Add(distccIOTimeout))
+	job, err := distcc.ReadJob(bufio.NewReader(conn))
+	if err != nil {
+		log.Printf("distcc: %s: reading job: %s", conn.RemoteAddr(), err.Error())
+		return
+	}
+	res, err := s.run(job)
+	if err != nil {
+		log.Printf("distcc: %s: %s", conn.RemoteAddr(), err.Error())
+		return
+	}
+	conn.SetDeadline(time.Now().Add(distccIOTimeout))
+	w := bufio.NewWriter(conn)
+	if err := distcc.WriteResult(w, res); err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		log.Printf("distcc: %s: writing result: %s", conn.RemoteAddr(), err.Error())
+	}
+}
+
+func (s *distccServer) run(job *distcc.Job) (*distcc.Result, error) {
+	const output = "distcc.o"
+	argv, err := job.Command(output)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "llama-distcc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, output)
+
+	args := daemon.InvokeWithFilesArgs{
+		Function: s.function,
+		Args:     argv,
+		Stdin:    job.Source,
+		Outputs: files.List{{
+			Local:  files.LocalFile{Path: local},
+			Remote: output,
+		}},
+	}
+	var reply daemon.InvokeWithFilesReply
+	if err := s.d.InvokeWithFiles(&args, &reply); err != nil {
+		return nil, err
+	}
+	if reply.InvokeErr != "" {
+		return nil, fmt.Errorf("invoke: %s", reply.InvokeErr)
+	}
+	res := distcc.Result{
+		ExitStatus: reply.ExitStatus,
+		Stdout:     reply.Stdout,
+		Stderr:     reply.Stderr,
+	}
+	if res.ExitStatus == 0 {
+		if res.Object, err = ioutil.ReadFile(local); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultDrainTimeout is how long a graceful shutdown waits for
+// in-flight invocations before abandoning them.
+const DefaultDrainTimeout = 2 * time.Minute
+
+// errDraining is returned for invocations that arrive once the
+// daemon has begun shutting down, so that clients fall back to
+// running them elsewhere.
+var errDraining = errors.New("the daemon is shutting down")
+
+// drainer tracks the invocations in flight, so that a graceful
+// shutdown can stop admitting new ones and wait for the rest.
+type drainer struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{}
+}
+
+// enter admits an invocation, unless we are draining. Every
+// successful enter must be paired with an exit.
+func (dr *drainer) enter() error {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	if dr.draining {
+		return errDraining
+	}
+	dr.active++
+	return nil
+}
+
+func (dr *drainer) exit() {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	dr.active--
+	if dr.draining && dr.active == 0 {
+		close(dr.idle)
+	}
+}
+
+// start stops admitting invocations, and returns a channel which is
+// closed once every admitted invocation has exited.
+func (dr *drainer) start() <-chan struct{} {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	if !dr.draining {
+		dr.draining = true
+		dr.idle = make(chan struct{})
+		if dr.active == 0 {
+			close(dr.idle)
+		}
+	}
+	return dr.idle
+}
+
+// inFlight returns the number of admitted invocations still running
+func (dr *drainer) inFlight() int {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	return dr.active
+}
+
+// drain stops accepting invocations and waits up to timeout for the
+// ones in flight to finish. It returns the number it gave up on.
+func (d *Daemon) drain(timeout time.Duration) int {
+	if timeout <= 0 {
+		timeout = d.drainTimeout
+	}
+	idle := d.drainer.start()
+	if n := d.drainer.inFlight(); n > 0 {
+		log.Printf("shutting down: waiting up to %s for %d in-flight invocations", timeout, n)
+	}
+	select {
+	case <-idle:
+		return 0
+	case <-time.After(timeout):
+		n := d.drainer.inFlight()
+		log.Printf("shutting down: abandoning %d in-flight invocations", n)
+		return n
+	}
+}
+
+// handleSignals shuts the daemon down gracefully on SIGTERM or an
+// interrupt, and immediately on a second one.
+func (d *Daemon) handleSignals(ctx context.Context) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	select {
+	case sig := <-sigs:
+		log.Printf("received %s, draining", sig)
+	case <-ctx.Done():
+		return
+	}
+	go func() {
+		d.drain(0)
+		d.shutdown()
+	}()
+	select {
+	case sig := <-sigs:
+		log.Printf("received %s, exiting immediately", sig)
+		d.shutdown()
+	case <-ctx.Done():
+	}
+}
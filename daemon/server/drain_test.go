@@ -0,0 +1,72 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainer(t *testing.T) {
+	var dr drainer
+	assert.NoError(t, dr.enter())
+	assert.NoError(t, dr.enter())
+
+	idle := dr.start()
+	assert.Equal(t, errDraining, dr.enter())
+	dr.exit()
+	select {
+	case <-idle:
+		t.Fatal("drained with an invocation in flight")
+	default:
+	}
+	dr.exit()
+	<-idle
+
+	// Starting again is harmless
+	<-dr.start()
+}
+
+func TestShutdownDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := Daemon{ctx: ctx, shutdown: cancel, drainTimeout: time.Minute}
+
+	assert.NoError(t, d.drainer.enter())
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		time.Sleep(10 * time.Millisecond)
+		d.drainer.exit()
+	}()
+	var out daemon.ShutdownReply
+	assert.NoError(t, d.Shutdown(daemon.ShutdownArgs{Drain: true}, &out))
+	assert.Equal(t, 0, out.Abandoned)
+	assert.Error(t, ctx.Err())
+	<-exited
+
+	// Invocations that outlive the timeout are abandoned
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	d2 := Daemon{ctx: ctx2, shutdown: cancel2, drainTimeout: time.Minute}
+	assert.NoError(t, d2.drainer.enter())
+	assert.NoError(t, d2.Shutdown(daemon.ShutdownArgs{Drain: true, DrainTimeout: 10 * time.Millisecond}, &out))
+	assert.Equal(t, 1, out.Abandoned)
+	assert.Error(t, ctx2.Err())
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/daemonpb"
+	"github.com/nelhage/llama/files"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer serves the daemon's gRPC API, for clients other than
+// our own tools. Each method wraps the net/rpc method of the same
+// name.
+type grpcServer struct {
+	daemonpb.UnimplementedDaemonServer
+	d *Daemon
+}
+
+func (s *grpcServer) GetVersion(_ context.Context, in *daemonpb.VersionRequest) (*daemonpb.VersionReply, error) {
+	return &daemonpb.VersionReply{
+		DaemonVersion:      daemon.Version(),
+		ProtocolVersion:    daemon.ProtocolVersion,
+		MinProtocolVersion: daemon.MinProtocolVersion,
+	}, nil
+}
+
+func (s *grpcServer) Ping(context.Context, *daemonpb.PingRequest) (*daemonpb.PingReply, error) {
+	return &daemonpb.PingReply{}, nil
+}
+
+func toFileList(in []*daemonpb.File) files.List {
+	var out files.List
+	for _, f := range in {
+		out = out.Append(files.Mapped{Local: files.LocalFile{Path: f.Local}, Remote: f.Remote})
+	}
+	return out
+}
+
+// Invoke runs an invocation, which is cancelled if the client goes
+// away before its outputs are written.
+func (s *grpcServer) Invoke(ctx context.Context, in *daemonpb.InvokeRequest) (*daemonpb.InvokeReply, error) {
+	args := daemon.InvokeWithFilesArgs{
+		Function:   in.Function,
+		ReturnLogs: in.ReturnLogs,
+		Args:       in.Args,
+		Stdin:      in.Stdin,
+		Files:      toFileList(in.Files),
+		Outputs:    toFileList(in.Outputs),
+		Trees:      toFileList(in.Trees),
+		UseCache:   in.UseCache,
+		Memory:     in.MemoryMb,
+		Timeout:    time.Duration(in.TimeoutMs) * time.Millisecond,
+		Env:        in.Env,
+	}
+	if in.Priority != "" {
+		p, err := daemon.ParsePriority(in.Priority)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		args.Priority = p
+	}
+	var out daemon.InvokeWithFilesReply
+	if err := s.d.invokeWithFiles(ctx, &args, &out); err != nil {
+		if err == errCancelled && ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, err
+	}
+	return &daemonpb.InvokeReply{
+		InvokeError: out.InvokeErr,
+		ExitStatus:  int32(out.ExitStatus),
+		Stdout:      out.Stdout,
+		Stderr:      out.Stderr,
+		Logs:        out.Logs,
+	}, nil
+}
+
+func (s *grpcServer) GetStats(_ context.Context, in *daemonpb.StatsRequest) (*daemonpb.StatsReply, error) {
+	var out daemon.StatsReply
+	if err := s.d.GetDaemonStats(&daemon.StatsArgs{Reset: in.Reset_}, &out); err != nil {
+		return nil, err
+	}
+	st := &out.Stats
+	return &daemonpb.StatsReply{
+		InFlight:         st.InFlight,
+		Invocations:      st.Invocations,
+		FunctionErrors:   st.FunctionErrors,
+		OtherErrors:      st.OtherErrors,
+		CacheHits:        st.CacheHits,
+		CacheMisses:      st.CacheMisses,
+		Retries:          st.Retries,
+		Throttles:        st.Throttles,
+		ConcurrencyLimit: st.ConcurrencyLimit,
+		EstimatedCost:    out.Cost.Total(),
+	}, nil
+}
+
+func (s *grpcServer) Shutdown(context.Context, *daemonpb.ShutdownRequest) (*daemonpb.ShutdownReply, error) {
+	s.d.shutdown()
+	return &daemonpb.ShutdownReply{}, nil
+}
+
+// serveGRPC serves the gRPC API on `sockPath` until `ctx` is done.
+// Every request postpones the idle timeout, by sending on `extend`.
+func (d *Daemon) serveGRPC(ctx context.Context, sockPath string, extend chan<- struct{}) error {
+	// The caller holds the daemon's lock, so any existing socket
+	// is stale.
+	os.Remove(sockPath)
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			select {
+			case extend <- struct{}{}:
+			case <-ctx.Done():
+			}
+			return handler(ctx, req)
+		}),
+	)
+	daemonpb.RegisterDaemonServer(srv, &grpcServer{d: d})
+	reflection.Register(srv)
+	go func() {
+		<-ctx.Done()
+		srv.Stop()
+	}()
+	go srv.Serve(l)
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/nelhage/llama/daemon"
+)
+
+// ErrDaemonTooNew is returned when the running daemon no longer
+// speaks the protocol of the client trying to use it. We never
+// replace a newer daemon with an older one, since two installs of
+// Llama on the same machine would otherwise fight over the socket.
+var ErrDaemonTooNew = errors.New("the running daemon no longer supports this client; upgrade llama")
+
+// An outdatedError reports a daemon that is too old to serve us. It
+// holds the client connected to it, so the caller can shut it down.
+type outdatedError struct {
+	client  *daemon.Client
+	version string
+}
+
+func (e *outdatedError) Error() string {
+	return fmt.Sprintf("the running daemon (version %s) is older than this client (version %s)",
+		e.version, daemon.Version())
+}
+
+// checkVersion classifies a daemon's reply to our Ping. A daemon
+// that predates the handshake reports protocol version 0, and so
+// counts as outdated.
+func checkVersion(pong *daemon.PingReply) error {
+	switch {
+	case pong.ProtocolVersion < daemon.ProtocolVersion:
+		return &outdatedError{version: pong.DaemonVersion}
+	case pong.MinProtocolVersion > daemon.ProtocolVersion:
+		return fmt.Errorf("%w (daemon version %s, ours %s)",
+			ErrDaemonTooNew, pong.DaemonVersion, daemon.Version())
+	}
+	return nil
+}
+
+// dialCurrent connects to the daemon on sockPath and checks that it
+// speaks our protocol. If it is outdated, the returned
+// *outdatedError holds the connection; otherwise no connection is
+// left open on error.
+func dialCurrent(ctx context.Context, sockPath string, urlPath string) (*daemon.Client, error) {
+	cl, err := daemon.DialPath(ctx, sockPath, urlPath)
+	if err != nil {
+		return nil, err
+	}
+	pong, err := cl.Ping(&daemon.PingArgs{ClientVersion: daemon.Version()})
+	if err == nil {
+		err = checkVersion(pong)
+	}
+	var old *outdatedError
+	if errors.As(err, &old) {
+		old.client = cl
+		return nil, old
+	}
+	if err != nil {
+		cl.Close()
+		return nil, err
+	}
+	return cl, nil
+}
+
+// replaceOutdated asks an outdated daemon to finish its in-flight
+// jobs and exit, and waits until it has released the socket.
+func replaceOutdated(ctx context.Context, sockPath string, old *outdatedError) error {
+	_, err := old.client.Shutdown(&daemon.ShutdownArgs{Drain: true})
+	old.client.Close()
+	if err != nil {
+		return fmt.Errorf("shutting down outdated daemon: %w", err)
+	}
+	lk := flock.New(sockPath + ".lock")
+	if _, err := lk.TryLockContext(ctx, 10*time.Millisecond); err != nil {
+		return fmt.Errorf("waiting for outdated daemon to exit: %w", err)
+	}
+	return lk.Unlock()
+}
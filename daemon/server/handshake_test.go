@@ -0,0 +1,108 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/rpc"
+	"path"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckVersion(t *testing.T) {
+	assert.NoError(t, checkVersion(&daemon.PingReply{
+		ProtocolVersion:    daemon.ProtocolVersion,
+		MinProtocolVersion: daemon.MinProtocolVersion,
+	}))
+	assert.NoError(t, checkVersion(&daemon.PingReply{
+		ProtocolVersion:    daemon.ProtocolVersion + 1,
+		MinProtocolVersion: daemon.ProtocolVersion,
+	}))
+
+	// Daemons from before the handshake don't report a version
+	err := checkVersion(&daemon.PingReply{ServerPid: 1})
+	var old *outdatedError
+	assert.True(t, errors.As(err, &old))
+
+	err = checkVersion(&daemon.PingReply{
+		DaemonVersion:      "v9.9.9",
+		ProtocolVersion:    daemon.ProtocolVersion + 2,
+		MinProtocolVersion: daemon.ProtocolVersion + 1,
+	})
+	assert.True(t, errors.Is(err, ErrDaemonTooNew))
+	assert.Contains(t, err.Error(), "v9.9.9")
+}
+
+type fakeDaemon struct {
+	pong     daemon.PingReply
+	shutdown chan daemon.ShutdownArgs
+}
+
+func (f *fakeDaemon) Ping(in daemon.PingArgs, out *daemon.PingReply) error {
+	*out = f.pong
+	return nil
+}
+
+func (f *fakeDaemon) Shutdown(in daemon.ShutdownArgs, out *daemon.ShutdownReply) error {
+	f.shutdown <- in
+	return nil
+}
+
+func serveFake(t *testing.T, f *fakeDaemon) string {
+	sock := path.Join(t.TempDir(), "llama.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv rpc.Server
+	if err := srv.RegisterName("Daemon", f); err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(l, &srv)
+	t.Cleanup(func() { l.Close() })
+	return sock
+}
+
+func TestDialCurrent(t *testing.T) {
+	ctx := context.Background()
+
+	current := &fakeDaemon{pong: daemon.PingReply{
+		ProtocolVersion:    daemon.ProtocolVersion,
+		MinProtocolVersion: daemon.MinProtocolVersion,
+	}}
+	cl, err := dialCurrent(ctx, serveFake(t, current), "/")
+	if assert.NoError(t, err) {
+		cl.Close()
+	}
+
+	// An outdated daemon is handed back so we can drain it
+	old := &fakeDaemon{
+		pong:     daemon.PingReply{ServerPid: 1},
+		shutdown: make(chan daemon.ShutdownArgs, 1),
+	}
+	sock := serveFake(t, old)
+	_, err = dialCurrent(ctx, sock, "/")
+	var outdated *outdatedError
+	if assert.True(t, errors.As(err, &outdated)) {
+		assert.NoError(t, replaceOutdated(ctx, sock, outdated))
+		assert.Equal(t, daemon.ShutdownArgs{Drain: true}, <-old.shutdown)
+	}
+}
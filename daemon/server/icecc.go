@@ -0,0 +1,314 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/icecc"
+	"github.com/nelhage/llama/files"
+)
+
+const (
+	// How often we report our load to the scheduler
+	iceccStatsInterval = 10 * time.Second
+	// How long we wait to reconnect after losing the scheduler
+	iceccReconnect = 10 * time.Second
+	// How long we give an icecream client to send its job, and
+	// then to read our reply
+	iceccIOTimeout = 5 * time.Minute
+	// The free memory, in megabytes, we report to the scheduler,
+	// which avoids nodes short on memory
+	iceccFreeMem = 1 << 20
+)
+
+// iceccNode joins an icecream scheduler as a compile server with
+// `capacity` slots, and compiles the jobs it routes to us on Lambda
+type iceccNode struct {
+	d         *Daemon
+	function  string
+	scheduler string
+	port      int
+	capacity  int
+	platform  string
+	extend    chan<- struct{}
+
+	running int32
+
+	mu    sync.Mutex
+	sched *icecc.Conn
+}
+
+// register keeps us logged in to the scheduler until `ctx` is done
+func (n *iceccNode) register(ctx context.Context) {
+	for {
+		err := n.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("icecc: scheduler %s: %s; reconnecting in %s", n.scheduler, err.Error(), iceccReconnect)
+		select {
+		case <-time.After(iceccReconnect):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *iceccNode) session(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.scheduler)
+	if err != nil {
+		return err
+	}
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-sessionCtx.Done()
+		conn.Close()
+	}()
+
+	c, err := icecc.Negotiate(conn)
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	if err := c.Send(&icecc.Login{
+		Port:           uint32(n.port),
+		MaxKids:        uint32(n.capacity),
+		NodeName:       "llama@" + hostname,
+		HostPlatform:   n.platform,
+		ChrootPossible: true,
+	}); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.sched = c
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		n.sched = nil
+		n.mu.Unlock()
+	}()
+	log.Printf("icecc: joined scheduler %s with %d slots", n.scheduler, n.capacity)
+
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			m, err := c.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, ok := m.(*icecc.Ping); ok {
+				if err := n.toScheduler(n.stats()); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(iceccStatsInterval)
+	defer ticker.Stop()
+	for {
+		if err := n.toScheduler(n.stats()); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// toScheduler sends `m` to the scheduler, if we're connected to one
+func (n *iceccNode) toScheduler(m icecc.Msg) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sched == nil {
+		return nil
+	}
+	return n.sched.Send(m)
+}
+
+func (n *iceccNode) stats() *icecc.Stats {
+	load := int(atomic.LoadInt32(&n.running)) * 1000 / n.capacity
+	if load > 1000 {
+		load = 1000
+	}
+	return &icecc.Stats{
+		Load:    uint32(load),
+		FreeMem: iceccFreeMem,
+	}
+}
+
+func (n *iceccNode) serve(ctx context.Context, l net.Listener) {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("icecc: accept: %s", err.Error())
+			}
+			return
+		}
+		go n.handle(ctx, conn)
+	}
+}
+
+// handle runs a single job. If we fail to run it, we close the
+// connection without a result, and the client compiles locally.
+func (n *iceccNode) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	select {
+	case n.extend <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+
+	conn.SetDeadline(time.Now().Add(iceccIOTimeout))
+	c, job, source, err := readIceccJob(conn)
+	if err != nil {
+		log.Printf("icecc: %s: reading job: %s", conn.RemoteAddr(), err.Error())
+		return
+	}
+
+	atomic.AddInt32(&n.running, 1)
+	defer atomic.AddInt32(&n.running, -1)
+	start := time.Now()
+	if err := n.toScheduler(&icecc.JobBegin{
+		JobID:     job.JobID,
+		StartTime: uint32(start.Unix()),
+	}); err != nil {
+		log.Printf("icecc: scheduler: %s", err.Error())
+	}
+	res, object, err := n.run(job, source)
+	done := icecc.JobDone{
+		JobID:           job.JobID,
+		ExitCode:        ^uint32(0),
+		RealMsec:        uint32(time.Since(start) / time.Millisecond),
+		InUncompressed:  uint32(len(source)),
+		OutUncompressed: uint32(len(object)),
+	}
+	if res != nil {
+		done.ExitCode = res.Status
+	}
+	if err := n.toScheduler(&done); err != nil {
+		log.Printf("icecc: scheduler: %s", err.Error())
+	}
+	if err != nil {
+		log.Printf("icecc: %s: %s", conn.RemoteAddr(), err.Error())
+		return
+	}
+
+	conn.SetDeadline(time.Now().Add(iceccIOTimeout))
+	err = c.Send(res)
+	if err == nil && res.Status == 0 {
+		err = c.SendFile(object)
+	}
+	if err != nil {
+		log.Printf("icecc: %s: writing result: %s", conn.RemoteAddr(), err.Error())
+	}
+}
+
+// readIceccJob reads a job from a client. Clients may first send the
+// compiler environment they'd like us to use; we compile with the
+// function's own toolchain, so we discard it.
+func readIceccJob(conn net.Conn) (*icecc.Conn, *icecc.CompileFile, []byte, error) {
+	c, err := icecc.Negotiate(conn)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for {
+		m, err := c.Recv()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		switch m := m.(type) {
+		case *icecc.TransferEnv:
+			if _, err := c.RecvFile(); err != nil {
+				return nil, nil, nil, fmt.Errorf("environment %s: %w", m.Name, err)
+			}
+		case *icecc.CompileFile:
+			source, err := c.RecvFile()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("source: %w", err)
+			}
+			return c, m, source, nil
+		default:
+			return nil, nil, nil, fmt.Errorf("unexpected message %c", m.Type())
+		}
+	}
+}
+
+func (n *iceccNode) run(job *icecc.CompileFile, source []byte) (*icecc.CompileResult, []byte, error) {
+	const output = "icecc.o"
+	argv, err := job.Command(output)
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := ioutil.TempDir("", "llama-icecc")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, output)
+
+	args := daemon.InvokeWithFilesArgs{
+		Function: n.function,
+		Args:     argv,
+		Stdin:    source,
+		Outputs: files.List{{
+			Local:  files.LocalFile{Path: local},
+			Remote: output,
+		}},
+	}
+	var reply daemon.InvokeWithFilesReply
+	if err := n.d.InvokeWithFiles(&args, &reply); err != nil {
+		return nil, nil, err
+	}
+	if reply.InvokeErr != "" {
+		return nil, nil, fmt.Errorf("invoke: %s", reply.InvokeErr)
+	}
+	res := icecc.CompileResult{
+		Status: uint32(reply.ExitStatus),
+		Stdout: string(reply.Stdout),
+		Stderr: string(reply.Stderr),
+	}
+	var object []byte
+	if res.Status == 0 {
+		if object, err = ioutil.ReadFile(local); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &res, object, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/nelhage/llama/daemon/icecc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIceccRegister(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	node := iceccNode{
+		scheduler: l.Addr().String(),
+		port:      icecc.DefaultPort,
+		capacity:  100,
+		platform:  "x86_64",
+		running:   25,
+	}
+	go node.register(ctx)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	sched, err := icecc.Negotiate(conn)
+	require.NoError(t, err)
+
+	m, err := sched.Recv()
+	require.NoError(t, err)
+	login, ok := m.(*icecc.Login)
+	require.True(t, ok, "got %#v", m)
+	assert.Equal(t, uint32(icecc.DefaultPort), login.Port)
+	assert.Equal(t, uint32(100), login.MaxKids)
+	assert.Equal(t, "x86_64", login.HostPlatform)
+
+	m, err = sched.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, &icecc.Stats{Load: 250, FreeMem: iceccFreeMem}, m)
+
+	// We answer pings with our load
+	require.NoError(t, sched.Send(&icecc.Ping{}))
+	m, err = sched.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, &icecc.Stats{Load: 250, FreeMem: iceccFreeMem}, m)
+}
@@ -0,0 +1,343 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+)
+
+var errComputedInclude = errors.New("computed #include")
+
+// includeIndex is the daemon's include server. It approximates the
+// set of headers a translation unit depends on by following
+// `#include` directives textually, in the style of distcc's "pump"
+// mode, without running the preprocessor. It does not evaluate
+// conditionals, and so may over-approximate. Headers that cannot be
+// found in any user-specified directory are assumed to be system
+// headers, which are present in the remote image.
+//
+// Because the daemon outlives any one compiler invocation, the index
+// remembers the `#include` directives in every file it has parsed,
+// and the transitive closure -- a "bundle" -- of every header
+// directly included by a translation unit. Translation units that
+// include the same headers under the same search path share the
+// bundles, and nothing is re-read until it changes on disk.
+type includeIndex struct {
+	mu      sync.Mutex
+	files   map[string]*parsedFile
+	bundles map[bundleKey]*bundle
+
+	// How many files we have read and parsed, and how many
+	// bundles we have reused, for tests and debugging
+	parsed int64
+	reused int64
+}
+
+type fileStamp struct {
+	size  int64
+	mtime time.Time
+}
+
+func stampOf(st os.FileInfo) fileStamp {
+	return fileStamp{size: st.Size(), mtime: st.ModTime()}
+}
+
+type includeDirective struct {
+	name   string
+	quoted bool
+}
+
+type parsedFile struct {
+	stamp    fileStamp
+	includes []includeDirective
+	err      error
+}
+
+type searchPath struct {
+	quote   []string
+	bracket []string
+}
+
+func (s *searchPath) key() string {
+	return strings.Join(s.quote, "\x00") + "\x01" + strings.Join(s.bracket, "\x00")
+}
+
+type bundleKey struct {
+	header string
+	search string
+}
+
+// A bundle is the closure of a header. To tell when it's stale, we
+// remember the state of every file in it, and every path we looked
+// for a header in and didn't find: if one of those is created, it
+// might shadow a header we did find.
+type bundle struct {
+	files  []string
+	stamps []fileStamp
+	misses []string
+}
+
+func (b *bundle) valid() bool {
+	for i, file := range b.files {
+		st, err := os.Stat(file)
+		if err != nil || stampOf(st) != b.stamps[i] {
+			return false
+		}
+	}
+	for _, miss := range b.misses {
+		if _, err := os.Stat(miss); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func newIncludeIndex() *includeIndex {
+	return &includeIndex{
+		files:   make(map[string]*parsedFile),
+		bundles: make(map[bundleKey]*bundle),
+	}
+}
+
+// parse returns the `#include` directives in `file`, reading it only
+// if it has changed since we last did.
+func (idx *includeIndex) parse(file string) (*parsedFile, error) {
+	st, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	stamp := stampOf(st)
+	idx.mu.Lock()
+	ent, ok := idx.files[file]
+	idx.mu.Unlock()
+	if ok && ent.stamp == stamp {
+		return ent, nil
+	}
+
+	ent = &parsedFile{stamp: stamp}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	scan.Buffer(nil, len(data)+1)
+	for scan.Scan() {
+		name, quoted, ok, err := parseIncludeLine(scan.Bytes())
+		if err != nil {
+			ent.err = fmt.Errorf("%s: %w", file, err)
+			break
+		}
+		if ok {
+			ent.includes = append(ent.includes, includeDirective{name: name, quoted: quoted})
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	idx.files[file] = ent
+	idx.parsed++
+	idx.mu.Unlock()
+	return ent, nil
+}
+
+// closure accumulates the transitive closure of a set of files
+type closure struct {
+	idx    *includeIndex
+	search *searchPath
+	seen   map[string]struct{}
+	bundle bundle
+}
+
+func (c *closure) resolve(from string, inc includeDirective) string {
+	dirs := c.search.bracket
+	if inc.quoted {
+		dirs = append([]string{filepath.Dir(from)}, c.search.quote...)
+	}
+	if filepath.IsAbs(inc.name) {
+		dirs = []string{""}
+	}
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, inc.name)
+		if st, err := os.Stat(candidate); err == nil && st.Mode().IsRegular() {
+			return candidate
+		}
+		c.bundle.misses = append(c.bundle.misses, candidate)
+	}
+	return ""
+}
+
+func (c *closure) visit(file string) error {
+	file = filepath.Clean(file)
+	if _, ok := c.seen[file]; ok {
+		return nil
+	}
+	c.seen[file] = struct{}{}
+	ent, err := c.idx.parse(file)
+	if err != nil {
+		return err
+	}
+	if ent.err != nil {
+		return ent.err
+	}
+	c.bundle.files = append(c.bundle.files, file)
+	c.bundle.stamps = append(c.bundle.stamps, ent.stamp)
+	for _, inc := range ent.includes {
+		if found := c.resolve(file, inc); found != "" {
+			if err := c.visit(found); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bundleFor returns the closure of `header` under `search`, reusing
+// a previous result if nothing in it has changed.
+func (idx *includeIndex) bundleFor(header string, search *searchPath) (*bundle, error) {
+	key := bundleKey{header: header, search: search.key()}
+	idx.mu.Lock()
+	b, ok := idx.bundles[key]
+	idx.mu.Unlock()
+	if ok && b.valid() {
+		idx.mu.Lock()
+		idx.reused++
+		idx.mu.Unlock()
+		return b, nil
+	}
+
+	c := closure{idx: idx, search: search, seen: make(map[string]struct{})}
+	if err := c.visit(header); err != nil {
+		return nil, err
+	}
+	b = &c.bundle
+	idx.mu.Lock()
+	idx.bundles[key] = b
+	idx.mu.Unlock()
+	return b, nil
+}
+
+// scan returns `inputs` and every header they may (transitively)
+// include.
+func (idx *includeIndex) scan(inputs []string, search *searchPath) ([]string, error) {
+	var deps []string
+	seen := make(map[string]struct{})
+	add := func(file string) {
+		if _, ok := seen[file]; !ok {
+			seen[file] = struct{}{}
+			deps = append(deps, file)
+		}
+	}
+	// Translation units are rarely shared, so we don't bundle
+	// them, just the headers they include.
+	c := closure{search: search}
+	for _, input := range inputs {
+		input = filepath.Clean(input)
+		ent, err := idx.parse(input)
+		if err != nil {
+			return nil, err
+		}
+		if ent.err != nil {
+			return nil, ent.err
+		}
+		add(input)
+		for _, inc := range ent.includes {
+			found := c.resolve(input, inc)
+			if found == "" {
+				continue
+			}
+			b, err := idx.bundleFor(filepath.Clean(found), search)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range b.files {
+				add(file)
+			}
+		}
+	}
+	return deps, nil
+}
+
+func (d *Daemon) ScanIncludes(in *daemon.ScanIncludesArgs, out *daemon.ScanIncludesReply) error {
+	for _, p := range append(append(append([]string(nil), in.Inputs...), in.Quote...), in.Bracket...) {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("ScanIncludes: path %q is not absolute", p)
+		}
+	}
+	deps, err := d.includes.scan(in.Inputs, &searchPath{quote: in.Quote, bracket: in.Bracket})
+	if err != nil {
+		return err
+	}
+	out.Deps = deps
+	return nil
+}
+
+var includeDirectives = [][]byte{
+	[]byte("include_next"),
+	[]byte("include"),
+	[]byte("import"),
+}
+
+// parseIncludeLine recognizes a line of the form `#include "file"` or
+// `#include <file>`, returning the file named and whether it was
+// quoted.
+func parseIncludeLine(line []byte) (string, bool, bool, error) {
+	line = bytes.TrimLeft(line, " \t")
+	if len(line) == 0 || line[0] != '#' {
+		return "", false, false, nil
+	}
+	line = bytes.TrimLeft(line[1:], " \t")
+	matched := false
+	for _, dir := range includeDirectives {
+		if bytes.HasPrefix(line, dir) {
+			line = line[len(dir):]
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return "", false, false, nil
+	}
+	line = bytes.TrimLeft(line, " \t")
+	if len(line) == 0 {
+		return "", false, false, errComputedInclude
+	}
+	var end byte
+	switch line[0] {
+	case '"':
+		end = '"'
+	case '<':
+		end = '>'
+	default:
+		return "", false, false, errComputedInclude
+	}
+	close := bytes.IndexByte(line[1:], end)
+	if close < 0 {
+		return "", false, false, errComputedInclude
+	}
+	return string(line[1 : close+1]), end == '"', true, nil
+}
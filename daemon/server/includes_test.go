@@ -0,0 +1,134 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, dir, name, body string) {
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+	require.NoError(t, ioutil.WriteFile(p, []byte(body), 0644))
+}
+
+func TestScanIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) { writeTestFile(t, dir, name, body) }
+	write("main.c", `#include "local.h"
+  #  include <lib/api.h>
+#include <stdio.h>
+#ifdef NEVER
+#include "missing.h"
+#endif
+`)
+	write("local.h", `#pragma once
+#include "local.h"
+`)
+	write("inc/lib/api.h", `#include_next <lib/types.h>
+`)
+	write("inc/lib/types.h", "")
+	write("computed.c", `#include HEADER
+`)
+
+	idx := newIncludeIndex()
+	inc := filepath.Join(dir, "inc")
+	search := &searchPath{quote: []string{inc}, bracket: []string{inc}}
+	deps, err := idx.scan([]string{filepath.Join(dir, "main.c")}, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "main.c"),
+		filepath.Join(dir, "local.h"),
+		filepath.Join(dir, "inc/lib/api.h"),
+		filepath.Join(dir, "inc/lib/types.h"),
+	}, deps)
+
+	_, err = idx.scan([]string{filepath.Join(dir, "computed.c")}, search)
+	assert.True(t, errors.Is(err, errComputedInclude))
+}
+
+func TestIncludeIndexReuse(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) { writeTestFile(t, dir, name, body) }
+	write("a.c", `#include "common.h"
+`)
+	write("b.c", `#include "common.h"
+#include <gen/config.h>
+`)
+	write("common.h", `#include <util.h>
+`)
+	write("inc/util.h", "")
+
+	idx := newIncludeIndex()
+	inc := filepath.Join(dir, "inc")
+	gen := filepath.Join(dir, "build")
+	search := &searchPath{quote: []string{gen, inc}, bracket: []string{gen, inc}}
+
+	deps, err := idx.scan([]string{filepath.Join(dir, "a.c")}, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.c"),
+		filepath.Join(dir, "common.h"),
+		filepath.Join(dir, "inc/util.h"),
+	}, deps)
+	assert.Equal(t, int64(3), idx.parsed)
+
+	// b.c reuses common.h's bundle without reading anything
+	// but b.c itself
+	deps, err = idx.scan([]string{filepath.Join(dir, "b.c")}, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "b.c"),
+		filepath.Join(dir, "common.h"),
+		filepath.Join(dir, "inc/util.h"),
+	}, deps)
+	assert.Equal(t, int64(4), idx.parsed)
+	assert.Equal(t, int64(1), idx.reused)
+
+	// A generated header that shadows one we found invalidates
+	// the bundle
+	write("build/util.h", "")
+	deps, err = idx.scan([]string{filepath.Join(dir, "a.c")}, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.c"),
+		filepath.Join(dir, "common.h"),
+		filepath.Join(dir, "build/util.h"),
+	}, deps)
+
+	// So does editing a header
+	write("common.h", `#include <util.h>
+#include "extra.h"
+`)
+	write("extra.h", "")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "common.h"), future, future))
+	deps, err = idx.scan([]string{filepath.Join(dir, "a.c")}, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.c"),
+		filepath.Join(dir, "common.h"),
+		filepath.Join(dir, "build/util.h"),
+		filepath.Join(dir, "extra.h"),
+	}, deps)
+}
@@ -16,18 +16,23 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/nelhage/llama/daemon"
 	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/logging"
 	"github.com/nelhage/llama/protocol"
 	"github.com/nelhage/llama/protocol/files"
 	"github.com/nelhage/llama/store"
@@ -36,22 +41,72 @@ import (
 
 func (d *Daemon) Ping(in daemon.PingArgs, reply *daemon.PingReply) error {
 	*reply = daemon.PingReply{
-		ServerPid: os.Getpid(),
+		ServerPid:          os.Getpid(),
+		DaemonVersion:      daemon.Version(),
+		ProtocolVersion:    daemon.ProtocolVersion,
+		MinProtocolVersion: daemon.MinProtocolVersion,
+	}
+	if in.ClientVersion != "" && in.ClientVersion != reply.DaemonVersion {
+		logging.Record(d.ctx, "client version mismatch",
+			"client_version", in.ClientVersion,
+			"daemon_version", reply.DaemonVersion,
+		)
 	}
 	return nil
 }
 
 func (d *Daemon) Shutdown(in daemon.ShutdownArgs, out *daemon.ShutdownReply) error {
-	d.shutdown()
 	*out = daemon.ShutdownReply{}
+	if in.Drain {
+		out.Abandoned = d.drain(in.DrainTimeout)
+	}
+	d.shutdown()
 	return nil
 }
 
+func newInvocationID() string {
+	var id [8]byte
+	if _, err := rand.Reader.Read(id[:]); err != nil {
+		panic(fmt.Sprintf("rand: %s", err.Error()))
+	}
+	return hex.EncodeToString(id[:])
+}
+
 func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.InvokeWithFilesReply) error {
+	return d.invokeWithFiles(context.Background(), in, out)
+}
+
+// invokeWithFiles implements InvokeWithFiles, additionally cancelling
+// the invocation if `reqCtx` is done before it commits to writing its
+// outputs. The invocation itself runs in the daemon's context.
+func (d *Daemon) invokeWithFiles(reqCtx context.Context, in *daemon.InvokeWithFilesArgs, out *daemon.InvokeWithFilesReply) (err error) {
 	ctx := d.ctx
 	ctx, sb := tracing.StartPropagatedSpan(ctx, "InvokeWithFiles", in.Trace)
 	defer sb.End()
 	sb.AddField("function", in.Function)
+	invocationID := newInvocationID()
+	sb.AddField("invocation_id", invocationID)
+	ctx = logging.WithFields(ctx, "invocation_id", invocationID, "function", in.Function)
+
+	if err := d.drainer.enter(); err != nil {
+		return err
+	}
+	defer d.drainer.exit()
+
+	var cancel *cancellable
+	if in.CancelID != "" {
+		var done func()
+		ctx, cancel, done = d.cancels.register(ctx, in.CancelID)
+		defer done()
+	}
+	if reqCtx.Done() != nil {
+		if cancel == nil {
+			var done func()
+			ctx, cancel, done = d.cancels.register(ctx, invocationID)
+			defer done()
+		}
+		defer d.cancels.cancelWhenDone(reqCtx, cancel)()
+	}
 
 	if in.DropSemaphore {
 		d.releaseSem()
@@ -61,12 +116,55 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 	atomic.AddUint64(&d.stats.Invocations, 1)
 	inflight := atomic.AddUint64(&d.stats.InFlight, 1)
 	sb.AddField("inflight", float64(inflight))
-	if len(in.Outputs) > 0 && in.Outputs[0].Local.Path != "" {
-		sb.AddField("output", in.Outputs[0].Local.Path)
-	}
+	var desc string
 	if len(in.Files) > 0 && in.Files[0].Local.Path != "" {
 		sb.AddField("file", in.Files[0].Local.Path)
+		desc = in.Files[0].Local.Path
 	}
+	if len(in.Outputs) > 0 && in.Outputs[0].Local.Path != "" {
+		sb.AddField("output", in.Outputs[0].Local.Path)
+		desc = in.Outputs[0].Local.Path
+	}
+	statusId := d.status.start(in.Function, desc, invocationID)
+	started := time.Now()
+	defer func() {
+		var failure string
+		switch {
+		case cancel != nil && d.cancels.isCancelled(cancel):
+			// The client no longer wanted the result
+		case err != nil:
+			failure = err.Error()
+		case out.InvokeErr != "":
+			failure = out.InvokeErr
+		case out.ExitStatus != 0:
+			failure = fmt.Sprintf("exit status %d", out.ExitStatus)
+		}
+		d.status.finish(statusId, failure)
+		if in.Build != "" {
+			d.builds.record(in.Build, daemon.BuildInvocation{
+				Description: desc,
+				E2E:         time.Since(started),
+				Remote:      out.Timing.Remote.Exec,
+				Cached:      out.Cached,
+			}, in.UseCache, failure != "")
+		}
+		if err != nil {
+			logging.Record(ctx, "invocation failed", "error", err)
+		} else {
+			logging.Record(ctx, "invocation",
+				"exit_status", out.ExitStatus,
+				"invoke_error", out.InvokeErr,
+				"cached", out.Cached,
+				"cold_start", out.Timing.Remote.ColdStart,
+				"e2e", out.Timing.E2E,
+				"upload", out.Timing.Upload,
+				"queue", out.Timing.Queue,
+				"invoke", out.Timing.Invoke,
+				"fetch", out.Timing.Fetch,
+				"remote_exec", out.Timing.Remote.Exec,
+			)
+		}
+	}()
 	defer atomic.AddUint64(&d.stats.InFlight, ^uint64(0))
 	for {
 		oldmax := atomic.LoadUint64(&d.stats.MaxInFlight)
@@ -79,7 +177,13 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 	}
 
 	for _, f := range in.Files {
-		if f.Local.Path != "" && !path.IsAbs(f.Local.Path) {
+		if f.Local.Path != "" && !filepath.IsAbs(f.Local.Path) {
+			return fmt.Errorf("must pass absolute path: %s", f.Local.Path)
+		}
+	}
+
+	for _, f := range in.Trees {
+		if !filepath.IsAbs(f.Local.Path) {
 			return fmt.Errorf("must pass absolute path: %s", f.Local.Path)
 		}
 	}
@@ -88,32 +192,75 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 		if f.Local.Path == "" {
 			return fmt.Errorf("file %q: must have local path", f.Remote)
 		}
-		if !path.IsAbs(f.Local.Path) {
+		if !filepath.IsAbs(f.Local.Path) {
 			return fmt.Errorf("must pass absolute path: %s", f.Local.Path)
 		}
 	}
 
+	if in.ChangesDir != "" && !filepath.IsAbs(in.ChangesDir) {
+		return fmt.Errorf("must pass absolute path: %s", in.ChangesDir)
+	}
+
+	cache := d.cache.Override(in.Cache)
+	if err := cache.Validate(); err != nil {
+		return err
+	}
+
 	args := llama.InvokeArgs{
 		Function:   in.Function,
 		ReturnLogs: in.ReturnLogs,
+		Idempotent: in.Idempotent,
 		Spec: protocol.InvocationSpec{
-			Args: in.Args,
+			Args:      in.Args,
+			Env:       in.Env,
+			Stream:    in.Stream,
+			TimeLimit: in.TimeLimit,
+
+			InvocationID: invocationID,
 		},
 	}
 
+	_, isLocal := d.local[in.Function]
+	profiled := in.Profile != "" && d.profiles != nil && !isLocal
+	memory := in.Memory
+	if profiled && memory == 0 {
+		memory = d.profiledMemory(ctx, in.Function, in.Profile)
+		if memory != 0 {
+			sb.AddField("profile_memory", memory)
+		}
+	}
+	variant, err := d.pickVariant(in.Function, memory, in.Timeout)
+	if err != nil && memory != in.Memory {
+		logging.Printf(ctx, "sizing %s: %s", in.Profile, err.Error())
+		variant, err = d.pickVariant(in.Function, in.Memory, in.Timeout)
+	}
+	if err != nil {
+		return err
+	}
+	if variant.Function != "" {
+		args.Function = variant.Function
+	}
+
 	t_start := time.Now()
 
 	{
 		ctx, sb := tracing.StartSpan(ctx, "upload")
 		sb.AddField("files", len(in.Files))
 		var err error
-		args.Spec.Files, err = in.Files.Upload(ctx, d.store, nil)
+		args.Spec.Files, err = in.Files.UploadInline(ctx, d.store, nil, d.inline)
 		if err != nil {
 			sb.AddField("error", fmt.Sprintf("upload: %s", err.Error()))
 			return err
 		}
-		if in.Stdin != nil {
-			args.Spec.Stdin, err = files.NewBlob(ctx, d.store, in.Stdin)
+		args.Spec.Trees, err = in.Trees.UploadTrees(ctx, d.store, d.trees, in.Ignore)
+		if err != nil {
+			sb.AddField("error", fmt.Sprintf("upload: %s", err.Error()))
+			return err
+		}
+		if in.StdinBlob != nil {
+			args.Spec.Stdin = in.StdinBlob
+		} else if in.Stdin != nil {
+			args.Spec.Stdin, err = files.NewBlobInline(ctx, d.store, in.Stdin, d.inline.Limit)
 			if err != nil {
 				sb.AddField("error", fmt.Sprintf("stdin: %s", err.Error()))
 				return err
@@ -122,45 +269,88 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 		for _, out := range in.Outputs {
 			args.Spec.Outputs = append(args.Spec.Outputs, out.Remote)
 		}
+		args.Spec.InlineOutputs = d.inline.Limit
+		args.Spec.OutputChanges = in.ChangesDir != ""
 		sb.End()
 	}
 
 	t_invoke := time.Now()
 
-	atomic.AddUint64(&d.stats.Usage.Lambda_Requests, 1)
-	repl, invokeErr := llama.Invoke(ctx, d.lambda, d.store, &args)
-	if invokeErr != nil {
-		sb.AddField("error", fmt.Sprintf("invoke: %s", invokeErr.Error()))
-		if _, ok := invokeErr.(*llama.ErrorReturn); ok {
-			atomic.AddUint64(&d.stats.FunctionErrors, 1)
+	var cacheHash string
+	var repl *llama.InvokeResult
+	var invokeErr error
+	var queued time.Duration
+	if in.UseCache {
+		cacheHash = d.resultCacheHash(ctx, &args)
+	}
+	if cacheHash != "" && cache.Readable() {
+		if cached := d.lookupResult(ctx, cache.LookupKeys(cacheHash)); cached != nil {
+			atomic.AddUint64(&d.stats.CacheHits, 1)
+			repl = &llama.InvokeResult{Response: *cached}
 		} else {
-			atomic.AddUint64(&d.stats.OtherErrors, 1)
+			atomic.AddUint64(&d.stats.CacheMisses, 1)
 		}
 	}
 
+	cached := repl != nil
+	sb.AddField("cached", cached)
+	if !cached {
+		var region string
+		repl, region, queued, invokeErr = d.invokeScheduled(ctx, &args, in.Priority)
+		sb.AddField("region", region)
+		d.status.invoked(statusId, region, llama.RequestID(repl, invokeErr))
+		if profiled {
+			d.recordProfile(ctx, in.Function, in.Profile, variant, repl, invokeErr)
+		}
+	}
+	if invokeErr != nil {
+		sb.AddField("error", fmt.Sprintf("invoke: %s", invokeErr.Error()))
+	}
+
 	if invokeErr != nil && repl == nil {
 		return invokeErr
 	}
 
+	if !cached && cacheHash != "" && cache.Writable() && invokeErr == nil && repl.Response.ExitStatus == 0 {
+		d.storeResult(ctx, daemon.ResultKey(cache.Namespace, cacheHash), &repl.Response)
+	}
+
 	t_fetch := time.Now()
 
-	atomic.AddUint64(&d.stats.ExitStatuses[repl.Response.ExitStatus&0xff], 1)
-	atomic.AddUint64(&d.stats.Usage.Lambda_MB_Millis, repl.Response.Usage.Lambda_MB_Millis)
-	atomic.AddUint64(&d.stats.Usage.Lambda_Millis, repl.Response.Usage.Lambda_Millis)
-	atomic.AddUint64(&d.stats.Usage.S3_Read_Requests, repl.Response.Usage.S3_Read_Requests)
-	atomic.AddUint64(&d.stats.Usage.S3_Write_Requests, repl.Response.Usage.S3_Write_Requests)
-	atomic.AddUint64(&d.stats.Usage.S3_Xfer_In, repl.Response.Usage.S3_Xfer_In)
+	d.recordResponse(&repl.Response)
 
-	// Transfer out from S3 to EC2 is free, so we deliberately do
-	// _not_ accumulate S3_Xfer_Out here.
+	if cancel != nil && !d.cancels.commit(cancel) {
+		return errCancelled
+	}
 
 	var gets []store.GetRequest
 
-	var fetchList, extra protocol.FileList
+	var fetchList, extra, direct, refs protocol.FileList
+	var changed []string
 	if repl.Response.Outputs != nil {
 		fetchList, extra = in.Outputs.TransformToLocal(ctx, repl.Response.Outputs)
+		if in.ChangesDir != "" {
+			var changes protocol.FileList
+			changes, changed, extra = localChanges(in.ChangesDir, extra)
+			for _, f := range changes {
+				// The command may have created new directories
+				os.MkdirAll(filepath.Dir(f.Path), 0755)
+			}
+			fetchList = append(fetchList, changes...)
+		}
 		for _, out := range extra {
-			log.Printf("Remote returned unexpected output: %s", out.Path)
+			logging.Printf(ctx, "Remote returned unexpected output: %s", out.Path)
+		}
+		if len(in.RefOutputs) > 0 {
+			fetchList, refs = splitRefs(fetchList, in.RefOutputs)
+		}
+		if _, ok := d.store.(store.Presigner); ok && in.DirectOutputs {
+			fetchList, direct = splitDirect(fetchList)
+		}
+		if d.skipChecksums {
+			files.SkipChecksums(fetchList)
+			files.SkipChecksums(refs)
+			files.SkipChecksums(direct)
 		}
 		for _, f := range fetchList {
 			gets = files.AppendGet(gets, &f.Blob)
@@ -170,6 +360,11 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 	*out = daemon.InvokeWithFilesReply{
 		Logs:       repl.Logs,
 		ExitStatus: repl.Response.ExitStatus,
+		Cached:     cached,
+
+		InvocationID:  invocationID,
+		DirectOutputs: direct,
+		Changed:       changed,
 	}
 	if invokeErr != nil {
 		out.InvokeErr = invokeErr.Error()
@@ -188,10 +383,20 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 	for _, f := range fetchList {
 		var err error
 		err, gets = files.FetchFile(&f.File, f.Path, gets)
+		var sumErr *files.ChecksumError
+		if errors.As(err, &sumErr) {
+			atomic.AddUint64(&d.stats.ChecksumMismatches, 1)
+			logging.Printf(ctx, "fetching outputs: %s", err.Error())
+		}
 		if err != nil && out.InvokeErr == "" {
 			out.InvokeErr = err.Error()
 		}
 	}
+	for _, f := range refs {
+		if err := files.WriteRef(f.Path, &f.File); err != nil && out.InvokeErr == "" {
+			out.InvokeErr = err.Error()
+		}
+	}
 
 	if repl.Response.Stdout != nil {
 		out.Stdout, _, gets = files.ReadBlob(repl.Response.Stdout, gets)
@@ -205,11 +410,13 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 
 	out.Timing.Remote = repl.Response.Times
 	out.Timing.Upload = t_invoke.Sub(t_start)
-	out.Timing.Invoke = t_fetch.Sub(t_invoke)
+	out.Timing.Queue = queued
+	out.Timing.Invoke = t_fetch.Sub(t_invoke) - queued
 	out.Timing.Fetch = t_end.Sub(t_fetch)
 	out.Timing.E2E = t_end.Sub(t_start)
 
 	sb.AddField("upload_ms", out.Timing.Upload.Milliseconds())
+	sb.AddField("queue_ms", out.Timing.Queue.Milliseconds())
 	sb.AddField("invoke_ms", out.Timing.Invoke.Milliseconds())
 	sb.AddField("fetch_ms", out.Timing.Fetch.Milliseconds())
 	sb.AddField("e2e_ms", out.Timing.E2E.Milliseconds())
@@ -217,8 +424,133 @@ func (d *Daemon) InvokeWithFiles(in *daemon.InvokeWithFilesArgs, out *daemon.Inv
 	return nil
 }
 
+// Invoke invokes a function on a spec the caller has already
+// uploaded. It lets clients that manage their own files, like `llama
+// xargs`, share the daemon's scheduler, budget, and retries.
+func (d *Daemon) Invoke(in *daemon.InvokeArgs, out *daemon.InvokeReply) (err error) {
+	ctx, sb := tracing.StartPropagatedSpan(d.ctx, "Invoke", in.Trace)
+	defer sb.End()
+	sb.AddField("function", in.Function)
+	sb.AddField("priority", in.Priority.String())
+
+	if err := d.drainer.enter(); err != nil {
+		return err
+	}
+	defer d.drainer.exit()
+
+	atomic.AddUint64(&d.stats.Invocations, 1)
+	inflight := atomic.AddUint64(&d.stats.InFlight, 1)
+	defer atomic.AddUint64(&d.stats.InFlight, ^uint64(0))
+	sb.AddField("inflight", float64(inflight))
+
+	statusId := d.status.start(in.Function, strings.Join(in.Spec.Args, " "), "")
+	defer func() {
+		var failure string
+		switch {
+		case err != nil:
+			failure = err.Error()
+		case out.FunctionErr != nil:
+			failure = fmt.Sprintf("function error: %q", out.FunctionErr)
+		case out.Response.ExitStatus != 0:
+			failure = fmt.Sprintf("exit status %d", out.Response.ExitStatus)
+		}
+		d.status.finish(statusId, failure)
+	}()
+
+	args := llama.InvokeArgs{
+		Function:   in.Function,
+		Qualifier:  in.Qualifier,
+		ReturnLogs: in.ReturnLogs,
+		Spec:       in.Spec,
+	}
+	repl, region, _, err := d.invokeScheduled(ctx, &args, in.Priority)
+	sb.AddField("region", region)
+	d.status.invoked(statusId, region, llama.RequestID(repl, err))
+	if err != nil {
+		sb.AddField("error", fmt.Sprintf("invoke: %s", err.Error()))
+		if ret, ok := err.(*llama.ErrorReturn); ok {
+			*out = daemon.InvokeReply{FunctionErr: ret.Payload, Logs: ret.Logs}
+			return nil
+		}
+		return err
+	}
+	d.recordResponse(&repl.Response)
+	*out = daemon.InvokeReply{Response: repl.Response, Logs: repl.Logs}
+	return nil
+}
+
+func (d *Daemon) CancelInvocation(in *daemon.CancelInvocationArgs, out *daemon.CancelInvocationReply) error {
+	*out = daemon.CancelInvocationReply{Cancelled: d.cancels.cancel(in.CancelID)}
+	return nil
+}
+
+func (d *Daemon) BeginBuild(in *daemon.BeginBuildArgs, out *daemon.BeginBuildReply) error {
+	if in.Build == "" {
+		return errors.New("BeginBuild: must name a build")
+	}
+	d.builds.begin(in.Build)
+	*out = daemon.BeginBuildReply{}
+	return nil
+}
+
+func (d *Daemon) EndBuild(in *daemon.EndBuildArgs, out *daemon.EndBuildReply) error {
+	report, found := d.builds.end(in.Build)
+	*out = daemon.EndBuildReply{Found: found, Report: report}
+	return nil
+}
+
+// invokeScheduled invokes a function once the scheduler and the
+// budget allow it, retrying transient failures. It also returns how
+// long the invocation waited to be admitted.
+func (d *Daemon) invokeScheduled(ctx context.Context, args *llama.InvokeArgs, prio daemon.Priority) (*llama.InvokeResult, string, time.Duration, error) {
+	t_queue := time.Now()
+	if err := d.sched.acquire(ctx, prio); err != nil {
+		return nil, "", 0, err
+	}
+	defer d.sched.release(prio)
+	release, err := d.admit(ctx)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer release()
+	queued := time.Since(t_queue)
+
+	var repl *llama.InvokeResult
+	var region string
+	err = d.retry.Do(ctx, func(attempt int) error {
+		if attempt > 0 {
+			atomic.AddUint64(&d.stats.Retries, 1)
+		}
+		var err error
+		repl, region, err = d.invoke(ctx, args)
+		return err
+	})
+	if err != nil {
+		if _, ok := err.(*llama.ErrorReturn); ok {
+			atomic.AddUint64(&d.stats.FunctionErrors, 1)
+		} else {
+			atomic.AddUint64(&d.stats.OtherErrors, 1)
+		}
+	}
+	return repl, region, queued, err
+}
+
+// recordResponse adds an invocation's exit status and usage to our
+// statistics
+func (d *Daemon) recordResponse(resp *protocol.InvocationResponse) {
+	atomic.AddUint64(&d.stats.ExitStatuses[resp.ExitStatus&0xff], 1)
+	atomic.AddUint64(&d.stats.Usage.Lambda_MB_Millis, resp.Usage.Lambda_MB_Millis)
+	atomic.AddUint64(&d.stats.Usage.Lambda_Millis, resp.Usage.Lambda_Millis)
+	atomic.AddUint64(&d.stats.Usage.S3_Read_Requests, resp.Usage.S3_Read_Requests)
+	atomic.AddUint64(&d.stats.Usage.S3_Write_Requests, resp.Usage.S3_Write_Requests)
+	atomic.AddUint64(&d.stats.Usage.S3_Xfer_In, resp.Usage.S3_Xfer_In)
+
+	// Transfer out from S3 to EC2 is free, so we deliberately do
+	// _not_ accumulate S3_Xfer_Out here.
+}
+
 func (d *Daemon) GetDaemonStats(in *daemon.StatsArgs, out *daemon.StatsReply) error {
-	d.store.FetchAWSUsage(&d.stats.Usage)
+	cost := d.sessionCost()
 
 	// TODO: We should really read this a field-at-a-time
 	// using `atomic.LoadUint64`, although I don't believe
@@ -227,9 +559,12 @@ func (d *Daemon) GetDaemonStats(in *daemon.StatsArgs, out *daemon.StatsReply) er
 	// snapshot of the entire stats struct. We could just
 	// use a mutex, I guess.
 	stats := d.stats
+	stats.ConcurrencyLimit = d.sched.limit()
 
 	*out = daemon.StatsReply{
-		Stats: stats,
+		Stats:  stats,
+		Cost:   cost,
+		Budget: d.budget.limits,
 	}
 	if in.Reset {
 		d.stats = daemon.Stats{}
@@ -243,6 +578,35 @@ func (d *Daemon) TraceSpans(in *daemon.TraceSpansArgs, out *daemon.TraceSpansRep
 	return nil
 }
 
+func (d *Daemon) ReadStream(in *daemon.ReadStreamArgs, out *daemon.ReadStreamReply) error {
+	*out = daemon.ReadStreamReply{}
+	kv, ok := d.store.(store.KeyValue)
+	if !ok {
+		return errors.New("store does not support streaming")
+	}
+	data, err := kv.GetKey(d.ctx, protocol.StreamChunkKey(in.Stream, in.Seq))
+	if err == store.ErrNotExists {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var chunk protocol.StreamChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return err
+	}
+	out.Found = true
+	out.Stdout = chunk.Stdout
+	out.Stderr = chunk.Stderr
+	return nil
+}
+
+func (d *Daemon) RecordFallback(in *daemon.RecordFallbackArgs, out *daemon.RecordFallbackReply) error {
+	atomic.AddUint64(&d.stats.LocalFallbacks, 1)
+	*out = daemon.RecordFallbackReply{}
+	return nil
+}
+
 func (d *Daemon) GetCompilerIncludePath(in *daemon.GetCompilerIncludePathArgs, out *daemon.GetCompilerIncludePathReply) error {
 	key := compilerAndLanguage{compiler: in.Compiler, language: in.Language}
 	d.includePathCache.RLock()
@@ -270,10 +634,37 @@ func (d *Daemon) GetCompilerIncludePath(in *daemon.GetCompilerIncludePathArgs, o
 	return nil
 }
 
+var includeEnvVars = []string{
+	"CPATH",
+	"C_INCLUDE_PATH",
+	"CPLUS_INCLUDE_PATH",
+	"OBJC_INCLUDE_PATH",
+	"OBJCPLUS_INCLUDE_PATH",
+}
+
+func withoutIncludeEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+outer:
+	for _, ev := range env {
+		for _, v := range includeEnvVars {
+			if strings.HasPrefix(ev, v+"=") {
+				continue outer
+			}
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
 func discoverDefaultSearchPath(compiler string, lang string) ([]string, error) {
 	var exe exec.Cmd
 	exe.Path = compiler
 	exe.Args = []string{compiler, "-Wp,-v", "-x", lang, "-E", "-"}
+	// We may have been autostarted by a llamacc with CPATH or
+	// similar set. Those directories aren't part of the compiler's
+	// default search path, and llamacc needs to upload headers
+	// from them.
+	exe.Env = withoutIncludeEnv(os.Environ())
 	var stderr bytes.Buffer
 	exe.Stderr = &stderr
 
@@ -290,9 +681,11 @@ func discoverDefaultSearchPath(compiler string, lang string) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
-		if strings.HasPrefix(line, " /") {
-			dir := strings.Trim(line, " \n")
-			paths = append(paths, path.Clean(dir))
+		// On Windows, directories may start with a drive letter,
+		// and lines end with \r\n.
+		dir := strings.TrimSpace(line)
+		if strings.HasPrefix(line, " ") && (strings.HasPrefix(dir, "/") || filepath.IsAbs(dir)) {
+			paths = append(paths, filepath.Clean(dir))
 		}
 	}
 	return paths, nil
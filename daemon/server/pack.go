@@ -0,0 +1,174 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/tracing"
+)
+
+// Jobs can only share a pack if they would otherwise make identical
+// requests
+type packKey struct {
+	function   string
+	qualifier  string
+	returnLogs bool
+	idempotent bool
+}
+
+type packedJob struct {
+	ctx  context.Context
+	args *llama.InvokeArgs
+	done chan struct{}
+
+	res    *llama.InvokeResult
+	region string
+	err    error
+}
+
+type pendingPack struct {
+	jobs  []*packedJob
+	timer *time.Timer
+}
+
+// A packer holds each invocation for up to the packing window, so
+// that others of the same function can join it, and sends them on
+// together, in packs of up to MaxJobs.
+type packer struct {
+	cfg  daemon.Packing
+	send func(key packKey, jobs []*packedJob)
+
+	mu      sync.Mutex
+	pending map[packKey]*pendingPack
+}
+
+func newPacker(cfg daemon.Packing, send func(packKey, []*packedJob)) *packer {
+	if cfg.Window <= 0 {
+		cfg.Window = daemon.DefaultPackWindow
+	}
+	return &packer{
+		cfg:     cfg,
+		send:    send,
+		pending: make(map[packKey]*pendingPack),
+	}
+}
+
+func (p *packer) enabled() bool {
+	return p != nil && p.cfg.MaxJobs > 1
+}
+
+// packable returns true if `spec` is small enough to share a request
+// with a full pack of others. Jobs carrying many files inline are
+// sent on their own.
+func (p *packer) packable(spec *protocol.InvocationSpec) bool {
+	return spec.InlineBytes() <= protocol.MaxRequestBytes/p.cfg.MaxJobs
+}
+
+// invoke adds an invocation to the pending pack for its function,
+// and waits for its result. A job whose context is cancelled stops
+// waiting, but still runs with the rest of its pack.
+func (p *packer) invoke(ctx context.Context, args *llama.InvokeArgs) (*llama.InvokeResult, string, error) {
+	// The pack is sent from another context, so carry the
+	// caller's trace with the job
+	args.Spec.Trace = tracing.PropagationFromContext(ctx)
+	job := &packedJob{ctx: ctx, args: args, done: make(chan struct{})}
+	p.add(packKey{args.Function, args.Qualifier, args.ReturnLogs, args.Idempotent}, job)
+	select {
+	case <-job.done:
+		return job.res, job.region, job.err
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+}
+
+func (p *packer) add(key packKey, job *packedJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := p.pending[key]
+	if pending == nil {
+		pending = &pendingPack{}
+		p.pending[key] = pending
+		pending.timer = time.AfterFunc(p.cfg.Window, func() {
+			p.flush(key, pending)
+		})
+	}
+	pending.jobs = append(pending.jobs, job)
+	if len(pending.jobs) >= p.cfg.MaxJobs {
+		pending.timer.Stop()
+		delete(p.pending, key)
+		go p.send(key, pending.jobs)
+	}
+}
+
+// flush sends a pending pack when its window closes, unless it filled
+// up and was sent first.
+func (p *packer) flush(key packKey, pending *pendingPack) {
+	p.mu.Lock()
+	if p.pending[key] != pending {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.pending, key)
+	p.mu.Unlock()
+	p.send(key, pending.jobs)
+}
+
+// sendPack invokes a pack of jobs, and hands each job its result. A
+// job that ends up alone is invoked as usual, without packing it.
+func (d *Daemon) sendPack(key packKey, jobs []*packedJob) {
+	defer func() {
+		for _, job := range jobs {
+			close(job.done)
+		}
+	}()
+	if len(jobs) == 1 {
+		job := jobs[0]
+		job.res, job.region, job.err = d.invokeLambda(job.ctx, job.args)
+		return
+	}
+
+	atomic.AddUint64(&d.stats.Usage.Lambda_Requests, 1)
+	atomic.AddUint64(&d.stats.PackedJobs, uint64(len(jobs)))
+	args := llama.InvokePackedArgs{
+		Function:    key.function,
+		ReturnLogs:  key.returnLogs,
+		Parallelism: d.packer.cfg.Parallelism,
+	}
+	for _, job := range jobs {
+		args.Specs = append(args.Specs, job.args.Spec)
+	}
+	var results []llama.PackedResult
+	region, err := d.invokeRegions(key.function, key.qualifier, key.idempotent, func(svc llama.Sender, qualifier string) error {
+		args.Qualifier = qualifier
+		var err error
+		results, err = llama.InvokePacked(d.ctx, svc, d.store, &args)
+		return err
+	})
+	for i, job := range jobs {
+		job.region = region
+		if err != nil {
+			job.err = err
+		} else {
+			job.res, job.err = results[i].Result, results[i].Err
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacker(t *testing.T) {
+	var mu sync.Mutex
+	var packs [][]string
+	p := newPacker(daemon.Packing{MaxJobs: 3, Window: 20 * time.Millisecond},
+		func(key packKey, jobs []*packedJob) {
+			var ids []string
+			for _, job := range jobs {
+				ids = append(ids, job.args.Spec.InvocationID)
+			}
+			mu.Lock()
+			packs = append(packs, ids)
+			mu.Unlock()
+			for _, job := range jobs {
+				id := job.args.Spec.InvocationID
+				job.res = &llama.InvokeResult{RequestID: key.function + ":" + id}
+				job.region = "us-test-1"
+				close(job.done)
+			}
+		})
+	assert.True(t, p.enabled())
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	invoke := func(function string, i int) {
+		defer wg.Done()
+		id := fmt.Sprintf("%s-%d", function, i)
+		res, region, err := p.invoke(ctx, &llama.InvokeArgs{
+			Function: function,
+			Spec:     protocol.InvocationSpec{InvocationID: id},
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, function+":"+id, res.RequestID)
+			assert.Equal(t, "us-test-1", region)
+		}
+	}
+	// Three jobs of one function fill a pack, and the fourth
+	// waits out the window; the other function's job is packed
+	// alone.
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go invoke("cc", i)
+	}
+	wg.Add(1)
+	go invoke("rustc", 0)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, packs, 3)
+	sizes := map[int]int{}
+	for _, pack := range packs {
+		sizes[len(pack)]++
+	}
+	assert.Equal(t, map[int]int{3: 1, 1: 2}, sizes)
+	p.mu.Lock()
+	assert.Empty(t, p.pending)
+	p.mu.Unlock()
+}
+
+func TestPackerCancel(t *testing.T) {
+	sent := make(chan []*packedJob, 1)
+	p := newPacker(daemon.Packing{MaxJobs: 2, Window: time.Hour},
+		func(key packKey, jobs []*packedJob) {
+			sent <- jobs
+		})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := p.invoke(ctx, &llama.InvokeArgs{Function: "cc"})
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// The abandoned job still fills out the next pack
+	go p.invoke(context.Background(), &llama.InvokeArgs{Function: "cc"})
+	jobs := <-sent
+	assert.Len(t, jobs, 2)
+	for _, job := range jobs {
+		close(job.done)
+	}
+}
+
+func TestPackerDisabled(t *testing.T) {
+	var p *packer
+	assert.False(t, p.enabled())
+	assert.False(t, newPacker(daemon.Packing{MaxJobs: 1}, nil).enabled())
+}
+
+func TestPackable(t *testing.T) {
+	p := newPacker(daemon.Packing{MaxJobs: 4}, nil)
+	spec := protocol.InvocationSpec{
+		Stdin: &protocol.Blob{Ref: "stdin"},
+		Files: protocol.FileList{
+			{File: protocol.File{Blob: protocol.Blob{String: "#pragma once\n"}}, Path: "a.h"},
+		},
+	}
+	assert.True(t, p.packable(&spec))
+	spec.Files = append(spec.Files, protocol.FileAndPath{
+		File: protocol.File{Blob: protocol.Blob{Bytes: make([]byte, protocol.MaxRequestBytes/4)}},
+		Path: "big.o",
+	})
+	assert.False(t, p.packable(&spec))
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/tracing"
+)
+
+func (d *Daemon) Prewarm(in *daemon.PrewarmArgs, out *daemon.PrewarmReply) error {
+	if !filepath.IsAbs(in.Database) {
+		return fmt.Errorf("Prewarm: path %q is not absolute", in.Database)
+	}
+	if len(in.Units) == 0 {
+		st, err := os.Stat(in.Database)
+		if err != nil {
+			return err
+		}
+		out.Wanted = d.claimPrewarm(in.Database, stampOf(st))
+		return nil
+	}
+	for _, unit := range in.Units {
+		for _, p := range append(append(append([]string(nil), unit.Inputs...), unit.Quote...), unit.Bracket...) {
+			if !filepath.IsAbs(p) {
+				return fmt.Errorf("Prewarm: path %q is not absolute", p)
+			}
+		}
+	}
+	go d.prewarm(d.ctx, in.Database, in.Units)
+	return nil
+}
+
+// claimPrewarm reports whether we have yet to prewarm this version of
+// a compilation database, and records that we are about to.
+func (d *Daemon) claimPrewarm(database string, stamp fileStamp) bool {
+	d.prewarmed.Lock()
+	defer d.prewarmed.Unlock()
+	if d.prewarmed.databases == nil {
+		d.prewarmed.databases = make(map[string]fileStamp)
+	}
+	if prev, ok := d.prewarmed.databases[database]; ok && prev == stamp {
+		return false
+	}
+	d.prewarmed.databases[database] = stamp
+	return true
+}
+
+// prewarmOrder returns every file the units depend on, those shared
+// by the most units first, since they are likeliest to be needed
+// soon.
+func (d *Daemon) prewarmOrder(units []daemon.ScanIncludesArgs) ([]string, int) {
+	counts := make(map[string]int)
+	var order []string
+	var failed int
+	for _, unit := range units {
+		deps, err := d.includes.scan(unit.Inputs, &searchPath{quote: unit.Quote, bracket: unit.Bracket})
+		if err != nil {
+			failed++
+			continue
+		}
+		for _, dep := range deps {
+			if counts[dep] == 0 {
+				order = append(order, dep)
+			}
+			counts[dep]++
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	return order, failed
+}
+
+func (d *Daemon) prewarm(ctx context.Context, database string, units []daemon.ScanIncludesArgs) {
+	ctx, span := tracing.StartSpan(ctx, "prewarm")
+	defer span.End()
+	start := time.Now()
+
+	order, failed := d.prewarmOrder(units)
+	var list files.List
+	for _, path := range order {
+		list = list.Append(files.Mapped{Local: files.LocalFile{Path: path}})
+	}
+	uploaded, _ := list.Upload(ctx, d.store, nil)
+	var uploadErrs int
+	for _, f := range uploaded {
+		if f.Blob.Err != "" {
+			uploadErrs++
+		}
+	}
+	span.AddField("units", len(units))
+	span.AddField("files", len(order))
+	logging.Record(ctx, "prewarmed",
+		"database", database,
+		"units", len(units),
+		"unscanned", failed,
+		"files", len(order),
+		"errors", uploadErrs,
+		"elapsed", time.Since(start),
+	)
+}
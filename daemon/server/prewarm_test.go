@@ -0,0 +1,81 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimPrewarm(t *testing.T) {
+	var d Daemon
+	stamp := fileStamp{size: 10, mtime: time.Unix(1600000000, 0)}
+	assert.True(t, d.claimPrewarm("/src/build/compile_commands.json", stamp))
+	assert.False(t, d.claimPrewarm("/src/build/compile_commands.json", stamp))
+	assert.True(t, d.claimPrewarm("/src/other/compile_commands.json", stamp))
+
+	stamp.mtime = stamp.mtime.Add(time.Second)
+	assert.True(t, d.claimPrewarm("/src/build/compile_commands.json", stamp),
+		"a changed database is prewarmed again")
+}
+
+func TestPrewarm(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) { writeTestFile(t, dir, name, body) }
+	write("a.c", `#include "common.h"
+`)
+	write("b.c", `#include "common.h"
+#include "b.h"
+`)
+	write("common.h", "")
+	write("b.h", "")
+	write("bad.c", `#include HEADER
+`)
+
+	st := store.InMemory()
+	d := &Daemon{store: st, includes: newIncludeIndex()}
+	units := []daemon.ScanIncludesArgs{
+		{Inputs: []string{filepath.Join(dir, "a.c")}},
+		{Inputs: []string{filepath.Join(dir, "bad.c")}},
+		{Inputs: []string{filepath.Join(dir, "b.c")}},
+	}
+	order, failed := d.prewarmOrder(units)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "common.h"),
+		filepath.Join(dir, "a.c"),
+		filepath.Join(dir, "b.c"),
+		filepath.Join(dir, "b.h"),
+	}, order)
+
+	d.prewarm(context.Background(), filepath.Join(dir, "compile_commands.json"), units)
+
+	err := d.Prewarm(&daemon.PrewarmArgs{Database: "compile_commands.json"}, &daemon.PrewarmReply{})
+	assert.Error(t, err, "relative paths are rejected")
+
+	var reply daemon.PrewarmReply
+	write("compile_commands.json", "[]")
+	require.NoError(t, d.Prewarm(&daemon.PrewarmArgs{Database: filepath.Join(dir, "compile_commands.json")}, &reply))
+	assert.True(t, reply.Wanted)
+	require.NoError(t, d.Prewarm(&daemon.PrewarmArgs{Database: filepath.Join(dir, "compile_commands.json")}, &reply))
+	assert.False(t, reply.Wanted)
+}
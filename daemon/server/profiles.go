@@ -0,0 +1,257 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/protocol"
+)
+
+const (
+	// Forget jobs we haven't seen in this long
+	profileTTL = 30 * 24 * time.Hour
+	// How often to write profiles to disk
+	profileSaveInterval = time.Minute
+)
+
+// A jobProfile records the resources a job used the last time it
+// ran, so that we can run it on a large enough function next time.
+type jobProfile struct {
+	// Peak resident set size of the command, in bytes
+	MaxRSS uint64        `json:"max_rss,omitempty"`
+	Exec   time.Duration `json:"exec,omitempty"`
+	// The memory size of the function it ran on, in MB
+	Memory int64 `json:"memory,omitempty"`
+	// The largest memory size, in MB, on which the job has run
+	// out of memory
+	OutOfMemory int64     `json:"out_of_memory,omitempty"`
+	Updated     time.Time `json:"updated"`
+}
+
+// needs returns the memory, in MB, that we think the job needs: its
+// peak usage with some headroom for the runtime and for variation
+// between runs, and more than it has ever run out of memory on.
+func (p *jobProfile) needs() int64 {
+	need := int64((p.MaxRSS+p.MaxRSS/4+(1<<20)-1)>>20) + 64
+	if p.MaxRSS == 0 {
+		need = 0
+	}
+	if p.OutOfMemory > 0 && p.OutOfMemory >= need {
+		need = p.OutOfMemory + 1
+	}
+	return need
+}
+
+// profileStore holds jobs' profiles, keyed by
+// InvokeWithFilesArgs.Profile, and persists them across restarts in a
+// JSON file.
+type profileStore struct {
+	path string
+
+	mu       sync.Mutex
+	profiles map[string]*jobProfile
+	dirty    bool
+}
+
+func loadProfiles(path string, now time.Time) *profileStore {
+	p := &profileStore{path: path, profiles: make(map[string]*jobProfile)}
+	if path == "" {
+		return p
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Printf(context.Background(), "reading job profiles: %s", err.Error())
+		}
+		return p
+	}
+	if err := json.Unmarshal(data, &p.profiles); err != nil {
+		logging.Printf(context.Background(), "reading job profiles: %s: %s", path, err.Error())
+		p.profiles = make(map[string]*jobProfile)
+		return p
+	}
+	for key, prof := range p.profiles {
+		if now.Sub(prof.Updated) > profileTTL {
+			delete(p.profiles, key)
+		}
+	}
+	return p
+}
+
+func (p *profileStore) get(key string) (jobProfile, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prof, ok := p.profiles[key]
+	if !ok {
+		return jobProfile{}, false
+	}
+	return *prof, true
+}
+
+// record updates the profile for key after the job ran on a function
+// with `memory` MB. resp is nil if the invocation failed; oom reports
+// whether it ran out of memory.
+func (p *profileStore) record(key string, memory int64, resp *protocol.InvocationResponse, oom bool, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prof, ok := p.profiles[key]
+	if !ok {
+		prof = &jobProfile{}
+		p.profiles[key] = prof
+	}
+	if resp != nil {
+		if resp.MaxRSS != 0 {
+			prof.MaxRSS = resp.MaxRSS
+		}
+		prof.Exec = resp.Times.Exec
+	}
+	prof.Memory = memory
+	if oom && memory > prof.OutOfMemory {
+		prof.OutOfMemory = memory
+	}
+	prof.Updated = now
+	p.dirty = true
+}
+
+func (p *profileStore) save() error {
+	p.mu.Lock()
+	if p.path == "" || !p.dirty {
+		p.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(p.profiles)
+	p.dirty = false
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(p.path), filepath.Base(p.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.path)
+}
+
+// run saves profiles periodically until done is closed.
+func (p *profileStore) run(done <-chan struct{}) {
+	tick := time.NewTicker(profileSaveInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			p.saveOrLog()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (p *profileStore) saveOrLog() {
+	if err := p.save(); err != nil {
+		logging.Printf(context.Background(), "saving job profiles: %s", err.Error())
+	}
+}
+
+// profiledMemory returns the memory to request for a job with the
+// given profile key, or 0 if we know of no reason to run it on
+// anything larger than the function itself.
+func (d *Daemon) profiledMemory(ctx context.Context, function, key string) int64 {
+	prof, ok := d.profiles.get(key)
+	if !ok {
+		return 0
+	}
+	need := prof.needs()
+	if need == 0 {
+		return 0
+	}
+	variants, err := d.listVariants(function)
+	if err != nil {
+		logging.Printf(ctx, "sizing %s: %s", key, err.Error())
+		return 0
+	}
+	var largest int64
+	for _, v := range variants {
+		if v.Memory > largest {
+			largest = v.Memory
+		}
+	}
+	if need > largest {
+		need = largest
+	}
+	if need <= variants[0].Memory {
+		return 0
+	}
+	return need
+}
+
+// recordProfile updates the profile for a job which ran on variant v
+// of function.
+func (d *Daemon) recordProfile(ctx context.Context, function, key string, v llama.Variant, repl *llama.InvokeResult, invokeErr error) {
+	oom := isOutOfMemory(invokeErr) || (repl != nil && repl.Response.Killed)
+	if invokeErr != nil && !oom {
+		// It failed for some other reason, which tells us nothing
+		return
+	}
+	memory := v.Memory
+	if memory == 0 {
+		variants, err := d.listVariants(function)
+		if err != nil {
+			logging.Printf(ctx, "profiling %s: %s", key, err.Error())
+			return
+		}
+		memory = variants[0].Memory
+	}
+	var resp *protocol.InvocationResponse
+	if invokeErr == nil {
+		resp = &repl.Response
+	}
+	d.profiles.record(key, memory, resp, oom, time.Now())
+}
+
+// isOutOfMemory reports whether err is Lambda's report that the
+// runtime was killed, which it is when it exceeds its memory size.
+func isOutOfMemory(err error) bool {
+	ret, ok := err.(*llama.ErrorReturn)
+	if !ok {
+		return false
+	}
+	return bytes.Contains(ret.Payload, []byte("Runtime.OutOfMemory")) ||
+		bytes.Contains(ret.Payload, []byte("signal: killed"))
+}
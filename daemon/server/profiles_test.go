@@ -0,0 +1,101 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobProfileNeeds(t *testing.T) {
+	assert.Equal(t, int64(0), (&jobProfile{}).needs())
+	assert.Equal(t, int64(1280+64), (&jobProfile{MaxRSS: 1 << 30}).needs())
+	assert.Equal(t, int64(2049), (&jobProfile{MaxRSS: 1 << 30, OutOfMemory: 2048}).needs())
+	assert.Equal(t, int64(1025), (&jobProfile{OutOfMemory: 1024}).needs())
+}
+
+func TestProfileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama-profiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.json")
+
+	now := time.Unix(1600000000, 0)
+	p := loadProfiles(path, now)
+	p.record("heavy.o", 1024, nil, true, now)
+	p.record("heavy.o", 2048, &protocol.InvocationResponse{
+		MaxRSS: 1500 << 20,
+		Times:  protocol.Timing{Exec: time.Minute},
+	}, false, now)
+	p.record("old.o", 1024, &protocol.InvocationResponse{MaxRSS: 1 << 20}, false, now.Add(-2*profileTTL))
+	require.NoError(t, p.save())
+
+	p = loadProfiles(path, now)
+	heavy, ok := p.get("heavy.o")
+	require.True(t, ok)
+	assert.True(t, now.Equal(heavy.Updated))
+	heavy.Updated = time.Time{}
+	assert.Equal(t, jobProfile{
+		MaxRSS:      1500 << 20,
+		Exec:        time.Minute,
+		Memory:      2048,
+		OutOfMemory: 1024,
+	}, heavy)
+	_, ok = p.get("old.o")
+	assert.False(t, ok, "old profiles expire")
+}
+
+func TestProfiledMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	d := &Daemon{profiles: loadProfiles("", now)}
+	d.variants.byFunction = map[string][]llama.Variant{
+		"gcc": {
+			{Memory: 1024, Timeout: time.Minute},
+			{Function: "gcc-m2048-t60", Memory: 2048, Timeout: time.Minute},
+			{Function: "gcc-m4096-t60", Memory: 4096, Timeout: time.Minute},
+		},
+	}
+	d.profiles.record("small.o", 1024, &protocol.InvocationResponse{MaxRSS: 200 << 20}, false, now)
+	d.profiles.record("big.o", 1024, &protocol.InvocationResponse{MaxRSS: 1200 << 20}, false, now)
+	d.profiles.record("huge.o", 4096, &protocol.InvocationResponse{MaxRSS: 8000 << 20}, false, now)
+
+	assert.Equal(t, int64(0), d.profiledMemory(ctx, "gcc", "unknown.o"))
+	assert.Equal(t, int64(0), d.profiledMemory(ctx, "gcc", "small.o"))
+	assert.Equal(t, int64(1564), d.profiledMemory(ctx, "gcc", "big.o"))
+	assert.Equal(t, int64(4096), d.profiledMemory(ctx, "gcc", "huge.o"))
+
+	// Running out of memory on the function moves the job up a size
+	d.recordProfile(ctx, "gcc", "small.o", llama.Variant{}, nil,
+		&llama.ErrorReturn{Payload: []byte(`{"errorMessage":"Runtime exited with error: signal: killed","errorType":"Runtime.ExitError"}`)})
+	v, err := d.pickVariant("gcc", d.profiledMemory(ctx, "gcc", "small.o"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "gcc-m2048-t60", v.Function)
+
+	// Other failures are not recorded
+	d.recordProfile(ctx, "gcc", "other.o", llama.Variant{}, nil, errors.New("throttled"))
+	_, ok := d.profiles.get("other.o")
+	assert.False(t, ok)
+}
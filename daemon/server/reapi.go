@@ -0,0 +1,492 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/daemon/reapi"
+	"github.com/nelhage/llama/store"
+	"google.golang.org/genproto/googleapis/bytestream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// The most blob data we advertise that a batch request can
+	// carry, and the largest message we accept
+	reapiMaxBatchBytes = 4 << 20
+	reapiMaxMessage    = 16 << 20
+	// The largest blob we accept, as for the HTTP cache
+	reapiMaxBlobBytes = 1 << 30
+	// How much of a blob we send in each ByteStream message
+	reapiChunkBytes = 1 << 20
+	// How many blobs we read or write at once
+	reapiConcurrency = 32
+	// How long WaitExecution can find an execution after it
+	// finishes
+	reapiOperationTTL = 10 * time.Minute
+	// The platform property naming the function to run an action
+	// with
+	reapiFunctionProperty = "function"
+)
+
+// reapiServer serves the Bazel Remote Execution API, so that Bazel
+// and other REAPI clients can run actions on Lambda. Blobs and action
+// results live in the store, under the same keys as `llama
+// bazel-cache` uses, and actions run through invokeWithFiles.
+type reapiServer struct {
+	reapi.UnimplementedExecutionServer
+	reapi.UnimplementedActionCacheServer
+	reapi.UnimplementedContentAddressableStorageServer
+	reapi.UnimplementedCapabilitiesServer
+
+	d        *Daemon
+	kv       store.KeyValue
+	function string
+	allow    []*net.IPNet
+	extend   chan<- struct{}
+	ops      reapiOps
+}
+
+// admit refuses requests from clients we don't accept, and postpones
+// the daemon's idle timeout for the rest
+func (s *reapiServer) admit(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok || !allowedPeer(s.allow, p.Addr) {
+		return status.Error(codes.PermissionDenied, "remote execution: client not allowed")
+	}
+	select {
+	case s.extend <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (s *reapiServer) serve(ctx context.Context, l net.Listener) {
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(reapiMaxMessage),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := s.admit(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.admit(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	reapi.RegisterExecutionServer(srv, s)
+	reapi.RegisterActionCacheServer(srv, s)
+	reapi.RegisterContentAddressableStorageServer(srv, s)
+	reapi.RegisterCapabilitiesServer(srv, s)
+	bytestream.RegisterByteStreamServer(srv, s)
+	go func() {
+		<-ctx.Done()
+		srv.Stop()
+	}()
+	if err := srv.Serve(l); err != nil && ctx.Err() == nil {
+		log.Printf("remote execution: %s", err.Error())
+	}
+}
+
+// reapiParallel calls fn(i) for each i in [0, n), reapiConcurrency at
+// a time
+func reapiParallel(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, reapiConcurrency)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func badDigest(what string, err error) error {
+	return status.Errorf(codes.InvalidArgument, "%s: %s", what, err.Error())
+}
+
+// getBlob reads the blob with digest `d` from the CAS, returning
+// store.ErrNotExists if we don't have it
+func (s *reapiServer) getBlob(ctx context.Context, d *reapi.Digest) ([]byte, error) {
+	if err := reapi.CheckDigest(d); err != nil {
+		return nil, badDigest("digest", err)
+	}
+	if d.SizeBytes == 0 {
+		// Clients needn't upload the empty blob
+		return []byte{}, nil
+	}
+	data, err := s.kv.GetKey(ctx, reapi.CASKey(d.Hash))
+	if err != nil {
+		return nil, err
+	}
+	if !d.Matches(data) {
+		return nil, status.Errorf(codes.DataLoss, "blob %s does not match its digest", d.Hash)
+	}
+	return data, nil
+}
+
+// putBlob stores `data` in the CAS, returning its digest
+func (s *reapiServer) putBlob(ctx context.Context, data []byte) (*reapi.Digest, error) {
+	d := reapi.DigestOf(data)
+	if d.SizeBytes == 0 {
+		return d, nil
+	}
+	return d, s.kv.SetKey(ctx, reapi.CASKey(d.Hash), data)
+}
+
+func storeStatus(err error) error {
+	if err == store.ErrNotExists {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *reapiServer) GetCapabilities(context.Context, *reapi.GetCapabilitiesRequest) (*reapi.ServerCapabilities, error) {
+	v2 := &reapi.SemVer{Major: 2}
+	return &reapi.ServerCapabilities{
+		CacheCapabilities: &reapi.CacheCapabilities{
+			DigestFunctions: []reapi.DigestFunction_Value{reapi.DigestFunction_SHA256},
+			ActionCacheUpdateCapabilities: &reapi.ActionCacheUpdateCapabilities{
+				UpdateEnabled: s.d.cache.Policy.Writes(),
+			},
+			MaxBatchTotalSizeBytes:      reapiMaxBatchBytes,
+			SymlinkAbsolutePathStrategy: reapi.SymlinkAbsolutePathStrategy_DISALLOWED,
+		},
+		ExecutionCapabilities: &reapi.ExecutionCapabilities{
+			DigestFunction: reapi.DigestFunction_SHA256,
+			ExecEnabled:    true,
+		},
+		LowApiVersion:  v2,
+		HighApiVersion: v2,
+	}, nil
+}
+
+// The action cache follows the daemon's cache policy: entries the
+// policy doesn't let us serve are reported missing, and results it
+// doesn't let us store are accepted and dropped.
+
+func (s *reapiServer) getResult(ctx context.Context, action *reapi.Digest) (*reapi.ActionResult, error) {
+	if !s.d.cache.Policy.Reads() {
+		return nil, store.ErrNotExists
+	}
+	data, err := s.kv.GetKey(ctx, reapi.ACKey(action.Hash))
+	if err != nil {
+		return nil, err
+	}
+	var res reapi.ActionResult
+	if err := proto.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("action result %s: %w", action.Hash, err)
+	}
+	return &res, nil
+}
+
+func (s *reapiServer) putResult(ctx context.Context, action *reapi.Digest, res *reapi.ActionResult) error {
+	if !s.d.cache.Policy.Writes() {
+		return nil
+	}
+	data, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return s.kv.SetKey(ctx, reapi.ACKey(action.Hash), data)
+}
+
+func (s *reapiServer) GetActionResult(ctx context.Context, in *reapi.GetActionResultRequest) (*reapi.ActionResult, error) {
+	if err := reapi.CheckDigest(in.ActionDigest); err != nil {
+		return nil, badDigest("action_digest", err)
+	}
+	res, err := s.getResult(ctx, in.ActionDigest)
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	return res, nil
+}
+
+func (s *reapiServer) UpdateActionResult(ctx context.Context, in *reapi.UpdateActionResultRequest) (*reapi.ActionResult, error) {
+	if err := reapi.CheckDigest(in.ActionDigest); err != nil {
+		return nil, badDigest("action_digest", err)
+	}
+	if in.ActionResult == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing action_result")
+	}
+	if err := s.putResult(ctx, in.ActionDigest, in.ActionResult); err != nil {
+		return nil, storeStatus(err)
+	}
+	return in.ActionResult, nil
+}
+
+func (s *reapiServer) FindMissingBlobs(ctx context.Context, in *reapi.FindMissingBlobsRequest) (*reapi.FindMissingBlobsResponse, error) {
+	for _, d := range in.BlobDigests {
+		if err := reapi.CheckDigest(d); err != nil {
+			return nil, badDigest("blob_digests", err)
+		}
+	}
+	errs := make([]error, len(in.BlobDigests))
+	reapiParallel(len(in.BlobDigests), func(i int) {
+		_, errs[i] = s.getBlob(ctx, in.BlobDigests[i])
+	})
+	var out reapi.FindMissingBlobsResponse
+	for i, err := range errs {
+		switch {
+		case err == nil:
+		case err == store.ErrNotExists || status.Code(err) == codes.DataLoss:
+			out.MissingBlobDigests = append(out.MissingBlobDigests, in.BlobDigests[i])
+		default:
+			return nil, storeStatus(err)
+		}
+	}
+	return &out, nil
+}
+
+func (s *reapiServer) BatchUpdateBlobs(ctx context.Context, in *reapi.BatchUpdateBlobsRequest) (*reapi.BatchUpdateBlobsResponse, error) {
+	out := reapi.BatchUpdateBlobsResponse{
+		Responses: make([]*reapi.BatchUpdateBlobsResponse_Response, len(in.Requests)),
+	}
+	reapiParallel(len(in.Requests), func(i int) {
+		req := in.Requests[i]
+		var err error
+		switch {
+		case reapi.CheckDigest(req.Digest) != nil:
+			err = badDigest("digest", reapi.CheckDigest(req.Digest))
+		case req.Compressor != reapi.Compressor_IDENTITY:
+			err = status.Error(codes.InvalidArgument, reapi.ErrCompressed.Error())
+		case !req.Digest.Matches(req.Data):
+			err = status.Error(codes.InvalidArgument, "data does not match digest")
+		default:
+			if _, err = s.putBlob(ctx, req.Data); err != nil {
+				err = storeStatus(err)
+			}
+		}
+		out.Responses[i] = &reapi.BatchUpdateBlobsResponse_Response{
+			Digest: req.Digest,
+			Status: status.Convert(err).Proto(),
+		}
+	})
+	return &out, nil
+}
+
+func (s *reapiServer) BatchReadBlobs(ctx context.Context, in *reapi.BatchReadBlobsRequest) (*reapi.BatchReadBlobsResponse, error) {
+	var total int64
+	for _, d := range in.Digests {
+		if err := reapi.CheckDigest(d); err != nil {
+			return nil, badDigest("digests", err)
+		}
+		total += d.SizeBytes
+	}
+	if total > reapiMaxBatchBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d bytes is larger than %d", total, reapiMaxBatchBytes)
+	}
+	out := reapi.BatchReadBlobsResponse{
+		Responses: make([]*reapi.BatchReadBlobsResponse_Response, len(in.Digests)),
+	}
+	reapiParallel(len(in.Digests), func(i int) {
+		data, err := s.getBlob(ctx, in.Digests[i])
+		if err != nil {
+			err = storeStatus(err)
+		}
+		out.Responses[i] = &reapi.BatchReadBlobsResponse_Response{
+			Digest: in.Digests[i],
+			Data:   data,
+			Status: status.Convert(err).Proto(),
+		}
+	})
+	return &out, nil
+}
+
+// getDirectory reads the Directory with digest `d` from the CAS
+func (s *reapiServer) getDirectory(ctx context.Context, d *reapi.Digest) (*reapi.Directory, error) {
+	data, err := s.getBlob(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	var dir reapi.Directory
+	if err := proto.Unmarshal(data, &dir); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "directory %s: %s", d.Hash, err.Error())
+	}
+	return &dir, nil
+}
+
+// GetTree returns the directories under the root, breadth-first. A
+// page token is the number of directories already returned.
+func (s *reapiServer) GetTree(in *reapi.GetTreeRequest, stream reapi.ContentAddressableStorage_GetTreeServer) error {
+	ctx := stream.Context()
+	var skip int
+	if in.PageToken != "" {
+		var err error
+		if skip, err = strconv.Atoi(in.PageToken); err != nil || skip < 0 {
+			return status.Errorf(codes.InvalidArgument, "bad page_token %q", in.PageToken)
+		}
+	}
+	sent := 0
+	var page []*reapi.Directory
+	flush := func(more bool) error {
+		sent += len(page)
+		resp := reapi.GetTreeResponse{Directories: page}
+		if more {
+			resp.NextPageToken = strconv.Itoa(sent)
+		}
+		page = nil
+		return stream.Send(&resp)
+	}
+	level := []*reapi.Digest{in.RootDigest}
+	for len(level) > 0 {
+		dirs := make([]*reapi.Directory, len(level))
+		errs := make([]error, len(level))
+		reapiParallel(len(level), func(i int) {
+			dirs[i], errs[i] = s.getDirectory(ctx, level[i])
+		})
+		var next []*reapi.Digest
+		for i, dir := range dirs {
+			if errs[i] != nil {
+				return storeStatus(errs[i])
+			}
+			for _, child := range dir.Directories {
+				next = append(next, child.Digest)
+			}
+			if skip > 0 {
+				skip--
+				sent++
+				continue
+			}
+			if in.PageSize > 0 && len(page) == int(in.PageSize) {
+				if err := flush(true); err != nil {
+					return err
+				}
+			}
+			page = append(page, dir)
+		}
+		level = next
+	}
+	return flush(false)
+}
+
+// The ByteStream API reads and writes blobs too large for the batch
+// methods
+
+func (s *reapiServer) Read(in *bytestream.ReadRequest, stream bytestream.ByteStream_ReadServer) error {
+	d, err := reapi.ParseReadResource(in.ResourceName)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	data, err := s.getBlob(stream.Context(), d)
+	if err != nil {
+		return storeStatus(err)
+	}
+	if in.ReadOffset < 0 || in.ReadOffset > int64(len(data)) {
+		return status.Errorf(codes.OutOfRange, "read_offset %d is outside the blob", in.ReadOffset)
+	}
+	if in.ReadLimit < 0 {
+		return status.Errorf(codes.InvalidArgument, "negative read_limit")
+	}
+	data = data[in.ReadOffset:]
+	if in.ReadLimit > 0 && in.ReadLimit < int64(len(data)) {
+		data = data[:in.ReadLimit]
+	}
+	for len(data) > 0 {
+		n := len(data)
+		if n > reapiChunkBytes {
+			n = reapiChunkBytes
+		}
+		if err := stream.Send(&bytestream.ReadResponse{Data: data[:n]}); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// Write stores a blob uploaded in one go; we don't support resuming
+// uploads.
+func (s *reapiServer) Write(stream bytestream.ByteStream_WriteServer) error {
+	var d *reapi.Digest
+	var data []byte
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return status.Error(codes.InvalidArgument, "upload ended without finish_write")
+		}
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			if d, err = reapi.ParseWriteResource(req.ResourceName); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			if d.SizeBytes > reapiMaxBlobBytes {
+				return status.Errorf(codes.InvalidArgument, "blob of %d bytes is larger than %d", d.SizeBytes, reapiMaxBlobBytes)
+			}
+		}
+		if req.WriteOffset != int64(len(data)) {
+			return status.Errorf(codes.InvalidArgument, "write_offset %d, expected %d", req.WriteOffset, len(data))
+		}
+		if int64(len(data)+len(req.Data)) > d.SizeBytes {
+			return status.Error(codes.InvalidArgument, "upload is larger than its digest")
+		}
+		data = append(data, req.Data...)
+		if req.FinishWrite {
+			break
+		}
+	}
+	if !d.Matches(data) {
+		return status.Error(codes.InvalidArgument, "data does not match digest")
+	}
+	if _, err := s.putBlob(stream.Context(), data); err != nil {
+		return storeStatus(err)
+	}
+	return stream.SendAndClose(&bytestream.WriteResponse{CommittedSize: d.SizeBytes})
+}
+
+// QueryWriteStatus reports uploads complete once we hold the blob;
+// since we don't resume uploads, any other upload starts over.
+func (s *reapiServer) QueryWriteStatus(ctx context.Context, in *bytestream.QueryWriteStatusRequest) (*bytestream.QueryWriteStatusResponse, error) {
+	d, err := reapi.ParseWriteResource(in.ResourceName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	_, err = s.getBlob(ctx, d)
+	switch {
+	case err == nil:
+		return &bytestream.QueryWriteStatusResponse{CommittedSize: d.SizeBytes, Complete: true}, nil
+	case err == store.ErrNotExists || status.Code(err) == codes.DataLoss:
+		return &bytestream.QueryWriteStatusResponse{}, nil
+	default:
+		return nil, storeStatus(err)
+	}
+}
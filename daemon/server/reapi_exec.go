@@ -0,0 +1,526 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/reapi"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/store"
+	"google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// reapiOps tracks executions, so that WaitExecution can find them
+// while they run and for reapiOperationTTL after they finish.
+type reapiOps struct {
+	sync.Mutex
+	byName map[string]*reapiOp
+}
+
+type reapiOp struct {
+	done chan struct{}
+	// The operation's final state, set before done is closed
+	final *longrunning.Operation
+}
+
+func (o *reapiOps) start() (string, *reapiOp) {
+	name := "operations/" + newInvocationID()
+	op := &reapiOp{done: make(chan struct{})}
+	o.Lock()
+	defer o.Unlock()
+	if o.byName == nil {
+		o.byName = make(map[string]*reapiOp)
+	}
+	o.byName[name] = op
+	return name, op
+}
+
+func (o *reapiOps) finish(name string, op *reapiOp, final *longrunning.Operation) {
+	op.final = final
+	close(op.done)
+	time.AfterFunc(reapiOperationTTL, func() {
+		o.Lock()
+		defer o.Unlock()
+		delete(o.byName, name)
+	})
+}
+
+func (o *reapiOps) get(name string) *reapiOp {
+	o.Lock()
+	defer o.Unlock()
+	return o.byName[name]
+}
+
+func reapiOperation(name string, action *reapi.Digest, stage reapi.ExecutionStage_Value, resp *reapi.ExecuteResponse) (*longrunning.Operation, error) {
+	meta, err := anypb.New(&reapi.ExecuteOperationMetadata{Stage: stage, ActionDigest: action})
+	if err != nil {
+		return nil, err
+	}
+	op := &longrunning.Operation{Name: name, Metadata: meta}
+	if resp != nil {
+		result, err := anypb.New(resp)
+		if err != nil {
+			return nil, err
+		}
+		op.Done = true
+		op.Result = &longrunning.Operation_Response{Response: result}
+	}
+	return op, nil
+}
+
+// Execute runs an action, unless its result is already cached. The
+// action is cancelled if this call ends before it finishes, so
+// WaitExecution only finds executions other calls are still waiting
+// on, or which have finished.
+func (s *reapiServer) Execute(in *reapi.ExecuteRequest, stream reapi.Execution_ExecuteServer) error {
+	if err := reapi.CheckDigest(in.ActionDigest); err != nil {
+		return badDigest("action_digest", err)
+	}
+	name, op := s.ops.start()
+	progress := func(stage reapi.ExecutionStage_Value) error {
+		op, err := reapiOperation(name, in.ActionDigest, stage, nil)
+		if err != nil {
+			return err
+		}
+		return stream.Send(op)
+	}
+	resp, err := s.execute(stream.Context(), in, progress)
+	if err != nil {
+		resp = &reapi.ExecuteResponse{Status: status.Convert(err).Proto()}
+	}
+	final, err := reapiOperation(name, in.ActionDigest, reapi.ExecutionStage_COMPLETED, resp)
+	if err != nil {
+		return err
+	}
+	s.ops.finish(name, op, final)
+	return stream.Send(final)
+}
+
+func (s *reapiServer) WaitExecution(in *reapi.WaitExecutionRequest, stream reapi.Execution_WaitExecutionServer) error {
+	op := s.ops.get(in.Name)
+	if op == nil {
+		return status.Errorf(codes.NotFound, "no operation %q", in.Name)
+	}
+	select {
+	case <-op.done:
+		return stream.Send(op.final)
+	case <-stream.Context().Done():
+		return status.FromContextError(stream.Context().Err()).Err()
+	}
+}
+
+// missingBlobs returns the error REAPI clients expect when an
+// action's inputs are missing from the CAS, which prompts them to
+// upload them and try again
+func missingBlobs(digests []*reapi.Digest) error {
+	var pf errdetails.PreconditionFailure
+	for _, d := range digests {
+		pf.Violations = append(pf.Violations, &errdetails.PreconditionFailure_Violation{
+			Type:    "MISSING",
+			Subject: fmt.Sprintf("blobs/%s/%d", d.Hash, d.SizeBytes),
+		})
+	}
+	st := status.New(codes.FailedPrecondition, fmt.Sprintf("%d blobs missing from the CAS", len(digests)))
+	if detailed, err := st.WithDetails(&pf); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// getBlobs reads the blobs with `digests` from the CAS, reporting any
+// which are missing with missingBlobs
+func (s *reapiServer) getBlobs(ctx context.Context, digests []*reapi.Digest) ([][]byte, error) {
+	data := make([][]byte, len(digests))
+	errs := make([]error, len(digests))
+	reapiParallel(len(digests), func(i int) {
+		data[i], errs[i] = s.getBlob(ctx, digests[i])
+	})
+	var missing []*reapi.Digest
+	for i, err := range errs {
+		switch {
+		case err == nil:
+		case err == store.ErrNotExists:
+			missing = append(missing, digests[i])
+		default:
+			return nil, storeStatus(err)
+		}
+	}
+	if missing != nil {
+		return nil, missingBlobs(missing)
+	}
+	return data, nil
+}
+
+func (s *reapiServer) getMessage(ctx context.Context, d *reapi.Digest, m proto.Message) error {
+	data, err := s.getBlobs(ctx, []*reapi.Digest{d})
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(data[0], m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "blob %s: %s", d.Hash, err.Error())
+	}
+	return nil
+}
+
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsRune(name, '/')
+}
+
+// validOutput reports whether `p` is a clean relative path within
+// the working directory
+func validOutput(p string) bool {
+	return p != "" && !path.IsAbs(p) && path.Clean(p) == p && p != "." && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// inputs reads the input tree with root `root` from the CAS,
+// returning its files, and the paths of its empty directories. We
+// don't support symlinks, since llama can't pass them to functions.
+func (s *reapiServer) inputs(ctx context.Context, root *reapi.Digest) (files.List, []string, error) {
+	type pending struct {
+		path   string
+		digest *reapi.Digest
+	}
+	var fileNodes []pending
+	var execs []bool
+	var empty []string
+	level := []pending{{path: "", digest: root}}
+	for len(level) > 0 {
+		digests := make([]*reapi.Digest, len(level))
+		for i, p := range level {
+			digests[i] = p.digest
+		}
+		blobs, err := s.getBlobs(ctx, digests)
+		if err != nil {
+			return nil, nil, err
+		}
+		var next []pending
+		for i, p := range level {
+			var dir reapi.Directory
+			if err := proto.Unmarshal(blobs[i], &dir); err != nil {
+				return nil, nil, status.Errorf(codes.InvalidArgument, "directory %q: %s", p.path, err.Error())
+			}
+			if len(dir.Symlinks) > 0 {
+				return nil, nil, status.Errorf(codes.InvalidArgument, "directory %q: symlinks are not supported", p.path)
+			}
+			if len(dir.Files) == 0 && len(dir.Directories) == 0 && p.path != "" {
+				empty = append(empty, p.path)
+			}
+			for _, f := range dir.Files {
+				if !validName(f.Name) {
+					return nil, nil, status.Errorf(codes.InvalidArgument, "directory %q: bad file name %q", p.path, f.Name)
+				}
+				fileNodes = append(fileNodes, pending{path: path.Join(p.path, f.Name), digest: f.Digest})
+				execs = append(execs, f.IsExecutable)
+			}
+			for _, d := range dir.Directories {
+				if !validName(d.Name) {
+					return nil, nil, status.Errorf(codes.InvalidArgument, "directory %q: bad subdirectory name %q", p.path, d.Name)
+				}
+				next = append(next, pending{path: path.Join(p.path, d.Name), digest: d.Digest})
+			}
+		}
+		level = next
+	}
+
+	// Read each distinct blob once
+	index := make(map[string]int)
+	var digests []*reapi.Digest
+	for _, f := range fileNodes {
+		if _, ok := index[f.digest.GetHash()]; !ok {
+			index[f.digest.GetHash()] = len(digests)
+			digests = append(digests, f.digest)
+		}
+	}
+	blobs, err := s.getBlobs(ctx, digests)
+	if err != nil {
+		return nil, nil, err
+	}
+	var list files.List
+	for i, f := range fileNodes {
+		mode := os.FileMode(0644)
+		if execs[i] {
+			mode = 0755
+		}
+		list = list.Append(files.Mapped{
+			Local:  files.LocalFile{Bytes: blobs[index[f.digest.Hash]], Mode: mode},
+			Remote: f.path,
+		})
+	}
+	return list, empty, nil
+}
+
+// reapiFunction returns the function to run an action with: the one
+// the first of `platforms` with a "function" property names, or our
+// default
+func (s *reapiServer) reapiFunction(platforms ...*reapi.Platform) string {
+	for _, p := range platforms {
+		for _, prop := range p.GetProperties() {
+			if prop.Name == reapiFunctionProperty {
+				return prop.Value
+			}
+		}
+	}
+	return s.function
+}
+
+func (s *reapiServer) execute(ctx context.Context, in *reapi.ExecuteRequest, progress func(reapi.ExecutionStage_Value) error) (*reapi.ExecuteResponse, error) {
+	var action reapi.Action
+	if err := s.getMessage(ctx, in.ActionDigest, &action); err != nil {
+		return nil, err
+	}
+	if !in.SkipCacheLookup && !action.DoNotCache {
+		if err := progress(reapi.ExecutionStage_CACHE_CHECK); err != nil {
+			return nil, err
+		}
+		res, err := s.getResult(ctx, in.ActionDigest)
+		if err == nil {
+			return &reapi.ExecuteResponse{Result: res, CachedResult: true}, nil
+		}
+		if err != store.ErrNotExists {
+			logging.Printf(ctx, "remote execution: action cache: %s", err.Error())
+		}
+	}
+
+	var cmd reapi.Command
+	if err := s.getMessage(ctx, action.CommandDigest, &cmd); err != nil {
+		return nil, err
+	}
+	if cmd.WorkingDirectory != "" {
+		return nil, status.Error(codes.InvalidArgument, "working_directory is not supported")
+	}
+	if len(cmd.OutputPaths) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "output_paths is not supported; use output_files and output_directories")
+	}
+	for _, p := range append(cmd.OutputFiles[:len(cmd.OutputFiles):len(cmd.OutputFiles)], cmd.OutputDirectories...) {
+		if !validOutput(p) {
+			return nil, status.Errorf(codes.InvalidArgument, "bad output path %q", p)
+		}
+	}
+	if len(cmd.Arguments) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing arguments")
+	}
+
+	inputs, empty, err := s.inputs(ctx, action.InputRootDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := ioutil.TempDir("", "llama-reapi")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(local)
+	// An empty local directory, to create empty directories with
+	emptyDir := filepath.Join(local, "empty")
+	outDir := filepath.Join(local, "out")
+	for _, dir := range []string{emptyDir, outDir} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	args := daemon.InvokeWithFilesArgs{
+		Function: s.reapiFunction(action.Platform, cmd.Platform),
+		Args:     cmd.Arguments,
+		Files:    inputs,
+		Timeout:  action.Timeout.AsDuration(),
+	}
+	if args.Function == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "no function to run the action with; set the %q platform property", reapiFunctionProperty)
+	}
+	args.TimeLimit = args.Timeout
+	// Remote execution assumes actions are hermetic, and may run
+	// them more than once anyway
+	args.Idempotent = true
+	for _, env := range cmd.EnvironmentVariables {
+		args.Env = append(args.Env, env.Name+"="+env.Value)
+	}
+	for _, dir := range empty {
+		args.Trees = args.Trees.Append(files.Mapped{Local: files.LocalFile{Path: emptyDir}, Remote: dir})
+	}
+	for _, out := range cmd.OutputFiles {
+		p := filepath.Join(outDir, filepath.FromSlash(out))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return nil, err
+		}
+		args.Outputs = args.Outputs.Append(files.Mapped{Local: files.LocalFile{Path: p}, Remote: out})
+	}
+	if len(cmd.OutputDirectories) > 0 {
+		// Create the output directories, as Bazel does when it
+		// runs actions itself, and fetch everything the
+		// command writes, to find what it wrote in them
+		for _, dir := range cmd.OutputDirectories {
+			args.Trees = args.Trees.Append(files.Mapped{Local: files.LocalFile{Path: emptyDir}, Remote: dir})
+		}
+		args.ChangesDir = filepath.Join(local, "changes")
+		if err := os.Mkdir(args.ChangesDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := progress(reapi.ExecutionStage_EXECUTING); err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	var reply daemon.InvokeWithFilesReply
+	if err := s.d.invokeWithFiles(ctx, &args, &reply); err != nil {
+		switch {
+		case err == errCancelled && ctx.Err() != nil:
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case err == errDraining:
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if reply.InvokeErr != "" {
+		return nil, status.Errorf(codes.Internal, "invoking %s: %s", args.Function, reply.InvokeErr)
+	}
+	completed := time.Now()
+
+	res, err := s.result(ctx, &cmd, &reply, outDir, args.ChangesDir)
+	if err != nil {
+		return nil, err
+	}
+	res.ExecutionMetadata = &reapi.ExecutedActionMetadata{
+		Worker:                      "llama:" + args.Function,
+		ExecutionStartTimestamp:     timestamppb.New(started),
+		ExecutionCompletedTimestamp: timestamppb.New(completed),
+	}
+	if res.ExitCode == 0 && !action.DoNotCache {
+		if err := s.putResult(ctx, in.ActionDigest, res); err != nil {
+			logging.Printf(ctx, "remote execution: storing action result: %s", err.Error())
+		}
+	}
+	return &reapi.ExecuteResponse{Result: res}, nil
+}
+
+// result stores an invocation's outputs in the CAS, and returns its
+// ActionResult
+func (s *reapiServer) result(ctx context.Context, cmd *reapi.Command, reply *daemon.InvokeWithFilesReply, outDir, changesDir string) (*reapi.ActionResult, error) {
+	res := reapi.ActionResult{ExitCode: int32(reply.ExitStatus)}
+	var err error
+	if res.StdoutDigest, err = s.putBlob(ctx, reply.Stdout); err != nil {
+		return nil, storeStatus(err)
+	}
+	if res.StderrDigest, err = s.putBlob(ctx, reply.Stderr); err != nil {
+		return nil, storeStatus(err)
+	}
+	for _, out := range cmd.OutputFiles {
+		node, err := s.putFile(ctx, filepath.Join(outDir, filepath.FromSlash(out)), path.Base(out))
+		if os.IsNotExist(err) {
+			// Missing outputs are left out of the result
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.OutputFiles = append(res.OutputFiles, &reapi.OutputFile{
+			Path:         out,
+			Digest:       node.Digest,
+			IsExecutable: node.IsExecutable,
+		})
+	}
+	for _, out := range cmd.OutputDirectories {
+		var tree reapi.Tree
+		root, err := s.putDirectory(ctx, filepath.Join(changesDir, filepath.FromSlash(out)), &tree)
+		if err != nil {
+			return nil, err
+		}
+		tree.Root = root
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(&tree)
+		if err != nil {
+			return nil, err
+		}
+		d, err := s.putBlob(ctx, data)
+		if err != nil {
+			return nil, storeStatus(err)
+		}
+		res.OutputDirectories = append(res.OutputDirectories, &reapi.OutputDirectory{Path: out, TreeDigest: d})
+	}
+	return &res, nil
+}
+
+// putFile stores the local file `p` in the CAS
+func (s *reapiServer) putFile(ctx context.Context, p, name string) (*reapi.FileNode, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.putBlob(ctx, data)
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	return &reapi.FileNode{Name: name, Digest: d, IsExecutable: st.Mode()&0100 != 0}, nil
+}
+
+// putDirectory stores the files under the local directory `dir` in
+// the CAS, returning the Directory describing it and adding its
+// descendants to `tree`. A missing directory is empty, since we
+// created it and the command wrote nothing in it.
+func (s *reapiServer) putDirectory(ctx context.Context, dir string, tree *reapi.Tree) (*reapi.Directory, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	var out reapi.Directory
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if !e.IsDir() {
+			node, err := s.putFile(ctx, p, e.Name())
+			if err != nil {
+				return nil, err
+			}
+			out.Files = append(out.Files, node)
+			continue
+		}
+		child, err := s.putDirectory(ctx, p, tree)
+		if err != nil {
+			return nil, err
+		}
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(child)
+		if err != nil {
+			return nil, err
+		}
+		tree.Children = append(tree.Children, child)
+		out.Directories = append(out.Directories, &reapi.DirectoryNode{
+			Name:   e.Name(),
+			Digest: reapi.DigestOf(data),
+		})
+	}
+	return &out, nil
+}
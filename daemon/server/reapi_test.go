@@ -0,0 +1,315 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/daemon/reapi"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/runner"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/bytestream"
+	"google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// newREAPIServer serves the Remote Execution API from a daemon which
+// runs the function "sh" locally
+func newREAPIServer(t *testing.T) (*grpc.ClientConn, *Daemon) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	st := store.InMemory()
+	d := &Daemon{
+		ctx:    ctx,
+		store:  st,
+		trees:  files.NewTreeCache(),
+		budget: newBudget(daemon.Budget{}, daemon.Pricing{}),
+		sched:  newScheduler(daemon.Schedule{}),
+		cache:  daemon.CacheConfig{Policy: daemon.CacheReadWrite},
+		local:  map[string]*runner.Runner{"sh": runner.New(st, nil, "local")},
+
+		codeHashes: newCodeHashCache(),
+	}
+	d.variants.byFunction = make(map[string][]llama.Variant)
+
+	extend := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-extend:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &reapiServer{d: d, kv: st.(store.KeyValue), function: "sh", extend: extend}
+	go s.serve(ctx, l)
+
+	conn, err := grpc.DialContext(ctx, l.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, d
+}
+
+func upload(t *testing.T, cas reapi.ContentAddressableStorageClient, blobs ...[]byte) []*reapi.Digest {
+	var req reapi.BatchUpdateBlobsRequest
+	var digests []*reapi.Digest
+	for _, b := range blobs {
+		d := reapi.DigestOf(b)
+		digests = append(digests, d)
+		req.Requests = append(req.Requests, &reapi.BatchUpdateBlobsRequest_Request{Digest: d, Data: b})
+	}
+	resp, err := cas.BatchUpdateBlobs(context.Background(), &req)
+	require.NoError(t, err)
+	for _, r := range resp.Responses {
+		require.Equal(t, int32(codes.OK), r.Status.GetCode(), r.Status.GetMessage())
+	}
+	return digests
+}
+
+func marshal(t *testing.T, m proto.Message) []byte {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	require.NoError(t, err)
+	return data
+}
+
+func read(t *testing.T, cas reapi.ContentAddressableStorageClient, d *reapi.Digest) []byte {
+	resp, err := cas.BatchReadBlobs(context.Background(), &reapi.BatchReadBlobsRequest{Digests: []*reapi.Digest{d}})
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.OK), resp.Responses[0].Status.GetCode(), resp.Responses[0].Status.GetMessage())
+	return resp.Responses[0].Data
+}
+
+func TestREAPIStorage(t *testing.T) {
+	conn, d := newREAPIServer(t)
+	ctx := context.Background()
+	cas := reapi.NewContentAddressableStorageClient(conn)
+
+	hello := []byte("hello, bazel\n")
+	digests := upload(t, cas, hello)
+	absent := reapi.DigestOf([]byte("absent"))
+	missing, err := cas.FindMissingBlobs(ctx, &reapi.FindMissingBlobsRequest{
+		BlobDigests: []*reapi.Digest{digests[0], absent, reapi.DigestOf(nil)},
+	})
+	require.NoError(t, err)
+	require.Len(t, missing.MissingBlobDigests, 1)
+	assert.Equal(t, absent.Hash, missing.MissingBlobDigests[0].Hash)
+	assert.Equal(t, hello, read(t, cas, digests[0]))
+
+	// The HTTP cache's keys
+	data, err := d.store.(store.KeyValue).GetKey(ctx, reapi.CASKey(digests[0].Hash))
+	require.NoError(t, err)
+	assert.Equal(t, hello, data)
+
+	bad, err := cas.BatchUpdateBlobs(ctx, &reapi.BatchUpdateBlobsRequest{
+		Requests: []*reapi.BatchUpdateBlobsRequest_Request{{Digest: absent, Data: hello}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(codes.InvalidArgument), bad.Responses[0].Status.GetCode())
+
+	reads, err := cas.BatchReadBlobs(ctx, &reapi.BatchReadBlobsRequest{Digests: []*reapi.Digest{absent}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(codes.NotFound), reads.Responses[0].Status.GetCode())
+
+	// ByteStream
+	bs := bytestream.NewByteStreamClient(conn)
+	big := []byte("a larger blob, uploaded in pieces")
+	bd := reapi.DigestOf(big)
+	w, err := bs.Write(ctx)
+	require.NoError(t, err)
+	name := "instance/uploads/some-uuid/blobs/" + bd.Hash + "/33"
+	require.NoError(t, w.Send(&bytestream.WriteRequest{ResourceName: name, Data: big[:10]}))
+	require.NoError(t, w.Send(&bytestream.WriteRequest{WriteOffset: 10, Data: big[10:], FinishWrite: true}))
+	wr, err := w.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(big)), wr.CommittedSize)
+
+	qs, err := bs.QueryWriteStatus(ctx, &bytestream.QueryWriteStatusRequest{ResourceName: name})
+	require.NoError(t, err)
+	assert.True(t, qs.Complete)
+
+	r, err := bs.Read(ctx, &bytestream.ReadRequest{ResourceName: "instance/blobs/" + bd.Hash + "/33", ReadOffset: 2, ReadLimit: 6})
+	require.NoError(t, err)
+	chunk, err := r.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, big[2:8], chunk.Data)
+
+	r, err = bs.Read(ctx, &bytestream.ReadRequest{ResourceName: "blobs/" + absent.Hash + "/6"})
+	require.NoError(t, err)
+	_, err = r.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// GetTree, in pages
+	leaf := marshal(t, &reapi.Directory{Files: []*reapi.FileNode{{Name: "f", Digest: digests[0]}}})
+	mid := marshal(t, &reapi.Directory{Directories: []*reapi.DirectoryNode{{Name: "leaf", Digest: reapi.DigestOf(leaf)}}})
+	root := marshal(t, &reapi.Directory{Directories: []*reapi.DirectoryNode{
+		{Name: "a", Digest: reapi.DigestOf(mid)},
+		{Name: "b", Digest: reapi.DigestOf(leaf)},
+	}})
+	upload(t, cas, leaf, mid, root)
+	tree, err := cas.GetTree(ctx, &reapi.GetTreeRequest{RootDigest: reapi.DigestOf(root), PageSize: 2})
+	require.NoError(t, err)
+	var pages [][]*reapi.Directory
+	var tokens []string
+	for {
+		resp, err := tree.Recv()
+		if err != nil {
+			break
+		}
+		pages = append(pages, resp.Directories)
+		tokens = append(tokens, resp.NextPageToken)
+	}
+	// The leaf is reachable twice
+	assert.Equal(t, []int{2, 2}, []int{len(pages[0]), len(pages[1])})
+	assert.Equal(t, []string{"2", ""}, tokens)
+
+	tree, err = cas.GetTree(ctx, &reapi.GetTreeRequest{RootDigest: reapi.DigestOf(root), PageToken: "3"})
+	require.NoError(t, err)
+	resp, err := tree.Recv()
+	require.NoError(t, err)
+	require.Len(t, resp.Directories, 1)
+	assert.Equal(t, "f", resp.Directories[0].Files[0].Name)
+
+	// The action cache follows the cache policy
+	ac := reapi.NewActionCacheClient(conn)
+	action := reapi.DigestOf([]byte("action"))
+	_, err = ac.GetActionResult(ctx, &reapi.GetActionResultRequest{ActionDigest: action})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = ac.UpdateActionResult(ctx, &reapi.UpdateActionResultRequest{
+		ActionDigest: action,
+		ActionResult: &reapi.ActionResult{ExitCode: 3},
+	})
+	require.NoError(t, err)
+	res, err := ac.GetActionResult(ctx, &reapi.GetActionResultRequest{ActionDigest: action})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), res.ExitCode)
+
+	d.cache.Policy = daemon.CacheWriteOnly
+	_, err = ac.GetActionResult(ctx, &reapi.GetActionResultRequest{ActionDigest: action})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	caps, err := reapi.NewCapabilitiesClient(conn).GetCapabilities(ctx, &reapi.GetCapabilitiesRequest{})
+	require.NoError(t, err)
+	assert.True(t, caps.CacheCapabilities.ActionCacheUpdateCapabilities.UpdateEnabled)
+	assert.Equal(t, reapi.DigestFunction_SHA256, caps.ExecutionCapabilities.DigestFunction)
+	assert.Equal(t, int32(2), caps.HighApiVersion.Major)
+}
+
+// execute runs `action` and returns the final operation's response
+func execute(t *testing.T, conn *grpc.ClientConn, action *reapi.Digest) (*longrunning.Operation, *reapi.ExecuteResponse) {
+	stream, err := reapi.NewExecutionClient(conn).Execute(context.Background(), &reapi.ExecuteRequest{ActionDigest: action})
+	require.NoError(t, err)
+	var op *longrunning.Operation
+	for {
+		next, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		op = next
+	}
+	require.NotNil(t, op)
+	require.True(t, op.Done)
+	var resp reapi.ExecuteResponse
+	require.NoError(t, op.GetResponse().UnmarshalTo(&resp))
+	return op, &resp
+}
+
+func TestREAPIExecute(t *testing.T) {
+	conn, _ := newREAPIServer(t)
+	ctx := context.Background()
+	cas := reapi.NewContentAddressableStorageClient(conn)
+
+	input := []byte("hello\n")
+	script := []byte("#!/bin/sh\necho from script\n")
+	blobs := upload(t, cas, input, script)
+	sub := marshal(t, &reapi.Directory{Files: []*reapi.FileNode{{Name: "run.sh", Digest: blobs[1], IsExecutable: true}}})
+	empty := marshal(t, &reapi.Directory{})
+	root := marshal(t, &reapi.Directory{
+		Files: []*reapi.FileNode{{Name: "in.txt", Digest: blobs[0]}},
+		Directories: []*reapi.DirectoryNode{
+			{Name: "empty", Digest: reapi.DigestOf(empty)},
+			{Name: "sub", Digest: reapi.DigestOf(sub)},
+		},
+	})
+	cmd := marshal(t, &reapi.Command{
+		Arguments: []string{"sh", "-c", `test -d empty || exit 9
+cat in.txt > out.txt
+sub/run.sh > outdir/nested/f
+echo "$GREETING"
+echo oops >&2`},
+		EnvironmentVariables: []*reapi.Command_EnvironmentVariable{{Name: "GREETING", Value: "hi"}},
+		OutputFiles:          []string{"out.txt", "not-written.txt"},
+		OutputDirectories:    []string{"outdir/nested"},
+	})
+	action := marshal(t, &reapi.Action{
+		CommandDigest:   reapi.DigestOf(cmd),
+		InputRootDigest: reapi.DigestOf(root),
+	})
+	actionDigest := reapi.DigestOf(action)
+
+	// Nothing is uploaded yet but the blobs
+	_, resp := execute(t, conn, actionDigest)
+	require.Equal(t, int32(codes.FailedPrecondition), resp.Status.GetCode())
+	var pf errdetails.PreconditionFailure
+	require.Len(t, resp.Status.Details, 1)
+	require.NoError(t, resp.Status.Details[0].UnmarshalTo(&pf))
+	require.Len(t, pf.Violations, 1)
+	assert.Equal(t, "MISSING", pf.Violations[0].Type)
+	assert.Equal(t, fmt.Sprintf("blobs/%s/%d", actionDigest.Hash, actionDigest.SizeBytes), pf.Violations[0].Subject)
+
+	upload(t, cas, sub, empty, root, cmd, action)
+	op, resp := execute(t, conn, actionDigest)
+	require.Equal(t, int32(codes.OK), resp.Status.GetCode(), resp.Status.GetMessage())
+	assert.False(t, resp.CachedResult)
+	res := resp.Result
+	require.Equal(t, int32(0), res.ExitCode)
+	assert.Equal(t, "hi\n", string(read(t, cas, res.StdoutDigest)))
+	assert.Equal(t, "oops\n", string(read(t, cas, res.StderrDigest)))
+	require.Len(t, res.OutputFiles, 1)
+	assert.Equal(t, "out.txt", res.OutputFiles[0].Path)
+	assert.Equal(t, input, read(t, cas, res.OutputFiles[0].Digest))
+
+	require.Len(t, res.OutputDirectories, 1)
+	var tree reapi.Tree
+	require.NoError(t, proto.Unmarshal(read(t, cas, res.OutputDirectories[0].TreeDigest), &tree))
+	require.Len(t, tree.Root.Files, 1)
+	assert.Equal(t, "f", tree.Root.Files[0].Name)
+	assert.Equal(t, "from script\n", string(read(t, cas, tree.Root.Files[0].Digest)))
+
+	// WaitExecution finds the finished operation
+	wait, err := reapi.NewExecutionClient(conn).WaitExecution(ctx, &reapi.WaitExecutionRequest{Name: op.Name})
+	require.NoError(t, err)
+	waited, err := wait.Recv()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(op, waited))
+
+	// The second time, the result is cached
+	_, resp = execute(t, conn, actionDigest)
+	assert.True(t, resp.CachedResult)
+	assert.True(t, proto.Equal(res, resp.Result))
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+)
+
+// splitRefs separates the outputs the client asked to leave in the
+// object store, by local path, from those to fetch. Outputs small
+// enough to be inline are always fetched.
+func splitRefs(fl protocol.FileList, paths []string) (fetch, refs protocol.FileList) {
+	want := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		want[p] = true
+	}
+	for _, f := range fl {
+		if want[f.Path] && len(f.Refs()) > 0 {
+			refs = append(refs, f)
+		} else {
+			fetch = append(fetch, f)
+		}
+	}
+	return fetch, refs
+}
+
+// Materialize downloads the contents of reference files, for clients
+// that need to read them locally.
+func (d *Daemon) Materialize(in *daemon.MaterializeArgs, out *daemon.MaterializeReply) error {
+	n, err := files.Materialize(d.ctx, d.store, in.Paths)
+	*out = daemon.MaterializeReply{Materialized: n}
+	return err
+}
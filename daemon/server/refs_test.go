@@ -0,0 +1,32 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitRefs(t *testing.T) {
+	inline := protocol.FileAndPath{Path: "/out/small.o", File: protocol.File{Blob: protocol.Blob{Bytes: []byte("x")}}}
+	obj := protocol.FileAndPath{Path: "/out/big.o", File: protocol.File{Blob: protocol.Blob{Ref: "abc"}}}
+	deps := protocol.FileAndPath{Path: "/out/big.d", File: protocol.File{Blob: protocol.Blob{Ref: "def"}}}
+
+	fetch, refs := splitRefs(protocol.FileList{inline, obj, deps}, []string{"/out/small.o", "/out/big.o"})
+	assert.Equal(t, protocol.FileList{inline, deps}, fetch)
+	assert.Equal(t, protocol.FileList{obj}, refs)
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/llama"
+)
+
+// After a region throttles us or fails, we route invocations to
+// other regions for this long before trying it again.
+const regionCooldown = 30 * time.Second
+
+// The region we report for invocations through Function URLs
+const regionURL = "url"
+
+type region struct {
+	name      string
+	lambda    *lambda.Lambda
+	downUntil time.Time
+}
+
+// regionSet tracks the health of each region we can invoke
+// functions in. Regions are tried in their configured order,
+// skipping any that have recently failed.
+type regionSet struct {
+	sync.Mutex
+	regions []*region
+}
+
+// order returns the regions to try, in order. Healthy regions come
+// first; regions that have recently failed are still tried as a last
+// resort.
+func (rs *regionSet) order(now time.Time) []*region {
+	rs.Lock()
+	defer rs.Unlock()
+	var up, down []*region
+	for _, r := range rs.regions {
+		if now.Before(r.downUntil) {
+			down = append(down, r)
+		} else {
+			up = append(up, r)
+		}
+	}
+	return append(up, down...)
+}
+
+func (rs *regionSet) markDown(r *region, now time.Time) {
+	rs.Lock()
+	defer rs.Unlock()
+	r.downUntil = now.Add(regionCooldown)
+}
+
+// shouldFailover reports whether an invocation error indicates a
+// problem with the region, rather than with the function or its
+// arguments.
+//
+// Throttling, or failing to connect at all, means the function never
+// ran, so it's always safe to try elsewhere. After a server error or
+// a failure partway through the request, the function may have run
+// anyway, so we only fail over if the invocation is `idempotent`.
+func shouldFailover(err error, idempotent bool) bool {
+	var ret *llama.ErrorReturn
+	if errors.As(err, &ret) {
+		return false
+	}
+	var reqerr awserr.RequestFailure
+	if errors.As(err, &reqerr) {
+		if reqerr.Code() == lambda.ErrCodeTooManyRequestsException ||
+			reqerr.StatusCode() == 429 {
+			return true
+		}
+		return idempotent && reqerr.StatusCode() >= 500
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		if awsErr.Code() != request.ErrCodeRequestError &&
+			awsErr.Code() != request.ErrCodeResponseTimeout {
+			return false
+		}
+		var opErr *net.OpError
+		if errors.As(awsErr.OrigErr(), &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return idempotent
+	}
+	return false
+}
+
+// tuneConcurrency feeds the result of a Lambda invocation back to the
+// scheduler's concurrency ceiling.
+func (d *Daemon) tuneConcurrency(err error) {
+	var ret *llama.ErrorReturn
+	if llama.IsThrottled(err) {
+		atomic.AddUint64(&d.stats.Throttles, 1)
+		d.sched.throttled(time.Now())
+	} else if err == nil || errors.As(err, &ret) {
+		// A function error still means Lambda ran it
+		d.sched.succeeded()
+	}
+}
+
+// invoke invokes a function in the first healthy region, failing
+// over to the next region if that region throttles or errors. It
+// returns the name of the region the function ran in, or "local" for
+// functions configured to run locally.
+func (d *Daemon) invoke(ctx context.Context, args *llama.InvokeArgs) (*llama.InvokeResult, string, error) {
+	if r, ok := d.local[args.Function]; ok {
+		atomic.AddUint64(&d.stats.Usage.Lambda_Requests, 1)
+		res, err := llama.InvokeLocal(ctx, r, d.store, args)
+		return res, "local", err
+	}
+	if d.packer.enabled() && d.packer.packable(&args.Spec) {
+		return d.packer.invoke(ctx, args)
+	}
+	return d.invokeLambda(ctx, args)
+}
+
+func (d *Daemon) invokeLambda(ctx context.Context, args *llama.InvokeArgs) (*llama.InvokeResult, string, error) {
+	// Every attempt is billed
+	atomic.AddUint64(&d.stats.Usage.Lambda_Requests, 1)
+	var res *llama.InvokeResult
+	name, err := d.invokeRegions(args.Function, args.Qualifier, args.Idempotent, func(svc llama.Sender, qualifier string) error {
+		a := *args
+		a.Qualifier = qualifier
+		var err error
+		res, err = llama.Invoke(ctx, svc, d.store, &a)
+		return err
+	})
+	return res, name, err
+}
+
+// invokeRegions calls `call` with the Lambda client of each region to
+// try in turn, and the qualifier to invoke `function` with there,
+// until one doesn't need to fail over. It returns the name of the
+// last region tried. Unless the invocation is `idempotent`, we only
+// fail over when we know it didn't run; see shouldFailover.
+//
+// A function with a Function URL is invoked there instead, once,
+// with the region "url"; the endpoint decides where it runs.
+func (d *Daemon) invokeRegions(function, variant string, idempotent bool, call func(svc llama.Sender, qualifier string) error) (string, error) {
+	if d.urls.Serves(function) {
+		err := call(d.urls, variant)
+		d.tuneConcurrency(err)
+		return regionURL, err
+	}
+	// An explicitly-chosen variant takes precedence over the
+	// warm pool
+	var warm string
+	if variant == "" {
+		var provision bool
+		warm, provision = d.warm.use(function, time.Now())
+		if provision {
+			go d.warm.provision(function)
+		}
+	}
+	var err error
+	var name string
+	for i, r := range d.regions.order(time.Now()) {
+		if i > 0 {
+			atomic.AddUint64(&d.stats.RegionFailovers, 1)
+		}
+		name = r.name
+		// We only keep a warm pool in the primary region
+		qualifier := variant
+		if warm != "" && r == d.regions.regions[0] {
+			qualifier = warm
+		}
+		err = call(&llama.LambdaSender{Lambda: r.lambda}, qualifier)
+		d.tuneConcurrency(err)
+		if err == nil || !shouldFailover(err, idempotent) {
+			return name, err
+		}
+		d.regions.markDown(r, time.Now())
+	}
+	return name, err
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/llama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionOrder(t *testing.T) {
+	a, b, c := &region{name: "a"}, &region{name: "b"}, &region{name: "c"}
+	rs := regionSet{regions: []*region{a, b, c}}
+	now := time.Now()
+
+	assert.Equal(t, []*region{a, b, c}, rs.order(now))
+	rs.markDown(a, now)
+	assert.Equal(t, []*region{b, c, a}, rs.order(now))
+	rs.markDown(c, now)
+	assert.Equal(t, []*region{b, a, c}, rs.order(now))
+	assert.Equal(t, []*region{a, b, c}, rs.order(now.Add(regionCooldown+time.Second)))
+}
+
+func TestShouldFailover(t *testing.T) {
+	// The SDK reports network errors as *url.Error
+	dial := &url.Error{Op: "Post", URL: "https://lambda", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	reset := &url.Error{Op: "Post", URL: "https://lambda", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	cases := []struct {
+		err error
+		// whether to fail over a non-idempotent, and an
+		// idempotent, invocation
+		want, wantIdempotent bool
+	}{
+		{&llama.ErrorReturn{Payload: []byte("oops")}, false, false},
+		{fmt.Errorf("Invoke(): %w",
+			awserr.NewRequestFailure(awserr.New(lambda.ErrCodeTooManyRequestsException, "slow down", nil), 429, "")), true, true},
+		{awserr.NewRequestFailure(awserr.New(lambda.ErrCodeServiceException, "", nil), 500, ""), false, true},
+		{awserr.NewRequestFailure(awserr.New(lambda.ErrCodeResourceNotFoundException, "", nil), 404, ""), false, false},
+		{fmt.Errorf("Invoke(): %w", awserr.New(request.ErrCodeRequestError, "send request failed", dial)), true, true},
+		{fmt.Errorf("Invoke(): %w", awserr.New(request.ErrCodeRequestError, "send request failed", reset)), false, true},
+		{awserr.New(request.ErrCodeResponseTimeout, "read timed out", nil), false, true},
+		{errors.New("something else"), false, false},
+	}
+	for i, tc := range cases {
+		assert.Equal(t, tc.want, shouldFailover(tc.err, false), "case %d: %v", i, tc.err)
+		assert.Equal(t, tc.wantIdempotent, shouldFailover(tc.err, true), "case %d (idempotent): %v", i, tc.err)
+	}
+}
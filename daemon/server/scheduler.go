@@ -0,0 +1,215 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+)
+
+// scheduler limits how many invocations run at once, both in total
+// and per priority class. When a slot frees up, it goes to the
+// longest-waiting invocation of the highest priority class that is
+// under its own limit, so a large batch job can't starve an
+// interactive build.
+//
+// Unless the schedule is Fixed or unlimited, the total is further
+// limited by a ceiling which the scheduler tunes from Lambda's
+// feedback, in the manner of TCP congestion control: the ceiling
+// grows by one for every successful invocation until the first
+// throttle ("slow start"), and by about one per ceiling's worth of
+// successes afterwards; each throttle halves it.
+type scheduler struct {
+	mu      sync.Mutex
+	limits  daemon.Schedule
+	running int64
+	classes [daemon.NumPriorities]schedClass
+
+	ceiling float64
+	// The ceiling at the last throttle; 0 while in slow start
+	threshold    float64
+	lastThrottle time.Time
+}
+
+type schedClass struct {
+	running int64
+	// Channels of waiting invocations, in arrival order. We
+	// close the channel to grant a waiter its slot.
+	waiting list.List
+}
+
+const (
+	// The ceiling we start at before we've heard from Lambda
+	initialCeiling = 32
+	// Throttles this soon after the last one are likely to come
+	// from invocations started before we backed off, so we
+	// don't back off again for them.
+	throttleHold = time.Second
+)
+
+func newScheduler(limits daemon.Schedule) *scheduler {
+	s := &scheduler{limits: limits, ceiling: initialCeiling}
+	s.clampLocked()
+	return s
+}
+
+func (s *scheduler) tuned() bool {
+	return !s.limits.Fixed && s.limits.Concurrency > 0
+}
+
+func (s *scheduler) clampLocked() {
+	if s.ceiling > float64(s.limits.Concurrency) {
+		s.ceiling = float64(s.limits.Concurrency)
+	}
+	if s.ceiling < 1 {
+		s.ceiling = 1
+	}
+}
+
+func (s *scheduler) class(p daemon.Priority) (daemon.Priority, *schedClass) {
+	if p < 0 || int(p) >= daemon.NumPriorities {
+		p = daemon.PriorityInteractive
+	}
+	return p, &s.classes[p]
+}
+
+func (s *scheduler) hasRoom(p daemon.Priority) bool {
+	if s.limits.Concurrency > 0 && s.running >= s.limits.Concurrency {
+		return false
+	}
+	if s.tuned() && s.running >= int64(s.ceiling) {
+		return false
+	}
+	limit := s.limits.Limit(p)
+	return limit <= 0 || s.classes[p].running < limit
+}
+
+// acquire waits for a slot for an invocation of priority `p`. On
+// success, the caller must release the slot once the invocation is
+// complete.
+func (s *scheduler) acquire(ctx context.Context, p daemon.Priority) error {
+	s.mu.Lock()
+	p, cls := s.class(p)
+	if cls.waiting.Len() == 0 && s.hasRoom(p) {
+		cls.running++
+		s.running++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := cls.waiting.PushBack(ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-ready:
+		// We were granted a slot as we gave up; hand it on.
+		s.releaseLocked(p)
+	default:
+		cls.waiting.Remove(elem)
+	}
+	return ctx.Err()
+}
+
+func (s *scheduler) release(p daemon.Priority) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, _ = s.class(p)
+	s.releaseLocked(p)
+}
+
+func (s *scheduler) releaseLocked(p daemon.Priority) {
+	s.classes[p].running--
+	s.running--
+	s.dispatchLocked()
+}
+
+// dispatchLocked grants free slots to waiting invocations in
+// priority order.
+func (s *scheduler) dispatchLocked() {
+	for p := daemon.Priority(0); int(p) < daemon.NumPriorities; p++ {
+		cls := &s.classes[p]
+		for cls.waiting.Len() > 0 && s.hasRoom(p) {
+			ready := cls.waiting.Remove(cls.waiting.Front()).(chan struct{})
+			cls.running++
+			s.running++
+			close(ready)
+		}
+	}
+}
+
+// waiting returns the number of invocations waiting in each class
+func (s *scheduler) waiting() [daemon.NumPriorities]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out [daemon.NumPriorities]int64
+	for i := range s.classes {
+		out[i] = int64(s.classes[i].waiting.Len())
+	}
+	return out
+}
+
+// succeeded records that Lambda accepted an invocation, and raises
+// the ceiling.
+func (s *scheduler) succeeded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tuned() {
+		return
+	}
+	if s.threshold == 0 || s.ceiling < s.threshold {
+		s.ceiling++
+	} else {
+		s.ceiling += 1 / s.ceiling
+	}
+	s.clampLocked()
+	s.dispatchLocked()
+}
+
+// throttled records that Lambda throttled an invocation at `now`, and
+// halves the ceiling.
+func (s *scheduler) throttled(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tuned() || now.Sub(s.lastThrottle) < throttleHold {
+		return
+	}
+	s.lastThrottle = now
+	s.ceiling /= 2
+	s.clampLocked()
+	s.threshold = s.ceiling
+}
+
+// limit returns the current ceiling on concurrent invocations, or 0
+// if there is none.
+func (s *scheduler) limit() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tuned() {
+		return s.limits.Concurrency
+	}
+	return int64(s.ceiling)
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// enqueue starts acquiring a slot of priority `p`, and waits until
+// the request is queued.
+func enqueue(t *testing.T, ctx context.Context, s *scheduler, p daemon.Priority) <-chan error {
+	before := s.waiting()[p]
+	done := make(chan error, 1)
+	go func() { done <- s.acquire(ctx, p) }()
+	require.Eventually(t, func() bool {
+		return s.waiting()[p] > before
+	}, time.Second, time.Millisecond)
+	return done
+}
+
+func granted(ch <-chan error) bool {
+	select {
+	case err := <-ch:
+		return err == nil
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func pending(ch <-chan error) bool {
+	select {
+	case <-ch:
+		return false
+	default:
+		return true
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	ctx := context.Background()
+	s := newScheduler(daemon.Schedule{Concurrency: 2})
+
+	require.NoError(t, s.acquire(ctx, daemon.PriorityBatch))
+	require.NoError(t, s.acquire(ctx, daemon.PriorityBatch))
+
+	batch1 := enqueue(t, ctx, s, daemon.PriorityBatch)
+	batch2 := enqueue(t, ctx, s, daemon.PriorityBatch)
+	interactive := enqueue(t, ctx, s, daemon.PriorityInteractive)
+	assert.Equal(t, [daemon.NumPriorities]int64{1, 2}, s.waiting())
+
+	s.release(daemon.PriorityBatch)
+	assert.True(t, granted(interactive), "interactive should jump the queue")
+	assert.True(t, pending(batch1))
+
+	s.release(daemon.PriorityBatch)
+	assert.True(t, granted(batch1), "batch is first-come first-served")
+	assert.True(t, pending(batch2))
+
+	s.release(daemon.PriorityInteractive)
+	assert.True(t, granted(batch2))
+	assert.Equal(t, [daemon.NumPriorities]int64{0, 0}, s.waiting())
+}
+
+func TestSchedulerClassLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newScheduler(daemon.Schedule{Concurrency: 3, Batch: 1})
+
+	require.NoError(t, s.acquire(ctx, daemon.PriorityBatch))
+	batch := enqueue(t, ctx, s, daemon.PriorityBatch)
+
+	// Batch work is at its limit, but interactive work still has
+	// room.
+	require.NoError(t, s.acquire(ctx, daemon.PriorityInteractive))
+	require.NoError(t, s.acquire(ctx, daemon.PriorityInteractive))
+	assert.True(t, pending(batch))
+
+	s.release(daemon.PriorityInteractive)
+	assert.True(t, pending(batch), "batch must wait for its own class")
+	s.release(daemon.PriorityBatch)
+	assert.True(t, granted(batch))
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := newScheduler(daemon.Schedule{Concurrency: 1})
+	require.NoError(t, s.acquire(context.Background(), daemon.PriorityInteractive))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := enqueue(t, ctx, s, daemon.PriorityBatch)
+	next := enqueue(t, context.Background(), s, daemon.PriorityBatch)
+	cancel()
+	assert.Equal(t, context.Canceled, <-cancelled)
+	assert.Equal(t, [daemon.NumPriorities]int64{0, 1}, s.waiting())
+
+	s.release(daemon.PriorityInteractive)
+	assert.True(t, granted(next))
+}
+
+func TestSchedulerUnlimited(t *testing.T) {
+	s := newScheduler(daemon.Schedule{})
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.acquire(context.Background(), daemon.PriorityBatch))
+	}
+}
+
+func TestSchedulerTuning(t *testing.T) {
+	s := newScheduler(daemon.Schedule{Concurrency: 100})
+	assert.Equal(t, int64(initialCeiling), s.limit())
+
+	// Slow start: +1 per success
+	for i := 0; i < 10; i++ {
+		s.succeeded()
+	}
+	assert.Equal(t, int64(initialCeiling+10), s.limit())
+
+	now := time.Now()
+	s.throttled(now)
+	assert.Equal(t, int64((initialCeiling+10)/2), s.limit())
+	// Throttles from invocations already in flight don't count
+	s.throttled(now.Add(throttleHold / 2))
+	assert.Equal(t, int64((initialCeiling+10)/2), s.limit())
+
+	// Additive increase: about +1 per ceiling's worth of
+	// successes
+	for i := 0; i < initialCeiling+10; i++ {
+		s.succeeded()
+	}
+	assert.Equal(t, int64((initialCeiling+10)/2+1), s.limit())
+
+	for i := 0; i < 20; i++ {
+		s.throttled(now.Add(time.Duration(i+1) * throttleHold))
+	}
+	assert.Equal(t, int64(1), s.limit(), "never below 1")
+
+	for i := 0; i < 10000; i++ {
+		s.succeeded()
+	}
+	assert.Equal(t, int64(100), s.limit(), "never above Concurrency")
+}
+
+func TestSchedulerTuningLimitsConcurrency(t *testing.T) {
+	ctx := context.Background()
+	s := newScheduler(daemon.Schedule{Concurrency: 10})
+	s.throttled(time.Now())
+	require.Equal(t, int64(5), s.limit())
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.acquire(ctx, daemon.PriorityInteractive))
+	}
+	waiter := enqueue(t, ctx, s, daemon.PriorityInteractive)
+
+	// Raising the ceiling admits a waiter without any release
+	for s.limit() == 5 {
+		s.succeeded()
+	}
+	assert.True(t, granted(waiter))
+}
+
+func TestSchedulerFixed(t *testing.T) {
+	s := newScheduler(daemon.Schedule{Concurrency: 100, Fixed: true})
+	s.throttled(time.Now())
+	assert.Equal(t, int64(100), s.limit())
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.acquire(context.Background(), daemon.PriorityBatch))
+	}
+}
@@ -23,16 +23,21 @@ import (
 	"net/rpc"
 	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
 	"runtime"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/gofrs/flock"
 	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/llama"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/runner"
 	"github.com/nelhage/llama/store"
 	"golang.org/x/sync/semaphore"
 )
@@ -44,8 +49,23 @@ type Daemon struct {
 	store    store.Store
 	session  *session.Session
 	lambda   *lambda.Lambda
+	regions  regionSet
+	local    map[string]*runner.Runner
+	warm     warmPool
+	retry    llama.RetryPolicy
+	trees    *files.TreeCache
+	budget   budget
+	sched    *scheduler
 
-	stats daemon.Stats
+	stats  daemon.Stats
+	status statusTracker
+	builds *buildTracker
+	queued int64
+
+	cancels cancelRegistry
+
+	drainer      drainer
+	drainTimeout time.Duration
 
 	llamaccSem *semaphore.Weighted
 
@@ -53,6 +73,30 @@ type Daemon struct {
 		sync.RWMutex
 		paths map[compilerAndLanguage][]string
 	}
+	includes *includeIndex
+	deps     *depsCache
+
+	toolchains *toolchainCache
+
+	codeHashes *codeHashCache
+
+	variants struct {
+		sync.Mutex
+		byFunction map[string][]llama.Variant
+	}
+
+	profiles *profileStore
+	packer   *packer
+
+	skipChecksums bool
+	inline        files.Inline
+	cache         daemon.CacheConfig
+	urls          *llama.FunctionURLs
+
+	prewarmed struct {
+		sync.Mutex
+		databases map[string]fileStamp
+	}
 }
 
 type compilerAndLanguage struct {
@@ -68,6 +112,86 @@ type StartArgs struct {
 	Session            *session.Session
 	IdleTimeout        time.Duration
 	LlamaCCConcurrency int64
+	// Additional regions to fail over to, in order, if the
+	// session's region is throttled or unavailable. Functions must
+	// be deployed under the same name in every region.
+	FailoverRegions []string
+	// Functions to run in local subprocesses instead of on
+	// Lambda, mapped to the command line to run
+	LocalFunctions map[string][]string
+	// If non-zero, provision this many warm instances of each
+	// function we invoke, releasing them after WarmPoolIdle
+	// without an invocation
+	WarmPoolSize int64
+	WarmPoolIdle time.Duration
+	// How to retry invocations which fail with transient errors
+	Retry llama.RetryPolicy
+	// Prices used to estimate costs, and the budget to hold them
+	// to
+	Pricing daemon.Pricing
+	Budget  daemon.Budget
+	// How many invocations to run at once, by priority
+	Schedule daemon.Schedule
+	// If set, also accept jobs from distcc clients on this
+	// address, and compile them with DistccFunction. Only clients
+	// in DistccAllow, or on the local machine if it is empty, may
+	// connect.
+	DistccAddr     string
+	DistccFunction string
+	DistccAllow    []*net.IPNet
+
+	// If set, join the icecream scheduler at this address as a
+	// compile server with IceccCapacity slots, accepting jobs on
+	// IceccAddr and compiling them with IceccFunction.
+	// IceccPlatform is the platform we advertise, which must
+	// match the function's.
+	IceccScheduler string
+	IceccAddr      string
+	IceccCapacity  int
+	IceccFunction  string
+	IceccPlatform  string
+
+	// If set, serve the Bazel Remote Execution API on this
+	// address, running actions with RemoteExecFunction unless
+	// their platform names another function. Only clients in
+	// RemoteExecAllow, or on the local machine if it is empty,
+	// may connect.
+	RemoteExecAddr     string
+	RemoteExecFunction string
+	RemoteExecAllow    []*net.IPNet
+
+	// Build sessions end after BuildIdle without an invocation,
+	// and their reports are written to BuildReportDir
+	BuildIdle      time.Duration
+	BuildReportDir string
+
+	// On SIGTERM or an interrupt, stop accepting invocations and
+	// wait up to DrainTimeout for the ones in flight before
+	// exiting. A second signal exits immediately.
+	DrainTimeout time.Duration
+
+	// Persist the resources used by jobs with a Profile to this
+	// file, so we can size their functions across restarts
+	ProfilePath string
+
+	// How to pack small invocations together
+	Packing daemon.Packing
+
+	// Don't check the outputs we fetch against the checksums the
+	// runtime records for them
+	SkipChecksums bool
+
+	// Which small inputs and outputs to carry in invocations
+	// themselves, rather than through the object store
+	Inline files.Inline
+
+	// The result-cache namespaces to use, unless an invocation
+	// overrides them
+	Cache daemon.CacheConfig
+
+	// If set, invoke the functions it has endpoints for through
+	// them, rather than the Lambda API
+	FunctionURLs *llama.FunctionURLs
 }
 
 const (
@@ -75,7 +199,7 @@ const (
 )
 
 func Start(ctx context.Context, args *StartArgs) error {
-	if err := os.MkdirAll(path.Dir(args.Path), 0700); err != nil {
+	if err := os.MkdirAll(filepath.Dir(args.Path), 0700); err != nil {
 		return err
 	}
 
@@ -98,6 +222,8 @@ func Start(ctx context.Context, args *StartArgs) error {
 		return err
 	}
 
+	grpcPath := daemon.GRPCSocketPath(args.Path)
+
 	srvCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -112,10 +238,48 @@ func Start(ctx context.Context, args *StartArgs) error {
 		store:    args.Store,
 		session:  args.Session,
 		lambda:   lambda.New(args.Session),
+		retry:    args.Retry,
+		trees:    files.NewTreeCache(),
+		budget:   newBudget(args.Budget, args.Pricing),
+		sched:    newScheduler(args.Schedule),
+		builds:   newBuildTracker(args.BuildIdle, args.BuildReportDir),
 
 		llamaccSem: semaphore.NewWeighted(concurrency),
+
+		drainTimeout: args.DrainTimeout,
+
+		skipChecksums: args.SkipChecksums,
+		inline:        args.Inline,
+		cache:         args.Cache,
+		urls:          args.FunctionURLs,
 	}
+	if daemon.drainTimeout <= 0 {
+		daemon.drainTimeout = DefaultDrainTimeout
+	}
+	daemon.regions.regions = []*region{{name: aws.StringValue(args.Session.Config.Region), lambda: daemon.lambda}}
+	for _, name := range args.FailoverRegions {
+		sess := args.Session.Copy(aws.NewConfig().WithRegion(name))
+		daemon.regions.regions = append(daemon.regions.regions, &region{name: name, lambda: lambda.New(sess)})
+	}
+	daemon.local = make(map[string]*runner.Runner)
+	for name, cmdline := range args.LocalFunctions {
+		daemon.local[name] = runner.New(args.Store, cmdline, "local")
+	}
+	daemon.warm.size = args.WarmPoolSize
+	daemon.warm.idle = args.WarmPoolIdle
+	daemon.warm.prov = &lambdaProvisioner{svc: daemon.lambda}
+	daemon.warm.functions = make(map[string]*warmFunction)
+	go daemon.warm.run(srvCtx.Done())
+	go daemon.builds.run(srvCtx.Done())
 	daemon.includePathCache.paths = make(map[compilerAndLanguage][]string)
+	daemon.includes = newIncludeIndex()
+	daemon.deps = newDepsCache()
+	daemon.toolchains = newToolchainCache()
+	daemon.codeHashes = newCodeHashCache()
+	daemon.variants.byFunction = make(map[string][]llama.Variant)
+	daemon.profiles = loadProfiles(args.ProfilePath, time.Now())
+	daemon.packer = newPacker(args.Packing, daemon.sendPack)
+	go daemon.profiles.run(srvCtx.Done())
 
 	extend := make(chan struct{})
 	go func() {
@@ -123,6 +287,61 @@ func Start(ctx context.Context, args *StartArgs) error {
 		cancel()
 	}()
 
+	if args.DistccAddr != "" {
+		l, err := net.Listen("tcp", args.DistccAddr)
+		if err != nil {
+			return fmt.Errorf("distcc: %w", err)
+		}
+		dcc := distccServer{
+			d:        &daemon,
+			function: args.DistccFunction,
+			allow:    args.DistccAllow,
+			extend:   extend,
+		}
+		go dcc.serve(srvCtx, l)
+	}
+
+	if args.IceccScheduler != "" {
+		l, err := net.Listen("tcp", args.IceccAddr)
+		if err != nil {
+			return fmt.Errorf("icecc: %w", err)
+		}
+		node := iceccNode{
+			d:         &daemon,
+			function:  args.IceccFunction,
+			scheduler: args.IceccScheduler,
+			port:      l.Addr().(*net.TCPAddr).Port,
+			capacity:  args.IceccCapacity,
+			platform:  args.IceccPlatform,
+			extend:    extend,
+		}
+		go node.serve(srvCtx, l)
+		go node.register(srvCtx)
+	}
+
+	if args.RemoteExecAddr != "" {
+		kv, ok := args.Store.(store.KeyValue)
+		if !ok {
+			return errors.New("remote execution: the store does not support named keys")
+		}
+		l, err := net.Listen("tcp", args.RemoteExecAddr)
+		if err != nil {
+			return fmt.Errorf("remote execution: %w", err)
+		}
+		re := &reapiServer{
+			d:        &daemon,
+			kv:       kv,
+			function: args.RemoteExecFunction,
+			allow:    args.RemoteExecAllow,
+			extend:   extend,
+		}
+		go re.serve(srvCtx, l)
+	}
+
+	if err := daemon.serveGRPC(srvCtx, grpcPath, extend); err != nil {
+		return fmt.Errorf("gRPC: %w", err)
+	}
+
 	var httpSrv http.Server
 	var rpcSrv rpc.Server
 	rpcSrv.Register(&daemon)
@@ -137,21 +356,72 @@ func Start(ctx context.Context, args *StartArgs) error {
 	go func() {
 		httpSrv.Serve(listener)
 	}()
+	go daemon.handleSignals(srvCtx)
 	<-srvCtx.Done()
 
 	httpSrv.Shutdown(ctx)
+	// Don't leave provisioned concurrency running once we're gone
+	daemon.warm.releaseAll()
+	daemon.builds.expire(true)
+	daemon.profiles.saveOrLog()
+	logging.Record(ctx, "daemon exiting",
+		"invocations", atomic.LoadUint64(&daemon.stats.Invocations),
+		"func_errors", atomic.LoadUint64(&daemon.stats.FunctionErrors),
+		"other_errors", atomic.LoadUint64(&daemon.stats.OtherErrors),
+		"cache_hits", atomic.LoadUint64(&daemon.stats.CacheHits),
+	)
 	return nil
 }
 
+// DialWithAutostart connects to the daemon on sockPath, starting one
+// if none is running and replacing one too old to speak our
+// protocol.
 func DialWithAutostart(ctx context.Context, sockPath string, urlPath string) (*daemon.Client, error) {
-	cl, err := daemon.DialPath(ctx, sockPath, urlPath)
+	cl, err := dialCurrent(ctx, sockPath, urlPath)
+	if err == nil || errors.Is(err, ErrDaemonTooNew) {
+		return cl, err
+	}
+	if old, ok := err.(*outdatedError); ok {
+		old.client.Close()
+	}
+
+	// Serialize starting and upgrading the daemon, so the jobs of
+	// a parallel build don't all try at once.
+	lk := flock.New(sockPath + ".start.lock")
+	if _, err := lk.TryLockContext(ctx, 10*time.Millisecond); err != nil {
+		return nil, err
+	}
+	defer lk.Unlock()
+
+	// Someone else may have beaten us to it while we waited.
+	cl, err = dialCurrent(ctx, sockPath, urlPath)
+	if err == nil || errors.Is(err, ErrDaemonTooNew) {
+		return cl, err
+	}
+	if old, ok := err.(*outdatedError); ok {
+		if err := replaceOutdated(ctx, sockPath, old); err != nil {
+			return nil, err
+		}
+	}
+
+	cl, err = autostart(ctx, sockPath, urlPath)
+	if err != nil {
+		return nil, err
+	}
+	pong, err := cl.Ping(&daemon.PingArgs{ClientVersion: daemon.Version()})
 	if err == nil {
-		return cl, nil
+		err = checkVersion(pong)
 	}
-	cmd := exec.Command("llama", "daemon", "-autostart", "-path", sockPath)
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setsid: true,
+	if err != nil {
+		cl.Close()
+		return nil, fmt.Errorf("started daemon: %w (is the llama on your $PATH out of date?)", err)
 	}
+	return cl, nil
+}
+
+func autostart(ctx context.Context, sockPath string, urlPath string) (*daemon.Client, error) {
+	cmd := exec.Command("llama", "daemon", "-autostart", "-path", sockPath)
+	cmd.SysProcAttr = DetachedProcAttr()
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
@@ -180,7 +450,7 @@ func DialWithAutostart(ctx context.Context, sockPath string, urlPath string) (*d
 	}()
 	for {
 		select {
-		case cl = <-connected:
+		case cl := <-connected:
 			return cl, nil
 		case err := <-exitStatus:
 			if err == nil {
@@ -226,6 +496,8 @@ loop:
 }
 
 func (d *Daemon) acquireSem(ctx context.Context) {
+	atomic.AddInt64(&d.queued, 1)
+	defer atomic.AddInt64(&d.queued, -1)
 	d.llamaccSem.Acquire(ctx, 1)
 }
 
@@ -0,0 +1,63 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	"github.com/nelhage/llama/tracing"
+)
+
+func (d *Daemon) Stage(in *daemon.StageArgs, out *daemon.StageReply) error {
+	for _, p := range in.Paths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("Stage: path %q is not absolute", p)
+		}
+	}
+	d.stage(in.Paths)
+	return nil
+}
+
+// stage uploads `paths` in the background. With an S3 store, an
+// invocation that needs one of them while it is still on its way
+// waits for that upload rather than starting another, so staging
+// files while llamacc is still looking for the rest of a
+// compilation's inputs overlaps reading, hashing and uploading them
+// with the search. Files small enough to send inline aren't stored.
+func (d *Daemon) stage(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	atomic.AddUint64(&d.stats.StagedFiles, uint64(len(paths)))
+	go d.stageNow(d.ctx, paths)
+}
+
+func (d *Daemon) stageNow(ctx context.Context, paths []string) {
+	ctx, span := tracing.StartSpan(ctx, "stage")
+	defer span.End()
+	span.AddField("files", len(paths))
+	var list files.List
+	for _, p := range paths {
+		list = list.Append(files.Mapped{Local: files.LocalFile{Path: p}})
+	}
+	// Errors surface, if they matter, when the invocation that
+	// needs the file uploads it itself
+	list.UploadInline(ctx, d.store, nil, d.inline)
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/files"
+	protofiles "github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStage(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("int x;\n", 1000)
+	small := strings.Repeat("int y;\n", 100)
+	writeTestFile(t, dir, "small.h", small)
+	writeTestFile(t, dir, "big.h", big)
+
+	ctx := context.Background()
+	st := store.InMemory()
+	d := &Daemon{ctx: ctx, store: st, inline: files.DefaultInline}
+
+	err := d.Stage(&daemon.StageArgs{Paths: []string{"big.h"}}, &daemon.StageReply{})
+	assert.Error(t, err, "relative paths are rejected")
+
+	d.stageNow(ctx, []string{
+		filepath.Join(dir, "small.h"),
+		filepath.Join(dir, "big.h"),
+		filepath.Join(dir, "gone.h"),
+	})
+	// The big file is stored; the small one would be sent inline
+	sizes, err := protofiles.UploadSizes(ctx, st, [][]byte{[]byte(big), []byte(small)})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, int64(len(small))}, sizes)
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+)
+
+const maxRecentFailures = 20
+
+// statusTracker records the invocations currently in flight and the
+// most recent failures, for `llama top`.
+type statusTracker struct {
+	sync.Mutex
+	nextId   uint64
+	active   map[uint64]daemon.InvocationStatus
+	failures []daemon.FailureStatus
+}
+
+func (t *statusTracker) start(function, desc, invocationID string) uint64 {
+	t.Lock()
+	defer t.Unlock()
+	if t.active == nil {
+		t.active = make(map[uint64]daemon.InvocationStatus)
+	}
+	id := t.nextId
+	t.nextId++
+	t.active[id] = daemon.InvocationStatus{
+		Function:    function,
+		Description: desc,
+		Started:     time.Now(),
+
+		InvocationID: invocationID,
+	}
+	return id
+}
+
+// invoked records the region and Lambda request ID an invocation ran
+// under.
+func (t *statusTracker) invoked(id uint64, region, requestID string) {
+	t.Lock()
+	defer t.Unlock()
+	if inv, ok := t.active[id]; ok {
+		inv.Region = region
+		inv.RequestID = requestID
+		t.active[id] = inv
+	}
+}
+
+// finish marks an invocation complete. If `failure` is non-empty, the
+// invocation is recorded as a recent failure.
+func (t *statusTracker) finish(id uint64, failure string) {
+	t.Lock()
+	defer t.Unlock()
+	inv := t.active[id]
+	delete(t.active, id)
+	if failure == "" {
+		return
+	}
+	t.failures = append(t.failures, daemon.FailureStatus{
+		Time:        time.Now(),
+		Function:    inv.Function,
+		Description: inv.Description,
+		Error:       failure,
+
+		InvocationID: inv.InvocationID,
+		Region:       inv.Region,
+		RequestID:    inv.RequestID,
+	})
+	if len(t.failures) > maxRecentFailures {
+		t.failures = t.failures[len(t.failures)-maxRecentFailures:]
+	}
+}
+
+func (t *statusTracker) snapshot(out *daemon.StatusReply) {
+	t.Lock()
+	defer t.Unlock()
+	out.InFlight = make([]daemon.InvocationStatus, 0, len(t.active))
+	for _, inv := range t.active {
+		out.InFlight = append(out.InFlight, inv)
+	}
+	sort.Slice(out.InFlight, func(i, j int) bool {
+		return out.InFlight[i].Started.Before(out.InFlight[j].Started)
+	})
+	out.RecentFailures = append([]daemon.FailureStatus(nil), t.failures...)
+}
+
+func (d *Daemon) Status(in *daemon.StatusArgs, out *daemon.StatusReply) error {
+	var stats daemon.StatsReply
+	if err := d.GetDaemonStats(&daemon.StatsArgs{}, &stats); err != nil {
+		return err
+	}
+	*out = daemon.StatusReply{
+		Stats:   stats.Stats,
+		Cost:    stats.Cost,
+		Queued:  atomic.LoadInt64(&d.queued),
+		Waiting: d.sched.waiting(),
+	}
+	d.status.snapshot(out)
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadStream(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	kv := st.(store.KeyValue)
+	d := &Daemon{ctx: ctx, store: st}
+	read := func(stream string, seq int) (daemon.ReadStreamReply, error) {
+		var out daemon.ReadStreamReply
+		err := d.ReadStream(&daemon.ReadStreamArgs{Stream: stream, Seq: seq}, &out)
+		return out, err
+	}
+
+	// A stream nothing has been published to yet
+	out, err := read("missing", 0)
+	require.NoError(t, err)
+	assert.False(t, out.Found)
+
+	// A stream the function has published part of
+	data, err := json.Marshal(&protocol.StreamChunk{Stdout: []byte("out"), Stderr: []byte("err")})
+	require.NoError(t, err)
+	require.NoError(t, kv.SetKey(ctx, protocol.StreamChunkKey("partial", 0), data))
+	out, err = read("partial", 0)
+	require.NoError(t, err)
+	assert.Equal(t, daemon.ReadStreamReply{Found: true, Stdout: []byte("out"), Stderr: []byte("err")}, out)
+	out, err = read("partial", 1)
+	require.NoError(t, err)
+	assert.False(t, out.Found)
+
+	require.NoError(t, kv.SetKey(ctx, protocol.StreamChunkKey("partial", 1), []byte(`{"stdout":`)))
+	out, err = read("partial", 1)
+	assert.Error(t, err, "a corrupt chunk")
+	assert.False(t, out.Found)
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/llama"
+)
+
+// toolchainCache remembers what we learned checking local compilers
+// against the toolchains recorded on functions, so that probing the
+// compiler and asking Lambda about the function happens once per
+// build, not once per compilation.
+type toolchainCache struct {
+	mu     sync.Mutex
+	local  map[string]probedToolchain
+	remote map[string]recordedToolchain
+	warned map[string]bool
+}
+
+type probedToolchain struct {
+	stamp     fileStamp
+	toolchain llama.Toolchain
+	err       error
+}
+
+type recordedToolchain struct {
+	fetched   time.Time
+	toolchain string
+}
+
+// How long we trust a function's recorded toolchain before asking
+// Lambda again, so that a long-running daemon notices a `llama
+// toolchain sync`.
+const toolchainTTL = time.Minute
+
+func newToolchainCache() *toolchainCache {
+	return &toolchainCache{
+		local:  make(map[string]probedToolchain),
+		remote: make(map[string]recordedToolchain),
+		warned: make(map[string]bool),
+	}
+}
+
+// localToolchain identifies the compiler at `path` with `probe`,
+// unless it hasn't changed since we last did.
+func (c *toolchainCache) localToolchain(path string, probe func(string) (llama.Toolchain, error)) (llama.Toolchain, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return llama.Toolchain{}, err
+	}
+	stamp := stampOf(st)
+	c.mu.Lock()
+	ent, ok := c.local[path]
+	c.mu.Unlock()
+	if ok && ent.stamp == stamp {
+		return ent.toolchain, ent.err
+	}
+	tc, err := probe(path)
+	c.mu.Lock()
+	c.local[path] = probedToolchain{stamp: stamp, toolchain: tc, err: err}
+	c.mu.Unlock()
+	return tc, err
+}
+
+// recordedToolchain returns the toolchain recorded on `function`,
+// calling `fetch` to look it up if we haven't recently.
+func (c *toolchainCache) recordedToolchain(function string, now time.Time, fetch func() (string, error)) (string, error) {
+	c.mu.Lock()
+	ent, ok := c.remote[function]
+	c.mu.Unlock()
+	if ok && now.Sub(ent.fetched) < toolchainTTL {
+		return ent.toolchain, nil
+	}
+	tc, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.remote[function] = recordedToolchain{fetched: now, toolchain: tc}
+	c.mu.Unlock()
+	return tc, nil
+}
+
+// firstWarning reports whether this is the first time we've been
+// asked to warn about `key`.
+func (c *toolchainCache) firstWarning(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warned[key] {
+		return false
+	}
+	c.warned[key] = true
+	return true
+}
+
+func (d *Daemon) CheckToolchain(in *daemon.CheckToolchainArgs, out *daemon.CheckToolchainReply) error {
+	if _, ok := d.local[in.Function]; ok {
+		// Local functions run our own compiler
+		return nil
+	}
+	if !filepath.IsAbs(in.Compiler) {
+		return fmt.Errorf("must pass absolute path: %s", in.Compiler)
+	}
+	recorded, err := d.toolchains.recordedToolchain(in.Function, time.Now(), func() (string, error) {
+		return llama.FunctionToolchain(d.ctx, d.lambda, in.Function)
+	})
+	if err != nil {
+		return fmt.Errorf("CheckToolchain: %w", err)
+	}
+	if recorded == "" {
+		// Nothing to check against
+		return nil
+	}
+	remote, err := llama.ParseToolchain(recorded)
+	if err != nil {
+		return fmt.Errorf("CheckToolchain: function %s: %w", in.Function, err)
+	}
+	local, err := d.toolchains.localToolchain(in.Compiler, llama.ProbeCompiler)
+	if err != nil {
+		return fmt.Errorf("CheckToolchain: %w", err)
+	}
+	out.Local = local.String()
+	out.Remote = remote.String()
+	out.Mismatch = local.Mismatch(remote, in.Target)
+	if out.Mismatch != "" {
+		out.First = d.toolchains.firstWarning(in.Function + "\x00" + in.Compiler + "\x00" + in.Target)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/llama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolchainCacheLocal(t *testing.T) {
+	dir := t.TempDir()
+	cc := filepath.Join(dir, "cc")
+	require.NoError(t, ioutil.WriteFile(cc, []byte("gcc 9"), 0755))
+
+	probes := 0
+	probe := func(path string) (llama.Toolchain, error) {
+		probes++
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return llama.Toolchain{}, err
+		}
+		return llama.Toolchain{Family: "gcc", Version: string(data[len("gcc "):]), Machine: "x86_64-linux-gnu"}, nil
+	}
+
+	c := newToolchainCache()
+	tc, err := c.localToolchain(cc, probe)
+	require.NoError(t, err)
+	assert.Equal(t, "9", tc.Version)
+	_, err = c.localToolchain(cc, probe)
+	require.NoError(t, err)
+	assert.Equal(t, 1, probes)
+
+	// Replacing the compiler probes it again
+	require.NoError(t, ioutil.WriteFile(cc, []byte("gcc 10"), 0755))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(cc, old, old))
+	tc, err = c.localToolchain(cc, probe)
+	require.NoError(t, err)
+	assert.Equal(t, "10", tc.Version)
+	assert.Equal(t, 2, probes)
+
+	_, err = c.localToolchain(filepath.Join(dir, "missing"), probe)
+	assert.Error(t, err)
+}
+
+func TestToolchainCacheRecorded(t *testing.T) {
+	c := newToolchainCache()
+	now := time.Now()
+	fetches := 0
+	fetch := func() (string, error) {
+		fetches++
+		return "gcc 9.3.0 x86_64-linux-gnu", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		tc, err := c.recordedToolchain("gcc", now, fetch)
+		require.NoError(t, err)
+		assert.Equal(t, "gcc 9.3.0 x86_64-linux-gnu", tc)
+	}
+	assert.Equal(t, 1, fetches)
+
+	_, err := c.recordedToolchain("gcc", now.Add(toolchainTTL), fetch)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fetches)
+
+	// Failures aren't cached
+	_, err = c.recordedToolchain("clang", now, func() (string, error) {
+		return "", errors.New("no such function")
+	})
+	assert.Error(t, err)
+	_, err = c.recordedToolchain("clang", now, fetch)
+	require.NoError(t, err)
+	assert.Equal(t, 3, fetches)
+
+	assert.True(t, c.firstWarning("gcc"))
+	assert.False(t, c.firstWarning("gcc"))
+	assert.True(t, c.firstWarning("clang"))
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"io/ioutil"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol/files"
+)
+
+// CheckUploads reports which of a client's local files the object
+// store already holds, for `LLAMACC_EXPLAIN`.
+func (d *Daemon) CheckUploads(in *daemon.CheckUploadsArgs, out *daemon.CheckUploadsReply) error {
+	out.Files = make([]daemon.UploadCheck, len(in.Paths))
+	contents := make([][]byte, len(in.Paths))
+	for i, path := range in.Paths {
+		out.Files[i].Path = path
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			out.Files[i].Err = err.Error()
+			continue
+		}
+		out.Files[i].Size = int64(len(data))
+		out.Files[i].Inline = files.Inline(data) || len(data) <= d.inline.Limit
+		if !out.Files[i].Inline {
+			contents[i] = data
+		}
+	}
+	sizes, err := files.UploadSizes(d.ctx, d.store, contents)
+	if err != nil {
+		return err
+	}
+	for i, n := range sizes {
+		out.Files[i].Upload = n
+	}
+	return nil
+}
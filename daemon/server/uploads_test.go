@@ -0,0 +1,56 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nelhage/llama/daemon"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUploads(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("int x;\n", 100)
+	writeTestFile(t, dir, "small.h", "#pragma once\n")
+	writeTestFile(t, dir, "stored.h", big)
+	writeTestFile(t, dir, "new.h", big+"int y;\n")
+
+	ctx := context.Background()
+	st := store.InMemory()
+	_, err := files.NewBlob(ctx, st, []byte(big))
+	require.NoError(t, err)
+	d := &Daemon{ctx: ctx, store: st}
+
+	paths := []string{"small.h", "stored.h", "new.h", "gone.h"}
+	for i, p := range paths {
+		paths[i] = filepath.Join(dir, p)
+	}
+	var out daemon.CheckUploadsReply
+	require.NoError(t, d.CheckUploads(&daemon.CheckUploadsArgs{Paths: paths}, &out))
+	require.Len(t, out.Files, 4)
+	assert.True(t, out.Files[0].Inline)
+	assert.Equal(t, int64(0), out.Files[0].Upload)
+	assert.False(t, out.Files[1].Inline)
+	assert.Equal(t, int64(0), out.Files[1].Upload)
+	assert.Equal(t, int64(len(big)+7), out.Files[2].Upload)
+	assert.NotEmpty(t, out.Files[3].Err)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nelhage/llama/llama"
+)
+
+// listVariants returns the variants of `function`, including the
+// function itself, first. We list each function's variants once, so
+// restart the daemon after adding variants.
+func (d *Daemon) listVariants(function string) ([]llama.Variant, error) {
+	d.variants.Lock()
+	defer d.variants.Unlock()
+	variants, ok := d.variants.byFunction[function]
+	if !ok {
+		var err error
+		variants, err = llama.ListVariants(d.lambda, function)
+		if err != nil {
+			return nil, fmt.Errorf("listing variants of %s: %w", function, err)
+		}
+		d.variants.byFunction[function] = variants
+	}
+	return variants, nil
+}
+
+// pickVariant returns the smallest variant of `function` with at
+// least the requested memory and timeout, or the zero Variant, for
+// the function itself, if there is no requirement.
+func (d *Daemon) pickVariant(function string, memory int64, timeout time.Duration) (llama.Variant, error) {
+	if memory == 0 && timeout == 0 {
+		return llama.Variant{}, nil
+	}
+	if _, ok := d.local[function]; ok {
+		return llama.Variant{}, nil
+	}
+	variants, err := d.listVariants(function)
+	if err != nil {
+		return llama.Variant{}, err
+	}
+	v, err := llama.PickVariant(variants, memory, timeout)
+	if err != nil {
+		return llama.Variant{}, fmt.Errorf("%s: %w", function, err)
+	}
+	return v, nil
+}
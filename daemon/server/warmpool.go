@@ -0,0 +1,225 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/daemon"
+)
+
+type provisioner interface {
+	provision(function string, n int64) error
+	release(function string) error
+}
+
+// Lambda takes a few minutes to allocate provisioned concurrency. We
+// check on it every provisionPoll, and give up after
+// provisionTimeout.
+const (
+	provisionPoll    = 15 * time.Second
+	provisionTimeout = 15 * time.Minute
+)
+
+type lambdaProvisioner struct {
+	svc *lambda.Lambda
+	// If nonzero, overrides provisionPoll
+	poll time.Duration
+}
+
+// provision asks Lambda for `n` warm instances of `function`, and
+// waits until they're ready; until then, invoking the warm-pool alias
+// would just cold-start instances on demand.
+func (p *lambdaProvisioner) provision(function string, n int64) error {
+	_, err := p.svc.PutProvisionedConcurrencyConfig(&lambda.PutProvisionedConcurrencyConfigInput{
+		FunctionName:                    aws.String(function),
+		Qualifier:                       aws.String(daemon.WarmPoolAlias),
+		ProvisionedConcurrentExecutions: aws.Int64(n),
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.waitReady(function); err != nil {
+		// Don't pay for instances we won't use
+		if rerr := p.release(function); rerr != nil {
+			log.Printf("warm pool: releasing %s: %s", function, rerr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *lambdaProvisioner) waitReady(function string) error {
+	poll := p.poll
+	if poll == 0 {
+		poll = provisionPoll
+	}
+	deadline := time.Now().Add(provisionTimeout)
+	for {
+		cfg, err := p.svc.GetProvisionedConcurrencyConfig(&lambda.GetProvisionedConcurrencyConfigInput{
+			FunctionName: aws.String(function),
+			Qualifier:    aws.String(daemon.WarmPoolAlias),
+		})
+		if err != nil {
+			return err
+		}
+		switch aws.StringValue(cfg.Status) {
+		case lambda.ProvisionedConcurrencyStatusEnumReady:
+			return nil
+		case lambda.ProvisionedConcurrencyStatusEnumFailed:
+			return fmt.Errorf("provisioning failed: %s", aws.StringValue(cfg.StatusReason))
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not provisioned after %s", provisionTimeout)
+		}
+		time.Sleep(poll)
+	}
+}
+
+func (p *lambdaProvisioner) release(function string) error {
+	_, err := p.svc.DeleteProvisionedConcurrencyConfig(&lambda.DeleteProvisionedConcurrencyConfigInput{
+		FunctionName: aws.String(function),
+		Qualifier:    aws.String(daemon.WarmPoolAlias),
+	})
+	return err
+}
+
+type warmState int
+
+const (
+	warmCold warmState = iota
+	// Until Lambda reports the instances ready, which takes a
+	// few minutes, we invoke $LATEST
+	warmPending
+	warmReady
+	// We failed to provision this function, most likely
+	// because it has no warm-pool alias; don't try again.
+	warmUnsupported
+)
+
+type warmFunction struct {
+	state    warmState
+	lastUsed time.Time
+}
+
+// warmPool manages provisioned concurrency for the functions we
+// invoke. The first time we invoke a function we ask Lambda to keep
+// `size` instances of it warm, and once we haven't invoked it for
+// `idle` we release them again, since provisioned concurrency is
+// billed whether or not it is used.
+type warmPool struct {
+	sync.Mutex
+	size      int64
+	idle      time.Duration
+	prov      provisioner
+	functions map[string]*warmFunction
+}
+
+// use records an invocation of `function`. It returns the qualifier
+// to invoke the function with, and whether the caller should start
+// provisioning the function.
+func (p *warmPool) use(function string, now time.Time) (string, bool) {
+	if p.size == 0 {
+		return "", false
+	}
+	p.Lock()
+	defer p.Unlock()
+	f, ok := p.functions[function]
+	if !ok {
+		f = &warmFunction{}
+		p.functions[function] = f
+	}
+	f.lastUsed = now
+	switch f.state {
+	case warmCold:
+		f.state = warmPending
+		return "", true
+	case warmReady:
+		return daemon.WarmPoolAlias, false
+	default:
+		return "", false
+	}
+}
+
+func (p *warmPool) provision(function string) {
+	err := p.prov.provision(function, p.size)
+	p.Lock()
+	defer p.Unlock()
+	f := p.functions[function]
+	if f.state != warmPending {
+		// We released it while waiting, on the way out
+		return
+	}
+	if err != nil {
+		log.Printf("warm pool: provisioning %s: %s", function, err.Error())
+		f.state = warmUnsupported
+		return
+	}
+	f.state = warmReady
+}
+
+// reap releases the provisioned concurrency of any functions which
+// have been idle since before `cutoff`.
+func (p *warmPool) reap(cutoff time.Time) {
+	p.releaseIf(func(f *warmFunction) bool {
+		return f.state == warmReady && f.lastUsed.Before(cutoff)
+	})
+}
+
+// releaseAll releases the provisioned concurrency of every function,
+// including any still being provisioned, for when the daemon exits.
+func (p *warmPool) releaseAll() {
+	p.releaseIf(func(f *warmFunction) bool {
+		return f.state == warmReady || f.state == warmPending
+	})
+}
+
+func (p *warmPool) releaseIf(pred func(f *warmFunction) bool) {
+	p.Lock()
+	var idle []string
+	for name, f := range p.functions {
+		if pred(f) {
+			f.state = warmCold
+			idle = append(idle, name)
+		}
+	}
+	p.Unlock()
+	for _, name := range idle {
+		if err := p.prov.release(name); err != nil {
+			log.Printf("warm pool: releasing %s: %s", name, err.Error())
+		}
+	}
+}
+
+func (p *warmPool) run(done <-chan struct{}) {
+	if p.size == 0 {
+		return
+	}
+	tick := time.NewTicker(p.idle / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-tick.C:
+			p.reap(now.Add(-p.idle))
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvisioner struct {
+	provisioned map[string]int64
+	fail        map[string]bool
+}
+
+func (f *fakeProvisioner) provision(function string, n int64) error {
+	if f.fail[function] {
+		return errors.New("no such alias")
+	}
+	f.provisioned[function] = n
+	return nil
+}
+
+func (f *fakeProvisioner) release(function string) error {
+	delete(f.provisioned, function)
+	return nil
+}
+
+func TestWarmPool(t *testing.T) {
+	prov := &fakeProvisioner{
+		provisioned: make(map[string]int64),
+		fail:        map[string]bool{"broken": true},
+	}
+	p := warmPool{
+		size:      8,
+		idle:      time.Minute,
+		prov:      prov,
+		functions: make(map[string]*warmFunction),
+	}
+	now := time.Now()
+
+	q, start := p.use("gcc", now)
+	assert.Equal(t, "", q)
+	assert.True(t, start)
+	// Until provisioning completes, invoke $LATEST
+	q, start = p.use("gcc", now)
+	assert.Equal(t, "", q)
+	assert.False(t, start)
+
+	p.provision("gcc")
+	assert.Equal(t, map[string]int64{"gcc": 8}, prov.provisioned)
+	q, _ = p.use("gcc", now.Add(30*time.Second))
+	assert.Equal(t, daemon.WarmPoolAlias, q)
+
+	_, start = p.use("broken", now)
+	assert.True(t, start)
+	p.provision("broken")
+	q, start = p.use("broken", now)
+	assert.Equal(t, "", q)
+	assert.False(t, start, "don't retry functions we failed to provision")
+
+	p.reap(now)
+	assert.Contains(t, prov.provisioned, "gcc")
+	p.reap(now.Add(time.Minute))
+	assert.Empty(t, prov.provisioned)
+
+	q, start = p.use("gcc", now.Add(2*time.Minute))
+	assert.Equal(t, "", q)
+	assert.True(t, start)
+}
+
+func TestWarmPoolReleaseAll(t *testing.T) {
+	prov := &fakeProvisioner{provisioned: make(map[string]int64)}
+	p := warmPool{
+		size:      8,
+		idle:      time.Minute,
+		prov:      prov,
+		functions: make(map[string]*warmFunction),
+	}
+	now := time.Now()
+	p.use("gcc", now)
+	p.provision("gcc")
+	// Still waiting on Lambda for this one
+	p.use("clang", now)
+	prov.provisioned["clang"] = 8
+
+	p.releaseAll()
+	assert.Empty(t, prov.provisioned)
+
+	// Provisioning finishing afterwards doesn't bring it back
+	p.provision("clang")
+	q, _ := p.use("clang", now)
+	assert.Equal(t, "", q)
+}
+
+// fakeProvisionedConcurrency serves Lambda's provisioned concurrency
+// API, reporting each configuration ready after `polls` checks, or
+// failed if `fail` is set.
+type fakeProvisionedConcurrency struct {
+	mu       sync.Mutex
+	polls    int
+	fail     bool
+	checks   int
+	released bool
+}
+
+func (f *fakeProvisionedConcurrency) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := lambda.ProvisionedConcurrencyStatusEnumInProgress
+	switch r.Method {
+	case "GET":
+		f.checks++
+		if f.fail {
+			status = lambda.ProvisionedConcurrencyStatusEnumFailed
+		} else if f.checks > f.polls {
+			status = lambda.ProvisionedConcurrencyStatusEnumReady
+		}
+	case "DELETE":
+		f.released = true
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"Status": status})
+}
+
+func TestLambdaProvisioner(t *testing.T) {
+	fake := &fakeProvisionedConcurrency{polls: 2}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	sess, err := session.NewSession(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-west-2").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	require.NoError(t, err)
+	prov := &lambdaProvisioner{svc: lambda.New(sess), poll: time.Millisecond}
+
+	require.NoError(t, prov.provision("gcc", 8))
+	assert.Equal(t, 3, fake.checks, "waits until ready")
+	assert.False(t, fake.released)
+
+	fake.fail = true
+	assert.Error(t, prov.provision("gcc", 8))
+	assert.True(t, fake.released, "releases what failed to provision")
+}
+
+func TestWarmPool_Disabled(t *testing.T) {
+	var p warmPool
+	q, start := p.use("gcc", time.Now())
+	assert.Equal(t, "", q)
+	assert.False(t, start)
+}
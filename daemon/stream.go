@@ -0,0 +1,91 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+const StreamPollInterval = 500 * time.Millisecond
+
+// An OutputStream relays the output of an invocation, published
+// under InvokeWithFilesArgs.Stream, as the remote command produces
+// it.
+type OutputStream struct {
+	ID string
+
+	client   *Client
+	stdout   io.Writer
+	stderr   io.Writer
+	wroteOut int
+	wroteErr int
+	done     chan struct{}
+	exited   chan struct{}
+}
+
+// StartStream allocates a new stream ID, and starts polling the
+// daemon for output published under it, copying it to `stdout` and
+// `stderr`. Pass the stream's ID in InvokeWithFilesArgs.Stream.
+func (c *Client) StartStream(stdout, stderr io.Writer) *OutputStream {
+	var id [16]byte
+	if _, err := rand.Reader.Read(id[:]); err != nil {
+		panic(fmt.Sprintf("rand: %s", err.Error()))
+	}
+	s := &OutputStream{
+		ID:     hex.EncodeToString(id[:]),
+		client: c,
+		stdout: stdout,
+		stderr: stderr,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	go s.poll()
+	return s
+}
+
+func (s *OutputStream) poll() {
+	defer close(s.exited)
+	seq := 0
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-time.After(StreamPollInterval):
+		}
+		for {
+			chunk, err := s.client.ReadStream(&ReadStreamArgs{Stream: s.ID, Seq: seq})
+			if err != nil || !chunk.Found {
+				break
+			}
+			s.stdout.Write(chunk.Stdout)
+			s.stderr.Write(chunk.Stderr)
+			s.wroteOut += len(chunk.Stdout)
+			s.wroteErr += len(chunk.Stderr)
+			seq++
+		}
+	}
+}
+
+// Stop halts polling, and returns the number of bytes of stdout and
+// stderr which have been written so far.
+func (s *OutputStream) Stop() (int, int) {
+	close(s.done)
+	<-s.exited
+	return s.wroteOut, s.wroteErr
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStreams struct {
+	mu     sync.Mutex
+	chunks map[string][]ReadStreamReply
+}
+
+func (f *fakeStreams) ReadStream(in *ReadStreamArgs, out *ReadStreamReply) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	chunks := f.chunks[in.Stream]
+	if in.Seq < len(chunks) {
+		*out = chunks[in.Seq]
+	}
+	return nil
+}
+
+func (f *fakeStreams) publish(stream string, stdout string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chunks[stream] = append(f.chunks[stream], ReadStreamReply{Found: true, Stdout: []byte(stdout)})
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestOutputStream(t *testing.T) {
+	fake := &fakeStreams{chunks: make(map[string][]ReadStreamReply)}
+	var srv rpc.Server
+	srv.RegisterName("Daemon", fake)
+	cconn, sconn := net.Pipe()
+	go srv.ServeConn(sconn)
+	client := &Client{conn: rpc.NewClient(cconn)}
+	defer client.Close()
+
+	var stdout, stderr syncBuffer
+	stream := client.StartStream(&stdout, &stderr)
+	fake.publish(stream.ID, "hello ")
+	fake.publish(stream.ID, "world\n")
+	fake.publish("other", "nope\n")
+
+	assert.Eventually(t, func() bool {
+		return stdout.String() == "hello world\n"
+	}, 10*StreamPollInterval, 10*time.Millisecond)
+	wroteOut, wroteErr := stream.Stop()
+	assert.Equal(t, len("hello world\n"), wroteOut)
+	assert.Equal(t, 0, wroteErr)
+	assert.Equal(t, "", stderr.String())
+}
@@ -19,16 +19,45 @@ import (
 
 	"github.com/nelhage/llama/files"
 	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
 	"github.com/nelhage/llama/tracing"
 )
 
-type PingArgs struct{}
+// ResultCachePrefix is the store key prefix under which cached
+// invocation results are stored.
+const ResultCachePrefix = "results"
+
+// WarmPoolAlias is the function alias which carries a function's
+// provisioned concurrency, for functions with a warm pool.
+const WarmPoolAlias = "llama-warm"
+
+type PingArgs struct {
+	// The version of the client, for the daemon's logs
+	ClientVersion string
+}
 type PingReply struct {
 	ServerPid int
+
+	// The daemon's Version() and the protocol versions it
+	// speaks. Daemons that predate the handshake leave these
+	// zero.
+	DaemonVersion      string
+	ProtocolVersion    uint32
+	MinProtocolVersion uint32
 }
 
-type ShutdownArgs struct{}
-type ShutdownReply struct{}
+type ShutdownArgs struct {
+	// Drain stops the daemon accepting new invocations, and waits
+	// up to DrainTimeout (default: the daemon's -drain-timeout)
+	// for the ones in flight before exiting
+	Drain        bool
+	DrainTimeout time.Duration
+}
+type ShutdownReply struct {
+	// With Drain, the number of invocations still running when we
+	// gave up waiting for them
+	Abandoned int
+}
 
 type InvokeWithFilesArgs struct {
 	Trace      *tracing.Propagation
@@ -38,10 +67,80 @@ type InvokeWithFilesArgs struct {
 	Stdin      []byte
 	Files      files.List
 	Outputs    files.List
+	// Local directories to upload as directory trees
+	Trees files.List
 
 	// If true, release the llamacc semaphore to allow other
 	// llamacc processes to use CPU while we talk to AWS
 	DropSemaphore bool
+
+	// If true, consult the result cache before invoking, and
+	// record successful results in it.
+	UseCache bool
+
+	// If true, running the command twice is harmless, as for a
+	// compile. Only then do we retry it in another region after a
+	// failure that leaves unknown whether it ran.
+	Idempotent bool
+
+	// If non-empty, the runtime will publish output under this
+	// stream ID while the command runs; see ReadStream.
+	Stream string
+
+	// If non-zero, run on the smallest variant of the function
+	// with at least this much memory (in MB) and this timeout
+	Memory  int64
+	Timeout time.Duration
+
+	// The scheduling class of the invocation
+	Priority Priority
+
+	// Additional environment variables for the command, as
+	// KEY=VALUE
+	Env []string
+
+	// If non-zero, kill the command if it runs longer than this
+	TimeLimit time.Duration
+
+	// If set, the build session to record this invocation in; see
+	// BeginBuildArgs
+	Build string
+	// If set, CancelInvocation with this ID abandons the
+	// invocation, unless it has begun writing its outputs
+	CancelID string
+
+	// StdinBlob, if set, replaces Stdin with a blob the client has
+	// already stored, using a store from NewDirectStore
+	StdinBlob *protocol.Blob
+	// If set, outputs held in the object store are returned in
+	// DirectOutputs for the client to fetch itself, instead of
+	// being written by the daemon
+	DirectOutputs bool
+	// Local paths of outputs to leave in the object store, writing
+	// reference files (see files.WriteRef) in their place.
+	// Uploading a reference file passes along the object it refers
+	// to, so these can be inputs to later invocations.
+	RefOutputs []string
+
+	// If set, identifies this job across builds, such as by the
+	// object file it produces. The daemon records the job's peak
+	// memory use under this key, and, unless Memory is set, runs
+	// later jobs with the same key on a variant of the function
+	// with enough memory for them.
+	Profile string
+
+	// Which files to leave out of Trees, if not
+	// files.DefaultIgnore
+	Ignore *files.IgnorePolicy
+	// If set, the daemon also fetches every file the command
+	// created or modified, and writes it under this local
+	// directory, at its path relative to the command's working
+	// directory
+	ChangesDir string
+
+	// If set, overrides the daemon's result-cache namespaces for
+	// this invocation. It can't make a read-only cache writable.
+	Cache *CacheConfig
 }
 
 type InvokeWithFilesReply struct {
@@ -50,8 +149,38 @@ type InvokeWithFilesReply struct {
 	Stdout     []byte
 	Stderr     []byte
 	Logs       []byte
+	Cached     bool
 
 	Timing Timing
+
+	// The ID the daemon and runtime log this invocation under
+	InvocationID string
+
+	// With DirectOutputs, the outputs the client must fetch and
+	// write itself, under their local paths
+	DirectOutputs protocol.FileList
+
+	// With ChangesDir, the files the command created or modified,
+	// relative to ChangesDir
+	Changed []string
+}
+
+// InvokeArgs invokes a function on an invocation spec whose files
+// the caller has already uploaded, through the daemon's scheduler.
+type InvokeArgs struct {
+	Trace      *tracing.Propagation
+	Function   string
+	Qualifier  string
+	ReturnLogs bool
+	Spec       protocol.InvocationSpec
+	Priority   Priority
+}
+
+type InvokeReply struct {
+	Response protocol.InvocationResponse
+	Logs     []byte
+	// If the function itself returned an error, its payload
+	FunctionErr []byte
 }
 
 type Timing struct {
@@ -60,6 +189,9 @@ type Timing struct {
 	Fetch  time.Duration
 	Remote protocol.Timing
 	Invoke time.Duration
+	// Time spent waiting for the daemon's scheduler to admit the
+	// invocation, which Invoke does not include
+	Queue time.Duration
 }
 
 type Stats struct {
@@ -71,7 +203,87 @@ type Stats struct {
 	OtherErrors    uint64
 	ExitStatuses   [256]uint64
 
+	// Compilations that llamacc retried locally after a remote
+	// failure
+	LocalFallbacks uint64
+
+	CacheHits   uint64
+	CacheMisses uint64
+
+	// Invocations retried in another region after a region was
+	// throttled or failed
+	RegionFailovers uint64
+
+	// Invocations retried after a transient failure
+	Retries uint64
+
+	// Invocations delayed, and refused, because the session was
+	// over budget
+	BudgetThrottled uint64
+	BudgetRefused   uint64
+
+	// Invocations Lambda throttled, and the resulting ceiling on
+	// concurrent invocations (0 if unlimited)
+	Throttles        uint64
+	ConcurrencyLimit int64
+
 	Usage protocol.UsageMetrics
+
+	// Invocations sent to Lambda packed together with others
+	PackedJobs uint64
+
+	// Outputs whose contents didn't match the checksum the
+	// runtime recorded for them
+	ChecksumMismatches uint64
+
+	// Dependency scans answered from, and missing from, the
+	// dependency cache; see DepsKey
+	DepsCacheHits   uint64
+	DepsCacheMisses uint64
+
+	// Files uploaded ahead of the invocations that need them;
+	// see StageArgs
+	StagedFiles uint64
+}
+
+type StatusArgs struct{}
+
+type InvocationStatus struct {
+	Function string
+	// A local file identifying the invocation, typically its
+	// first output
+	Description string
+	Started     time.Time
+
+	// How to find the invocation's logs: the ID the daemon logs
+	// it under, and the region and Lambda request ID of its last
+	// attempt, once there has been one
+	InvocationID string
+	Region       string
+	RequestID    string
+}
+
+type FailureStatus struct {
+	Time        time.Time
+	Function    string
+	Description string
+	Error       string
+
+	InvocationID string
+	Region       string
+	RequestID    string
+}
+
+type StatusReply struct {
+	Stats Stats
+	Cost  Cost
+	// Number of llamacc requests waiting for a concurrency slot
+	Queued int64
+	// Number of invocations of each priority waiting for the
+	// scheduler
+	Waiting        [NumPriorities]int64
+	InFlight       []InvocationStatus
+	RecentFailures []FailureStatus
 }
 
 type StatsArgs struct {
@@ -79,6 +291,10 @@ type StatsArgs struct {
 }
 type StatsReply struct {
 	Stats Stats
+	// The estimated cost of Stats.Usage, and the budget it counts
+	// against
+	Cost   Cost
+	Budget Budget
 }
 
 type TraceSpansArgs struct {
@@ -87,6 +303,20 @@ type TraceSpansArgs struct {
 
 type TraceSpansReply struct{}
 
+type ReadStreamArgs struct {
+	Stream string
+	Seq    int
+}
+
+type ReadStreamReply struct {
+	Found  bool
+	Stdout []byte
+	Stderr []byte
+}
+
+type RecordFallbackArgs struct{}
+type RecordFallbackReply struct{}
+
 type GetCompilerIncludePathArgs struct {
 	Compiler string
 	Language string
@@ -95,3 +325,193 @@ type GetCompilerIncludePathArgs struct {
 type GetCompilerIncludePathReply struct {
 	Paths []string
 }
+
+// ScanIncludesArgs asks the daemon's include server for the headers a
+// translation unit depends on. All paths must be absolute.
+type ScanIncludesArgs struct {
+	// The input file, and any headers included with `-include`
+	Inputs []string
+	// The directories searched for "quoted" and <bracketed>
+	// includes, in order. Quoted includes search the including
+	// file's directory first.
+	Quote   []string
+	Bracket []string
+}
+
+type ScanIncludesReply struct {
+	// The inputs and every header they may include
+	Deps []string
+}
+
+// A DepsKey describes a run of the preprocessor to list the headers
+// a translation unit depends on, as with `cpp -M`, for the daemon's
+// dependency cache. The daemon remembers the headers each run
+// found, keyed on the compiler, its arguments and the contents of
+// the input, and reports them again until one of the headers, or one
+// of the directories searched for them, changes. Paths must be
+// absolute.
+type DepsKey struct {
+	Compiler string
+	Args     []string
+	Dir      string
+	// Environment variables, such as CPATH, that change where the
+	// preprocessor looks for headers, as KEY=VALUE
+	Env []string
+	// The input file, or "-" and its contents if it is read from
+	// stdin
+	Input string
+	Stdin []byte
+	// The directories searched for headers, beyond those that
+	// hold the headers found
+	Search []string
+}
+
+type LookupDepsArgs struct {
+	Key DepsKey
+
+	// If the cache misses, start uploading the headers the
+	// input depended on the last time it was recorded, while the
+	// caller runs the preprocessor; see Stage
+	Stage bool
+}
+
+type LookupDepsReply struct {
+	// Whether the cache held the dependencies
+	Found bool
+	Deps  []string
+}
+
+// RecordDepsArgs adds the dependencies the preprocessor listed to the
+// dependency cache. Relative paths are relative to Key.Dir.
+type RecordDepsArgs struct {
+	Key  DepsKey
+	Deps []string
+}
+
+type RecordDepsReply struct{}
+
+// CheckToolchainArgs asks the daemon to compare a local compiler
+// with the toolchain recorded on a function.
+type CheckToolchainArgs struct {
+	Function string
+	// The absolute path of the local compiler
+	Compiler string
+	// The target the remote compiler is told to compile for, if
+	// not its default
+	Target string
+}
+
+type CheckToolchainReply struct {
+	// The local and remote toolchains, if the function records
+	// one
+	Local  string
+	Remote string
+	// If not empty, how they differ
+	Mismatch string
+	// Whether this is the first time the daemon has reported
+	// this mismatch, so only one compilation of a build warns
+	// about it
+	First bool
+}
+
+// PrewarmArgs asks the daemon to upload, in the background, the
+// sources and headers of the translation units in a compilation
+// database, so that they are already in the object store when the
+// build gets to them.
+type PrewarmArgs struct {
+	// The absolute path of the compilation database
+	// (compile_commands.json)
+	Database string
+	// The database's translation units, described as for
+	// ScanIncludes. If empty, the daemon only reports whether it
+	// wants them: it prewarms each database once, until it
+	// changes.
+	Units []ScanIncludesArgs
+}
+
+type PrewarmReply struct {
+	// Whether the caller should send the units
+	Wanted bool
+}
+
+// PresignArgs asks for presigned requests to upload the objects Put
+// and download the objects Get, which are object IDs.
+type PresignArgs struct {
+	Put []string
+	Get []string
+}
+type PresignReply struct {
+	// False if the daemon's object store can't presign requests,
+	// in which case clients must transfer data through the daemon
+	Supported bool
+	// A request for each object in PresignArgs. A Put request with
+	// an empty URL means the store already holds that object.
+	Put []store.PresignedRequest
+	Get []store.PresignedRequest
+}
+
+// MaterializeArgs asks the daemon to replace any reference files
+// among Paths with the contents they refer to.
+type MaterializeArgs struct {
+	Paths []string
+}
+type MaterializeReply struct {
+	Materialized int
+}
+
+// CheckUploadsArgs asks which of the local files Paths the object
+// store already holds, without uploading anything.
+type CheckUploadsArgs struct {
+	Paths []string
+}
+type CheckUploadsReply struct {
+	Files []UploadCheck
+}
+
+// An UploadCheck reports what uploading a file would involve
+type UploadCheck struct {
+	Path string
+	Size int64
+	// Set if the file is small enough to be sent inline, rather
+	// than through the object store
+	Inline bool
+	// How many bytes uploading the file would send to the store:
+	// zero if it already holds the file
+	Upload int64
+	Err    string
+}
+
+// StageArgs asks the daemon to start uploading the local files
+// Paths, which must be absolute, in the background, so that an
+// invocation which needs them soon finds them already in the object
+// store, or on their way there. Stage returns at once.
+type StageArgs struct {
+	Paths []string
+}
+type StageReply struct{}
+
+type CancelInvocationArgs struct {
+	CancelID string
+}
+type CancelInvocationReply struct {
+	// False if the invocation had already begun writing its
+	// outputs, and will run to completion
+	Cancelled bool
+}
+
+// BeginBuildArgs begins a build session, which aggregates statistics
+// about a build's invocations and reports on them when it ends, after
+// the daemon's build idle timeout or an explicit EndBuild. Invoking
+// with a Build that isn't running also begins it.
+type BeginBuildArgs struct {
+	Build string
+}
+type BeginBuildReply struct{}
+
+type EndBuildArgs struct {
+	Build string
+}
+type EndBuildReply struct {
+	Found  bool
+	Report BuildReport
+}
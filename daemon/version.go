@@ -0,0 +1,41 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import "runtime/debug"
+
+// The version of the daemon's APIs: both its gRPC API (see package
+// daemonpb) and the net/rpc API llamacc and friends use. We increment
+// ProtocolVersion whenever we add to either, and MinProtocolVersion
+// when we stop supporting older clients.
+const (
+	ProtocolVersion    = 4
+	MinProtocolVersion = 1
+)
+
+// Version returns the version of Llama this binary was built from,
+// or "(devel)" for a build from a source checkout.
+func Version() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return "(devel)"
+}
+
+// GRPCSocketPath returns the path of the socket the daemon serves its
+// gRPC API on, given the path of its main socket.
+func GRPCSocketPath(sockPath string) string {
+	return sockPath + ".grpc"
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -27,10 +28,10 @@ type IOContext struct {
 }
 
 func (io *IOContext) cleanPath(file string) (Mapped, error) {
-	if path.IsAbs(file) {
+	if path.IsAbs(file) || filepath.IsAbs(file) || HasDrive(file) {
 		return Mapped{}, fmt.Errorf("Cannot pass absolute path: %q", file)
 	}
-	file = path.Clean(file)
+	file = path.Clean(filepath.ToSlash(file))
 	if strings.HasPrefix(file, "../") {
 		return Mapped{}, fmt.Errorf("Cannot pass path outside working directory: %q", file)
 	}
@@ -0,0 +1,58 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// HasDrive returns true if `p` begins with a Windows drive letter,
+// like `C:\src` or `C:/src`.
+func HasDrive(p string) bool {
+	if len(p) < 2 || p[1] != ':' {
+		return false
+	}
+	c := p[0]
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// RemotePath returns the slash-separated path, rooted at `/`, that
+// stands in for the absolute local path `local` on the remote side.
+// On Unix hosts that's the path itself; a Windows path like
+// `C:\src\foo.c` becomes `/c/src/foo.c`, as under MSYS2.
+func RemotePath(local string) string {
+	p := filepath.ToSlash(local)
+	if HasDrive(p) {
+		p = "/" + strings.ToLower(p[:1]) + path.Clean("/"+p[2:])
+	}
+	return p
+}
+
+// FromMSYS converts an MSYS2 or Cygwin path naming a Windows drive,
+// like `/c/src/foo.h` or `/cygdrive/c/src/foo.h`, to the native
+// `C:/src/foo.h`. Other paths are returned unchanged. Only call it on
+// Windows hosts: on Unix, `/c/src` is an ordinary path.
+func FromMSYS(p string) string {
+	rest := strings.TrimPrefix(p, "/cygdrive")
+	if len(rest) < 2 || rest[0] != '/' || !HasDrive(rest[1:2]+":") {
+		return p
+	}
+	if len(rest) > 2 && rest[2] != '/' {
+		return p
+	}
+	return strings.ToUpper(rest[1:2]) + ":/" + strings.TrimPrefix(rest[2:], "/")
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemotePath(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{"/home/me/src/foo.c", "/home/me/src/foo.c"},
+		{"C:/src/foo.c", "/c/src/foo.c"},
+		{"d:/src/../inc/foo.h", "/d/inc/foo.h"},
+		{"C:/", "/c/"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.out, RemotePath(tc.in), tc.in)
+	}
+}
+
+func TestFromMSYS(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{"/c/src/foo.h", "C:/src/foo.h"},
+		{"/cygdrive/d/src/foo.h", "D:/src/foo.h"},
+		{"/c", "C:/"},
+		{"/usr/include/stdio.h", "/usr/include/stdio.h"},
+		{"/cc/foo.h", "/cc/foo.h"},
+		{"C:/src/foo.h", "C:/src/foo.h"},
+		{"foo.h", "foo.h"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.out, FromMSYS(tc.in), tc.in)
+	}
+}
+
+func TestListSetDrive(t *testing.T) {
+	var l List
+	assert.NoError(t, l.Set(`C:\src\foo.c:foo.c`))
+	assert.Error(t, l.Set(`C:\src\bar.c`))
+	assert.Equal(t, List{{Local: LocalFile{Path: `C:\src\foo.c`}, Remote: "foo.c"}}, l)
+}
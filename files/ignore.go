@@ -0,0 +1,200 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// An IgnorePolicy leaves files out of the directory trees we upload,
+// using the pattern syntax of gitignore(5).
+type IgnorePolicy struct {
+	// Patterns that apply to the whole tree, as if read from an
+	// ignore file at its root
+	Patterns []string
+	// The names of ignore files, such as ".gitignore", whose
+	// patterns apply to the directory that holds them and
+	// everything below it
+	Files []string
+}
+
+// IgnoreFileName names the files that list, in gitignore(5) syntax,
+// the files in their directory that Llama should never upload: build
+// artifacts, dependencies fetched by a package manager, and the like.
+const IgnoreFileName = ".llamaignore"
+
+// DefaultIgnore is the policy for directory trees, unless the caller
+// chooses another.
+var DefaultIgnore = IgnorePolicy{Files: []string{IgnoreFileName}}
+
+// An ignoreRule is one pattern from an ignore file
+type ignoreRule struct {
+	// The directory, relative to the root of the tree, whose
+	// ignore file the pattern came from
+	base string
+	// The pattern, split at slashes
+	segs []string
+	// Whether the pattern only matches relative to `base`, as
+	// opposed to the name of a file at any depth below it
+	anchored bool
+	dirOnly  bool
+	negate   bool
+}
+
+// parseIgnoreRule parses a line of an ignore file in directory
+// `base`. It returns false for blank lines and comments.
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.segs = strings.Split(line, "/")
+	return rule, true
+}
+
+// matchSegments matches a pattern against a path, both split at
+// slashes. "**" matches any number of directories.
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			if len(rest) == 0 {
+				// "x/**" matches everything inside x, but
+				// not x itself
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func (r *ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if r.anchored {
+		return matchSegments(r.segs, strings.Split(rel, "/"))
+	}
+	return matchSegments(r.segs, []string{path.Base(rel)})
+}
+
+// An ignoreMatcher holds the rules that apply within one directory of
+// a tree: those from the policy and the ignore files in it and its
+// parents, in increasing order of precedence.
+type ignoreMatcher struct {
+	policy *IgnorePolicy
+	rules  []ignoreRule
+}
+
+func newIgnoreMatcher(policy *IgnorePolicy) *ignoreMatcher {
+	if policy == nil {
+		return nil
+	}
+	m := &ignoreMatcher{policy: policy}
+	for _, line := range policy.Patterns {
+		if rule, ok := parseIgnoreRule("", line); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return m
+}
+
+// enter returns the matcher for the directory `dir`, at `rel`
+// relative to the root of the tree, adding the rules from its ignore
+// files.
+func (m *ignoreMatcher) enter(dir, rel string) (*ignoreMatcher, error) {
+	if m == nil {
+		return nil, nil
+	}
+	// Don't share the backing array with sibling directories
+	out := &ignoreMatcher{policy: m.policy, rules: m.rules[:len(m.rules):len(m.rules)]}
+	for _, name := range m.policy.Files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scan := bufio.NewScanner(bytes.NewReader(data))
+		for scan.Scan() {
+			if rule, ok := parseIgnoreRule(rel, scan.Text()); ok {
+				out.rules = append(out.rules, rule)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ignored returns whether the file or directory at `rel`, relative
+// to the root of the tree, is left out. The last rule to match
+// decides.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		if m.rules[i].match(rel, isDir) {
+			return !m.rules[i].negate
+		}
+	}
+	return false
+}
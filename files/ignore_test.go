@@ -0,0 +1,149 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	cases := []struct {
+		base, pattern, path string
+		isDir, want         bool
+	}{
+		{"", "*.o", "main.o", false, true},
+		{"", "*.o", "src/main.o", false, true},
+		{"", "*.o", "main.c", false, false},
+		{"", "build/", "build", true, true},
+		{"", "build/", "build", false, false},
+		{"", "build/", "src/build", true, true},
+		{"", "/build", "build", true, true},
+		{"", "/build", "src/build", true, false},
+		{"", "doc/*.html", "doc/index.html", false, true},
+		{"", "doc/*.html", "src/doc/index.html", false, false},
+		{"", "**/logs", "a/b/logs", true, true},
+		{"", "**/logs", "logs", true, true},
+		{"", "a/**/z", "a/z", false, true},
+		{"", "a/**/z", "a/b/c/z", false, true},
+		{"", "a/**", "a/b", false, true},
+		{"", "a/**", "a", true, false},
+		{"sub", "*.tmp", "sub/x.tmp", false, true},
+		{"sub", "*.tmp", "x.tmp", false, false},
+		{"sub", "/gen", "sub/gen", true, true},
+		{"sub", "/gen", "sub/deeper/gen", true, false},
+		{"", `\#notes`, "#notes", false, true},
+	}
+	for _, tc := range cases {
+		rule, ok := parseIgnoreRule(tc.base, tc.pattern)
+		require.True(t, ok, tc.pattern)
+		assert.Equal(t, tc.want, rule.match(tc.path, tc.isDir), "%q in %q vs %q", tc.pattern, tc.base, tc.path)
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		_, ok := parseIgnoreRule("", line)
+		assert.False(t, ok, "%q", line)
+	}
+}
+
+func TestUploadIgnoring(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		".gitignore":         "*.o\nbuild/\n!keep.o\n",
+		".git/HEAD":          "ref: refs/heads/main\n",
+		"main.c":             "",
+		"main.o":             "",
+		"keep.o":             "",
+		"build/out":          "",
+		"sub/.gitignore":     "*.c\n!main.o\n",
+		"sub/x.c":            "",
+		"sub/main.o":         "",
+		"sub/y.h":            "",
+		"node_modules/x.js":  "",
+		"sub/.llamaignore":   "y.h\n",
+		"other/.llamaignore": "*\n",
+		"other/z":            "",
+	})
+
+	root, err := NewTreeCache().Upload(ctx, st, src, &IgnorePolicy{
+		Patterns: []string{".git/", "node_modules/"},
+		Files:    []string{".gitignore", ".llamaignore"},
+	})
+	require.NoError(t, err)
+
+	dst := t.TempDir()
+	require.NoError(t, files.FetchTrees(ctx, st, dst, []protocol.Tree{{Path: ".", Root: root}}))
+	var got []string
+	for name := range readTree(t, dst) {
+		got = append(got, name)
+	}
+	assert.ElementsMatch(t, []string{
+		".gitignore",
+		"main.c",
+		"keep.o",
+		"sub/.gitignore",
+		"sub/.llamaignore",
+		"sub/main.o",
+	}, got)
+
+	// Without a policy, everything is uploaded
+	root, err = NewTreeCache().Upload(ctx, st, src, nil)
+	require.NoError(t, err)
+	dst = t.TempDir()
+	require.NoError(t, files.FetchTrees(ctx, st, dst, []protocol.Tree{{Path: ".", Root: root}}))
+	assert.Contains(t, readTree(t, dst), path.Join("node_modules", "x.js"))
+}
+
+func TestUploadTreesDefaultIgnore(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		".llamaignore":          "node_modules/\n*.log\n",
+		".gitignore":            "src/\n",
+		"src/index.js":          "",
+		"debug.log":             "",
+		"node_modules/x/x.js":   "",
+		"vendor/.llamaignore":   "!keep.log\n",
+		"vendor/keep.log":       "",
+		"vendor/other/drop.log": "",
+	})
+
+	trees, err := List{{Local: LocalFile{Path: src}, Remote: "app"}}.UploadTrees(ctx, st, NewTreeCache(), nil)
+	require.NoError(t, err)
+	dst := t.TempDir()
+	require.NoError(t, files.FetchTrees(ctx, st, dst, trees))
+	var got []string
+	for name := range readTree(t, path.Join(dst, "app")) {
+		got = append(got, name)
+	}
+	// .gitignore only applies where we ask for it
+	assert.ElementsMatch(t, []string{
+		".llamaignore",
+		".gitignore",
+		"src/index.js",
+		"vendor/.llamaignore",
+		"vendor/keep.log",
+	}, got)
+}
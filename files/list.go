@@ -20,9 +20,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 
+	"github.com/nelhage/llama/logging"
 	"github.com/nelhage/llama/protocol"
 	"github.com/nelhage/llama/protocol/files"
 	"github.com/nelhage/llama/store"
@@ -55,6 +57,12 @@ func (f List) Get() interface{} {
 
 func (f *List) Set(v string) error {
 	idx := strings.IndexRune(v, ':')
+	if HasDrive(v) {
+		// C:\src\foo.c:foo.c
+		if idx = strings.IndexRune(v[2:], ':'); idx >= 0 {
+			idx += 2
+		}
+	}
 	var source, dest string
 	if idx > 0 {
 		source = v[:idx]
@@ -63,7 +71,7 @@ func (f *List) Set(v string) error {
 		source = v
 		dest = v
 	}
-	if path.IsAbs(dest) {
+	if path.IsAbs(dest) || HasDrive(dest) {
 		return fmt.Errorf("-file: cannot expose file at absolute path: %q", dest)
 	}
 	*f = f.Append(Mapped{Local: LocalFile{Path: source}, Remote: dest})
@@ -74,69 +82,131 @@ func (f List) Append(mapped ...Mapped) List {
 	return append(f, mapped...)
 }
 
-func uploadWorker(ctx context.Context, store store.Store, jobs <-chan Mapped, out chan<- *protocol.FileAndPath) {
-	for file := range jobs {
-		data, mode, err := func() ([]byte, os.FileMode, error) {
-			if file.Local.Bytes != nil {
-				if file.Local.Path != "" {
-					panic("MappedFile: got both Path and Bytes")
-				}
-				return file.Local.Bytes, file.Local.Mode, nil
-			} else {
-				data, err := ioutil.ReadFile(file.Local.Path)
-				if err != nil {
-					return nil, 0, fmt.Errorf("reading file %q: %w", file.Local.Path, err)
-				}
-				st, err := os.Stat(file.Local.Path)
-				if err != nil {
-					return nil, 0, fmt.Errorf("stat %q: %w", file.Local.Path, err)
-				}
-				return data, st.Mode(), nil
-			}
-		}()
-		var blob *protocol.Blob
-		if err == nil {
-			blob, err = files.NewBlob(ctx, store, data)
-		}
-		if err != nil {
-			blob = &protocol.Blob{Err: err.Error()}
-		}
-		out <- &protocol.FileAndPath{
-			File: protocol.File{Blob: *blob, Mode: mode},
-			Path: file.Remote,
+// localContents holds a file read for upload
+type localContents struct {
+	data []byte
+	mode os.FileMode
+	// Set if the file is a ref to an object already in the store
+	ref *protocol.File
+	err error
+}
+
+func readLocal(file LocalFile) localContents {
+	if file.Bytes != nil {
+		if file.Path != "" {
+			panic("MappedFile: got both Path and Bytes")
 		}
+		return localContents{data: file.Bytes, mode: file.Mode}
+	}
+	data, err := ioutil.ReadFile(file.Path)
+	if err != nil {
+		return localContents{err: fmt.Errorf("reading file %q: %w", file.Path, err)}
+	}
+	st, err := os.Stat(file.Path)
+	if err != nil {
+		return localContents{err: fmt.Errorf("stat %q: %w", file.Path, err)}
+	}
+	if ref, ok := files.ParseRef(data); ok {
+		return localContents{ref: ref}
+	}
+	return localContents{data: data, mode: st.Mode()}
+}
+
+func uploadContents(ctx context.Context, store store.Store, c *localContents, inline int) protocol.File {
+	if c.ref != nil {
+		// The contents are already in the store
+		return protocol.File{Blob: c.ref.Blob, Mode: c.ref.Mode}
 	}
+	err := c.err
+	var blob *protocol.Blob
+	if err == nil {
+		blob, err = files.NewBlobInline(ctx, store, c.data, inline)
+	}
+	if err != nil {
+		blob = &protocol.Blob{Err: err.Error()}
+	}
+	return protocol.File{Blob: *blob, Mode: c.mode}
 }
 
 const uploadConcurrency = 32
 
-func (f List) Upload(ctx context.Context, store store.Store, files protocol.FileList) (protocol.FileList, error) {
+// forEach calls fn(i) for each i in [0, n), uploadConcurrency at a
+// time.
+func forEach(n int, fn func(i int)) {
 	var wg sync.WaitGroup
-	jobs := make(chan Mapped)
-	out := make(chan *protocol.FileAndPath)
+	sem := make(chan struct{}, uploadConcurrency)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Upload stores the files in `f`, and appends them to `files` under
+// their remote paths. It reads every file first, so that a store
+// which can check for many objects at once can find out which it
+// already holds in one go, rather than as it stores each one.
+func (f List) Upload(ctx context.Context, store store.Store, list protocol.FileList) (protocol.FileList, error) {
+	return f.UploadInline(ctx, store, list, Inline{})
+}
+
+// Inline bounds the files an upload carries in the invocation
+// itself, rather than storing them: those of up to Limit bytes, until
+// they come to Total bytes in all. Files too small to be worth
+// storing are always inline.
+type Inline struct {
+	Limit int
+	Total int
+}
 
-	go func() {
-		defer close(jobs)
-		for _, file := range f {
-			jobs <- file
+// DefaultInline inlines the many small headers of a typical C
+// compilation, while keeping requests well within Lambda's limit;
+// see protocol.MaxRequestBytes.
+var DefaultInline = Inline{Limit: 4 << 10, Total: 1 << 20}
+
+// UploadInline is like Upload, but carries small files inline, as
+// `inline` allows.
+func (f List) UploadInline(ctx context.Context, store store.Store, list protocol.FileList, inline Inline) (protocol.FileList, error) {
+	contents := make([]localContents, len(f))
+	forEach(len(f), func(i int) {
+		contents[i] = readLocal(f[i].Local)
+	})
+
+	limits := make([]int, len(f))
+	budget := inline.Total
+	var toStore [][]byte
+	for i := range contents {
+		if contents[i].err != nil || contents[i].ref != nil {
+			continue
 		}
-	}()
-	for i := 0; i < uploadConcurrency; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			uploadWorker(ctx, store, jobs, out)
-		}()
+		if n := len(contents[i].data); n <= inline.Limit && n <= budget {
+			limits[i] = inline.Limit
+			budget -= n
+			continue
+		}
+		toStore = append(toStore, contents[i].data)
 	}
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	for file := range out {
-		files = append(files, *file)
+	if err := files.PrimeExistence(ctx, store, toStore); err != nil {
+		// Storing the files will check for them one at a time
+		// instead
+		logging.Printf(ctx, "checking for existing objects: %s", err.Error())
 	}
 
-	return files, nil
+	uploaded := make(protocol.FileList, len(f))
+	forEach(len(f), func(i int) {
+		uploaded[i] = protocol.FileAndPath{
+			File: uploadContents(ctx, store, &contents[i], limits[i]),
+			Path: f[i].Remote,
+		}
+	})
+	return append(list, uploaded...), nil
 }
 
 func (f List) TransformToLocal(ctx context.Context, files protocol.FileList) (ok protocol.FileList, bad protocol.FileList) {
@@ -158,8 +228,8 @@ func (f List) TransformToLocal(ctx context.Context, files protocol.FileList) (ok
 func (f List) MakeAbsolute(base string) List {
 	out := make(List, 0, len(f))
 	for _, e := range f {
-		if e.Local.Path != "" && !path.IsAbs(e.Local.Path) {
-			e.Local.Path = path.Join(base, e.Local.Path)
+		if e.Local.Path != "" && !filepath.IsAbs(e.Local.Path) {
+			e.Local.Path = filepath.Join(base, e.Local.Path)
 		}
 		out = append(out, e)
 	}
@@ -0,0 +1,130 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadRef(t *testing.T) {
+	ctx := context.Background()
+	st := &countingStore{inner: store.InMemory()}
+	dir, err := ioutil.TempDir("", "llama-upload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := bytes.Repeat([]byte("\x7fELF\x00"), protocol.MaxInlineBlob)
+	blob, err := files.NewBlob(ctx, st, data)
+	require.NoError(t, err)
+	st.reset()
+
+	obj := path.Join(dir, "hello.o")
+	require.NoError(t, files.WriteRef(obj, &protocol.File{Blob: *blob, Mode: 0644}))
+	up, err := List{{Local: LocalFile{Path: obj}, Remote: "hello.o"}}.Upload(ctx, st, nil)
+	require.NoError(t, err)
+	require.Len(t, up, 1)
+	assert.Equal(t, 0, st.reset(), "a reference file should not be uploaded")
+	assert.Equal(t, "hello.o", up[0].Path)
+	assert.Equal(t, blob.Refs(), up[0].Refs())
+	assert.Equal(t, os.FileMode(0644), up[0].Mode)
+}
+
+// checkingStore records its batched existence checks
+type checkingStore struct {
+	countingStore
+	checks [][]string
+}
+
+func (c *checkingStore) ObjectID(obj []byte) (string, error) {
+	return c.inner.(store.Inspectable).ObjectID(obj)
+}
+
+func (c *checkingStore) StatObject(ctx context.Context, id string) (store.ObjectInfo, error) {
+	return c.inner.(store.Inspectable).StatObject(ctx, id)
+}
+
+func (c *checkingStore) HasObjects(ctx context.Context, ids []string) ([]bool, error) {
+	c.checks = append(c.checks, ids)
+	return c.inner.(store.ExistenceChecker).HasObjects(ctx, ids)
+}
+
+func TestUploadChecksExistenceOnce(t *testing.T) {
+	ctx := context.Background()
+	st := &checkingStore{countingStore: countingStore{inner: store.InMemory()}}
+	dir := t.TempDir()
+
+	var list List
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("h%d.h", i)
+		// Every other header has the same contents
+		data := bytes.Repeat([]byte(fmt.Sprintf("#define H%d\n", i%2*i)), 20)
+		require.NoError(t, ioutil.WriteFile(path.Join(dir, name), data, 0644))
+		list = append(list, Mapped{Local: LocalFile{Path: path.Join(dir, name)}, Remote: name})
+	}
+	list = append(list, Mapped{Local: LocalFile{Bytes: []byte("tiny")}, Remote: "tiny.h"})
+
+	up, err := list.Upload(ctx, st, nil)
+	require.NoError(t, err)
+	require.Len(t, up, len(list))
+	for i, f := range up {
+		assert.Equal(t, list[i].Remote, f.Path)
+	}
+	// One check, for the 26 distinct objects that aren't inlined
+	require.Len(t, st.checks, 1)
+	assert.Len(t, st.checks[0], 26)
+}
+
+func TestUploadInline(t *testing.T) {
+	ctx := context.Background()
+	st := &checkingStore{countingStore: countingStore{inner: store.InMemory()}}
+
+	header := bytes.Repeat([]byte("#define X 1\n"), 100)
+	object := bytes.Repeat([]byte("\x7fELF\x00\xff"), 100)
+	big := bytes.Repeat([]byte("int x;\n"), 1000)
+	list := List{
+		{Local: LocalFile{Bytes: header}, Remote: "a.h"},
+		{Local: LocalFile{Bytes: object}, Remote: "a.o"},
+		{Local: LocalFile{Bytes: big}, Remote: "big.c"},
+		{Local: LocalFile{Bytes: append(header, '\n')}, Remote: "b.h"},
+	}
+	up, err := list.UploadInline(ctx, st, nil, Inline{Limit: 2000, Total: 2000})
+	require.NoError(t, err)
+	require.Len(t, up, len(list))
+
+	assert.Equal(t, string(header), up[0].String)
+	assert.Equal(t, object, up[1].Bytes)
+	assert.NotEmpty(t, up[2].Ref, "too large to inline")
+	assert.NotEmpty(t, up[3].Ref, "over the total")
+	require.Len(t, st.checks, 1)
+	assert.Len(t, st.checks[0], 2)
+
+	for i, f := range up {
+		data, err := files.Read(ctx, st, &f.Blob)
+		require.NoError(t, err)
+		assert.Equal(t, list[i].Local.Bytes, data)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"golang.org/x/sync/errgroup"
+)
+
+// A TreeCache remembers the directory trees it has uploaded, so that
+// unchanged subtrees are never uploaded again. Directories are
+// identified by the names, sizes, modes, and modification times of
+// their contents, so checking a tree only requires a stat() of each
+// file. A nil *TreeCache caches nothing.
+type TreeCache struct {
+	mu   sync.Mutex
+	dirs map[[sha256.Size]byte]string
+}
+
+func NewTreeCache() *TreeCache {
+	return &TreeCache{dirs: make(map[[sha256.Size]byte]string)}
+}
+
+func (c *TreeCache) lookup(digest [sha256.Size]byte) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.dirs[digest]
+	return id, ok
+}
+
+func (c *TreeCache) remember(digest [sha256.Size]byte, id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs[digest] = id
+}
+
+type treeFile struct {
+	name string
+	path string
+	mode os.FileMode
+}
+
+type treeDir struct {
+	name string
+	node *treeNode
+}
+
+type treeNode struct {
+	digest [sha256.Size]byte
+	files  []treeFile
+	dirs   []treeDir
+}
+
+func writeEntry(h hash.Hash, kind byte, name string, data ...uint64) {
+	h.Write([]byte{kind})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	var buf [8]byte
+	for _, v := range data {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+}
+
+// scanTree walks the local directory `dir`, at `rel` relative to the
+// root of the tree, computing the digest of each directory from the
+// metadata of its contents. Symlinks to files are followed. Files
+// `ign` ignores are left out.
+func scanTree(dir, rel string, ign *ignoreMatcher) (*treeNode, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if ign, err = ign.enter(dir, rel); err != nil {
+		return nil, err
+	}
+	var node treeNode
+	h := sha256.New()
+	for _, fi := range entries {
+		full := filepath.Join(dir, fi.Name())
+		symlink := fi.Mode()&os.ModeSymlink != 0
+		if symlink {
+			if fi, err = os.Stat(full); err != nil {
+				return nil, err
+			}
+		}
+		childRel := path.Join(rel, fi.Name())
+		if ign.ignored(childRel, fi.IsDir()) {
+			continue
+		}
+		if symlink && fi.IsDir() {
+			return nil, fmt.Errorf("%s: symlinks to directories are not supported", full)
+		}
+		switch {
+		case fi.IsDir():
+			child, err := scanTree(full, childRel, ign)
+			if err != nil {
+				return nil, err
+			}
+			node.dirs = append(node.dirs, treeDir{name: fi.Name(), node: child})
+			h.Write([]byte{'d'})
+			h.Write([]byte(fi.Name()))
+			h.Write([]byte{0})
+			h.Write(child.digest[:])
+		case fi.Mode().IsRegular():
+			node.files = append(node.files, treeFile{name: fi.Name(), path: full, mode: fi.Mode()})
+			writeEntry(h, 'f', fi.Name(),
+				uint64(fi.Size()), uint64(fi.Mode().Perm()), uint64(fi.ModTime().UnixNano()))
+		}
+	}
+	h.Sum(node.digest[:0])
+	return &node, nil
+}
+
+func (c *TreeCache) uploadNode(ctx context.Context, st store.Store, sem chan struct{}, node *treeNode) (string, error) {
+	if id, ok := c.lookup(node.digest); ok {
+		return id, nil
+	}
+	dir := protocol.Directory{
+		Files: make(protocol.FileList, len(node.files)),
+		Dirs:  make([]protocol.DirNode, len(node.dirs)),
+	}
+	grp, ctx := errgroup.WithContext(ctx)
+	for i, f := range node.files {
+		i, f := i, f
+		grp.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			data, err := ioutil.ReadFile(f.path)
+			if err != nil {
+				return err
+			}
+			blob, err := files.NewBlob(ctx, st, data)
+			if err != nil {
+				return fmt.Errorf("uploading %s: %w", f.path, err)
+			}
+			dir.Files[i] = protocol.FileAndPath{
+				File: protocol.File{Blob: *blob, Mode: f.mode.Perm()},
+				Path: f.name,
+			}
+			return nil
+		})
+	}
+	for i, d := range node.dirs {
+		i, d := i, d
+		grp.Go(func() error {
+			id, err := c.uploadNode(ctx, st, sem, d.node)
+			dir.Dirs[i] = protocol.DirNode{Name: d.name, Ref: id}
+			return err
+		})
+	}
+	if err := grp.Wait(); err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(&dir)
+	if err != nil {
+		return "", err
+	}
+	// Store the Directory only once its contents are stored, so
+	// that any Directory in the store is complete.
+	id, err := st.Store(ctx, encoded)
+	if err != nil {
+		return "", err
+	}
+	c.remember(node.digest, id)
+	return id, nil
+}
+
+// Upload uploads the directory tree rooted at the local directory
+// `dir`, leaving out any files `ignore` (which may be nil) excludes,
+// and returns the object ID of its root protocol.Directory.
+func (c *TreeCache) Upload(ctx context.Context, st store.Store, dir string, ignore *IgnorePolicy) (string, error) {
+	node, err := scanTree(dir, "", newIgnoreMatcher(ignore))
+	if err != nil {
+		return "", err
+	}
+	return c.uploadNode(ctx, st, make(chan struct{}, uploadConcurrency), node)
+}
+
+// UploadTrees uploads each entry in `f`, which must name local
+// directories, as a directory tree. If `ignore` is nil, it leaves out
+// the files DefaultIgnore does.
+func (f List) UploadTrees(ctx context.Context, st store.Store, cache *TreeCache, ignore *IgnorePolicy) ([]protocol.Tree, error) {
+	if ignore == nil {
+		ignore = &DefaultIgnore
+	}
+	var trees []protocol.Tree
+	for _, m := range f {
+		if m.Local.Path == "" {
+			return nil, fmt.Errorf("tree %q: must have a local path", m.Remote)
+		}
+		root, err := cache.Upload(ctx, st, m.Local.Path, ignore)
+		if err != nil {
+			return nil, fmt.Errorf("tree %q: %w", m.Local.Path, err)
+		}
+		trees = append(trees, protocol.Tree{Path: m.Remote, Root: root})
+	}
+	return trees, nil
+}
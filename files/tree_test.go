@@ -0,0 +1,149 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore records the objects stored through it
+type countingStore struct {
+	inner  store.Store
+	mu     sync.Mutex
+	stored int
+}
+
+func (c *countingStore) Store(ctx context.Context, obj []byte) (string, error) {
+	c.mu.Lock()
+	c.stored++
+	c.mu.Unlock()
+	return c.inner.Store(ctx, obj)
+}
+
+func (c *countingStore) GetObjects(ctx context.Context, gets []store.GetRequest) {
+	c.inner.GetObjects(ctx, gets)
+}
+
+func (c *countingStore) FetchAWSUsage(u *protocol.UsageMetrics) {}
+
+func (c *countingStore) reset() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.stored
+	c.stored = 0
+	return n
+}
+
+func writeTree(t *testing.T, root string, tree map[string]string) {
+	for name, contents := range tree {
+		p := path.Join(root, name)
+		require.NoError(t, os.MkdirAll(path.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte(contents), 0644))
+	}
+}
+
+func readTree(t *testing.T, root string) map[string]string {
+	out := make(map[string]string)
+	var walk func(dir string)
+	walk = func(dir string) {
+		entries, err := ioutil.ReadDir(path.Join(root, dir))
+		require.NoError(t, err)
+		for _, fi := range entries {
+			name := path.Join(dir, fi.Name())
+			if fi.IsDir() {
+				walk(name)
+				continue
+			}
+			data, err := ioutil.ReadFile(path.Join(root, name))
+			require.NoError(t, err)
+			out[name] = string(data)
+		}
+	}
+	walk("")
+	return out
+}
+
+func TestTreeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	st := &countingStore{inner: store.InMemory()}
+	src := t.TempDir()
+	big := string(make([]byte, 1000))
+	tree := map[string]string{
+		"README":           "hello\n",
+		"src/main.c":       "int main() { return 0; }\n",
+		"src/big.bin":      big,
+		"a/include/x.h":    "#define X 1\n",
+		"b/include/x.h":    "#define X 1\n",
+		"a/include/y.h":    "#define Y 1\n",
+		"b/include/y.h":    "#define Y 1\n",
+		"deep/1/2/3/4.txt": "deep\n",
+	}
+	writeTree(t, src, tree)
+	require.NoError(t, os.Chmod(path.Join(src, "src/main.c"), 0755))
+
+	cache := NewTreeCache()
+	root, err := cache.Upload(ctx, st, src, nil)
+	require.NoError(t, err)
+	assert.True(t, st.reset() > 0)
+
+	dst := t.TempDir()
+	require.NoError(t, files.FetchTrees(ctx, st, dst, []protocol.Tree{{Path: "tree", Root: root}}))
+	assert.Equal(t, tree, readTree(t, path.Join(dst, "tree")))
+	fi, err := os.Stat(path.Join(dst, "tree/src/main.c"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+
+	// Nothing has changed, so nothing is uploaded
+	again, err := cache.Upload(ctx, st, src, nil)
+	require.NoError(t, err)
+	assert.Equal(t, root, again)
+	assert.Equal(t, 0, st.reset())
+
+	// Changing one file re-uploads only that file and the
+	// directories above it
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, ioutil.WriteFile(path.Join(src, "deep/1/2/3/4.txt"), []byte("changed\n"), 0644))
+	require.NoError(t, os.Chtimes(path.Join(src, "deep/1/2/3/4.txt"), later, later))
+	changed, err := cache.Upload(ctx, st, src, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, root, changed)
+	assert.Equal(t, 5, st.reset(), "deep/1/2/3, deep/1/2, deep/1, deep, and the root")
+
+	dst = t.TempDir()
+	require.NoError(t, files.FetchTrees(ctx, st, dst, []protocol.Tree{{Path: ".", Root: changed}}))
+	tree["deep/1/2/3/4.txt"] = "changed\n"
+	assert.Equal(t, tree, readTree(t, dst))
+}
+
+func TestFetchTreesBadName(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	id, err := st.Store(ctx, []byte(`{"f":[{"p":"../escape","s":"oops"}]}`))
+	require.NoError(t, err)
+	err = files.FetchTrees(ctx, st, t.TempDir(), []protocol.Tree{{Path: "x", Root: id}})
+	assert.Error(t, err)
+}
@@ -4,9 +4,10 @@ go 1.14
 
 require (
 	github.com/aws/aws-lambda-go v1.20.0
-	github.com/aws/aws-sdk-go v1.38.13
+	github.com/aws/aws-sdk-go v1.42.0
 	github.com/fraugster/parquet-go v0.3.0
 	github.com/gofrs/flock v0.8.0
+	github.com/golang/protobuf v1.4.2
 	github.com/golang/snappy v0.0.2
 	github.com/google/subcommands v1.2.0
 	github.com/jaegertracing/jaeger v1.21.0
@@ -14,8 +15,13 @@ require (
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/stretchr/testify v1.6.1
 	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
-	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
-	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f
+	golang.org/x/oauth2 v0.0.0-20210413134643-5e61552d6c78
+	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
+	golang.org/x/sys v0.0.0-20210423082822-04245dca01da
+	google.golang.org/genproto v0.0.0-20200825200019-8632dd797987
+	google.golang.org/grpc v1.32.0
+	google.golang.org/protobuf v1.25.0
+	lukechampine.com/blake3 v1.1.7
 )
 
 replace github.com/fraugster/parquet-go v0.3.0 => github.com/nelhage/parquet-go v0.3.1-0.20210416231405-1e924319d941
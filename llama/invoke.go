@@ -18,39 +18,79 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/golang/snappy"
 	"github.com/nelhage/llama/protocol"
 	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/runner"
 	"github.com/nelhage/llama/store"
 	"github.com/nelhage/llama/tracing"
 )
 
 type InvokeArgs struct {
-	Function   string
+	Function string
+	// If non-empty, the version or alias of the function to
+	// invoke
+	Qualifier  string
 	ReturnLogs bool
+	// If true, the invocation may safely run more than once
+	Idempotent bool
 	Spec       protocol.InvocationSpec
 }
 
 type InvokeResult struct {
 	Logs     []byte
 	Response protocol.InvocationResponse
+	// The Lambda request ID, which identifies the invocation in
+	// CloudWatch Logs
+	RequestID string
 }
 
 type ErrorReturn struct {
-	Payload []byte
-	Logs    []byte
+	Payload   []byte
+	Logs      []byte
+	RequestID string
 }
 
 func (e *ErrorReturn) Error() string {
 	return fmt.Sprintf("Function returned error: %q", e.Payload)
 }
 
-func Invoke(ctx context.Context, svc *lambda.Lambda,
+// Type returns the type the function reported its error as. The
+// runtime reports errors from AWS, such as S3 throttling it, with
+// their error code as the type.
+func (e *ErrorReturn) Type() string {
+	var payload struct {
+		ErrorType string `json:"errorType"`
+	}
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return ""
+	}
+	return payload.ErrorType
+}
+
+// A Sender delivers a payload to a function, and returns the
+// function's response payload, recording the request ID and any logs
+// in `out`. LambdaSender sends payloads through the Lambda API, and
+// FunctionURLs through HTTPS endpoints.
+type Sender interface {
+	Send(ctx context.Context, span *tracing.SpanBuilder,
+		function, qualifier string, returnLogs bool,
+		payload []byte, out *InvokeResult) ([]byte, error)
+}
+
+// LambdaSender invokes functions with the Lambda API's Invoke.
+type LambdaSender struct {
+	Lambda *lambda.Lambda
+}
+
+func Invoke(ctx context.Context, svc Sender,
 	st store.Store, args *InvokeArgs) (*InvokeResult, error) {
 	ctx, span := tracing.StartSpan(ctx, "llama.Invoke")
 	defer span.End()
@@ -65,22 +105,52 @@ func Invoke(ctx context.Context, svc *lambda.Lambda,
 		return nil, fmt.Errorf("marshal: %w", err)
 	}
 
+	var out InvokeResult
+	respPayload, err := svc.Send(ctx, span, args.Function, args.Qualifier, args.ReturnLogs, payload, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(respPayload, &out.Response); err != nil {
+		return nil, fmt.Errorf("unmarshal: %q", err)
+	}
+	if out.Response.Spilled != nil {
+		span.AddField("spilled", true)
+		if err := unspill(ctx, st, &out.Response); err != nil {
+			return nil, err
+		}
+	}
+
+	finishInvoke(ctx, span, st, &out)
+	return &out, nil
+}
+
+// Send implements Sender
+func (s *LambdaSender) Send(_ context.Context, span *tracing.SpanBuilder,
+	function, qualifier string, returnLogs bool,
+	payload []byte, out *InvokeResult) ([]byte, error) {
 	span.AddField("payload_bytes", len(payload))
 
 	input := lambda.InvokeInput{
-		FunctionName: &args.Function,
+		FunctionName: &function,
 		Payload:      payload,
 	}
-	if args.ReturnLogs {
+	if qualifier != "" {
+		input.Qualifier = &qualifier
+	}
+	if returnLogs {
 		input.LogType = aws.String(lambda.LogTypeTail)
 	}
 
-	var out InvokeResult
-
-	resp, err := svc.Invoke(&input)
-	if err != nil {
+	req, resp := s.Lambda.InvokeRequest(&input)
+	// Callers retry invocations with a RetryPolicy, so retries by
+	// the SDK would multiply the number of attempts
+	req.Retryer = client.NoOpRetryer{}
+	if err := req.Send(); err != nil {
 		return nil, fmt.Errorf("Invoke(): %w", err)
 	}
+	out.RequestID = req.RequestID
+	span.AddField("aws_request_id", out.RequestID)
 	if resp.LogResult != nil {
 		logs, _ := base64.StdEncoding.DecodeString(*resp.LogResult)
 		out.Logs = logs
@@ -88,17 +158,77 @@ func Invoke(ctx context.Context, svc *lambda.Lambda,
 
 	if resp.FunctionError != nil {
 		return nil, &ErrorReturn{
-			Payload: resp.Payload,
-			Logs:    out.Logs,
+			Payload:   resp.Payload,
+			Logs:      out.Logs,
+			RequestID: out.RequestID,
 		}
 	}
 
 	span.AddField("response_bytes", len(resp.Payload))
+	return resp.Payload, nil
+}
 
-	if err := json.Unmarshal(resp.Payload, &out.Response); err != nil {
-		return nil, fmt.Errorf("unmarshal: %q", err)
+// RequestID returns the Lambda request ID of an invocation, given
+// its result and error, or "" if it never reached Lambda.
+func RequestID(res *InvokeResult, err error) string {
+	var ret *ErrorReturn
+	switch {
+	case res != nil:
+		return res.RequestID
+	case errors.As(err, &ret):
+		return ret.RequestID
 	}
+	return ""
+}
 
+// InvokeLocal runs an invocation in a local subprocess using `r`,
+// instead of on Lambda.
+func InvokeLocal(ctx context.Context, r *runner.Runner,
+	st store.Store, args *InvokeArgs) (*InvokeResult, error) {
+	ctx, span := tracing.StartSpan(ctx, "llama.InvokeLocal")
+	defer span.End()
+	span.AddField("function", args.Function)
+
+	if span.WillSubmit() {
+		args.Spec.Trace = span.Propagation()
+	}
+
+	// Round-trip the spec through JSON, exactly as if we sent it
+	// to Lambda, since the runner modifies it.
+	payload, err := json.Marshal(&args.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	var spec protocol.InvocationSpec
+	if err := json.Unmarshal(payload, &spec); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	resp, err := r.RunOne(ctx, &spec)
+	if err != nil {
+		return nil, &ErrorReturn{Payload: []byte(err.Error())}
+	}
+	out := InvokeResult{Response: *resp}
+	finishInvoke(ctx, span, st, &out)
+	return &out, nil
+}
+
+// unspill replaces a response which the runtime spilled to the
+// store with the full response.
+func unspill(ctx context.Context, st store.Store, resp *protocol.InvocationResponse) error {
+	data, err := files.Read(ctx, st, resp.Spilled)
+	if err != nil {
+		return fmt.Errorf("reading spilled response: %w", err)
+	}
+	var full protocol.InvocationResponse
+	if err := json.Unmarshal(data, &full); err != nil {
+		return fmt.Errorf("unmarshal spilled response: %w", err)
+	}
+	*resp = full
+	return nil
+}
+
+func finishInvoke(ctx context.Context, span *tracing.SpanBuilder, st store.Store, out *InvokeResult) {
 	if out.Response.Spans != nil {
 		gets := files.AppendGet(nil, out.Response.Spans)
 		st.GetObjects(ctx, gets)
@@ -125,7 +255,6 @@ func Invoke(ctx context.Context, svc *lambda.Lambda,
 	span.AddField("upload_ms", out.Response.Times.Upload.Milliseconds())
 	if out.Response.Times.ColdStart {
 		span.AddField("cold_start", true)
+		span.AddField("init_ms", out.Response.Times.Init.Milliseconds())
 	}
-
-	return &out, nil
 }
@@ -0,0 +1,152 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/runner"
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvokeLocal(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	in, err := files.NewBlob(ctx, st, []byte("hello\n"))
+	require.NoError(t, err)
+
+	r := runner.New(st, []string{"/bin/sh", "-c"}, "local")
+	res, err := InvokeLocal(ctx, r, st, &InvokeArgs{
+		Function: "sh",
+		Spec: protocol.InvocationSpec{
+			Args:    []string{"tr a-z A-Z < in.txt > out.txt"},
+			Files:   protocol.FileList{{Path: "in.txt", File: protocol.File{Blob: *in}}},
+			Outputs: []string{"out.txt"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Response.ExitStatus)
+	require.Len(t, res.Response.Outputs, 1)
+	out, err := files.Read(ctx, st, &res.Response.Outputs[0].Blob)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", string(out))
+
+	res, err = InvokeLocal(ctx, r, st, &InvokeArgs{
+		Function: "sh",
+		Spec:     protocol.InvocationSpec{Args: []string{"exit 3"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Response.ExitStatus)
+}
+
+func TestUnspill(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	full := protocol.InvocationResponse{
+		ExitStatus: 2,
+		Outputs:    protocol.FileList{{Path: "out.o", File: protocol.File{Blob: protocol.Blob{Ref: "abc"}}}},
+	}
+	data, err := json.Marshal(&full)
+	require.NoError(t, err)
+	blob, err := files.NewBlob(ctx, st, data)
+	require.NoError(t, err)
+
+	resp := protocol.InvocationResponse{Spilled: blob}
+	require.NoError(t, unspill(ctx, st, &resp))
+	assert.Equal(t, full, resp)
+
+	resp = protocol.InvocationResponse{Spilled: &protocol.Blob{Ref: "missing"}}
+	assert.Error(t, unspill(ctx, st, &resp))
+}
+
+func TestUnpack(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	full := protocol.InvocationResponse{ExitStatus: 1}
+	data, err := json.Marshal(&full)
+	require.NoError(t, err)
+	blob, err := files.NewBlob(ctx, st, data)
+	require.NoError(t, err)
+
+	spans := make([]*tracing.SpanBuilder, 3)
+	for i := range spans {
+		_, spans[i] = tracing.StartSpan(ctx, "job")
+	}
+	out := InvokeResult{RequestID: "req-1", Logs: []byte("logs")}
+	results, err := unpack(ctx, st, spans, &out, &protocol.PackResponse{
+		Results: []protocol.PackResult{
+			{Response: &protocol.InvocationResponse{ExitStatus: 0}},
+			{Error: "No arguments provided"},
+			{Response: &protocol.InvocationResponse{Spilled: blob}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "req-1", results[0].Result.RequestID)
+	assert.Equal(t, []byte("logs"), results[0].Result.Logs)
+
+	var ret *ErrorReturn
+	require.True(t, errors.As(results[1].Err, &ret))
+	assert.Equal(t, "No arguments provided", string(ret.Payload))
+	assert.Equal(t, "req-1", RequestID(results[1].Result, results[1].Err))
+
+	require.NoError(t, results[2].Err)
+	assert.Equal(t, full, results[2].Result.Response)
+
+	_, err = unpack(ctx, st, spans[:2], &out, &protocol.PackResponse{})
+	assert.Error(t, err)
+}
+
+func TestLambdaSenderNoSDKRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Amzn-ErrorType", lambda.ErrCodeTooManyRequestsException)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message":"Rate exceeded"}`))
+	}))
+	defer srv.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(srv.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(3),
+	}))
+	sender := &LambdaSender{Lambda: lambda.New(sess)}
+	ctx, span := tracing.StartSpan(context.Background(), "test")
+	defer span.End()
+	_, err := sender.Send(ctx, span, "gcc", "", false, []byte("{}"), &InvokeResult{})
+	assert.True(t, IsThrottled(err), "%v", err)
+	// The RetryPolicy retries, not the SDK
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/tracing"
+)
+
+type InvokePackedArgs struct {
+	Function   string
+	Qualifier  string
+	ReturnLogs bool
+	// The jobs to run, and how many of them the function runs at
+	// once
+	Specs       []protocol.InvocationSpec
+	Parallelism int
+}
+
+// A PackedResult is the outcome of one job in a pack: its result, or
+// the error that kept the runtime from running it.
+type PackedResult struct {
+	Result *InvokeResult
+	Err    error
+}
+
+// InvokePacked runs several jobs in a single invocation of a
+// function, which must run a runtime that understands packs. It
+// returns an error if the invocation as a whole failed, and
+// otherwise a result for each job, in order. Every result carries
+// the invocation's request ID and logs.
+func InvokePacked(ctx context.Context, svc Sender,
+	st store.Store, args *InvokePackedArgs) ([]PackedResult, error) {
+	ctx, span := tracing.StartSpan(ctx, "llama.InvokePacked")
+	defer span.End()
+	span.AddField("function", args.Function)
+	span.AddField("jobs", len(args.Specs))
+
+	// Each job gets a span of its own, under the one its caller
+	// propagated in its spec, if any.
+	pack := protocol.Pack{
+		Jobs:        make([]protocol.InvocationSpec, len(args.Specs)),
+		Parallelism: args.Parallelism,
+	}
+	spans := make([]*tracing.SpanBuilder, len(args.Specs))
+	for i := range args.Specs {
+		pack.Jobs[i] = args.Specs[i]
+		_, spans[i] = tracing.StartPropagatedSpan(ctx, "llama.Invoke", args.Specs[i].Trace)
+		defer spans[i].End()
+		spans[i].AddField("function", args.Function)
+		if spans[i].WillSubmit() {
+			pack.Jobs[i].Trace = spans[i].Propagation()
+		}
+	}
+
+	payload, err := json.Marshal(&protocol.PackPayload{Pack: &pack})
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	var out InvokeResult
+	respPayload, err := svc.Send(ctx, span, args.Function, args.Qualifier, args.ReturnLogs, payload, &out)
+	if err != nil {
+		return nil, err
+	}
+	var resp protocol.PackResponse
+	if err := json.Unmarshal(respPayload, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal: %q", err)
+	}
+	return unpack(ctx, st, spans, &out, &resp)
+}
+
+// unpack splits the response to a packed invocation into the results
+// of its jobs, each of which shares the invocation's request ID and
+// logs from `out`.
+func unpack(ctx context.Context, st store.Store, spans []*tracing.SpanBuilder,
+	out *InvokeResult, resp *protocol.PackResponse) ([]PackedResult, error) {
+	if len(resp.Results) != len(spans) {
+		return nil, fmt.Errorf("function returned %d results for %d packed jobs", len(resp.Results), len(spans))
+	}
+	results := make([]PackedResult, len(spans))
+	for i, r := range resp.Results {
+		if r.Response == nil {
+			results[i].Err = &ErrorReturn{
+				Payload:   []byte(r.Error),
+				Logs:      out.Logs,
+				RequestID: out.RequestID,
+			}
+			continue
+		}
+		res := &InvokeResult{
+			Logs:      out.Logs,
+			RequestID: out.RequestID,
+			Response:  *r.Response,
+		}
+		if res.Response.Spilled != nil {
+			spans[i].AddField("spilled", true)
+			if err := unspill(ctx, st, &res.Response); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+		finishInvoke(ctx, spans[i], st, res)
+		results[i].Result = res
+	}
+	return results, nil
+}
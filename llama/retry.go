@@ -0,0 +1,128 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// A RetryPolicy describes how to retry invocations which fail with
+// transient errors.
+type RetryPolicy struct {
+	// The maximum number of attempts, including the first. A
+	// value of 1 or less disables retries.
+	MaxAttempts int
+	// The delay before the first retry. Each subsequent retry
+	// doubles the delay, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	Backoff:     250 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+}
+
+// Error codes, across Lambda and S3, which indicate that we are being
+// throttled
+var throttleCodes = map[string]bool{
+	lambda.ErrCodeTooManyRequestsException: true,
+	lambda.ErrCodeEC2ThrottledException:    true,
+	"SlowDown":                             true,
+	"ThrottlingException":                  true,
+	"RequestLimitExceeded":                 true,
+}
+
+// IsRetryable reports whether an invocation error is likely to be
+// transient: throttling or server errors from Lambda or S3, or
+// network errors.
+func IsRetryable(err error) bool {
+	var ret *ErrorReturn
+	if errors.As(err, &ret) {
+		// S3 throttling the function
+		return throttleCodes[ret.Type()]
+	}
+	var reqerr awserr.RequestFailure
+	if errors.As(err, &reqerr) {
+		return throttleCodes[reqerr.Code()] ||
+			reqerr.StatusCode() == 429 ||
+			reqerr.StatusCode() >= 500
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return throttleCodes[awsErr.Code()] ||
+			awsErr.Code() == request.ErrCodeRequestError ||
+			awsErr.Code() == request.ErrCodeResponseTimeout
+	}
+	return false
+}
+
+// IsThrottled reports whether an invocation error means that Lambda
+// (or EC2, on its behalf) is refusing to run any more concurrent
+// invocations, as opposed to any other transient failure.
+func IsThrottled(err error) bool {
+	var reqerr awserr.RequestFailure
+	if errors.As(err, &reqerr) {
+		return throttleCodes[reqerr.Code()] || reqerr.StatusCode() == 429
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return throttleCodes[awsErr.Code()]
+	}
+	return false
+}
+
+// Delay returns how long to wait before retry number `retry`
+// (counting from 0). Delays grow exponentially, with random jitter of
+// up to half the delay so that clients throttled at the same time
+// don't retry in lockstep.
+func (p *RetryPolicy) Delay(retry int) time.Duration {
+	d := p.Backoff
+	for i := 0; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Do calls `fn` until it succeeds, it returns an error which is not
+// retryable, or we run out of attempts, and returns its last error.
+// `fn` is passed the number of the attempt, counting from 0.
+func (p *RetryPolicy) Do(ctx context.Context, fn func(attempt int) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || attempt+1 >= p.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.Delay(attempt)):
+		}
+	}
+}
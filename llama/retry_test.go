@@ -0,0 +1,121 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{awserr.NewRequestFailure(awserr.New(lambda.ErrCodeTooManyRequestsException, "slow down", nil), 429, "id"), true},
+		{awserr.NewRequestFailure(awserr.New(lambda.ErrCodeServiceException, "oops", nil), 500, "id"), true},
+		{awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate", nil), 503, "id"), true},
+		{awserr.NewRequestFailure(awserr.New(lambda.ErrCodeResourceNotFoundException, "no such function", nil), 404, "id"), false},
+		{awserr.New(request.ErrCodeRequestError, "send request failed", nil), true},
+		{fmt.Errorf("Invoke(): %w", awserr.New(request.ErrCodeResponseTimeout, "timeout", nil)), true},
+		{&ErrorReturn{Payload: []byte(`{"errorMessage":"fetching files: SlowDown: Please reduce your request rate","errorType":"SlowDown"}`)}, true},
+		// Only the error's type counts, not its text
+		{&ErrorReturn{Payload: []byte(`{"errorMessage":"compiling SlowDown.c: exit status 1","errorType":"errorString"}`)}, false},
+		{&ErrorReturn{Payload: []byte(`{"errorMessage":"Runtime exited with error: signal: killed"}`)}, false},
+		{&ErrorReturn{Payload: []byte(`not json`)}, false},
+		{errors.New("marshal: oops"), false},
+		{context.Canceled, false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, IsRetryable(tc.err), "%v", tc.err)
+	}
+}
+
+func TestIsThrottled(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{awserr.NewRequestFailure(awserr.New(lambda.ErrCodeTooManyRequestsException, "Rate exceeded", nil), 429, "id"), true},
+		{fmt.Errorf("Invoke(): %w", awserr.NewRequestFailure(awserr.New("Unknown", "", nil), 429, "id")), true},
+		{awserr.New(lambda.ErrCodeEC2ThrottledException, "throttled", nil), true},
+		{awserr.NewRequestFailure(awserr.New(lambda.ErrCodeServiceException, "oops", nil), 500, "id"), false},
+		{awserr.New(request.ErrCodeRequestError, "send request failed", nil), false},
+		{&ErrorReturn{Payload: []byte(`{"errorMessage":"fetching files: SlowDown: Please reduce your request rate","errorType":"SlowDown"}`)}, false},
+		{errors.New("marshal: oops"), false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, IsThrottled(tc.err), "%v", tc.err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			d := p.Delay(retry)
+			assert.True(t, d >= max/2 && d <= max, "retry %d: delay %s not in [%s, %s]", retry, d, max/2, max)
+		}
+	}
+}
+
+func TestRetryDo(t *testing.T) {
+	throttled := awserr.NewRequestFailure(awserr.New(lambda.ErrCodeTooManyRequestsException, "", nil), 429, "")
+	p := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx := context.Background()
+
+	calls := 0
+	err := p.Do(ctx, func(attempt int) error {
+		assert.Equal(t, calls, attempt)
+		calls++
+		if calls < 2 {
+			return throttled
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	err = p.Do(ctx, func(int) error { calls++; return throttled })
+	assert.Equal(t, throttled, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	bad := errors.New("bad arguments")
+	err = p.Do(ctx, func(int) error { calls++; return bad })
+	assert.Equal(t, bad, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	p.MaxAttempts = 0
+	p.Do(ctx, func(int) error { calls++; return throttled })
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+const (
+	// ToolchainLabel is the image label under which `llama
+	// toolchain sync` records the compiler it installed
+	ToolchainLabel = "llama.toolchain"
+	// ToolchainTag is the tag under which `llama update-function`
+	// copies an image's ToolchainLabel onto the function, where
+	// clients can find it without pulling the image
+	ToolchainTag = "LlamaToolchain"
+)
+
+// A Toolchain identifies a C compiler exactly enough to tell whether
+// objects it produces can be mixed with another's.
+type Toolchain struct {
+	// "gcc" or "clang"
+	Family  string
+	Version string
+	// The compiler's target triple, from -dumpmachine
+	Machine string
+}
+
+func (t Toolchain) String() string {
+	return fmt.Sprintf("%s %s %s", t.Family, t.Version, t.Machine)
+}
+
+// ParseToolchain parses a toolchain in the form Toolchain.String
+// produces, as found in a ToolchainLabel.
+func ParseToolchain(s string) (Toolchain, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return Toolchain{}, fmt.Errorf("bad toolchain %q: expected FAMILY VERSION MACHINE", s)
+	}
+	return Toolchain{Family: fields[0], Version: fields[1], Machine: fields[2]}, nil
+}
+
+// Mismatch describes how `remote`, targeting `target` if it is not
+// empty, differs from `t`, or returns "" if they match.
+func (t Toolchain) Mismatch(remote Toolchain, target string) string {
+	machine := remote.Machine
+	if target != "" {
+		machine = target
+	}
+	var diffs []string
+	if t.Family != remote.Family || t.Version != remote.Version {
+		diffs = append(diffs, fmt.Sprintf("%s %s locally but %s %s remotely",
+			t.Family, t.Version, remote.Family, remote.Version))
+	}
+	if normalizeTriple(t.Machine) != normalizeTriple(machine) {
+		diffs = append(diffs, fmt.Sprintf("targets %s locally but %s remotely", t.Machine, machine))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// normalizeTriple drops the vendor from a target triple, since gcc
+// and clang spell the same target differently (x86_64-linux-gnu and
+// x86_64-pc-linux-gnu).
+func normalizeTriple(triple string) string {
+	parts := strings.Split(triple, "-")
+	if len(parts) >= 3 && (parts[1] == "pc" || parts[1] == "unknown") {
+		parts = append(parts[:1], parts[2:]...)
+	}
+	return strings.Join(parts, "-")
+}
+
+func compilerOutput(args ...string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ProbeCompiler identifies the gcc or clang at `cc`.
+func ProbeCompiler(cc string) (Toolchain, error) {
+	var tc Toolchain
+	banner, err := compilerOutput(cc, "--version")
+	if err != nil {
+		return tc, err
+	}
+	tc.Family = "gcc"
+	versionFlag := "-dumpfullversion"
+	if strings.Contains(banner, "clang") {
+		tc.Family = "clang"
+		versionFlag = "-dumpversion"
+	}
+	if tc.Version, err = compilerOutput(cc, versionFlag); err != nil {
+		return tc, err
+	}
+	if tc.Machine, err = compilerOutput(cc, "-dumpmachine"); err != nil {
+		return tc, err
+	}
+	return tc, nil
+}
+
+// FunctionToolchain returns the toolchain recorded on `function`, or
+// "" if it has none.
+func FunctionToolchain(ctx context.Context, svc *lambda.Lambda, function string) (string, error) {
+	out, err := svc.GetFunctionWithContext(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(function),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.Tags[ToolchainTag]), nil
+}
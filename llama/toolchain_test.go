@@ -0,0 +1,57 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToolchain(t *testing.T) {
+	tc, err := ParseToolchain("gcc 9.3.0 x86_64-linux-gnu")
+	require.NoError(t, err)
+	assert.Equal(t, Toolchain{Family: "gcc", Version: "9.3.0", Machine: "x86_64-linux-gnu"}, tc)
+	assert.Equal(t, "gcc 9.3.0 x86_64-linux-gnu", tc.String())
+
+	_, err = ParseToolchain("gcc 9.3.0")
+	assert.Error(t, err)
+	_, err = ParseToolchain("")
+	assert.Error(t, err)
+}
+
+func TestToolchainMismatch(t *testing.T) {
+	gcc9 := Toolchain{Family: "gcc", Version: "9.3.0", Machine: "x86_64-linux-gnu"}
+	clang := Toolchain{Family: "clang", Version: "13.0.1", Machine: "x86_64-pc-linux-gnu"}
+
+	assert.Equal(t, "", gcc9.Mismatch(gcc9, ""))
+
+	gcc10 := gcc9
+	gcc10.Version = "10.2.1"
+	assert.Equal(t, "gcc 9.3.0 locally but gcc 10.2.1 remotely", gcc9.Mismatch(gcc10, ""))
+
+	arm := gcc9
+	arm.Machine = "aarch64-linux-gnu"
+	assert.Equal(t, "targets x86_64-linux-gnu locally but aarch64-linux-gnu remotely", gcc9.Mismatch(arm, ""))
+
+	// A cross-compiling function is compared by its target
+	armClang := clang
+	armClang.Machine = "aarch64-unknown-linux-gnu"
+	assert.Equal(t, "", clang.Mismatch(armClang, "x86_64-linux-gnu"))
+	assert.Equal(t,
+		"clang 13.0.1 locally but gcc 9.3.0 remotely; targets x86_64-pc-linux-gnu locally but aarch64-linux-gnu remotely",
+		clang.Mismatch(arm, ""))
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/tracing"
+)
+
+// How FunctionURLs authenticates its requests
+const (
+	// Sign requests with SigV4, as Function URLs with the
+	// AWS_IAM auth type require
+	URLAuthIAM = "iam"
+	// Send a bearer token, such as an OIDC ID token, for an API
+	// Gateway authorizer or a proxy to check
+	URLAuthOIDC = "oidc"
+	// Send no credentials
+	URLAuthNone = "none"
+)
+
+// FunctionURLs invokes functions by POSTing their payloads to HTTPS
+// endpoints: Lambda Function URLs, or an API Gateway or CDN in front
+// of them. This works where egress proxies block the Lambda API, and
+// lets the endpoint be fronted by a CDN. Function URLs can't return
+// logs, so ReturnLogs is ignored.
+type FunctionURLs struct {
+	// The endpoint for each function. An endpoint for
+	// "FUNCTION:QUALIFIER" invokes that version or alias;
+	// otherwise, qualified invocations use the function's
+	// endpoint.
+	URLs map[string]string
+	// One of the URLAuth constants; the default is URLAuthIAM
+	Auth string
+	// For URLAuthIAM, the credentials to sign requests with, and
+	// the region to sign them for, unless the endpoint is a Lambda
+	// Function URL, which names its region
+	Credentials *credentials.Credentials
+	Region      string
+	// For URLAuthOIDC, a file holding the token to send, which
+	// we read for every request so that it can be refreshed
+	// underneath us
+	TokenFile string
+	// The HTTP client to use, or http.DefaultClient
+	Client *http.Client
+}
+
+// Serves reports whether `function` has an endpoint. It is safe to
+// call on a nil *FunctionURLs.
+func (f *FunctionURLs) Serves(function string) bool {
+	if f == nil {
+		return false
+	}
+	_, ok := f.URLs[function]
+	return ok
+}
+
+// URL returns the endpoint to invoke `function` at.
+func (f *FunctionURLs) URL(function, qualifier string) (string, bool) {
+	if qualifier != "" {
+		if u, ok := f.URLs[function+":"+qualifier]; ok {
+			return u, true
+		}
+	}
+	u, ok := f.URLs[function]
+	return u, ok
+}
+
+// Validate checks that f's endpoints and authentication make sense.
+func (f *FunctionURLs) Validate() error {
+	for fn, u := range f.URLs {
+		if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return fmt.Errorf("%s: %q is not an HTTP(S) URL", fn, u)
+		}
+	}
+	switch f.Auth {
+	case "", URLAuthIAM, URLAuthNone:
+	case URLAuthOIDC:
+		if f.TokenFile == "" {
+			return fmt.Errorf("auth %q needs a token_file", f.Auth)
+		}
+	default:
+		return fmt.Errorf("unknown auth %q: expected %s, %s, or %s", f.Auth, URLAuthIAM, URLAuthOIDC, URLAuthNone)
+	}
+	return nil
+}
+
+// Send implements Sender
+func (f *FunctionURLs) Send(ctx context.Context, span *tracing.SpanBuilder,
+	function, qualifier string, returnLogs bool,
+	payload []byte, out *InvokeResult) ([]byte, error) {
+	span.AddField("payload_bytes", len(payload))
+	span.AddField("function_url", true)
+
+	u, ok := f.URL(function, qualifier)
+	if !ok {
+		return nil, fmt.Errorf("no function URL for %s", function)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := f.authenticate(req, payload); err != nil {
+		return nil, err
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Report network errors as the AWS SDK would, so that
+		// we retry them in the same way
+		return nil, awserr.New(request.ErrCodeRequestError, "POST "+u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, awserr.New(request.ErrCodeRequestError, "reading response", err)
+	}
+
+	out.RequestID = resp.Header.Get("X-Amzn-Requestid")
+	if out.RequestID == "" {
+		out.RequestID = resp.Header.Get("Apigw-Requestid")
+	}
+	span.AddField("aws_request_id", out.RequestID)
+	return urlResponse(resp, body, out.RequestID)
+}
+
+// urlResponse interprets the response to a Function URL request.
+func urlResponse(resp *http.Response, body []byte, requestID string) ([]byte, error) {
+	switch {
+	case resp.Header.Get(protocol.FunctionErrorHeader) != "":
+		return nil, &ErrorReturn{Payload: body, RequestID: requestID}
+	case resp.StatusCode == http.StatusOK:
+		return body, nil
+	case resp.StatusCode == http.StatusBadGateway:
+		// Lambda's answer when the function crashes, times
+		// out, or returns something it can't make sense of
+		return nil, &ErrorReturn{Payload: body, RequestID: requestID}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, awserr.NewRequestFailure(
+			awserr.New(lambda.ErrCodeTooManyRequestsException, string(body), nil),
+			resp.StatusCode, requestID)
+	}
+	return nil, awserr.NewRequestFailure(
+		awserr.New(http.StatusText(resp.StatusCode), strings.TrimSpace(string(body)), nil),
+		resp.StatusCode, requestID)
+}
+
+func (f *FunctionURLs) authenticate(req *http.Request, payload []byte) error {
+	switch f.Auth {
+	case "", URLAuthIAM:
+		if f.Credentials == nil {
+			return fmt.Errorf("function URLs: no credentials to sign requests with")
+		}
+		signer := v4.NewSigner(f.Credentials)
+		_, err := signer.Sign(req, bytes.NewReader(payload), "lambda", urlRegion(req.URL.Hostname(), f.Region), time.Now())
+		return err
+	case URLAuthOIDC:
+		token, err := ioutil.ReadFile(f.TokenFile)
+		if err != nil {
+			return fmt.Errorf("function URLs: reading token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return nil
+}
+
+// urlRegion returns the region of a Function URL's host,
+// ID.lambda-url.REGION.on.aws, or `def` for any other host.
+func urlRegion(host, def string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) == 5 && parts[1] == "lambda-url" && parts[3] == "on" && parts[4] == "aws" {
+		return parts[2]
+	}
+	return def
+}
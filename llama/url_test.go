@@ -0,0 +1,121 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionURLSend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Amzn-Requestid", "req-1")
+		switch r.URL.Path {
+		case "/echo":
+			assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+			w.Write(body)
+		case "/fail":
+			w.Header().Set(protocol.FunctionErrorHeader, "Unhandled")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errorMessage":"boom"}`))
+		}
+	}))
+	defer srv.Close()
+
+	tokenFile := path.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("tok\n"), 0644))
+	urls := &FunctionURLs{
+		URLs: map[string]string{
+			"echo": srv.URL + "/echo",
+			"fail": srv.URL + "/fail",
+		},
+		Auth:      URLAuthOIDC,
+		TokenFile: tokenFile,
+	}
+	require.NoError(t, urls.Validate())
+
+	ctx, span := tracing.StartSpan(context.Background(), "test")
+	var out InvokeResult
+	resp, err := urls.Send(ctx, span, "echo", "", false, []byte(`{"args":[]}`), &out)
+	require.NoError(t, err)
+	assert.Equal(t, `{"args":[]}`, string(resp))
+	assert.Equal(t, "req-1", out.RequestID)
+
+	_, err = urls.Send(ctx, span, "fail", "", false, []byte(`{}`), &out)
+	ret, ok := err.(*ErrorReturn)
+	require.True(t, ok, "error: %v", err)
+	assert.Equal(t, `{"errorMessage":"boom"}`, string(ret.Payload))
+
+	_, err = urls.Send(ctx, span, "missing", "", false, []byte(`{}`), &out)
+	assert.Error(t, err)
+}
+
+func TestURLResponse(t *testing.T) {
+	_, err := urlResponse(&http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}}, []byte("crashed"), "")
+	assert.IsType(t, &ErrorReturn{}, err)
+
+	_, err = urlResponse(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}, nil, "")
+	aerr, ok := err.(awserr.RequestFailure)
+	require.True(t, ok)
+	assert.Equal(t, lambda.ErrCodeTooManyRequestsException, aerr.Code())
+
+	_, err = urlResponse(&http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}}, []byte("denied\n"), "")
+	aerr, ok = err.(awserr.RequestFailure)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, aerr.StatusCode())
+	assert.Equal(t, "denied", aerr.Message())
+}
+
+func TestFunctionURLQualifier(t *testing.T) {
+	urls := &FunctionURLs{URLs: map[string]string{
+		"cc":      "https://a.example.com",
+		"cc:live": "https://b.example.com",
+	}}
+	u, ok := urls.URL("cc", "live")
+	require.True(t, ok)
+	assert.Equal(t, "https://b.example.com", u)
+	u, ok = urls.URL("cc", "v3")
+	require.True(t, ok)
+	assert.Equal(t, "https://a.example.com", u)
+
+	assert.True(t, urls.Serves("cc"))
+	assert.False(t, urls.Serves("gcc"))
+	var none *FunctionURLs
+	assert.False(t, none.Serves("cc"))
+}
+
+func TestFunctionURLValidate(t *testing.T) {
+	assert.NoError(t, (&FunctionURLs{URLs: map[string]string{"cc": "https://x"}}).Validate())
+	assert.Error(t, (&FunctionURLs{URLs: map[string]string{"cc": "x.example.com"}}).Validate())
+	assert.Error(t, (&FunctionURLs{Auth: URLAuthOIDC}).Validate())
+	assert.Error(t, (&FunctionURLs{Auth: "kerberos"}).Validate())
+}
+
+func TestURLRegion(t *testing.T) {
+	assert.Equal(t, "eu-west-1", urlRegion("abc123.lambda-url.eu-west-1.on.aws", "us-east-1"))
+	assert.Equal(t, "us-east-1", urlRegion("llama.example.com", "us-east-1"))
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// A Variant is a copy of a function with a particular memory size
+// and timeout. `llama update-function -variants` deploys each variant
+// as a function of its own, with the same code and configuration, and
+// a name which records its size.
+type Variant struct {
+	// The function to invoke, or "" for the function itself
+	Function string
+	Memory   int64
+	Timeout  time.Duration
+}
+
+// VariantFunction returns the name of the variant of `function` with
+// the given memory size and timeout.
+func VariantFunction(function string, memory int64, timeout time.Duration) string {
+	return fmt.Sprintf("%s-m%d-t%d", function, memory, int64(timeout/time.Second))
+}
+
+func parseVariantFunction(function, name string) (Variant, bool) {
+	if !strings.HasPrefix(name, function+"-") {
+		return Variant{}, false
+	}
+	var memory, seconds int64
+	var rest string
+	n, _ := fmt.Sscanf(name[len(function):], "-m%d-t%d%s", &memory, &seconds, &rest)
+	timeout := time.Duration(seconds) * time.Second
+	if n != 2 || name != VariantFunction(function, memory, timeout) {
+		return Variant{}, false
+	}
+	return Variant{Function: name, Memory: memory, Timeout: timeout}, true
+}
+
+// ListVariants returns all variants of `function`, starting with the
+// function itself.
+func ListVariants(svc *lambda.Lambda, function string) ([]Variant, error) {
+	cfg, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(function),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := []Variant{{
+		Memory:  aws.Int64Value(cfg.MemorySize),
+		Timeout: time.Duration(aws.Int64Value(cfg.Timeout)) * time.Second,
+	}}
+	err = svc.ListFunctionsPages(&lambda.ListFunctionsInput{},
+		func(page *lambda.ListFunctionsOutput, _ bool) bool {
+			for _, fn := range page.Functions {
+				if v, ok := parseVariantFunction(function, aws.StringValue(fn.FunctionName)); ok {
+					out = append(out, v)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PickVariant returns the smallest variant with at least `memory` MB
+// of memory and a timeout of at least `timeout`. Zero values impose no
+// requirement.
+func PickVariant(variants []Variant, memory int64, timeout time.Duration) (Variant, error) {
+	var fit []Variant
+	for _, v := range variants {
+		if v.Memory >= memory && v.Timeout >= timeout {
+			fit = append(fit, v)
+		}
+	}
+	if len(fit) == 0 {
+		return Variant{}, fmt.Errorf("no variant has at least %dMB of memory and a %s timeout", memory, timeout)
+	}
+	sort.Slice(fit, func(i, j int) bool {
+		if fit[i].Memory != fit[j].Memory {
+			return fit[i].Memory < fit[j].Memory
+		}
+		return fit[i].Timeout < fit[j].Timeout
+	})
+	return fit[0], nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package llama
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariantFunction(t *testing.T) {
+	v, ok := parseVariantFunction("gcc", VariantFunction("gcc", 3008, 5*time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, Variant{Function: "gcc-m3008-t300", Memory: 3008, Timeout: 5 * time.Minute}, v)
+
+	for _, name := range []string{"gcc", "gcc-m3008", "gcc-m3008-t300x", "gcc-old", "clang-m3008-t300", "gcc-m3008-t300-m4096-t60"} {
+		_, ok := parseVariantFunction("gcc", name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []Variant{
+		{Function: "", Memory: 1769, Timeout: time.Minute},
+		{Function: "gcc-m10240-t900", Memory: 10240, Timeout: 15 * time.Minute},
+		{Function: "gcc-m3008-t60", Memory: 3008, Timeout: time.Minute},
+		{Function: "gcc-m3008-t300", Memory: 3008, Timeout: 5 * time.Minute},
+	}
+	cases := []struct {
+		memory  int64
+		timeout time.Duration
+		want    string
+		err     bool
+	}{
+		{0, 0, "", false},
+		{2048, 0, "gcc-m3008-t60", false},
+		{2048, 2 * time.Minute, "gcc-m3008-t300", false},
+		{0, 10 * time.Minute, "gcc-m10240-t900", false},
+		{20000, 0, "", true},
+	}
+	for _, tc := range cases {
+		v, err := PickVariant(variants, tc.memory, tc.timeout)
+		if tc.err {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, v.Function, "memory=%d timeout=%s", tc.memory, tc.timeout)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging configures how Llama's components write their logs.
+//
+// By default we log plain text through the standard `log` package.
+// In JSON mode, every line becomes a JSON object carrying the
+// component that wrote it, and, for messages logged with a context,
+// the invocation and trace IDs attached to that context, so that logs
+// from the client, the daemon, and the runtime in CloudWatch can be
+// correlated and ingested by log pipelines.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/tracing"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// FormatEnv names the environment variable that selects the log
+// format. Setup sets it, so that processes we start, such as an
+// autostarted daemon, log the same way.
+const FormatEnv = "LLAMA_LOG_FORMAT"
+
+var state struct {
+	sync.Mutex
+	json      bool
+	component string
+	out       io.Writer
+}
+
+func init() {
+	state.out = os.Stderr
+}
+
+// Setup configures logging for `component`. An empty format selects
+// the format from $LLAMA_LOG_FORMAT, defaulting to text.
+func Setup(format, component string) error {
+	if format == "" {
+		format = os.Getenv(FormatEnv)
+	}
+	switch format {
+	case "", FormatText:
+		format = FormatText
+	case FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q (want %s or %s)", format, FormatText, FormatJSON)
+	}
+	os.Setenv(FormatEnv, format)
+
+	state.Lock()
+	defer state.Unlock()
+	state.json = format == FormatJSON
+	state.component = component
+	if state.json {
+		log.SetFlags(0)
+		log.SetOutput(lineWriter{})
+	} else {
+		log.SetFlags(log.LstdFlags)
+		log.SetOutput(state.out)
+	}
+	return nil
+}
+
+// SetOutput redirects logs to `w`; use it instead of log.SetOutput.
+func SetOutput(w io.Writer) {
+	state.Lock()
+	defer state.Unlock()
+	state.out = w
+	if !state.json {
+		log.SetOutput(w)
+	}
+}
+
+// JSON reports whether we are logging JSON
+func JSON() bool {
+	state.Lock()
+	defer state.Unlock()
+	return state.json
+}
+
+type fieldsKey struct{}
+
+// WithFields returns a context which attaches the given key-value
+// pairs to everything logged with it.
+func WithFields(ctx context.Context, kv ...interface{}) context.Context {
+	fields := map[string]interface{}{}
+	if parent, ok := ctx.Value(fieldsKey{}).(map[string]interface{}); ok {
+		for k, v := range parent {
+			fields[k] = v
+		}
+	}
+	addPairs(fields, kv)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+func addPairs(fields map[string]interface{}, kv []interface{}) {
+	for i := 0; i+1 < len(kv); i += 2 {
+		k := fmt.Sprint(kv[i])
+		switch v := kv[i+1].(type) {
+		case time.Duration:
+			// Durations are easier to work with as numbers
+			fields[k+"_ms"] = float64(v) / float64(time.Millisecond)
+		case error:
+			fields[k] = v.Error()
+		default:
+			fields[k] = v
+		}
+	}
+}
+
+// Printf logs a message like log.Printf. In JSON mode, the record
+// also carries the fields attached to `ctx`, and its trace and span
+// IDs.
+func Printf(ctx context.Context, format string, args ...interface{}) {
+	state.Lock()
+	isJSON := state.json
+	state.Unlock()
+	if !isJSON {
+		log.Printf(format, args...)
+		return
+	}
+	write(ctx, fmt.Sprintf(format, args...), nil)
+}
+
+// Record logs a structured record, such as a summary of an
+// invocation with its timings. Records are only written in JSON
+// mode; text logs would be too noisy with them.
+func Record(ctx context.Context, msg string, kv ...interface{}) {
+	state.Lock()
+	isJSON := state.json
+	state.Unlock()
+	if isJSON {
+		write(ctx, msg, kv)
+	}
+}
+
+func write(ctx context.Context, msg string, kv []interface{}) {
+	fields := map[string]interface{}{}
+	if ctx != nil {
+		if span, ok := tracing.SpanFromContext(ctx); ok {
+			fields["trace_id"] = span.TraceId
+			fields["span_id"] = span.SpanId
+		}
+		if attached, ok := ctx.Value(fieldsKey{}).(map[string]interface{}); ok {
+			for k, v := range attached {
+				fields[k] = v
+			}
+		}
+	}
+	addPairs(fields, kv)
+	state.Lock()
+	defer state.Unlock()
+	writeLocked(msg, fields)
+}
+
+func writeLocked(msg string, fields map[string]interface{}) {
+	fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	fields["msg"] = msg
+	if state.component != "" {
+		fields["component"] = state.component
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		// Some value wasn't representable; don't lose the
+		// message
+		buf.Reset()
+		enc.Encode(map[string]interface{}{
+			"time": fields["time"], "msg": msg, "component": state.component,
+			"log_error": err.Error(),
+		})
+	}
+	state.out.Write(buf.Bytes())
+}
+
+// lineWriter receives messages from the standard logger in JSON mode
+type lineWriter struct{}
+
+func (lineWriter) Write(p []byte) (int, error) {
+	state.Lock()
+	defer state.Unlock()
+	writeLocked(string(bytes.TrimSuffix(p, []byte("\n"))), map[string]interface{}{})
+	return len(p), nil
+}
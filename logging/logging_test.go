@@ -0,0 +1,94 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func capture(t *testing.T, format string) *bytes.Buffer {
+	var buf bytes.Buffer
+	require.NoError(t, Setup(format, "test"))
+	SetOutput(&buf)
+	t.Cleanup(func() {
+		SetOutput(os.Stderr)
+		Setup(FormatText, "")
+		os.Unsetenv(FormatEnv)
+	})
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec), line)
+		assert.NotEmpty(t, rec["time"])
+		delete(rec, "time")
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestJSON(t *testing.T) {
+	buf := capture(t, FormatJSON)
+	assert.Equal(t, FormatJSON, os.Getenv(FormatEnv))
+
+	ctx, span := tracing.StartSpanInTrace(context.Background(), "test", "trace", "")
+	ctx = WithFields(ctx, "invocation_id", "abc")
+	ctx = WithFields(ctx, "function", "gcc")
+
+	log.Printf("plain <message>")
+	Printf(ctx, "hello %d", 1)
+	Record(ctx, "invocation", "exit_status", 2, "e2e", 1500*time.Microsecond)
+
+	assert.Equal(t, []map[string]interface{}{
+		{"component": "test", "msg": "plain <message>"},
+		{
+			"component": "test", "msg": "hello 1",
+			"trace_id": "trace", "span_id": span.Id(),
+			"invocation_id": "abc", "function": "gcc",
+		},
+		{
+			"component": "test", "msg": "invocation",
+			"trace_id": "trace", "span_id": span.Id(),
+			"invocation_id": "abc", "function": "gcc",
+			"exit_status": 2.0, "e2e_ms": 1.5,
+		},
+	}, records(t, buf))
+}
+
+func TestText(t *testing.T) {
+	buf := capture(t, "")
+	ctx := WithFields(context.Background(), "invocation_id", "abc")
+	Printf(ctx, "hello %d", 1)
+	Record(ctx, "invocation", "exit_status", 2)
+	assert.Regexp(t, `^\S+ \S+ hello 1\n$`, buf.String())
+}
+
+func TestBadFormat(t *testing.T) {
+	assert.Error(t, Setup("xml", "test"))
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"fmt"
+	"time"
+)
+
+// Asynchronous jobs are submitted as SQS messages, which Lambda
+// delivers to the function's runtime. The runtime writes each job's
+// result to the object store, under a key named for its batch and
+// index, instead of returning it to a waiting client.
+
+// AsyncJob is the body of the SQS message for one asynchronous job
+type AsyncJob struct {
+	Batch string         `json:"batch"`
+	Index int            `json:"index"`
+	Spec  InvocationSpec `json:"spec"`
+}
+
+// AsyncResult records the outcome of an asynchronous job
+type AsyncResult struct {
+	Response *InvocationResponse `json:"response,omitempty"`
+	// Set if the runtime failed to run the job at all
+	Error string `json:"error,omitempty"`
+}
+
+// AsyncBatch describes a batch of asynchronous jobs, so that a later
+// `llama wait` knows what to wait for.
+type AsyncBatch struct {
+	Function  string    `json:"function"`
+	Jobs      int       `json:"jobs"`
+	Args      []string  `json:"args"`
+	Submitted time.Time `json:"submitted"`
+}
+
+// AsyncPrefix is the key prefix under which batches are stored
+const AsyncPrefix = "async"
+
+func AsyncBatchKey(batch string) string {
+	return fmt.Sprintf("%s/%s/batch", AsyncPrefix, batch)
+}
+
+// AsyncResultPrefix returns the prefix of the keys holding a
+// batch's results
+func AsyncResultPrefix(batch string) string {
+	return fmt.Sprintf("%s/%s/results/", AsyncPrefix, batch)
+}
+
+func AsyncResultKey(batch string, index int) string {
+	return fmt.Sprintf("%s%d", AsyncResultPrefix(batch), index)
+}
@@ -20,16 +20,36 @@ import (
 
 const MaxInlineBlob = 100
 
+// Blobs larger than ChunkSize are split into chunks of ChunkSize
+// bytes, each stored as a separate object, so that they can be
+// uploaded and downloaded in parallel.
+const ChunkSize = 4 << 20
+
 type Blob struct {
 	String string `json:"s,omitempty"`
 	Bytes  []byte `json:"b,omitempty"`
 	Ref    string `json:"r,omitempty"`
-	Err    string `json:"e,omitempty"`
+	// The objects containing a chunked blob, in order
+	Chunks []string `json:"c,omitempty"`
+	Err    string   `json:"e,omitempty"`
+}
+
+// Refs returns the IDs of all the objects that make up the blob
+func (b *Blob) Refs() []string {
+	if b.Ref != "" {
+		return []string{b.Ref}
+	}
+	return b.Chunks
 }
 
 type File struct {
 	Blob
 	Mode os.FileMode `json:"m,omitempty"`
+
+	// The hex SHA-256 of the file's contents. The runtime records
+	// it for each output, and clients check what they fetch
+	// against it.
+	Sum string `json:"h,omitempty"`
 }
 
 type FileAndPath struct {
@@ -38,3 +58,28 @@ type FileAndPath struct {
 }
 
 type FileList []FileAndPath
+
+// A Directory is a node in a Merkle tree describing a directory
+// tree, in the style of Bazel's remote-execution Directory
+// message. Each Directory is stored as a content-addressed object,
+// so identical subtrees share a single object. Entries are sorted by
+// name.
+type Directory struct {
+	// Files in this directory; each Path is a name within the
+	// directory
+	Files FileList  `json:"f,omitempty"`
+	Dirs  []DirNode `json:"d,omitempty"`
+}
+
+type DirNode struct {
+	Name string `json:"n"`
+	// The object ID of the child's Directory
+	Ref string `json:"r"`
+}
+
+// A Tree maps the directory tree rooted at the Directory object Root
+// to Path
+type Tree struct {
+	Path string `json:"p"`
+	Root string `json:"r"`
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "out.o")
+	require.NoError(t, ioutil.WriteFile(p, []byte("old contents"), 0644))
+
+	require.NoError(t, WriteFileAtomic(p, []byte("new"), 0755))
+	data, err := ioutil.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(p)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0755)&^umask, fi.Mode().Perm())
+
+		defer func(old os.FileMode) { umask = old }(umask)
+		umask = 027
+		require.NoError(t, WriteFileAtomic(p, []byte("new"), 0777))
+		fi, err = os.Stat(p)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0750), fi.Mode().Perm(), "the umask applies")
+	}
+
+	// No temporary files are left behind
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "out.o", entries[0].Name())
+
+	// A failure leaves the old file alone
+	assert.Error(t, WriteFileAtomic(filepath.Join(dir, "missing", "out.o"), []byte("x"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0755))
+	assert.Error(t, WriteFileAtomic(filepath.Join(dir, "subdir"), []byte("x"), 0644))
+	entries, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	link := filepath.Join(dir, "link")
+	require.NoError(t, ioutil.WriteFile(target, []byte("target"), 0644))
+	require.NoError(t, os.Symlink(target, link))
+
+	require.NoError(t, WriteFileAtomic(link, []byte("new"), 0644))
+	fi, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.True(t, fi.Mode().IsRegular(), "the link is replaced")
+	data, err := ioutil.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "target", string(data), "not written through")
+}
@@ -16,7 +16,9 @@ package files
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -25,11 +27,12 @@ import (
 
 	"github.com/nelhage/llama/protocol"
 	"github.com/nelhage/llama/store"
+	"golang.org/x/sync/errgroup"
 )
 
 func AppendGet(reqs []store.GetRequest, b *protocol.Blob) []store.GetRequest {
-	if b.Ref != "" {
-		reqs = append(reqs, store.GetRequest{Id: b.Ref})
+	for _, id := range b.Refs() {
+		reqs = append(reqs, store.GetRequest{Id: id})
 	}
 	return reqs
 }
@@ -50,34 +53,108 @@ func ReadBlob(b *protocol.Blob, gets []store.GetRequest) ([]byte, error, []store
 		}
 		return gets[0].Data, gets[0].Err, gets[1:]
 	}
+	if b.Chunks != nil {
+		var data []byte
+		for i, id := range b.Chunks {
+			if gets[i].Id != id {
+				panic(fmt.Sprintf("ReadBlob: bad requests %s != %s", gets[i].Id, id))
+			}
+			if gets[i].Err != nil {
+				return nil, fmt.Errorf("chunk %d: %w", i, gets[i].Err), gets[len(b.Chunks):]
+			}
+			data = append(data, gets[i].Data...)
+		}
+		return data, nil, gets[len(b.Chunks):]
+	}
 	return nil, nil, gets
 }
 
 func Read(ctx context.Context, st store.Store, b *protocol.Blob) ([]byte, error) {
 	gets := AppendGet(nil, b)
+	st.GetObjects(ctx, gets)
 	data, err, _ := ReadBlob(b, gets)
 	return data, err
 }
 
+// Checksum returns the hex SHA-256 of `data`, as recorded in
+// protocol.File.Sum
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// A ChecksumError reports a fetched file whose contents don't match
+// the checksum the runtime recorded for it: the store returned
+// corrupt data, or something overwrote the object after the runtime
+// wrote it.
+type ChecksumError struct {
+	Path string
+	Want string
+	Got  string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: checksum mismatch: expected sha256 %s, fetched %s",
+		e.Path, e.Want, e.Got)
+}
+
+// SkipChecksums clears the checksums of the files in `fl`, so that
+// FetchFile doesn't check them, for clients configured not to verify
+// outputs.
+func SkipChecksums(fl protocol.FileList) {
+	for i := range fl {
+		fl[i].Sum = ""
+	}
+}
+
+// FetchFile writes `f` to `where`, given the results of the requests
+// AppendGet made for it, after checking its contents against its
+// checksum, if it has one. The file gets `f`'s mode less the umask,
+// and replaces any symlink at `where`; see WriteFileAtomic.
 func FetchFile(f *protocol.File, where string, gets []store.GetRequest) (error, []store.GetRequest) {
 	data, err, gets := ReadBlob(&f.Blob, gets)
 	if err != nil {
 		return err, gets
 	}
+	if f.Sum != "" {
+		if got := Checksum(data); got != f.Sum {
+			return &ChecksumError{Path: where, Want: f.Sum, Got: got}, gets
+		}
+	}
 	mode := f.Mode
 	if mode == 0 {
 		mode = 0644
 	}
-	return ioutil.WriteFile(where, data, mode), gets
+	return WriteFileAtomic(where, data, mode), gets
 }
 
-func NewBlob(ctx context.Context, store store.Store, bytes []byte) (*protocol.Blob, error) {
+// inlineBlob returns a blob holding `bytes` inline, or nil if they
+// are too large to inline. Anything up to `limit` bytes is inline, as
+// well as anything too small to be worth storing.
+func inlineBlob(bytes []byte, limit int) *protocol.Blob {
 	stringOk := utf8.Valid(bytes)
-	if stringOk && len(bytes) < protocol.MaxInlineBlob {
-		return &protocol.Blob{String: string(bytes)}, nil
+	if stringOk && (len(bytes) < protocol.MaxInlineBlob || len(bytes) <= limit) {
+		return &protocol.Blob{String: string(bytes)}
+	}
+	if base64.StdEncoding.EncodedLen(len(bytes)) < protocol.MaxInlineBlob || len(bytes) <= limit {
+		return &protocol.Blob{Bytes: bytes}
+	}
+	return nil
+}
+
+func NewBlob(ctx context.Context, store store.Store, bytes []byte) (*protocol.Blob, error) {
+	return NewBlobInline(ctx, store, bytes, 0)
+}
+
+// NewBlobInline is like NewBlob, but also carries `bytes` in the blob
+// itself, rather than storing them, if there are at most `limit` of
+// them.
+func NewBlobInline(ctx context.Context, store store.Store, bytes []byte, limit int) (*protocol.Blob, error) {
+	if blob := inlineBlob(bytes, limit); blob != nil {
+		return blob, nil
 	}
-	if base64.StdEncoding.EncodedLen(len(bytes)) < protocol.MaxInlineBlob {
-		return &protocol.Blob{Bytes: bytes}, nil
+	if len(bytes) > protocol.ChunkSize {
+		return storeChunks(ctx, store, bytes)
 	}
 	id, err := store.Store(ctx, bytes)
 	if err != nil {
@@ -86,30 +163,190 @@ func NewBlob(ctx context.Context, store store.Store, bytes []byte) (*protocol.Bl
 	return &protocol.Blob{Ref: id}, nil
 }
 
+// The maximum number of chunks of a single blob to upload at once
+const chunkConcurrency = 8
+
+// Inline reports whether a blob of `data` is small enough to carry
+// its data itself, rather than refer to the object store.
+func Inline(data []byte) bool {
+	return inlineBlob(data, 0) != nil
+}
+
+// splitChunks splits `bytes` into the chunks we store a large blob
+// as.
+func splitChunks(bytes []byte) [][]byte {
+	var chunks [][]byte
+	for start := 0; start < len(bytes); start += protocol.ChunkSize {
+		end := start + protocol.ChunkSize
+		if end > len(bytes) {
+			end = len(bytes)
+		}
+		chunks = append(chunks, bytes[start:end])
+	}
+	return chunks
+}
+
+func storeChunks(ctx context.Context, st store.Store, bytes []byte) (*protocol.Blob, error) {
+	data := splitChunks(bytes)
+	chunks := make([]string, len(data))
+	grp, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, chunkConcurrency)
+	for i := range chunks {
+		i := i
+		sem <- struct{}{}
+		grp.Go(func() error {
+			defer func() { <-sem }()
+			id, err := st.Store(ctx, data[i])
+			if err != nil {
+				return fmt.Errorf("chunk %d: %w", i, err)
+			}
+			chunks[i] = id
+			return nil
+		})
+	}
+	if err := grp.Wait(); err != nil {
+		return nil, err
+	}
+	return &protocol.Blob{Chunks: chunks}, nil
+}
+
+// storedObjects returns the objects NewBlob would store `data` as, and
+// their IDs, or nothing if it would inline it.
+func storedObjects(insp store.Inspectable, data []byte) ([][]byte, []string, error) {
+	if inlineBlob(data, 0) != nil {
+		return nil, nil, nil
+	}
+	objects := [][]byte{data}
+	if len(data) > protocol.ChunkSize {
+		objects = splitChunks(data)
+	}
+	ids := make([]string, len(objects))
+	for i, obj := range objects {
+		id, err := insp.ObjectID(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids[i] = id
+	}
+	return objects, ids, nil
+}
+
+// PrimeExistence asks a store that can check for many objects at
+// once which of the objects NewBlob would store for each of
+// `contents` it already holds, so that NewBlob doesn't check for them
+// one at a time. It does nothing for other stores.
+func PrimeExistence(ctx context.Context, st store.Store, contents [][]byte) error {
+	checker, ok := st.(store.ExistenceChecker)
+	if !ok {
+		return nil
+	}
+	insp, ok := st.(store.Inspectable)
+	if !ok {
+		return nil
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, data := range contents {
+		_, objIDs, err := storedObjects(insp, data)
+		if err != nil {
+			return err
+		}
+		for _, id := range objIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := checker.HasObjects(ctx, ids)
+	return err
+}
+
+// UploadSizes reports, for each of `contents`, how many bytes NewBlob
+// would upload to `st` to store it: none if it is small enough to be
+// inline, or if the store already holds it. It stores nothing.
+func UploadSizes(ctx context.Context, st store.Store, contents [][]byte) ([]int64, error) {
+	checker, ok := st.(store.ExistenceChecker)
+	insp, ok2 := st.(store.Inspectable)
+	if !ok || !ok2 {
+		return nil, errors.New("the object store can't report which objects it holds")
+	}
+	// Each object's ID and size, and the index of the contents
+	// it holds part of
+	var ids []string
+	var sizes []int64
+	var owner []int
+	for i, data := range contents {
+		objects, objIDs, err := storedObjects(insp, data)
+		if err != nil {
+			return nil, err
+		}
+		for j, id := range objIDs {
+			ids = append(ids, id)
+			sizes = append(sizes, int64(len(objects[j])))
+			owner = append(owner, i)
+		}
+	}
+	out := make([]int64, len(contents))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	has, err := checker.HasObjects(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for k, present := range has {
+		if !present {
+			out[owner[k]] += sizes[k]
+		}
+	}
+	return out, nil
+}
+
 func ReadFile(ctx context.Context, store store.Store, path string) (*protocol.File, error) {
-	fh, err := os.Open(path)
+	file, _, err := readFile(ctx, store, path, 0)
+	return file, err
+}
+
+// ReadOutput reads a file a job produced, like ReadFile, and records
+// its checksum, so that clients can verify it when they fetch it.
+// Outputs of up to `inline` bytes are carried inline.
+func ReadOutput(ctx context.Context, store store.Store, path string, inline int) (*protocol.File, error) {
+	file, bytes, err := readFile(ctx, store, path, inline)
 	if err != nil {
 		return nil, err
 	}
+	file.Sum = Checksum(bytes)
+	return file, nil
+}
+
+func readFile(ctx context.Context, store store.Store, path string, inline int) (*protocol.File, []byte, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer fh.Close()
 	fi, err := fh.Stat()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if fi.Mode().IsDir() {
-		return nil, errors.New("ReadFile: got directory")
+		return nil, nil, errors.New("ReadFile: got directory")
 	}
 	bytes, err := ioutil.ReadAll(fh)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	blob, err := NewBlob(ctx, store, bytes)
+	blob, err := NewBlobInline(ctx, store, bytes, inline)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	return &protocol.File{
 		Blob: *blob,
 		Mode: fi.Mode(),
-	}, nil
+	}, bytes, nil
 }
@@ -0,0 +1,167 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkedBlob(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	cases := []struct {
+		size   int
+		chunks int
+	}{
+		{protocol.ChunkSize, 0},
+		{protocol.ChunkSize + 1, 2},
+		{3*protocol.ChunkSize - 7, 3},
+	}
+	for _, tc := range cases {
+		data := bytes.Repeat([]byte("0123456789abcdef\xff"), tc.size/17+1)[:tc.size]
+		blob, err := NewBlob(ctx, st, data)
+		require.NoError(t, err)
+		assert.Len(t, blob.Chunks, tc.chunks, "size=%d", tc.size)
+		assert.Len(t, blob.Refs(), max(tc.chunks, 1), "size=%d", tc.size)
+
+		got, err := Read(ctx, st, blob)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(data, got), "size=%d: data mismatch", tc.size)
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func TestReadBlobSequence(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	big := bytes.Repeat([]byte{0xfe}, protocol.ChunkSize+100)
+	small := bytes.Repeat([]byte{0xfd}, 1000)
+	var blobs []*protocol.Blob
+	for _, data := range [][]byte{small, big, []byte("inline"), small} {
+		b, err := NewBlob(ctx, st, data)
+		require.NoError(t, err)
+		blobs = append(blobs, b)
+	}
+
+	var gets []store.GetRequest
+	for _, b := range blobs {
+		gets = AppendGet(gets, b)
+	}
+	assert.Len(t, gets, 4)
+	st.GetObjects(ctx, gets)
+	for i, want := range [][]byte{small, big, []byte("inline"), small} {
+		var got []byte
+		var err error
+		got, err, gets = ReadBlob(blobs[i], gets)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(want, got), "blob %d: data mismatch", i)
+	}
+	assert.Empty(t, gets)
+}
+
+func TestFetchFileChecksum(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	dir := t.TempDir()
+
+	data := bytes.Repeat([]byte("object code\n"), 100)
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "out.o"), data, 0644))
+	file, err := ReadOutput(ctx, st, path.Join(dir, "out.o"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, Checksum(data), file.Sum)
+
+	fetch := func(f *protocol.File, where string) error {
+		gets := AppendGet(nil, &f.Blob)
+		st.GetObjects(ctx, gets)
+		err, _ := FetchFile(f, where, gets)
+		return err
+	}
+	require.NoError(t, fetch(file, path.Join(dir, "good.o")))
+	got, err := ioutil.ReadFile(path.Join(dir, "good.o"))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// Point the file at different contents, as if the object had
+	// been overwritten
+	other, err := NewBlob(ctx, st, bytes.Repeat([]byte("corrupt\n"), 100))
+	require.NoError(t, err)
+	bad := *file
+	bad.Blob = *other
+	err = fetch(&bad, path.Join(dir, "bad.o"))
+	var sumErr *ChecksumError
+	require.True(t, errors.As(err, &sumErr))
+	assert.Equal(t, file.Sum, sumErr.Want)
+	_, err = os.Stat(path.Join(dir, "bad.o"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Files without a checksum aren't checked
+	bad.Sum = ""
+	assert.NoError(t, fetch(&bad, path.Join(dir, "bad.o")))
+}
+
+func TestInlineOutput(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	dir := t.TempDir()
+
+	data := bytes.Repeat([]byte("object code\n"), 100)
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "out.o"), data, 0644))
+	file, err := ReadOutput(ctx, st, path.Join(dir, "out.o"), len(data)-1)
+	require.NoError(t, err)
+	assert.NotEmpty(t, file.Ref)
+
+	file, err = ReadOutput(ctx, st, path.Join(dir, "out.o"), len(data))
+	require.NoError(t, err)
+	assert.Empty(t, file.Refs())
+	assert.Equal(t, string(data), file.String)
+	assert.Equal(t, Checksum(data), file.Sum)
+}
+
+func TestUploadSizes(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	big := func(c byte, n int) []byte { return bytes.Repeat([]byte{c, 0xff}, n/2) }
+	stored := big('a', 2*protocol.MaxInlineBlob)
+	missing := big('b', 2*protocol.MaxInlineBlob)
+	chunked := append(big('c', protocol.ChunkSize), big('d', protocol.ChunkSize)...)
+	_, err := NewBlob(ctx, st, stored)
+	require.NoError(t, err)
+	_, err = NewBlob(ctx, st, chunked[:protocol.ChunkSize])
+	require.NoError(t, err)
+
+	sizes, err := UploadSizes(ctx, st, [][]byte{[]byte("small"), stored, missing, chunked})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 0, int64(len(missing)), protocol.ChunkSize}, sizes)
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+)
+
+// A reference file stands in, locally, for a file whose contents are
+// held in the object store. It records the file as the remote side
+// returned it, so uploading a reference file passes that along
+// instead of its own contents.
+const refMagic = "!<llama-ref>\n"
+
+// WriteRef writes a reference file for `f` to `path`
+func WriteRef(path string, f *protocol.File) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(refMagic)
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return WriteFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// ParseRef returns the file that `data` refers to, if it is the
+// contents of a reference file.
+func ParseRef(data []byte) (*protocol.File, bool) {
+	if !bytes.HasPrefix(data, []byte(refMagic)) {
+		return nil, false
+	}
+	var f protocol.File
+	if err := json.Unmarshal(data[len(refMagic):], &f); err != nil {
+		return nil, false
+	}
+	return &f, true
+}
+
+// IsRef returns true if `path` is a reference file
+func IsRef(path string) bool {
+	fh, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer fh.Close()
+	head := make([]byte, len(refMagic))
+	if _, err := io.ReadFull(fh, head); err != nil {
+		return false
+	}
+	return string(head) == refMagic
+}
+
+// Materialize replaces any reference files among `paths` with the
+// contents they refer to, fetched from `st`. It returns the number of
+// files it replaced.
+func Materialize(ctx context.Context, st store.Store, paths []string) (int, error) {
+	var refs []string
+	var fs []*protocol.File
+	var gets []store.GetRequest
+	for _, path := range paths {
+		if !IsRef(path) {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		f, ok := ParseRef(data)
+		if !ok {
+			return 0, fmt.Errorf("%s: malformed reference file", path)
+		}
+		refs = append(refs, path)
+		fs = append(fs, f)
+		gets = AppendGet(gets, &f.Blob)
+	}
+	st.GetObjects(ctx, gets)
+	for i, path := range refs {
+		var err error
+		if err, gets = FetchFile(fs[i], path, gets); err != nil {
+			return i, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return len(refs), nil
+}
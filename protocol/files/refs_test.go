@@ -0,0 +1,67 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	dir, err := ioutil.TempDir("", "llama-refs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := bytes.Repeat([]byte("\x7fELF\x00"), protocol.MaxInlineBlob)
+	blob, err := NewBlob(ctx, st, data)
+	require.NoError(t, err)
+	require.NotEmpty(t, blob.Refs())
+
+	obj := filepath.Join(dir, "hello.o")
+	require.NoError(t, WriteRef(obj, &protocol.File{Blob: *blob, Mode: 0755}))
+	plain := filepath.Join(dir, "hello.c")
+	require.NoError(t, ioutil.WriteFile(plain, []byte("int main() {}\n"), 0644))
+
+	assert.True(t, IsRef(obj))
+	assert.False(t, IsRef(plain))
+	assert.False(t, IsRef(filepath.Join(dir, "missing.o")))
+
+	ref, err := ioutil.ReadFile(obj)
+	require.NoError(t, err)
+	f, ok := ParseRef(ref)
+	require.True(t, ok)
+	assert.Equal(t, blob.Refs(), f.Refs())
+	_, ok = ParseRef([]byte(refMagic + "{garbage"))
+	assert.False(t, ok)
+
+	n, err := Materialize(ctx, st, []string{plain, obj})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	got, err := ioutil.ReadFile(obj)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, got))
+	assert.False(t, IsRef(obj))
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+)
+
+// ValidName reports whether `name` is acceptable as the name of an
+// entry in a protocol.Directory.
+func ValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+// FetchTrees materializes each of `trees` under `root`. It fetches
+// the trees a level at a time, so the number of round-trips to the
+// store is proportional to the depth of the deepest tree.
+func FetchTrees(ctx context.Context, st store.Store, root string, trees []protocol.Tree) error {
+	type pending struct {
+		dir string
+		ref string
+	}
+	var level []pending
+	for _, t := range trees {
+		level = append(level, pending{dir: path.Join(root, t.Path), ref: t.Root})
+	}
+	for len(level) > 0 {
+		gets := make([]store.GetRequest, len(level))
+		for i, p := range level {
+			gets[i].Id = p.ref
+		}
+		st.GetObjects(ctx, gets)
+
+		var next []pending
+		var fetch protocol.FileList
+		var fileGets []store.GetRequest
+		for i, p := range level {
+			if gets[i].Err != nil {
+				return fmt.Errorf("fetching directory %s: %w", p.dir, gets[i].Err)
+			}
+			var dir protocol.Directory
+			if err := json.Unmarshal(gets[i].Data, &dir); err != nil {
+				return fmt.Errorf("directory %s: %w", p.dir, err)
+			}
+			if err := os.MkdirAll(p.dir, 0755); err != nil {
+				return err
+			}
+			for _, f := range dir.Files {
+				if !ValidName(f.Path) {
+					return fmt.Errorf("directory %s: bad file name %q", p.dir, f.Path)
+				}
+				f.Path = path.Join(p.dir, f.Path)
+				fetch = append(fetch, f)
+				fileGets = AppendGet(fileGets, &f.Blob)
+			}
+			for _, d := range dir.Dirs {
+				if !ValidName(d.Name) {
+					return fmt.Errorf("directory %s: bad subdirectory name %q", p.dir, d.Name)
+				}
+				next = append(next, pending{dir: path.Join(p.dir, d.Name), ref: d.Ref})
+			}
+		}
+
+		st.GetObjects(ctx, fileGets)
+		for _, f := range fetch {
+			var err error
+			err, fileGets = FetchFile(&f.File, f.Path, fileGets)
+			if err != nil {
+				return err
+			}
+		}
+		level = next
+	}
+	return nil
+}
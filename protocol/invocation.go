@@ -25,7 +25,30 @@ type InvocationSpec struct {
 	Args    []string             `json:"args"`
 	Stdin   *Blob                `json:"stdin,omitempty"`
 	Files   FileList             `json:"files,omitempty"`
+	Trees   []Tree               `json:"trees,omitempty"`
 	Outputs []string             `json:"outputs,emitempty"`
+	Stream  string               `json:"stream,omitempty"`
+
+	// Additional environment variables for the command, as
+	// KEY=VALUE
+	Env []string `json:"env,omitempty"`
+
+	// If non-zero, the runtime kills the command if it runs for
+	// longer than this, and reports exit status 124, like
+	// timeout(1).
+	TimeLimit time.Duration `json:"time_limit,omitempty"`
+
+	// Identifies this invocation in logs, across the daemon and
+	// the runtime
+	InvocationID string `json:"invocation_id,omitempty"`
+
+	// Outputs of up to this many bytes are returned inline in the
+	// response, rather than through the object store
+	InlineOutputs int `json:"inline_outputs,omitempty"`
+
+	// If set, the runtime also returns, as outputs, every file
+	// under the job's root that the command created or modified
+	OutputChanges bool `json:"output_changes,omitempty"`
 }
 
 type InvocationResponse struct {
@@ -37,6 +60,61 @@ type InvocationResponse struct {
 	Spans       *Blob          `json:"spans,omitempty"`
 	Usage       UsageMetrics   `json:"usage"`
 	Times       Timing         `json:"times"`
+
+	// If set, the response was too large to return from Lambda,
+	// and this blob holds it, JSON-encoded, instead; every other
+	// field is empty.
+	Spilled *Blob `json:"spilled,omitempty"`
+
+	// The command's peak resident set size, in bytes, if the
+	// platform reports it
+	MaxRSS uint64 `json:"max_rss,omitempty"`
+	// Set if the command was killed by SIGKILL other than for
+	// exceeding its time limit, which in a function almost always
+	// means it ran out of memory
+	Killed bool `json:"killed,omitempty"`
+}
+
+// Refs returns the IDs of the objects holding the response's output
+// and output files, which a result-cache entry of it refers to.
+func (r *InvocationResponse) Refs() []string {
+	var refs []string
+	for _, b := range []*Blob{r.Stdout, r.Stderr} {
+		if b != nil {
+			refs = append(refs, b.Refs()...)
+		}
+	}
+	for _, out := range r.Outputs {
+		refs = append(refs, out.Refs()...)
+	}
+	return refs
+}
+
+// MaxResponseBytes is the largest response the runtime returns
+// directly. Lambda limits synchronous responses to 6MB; we leave some
+// margin.
+const MaxResponseBytes = 5 << 20
+
+// FunctionErrorHeader marks a response the runtime sends through a
+// Function URL which carries the error the function failed with,
+// like the Lambda API's X-Amz-Function-Error.
+const FunctionErrorHeader = "X-Llama-Function-Error"
+
+// MaxRequestBytes is the largest request we send a function, which
+// Lambda also limits to 6MB.
+const MaxRequestBytes = 5 << 20
+
+// InlineBytes returns how many bytes of file contents the spec
+// carries inline, rather than by reference to the object store
+func (s *InvocationSpec) InlineBytes() int {
+	n := 0
+	if s.Stdin != nil {
+		n += len(s.Stdin.String) + len(s.Stdin.Bytes)
+	}
+	for _, f := range s.Files {
+		n += len(f.String) + len(f.Bytes)
+	}
+	return n
 }
 
 type UsageMetrics struct {
@@ -55,4 +133,7 @@ type Timing struct {
 	Fetch     time.Duration `json:"fetch"`
 	Upload    time.Duration `json:"upload"`
 	Exec      time.Duration `json:"exec"`
+	// On a cold start, how long the runtime spent starting up
+	// before it could begin the job
+	Init time.Duration `json:"init,omitempty"`
 }
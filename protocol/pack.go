@@ -0,0 +1,45 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+// A pack runs several small invocations in a single call to the
+// function, to spread the overhead of a Lambda request, and of any
+// cold start, across all of them. The payload of a packed invocation
+// is a PackPayload, and the runtime replies with a PackResponse
+// holding a result for each job, in order.
+
+type Pack struct {
+	Jobs []InvocationSpec `json:"jobs"`
+	// How many jobs the runtime runs at once; zero or one runs
+	// them one after another
+	Parallelism int `json:"parallelism,omitempty"`
+}
+
+// PackPayload is the payload of a packed invocation. Its only key,
+// "pack", distinguishes it from an InvocationSpec.
+type PackPayload struct {
+	Pack *Pack `json:"pack"`
+}
+
+// PackResult records the outcome of one job in a pack
+type PackResult struct {
+	Response *InvocationResponse `json:"response,omitempty"`
+	// Set if the runtime failed to run the job at all
+	Error string `json:"error,omitempty"`
+}
+
+type PackResponse struct {
+	Results []PackResult `json:"results"`
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import "fmt"
+
+// A StreamChunk holds output produced by a running command since the
+// previous chunk. If an invocation requests streaming, the runtime
+// periodically publishes chunks to the store under StreamChunkKey,
+// numbered sequentially from 0, and deletes them once the command
+// exits and its response carries the whole output.
+type StreamChunk struct {
+	Stdout []byte `json:"stdout,omitempty"`
+	Stderr []byte `json:"stderr,omitempty"`
+}
+
+func StreamChunkKey(stream string, seq int) string {
+	return fmt.Sprintf("streams/%s/%d", stream, seq)
+}
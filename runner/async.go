@@ -0,0 +1,79 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+)
+
+func isSQSEvent(event *events.SQSEvent) bool {
+	return len(event.Records) > 0 && event.Records[0].EventSource == "aws:sqs"
+}
+
+// SQSBatchResponse is how a function consuming an SQS queue with
+// ReportBatchItemFailures tells Lambda which messages of a batch
+// failed, so that SQS delivers only those again, rather than the
+// whole batch.
+type SQSBatchResponse struct {
+	BatchItemFailures []SQSBatchItemFailure `json:"batchItemFailures"`
+}
+
+type SQSBatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// RunAsync runs each job in `event`, and writes its result to the
+// store. A job which fails is recorded as failed, rather than
+// reported as a failure, so that SQS doesn't deliver it again; we
+// only report the messages whose results we couldn't record.
+func (r *Runner) RunAsync(ctx context.Context, event *events.SQSEvent) (*SQSBatchResponse, error) {
+	kv, ok := r.store.(store.KeyValue)
+	if !ok {
+		return nil, errors.New("asynchronous jobs need a store which supports keys")
+	}
+	out := &SQSBatchResponse{BatchItemFailures: []SQSBatchItemFailure{}}
+	for i := range event.Records {
+		msg := &event.Records[i]
+		var job protocol.AsyncJob
+		if err := json.Unmarshal([]byte(msg.Body), &job); err != nil {
+			// Redelivering a malformed message won't help
+			logging.Printf(ctx, "async: message %s: %s", msg.MessageId, err.Error())
+			continue
+		}
+		var result protocol.AsyncResult
+		resp, err := r.RunOne(ctx, &job.Spec)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Response = resp
+		}
+		data, err := json.Marshal(&result)
+		if err == nil {
+			err = kv.SetKey(ctx, protocol.AsyncResultKey(job.Batch, job.Index), data)
+		}
+		if err != nil {
+			logging.Printf(ctx, "async: recording result of %s/%d: %s", job.Batch, job.Index, err.Error())
+			out.BatchItemFailures = append(out.BatchItemFailures, SQSBatchItemFailure{ItemIdentifier: msg.MessageId})
+		}
+	}
+	return out, nil
+}
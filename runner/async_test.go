@@ -0,0 +1,135 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sqsEvent(t *testing.T, jobs ...protocol.AsyncJob) json.RawMessage {
+	var event events.SQSEvent
+	for i, job := range jobs {
+		body, err := json.Marshal(&job)
+		require.NoError(t, err)
+		event.Records = append(event.Records, events.SQSMessage{
+			MessageId:   fmt.Sprintf("m%d", i),
+			Body:        string(body),
+			EventSource: "aws:sqs",
+		})
+	}
+	payload, err := json.Marshal(&event)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestHandleAsync(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	r := Runner{store: st, cmdline: []string{"/bin/sh", "-c"}}
+
+	payload := sqsEvent(t,
+		protocol.AsyncJob{Batch: "b1", Index: 0, Spec: protocol.InvocationSpec{Args: []string{"echo zero"}}},
+		protocol.AsyncJob{Batch: "b1", Index: 1, Spec: protocol.InvocationSpec{Args: []string{"exit 3"}}},
+	)
+	out, err := r.Handle(ctx, payload)
+	require.NoError(t, err)
+	assert.Empty(t, out.(*SQSBatchResponse).BatchItemFailures)
+
+	kv := st.(store.KeyValue)
+	read := func(idx int) protocol.AsyncResult {
+		data, err := kv.GetKey(ctx, protocol.AsyncResultKey("b1", idx))
+		require.NoError(t, err)
+		var res protocol.AsyncResult
+		require.NoError(t, json.Unmarshal(data, &res))
+		return res
+	}
+
+	zero := read(0)
+	require.NotNil(t, zero.Response)
+	assert.Equal(t, 0, zero.Response.ExitStatus)
+	stdout, err := files.Read(ctx, st, zero.Response.Stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "zero\n", string(stdout))
+
+	one := read(1)
+	require.NotNil(t, one.Response)
+	assert.Equal(t, 3, one.Response.ExitStatus)
+}
+
+// failingKeys fails to set the keys in `fail`
+type failingKeys struct {
+	inner store.Store
+	fail  map[string]bool
+}
+
+func (f *failingKeys) Store(ctx context.Context, obj []byte) (string, error) {
+	return f.inner.Store(ctx, obj)
+}
+
+func (f *failingKeys) GetObjects(ctx context.Context, gets []store.GetRequest) {
+	f.inner.GetObjects(ctx, gets)
+}
+
+func (f *failingKeys) FetchAWSUsage(u *protocol.UsageMetrics) {}
+
+func (f *failingKeys) GetKey(ctx context.Context, key string) ([]byte, error) {
+	return f.inner.(store.KeyValue).GetKey(ctx, key)
+}
+
+func (f *failingKeys) SetKey(ctx context.Context, key string, value []byte) error {
+	if f.fail[key] {
+		return errors.New("store unavailable")
+	}
+	return f.inner.(store.KeyValue).SetKey(ctx, key, value)
+}
+
+func TestHandleAsyncPartialFailure(t *testing.T) {
+	ctx := context.Background()
+	st := &failingKeys{
+		inner: store.InMemory(),
+		fail:  map[string]bool{protocol.AsyncResultKey("b1", 1): true},
+	}
+	r := Runner{store: st, cmdline: []string{"/bin/sh", "-c"}}
+
+	payload := sqsEvent(t,
+		protocol.AsyncJob{Batch: "b1", Index: 0, Spec: protocol.InvocationSpec{Args: []string{"true"}}},
+		protocol.AsyncJob{Batch: "b1", Index: 1, Spec: protocol.InvocationSpec{Args: []string{"true"}}},
+		protocol.AsyncJob{Batch: "b1", Index: 2, Spec: protocol.InvocationSpec{Args: []string{"true"}}},
+	)
+	out, err := r.Handle(ctx, payload)
+	require.NoError(t, err)
+	// Only the message whose result we lost is delivered again
+	assert.Equal(t, &SQSBatchResponse{
+		BatchItemFailures: []SQSBatchItemFailure{{ItemIdentifier: "m1"}},
+	}, out)
+
+	_, err = st.GetKey(ctx, protocol.AsyncResultKey("b1", 2))
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(out)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"batchItemFailures":[{"itemIdentifier":"m1"}]}`, string(data))
+}
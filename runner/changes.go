@@ -0,0 +1,72 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// fileState is what we compare to tell whether a command changed a
+// file
+type fileState struct {
+	size  int64
+	mtime time.Time
+	mode  os.FileMode
+}
+
+// snapshotTree records the state of every regular file under `root`,
+// by its slash-separated path relative to `root`.
+func snapshotTree(root string) (map[string]fileState, error) {
+	out := make(map[string]fileState)
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		out[filepath.ToSlash(rel)] = fileState{size: fi.Size(), mtime: fi.ModTime(), mode: fi.Mode()}
+		return nil
+	})
+	return out, err
+}
+
+// changedFiles returns the files in `after` which are new or differ
+// from `before`, skipping those in `skip`.
+func changedFiles(before, after map[string]fileState, skip []string) []string {
+	skipped := make(map[string]bool, len(skip))
+	for _, p := range skip {
+		skipped[p] = true
+	}
+	var out []string
+	for p, st := range after {
+		if skipped[p] {
+			continue
+		}
+		if old, ok := before[p]; ok && old.size == st.size && old.mtime.Equal(st.mtime) && old.mode == st.mode {
+			continue
+		}
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
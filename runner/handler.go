@@ -0,0 +1,145 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/nelhage/llama/logging"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+)
+
+// Handle is the Lambda handler for the runtime. It runs a single
+// InvocationSpec and returns its response, spilling it to the store
+// if it is too large for Lambda to return. If the payload is an SQS
+// event, it runs the asynchronous jobs it carries with RunAsync, and
+// if it is a PackPayload, the jobs it packs with RunPack. Requests
+// through a Function URL are handled by HandleHTTP.
+func (r *Runner) Handle(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	var req events.APIGatewayV2HTTPRequest
+	if err := json.Unmarshal(payload, &req); err == nil && isHTTPRequest(&req) {
+		return r.HandleHTTP(ctx, &req), nil
+	}
+	var event events.SQSEvent
+	if err := json.Unmarshal(payload, &event); err == nil && isSQSEvent(&event) {
+		return r.RunAsync(ctx, &event)
+	}
+	var packed protocol.PackPayload
+	if err := json.Unmarshal(payload, &packed); err == nil && packed.Pack != nil {
+		return r.RunPack(ctx, packed.Pack), nil
+	}
+	var spec protocol.InvocationSpec
+	if err := json.Unmarshal(payload, &spec); err != nil {
+		return nil, err
+	}
+	resp, err := r.RunOne(ctx, &spec)
+	if err != nil {
+		return nil, functionError(err)
+	}
+	resp, err = r.spill(ctx, resp, protocol.MaxResponseBytes)
+	if err != nil {
+		return nil, functionError(err)
+	}
+	return resp, nil
+}
+
+// functionError returns the error to report to Lambda for `err`.
+// Errors from AWS, such as S3 throttling us while we fetch files, are
+// reported with their error code as their type, so that clients can
+// tell which failures are worth retrying.
+func functionError(err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return messages.InvokeResponse_Error{Message: err.Error(), Type: awsErr.Code()}
+	}
+	return err
+}
+
+// HandleHTTP handles a payload POSTed to the function's Function
+// URL. Lambda reports errors returned by a URL-invoked function only
+// as a bare 502, so we instead return them as the body of a 500
+// marked with protocol.FunctionErrorHeader.
+func (r *Runner) HandleHTTP(ctx context.Context, req *events.APIGatewayV2HTTPRequest) *events.APIGatewayV2HTTPResponse {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(req.Body); err != nil {
+			return httpError(fmt.Errorf("decoding body: %w", err))
+		}
+	}
+	if req.RequestContext.HTTP.Method != http.MethodPost {
+		return httpError(fmt.Errorf("unsupported method: %s", req.RequestContext.HTTP.Method))
+	}
+	out, err := r.Handle(ctx, body)
+	if err != nil {
+		return httpError(err)
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return httpError(err)
+	}
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(data),
+	}
+}
+
+func httpError(err error) *events.APIGatewayV2HTTPResponse {
+	body := messages.InvokeResponse_Error{Message: err.Error()}
+	if ive, ok := err.(messages.InvokeResponse_Error); ok {
+		body = ive
+	}
+	data, _ := json.Marshal(&body)
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusInternalServerError,
+		Headers: map[string]string{
+			"Content-Type":               "application/json",
+			protocol.FunctionErrorHeader: "Unhandled",
+		},
+		Body: string(data),
+	}
+}
+
+func isHTTPRequest(req *events.APIGatewayV2HTTPRequest) bool {
+	return req.Version == "2.0" && req.RequestContext.HTTP.Method != ""
+}
+
+// spill returns `resp`, or, if its encoding is larger than `limit`,
+// stores it and returns a response referring to it.
+func (r *Runner) spill(ctx context.Context, resp *protocol.InvocationResponse, limit int) (*protocol.InvocationResponse, error) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= limit {
+		return resp, nil
+	}
+	logging.Printf(ctx, "spilling %d-byte response to the store", len(data))
+	blob, err := files.NewBlob(ctx, r.store, data)
+	if err != nil {
+		return nil, fmt.Errorf("spilling response: %w", err)
+	}
+	return &protocol.InvocationResponse{Spilled: blob}, nil
+}
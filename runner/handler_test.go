@@ -0,0 +1,182 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSync(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	r := Runner{store: st, cmdline: []string{"/bin/sh", "-c"}}
+
+	payload, err := json.Marshal(&protocol.InvocationSpec{Args: []string{"exit 2"}})
+	require.NoError(t, err)
+	out, err := r.Handle(ctx, payload)
+	require.NoError(t, err)
+	resp, ok := out.(*protocol.InvocationResponse)
+	require.True(t, ok)
+	assert.Equal(t, 2, resp.ExitStatus)
+}
+
+func TestHandlePack(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	r := Runner{store: st}
+
+	payload, err := json.Marshal(&protocol.PackPayload{Pack: &protocol.Pack{
+		Jobs: []protocol.InvocationSpec{
+			{Args: []string{"/bin/sh", "-c", "exit 3"}},
+			{},
+			{Args: []string{"/bin/sh", "-c", "echo hi"}},
+		},
+		Parallelism: 2,
+	}})
+	require.NoError(t, err)
+	out, err := r.Handle(ctx, payload)
+	require.NoError(t, err)
+	resp, ok := out.(*protocol.PackResponse)
+	require.True(t, ok)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, 3, resp.Results[0].Response.ExitStatus)
+
+	// A job the runner can't start fails without failing the pack
+	assert.Nil(t, resp.Results[1].Response)
+	assert.NotEmpty(t, resp.Results[1].Error)
+
+	stdout, err := files.Read(ctx, st, resp.Results[2].Response.Stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "hi\n", string(stdout))
+}
+
+func TestHandleHTTP(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	r := Runner{store: st, cmdline: []string{"/bin/sh", "-c"}}
+
+	spec, err := json.Marshal(&protocol.InvocationSpec{Args: []string{"exit 2"}})
+	require.NoError(t, err)
+	req := events.APIGatewayV2HTTPRequest{
+		Version:         "2.0",
+		Body:            base64.StdEncoding.EncodeToString(spec),
+		IsBase64Encoded: true,
+	}
+	req.RequestContext.HTTP.Method = "POST"
+	payload, err := json.Marshal(&req)
+	require.NoError(t, err)
+
+	out, err := r.Handle(ctx, payload)
+	require.NoError(t, err)
+	httpResp, ok := out.(*events.APIGatewayV2HTTPResponse)
+	require.True(t, ok)
+	assert.Equal(t, 200, httpResp.StatusCode)
+	var resp protocol.InvocationResponse
+	require.NoError(t, json.Unmarshal([]byte(httpResp.Body), &resp))
+	assert.Equal(t, 2, resp.ExitStatus)
+
+	req.Body = "{not json"
+	req.IsBase64Encoded = false
+	httpResp = r.HandleHTTP(ctx, &req)
+	assert.Equal(t, 500, httpResp.StatusCode)
+	assert.NotEmpty(t, httpResp.Headers[protocol.FunctionErrorHeader])
+}
+
+func TestSpill(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	r := Runner{store: st}
+
+	resp := &protocol.InvocationResponse{ExitStatus: 1}
+	for i := 0; i < 100; i++ {
+		resp.Outputs = append(resp.Outputs, protocol.FileAndPath{
+			Path: strings.Repeat("d/", 20) + "out.o",
+			File: protocol.File{Blob: protocol.Blob{Ref: strings.Repeat("a", 64)}},
+		})
+	}
+	same, err := r.spill(ctx, resp, protocol.MaxResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, resp, same)
+
+	spilled, err := r.spill(ctx, resp, 1024)
+	require.NoError(t, err)
+	require.NotNil(t, spilled.Spilled)
+	assert.Nil(t, spilled.Outputs)
+
+	data, err := files.Read(ctx, st, spilled.Spilled)
+	require.NoError(t, err)
+	var full protocol.InvocationResponse
+	require.NoError(t, json.Unmarshal(data, &full))
+	assert.Equal(t, resp, &full)
+}
+
+// throttledStore fails every read as S3 does when throttling us
+type throttledStore struct {
+	inner store.Store
+}
+
+func (s *throttledStore) Store(ctx context.Context, obj []byte) (string, error) {
+	return s.inner.Store(ctx, obj)
+}
+
+func (s *throttledStore) GetObjects(ctx context.Context, gets []store.GetRequest) {
+	for i := range gets {
+		gets[i].Err = awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate", nil), 503, "id")
+	}
+}
+
+func (s *throttledStore) FetchAWSUsage(u *protocol.UsageMetrics) {}
+
+func TestHandleAWSError(t *testing.T) {
+	ctx := context.Background()
+	st := &throttledStore{inner: store.InMemory()}
+	r := Runner{store: st, cmdline: []string{"/bin/sh", "-c"}}
+
+	blob, err := files.NewBlob(ctx, st, []byte(strings.Repeat("x", 64<<10)))
+	require.NoError(t, err)
+	spec, err := json.Marshal(&protocol.InvocationSpec{
+		Args:  []string{"cat in.txt"},
+		Files: protocol.FileList{{Path: "in.txt", File: protocol.File{Blob: *blob}}},
+	})
+	require.NoError(t, err)
+
+	_, err = r.Handle(ctx, spec)
+	require.Error(t, err)
+	ive, ok := err.(messages.InvokeResponse_Error)
+	require.True(t, ok, "%#v", err)
+	assert.Equal(t, "SlowDown", ive.Type)
+
+	resp := httpError(err)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal([]byte(resp.Body), &body))
+	assert.Equal(t, "SlowDown", body["errorType"])
+
+	plain := errors.New("bad spec")
+	assert.Equal(t, plain, functionError(plain))
+}
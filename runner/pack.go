@@ -0,0 +1,63 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nelhage/llama/protocol"
+)
+
+// RunPack runs each job in `pack`, up to pack.Parallelism at once,
+// and returns their results in order. A job which fails is recorded
+// as failed, rather than failing the pack, so that the other jobs'
+// results still reach the client. Each response is spilled if it
+// would take more than its share of what Lambda can return.
+func (r *Runner) RunPack(ctx context.Context, pack *protocol.Pack) *protocol.PackResponse {
+	results := make([]protocol.PackResult, len(pack.Jobs))
+	if len(pack.Jobs) == 0 {
+		return &protocol.PackResponse{Results: results}
+	}
+	parallelism := pack.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	limit := protocol.MaxResponseBytes / len(pack.Jobs)
+
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range pack.Jobs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resp, err := r.RunOne(ctx, &pack.Jobs[i])
+			if err == nil {
+				resp, err = r.spill(ctx, resp, limit)
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].Response = resp
+			}
+		}(i)
+	}
+	wg.Wait()
+	return &protocol.PackResponse{Results: results}
+}
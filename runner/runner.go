@@ -12,9 +12,11 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build llama.runtime
-
-package main
+// Package runner executes llama invocations: it fetches a job's
+// files from the store into a scratch directory, runs the command, and
+// uploads its outputs. It is the core of the Lambda runtime, and is
+// also used to run functions locally.
+package runner
 
 import (
 	"bytes"
@@ -23,26 +25,57 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
 	"path"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/golang/snappy"
+	"github.com/nelhage/llama/logging"
 	"github.com/nelhage/llama/protocol"
 	"github.com/nelhage/llama/protocol/files"
 	"github.com/nelhage/llama/store"
 	"github.com/nelhage/llama/tracing"
 )
 
-type Runtime struct {
-	store    store.Store
-	cmdline  []string
-	jobCount int
-	workerId string
+// processStart approximates when the runtime started, so a cold
+// start can report how long initialization took
+var processStart = time.Now()
+
+type Runner struct {
+	store     store.Store
+	cmdline   []string
+	jobCount  int64
+	workerId  string
+	exporters []Exporter
+}
+
+// An Exporter sends the spans of each traced job to a tracing
+// backend directly, in addition to the runner returning them to the
+// client. Export is called once per job, before the response is
+// returned, since Lambda may freeze us afterwards.
+type Exporter interface {
+	Export(spans []tracing.Span) error
+}
+
+// New returns a Runner which runs `cmdline`, with each job's
+// arguments appended.
+func New(st store.Store, cmdline []string, workerId string) *Runner {
+	return &Runner{
+		store:    st,
+		cmdline:  cmdline,
+		workerId: workerId,
+	}
+}
+
+// AddExporter makes the runner export the spans of traced jobs to
+// `e` as well.
+func (r *Runner) AddExporter(e Exporter) {
+	r.exporters = append(r.exporters, e)
 }
 
 type ParsedJob struct {
@@ -65,14 +98,23 @@ func (p *ParsedJob) TempPath(name string) (string, error) {
 
 const MaxInlineSpans = 100
 
-func (r *Runtime) RunOne(ctx context.Context, job *protocol.InvocationSpec) (*protocol.InvocationResponse, error) {
+// The exit status of commands killed for exceeding their time limit,
+// the same as timeout(1)'s
+const timeLimitExitStatus = 124
+
+func (r *Runner) RunOne(ctx context.Context, job *protocol.InvocationSpec) (*protocol.InvocationResponse, error) {
 	start := time.Now()
 
 	var tracer *tracing.MemoryTracer
 	var resp *protocol.InvocationResponse
 	var err error
 
-	r.jobCount += 1
+	jobCount := atomic.AddInt64(&r.jobCount, 1)
+
+	ctx = logging.WithFields(ctx, "invocation_id", job.InvocationID, "worker_id", r.workerId)
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		ctx = logging.WithFields(ctx, "aws_request_id", lc.AwsRequestID)
+	}
 
 	defer func() {
 		if resp == nil {
@@ -94,14 +136,22 @@ func (r *Runtime) RunOne(ctx context.Context, job *protocol.InvocationSpec) (*pr
 			job.Trace.TraceId,
 			job.Trace.ParentId,
 		)
-		span.AddField("job_count", r.jobCount)
+		span.AddField("job_count", jobCount)
 		span.AddField("worker_id", r.workerId)
 		defer func() {
+			if err != nil {
+				span.AddField("error", err.Error())
+			}
 			span.End()
+			spans := tracer.Close()
+			for _, e := range r.exporters {
+				if err := e.Export(spans); err != nil {
+					logging.Printf(topctx, "exporting spans: %s", err.Error())
+				}
+			}
 			if resp == nil {
 				return
 			}
-			spans := tracer.Close()
 			if len(spans) < MaxInlineSpans {
 				resp.InlineSpans = spans
 			} else {
@@ -127,13 +177,34 @@ func (r *Runtime) RunOne(ctx context.Context, job *protocol.InvocationSpec) (*pr
 	}
 
 	resp, err = r.executeJob(ctx, job)
+	if resp != nil {
+		resp.Times.ColdStart = jobCount == 1
+		if resp.Times.ColdStart {
+			resp.Times.Init = start.Sub(processStart)
+		}
+	}
+	if err != nil {
+		logging.Record(ctx, "job failed", "error", err)
+	} else {
+		logging.Record(ctx, "job",
+			"exit_status", resp.ExitStatus,
+			"cold_start", resp.Times.ColdStart,
+			"init", resp.Times.Init,
+			"e2e", resp.Times.E2E,
+			"fetch", resp.Times.Fetch,
+			"exec", resp.Times.Exec,
+			"upload", resp.Times.Upload,
+		)
+	}
 
 	return resp, err
 }
 
-func (r *Runtime) executeJob(ctx context.Context, job *protocol.InvocationSpec) (*protocol.InvocationResponse, error) {
+func (r *Runner) executeJob(ctx context.Context, job *protocol.InvocationSpec) (*protocol.InvocationResponse, error) {
 	t_start := time.Now()
-	parsed, err := r.parseJob(ctx, job)
+	fetchCtx, span := tracing.StartSpan(ctx, "fetch")
+	parsed, err := r.parseJob(fetchCtx, job)
+	span.End()
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +218,13 @@ func (r *Runtime) executeJob(ctx context.Context, job *protocol.InvocationSpec)
 		return nil, errors.New("No arguments provided")
 	}
 
+	var before map[string]fileState
+	if job.OutputChanges {
+		if before, err = snapshotTree(parsed.Root); err != nil {
+			return nil, err
+		}
+	}
+
 	exe := parsed.Args[0]
 	if strings.ContainsRune(exe, '/') {
 		// Use as-is. Will be interpreted relative to the root
@@ -166,20 +244,44 @@ func (r *Runtime) executeJob(ctx context.Context, job *protocol.InvocationSpec)
 	if parsed.Stdin != nil {
 		cmd.Stdin = bytes.NewReader(parsed.Stdin)
 	}
-	var stdout, stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	cmd.Stdout = &stdout
+	var output outputCapture
+	cmd.Stderr = output.Stderr()
+	cmd.Stdout = output.Stdout()
 
-	log.Printf("starting command: %v\n", cmd.Args)
+	logging.Printf(ctx, "starting command: %v", cmd.Args)
 
 	t_exec := time.Now()
 
+	var timedOut int32
 	{
 		_, span := tracing.StartSpan(ctx, "exec")
+		if len(job.Env) > 0 {
+			cmd.Env = append(os.Environ(), job.Env...)
+		}
+		if span.WillSubmit() {
+			// Let instrumented commands continue the trace
+			if cmd.Env == nil {
+				cmd.Env = os.Environ()
+			}
+			cmd.Env = append(cmd.Env,
+				tracing.TraceparentEnv+"="+span.Propagation().Traceparent())
+		}
 		if err := cmd.Start(); err != nil {
 			return nil, fmt.Errorf("starting command: %q", err)
 		}
+		output.Start(ctx, r.store, job.Stream)
+		if job.TimeLimit > 0 {
+			timer := time.AfterFunc(job.TimeLimit, func() {
+				atomic.StoreInt32(&timedOut, 1)
+				cmd.Process.Kill()
+			})
+			defer timer.Stop()
+		}
 		cmd.Wait()
+		if atomic.LoadInt32(&timedOut) != 0 {
+			fmt.Fprintf(output.Stderr(), "llama: killed after time limit of %s\n", job.TimeLimit)
+		}
+		output.Stop(ctx)
 		span.End()
 	}
 	t_wait := time.Now()
@@ -187,19 +289,33 @@ func (r *Runtime) executeJob(ctx context.Context, job *protocol.InvocationSpec)
 	resp := protocol.InvocationResponse{
 		ExitStatus: cmd.ProcessState.ExitCode(),
 	}
+	if atomic.LoadInt32(&timedOut) != 0 {
+		resp.ExitStatus = timeLimitExitStatus
+	} else {
+		resp.Killed = killed(cmd.ProcessState)
+	}
+	resp.MaxRSS = maxRSS(cmd.ProcessState)
 
 	{
 		ctx, span := tracing.StartSpan(ctx, "upload")
-		resp.Stdout, err = files.NewBlob(ctx, r.store, stdout.Bytes())
+		resp.Stdout, err = files.NewBlob(ctx, r.store, output.stdout.Bytes())
 		if err != nil {
 			resp.Stdout = &protocol.Blob{Err: err.Error()}
 		}
-		resp.Stderr, err = files.NewBlob(ctx, r.store, stderr.Bytes())
+		resp.Stderr, err = files.NewBlob(ctx, r.store, output.stderr.Bytes())
 		if err != nil {
 			resp.Stderr = &protocol.Blob{Err: err.Error()}
 		}
-		for _, out := range job.Outputs {
-			file, err := files.ReadFile(ctx, r.store, path.Join(parsed.Root, out))
+		outputs := job.Outputs
+		if before != nil {
+			after, err := snapshotTree(parsed.Root)
+			if err != nil {
+				return nil, fmt.Errorf("finding changed files: %w", err)
+			}
+			outputs = append(outputs[:len(outputs):len(outputs)], changedFiles(before, after, job.Outputs)...)
+		}
+		for _, out := range outputs {
+			file, err := files.ReadOutput(ctx, r.store, path.Join(parsed.Root, out), job.InlineOutputs)
 			if err != nil {
 				if os.IsNotExist(err) {
 					continue
@@ -216,7 +332,6 @@ func (r *Runtime) executeJob(ctx context.Context, job *protocol.InvocationSpec)
 	}
 	t_done := time.Now()
 
-	resp.Times.ColdStart = r.jobCount == 1
 	resp.Times.Fetch = t_exec.Sub(t_start)
 	resp.Times.Exec = t_wait.Sub(t_exec)
 	resp.Times.Upload = t_done.Sub(t_wait)
@@ -225,7 +340,7 @@ func (r *Runtime) executeJob(ctx context.Context, job *protocol.InvocationSpec)
 	return &resp, nil
 }
 
-func (r *Runtime) parseJob(ctx context.Context, spec *protocol.InvocationSpec) (*ParsedJob, error) {
+func (r *Runner) parseJob(ctx context.Context, spec *protocol.InvocationSpec) (*ParsedJob, error) {
 
 	var err error
 	temp, err := ioutil.TempDir("", "llama.*")
@@ -270,6 +385,10 @@ func (r *Runtime) parseJob(ctx context.Context, spec *protocol.InvocationSpec) (
 		}
 	}
 
+	if err := files.FetchTrees(ctx, r.store, job.Root, spec.Trees); err != nil {
+		return nil, err
+	}
+
 	for _, f := range spec.Outputs {
 		if err := os.MkdirAll(path.Join(job.Root, path.Dir(f)), 0755); err != nil {
 			return nil, fmt.Errorf("creating output directory for %q: %s", f, err)
@@ -0,0 +1,273 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/protocol/files"
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJob(t *testing.T) {
+	const (
+		contentsA = "Hello, A\n"
+		contentsB = "This is B\n"
+	)
+
+	ctx := context.Background()
+	st := store.InMemory()
+	a_txt, _ := files.NewBlob(ctx, st, []byte(contentsA))
+	b_txt, _ := files.NewBlob(ctx, st, []byte(contentsB))
+
+	cmdline := []string{"/bin/echo", "Hello"}
+	spec := protocol.InvocationSpec{
+		Args: []string{"World"},
+		Files: protocol.FileList{
+			{Path: "a.txt", File: protocol.File{Blob: *a_txt}},
+			{Path: "indir/b.txt", File: protocol.File{Blob: *b_txt}},
+		},
+		Outputs: []string{"outdir/c.txt"},
+	}
+
+	r := Runner{store: st, cmdline: cmdline}
+
+	job, err := r.parseJob(ctx, &spec)
+	if err != nil {
+		t.Fatal("parseJob", err)
+	}
+	defer job.Cleanup()
+	if !reflect.DeepEqual(job.Args, []string{"/bin/echo", "Hello", "World"}) {
+		t.Errorf("Bad args: %q", job.Args)
+	}
+	data, err := ioutil.ReadFile(path.Join(job.Root, "a.txt"))
+	if err != nil || string(data) != contentsA {
+		t.Errorf("Bad a.txt: %q/%v", data, err)
+	}
+	data, err = ioutil.ReadFile(path.Join(job.Root, "indir/b.txt"))
+	if err != nil || string(data) != contentsB {
+		t.Errorf("Bad b.txt: %q/%v", data, err)
+	}
+	fi, err := os.Stat(path.Join(job.Root, "outdir"))
+	if err != nil {
+		t.Errorf("coult not stat outdir: %s", err.Error())
+	} else if !fi.Mode().IsDir() {
+		t.Errorf("outdir should be a directory, is: %d", fi.Mode())
+	}
+}
+
+func TestRunOne(t *testing.T) {
+	const (
+		contentsA = "Hello, A\n"
+	)
+
+	ctx := context.Background()
+	st := store.InMemory()
+	a_txt, _ := files.NewBlob(ctx, st, []byte(contentsA))
+
+	cmdline := []string{"/bin/sh", "-c"}
+	spec := protocol.InvocationSpec{
+		Args: []string{`cat in/a.txt > b.txt; echo World >> b.txt; echo OutPUT; echo STDeRR >&2`},
+		Files: protocol.FileList{
+			{Path: "in/a.txt", File: protocol.File{Blob: *a_txt}},
+		},
+		Outputs: []string{"b.txt", "c.txt"},
+	}
+
+	r := Runner{store: st, cmdline: cmdline}
+	resp, err := r.RunOne(ctx, &spec)
+	if err != nil {
+		t.Fatal("runOne", err)
+	}
+
+	// c.txt is not created and will not be included in the
+	// outputs
+	assert.Equal(t, 1, len(resp.Outputs))
+
+	b_blob := resp.Outputs[0]
+	assert.Equal(t, "b.txt", b_blob.Path)
+	b_txt, err := files.Read(ctx, st, &b_blob.Blob)
+	assert.NoError(t, err)
+	assert.Equal(t, contentsA+"World\n", string(b_txt))
+}
+
+func TestRunOne_OutputChanges(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	a_txt, _ := files.NewBlob(ctx, st, []byte("A\n"))
+	b_txt, _ := files.NewBlob(ctx, st, []byte("B\n"))
+
+	spec := protocol.InvocationSpec{
+		Args: []string{"/bin/sh", "-c", `echo more >> in/a.txt; mkdir -p new; echo C > new/c.txt; echo D > d.txt`},
+		Files: protocol.FileList{
+			{Path: "in/a.txt", File: protocol.File{Blob: *a_txt}},
+			{Path: "in/b.txt", File: protocol.File{Blob: *b_txt}},
+		},
+		Outputs:       []string{"d.txt"},
+		OutputChanges: true,
+	}
+
+	r := Runner{store: st}
+	resp, err := r.RunOne(ctx, &spec)
+	require.NoError(t, err)
+
+	got := make(map[string]string)
+	for _, out := range resp.Outputs {
+		data, err := files.Read(ctx, st, &out.Blob)
+		require.NoError(t, err)
+		got[out.Path] = string(data)
+	}
+	assert.Equal(t, map[string]string{
+		"d.txt":     "D\n",
+		"in/a.txt":  "A\nmore\n",
+		"new/c.txt": "C\n",
+	}, got)
+	assert.Len(t, resp.Outputs, 3)
+}
+
+func TestRunOne_NoCmdLine(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	spec := protocol.InvocationSpec{
+		Args:    []string{`echo`, `hello`},
+		Files:   nil,
+		Outputs: nil,
+	}
+
+	r := Runner{store: st}
+	resp, err := r.RunOne(ctx, &spec)
+	if err != nil {
+		t.Fatal("runOne", err)
+	}
+
+	stdout, err := files.Read(ctx, st, resp.Stdout)
+	require.NoError(t, err)
+	assert.Equal(t, stdout, []byte("hello\n"))
+}
+
+func TestRunOne_Env(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	spec := protocol.InvocationSpec{
+		Args: []string{"/bin/sh", "-c", `echo "$SOURCE_DATE_EPOCH"`},
+		Env:  []string{"SOURCE_DATE_EPOCH=1600000000"},
+	}
+
+	r := Runner{store: st}
+	resp, err := r.RunOne(ctx, &spec)
+	require.NoError(t, err)
+
+	stdout, err := files.Read(ctx, st, resp.Stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "1600000000\n", string(stdout))
+}
+
+func TestRunOne_TimeLimit(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	r := Runner{store: st}
+	resp, err := r.RunOne(ctx, &protocol.InvocationSpec{
+		Args:      []string{"/bin/sh", "-c", "exec sleep 10"},
+		TimeLimit: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, timeLimitExitStatus, resp.ExitStatus)
+	stderr, err := files.Read(ctx, st, resp.Stderr)
+	require.NoError(t, err)
+	assert.Contains(t, string(stderr), "time limit")
+
+	resp, err = r.RunOne(ctx, &protocol.InvocationSpec{
+		Args:      []string{"/bin/sh", "-c", "exit 3"},
+		TimeLimit: 10 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.ExitStatus)
+}
+
+func TestRunOne_Usage(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+
+	r := Runner{store: st}
+	resp, err := r.RunOne(ctx, &protocol.InvocationSpec{
+		Args:      []string{"/bin/sh", "-c", "kill -9 $$"},
+		TimeLimit: 10 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.Killed)
+	assert.NotZero(t, resp.MaxRSS)
+
+	resp, err = r.RunOne(ctx, &protocol.InvocationSpec{
+		Args:      []string{"/bin/sh", "-c", "exec sleep 10"},
+		TimeLimit: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.False(t, resp.Killed, "timeouts are not reported as kills")
+}
+
+type recordingExporter struct {
+	spans [][]tracing.Span
+}
+
+func (e *recordingExporter) Export(spans []tracing.Span) error {
+	e.spans = append(e.spans, spans)
+	return nil
+}
+
+func TestRunOne_Export(t *testing.T) {
+	ctx := context.Background()
+	st := store.InMemory()
+	var exp recordingExporter
+
+	r := Runner{store: st}
+	r.AddExporter(&exp)
+	trace := &tracing.Propagation{TraceId: "5759e988bd862e3fe1be46a994272793", ParentId: "1111111111111111"}
+	resp, err := r.RunOne(ctx, &protocol.InvocationSpec{
+		Args:  []string{"/bin/sh", "-c", "true"},
+		Trace: trace,
+	})
+	require.NoError(t, err)
+	require.Len(t, exp.spans, 1)
+
+	var names []string
+	for _, sp := range exp.spans[0] {
+		assert.Equal(t, trace.TraceId, sp.TraceId)
+		names = append(names, sp.Name)
+	}
+	assert.ElementsMatch(t, []string{"fetch", "exec", "upload", "runtime.Execute"}, names)
+	assert.Equal(t, exp.spans[0], resp.InlineSpans)
+
+	// Jobs which fail to run are exported too, but there's
+	// nothing to export without a trace
+	_, err = r.RunOne(ctx, &protocol.InvocationSpec{Trace: trace})
+	require.Error(t, err)
+	require.Len(t, exp.spans, 2)
+	_, err = r.RunOne(ctx, &protocol.InvocationSpec{Args: []string{"true"}})
+	require.NoError(t, err)
+	assert.Len(t, exp.spans, 2)
+}
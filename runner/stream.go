@@ -0,0 +1,137 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+)
+
+var streamInterval = time.Second
+
+// outputCapture collects a command's stdout and stderr. If a stream
+// is configured, it also periodically publishes any new output to the
+// store, so that clients can display it before the command exits.
+// The response carries all of the command's output, so once it exits,
+// clients have no further use for the published chunks.
+type outputCapture struct {
+	mu             sync.Mutex
+	stdout, stderr bytes.Buffer
+	sentOut        int
+	sentErr        int
+	seq            int
+
+	kv     store.KeyValue
+	stream string
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+type captureWriter struct {
+	c   *outputCapture
+	buf *bytes.Buffer
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (c *outputCapture) Stdout() *captureWriter {
+	return &captureWriter{c, &c.stdout}
+}
+
+func (c *outputCapture) Stderr() *captureWriter {
+	return &captureWriter{c, &c.stderr}
+}
+
+func (c *outputCapture) Start(ctx context.Context, st store.Store, stream string) {
+	kv, ok := st.(store.KeyValue)
+	if stream == "" || !ok {
+		return
+	}
+	c.kv = kv
+	c.stream = stream
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		tick := time.NewTicker(streamInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-tick.C:
+				c.flush(ctx)
+			}
+		}
+	}()
+}
+
+// Stop halts streaming, and deletes the chunks published so far. Any
+// output not yet published is returned in the final response,
+// instead.
+func (c *outputCapture) Stop(ctx context.Context) {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	coll, ok := c.kv.(store.Collectable)
+	if !ok || c.seq == 0 {
+		// The bucket's lifecycle rules, or llama gc, will
+		// clean up after us
+		return
+	}
+	keys := make([]string, c.seq)
+	for i := range keys {
+		keys[i] = protocol.StreamChunkKey(c.stream, i)
+	}
+	if err := coll.DeleteKeys(ctx, keys); err != nil {
+		log.Printf("deleting streamed output: %s", err.Error())
+	}
+}
+
+func (c *outputCapture) flush(ctx context.Context) {
+	c.mu.Lock()
+	chunk := protocol.StreamChunk{
+		Stdout: append([]byte(nil), c.stdout.Bytes()[c.sentOut:]...),
+		Stderr: append([]byte(nil), c.stderr.Bytes()[c.sentErr:]...),
+	}
+	c.mu.Unlock()
+	if len(chunk.Stdout) == 0 && len(chunk.Stderr) == 0 {
+		return
+	}
+	data, err := json.Marshal(&chunk)
+	if err != nil {
+		return
+	}
+	if err := c.kv.SetKey(ctx, protocol.StreamChunkKey(c.stream, c.seq), data); err != nil {
+		log.Printf("streaming output: %s", err.Error())
+		return
+	}
+	c.seq++
+	c.sentOut += len(chunk.Stdout)
+	c.sentErr += len(chunk.Stderr)
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type kvStore interface {
+	store.Store
+	store.KeyValue
+}
+
+// collectableKV is an in-memory store which records the keys deleted
+// from it, as the S3 store the runtime uses can delete them
+type collectableKV struct {
+	kvStore
+	deleted *[]string
+}
+
+func (c collectableKV) ListObjects(ctx context.Context, cb func(store.ObjectInfo) error) error {
+	return nil
+}
+
+func (c collectableKV) ListKeys(ctx context.Context, prefix string, cb func(store.ObjectInfo) error) error {
+	return nil
+}
+
+func (c collectableKV) DeleteObjects(ctx context.Context, ids []string) error {
+	return nil
+}
+
+func (c collectableKV) DeleteKeys(ctx context.Context, keys []string) error {
+	*c.deleted = append(*c.deleted, keys...)
+	return nil
+}
+
+func TestOutputStream(t *testing.T) {
+	// Publish only when we flush by hand
+	defer func(interval time.Duration) { streamInterval = interval }(streamInterval)
+	streamInterval = time.Hour
+
+	ctx := context.Background()
+	var deleted []string
+	st := collectableKV{store.InMemory().(kvStore), &deleted}
+	chunk := func(seq int) *protocol.StreamChunk {
+		data, err := st.GetKey(ctx, protocol.StreamChunkKey("s", seq))
+		if err == store.ErrNotExists {
+			return nil
+		}
+		require.NoError(t, err)
+		var chunk protocol.StreamChunk
+		require.NoError(t, json.Unmarshal(data, &chunk))
+		return &chunk
+	}
+
+	var c outputCapture
+	c.Start(ctx, st, "s")
+	fmt.Fprint(c.Stdout(), "one\n")
+	fmt.Fprint(c.Stderr(), "warning\n")
+	c.flush(ctx)
+	// Nothing new, so no chunk
+	c.flush(ctx)
+	fmt.Fprint(c.Stdout(), "two\n")
+	c.flush(ctx)
+	// Output since the last publish stays in the response
+	fmt.Fprint(c.Stdout(), "three\n")
+
+	assert.Equal(t, &protocol.StreamChunk{Stdout: []byte("one\n"), Stderr: []byte("warning\n")}, chunk(0))
+	assert.Equal(t, &protocol.StreamChunk{Stdout: []byte("two\n")}, chunk(1))
+	assert.Nil(t, chunk(2))
+
+	c.Stop(ctx)
+	assert.Equal(t, "one\ntwo\nthree\n", c.stdout.String())
+	assert.Equal(t, []string{protocol.StreamChunkKey("s", 0), protocol.StreamChunkKey("s", 1)}, deleted,
+		"chunks are deleted once the command exits")
+}
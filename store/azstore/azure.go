@@ -0,0 +1,88 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azstore implements a llama store in Azure Blob Storage,
+// addressed as azblob://ACCOUNT/CONTAINER/PATH. It authenticates
+// using a shared access signature read from
+// $AZURE_STORAGE_SAS_TOKEN.
+package azstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/store/blobstore"
+)
+
+const apiVersion = "2020-04-08"
+
+func init() {
+	store.RegisterBackend("azblob", Open)
+}
+
+func Open(ctx context.Context, u *url.URL) (store.Store, error) {
+	account, container, prefix, err := parseURL(u)
+	if err != nil {
+		return nil, err
+	}
+	sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	if sas == "" {
+		return nil, errors.New("azblob: AZURE_STORAGE_SAS_TOKEN is not set")
+	}
+	endpoint := &url.URL{Scheme: "https", Host: account + ".blob.core.windows.net"}
+	return newStore(endpoint, container, prefix, sas), nil
+}
+
+// parseURL splits azblob://ACCOUNT/CONTAINER/PATH into its parts
+func parseURL(u *url.URL) (account, container, prefix string, err error) {
+	account = u.Host
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if account == "" || parts[0] == "" {
+		return "", "", "", fmt.Errorf("azblob: %q: expected azblob://ACCOUNT/CONTAINER/PATH", u.String())
+	}
+	container = parts[0]
+	if len(parts) > 1 {
+		prefix = parts[1]
+	}
+	return account, container, prefix, nil
+}
+
+// newStore returns a store in `container` under `prefix`, using the
+// storage account at `endpoint` and authenticating with the
+// shared access signature `sas`.
+func newStore(endpoint *url.URL, container, prefix, sas string) *blobstore.Store {
+	bucket := &blobstore.HTTPBucket{
+		URL: func(key string) string {
+			return (&url.URL{
+				Scheme:   endpoint.Scheme,
+				Host:     endpoint.Host,
+				Path:     "/" + container + "/" + key,
+				RawQuery: sas,
+			}).String()
+		},
+		Prepare: func(req *http.Request) {
+			req.Header.Set("x-ms-version", apiVersion)
+			if req.Method == "PUT" {
+				req.Header.Set("x-ms-blob-type", "BlockBlob")
+			}
+		},
+	}
+	return blobstore.New(bucket, prefix)
+}
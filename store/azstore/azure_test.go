@@ -0,0 +1,143 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azstore
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAzure serves blobs in the manner of Azure Blob Storage,
+// rejecting requests which don't carry the expected SAS
+type fakeAzure struct {
+	sync.Mutex
+	t     *testing.T
+	sig   string
+	blobs map[string][]byte
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	if r.URL.Query().Get("sig") != f.sig {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	assert.Equal(f.t, apiVersion, r.Header.Get("x-ms-version"), "%s %s", r.Method, r.URL)
+	switch r.Method {
+	case "GET", "HEAD":
+		assert.Empty(f.t, r.Header.Get("x-ms-blob-type"))
+		data, ok := f.blobs[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case "PUT":
+		assert.Equal(f.t, "BlockBlob", r.Header.Get("x-ms-blob-type"))
+		data, _ := ioutil.ReadAll(r.Body)
+		f.blobs[r.URL.Path] = data
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	cases := []struct {
+		url                        string
+		account, container, prefix string
+		err                        bool
+	}{
+		{url: "azblob://acct/container", account: "acct", container: "container"},
+		{url: "azblob://acct/container/", account: "acct", container: "container"},
+		{url: "azblob://acct/container/llama/objs", account: "acct", container: "container", prefix: "llama/objs"},
+		{url: "azblob://acct", err: true},
+		{url: "azblob://acct/", err: true},
+		{url: "azblob:///container", err: true},
+	}
+	for _, tc := range cases {
+		u, err := url.Parse(tc.url)
+		require.NoError(t, err)
+		account, container, prefix, err := parseURL(u)
+		if tc.err {
+			assert.Error(t, err, tc.url)
+			continue
+		}
+		if assert.NoError(t, err, tc.url) {
+			assert.Equal(t, tc.account, account, tc.url)
+			assert.Equal(t, tc.container, container, tc.url)
+			assert.Equal(t, tc.prefix, prefix, tc.url)
+		}
+	}
+}
+
+func TestOpenRequiresSAS(t *testing.T) {
+	old, had := os.LookupEnv("AZURE_STORAGE_SAS_TOKEN")
+	defer func() {
+		if had {
+			os.Setenv("AZURE_STORAGE_SAS_TOKEN", old)
+		} else {
+			os.Unsetenv("AZURE_STORAGE_SAS_TOKEN")
+		}
+	}()
+	u, _ := url.Parse("azblob://acct/container/llama")
+
+	os.Unsetenv("AZURE_STORAGE_SAS_TOKEN")
+	_, err := Open(context.Background(), u)
+	assert.Error(t, err)
+
+	os.Setenv("AZURE_STORAGE_SAS_TOKEN", "?sv=2020-04-08&sig=abc")
+	_, err = Open(context.Background(), u)
+	assert.NoError(t, err)
+}
+
+func TestStore(t *testing.T) {
+	az := &fakeAzure{t: t, sig: "s3cr=t", blobs: make(map[string][]byte)}
+	srv := httptest.NewServer(az)
+	defer srv.Close()
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	sas := url.Values{"sv": {"2020-04-08"}, "sig": {az.sig}}.Encode()
+	st := newStore(endpoint, "container", "llama", sas)
+	ctx := context.Background()
+
+	_, err = st.GetKey(ctx, "missing")
+	assert.Equal(t, store.ErrNotExists, err)
+
+	require.NoError(t, st.SetKey(ctx, "dir/key", []byte("value")))
+	assert.Equal(t, []byte("value"), az.blobs["/container/llama/keys/dir/key"])
+	got, err := st.GetKey(ctx, "dir/key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), got)
+
+	id, err := st.Store(ctx, []byte("hello"))
+	require.NoError(t, err)
+	assert.Contains(t, az.blobs, "/container/llama/"+id)
+
+	bad := newStore(endpoint, "container", "llama", "sig=wrong")
+	_, err = bad.GetKey(ctx, "dir/key")
+	assert.Error(t, err)
+	assert.NotEqual(t, store.ErrNotExists, err)
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blobstore implements a llama Store on top of any simple
+// blob storage service, such as Google Cloud Storage or Azure Blob
+// Storage. Objects are compressed and addressed exactly as in the S3
+// store.
+//
+// A Bucket can't list or delete objects, so Store doesn't implement
+// store.Collectable.
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/store/internal/storeutil"
+	"github.com/nelhage/llama/tracing"
+	"golang.org/x/sync/errgroup"
+)
+
+// A Bucket is the minimal interface a blob storage service must
+// provide.
+type Bucket interface {
+	// Get returns store.ErrNotExists if `key` does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+type Store struct {
+	bucket Bucket
+	prefix string
+	seen   storeutil.Cache
+}
+
+var (
+	encode *zstd.Encoder
+	decode *zstd.Decoder
+)
+
+func init() {
+	var err error
+	encode, err = zstd.NewWriter(nil)
+	if err != nil {
+		panic(fmt.Sprintf("zstd: init writer: %s", err.Error()))
+	}
+	decode, err = zstd.NewReader(nil)
+	if err != nil {
+		panic(fmt.Sprintf("zstd: init reader: %s", err.Error()))
+	}
+}
+
+// New returns a Store which keeps its objects in `bucket`, under
+// `prefix`.
+func New(bucket Bucket, prefix string) *Store {
+	return &Store{bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *Store) objectKey(id string) string {
+	return path.Join(s.prefix, id)
+}
+
+func (s *Store) Store(ctx context.Context, obj []byte) (string, error) {
+	ctx, span := tracing.StartSpan(ctx, "blobstore.store")
+	defer span.End()
+	id := storeutil.HashObject(obj) + ":zstd"
+	span.AddField("object_id", id)
+	if s.seen.HasObject(id) {
+		return id, nil
+	}
+
+	upload := s.seen.StartUpload(id)
+	defer upload.Rollback()
+
+	exists, err := s.bucket.Exists(ctx, s.objectKey(id))
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		compressed := encode.EncodeAll(obj, nil)
+		span.AddField("write_bytes", len(compressed))
+		if err := s.bucket.Put(ctx, s.objectKey(id), compressed); err != nil {
+			return "", err
+		}
+	}
+	upload.Complete()
+	return id, nil
+}
+
+const getConcurrency = 32
+
+func (s *Store) getOne(ctx context.Context, id string) ([]byte, error) {
+	body, err := s.bucket.Get(ctx, s.objectKey(id))
+	if err != nil {
+		return nil, err
+	}
+	hash := id
+	if colon := strings.IndexRune(id, ':'); colon > 0 {
+		hash = id[:colon]
+		if coding := id[colon+1:]; coding != "zstd" {
+			return nil, fmt.Errorf("%q: unknown compression %s", id, coding)
+		}
+		body, err = decode.DecodeAll(body, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%q: decoding: %w", id, err)
+		}
+	}
+	if got, ok := storeutil.CheckHash(hash, body); !ok {
+		return nil, fmt.Errorf("object store mismatch: got csum=%s expected %s", got, id)
+	}
+	u := s.seen.StartUpload(id)
+	u.Complete()
+	return body, nil
+}
+
+func (s *Store) GetObjects(ctx context.Context, gets []store.GetRequest) {
+	ctx, span := tracing.StartSpan(ctx, "blobstore.get_objects")
+	defer span.End()
+	span.AddField("objects", len(gets))
+	grp, ctx := errgroup.WithContext(ctx)
+	jobs := make(chan int)
+
+	grp.Go(func() error {
+		defer close(jobs)
+		for i := range gets {
+			jobs <- i
+		}
+		return nil
+	})
+	for i := 0; i < getConcurrency; i++ {
+		grp.Go(func() error {
+			for idx := range jobs {
+				gets[idx].Data, gets[idx].Err = s.getOne(ctx, gets[idx].Id)
+			}
+			return nil
+		})
+	}
+
+	if err := grp.Wait(); err != nil {
+		log.Fatalf("GetObjects: internal error %s", err)
+	}
+}
+
+func (s *Store) GetKey(ctx context.Context, key string) ([]byte, error) {
+	return s.bucket.Get(ctx, path.Join(s.prefix, "keys", key))
+}
+
+func (s *Store) SetKey(ctx context.Context, key string, value []byte) error {
+	return s.bucket.Put(ctx, path.Join(s.prefix, "keys", key), value)
+}
+
+// FetchAWSUsage is a no-op, since we don't store objects in AWS
+func (s *Store) FetchAWSUsage(u *protocol.UsageMetrics) {}
@@ -0,0 +1,126 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobstore
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// httpBucket serves a bucket over HTTP, in the manner of GCS's XML
+// API
+type httpBucket struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *httpBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.Lock()
+	defer b.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case "GET", "HEAD":
+		data, ok := b.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		b.objects[key] = data
+	}
+}
+
+func TestStore(t *testing.T) {
+	backing := &httpBucket{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(backing)
+	defer srv.Close()
+
+	st := New(&HTTPBucket{
+		URL: func(key string) string { return srv.URL + "/" + key },
+	}, "/llama/")
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("hello, world\n", 100))
+	id, err := st.Store(ctx, data)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(id, ":zstd"))
+	assert.Contains(t, backing.objects, "llama/"+id)
+	assert.Less(t, len(backing.objects["llama/"+id]), len(data))
+
+	got, err := store.Get(ctx, st, id)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// A fresh store must read the object back from the bucket
+	st2 := New(st.bucket, "llama")
+	got, err = store.Get(ctx, st2, id)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, err = store.Get(ctx, st2, strings.Repeat("0", 64))
+	assert.Equal(t, store.ErrNotExists, err)
+
+	backing.objects["llama/"+id] = backing.objects["llama/"+id][:10]
+	_, err = store.Get(ctx, New(st.bucket, "llama"), id)
+	assert.Error(t, err)
+
+	_, err = st.GetKey(ctx, "results/abc")
+	assert.Equal(t, store.ErrNotExists, err)
+	require.NoError(t, st.SetKey(ctx, "results/abc", []byte("value")))
+	assert.Contains(t, backing.objects, "llama/keys/results/abc")
+	val, err := st.GetKey(ctx, "results/abc")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), val)
+}
+
+func TestDirBucket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama-blobstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bucket := &DirBucket{Root: dir}
+	ctx := context.Background()
+
+	_, err = bucket.Get(ctx, "a/b")
+	assert.Equal(t, store.ErrNotExists, err)
+	ok, err := bucket.Exists(ctx, "a/b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, bucket.Put(ctx, "a/b", []byte("data")))
+	ok, err = bucket.Exists(ctx, "a/b")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	got, err := bucket.Get(ctx, "a/b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), got)
+
+	ents, err := ioutil.ReadDir(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	assert.Len(t, ents, 1, "temporary files should be cleaned up")
+}
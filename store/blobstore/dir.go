@@ -0,0 +1,71 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nelhage/llama/store"
+)
+
+// DirBucket implements Bucket using files in a local directory.
+type DirBucket struct {
+	Root string
+}
+
+func (b *DirBucket) path(key string) string {
+	return filepath.Join(b.Root, filepath.FromSlash(key))
+}
+
+func (b *DirBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(b.path(key))
+	if os.IsNotExist(err) {
+		return nil, store.ErrNotExists
+	}
+	return data, err
+}
+
+func (b *DirBucket) Put(ctx context.Context, key string, data []byte) error {
+	dst := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it into place, so that
+	// concurrent readers never see a partial object.
+	f, err := ioutil.TempFile(filepath.Dir(dst), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+func (b *DirBucket) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(b.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/nelhage/llama/store"
+)
+
+// HTTPBucket implements Bucket for services which expose objects as
+// HTTP resources supporting GET, HEAD, and PUT.
+type HTTPBucket struct {
+	Client *http.Client
+	// URL returns the URL of the object named by `key`
+	URL func(key string) string
+	// Prepare, if non-nil, is called on each request before it is
+	// sent, e.g. to add headers
+	Prepare func(req *http.Request)
+}
+
+func (b *HTTPBucket) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.URL(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if b.Prepare != nil {
+		b.Prepare(req)
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+func statusError(method, key string, resp *http.Response) error {
+	msg, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("%s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(msg))
+}
+
+func (b *HTTPBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := b.do(ctx, "GET", key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, store.ErrNotExists
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("GET", key, resp)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (b *HTTPBucket) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := b.do(ctx, "PUT", key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return statusError("PUT", key, resp)
+	}
+	return nil
+}
+
+func (b *HTTPBucket) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := b.do(ctx, "HEAD", key, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("HEAD %s: %s", key, resp.Status)
+	}
+}
@@ -19,12 +19,21 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/nelhage/llama/store/internal/storeutil"
 )
 
 const debugCache = false
 
+// Objects are written to temporary files with this prefix, and then
+// renamed into place.
+const tempPrefix = ".tmp."
+
 type Cache struct {
 	maxBytes uint64
 	root     string
@@ -68,6 +77,9 @@ type entry struct {
 	prev  *entry
 }
 
+// New returns a cache of at most `limit` bytes in the directory
+// `path`. Objects already in the directory, from an earlier process,
+// are adopted, in order of how recently they were used.
 func New(path string, limit uint64) *Cache {
 	st := &Cache{
 		maxBytes: limit,
@@ -78,9 +90,61 @@ func New(path string, limit uint64) *Cache {
 	}
 	st.objects.head.next = &st.objects.head
 	st.objects.head.prev = &st.objects.head
+	st.load()
 	return st
 }
 
+type existingObject struct {
+	id    string
+	size  int64
+	mtime time.Time
+}
+
+func (st *Cache) load() {
+	var existing []existingObject
+	dirs, _ := ioutil.ReadDir(st.root)
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		objs, _ := ioutil.ReadDir(filepath.Join(st.root, dir.Name()))
+		for _, obj := range objs {
+			if !obj.Mode().IsRegular() {
+				continue
+			}
+			if strings.HasPrefix(obj.Name(), tempPrefix) {
+				// Left behind by a crash mid-write
+				os.Remove(filepath.Join(st.root, dir.Name(), obj.Name()))
+				continue
+			}
+			id := storeutil.ShardID(dir.Name(), obj.Name())
+			if !st.inPlace(id, dir.Name(), obj.Name()) {
+				continue
+			}
+			existing = append(existing, existingObject{
+				id:    id,
+				size:  obj.Size(),
+				mtime: obj.ModTime(),
+			})
+		}
+	}
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].mtime.Before(existing[j].mtime)
+	})
+
+	st.objects.Lock()
+	defer st.objects.Unlock()
+	for _, obj := range existing {
+		ent := &entry{
+			id:    obj.id,
+			bytes: uint64(len(obj.id)) + uint64(obj.size),
+		}
+		st.objects.have[obj.id] = ent
+		st.objects.pushFront(ent)
+	}
+	st.shrinkLocked()
+}
+
 func (st *Cache) Put(key string, obj []byte) {
 	st.addToCache(key, obj)
 }
@@ -88,18 +152,53 @@ func (st *Cache) Put(key string, obj []byte) {
 func (st *Cache) Get(key string) ([]byte, bool) {
 	st.objects.Lock()
 	defer st.objects.Unlock()
-	if _, ok := st.objects.have[key]; !ok {
+	ent, ok := st.objects.have[key]
+	if !ok {
 		return nil, false
 	}
 	data, err := st.getOneCached(key)
 	if err != nil {
-		log.Printf("cache.get(%q): %s", key, err.Error())
+		// Another process sharing the directory may have
+		// evicted it
+		if !os.IsNotExist(err) {
+			log.Printf("cache.get(%q): %s", key, err.Error())
+		}
+		st.objects.unlink(ent)
+		delete(st.objects.have, key)
+		st.objects.checkConsistency()
+		return nil, false
 	}
+	// Move the object to the head of the LRU list, and record
+	// that on disk so it survives a restart.
+	st.objects.unlink(ent)
+	st.objects.pushFront(ent)
+	now := time.Now()
+	os.Chtimes(st.pathFor(key), now, now)
+	st.objects.checkConsistency()
 	return data, true
 }
 
 func (st *Cache) pathFor(id string) string {
-	return path.Join(st.root, id[:2], id[2:])
+	dir, name := storeutil.ShardPath(id)
+	return filepath.Join(st.root, dir, name)
+}
+
+// inPlace makes sure the object `id`, found as `name` in `dir`, is
+// where pathFor expects it, moving it there if an older layout put it
+// elsewhere. It reports whether the object is usable.
+func (st *Cache) inPlace(id, dir, name string) bool {
+	want := st.pathFor(id)
+	have := filepath.Join(st.root, dir, name)
+	if want == have {
+		return true
+	}
+	if err := os.MkdirAll(filepath.Dir(want), 0755); err == nil {
+		if err := os.Rename(have, want); err == nil {
+			return true
+		}
+	}
+	os.Remove(have)
+	return false
 }
 
 func (st *Cache) getOneCached(id string) ([]byte, error) {
@@ -110,6 +209,45 @@ func (st *Cache) getOneCached(id string) ([]byte, error) {
 	return data, nil
 }
 
+func (o *objectTracker) unlink(ent *entry) {
+	ent.prev.next = ent.next
+	ent.next.prev = ent.prev
+	o.bytes -= ent.bytes
+}
+
+func (o *objectTracker) pushFront(ent *entry) {
+	head := &o.head
+	ent.next = head.next
+	ent.prev = head
+	head.next.prev = ent
+	head.next = ent
+	o.bytes += ent.bytes
+}
+
+// writeFile writes `data` to `file` via a temporary file, so that
+// other processes sharing the cache never see a partial object.
+func writeFile(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
 func (st *Cache) addToCache(id string, data []byte) {
 	st.objects.Lock()
 	defer st.objects.Unlock()
@@ -118,42 +256,34 @@ func (st *Cache) addToCache(id string, data []byte) {
 		// Already in cache; move to the head of the LRU list.
 		// Remove from the list, and let the next section
 		// re-add it.
-		ent.prev.next = ent.next
-		ent.next.prev = ent.prev
-		st.objects.bytes -= ent.bytes
+		st.objects.unlink(ent)
 	} else {
 		ent = &entry{
 			id:    id,
 			bytes: uint64(len(id) + len(data)),
 		}
 		file := st.pathFor(id)
-		os.Mkdir(path.Dir(file), 0755)
-		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		if err := writeFile(file, data); err != nil {
 			log.Printf("Error writing to cache! path=%s err=%q", file, err.Error())
 			return
 		}
 		st.objects.have[id] = ent
 	}
 
-	// Add the entry to the head of the list
-	head := &st.objects.head
-	ent.next = head.next
-	ent.prev = head
-	head.next.prev = ent
-	head.next = ent
-	st.objects.bytes += ent.bytes
-
+	st.objects.pushFront(ent)
 	st.objects.checkConsistency()
+	st.shrinkLocked()
+}
 
-	// Shrink down to fit
+// shrinkLocked evicts the least-recently-used objects until the
+// cache fits in its limit.
+func (st *Cache) shrinkLocked() {
 	for st.objects.bytes > st.maxBytes {
 		// prune the tail object
 		ent := st.objects.head.prev
 		os.Remove(st.pathFor(ent.id))
-		st.objects.head.prev = ent.prev
-		ent.prev.next = &st.objects.head
+		st.objects.unlink(ent)
 		delete(st.objects.have, ent.id)
-		st.objects.bytes -= ent.bytes
 		st.objects.checkConsistency()
 	}
 }
@@ -17,8 +17,11 @@ package diskcache
 import (
 	"crypto/rand"
 	"io/fs"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/nelhage/llama/store/internal/storeutil"
 	"github.com/stretchr/testify/assert"
@@ -103,3 +106,77 @@ func TestEviction(t *testing.T) {
 		return nil
 	})
 }
+
+func TestGetUpdatesLRU(t *testing.T) {
+	idA := storeutil.HashObject([]byte("a"))
+	idB := storeutil.HashObject([]byte("b"))
+	idC := storeutil.HashObject([]byte("c"))
+	entry := uint64(len(idA) + 1)
+	cache := New(t.TempDir(), 2*entry)
+
+	cache.Put(idA, []byte("a"))
+	cache.Put(idB, []byte("b"))
+	_, ok := cache.Get(idA)
+	assert.True(t, ok)
+
+	// b is now the least recently used, and makes room for c
+	cache.Put(idC, []byte("c"))
+	_, ok = cache.Get(idB)
+	assert.False(t, ok)
+	_, ok = cache.Get(idA)
+	assert.True(t, ok)
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	idA := storeutil.HashObject([]byte(fileA))
+	idB := storeutil.HashObject([]byte(fileB))
+
+	cache := New(dir, 1024*1024)
+	cache.Put(idA, []byte(fileA))
+	cache.Put(idB, []byte(fileB))
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(cache.pathFor(idA), old, old)
+
+	// A new process adopts the objects already on disk, oldest
+	// first, and evicts down to its own limit
+	limit := uint64(len(idB) + len(fileB))
+	reopened := New(dir, limit)
+	got, ok := reopened.Get(idB)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(fileB), got)
+	_, ok = reopened.Get(idA)
+	assert.False(t, ok)
+	_, err := os.Stat(cache.pathFor(idA))
+	assert.True(t, os.IsNotExist(err))
+
+	// Objects evicted by another process are misses
+	os.Remove(cache.pathFor(idB))
+	_, ok = reopened.Get(idB)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(0), reopened.objects.bytes)
+}
+
+func TestBLAKE3Sharding(t *testing.T) {
+	dir := t.TempDir()
+	idA := storeutil.HashBLAKE3.Sum([]byte(fileA))
+	idB := storeutil.HashBLAKE3.Sum([]byte(fileB))
+
+	cache := New(dir, 1024*1024)
+	cache.Put(idA, []byte(fileA))
+	_, err := os.Stat(filepath.Join(dir, idA[3:5]))
+	assert.NoError(t, err)
+
+	// Objects written before we sharded by the digest, into
+	// one "b3" directory, are moved into place
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "b3"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b3", idB[2:]), []byte(fileB), 0644))
+	reopened := New(dir, 1024*1024)
+	for id, want := range map[string]string{idA: fileA, idB: fileB} {
+		got, ok := reopened.Get(id)
+		assert.True(t, ok)
+		assert.Equal(t, []byte(want), got)
+	}
+	_, err = os.Stat(filepath.Join(dir, "b3", idB[2:]))
+	assert.True(t, os.IsNotExist(err))
+}
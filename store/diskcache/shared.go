@@ -0,0 +1,206 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskcache
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/nelhage/llama/store/internal/storeutil"
+)
+
+// DefaultSharedBytes is the size a Shared cache is kept to, unless
+// configured otherwise
+const DefaultSharedBytes = 20 << 30
+
+// SharedTouchInterval is how out of date an object's mtime may get
+// before Get refreshes it. Refreshing it on every Get would turn
+// every read of a network filesystem into a write, too.
+const SharedTouchInterval = time.Hour
+
+const (
+	// How often, at most, any process sharing the cache prunes it
+	pruneInterval = 10 * time.Minute
+	// Prune down to this fraction of the limit, so that we don't
+	// prune again as soon as anything is added
+	pruneLowWater = 0.9
+	// A process considers pruning each time it has written this
+	// fraction of the limit
+	pruneEvery = 32
+
+	// Files in the cache's root: one which the pruning process
+	// holds locked, and one whose mtime records the last prune
+	pruneLockFile  = ".prune.lock"
+	pruneStampFile = ".pruned"
+)
+
+// Shared is a cache of objects in a directory that may be shared by
+// many processes at once, such as an EFS filesystem mounted into
+// every instance of a Lambda function. Unlike Cache, it keeps no
+// index in memory: every Get consults the filesystem, so objects
+// written by one process are visible to all the others.
+//
+// Objects are written to a temporary file and renamed into place, so
+// readers never see a partial object. Because keys name immutable,
+// content-addressed objects, concurrent writers of the same key are
+// harmless, and it is always safe to delete objects (or the entire
+// directory) out from under it.
+//
+// Each object's mtime records when it was last used. Every so often,
+// one of the processes sharing the cache scans it, and deletes the
+// least recently used objects until it fits in its limit.
+type Shared struct {
+	root     string
+	maxBytes uint64
+
+	// Bytes written since we last considered pruning
+	written uint64
+	pruning int32
+}
+
+// NewShared returns a cache in the directory `path`, which is pruned
+// to at most `limit` bytes.
+func NewShared(path string, limit uint64) *Shared {
+	return &Shared{root: path, maxBytes: limit}
+}
+
+func (s *Shared) pathFor(id string) string {
+	dir, name := storeutil.ShardPath(id)
+	return filepath.Join(s.root, dir, name)
+}
+
+func (s *Shared) Get(key string) ([]byte, bool) {
+	data, _, ok := s.GetTouched(key)
+	return data, ok
+}
+
+// GetTouched is like Get, but also reports whether it refreshed the
+// object's mtime: whether this is the first Get of the object, by
+// any process sharing the cache, in SharedTouchInterval.
+func (s *Shared) GetTouched(key string) ([]byte, bool, bool) {
+	file := s.pathFor(key)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("shared cache.get(%q): %s", key, err.Error())
+		}
+		return nil, false, false
+	}
+	touched := false
+	if fi, err := os.Stat(file); err == nil && time.Since(fi.ModTime()) > SharedTouchInterval {
+		now := time.Now()
+		touched = os.Chtimes(file, now, now) == nil
+	}
+	return data, touched, true
+}
+
+func (s *Shared) Put(key string, data []byte) {
+	file := s.pathFor(key)
+	if _, err := os.Stat(file); err == nil {
+		return
+	}
+	if err := writeFile(file, data); err != nil {
+		log.Printf("Error writing to shared cache! path=%s err=%q", file, err.Error())
+		return
+	}
+	if atomic.AddUint64(&s.written, uint64(len(data))) >= s.maxBytes/pruneEvery {
+		atomic.StoreUint64(&s.written, 0)
+		if atomic.CompareAndSwapInt32(&s.pruning, 0, 1) {
+			go func() {
+				defer atomic.StoreInt32(&s.pruning, 0)
+				s.maybePrune()
+			}()
+		}
+	}
+}
+
+// maybePrune prunes the cache, unless another process is pruning it
+// or has done so recently
+func (s *Shared) maybePrune() {
+	lk := flock.New(filepath.Join(s.root, pruneLockFile))
+	locked, err := lk.TryLock()
+	if err != nil || !locked {
+		return
+	}
+	defer lk.Unlock()
+	stamp := filepath.Join(s.root, pruneStampFile)
+	if fi, err := os.Stat(stamp); err == nil && time.Since(fi.ModTime()) < pruneInterval {
+		return
+	}
+	s.prune()
+	ioutil.WriteFile(stamp, nil, 0644)
+}
+
+type sharedObject struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+// prune deletes the least recently used objects until the cache fits
+// in its limit
+func (s *Shared) prune() {
+	var objects []sharedObject
+	var total uint64
+	dirs, _ := ioutil.ReadDir(s.root)
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		ents, _ := ioutil.ReadDir(filepath.Join(s.root, dir.Name()))
+		for _, ent := range ents {
+			if !ent.Mode().IsRegular() {
+				continue
+			}
+			path := filepath.Join(s.root, dir.Name(), ent.Name())
+			if strings.HasPrefix(ent.Name(), tempPrefix) {
+				// Left behind by a crash mid-write,
+				// unless it's still being written
+				if time.Since(ent.ModTime()) > SharedTouchInterval {
+					os.Remove(path)
+				}
+				continue
+			}
+			objects = append(objects, sharedObject{path: path, size: ent.Size(), mtime: ent.ModTime()})
+			total += uint64(ent.Size())
+		}
+	}
+	if total <= s.maxBytes {
+		return
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].mtime.Before(objects[j].mtime)
+	})
+	target := uint64(float64(s.maxBytes) * pruneLowWater)
+	var removed int
+	for _, obj := range objects {
+		if total <= target {
+			break
+		}
+		if err := os.Remove(obj.path); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		total -= uint64(obj.size)
+		removed++
+	}
+	log.Printf("shared cache: pruned %d objects, leaving %d bytes", removed, total)
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/store/internal/storeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShared(t *testing.T) {
+	dir := t.TempDir()
+	one := NewShared(dir, 1<<20)
+	two := NewShared(dir, 1<<20)
+
+	idA := storeutil.HashObject([]byte(fileA))
+
+	got, ok := one.Get(idA)
+	assert.False(t, ok)
+	assert.Nil(t, got)
+
+	one.Put(idA, []byte(fileA))
+	got, ok = one.Get(idA)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(fileA), got)
+
+	// Objects written by one process are visible to every other
+	// process sharing the directory
+	got, ok = two.Get(idA)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(fileA), got)
+
+	// Writing an object twice is harmless
+	two.Put(idA, []byte(fileA))
+	got, ok = one.Get(idA)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(fileA), got)
+
+	// No temporary files are left behind
+	ents, err := ioutil.ReadDir(filepath.Join(dir, idA[:2]))
+	assert.NoError(t, err)
+	assert.Len(t, ents, 1)
+}
+
+// putAged writes objects to `dir` last used an hour apart, oldest
+// first, and returns their IDs
+func putAged(t *testing.T, dir string, objs ...string) []string {
+	writer := NewShared(dir, 1<<30)
+	var ids []string
+	for i, obj := range objs {
+		id := storeutil.HashObject([]byte(obj))
+		writer.Put(id, []byte(obj))
+		used := time.Now().Add(time.Duration(i-len(objs)) * time.Hour)
+		require.NoError(t, os.Chtimes(writer.pathFor(id), used, used))
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestSharedPrune(t *testing.T) {
+	dir := t.TempDir()
+	obj := func(c byte) string { return strings.Repeat(string(c), 40) }
+	ids := putAged(t, dir, obj('a'), obj('b'), obj('c'), obj('d'), obj('e'))
+
+	// Using an object makes it recently used
+	s := NewShared(dir, 100)
+	_, touched, ok := s.GetTouched(ids[0])
+	assert.True(t, ok)
+	assert.True(t, touched)
+	_, touched, _ = NewShared(dir, 100).GetTouched(ids[0])
+	assert.False(t, touched, "another process used it just now")
+
+	// Prune to 90% of the limit, least recently used first
+	s.prune()
+	for i, want := range []bool{true, false, false, false, true} {
+		_, err := os.Stat(s.pathFor(ids[i]))
+		assert.Equal(t, want, err == nil, "object %d", i)
+	}
+}
+
+func TestSharedPruneInterval(t *testing.T) {
+	dir := t.TempDir()
+	ids := putAged(t, dir, strings.Repeat("a", 80), strings.Repeat("b", 80))
+	s := NewShared(dir, 100)
+
+	// Another process pruned recently
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, pruneStampFile), nil, 0644))
+	s.maybePrune()
+	_, err := os.Stat(s.pathFor(ids[0]))
+	assert.NoError(t, err)
+
+	old := time.Now().Add(-2 * pruneInterval)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, pruneStampFile), old, old))
+	s.maybePrune()
+	_, err = os.Stat(s.pathFor(ids[0]))
+	assert.True(t, os.IsNotExist(err))
+	fi, err := os.Stat(filepath.Join(dir, pruneStampFile))
+	require.NoError(t, err)
+	assert.True(t, fi.ModTime().After(old))
+}
+
+func TestSharedPutPrunes(t *testing.T) {
+	dir := t.TempDir()
+	s := NewShared(dir, 100)
+	for c := byte('a'); c < 'f'; c++ {
+		obj := strings.Repeat(string(c), 40)
+		s.Put(storeutil.HashObject([]byte(obj)), []byte(obj))
+	}
+	assert.Eventually(t, func() bool {
+		var total int64
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") {
+				total += info.Size()
+			}
+			return nil
+		})
+		return total <= 100
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,267 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encstore wraps a llama store to encrypt everything written
+// to it, so that the underlying storage only ever sees ciphertext.
+//
+// Objects are encrypted deterministically, with a nonce derived from
+// a MAC of their contents, so that identical objects still share an
+// ID and content-addressing and result caching continue to work.
+// This reveals which objects are identical, but nothing else about
+// their contents. Values stored under keys are not content-addressed,
+// and use random nonces.
+package encstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+)
+
+// KeySize is the size of the key objects are encrypted with
+const KeySize = 32
+
+// Every encrypted blob starts with this header, followed by the
+// nonce and the AES-GCM sealed, zstd-compressed plaintext
+var magic = []byte("LLE\x01")
+
+var ErrNotEncrypted = errors.New("object is not encrypted")
+
+type Store struct {
+	inner    store.Store
+	aead     cipher.AEAD
+	nonceKey []byte
+
+	encode *zstd.Encoder
+	decode *zstd.Decoder
+}
+
+var _ store.Store = &Store{}
+var _ store.KeyValue = &Store{}
+var _ store.Collectable = &Store{}
+var _ store.Indexer = &Store{}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// New returns a Store which encrypts objects with `key` before
+// storing them in `inner`. Since we encrypt objects, the inner store
+// can't usefully compress them; we compress them before encrypting
+// instead.
+func New(inner store.Store, key []byte) (*Store, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(deriveKey(key, "llama object encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		inner:    inner,
+		aead:     aead,
+		nonceKey: deriveKey(key, "llama object nonce"),
+		encode:   enc,
+		decode:   dec,
+	}, nil
+}
+
+func (s *Store) seal(nonce []byte, plaintext []byte) []byte {
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plaintext)+s.aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return s.aead.Seal(out, nonce, s.encode.EncodeAll(plaintext, nil), magic)
+}
+
+func (s *Store) open(blob []byte) ([]byte, error) {
+	ns := s.aead.NonceSize()
+	if len(blob) < len(magic)+ns || !bytes.Equal(blob[:len(magic)], magic) {
+		return nil, ErrNotEncrypted
+	}
+	nonce := blob[len(magic) : len(magic)+ns]
+	compressed, err := s.aead.Open(nil, nonce, blob[len(magic)+ns:], magic)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+	return s.decode.DecodeAll(compressed, nil)
+}
+
+// sealObject encrypts an object deterministically, so that identical
+// objects produce identical ciphertext.
+func (s *Store) sealObject(obj []byte) []byte {
+	mac := hmac.New(sha256.New, s.nonceKey)
+	mac.Write(obj)
+	nonce := mac.Sum(nil)[:s.aead.NonceSize()]
+	return s.seal(nonce, obj)
+}
+
+func (s *Store) Store(ctx context.Context, obj []byte) (string, error) {
+	return s.inner.Store(ctx, s.sealObject(obj))
+}
+
+func (s *Store) GetObjects(ctx context.Context, gets []store.GetRequest) {
+	s.inner.GetObjects(ctx, gets)
+	for i := range gets {
+		if gets[i].Err != nil {
+			continue
+		}
+		data, err := s.open(gets[i].Data)
+		if err != nil {
+			gets[i].Data, gets[i].Err = nil, fmt.Errorf("%s: %w", gets[i].Id, err)
+			continue
+		}
+		gets[i].Data = data
+	}
+}
+
+func (s *Store) FetchAWSUsage(u *protocol.UsageMetrics) {
+	s.inner.FetchAWSUsage(u)
+}
+
+var errUnsupported = errors.New("underlying store does not support this operation")
+
+func (s *Store) GetKey(ctx context.Context, key string) ([]byte, error) {
+	kv, ok := s.inner.(store.KeyValue)
+	if !ok {
+		return nil, errUnsupported
+	}
+	blob, err := kv.GetKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(blob)
+}
+
+func (s *Store) SetKey(ctx context.Context, key string, value []byte) error {
+	kv, ok := s.inner.(store.KeyValue)
+	if !ok {
+		return errUnsupported
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return kv.SetKey(ctx, key, s.seal(nonce, value))
+}
+
+func (s *Store) ObjectID(obj []byte) (string, error) {
+	insp, ok := s.inner.(store.Inspectable)
+	if !ok {
+		return "", errUnsupported
+	}
+	return insp.ObjectID(s.sealObject(obj))
+}
+
+func (s *Store) StatObject(ctx context.Context, id string) (store.ObjectInfo, error) {
+	insp, ok := s.inner.(store.Inspectable)
+	if !ok {
+		return store.ObjectInfo{}, errUnsupported
+	}
+	return insp.StatObject(ctx, id)
+}
+
+// HasObjects checks the underlying store directly, since we store
+// objects under the IDs of their ciphertext.
+func (s *Store) HasObjects(ctx context.Context, ids []string) ([]bool, error) {
+	checker, ok := s.inner.(store.ExistenceChecker)
+	if !ok {
+		return nil, errUnsupported
+	}
+	return checker.HasObjects(ctx, ids)
+}
+
+func (s *Store) collectable() (store.Collectable, error) {
+	coll, ok := s.inner.(store.Collectable)
+	if !ok {
+		return nil, errUnsupported
+	}
+	return coll, nil
+}
+
+func (s *Store) ListObjects(ctx context.Context, cb func(store.ObjectInfo) error) error {
+	coll, err := s.collectable()
+	if err != nil {
+		return err
+	}
+	return coll.ListObjects(ctx, cb)
+}
+
+func (s *Store) ListKeys(ctx context.Context, prefix string, cb func(store.ObjectInfo) error) error {
+	coll, err := s.collectable()
+	if err != nil {
+		return err
+	}
+	return coll.ListKeys(ctx, prefix, cb)
+}
+
+func (s *Store) DeleteObjects(ctx context.Context, ids []string) error {
+	coll, err := s.collectable()
+	if err != nil {
+		return err
+	}
+	return coll.DeleteObjects(ctx, ids)
+}
+
+func (s *Store) DeleteKeys(ctx context.Context, keys []string) error {
+	coll, err := s.collectable()
+	if err != nil {
+		return err
+	}
+	return coll.DeleteKeys(ctx, keys)
+}
+
+func (s *Store) HasIndex() bool {
+	idx, ok := s.inner.(store.Indexer)
+	return ok && idx.HasIndex()
+}
+
+func (s *Store) AddRefs(ctx context.Context, ids []string, delta int64) error {
+	idx, ok := s.inner.(store.Indexer)
+	if !ok {
+		return errUnsupported
+	}
+	return idx.AddRefs(ctx, ids, delta)
+}
+
+func (s *Store) Reindex(ctx context.Context) (int, error) {
+	idx, ok := s.inner.(store.Indexer)
+	if !ok {
+		return 0, errUnsupported
+	}
+	return idx.Reindex(ctx)
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	inner := store.InMemory()
+	st, err := New(inner, testKey(1))
+	require.NoError(t, err)
+
+	secret := []byte("int main() { return SECRET; }\n")
+	id, err := st.Store(ctx, secret)
+	require.NoError(t, err)
+
+	// The inner store only sees ciphertext
+	raw, err := store.Get(ctx, inner, id)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("SECRET")))
+
+	got, err := store.Get(ctx, st, id)
+	require.NoError(t, err)
+	assert.Equal(t, secret, got)
+
+	// Identical objects get identical IDs, so that
+	// content-addressing still works
+	again, err := st.Store(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+	other, err := st.Store(ctx, []byte("something else"))
+	require.NoError(t, err)
+	assert.NotEqual(t, id, other)
+
+	// A different key can't read it
+	wrong, err := New(inner, testKey(2))
+	require.NoError(t, err)
+	_, err = store.Get(ctx, wrong, id)
+	assert.Error(t, err)
+
+	// Nor can we read unencrypted objects
+	plainId, err := inner.Store(ctx, secret)
+	require.NoError(t, err)
+	_, err = store.Get(ctx, st, plainId)
+	assert.True(t, errors.Is(err, ErrNotEncrypted))
+}
+
+func TestKeyValue(t *testing.T) {
+	ctx := context.Background()
+	inner := store.InMemory()
+	st, err := New(inner, testKey(1))
+	require.NoError(t, err)
+
+	require.NoError(t, st.SetKey(ctx, "results/k", []byte("SECRET")))
+	raw, err := inner.(store.KeyValue).GetKey(ctx, "results/k")
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("SECRET")))
+	got, err := st.GetKey(ctx, "results/k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("SECRET"), got)
+
+	_, err = st.GetKey(ctx, "missing")
+	assert.Equal(t, store.ErrNotExists, err)
+}
+
+func TestLoadKey(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	keyPath := path.Join(dir, "store.key")
+
+	spec, err := GenerateKeyFile(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, "file:"+keyPath, spec)
+	_, err = GenerateKeyFile(keyPath)
+	assert.Error(t, err, "must not overwrite an existing key")
+
+	key, err := LoadKey(ctx, nil, spec)
+	require.NoError(t, err)
+	assert.Len(t, key, KeySize)
+
+	remote, err := ForFunction(spec)
+	require.NoError(t, err)
+	assert.Equal(t, "raw:"+base64.StdEncoding.EncodeToString(key), remote)
+	rawKey, err := LoadKey(ctx, nil, remote)
+	require.NoError(t, err)
+	assert.Equal(t, key, rawKey)
+
+	kms := "kms:AQID"
+	same, err := ForFunction(kms)
+	require.NoError(t, err)
+	assert.Equal(t, kms, same)
+
+	require.NoError(t, ioutil.WriteFile(keyPath+".short", []byte("AQID\n"), 0600))
+	for _, bad := range []string{"nope", "raw:!!", "raw:AQID", "file:" + keyPath + ".short", "gpg:foo"} {
+		_, err := LoadKey(ctx, nil, bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestObjectID(t *testing.T) {
+	ctx := context.Background()
+	st, err := New(store.InMemory(), testKey(1))
+	require.NoError(t, err)
+
+	obj := []byte("int main() {}\n")
+	want, err := st.ObjectID(obj)
+	require.NoError(t, err)
+	_, err = st.StatObject(ctx, want)
+	assert.Equal(t, store.ErrNotExists, err)
+
+	id, err := st.Store(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, want, id)
+	info, err := st.StatObject(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, info.Id)
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// Keys are described by a string of one of the forms:
+//
+//   kms:BASE64  A data key encrypted by AWS KMS. Decrypting it
+//               requires kms:Decrypt on the KMS key.
+//   file:PATH   A local file containing the base64-encoded key
+//   raw:BASE64  The key itself
+//
+// Functions can't read the local files, so they are given keys from
+// files as `raw:` keys; see ForFunction.
+
+func decodeKey(b64 string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// LoadKey returns the key described by `spec`, using `sess` to talk
+// to KMS if necessary.
+func LoadKey(ctx context.Context, sess client.ConfigProvider, spec string) ([]byte, error) {
+	colon := strings.IndexRune(spec, ':')
+	if colon < 0 {
+		return nil, fmt.Errorf("bad key %q: expected kms:, file:, or raw:", spec)
+	}
+	kind, val := spec[:colon], spec[colon+1:]
+	switch kind {
+	case "kms":
+		blob, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			return nil, fmt.Errorf("kms key: %w", err)
+		}
+		out, err := kms.New(sess).DecryptWithContext(ctx, &kms.DecryptInput{CiphertextBlob: blob})
+		if err != nil {
+			return nil, fmt.Errorf("kms: decrypting data key: %w", err)
+		}
+		return out.Plaintext, nil
+	case "file":
+		data, err := ioutil.ReadFile(val)
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", val, err)
+		}
+		return key, nil
+	case "raw":
+		return decodeKey(val)
+	default:
+		return nil, fmt.Errorf("bad key %q: unknown kind %q", spec, kind)
+	}
+}
+
+// ForFunction returns the description of the key in `spec` to give
+// to functions, which can't read local files.
+func ForFunction(spec string) (string, error) {
+	if !strings.HasPrefix(spec, "file:") {
+		return spec, nil
+	}
+	key, err := LoadKey(context.Background(), nil, spec)
+	if err != nil {
+		return "", err
+	}
+	return "raw:" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// GenerateKMSKey generates a new data key under the KMS key `keyId`,
+// and returns its description.
+func GenerateKMSKey(ctx context.Context, sess client.ConfigProvider, keyId string) (string, error) {
+	out, err := kms.New(sess).GenerateDataKeyWithoutPlaintextWithContext(ctx, &kms.GenerateDataKeyWithoutPlaintextInput{
+		KeyId:         aws.String(keyId),
+		NumberOfBytes: aws.Int64(KeySize),
+	})
+	if err != nil {
+		return "", fmt.Errorf("kms: generating data key: %w", err)
+	}
+	return "kms:" + base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
+
+// GenerateKeyFile writes a new random key to `path`, which must not
+// exist, and returns its description.
+func GenerateKeyFile(path string) (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+	if err := writeNew(path, []byte(encoded)); err != nil {
+		return "", err
+	}
+	return "file:" + path, nil
+}
+
+func writeNew(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
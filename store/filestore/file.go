@@ -0,0 +1,41 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filestore implements a llama store in a local directory,
+// addressed as file:///PATH. It is intended for use with local
+// functions, which need no network access at all.
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/store/blobstore"
+)
+
+func init() {
+	store.RegisterBackend("file", Open)
+}
+
+func Open(ctx context.Context, u *url.URL) (store.Store, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("file: %q: remote hosts are not supported", u.String())
+	}
+	if u.Path == "" {
+		return nil, fmt.Errorf("file: %q: missing path", u.String())
+	}
+	return blobstore.New(&blobstore.DirBucket{Root: u.Path}, ""), nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcsstore implements a llama store in Google Cloud Storage,
+// addressed as gs://BUCKET/PATH. It authenticates using Google's
+// Application Default Credentials.
+package gcsstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/store/blobstore"
+	"golang.org/x/oauth2/google"
+)
+
+const scope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+var endpoint = &url.URL{Scheme: "https", Host: "storage.googleapis.com"}
+
+func init() {
+	store.RegisterBackend("gs", Open)
+}
+
+func Open(ctx context.Context, u *url.URL) (store.Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("gs: %q: missing bucket", u.String())
+	}
+	client, err := google.DefaultClient(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("gs: finding credentials: %w", err)
+	}
+	return newStore(client, endpoint, u.Host, u.Path), nil
+}
+
+// newStore returns a store in `bucket` under `prefix`, using GCS's
+// XML API at `endpoint`.
+func newStore(client *http.Client, endpoint *url.URL, bucket, prefix string) *blobstore.Store {
+	return blobstore.New(&blobstore.HTTPBucket{
+		Client: client,
+		URL: func(key string) string {
+			return (&url.URL{
+				Scheme: endpoint.Scheme,
+				Host:   endpoint.Host,
+				Path:   "/" + bucket + "/" + key,
+			}).String()
+		},
+	}, prefix)
+}
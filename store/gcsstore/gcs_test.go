@@ -0,0 +1,85 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsstore
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// fakeGCS serves objects in the manner of GCS's XML API, recording
+// the requests it sees
+type fakeGCS struct {
+	sync.Mutex
+	objects  map[string][]byte
+	requests []*http.Request
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, r)
+	switch r.Method {
+	case "GET", "HEAD":
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+	}
+}
+
+func TestStore(t *testing.T) {
+	gcs := &fakeGCS{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(gcs)
+	defer srv.Close()
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}))
+	st := newStore(client, endpoint, "bucket", "/llama/")
+
+	_, err = st.GetKey(ctx, "missing")
+	assert.Equal(t, store.ErrNotExists, err)
+
+	require.NoError(t, st.SetKey(ctx, "dir/key", []byte("value")))
+	assert.Equal(t, []byte("value"), gcs.objects["/bucket/llama/keys/dir/key"])
+	got, err := st.GetKey(ctx, "dir/key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), got)
+
+	id, err := st.Store(ctx, []byte("hello"))
+	require.NoError(t, err)
+	assert.Contains(t, gcs.objects, "/bucket/llama/"+id)
+
+	for _, r := range gcs.requests {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"), "%s %s", r.Method, r.URL)
+	}
+}
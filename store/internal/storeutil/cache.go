@@ -14,7 +14,10 @@
 
 package storeutil
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type entry struct {
 	wait chan struct{}
@@ -24,11 +27,20 @@ type entry struct {
 type Cache struct {
 	sync.Mutex
 	seen map[string]*entry
+
+	index *Index
+
+	// Objects a batched check just found missing, so that storing
+	// them needn't check again
+	absent map[string]struct{}
 }
 
 type UploadHandle struct {
 	ent      *entry
 	resolved bool
+
+	id    string
+	index *Index
 }
 
 func (u *UploadHandle) Complete() {
@@ -37,6 +49,16 @@ func (u *UploadHandle) Complete() {
 	close(u.ent.wait)
 }
 
+// Confirm completes the upload, and records in the cache's index, if
+// any, that the object was written to the store at modified.
+func (u *UploadHandle) Confirm(modified time.Time) error {
+	u.Complete()
+	if u.index == nil {
+		return nil
+	}
+	return u.index.Add(u.id, modified)
+}
+
 func (u *UploadHandle) Rollback() {
 	if u.resolved {
 		return
@@ -51,7 +73,7 @@ func (c *Cache) HasObject(id string) bool {
 	ent, ok := c.seen[id]
 	c.Unlock()
 	if !ok {
-		return false
+		return c.index != nil && c.index.Contains(id)
 	}
 	<-ent.wait
 	return ent.ok
@@ -65,5 +87,31 @@ func (c *Cache) StartUpload(id string) UploadHandle {
 	}
 	ent := &entry{wait: make(chan struct{})}
 	c.seen[id] = ent
-	return UploadHandle{ent: ent}
+	return UploadHandle{ent: ent, id: id, index: c.index}
+}
+
+// MarkAbsent records that the store was just found not to hold `id`.
+func (c *Cache) MarkAbsent(id string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.absent == nil {
+		c.absent = make(map[string]struct{})
+	}
+	c.absent[id] = struct{}{}
+}
+
+// TakeAbsent reports whether `id` was marked absent, and forgets it,
+// so that only the next upload of the object skips checking for it.
+func (c *Cache) TakeAbsent(id string) bool {
+	c.Lock()
+	defer c.Unlock()
+	_, ok := c.absent[id]
+	delete(c.absent, id)
+	return ok
+}
+
+// SetIndex backs the cache with a persistent index. It must be called
+// before the cache is used.
+func (c *Cache) SetIndex(idx *Index) {
+	c.index = idx
 }
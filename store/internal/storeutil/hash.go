@@ -16,12 +16,94 @@ package storeutil
 
 import (
 	"encoding/hex"
+	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/blake2b"
+	"lukechampine.com/blake3"
 )
 
-func HashObject(obj []byte) string {
+// A Hash is an algorithm for deriving an object's ID from its
+// contents. An ID says which hash produced it, so a store can hold,
+// and verify, objects hashed either way: switching a store's hash
+// only means that new objects are written under new IDs.
+type Hash string
+
+const (
+	// BLAKE2b-256, with IDs of bare hex digests. Every object
+	// was hashed this way before the hash was configurable.
+	HashBLAKE2b Hash = "blake2b"
+	// BLAKE3, which is several times faster on large objects,
+	// with IDs prefixed by "b3-"
+	HashBLAKE3 Hash = "blake3"
+
+	DefaultHash = HashBLAKE2b
+)
+
+const blake3Prefix = "b3-"
+
+// ParseHash parses the name of a hash; the empty string selects
+// DefaultHash
+func ParseHash(name string) (Hash, error) {
+	switch Hash(name) {
+	case "":
+		return DefaultHash, nil
+	case HashBLAKE2b, HashBLAKE3:
+		return Hash(name), nil
+	}
+	return "", fmt.Errorf("unknown hash %q (supported: %s, %s)", name, HashBLAKE2b, HashBLAKE3)
+}
+
+// Sum returns the ID of `obj` under `h`
+func (h Hash) Sum(obj []byte) string {
+	if h == HashBLAKE3 {
+		csum := blake3.Sum256(obj)
+		return blake3Prefix + hex.EncodeToString(csum[:])
+	}
 	csum := blake2b.Sum256(obj)
-	id := hex.EncodeToString(csum[:])
-	return id
+	return hex.EncodeToString(csum[:])
+}
+
+// HashOf returns the hash which produced `id`, which may carry an
+// encoding suffix like ":zstd"
+func HashOf(id string) Hash {
+	if strings.HasPrefix(id, blake3Prefix) {
+		return HashBLAKE3
+	}
+	return HashBLAKE2b
+}
+
+// ShardPath returns the directory an on-disk cache keeps the object
+// `id` in, and its name there. Objects are spread across directories
+// by the first byte of their digest, skipping the prefix naming their
+// hash, which would otherwise put every BLAKE3 object in one
+// directory.
+func ShardPath(id string) (dir, name string) {
+	prefix := ""
+	if HashOf(id) == HashBLAKE3 {
+		prefix = blake3Prefix
+	}
+	digest := id[len(prefix):]
+	return digest[:2], prefix + digest[2:]
+}
+
+// ShardID is the inverse of ShardPath
+func ShardID(dir, name string) string {
+	if strings.HasPrefix(name, blake3Prefix) {
+		return blake3Prefix + dir + name[len(blake3Prefix):]
+	}
+	return dir + name
+}
+
+// HashObject returns the ID of `obj` under DefaultHash
+func HashObject(obj []byte) string {
+	return DefaultHash.Sum(obj)
+}
+
+// CheckHash checks `obj` against `hash`, an ID without any encoding
+// suffix, under whichever hash produced it. It returns the ID `obj`
+// actually has.
+func CheckHash(hash string, obj []byte) (string, bool) {
+	got := HashOf(hash).Sum(obj)
+	return got, got == hash
 }
@@ -0,0 +1,95 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storeutil
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+	"lukechampine.com/blake3"
+)
+
+func TestBLAKE3(t *testing.T) {
+	// From the test vectors published with the BLAKE3 reference
+	// implementation, whose inputs repeat the bytes 0..250
+	vectors := []struct {
+		len  int
+		hash string
+	}{
+		{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+		{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+	}
+	for _, v := range vectors {
+		input := make([]byte, v.len)
+		for i := range input {
+			input[i] = byte(i % 251)
+		}
+		sum := blake3.Sum256(input)
+		assert.Equal(t, v.hash, hex.EncodeToString(sum[:]), "len=%d", v.len)
+	}
+}
+
+func TestHash(t *testing.T) {
+	obj := []byte("int main() { return 0; }\n")
+	b2 := blake2b.Sum256(obj)
+	assert.Equal(t, hex.EncodeToString(b2[:]), HashObject(obj))
+
+	id := HashBLAKE3.Sum(obj)
+	assert.Equal(t, "b3-", id[:3])
+	assert.Equal(t, HashBLAKE3, HashOf(id+":zstd"))
+	assert.Equal(t, HashBLAKE2b, HashOf(HashObject(obj)))
+
+	for _, h := range []Hash{HashBLAKE2b, HashBLAKE3} {
+		got, ok := CheckHash(h.Sum(obj), obj)
+		assert.True(t, ok, "%s", h)
+		assert.Equal(t, h.Sum(obj), got)
+		_, ok = CheckHash(h.Sum(obj), []byte("corrupt"))
+		assert.False(t, ok, "%s", h)
+	}
+
+	h, err := ParseHash("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultHash, h)
+	h, err = ParseHash("blake3")
+	require.NoError(t, err)
+	assert.Equal(t, HashBLAKE3, h)
+	_, err = ParseHash("md5")
+	assert.Error(t, err)
+}
+
+func TestShardPath(t *testing.T) {
+	obj := []byte("int main() { return 0; }\n")
+	for _, id := range []string{
+		HashBLAKE2b.Sum(obj),
+		HashBLAKE3.Sum(obj),
+		HashBLAKE3.Sum(obj) + ":zstd",
+	} {
+		dir, name := ShardPath(id)
+		assert.Equal(t, id, ShardID(dir, name), "%s", id)
+	}
+
+	dir, name := ShardPath("0123abcd")
+	assert.Equal(t, "01", dir)
+	assert.Equal(t, "23abcd", name)
+	dir, name = ShardPath("b3-0123abcd")
+	assert.Equal(t, "01", dir)
+	assert.Equal(t, "b3-23abcd", name)
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storeutil
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIndexTTL is how long an Index trusts that an object is
+// still in the store. It must stay well under the age at which `llama
+// gc` deletes objects.
+const DefaultIndexTTL = 7 * 24 * time.Hour
+
+// An Index is an on-disk record of objects known to be present in a
+// store, so that a new process need not re-check or re-upload them.
+// Each object is recorded with the time it was last written to the
+// store, and is forgotten once that is more than the TTL ago.
+//
+// The file is append-only between compactions, which happen when it
+// is opened. Several processes may share a file; an append that
+// races with another process's compaction is lost, which costs only
+// a redundant upload later.
+type Index struct {
+	mu  sync.Mutex
+	f   *os.File
+	ttl time.Duration
+	now func() time.Time
+	ids map[string]time.Time
+}
+
+// OpenIndex opens or creates the index at path, discarding entries
+// older than ttl.
+func OpenIndex(path string, ttl time.Duration) (*Index, error) {
+	if ttl <= 0 {
+		ttl = DefaultIndexTTL
+	}
+	idx := &Index{ttl: ttl, now: time.Now, ids: make(map[string]time.Time)}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := idx.load(path); err != nil {
+		return nil, err
+	}
+	// Compaction is best-effort: it can fail if another process
+	// has the file open on Windows, and we'll retry next time.
+	_ = idx.compact(path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	idx.f = f
+	return idx, nil
+}
+
+func (idx *Index) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	now := idx.now()
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		fields := strings.Fields(scan.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		at := time.Unix(sec, 0)
+		if now.Sub(at) >= idx.ttl {
+			continue
+		}
+		if at.After(idx.ids[fields[1]]) {
+			idx.ids[fields[1]] = at
+		}
+	}
+	return scan.Err()
+}
+
+func (idx *Index) compact(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for id, at := range idx.ids {
+		fmt.Fprintf(w, "%d %s\n", at.Unix(), id)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Contains reports whether id was written to the store less than the
+// TTL ago.
+func (idx *Index) Contains(id string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	at, ok := idx.ids[id]
+	return ok && idx.now().Sub(at) < idx.ttl
+}
+
+// Add records that id was written to the store at time at.
+func (idx *Index) Add(id string, at time.Time) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.now().Sub(at) >= idx.ttl || !at.After(idx.ids[id]) {
+		return nil
+	}
+	idx.ids[id] = at
+	_, err := fmt.Fprintf(idx.f, "%d %s\n", at.Unix(), id)
+	return err
+}
+
+// Close closes the index's file.
+func (idx *Index) Close() error {
+	return idx.f.Close()
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storeutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uploaded", "index")
+	now := time.Now()
+
+	idx, err := OpenIndex(path, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, idx.Contains("a"))
+
+	require.NoError(t, idx.Add("a", now))
+	require.NoError(t, idx.Add("b", now.Add(-30*time.Minute)))
+	require.NoError(t, idx.Add("old", now.Add(-2*time.Hour)))
+	assert.True(t, idx.Contains("a"))
+	assert.True(t, idx.Contains("b"))
+	assert.False(t, idx.Contains("old"))
+	require.NoError(t, idx.Close())
+
+	idx, err = OpenIndex(path, time.Hour)
+	require.NoError(t, err)
+	defer idx.Close()
+	assert.True(t, idx.Contains("a"))
+	assert.True(t, idx.Contains("b"))
+	assert.False(t, idx.Contains("old"))
+
+	idx.now = func() time.Time { return now.Add(45 * time.Minute) }
+	assert.True(t, idx.Contains("a"))
+	assert.False(t, idx.Contains("b"), "entries expire")
+
+	// A later write renews an entry
+	require.NoError(t, idx.Add("b", now.Add(45*time.Minute)))
+	assert.True(t, idx.Contains("b"))
+}
+
+func TestIndexCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	now := time.Now().Unix()
+	stale := now - 3600*24*30
+	contents := strings.Join([]string{
+		itoa(stale) + " stale",
+		itoa(now) + " a",
+		"garbage",
+		itoa(now-10) + " a",
+		itoa(now) + " b",
+	}, "\n") + "\n"
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+
+	idx, err := OpenIndex(path, 0)
+	require.NoError(t, err)
+	defer idx.Close()
+	assert.False(t, idx.Contains("stale"))
+	assert.True(t, idx.Contains("a"))
+	assert.True(t, idx.Contains("b"))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.ElementsMatch(t, []string{itoa(now) + " a", itoa(now) + " b"}, lines)
+}
+
+func TestCacheIndex(t *testing.T) {
+	idx, err := OpenIndex(filepath.Join(t.TempDir(), "index"), 0)
+	require.NoError(t, err)
+	defer idx.Close()
+	require.NoError(t, idx.Add("persisted", time.Now()))
+
+	var cache Cache
+	cache.SetIndex(idx)
+	assert.True(t, cache.HasObject("persisted"))
+	assert.False(t, cache.HasObject("new"))
+
+	u := cache.StartUpload("new")
+	u.Complete()
+	assert.True(t, cache.HasObject("new"))
+	assert.False(t, idx.Contains("new"), "only confirmed uploads are persisted")
+
+	u = cache.StartUpload("confirmed")
+	require.NoError(t, u.Confirm(time.Now()))
+	assert.True(t, idx.Contains("confirmed"))
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
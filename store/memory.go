@@ -17,23 +17,34 @@ package store
 import (
 	"context"
 	"encoding/hex"
+	"sync"
 
 	"github.com/nelhage/llama/protocol"
 	"golang.org/x/crypto/blake2b"
 )
 
 type inMemory struct {
+	mu      sync.Mutex
 	objects map[string][]byte
+	keys    map[string][]byte
 }
 
-func (s *inMemory) Store(ctx context.Context, obj []byte) (string, error) {
+func (s *inMemory) ObjectID(obj []byte) (string, error) {
 	sha := blake2b.Sum256(obj)
-	id := hex.EncodeToString(sha[:])
+	return hex.EncodeToString(sha[:]), nil
+}
+
+func (s *inMemory) Store(ctx context.Context, obj []byte) (string, error) {
+	id, _ := s.ObjectID(obj)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.objects[id] = append([]byte(nil), obj...)
 	return id, nil
 }
 
 func (s *inMemory) GetObjects(ctx context.Context, gets []GetRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for i := range gets {
 		id := gets[i].Id
 		if got, ok := s.objects[id]; ok {
@@ -44,10 +55,46 @@ func (s *inMemory) GetObjects(ctx context.Context, gets []GetRequest) {
 	}
 }
 
+func (s *inMemory) HasObjects(ctx context.Context, ids []string) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	has := make([]bool, len(ids))
+	for i, id := range ids {
+		_, has[i] = s.objects[id]
+	}
+	return has, nil
+}
+
+func (s *inMemory) StatObject(ctx context.Context, id string) (ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if got, ok := s.objects[id]; ok {
+		return ObjectInfo{Id: id, Size: int64(len(got))}, nil
+	}
+	return ObjectInfo{}, ErrNotExists
+}
+
+func (s *inMemory) GetKey(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if got, ok := s.keys[key]; ok {
+		return append([]byte(nil), got...), nil
+	}
+	return nil, ErrNotExists
+}
+
+func (s *inMemory) SetKey(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = append([]byte(nil), value...)
+	return nil
+}
+
 func (s *inMemory) FetchAWSUsage(u *protocol.UsageMetrics) {}
 
 func InMemory() Store {
 	return &inMemory{
 		objects: make(map[string][]byte),
+		keys:    make(map[string][]byte),
 	}
 }
@@ -0,0 +1,129 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/nelhage/llama/protocol"
+	"github.com/nelhage/llama/store"
+	"github.com/nelhage/llama/store/internal/storeutil"
+	"golang.org/x/sync/errgroup"
+)
+
+// A URLSource presigns requests for a Direct store, typically by
+// asking a process which holds credentials for the store. It returns
+// a request for each of puts and gets, in order; a put request with
+// an empty URL means the store already has that object.
+type URLSource interface {
+	Presign(ctx context.Context, puts, gets []string) ([]store.PresignedRequest, []store.PresignedRequest, error)
+}
+
+// Direct is a Store for processes without AWS credentials, such as
+// llamacc, which transfers objects to and from S3 itself using
+// requests presigned by a URLSource, instead of passing their
+// contents through the daemon. It compresses every object it stores.
+type Direct struct {
+	urls   URLSource
+	client *http.Client
+}
+
+func NewDirect(urls URLSource) *Direct {
+	return &Direct{urls: urls, client: http.DefaultClient}
+}
+
+func (d *Direct) Store(ctx context.Context, obj []byte) (string, error) {
+	id := storeutil.HashObject(obj) + ":zstd"
+	puts, _, err := d.urls.Presign(ctx, []string{id}, nil)
+	if err != nil {
+		return "", err
+	}
+	if puts[0].URL == "" {
+		return id, nil
+	}
+	if _, err := d.do(ctx, http.MethodPut, &puts[0], encode.EncodeAll(obj, nil)); err != nil {
+		return "", fmt.Errorf("storing %s: %w", id, err)
+	}
+	return id, nil
+}
+
+func (d *Direct) GetObjects(ctx context.Context, gets []store.GetRequest) {
+	ids := make([]string, len(gets))
+	for i := range gets {
+		ids[i] = gets[i].Id
+	}
+	_, reqs, err := d.urls.Presign(ctx, nil, ids)
+	if err != nil {
+		for i := range gets {
+			gets[i].Err = err
+		}
+		return
+	}
+
+	var grp errgroup.Group
+	sem := make(chan struct{}, getConcurrency)
+	for i := range gets {
+		i := i
+		sem <- struct{}{}
+		grp.Go(func() error {
+			defer func() { <-sem }()
+			body, err := d.do(ctx, http.MethodGet, &reqs[i], nil)
+			if err == nil {
+				body, err = decodeObject(gets[i].Id, body)
+			}
+			gets[i].Data, gets[i].Err = body, err
+			return nil
+		})
+	}
+	grp.Wait()
+}
+
+// FetchAWSUsage reports nothing, since our requests were signed, and
+// are accounted for, by another process
+func (d *Direct) FetchAWSUsage(u *protocol.UsageMetrics) {}
+
+func (d *Direct) do(ctx context.Context, method string, presigned *store.PresignedRequest, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, presigned.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range presigned.Header {
+		req.Header[k] = vs
+	}
+	req.ContentLength = int64(len(body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && method == http.MethodGet:
+		return nil, store.ErrNotExists
+	case resp.StatusCode/100 != 2:
+		if len(data) > 256 {
+			data = data[:256]
+		}
+		return nil, fmt.Errorf("%s: %s: %q", method, resp.Status, data)
+	}
+	return data, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 serves objects by path, requiring the header fakeURLs signs
+// requests with
+type fakeS3 struct {
+	sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Signed") != "yes" {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	id := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		f.objects[id] = body
+		f.puts++
+	case http.MethodGet:
+		body, ok := f.objects[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}
+}
+
+type fakeURLs struct {
+	base string
+	s3   *fakeS3
+}
+
+func (f *fakeURLs) sign(id string) store.PresignedRequest {
+	return store.PresignedRequest{
+		URL:    f.base + "/" + id,
+		Header: http.Header{"X-Signed": []string{"yes"}},
+	}
+}
+
+func (f *fakeURLs) Presign(ctx context.Context, puts, gets []string) ([]store.PresignedRequest, []store.PresignedRequest, error) {
+	var putReqs, getReqs []store.PresignedRequest
+	for _, id := range puts {
+		f.s3.Lock()
+		_, ok := f.s3.objects[id]
+		f.s3.Unlock()
+		if ok {
+			putReqs = append(putReqs, store.PresignedRequest{})
+		} else {
+			putReqs = append(putReqs, f.sign(id))
+		}
+	}
+	for _, id := range gets {
+		getReqs = append(getReqs, f.sign(id))
+	}
+	return putReqs, getReqs, nil
+}
+
+func TestDirect(t *testing.T) {
+	ctx := context.Background()
+	s3 := &fakeS3{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+	d := NewDirect(&fakeURLs{base: srv.URL, s3: s3})
+
+	obj := []byte(strings.Repeat("hello, direct transfer\n", 100))
+	id, err := d.Store(ctx, obj)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(id, ":zstd"))
+	assert.Less(t, len(s3.objects[id]), len(obj), "objects are compressed")
+
+	// The store already has it
+	id2, err := d.Store(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, id, id2)
+	assert.Equal(t, 1, s3.puts)
+
+	got, err := store.Get(ctx, d, id)
+	require.NoError(t, err)
+	assert.Equal(t, obj, got)
+
+	gets := []store.GetRequest{{Id: id}, {Id: "missing:zstd"}}
+	d.GetObjects(ctx, gets)
+	assert.NoError(t, gets[0].Err)
+	assert.Equal(t, store.ErrNotExists, gets[1].Err)
+
+	// Objects are checked against their IDs
+	s3.objects[id] = encode.EncodeAll([]byte("tampered"), nil)
+	_, err = store.Get(ctx, d, id)
+	assert.Error(t, err)
+}
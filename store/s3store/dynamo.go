@@ -0,0 +1,315 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/nelhage/llama/store"
+)
+
+// dynamoIndex records metadata about a store's objects in a DynamoDB
+// table, keyed by object ID: each object's size, when it was last
+// written and last read, and how many result-cache entries refer to
+// it. The table answers questions that would otherwise take a HEAD
+// per object, or a LIST of the whole bucket.
+//
+// The index is advisory. Objects written before it existed are
+// missing from it until they are next stored, or until `llama store
+// reindex` backfills them, and a failure to update it never fails
+// the operation on S3.
+type dynamoIndex struct {
+	svc   dynamodbiface.DynamoDBAPI
+	table string
+
+	mu      sync.Mutex
+	touched map[string]time.Time
+}
+
+// Index item attributes
+const (
+	attrID       = "id"
+	attrSize     = "size"
+	attrWritten  = "written"
+	attrAccessed = "accessed"
+	attrRefs     = "refs"
+)
+
+// DynamoDB's limits on the items in one BatchGetItem and one
+// BatchWriteItem
+const (
+	batchGetLimit   = 100
+	batchWriteLimit = 25
+)
+
+// touchInterval is how often we record each object being read, so
+// that hot objects don't cost a write on every fetch. It is much
+// finer than any sensible garbage-collection age.
+const touchInterval = time.Hour
+
+// How many times we resubmit the items a batch request leaves
+// unprocessed before giving up
+const batchRetries = 8
+
+func newDynamoIndex(svc dynamodbiface.DynamoDBAPI, table string) *dynamoIndex {
+	return &dynamoIndex{svc: svc, table: table, touched: make(map[string]time.Time)}
+}
+
+// names maps the placeholder "#attr" to each of `attrs`, since
+// several of them are DynamoDB reserved words. DynamoDB rejects
+// placeholders an expression doesn't use.
+func names(attrs ...string) map[string]*string {
+	out := make(map[string]*string, len(attrs))
+	for _, attr := range attrs {
+		out["#"+attr] = aws.String(attr)
+	}
+	return out
+}
+
+func numberAttr(n int64) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(n, 10))}
+}
+
+func numberValue(av *dynamodb.AttributeValue) int64 {
+	if av == nil || av.N == nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(*av.N, 10, 64)
+	return n
+}
+
+func timeValue(av *dynamodb.AttributeValue) time.Time {
+	if n := numberValue(av); n != 0 {
+		return time.Unix(n, 0)
+	}
+	return time.Time{}
+}
+
+func (x *dynamoIndex) key(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{attrID: {S: aws.String(id)}}
+}
+
+func itemInfo(item map[string]*dynamodb.AttributeValue) store.ObjectInfo {
+	return store.ObjectInfo{
+		Id:           aws.StringValue(item[attrID].S),
+		Size:         numberValue(item[attrSize]),
+		LastModified: timeValue(item[attrWritten]),
+		LastAccessed: timeValue(item[attrAccessed]),
+		Refs:         numberValue(item[attrRefs]),
+	}
+}
+
+func conditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// record notes that the object `id`, `size` bytes in S3, was last
+// written at `written` and used just now.
+func (x *dynamoIndex) record(ctx context.Context, id string, size int64, written time.Time) error {
+	now := time.Now()
+	_, err := x.svc.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                &x.table,
+		Key:                      x.key(id),
+		UpdateExpression:         aws.String("SET #size = :size, #written = :written, #accessed = :accessed"),
+		ExpressionAttributeNames: names(attrSize, attrWritten, attrAccessed),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":size":     numberAttr(size),
+			":written":  numberAttr(written.Unix()),
+			":accessed": numberAttr(now.Unix()),
+		},
+	})
+	if err == nil {
+		x.mu.Lock()
+		x.touched[id] = now
+		x.mu.Unlock()
+	}
+	return err
+}
+
+// touch notes that `id` was just read, unless we noted it less than
+// touchInterval ago. Objects missing from the index stay missing,
+// since we don't know their size.
+func (x *dynamoIndex) touch(ctx context.Context, id string) error {
+	now := time.Now()
+	x.mu.Lock()
+	last, ok := x.touched[id]
+	if ok && now.Sub(last) < touchInterval {
+		x.mu.Unlock()
+		return nil
+	}
+	x.touched[id] = now
+	x.mu.Unlock()
+
+	_, err := x.svc.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &x.table,
+		Key:                       x.key(id),
+		UpdateExpression:          aws.String("SET #accessed = :accessed"),
+		ConditionExpression:       aws.String("attribute_exists(" + attrID + ")"),
+		ExpressionAttributeNames:  names(attrAccessed),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":accessed": numberAttr(now.Unix())},
+	})
+	if conditionFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// addRefs adds `delta` to the reference count of each of `ids`
+// which is in the index.
+func (x *dynamoIndex) addRefs(ctx context.Context, ids []string, delta int64) error {
+	for _, id := range ids {
+		_, err := x.svc.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 &x.table,
+			Key:                       x.key(id),
+			UpdateExpression:          aws.String("ADD #refs :delta"),
+			ConditionExpression:       aws.String("attribute_exists(" + attrID + ")"),
+			ExpressionAttributeNames:  names(attrRefs),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":delta": numberAttr(delta)},
+		})
+		if err != nil && !conditionFailed(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns what the index knows about each of `ids`; IDs it
+// has no record of are absent from the result.
+func (x *dynamoIndex) lookup(ctx context.Context, ids []string) (map[string]store.ObjectInfo, error) {
+	found := make(map[string]store.ObjectInfo, len(ids))
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > batchGetLimit {
+			n = batchGetLimit
+		}
+		keys := make([]map[string]*dynamodb.AttributeValue, n)
+		for i, id := range ids[:n] {
+			keys[i] = x.key(id)
+		}
+		ids = ids[n:]
+
+		req := map[string]*dynamodb.KeysAndAttributes{x.table: {Keys: keys}}
+		for attempt := 0; len(req) > 0; attempt++ {
+			if attempt > batchRetries {
+				return nil, fmt.Errorf("dynamodb %s: lookup: too many unprocessed keys", x.table)
+			}
+			if attempt > 0 {
+				backoff(ctx, attempt)
+			}
+			out, err := x.svc.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: req})
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range out.Responses[x.table] {
+				info := itemInfo(item)
+				found[info.Id] = info
+			}
+			req = out.UnprocessedKeys
+		}
+	}
+	return found, nil
+}
+
+// write submits `writes` in batches, resubmitting any that DynamoDB
+// leaves unprocessed.
+func (x *dynamoIndex) write(ctx context.Context, writes []*dynamodb.WriteRequest) error {
+	for len(writes) > 0 {
+		n := len(writes)
+		if n > batchWriteLimit {
+			n = batchWriteLimit
+		}
+		req := map[string][]*dynamodb.WriteRequest{x.table: writes[:n]}
+		writes = writes[n:]
+		for attempt := 0; len(req) > 0; attempt++ {
+			if attempt > batchRetries {
+				return fmt.Errorf("dynamodb %s: write: too many unprocessed items", x.table)
+			}
+			if attempt > 0 {
+				backoff(ctx, attempt)
+			}
+			out, err := x.svc.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: req})
+			if err != nil {
+				return err
+			}
+			req = out.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// remove deletes the records of `ids`
+func (x *dynamoIndex) remove(ctx context.Context, ids []string) error {
+	writes := make([]*dynamodb.WriteRequest, len(ids))
+	for i, id := range ids {
+		writes[i] = &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: x.key(id)}}
+	}
+	x.mu.Lock()
+	for _, id := range ids {
+		delete(x.touched, id)
+	}
+	x.mu.Unlock()
+	return x.write(ctx, writes)
+}
+
+// add creates records for objects, as listed from S3. They count as
+// last read when they were written.
+func (x *dynamoIndex) add(ctx context.Context, objs []store.ObjectInfo) error {
+	writes := make([]*dynamodb.WriteRequest, len(objs))
+	for i, obj := range objs {
+		writes[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{
+			Item: map[string]*dynamodb.AttributeValue{
+				attrID:       {S: aws.String(obj.Id)},
+				attrSize:     numberAttr(obj.Size),
+				attrWritten:  numberAttr(obj.LastModified.Unix()),
+				attrAccessed: numberAttr(obj.LastModified.Unix()),
+			},
+		}}
+	}
+	return x.write(ctx, writes)
+}
+
+// scan calls `cb` with every object in the index
+func (x *dynamoIndex) scan(ctx context.Context, cb func(store.ObjectInfo) error) error {
+	var cbErr error
+	err := x.svc.ScanPagesWithContext(ctx, &dynamodb.ScanInput{TableName: &x.table},
+		func(page *dynamodb.ScanOutput, last bool) bool {
+			for _, item := range page.Items {
+				if cbErr = cb(itemInfo(item)); cbErr != nil {
+					return false
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	return cbErr
+}
+
+func backoff(ctx context.Context, attempt int) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Duration(attempt*attempt) * 25 * time.Millisecond):
+	}
+}
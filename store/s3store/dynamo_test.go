@@ -0,0 +1,175 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"context"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTable implements the part of DynamoDB dynamoIndex uses,
+// understanding just the update expressions it sends
+type fakeTable struct {
+	dynamodbiface.DynamoDBAPI
+
+	sync.Mutex
+	items   map[string]map[string]*dynamodb.AttributeValue
+	updates int
+}
+
+func (f *fakeTable) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.updates++
+	id := aws.StringValue(in.Key[attrID].S)
+	item, ok := f.items[id]
+	if !ok {
+		if in.ConditionExpression != nil {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "no such item", nil)
+		}
+		item = map[string]*dynamodb.AttributeValue{attrID: {S: aws.String(id)}}
+		f.items[id] = item
+	}
+	for placeholder, attr := range in.ExpressionAttributeNames {
+		val := in.ExpressionAttributeValues[":"+placeholder[1:]]
+		if val == nil {
+			val = in.ExpressionAttributeValues[":delta"]
+			val = numberAttr(numberValue(item[*attr]) + numberValue(val))
+		}
+		item[*attr] = val
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (f *fakeTable) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	out := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]*dynamodb.AttributeValue)}
+	for table, req := range in.RequestItems {
+		for _, key := range req.Keys {
+			if item, ok := f.items[aws.StringValue(key[attrID].S)]; ok {
+				out.Responses[table] = append(out.Responses[table], item)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeTable) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	for _, writes := range in.RequestItems {
+		for _, w := range writes {
+			if w.DeleteRequest != nil {
+				delete(f.items, aws.StringValue(w.DeleteRequest.Key[attrID].S))
+			} else {
+				f.items[aws.StringValue(w.PutRequest.Item[attrID].S)] = w.PutRequest.Item
+			}
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (f *fakeTable) ScanPagesWithContext(ctx aws.Context, in *dynamodb.ScanInput, cb func(*dynamodb.ScanOutput, bool) bool, _ ...request.Option) error {
+	f.Lock()
+	var page dynamodb.ScanOutput
+	for _, item := range f.items {
+		page.Items = append(page.Items, item)
+	}
+	f.Unlock()
+	cb(&page, true)
+	return nil
+}
+
+func TestIndex(t *testing.T) {
+	bucket := newFakeBucket()
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+	table := &fakeTable{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	open := func() *Store {
+		st, err := FromSessionAndOptions(sess, "s3://bucket/obj/", Options{
+			Endpoint:         srv.URL,
+			ForcePathStyle:   true,
+			CompressionLevel: -1,
+			DisableHeadCheck: true,
+		})
+		require.NoError(t, err)
+		st.index = newDynamoIndex(table, "llama")
+		return st
+	}
+
+	ctx := context.Background()
+	st := open()
+	newID, err := st.Store(ctx, []byte("new"))
+	require.NoError(t, err)
+	oldID, _ := st.ObjectID([]byte("old"))
+	bucket.modified[path.Join("/bucket/obj", oldID)] = time.Now()
+
+	// A fresh store finds what the first one wrote in the index,
+	// without a HEAD
+	has, err := open().HasObjects(ctx, []string{newID, oldID})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, has)
+	assert.Equal(t, 0, bucket.heads)
+
+	var objs []store.ObjectInfo
+	require.NoError(t, st.ListObjects(ctx, func(info store.ObjectInfo) error {
+		objs = append(objs, info)
+		return nil
+	}))
+	require.Len(t, objs, 1)
+	assert.Equal(t, newID, objs[0].Id)
+	assert.Equal(t, int64(len("new")), objs[0].Size)
+	assert.False(t, objs[0].LastAccessed.IsZero())
+
+	require.NoError(t, st.AddRefs(ctx, []string{newID, oldID}, 2))
+	require.NoError(t, st.AddRefs(ctx, []string{newID}, -1))
+	infos, err := st.index.lookup(ctx, []string{newID, oldID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), infos[newID].Refs)
+	assert.NotContains(t, infos, oldID)
+
+	// Reads are recorded at most once per touchInterval
+	updates := table.updates
+	require.NoError(t, st.index.touch(ctx, newID))
+	assert.Equal(t, updates, table.updates)
+	fresh := open()
+	require.NoError(t, fresh.index.touch(ctx, newID))
+	require.NoError(t, fresh.index.touch(ctx, oldID))
+	assert.Equal(t, updates+2, table.updates)
+	assert.NotContains(t, table.items, oldID)
+
+	require.NoError(t, st.index.remove(ctx, []string{newID}))
+	assert.Empty(t, table.items)
+}
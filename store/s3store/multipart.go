@@ -0,0 +1,83 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/nelhage/llama/tracing"
+)
+
+const (
+	// S3 rejects parts smaller than this, except the last
+	minPartSize = s3manager.MinUploadPartSize
+	// When choosing part sizes ourselves, we don't go beyond this,
+	// so that a slow part doesn't hold up the whole upload
+	maxAutoPartSize        = 64 << 20
+	defaultPartConcurrency = s3manager.DefaultUploadConcurrency
+)
+
+func (s *Store) partConcurrency() int {
+	if s.opts.PartConcurrency > 0 {
+		return s.opts.PartConcurrency
+	}
+	return defaultPartConcurrency
+}
+
+// partSize returns the size of the parts to upload an n-byte object
+// in, or 0 to upload it in a single request. Unless configured
+// otherwise, we split large objects evenly across our connections,
+// so that high-latency links keep several requests in flight.
+func (s *Store) partSize(n int64) int64 {
+	size := s.opts.PartSize
+	if size == 0 {
+		conc := int64(s.partConcurrency())
+		size = (n + conc - 1) / conc
+		if size > maxAutoPartSize {
+			size = maxAutoPartSize
+		}
+	}
+	if size < minPartSize {
+		size = minPartSize
+	}
+	if n <= size {
+		return 0
+	}
+	return size
+}
+
+func (s *Store) uploadParts(ctx context.Context, key *string, body []byte, partSize int64, usage *usageMetrics) error {
+	ctx, span := tracing.StartSpan(ctx, "s3.upload_parts")
+	defer span.End()
+	parts := (int64(len(body)) + partSize - 1) / partSize
+	span.AddField("s3.part_size", partSize)
+	span.AddField("s3.parts", parts)
+
+	uploader := s3manager.NewUploaderWithClient(s.s3, func(u *s3manager.Uploader) {
+		u.PartSize = partSize
+		u.Concurrency = s.partConcurrency()
+	})
+	// Creating and completing the upload are requests too
+	usage.WriteRequests += uint64(parts) + 2
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Body:         bytes.NewReader(body),
+		Bucket:       &s.url.Host,
+		Key:          key,
+		StorageClass: s.storageClass(),
+	})
+	return err
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartSize(t *testing.T) {
+	const mb = 1 << 20
+	cases := []struct {
+		opts Options
+		n    int64
+		want int64
+	}{
+		{Options{}, 0, 0},
+		{Options{}, 5 * mb, 0},
+		{Options{}, 6 * mb, 5 * mb},
+		{Options{}, 50 * mb, 10 * mb},
+		{Options{}, 1000 * mb, 64 * mb},
+		{Options{PartConcurrency: 10}, 50 * mb, 5 * mb},
+		{Options{PartSize: 16 * mb}, 16 * mb, 0},
+		{Options{PartSize: 16 * mb}, 1000 * mb, 16 * mb},
+		{Options{PartSize: mb}, 6 * mb, 5 * mb},
+	}
+	for _, tc := range cases {
+		s := &Store{opts: tc.opts}
+		assert.Equal(t, tc.want, s.partSize(tc.n), "opts=%+v n=%d", tc.opts, tc.n)
+	}
+}
@@ -17,19 +17,24 @@ package s3store
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"net/url"
 	"path"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/klauspost/compress/zstd"
 	"github.com/nelhage/llama/protocol"
@@ -44,6 +49,102 @@ type Options struct {
 	DisableHeadCheck bool
 	DiskCachePath    string
 	DiskCacheBytes   uint64
+	// SharedCachePath names a directory shared with other
+	// processes, such as an EFS mount, to cache objects in behind
+	// the disk cache. It is pruned to SharedCacheBytes, or
+	// diskcache.DefaultSharedBytes if that is zero.
+	SharedCachePath  string
+	SharedCacheBytes uint64
+	// The zstd compression level (1-22) for uploaded objects. Zero
+	// selects the default level, and a negative level disables
+	// compression. Objects record their encoding in their ID, so
+	// stores written with any setting remain readable.
+	CompressionLevel int
+
+	// Endpoint overrides the S3 endpoint URL, for S3-compatible
+	// services such as MinIO or Ceph RGW.
+	Endpoint       string
+	ForcePathStyle bool
+	// InsecureSkipVerify disables TLS certificate verification
+	// when talking to the endpoint
+	InsecureSkipVerify bool
+
+	// Accelerate sends requests through S3 Transfer Acceleration,
+	// which must be enabled on the bucket
+	Accelerate bool
+	// Objects larger than PartSize bytes are uploaded in parts,
+	// PartConcurrency at a time. Zero values select defaults that
+	// adapt to the size of each object; see partSize.
+	PartSize        int64
+	PartConcurrency int
+	// RequestTimeout bounds each HTTP request to S3, including
+	// each part of a multipart upload. Requests that time out are
+	// retried.
+	RequestTimeout time.Duration
+
+	// UploadIndexPath names a file in which to record the objects
+	// this store has written, so that later processes can skip
+	// uploading them again. Records expire after UploadIndexTTL
+	// (default: storeutil.DefaultIndexTTL), which must be shorter
+	// than the age at which objects are garbage-collected.
+	UploadIndexPath string
+	UploadIndexTTL  time.Duration
+
+	// StorageClass, if set, is the S3 storage class for new
+	// objects, such as INTELLIGENT_TIERING. Keys are small and
+	// often rewritten, so they are always STANDARD.
+	StorageClass string
+	// RefreshAge, if non-zero, makes Store rewrite an object which
+	// already exists but was last written more than this long ago,
+	// so that a lifecycle rule which expires objects by age spares
+	// those still in use.
+	RefreshAge time.Duration
+	// TouchAge is how far out of date an object's Last-Modified
+	// time may get while it is in use. Reading an object, or
+	// finding it already stored, which was last modified more
+	// than TouchAge ago copies it onto itself, so that its
+	// Last-Modified time records its last use, and "llama gc"
+	// spares it. Zero selects DefaultTouchAge; negative values
+	// disable touching.
+	TouchAge time.Duration
+
+	// IndexTable names a DynamoDB table, with the string partition
+	// key "id", in which to record the size and last use of each
+	// object. With an index, HasObjects consults it before
+	// falling back to HEAD requests, and ListObjects scans it
+	// rather than listing the bucket. IndexRegion is the table's
+	// region, if not the session's.
+	IndexTable  string
+	IndexRegion string
+
+	// Hash names the hash new objects' IDs are derived from:
+	// "blake2b" (the default) or "blake3". Objects are read and
+	// verified whichever hash named them, so changing it on an
+	// existing store is safe.
+	Hash string
+}
+
+// StorageClasses are the S3 storage classes objects may be written
+// in: those whose objects can be read immediately.
+var StorageClasses = []string{
+	s3.StorageClassStandard,
+	s3.StorageClassIntelligentTiering,
+	s3.StorageClassStandardIa,
+	s3.StorageClassOnezoneIa,
+	s3.StorageClassReducedRedundancy,
+	"GLACIER_IR",
+}
+
+func checkStorageClass(class string) error {
+	if class == "" {
+		return nil
+	}
+	for _, c := range StorageClasses {
+		if c == class {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported storage class %q (supported: %s)", class, strings.Join(StorageClasses, ", "))
 }
 
 type Store struct {
@@ -52,11 +153,18 @@ type Store struct {
 	s3      *s3.S3
 	url     *url.URL
 
-	seen storeutil.Cache
-	disk *diskcache.Cache
+	seen   storeutil.Cache
+	disk   *diskcache.Cache
+	shared *diskcache.Shared
+	encode *zstd.Encoder
 
 	metricsMu sync.Mutex
 	metrics   usageMetrics
+
+	index *dynamoIndex
+	hash  storeutil.Hash
+
+	uses useTracker
 }
 
 type usageMetrics struct {
@@ -83,6 +191,16 @@ func init() {
 	}
 }
 
+func init() {
+	store.RegisterBackend("s3", func(ctx context.Context, u *url.URL) (store.Store, error) {
+		sess, err := session.NewSession()
+		if err != nil {
+			return nil, err
+		}
+		return FromSession(sess, u.String())
+	})
+}
+
 func (s *Store) FetchAWSUsage(u *protocol.UsageMetrics) {
 	s.metricsMu.Lock()
 	defer s.metricsMu.Unlock()
@@ -111,10 +229,40 @@ func FromSessionAndOptions(s *session.Session, address string, opts Options) (*S
 	if e != nil {
 		return nil, fmt.Errorf("Parsing store: %q: %w", address, e)
 	}
+	if e := checkStorageClass(opts.StorageClass); e != nil {
+		return nil, fmt.Errorf("Object store: %q: %w", address, e)
+	}
+	hash, e := storeutil.ParseHash(opts.Hash)
+	if e != nil {
+		return nil, fmt.Errorf("Object store: %q: %w", address, e)
+	}
 	if u.Scheme != "s3" {
 		return nil, fmt.Errorf("Object store: %q: unsupported scheme %s", address, u.Scheme)
 	}
-	svc := s3.New(s, aws.NewConfig().WithS3DisableContentMD5Validation(true))
+	cfg := aws.NewConfig().WithS3DisableContentMD5Validation(true)
+	if opts.Endpoint != "" {
+		cfg = cfg.WithEndpoint(opts.Endpoint)
+	}
+	if opts.ForcePathStyle {
+		cfg = cfg.WithS3ForcePathStyle(true)
+	}
+	if opts.Accelerate {
+		if opts.Endpoint != "" || opts.ForcePathStyle {
+			return nil, fmt.Errorf("Object store: %q: transfer acceleration requires the default S3 endpoint and virtual-hosted buckets", address)
+		}
+		cfg = cfg.WithS3UseAccelerate(true)
+	}
+	if opts.InsecureSkipVerify || opts.RequestTimeout > 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if opts.InsecureSkipVerify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		cfg = cfg.WithHTTPClient(&http.Client{
+			Transport: transport,
+			Timeout:   opts.RequestTimeout,
+		})
+	}
+	svc := s3.New(s, cfg)
 	svc.Handlers.Sign.PushFront(func(r *request.Request) {
 		r.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
 	})
@@ -123,20 +271,75 @@ func FromSessionAndOptions(s *session.Session, address string, opts Options) (*S
 	if opts.DiskCacheBytes > 0 {
 		disk = diskcache.New(opts.DiskCachePath, opts.DiskCacheBytes)
 	}
+	var shared *diskcache.Shared
+	if opts.SharedCachePath != "" {
+		limit := opts.SharedCacheBytes
+		if limit == 0 {
+			limit = diskcache.DefaultSharedBytes
+		}
+		shared = diskcache.NewShared(opts.SharedCachePath, limit)
+	}
 
-	return &Store{
+	var enc *zstd.Encoder
+	switch {
+	case opts.CompressionLevel == 0:
+		enc = encode
+	case opts.CompressionLevel > 0:
+		enc, e = zstd.NewWriter(nil,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.CompressionLevel)))
+		if e != nil {
+			return nil, fmt.Errorf("zstd: init writer: %w", e)
+		}
+	}
+
+	st := &Store{
 		opts:    opts,
 		session: s,
 		s3:      svc,
 		url:     u,
 		disk:    disk,
-	}, nil
+		shared:  shared,
+		encode:  enc,
+		hash:    hash,
+	}
+	if opts.UploadIndexPath != "" {
+		ttl := opts.UploadIndexTTL
+		if ttl <= 0 {
+			ttl = storeutil.DefaultIndexTTL
+		}
+		// We trust the index for up to ttl, without checking
+		// whether an object needs refreshing
+		if opts.RefreshAge > 0 && opts.RefreshAge < ttl {
+			return nil, fmt.Errorf("Object store: %q: refresh age %s is shorter than the upload index TTL %s", address, opts.RefreshAge, ttl)
+		}
+		idx, err := storeutil.OpenIndex(opts.UploadIndexPath, opts.UploadIndexTTL)
+		if err != nil {
+			return nil, fmt.Errorf("opening upload index: %w", err)
+		}
+		st.seen.SetIndex(idx)
+	}
+	if opts.IndexTable != "" {
+		cfg := aws.NewConfig()
+		if opts.IndexRegion != "" {
+			cfg = cfg.WithRegion(opts.IndexRegion)
+		}
+		st.index = newDynamoIndex(dynamodb.New(s, cfg), opts.IndexTable)
+	}
+	return st, nil
+}
+
+func (s *Store) ObjectID(obj []byte) (string, error) {
+	id := s.hash.Sum(obj)
+	if s.encode != nil {
+		id += ":zstd"
+	}
+	return id, nil
 }
 
 func (s *Store) Store(ctx context.Context, obj []byte) (string, error) {
 	ctx, span := tracing.StartSpan(ctx, "s3.store")
 	defer span.End()
-	id := storeutil.HashObject(obj) + ":zstd"
+	id, _ := s.ObjectID(obj)
 
 	span.AddField("object_id", id)
 	if s.seen.HasObject(id) {
@@ -152,44 +355,268 @@ func (s *Store) Store(ctx context.Context, obj []byte) (string, error) {
 	upload := s.seen.StartUpload(id)
 	defer upload.Rollback()
 
-	if !s.opts.DisableHeadCheck {
+	// If HasObjects just found the object missing, don't check
+	// again
+	if !s.opts.DisableHeadCheck && !s.seen.TakeAbsent(id) {
 		usage.ReadRequests += 1
-		_, err = s.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		var head *s3.HeadObjectOutput
+		head, err = s.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
 			Bucket: &s.url.Host,
 			Key:    key,
 		})
-		if err == nil {
-			upload.Complete()
+		if err == nil && !s.stale(aws.TimeValue(head.LastModified)) {
+			s.confirm(&upload, aws.TimeValue(head.LastModified))
+			s.record(ctx, id, aws.Int64Value(head.ContentLength), aws.TimeValue(head.LastModified))
+			s.used(ctx, id, aws.TimeValue(head.LastModified), aws.Int64Value(head.ContentLength), &usage)
 			span.AddField("s3.exists", true)
 			return id, nil
 		}
-		if reqerr, ok := err.(awserr.RequestFailure); ok && reqerr.StatusCode() == 404 {
+		if err == nil {
+			// It's old enough that a lifecycle rule might
+			// expire it while it's still in use
+			span.AddField("s3.refresh", true)
+		} else if reqerr, ok := err.(awserr.RequestFailure); ok && reqerr.StatusCode() == 404 {
 			// 404 not found -- do the upload
 		} else {
 			return "", err
 		}
 	}
 
-	compressed := encode.EncodeAll(obj, nil)
-	span.AddField("s3.write_bytes", len(compressed))
+	body := obj
+	if s.encode != nil {
+		body = s.encode.EncodeAll(obj, nil)
+	}
+	span.AddField("s3.write_bytes", len(body))
+
+	if part := s.partSize(int64(len(body))); part > 0 {
+		err = s.uploadParts(ctx, key, body, part, &usage)
+	} else {
+		usage.WriteRequests += 1
+		_, err = s.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Body:         bytes.NewReader(body),
+			Bucket:       &s.url.Host,
+			Key:          key,
+			StorageClass: s.storageClass(),
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+	s.metrics.XferIn += uint64(len(obj))
+	now := time.Now()
+	s.confirm(&upload, now)
+	s.record(ctx, id, int64(len(body)), now)
+	return id, nil
+}
+
+// stale reports whether an object last written at modified should be
+// rewritten to keep it from expiring; see Options.RefreshAge.
+func (s *Store) stale(modified time.Time) bool {
+	return s.opts.RefreshAge > 0 && time.Since(modified) > s.opts.RefreshAge
+}
+
+func (s *Store) storageClass() *string {
+	if s.opts.StorageClass == "" {
+		return nil
+	}
+	return aws.String(s.opts.StorageClass)
+}
+
+func (s *Store) confirm(upload *storeutil.UploadHandle, modified time.Time) {
+	if err := upload.Confirm(modified); err != nil {
+		log.Printf("s3: recording upload: %s", err.Error())
+	}
+}
+
+// record notes an object we've written or found in the index, if we
+// have one
+func (s *Store) record(ctx context.Context, id string, size int64, written time.Time) {
+	if s.index == nil {
+		return
+	}
+	if err := s.index.record(ctx, id, size, written); err != nil {
+		log.Printf("s3: recording %s in index: %s", id, err.Error())
+	}
+}
+
+// The most HEAD requests HasObjects makes at once
+const headConcurrency = 128
+
+// HasObjects checks for the objects we haven't already seen in the
+// index, if we have one, and then with concurrent HEAD requests, since
+// S3 has no batch HEAD. Objects old enough to need refreshing count as
+// missing, so that Store rewrites them. With DisableHeadCheck,
+// objects we find in neither are reported missing without a HEAD.
+func (s *Store) HasObjects(ctx context.Context, ids []string) ([]bool, error) {
+	ctx, span := tracing.StartSpan(ctx, "s3.has_objects")
+	defer span.End()
+	span.AddField("objects", len(ids))
+
+	has := make([]bool, len(ids))
+	for i, id := range ids {
+		has[i] = s.seen.HasObject(id)
+	}
+	if s.index != nil {
+		s.checkIndex(ctx, ids, has)
+	}
+
+	var usage usageMetrics
+	var found uint64
+	grp, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, headConcurrency)
+	for i, id := range ids {
+		if has[i] {
+			continue
+		}
+		if s.opts.DisableHeadCheck {
+			continue
+		}
+		i, id := i, id
+		sem <- struct{}{}
+		grp.Go(func() error {
+			defer func() { <-sem }()
+			upload := s.seen.StartUpload(id)
+			defer upload.Rollback()
+			atomic.AddUint64(&usage.ReadRequests, 1)
+			head, err := s.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+				Bucket: &s.url.Host,
+				Key:    aws.String(path.Join(s.url.Path, id)),
+			})
+			if err == nil && !s.stale(aws.TimeValue(head.LastModified)) {
+				s.confirm(&upload, aws.TimeValue(head.LastModified))
+				s.record(ctx, id, aws.Int64Value(head.ContentLength), aws.TimeValue(head.LastModified))
+				s.used(ctx, id, aws.TimeValue(head.LastModified), aws.Int64Value(head.ContentLength), &usage)
+				atomic.AddUint64(&found, 1)
+				has[i] = true
+				return nil
+			}
+			if reqerr, ok := err.(awserr.RequestFailure); err == nil || ok && reqerr.StatusCode() == 404 {
+				s.seen.MarkAbsent(id)
+				return nil
+			}
+			return err
+		})
+	}
+	err := grp.Wait()
+	s.addUsage(&usage)
+	span.AddField("s3.heads", usage.ReadRequests)
+	span.AddField("s3.found", found)
+	return has, err
+}
+
+// checkIndex sets has[i] for each object the index records as
+// present and fresh. If the index fails us, we leave it to HEADs.
+func (s *Store) checkIndex(ctx context.Context, ids []string, has []bool) {
+	ctx, span := tracing.StartSpan(ctx, "s3.check_index")
+	defer span.End()
+	var lookup []string
+	for i, id := range ids {
+		if !has[i] {
+			lookup = append(lookup, id)
+		}
+	}
+	if len(lookup) == 0 {
+		return
+	}
+	infos, err := s.index.lookup(ctx, lookup)
+	if err != nil {
+		log.Printf("s3: checking index: %s", err.Error())
+		return
+	}
+	var found int
+	for i, id := range ids {
+		info, ok := infos[id]
+		if has[i] || !ok || s.stale(info.LastModified) {
+			continue
+		}
+		upload := s.seen.StartUpload(id)
+		s.confirm(&upload, info.LastModified)
+		has[i] = true
+		found++
+	}
+	span.AddField("index.found", found)
+}
+
+func (s *Store) StatObject(ctx context.Context, id string) (store.ObjectInfo, error) {
+	ctx, span := tracing.StartSpan(ctx, "s3.stat_object")
+	defer span.End()
+	span.AddField("object_id", id)
+
+	var usage usageMetrics
+	defer s.addUsage(&usage)
+	usage.ReadRequests += 1
+	head, err := s.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &s.url.Host,
+		Key:    aws.String(path.Join(s.url.Path, id)),
+	})
+	if err != nil {
+		if reqerr, ok := err.(awserr.RequestFailure); ok && reqerr.StatusCode() == 404 {
+			return store.ObjectInfo{}, store.ErrNotExists
+		}
+		return store.ObjectInfo{}, err
+	}
+	return store.ObjectInfo{
+		Id:           id,
+		Size:         aws.Int64Value(head.ContentLength),
+		LastModified: aws.TimeValue(head.LastModified),
+	}, nil
+}
+
+func (s *Store) keyPath(key string) *string {
+	return aws.String(path.Join(s.url.Path, "keys", key))
+}
+
+func (s *Store) GetKey(ctx context.Context, key string) ([]byte, error) {
+	ctx, span := tracing.StartSpan(ctx, "s3.get_key")
+	defer span.End()
+
+	var usage usageMetrics
+	defer s.addUsage(&usage)
+
+	usage.ReadRequests += 1
+	resp, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &s.url.Host,
+		Key:    s.keyPath(key),
+	})
+	if err != nil {
+		if reqerr, ok := err.(awserr.RequestFailure); ok && reqerr.StatusCode() == 404 {
+			return nil, store.ErrNotExists
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	usage.XferOut += uint64(len(body))
+	s.used(ctx, path.Join("keys", key), aws.TimeValue(resp.LastModified), aws.Int64Value(resp.ContentLength), &usage)
+	return body, nil
+}
+
+func (s *Store) SetKey(ctx context.Context, key string, value []byte) error {
+	ctx, span := tracing.StartSpan(ctx, "s3.set_key")
+	defer span.End()
+
+	var usage usageMetrics
+	defer s.addUsage(&usage)
 
 	usage.WriteRequests += 1
-	_, err = s.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
-		Body:   bytes.NewReader(compressed),
+	_, err := s.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Body:   bytes.NewReader(value),
 		Bucket: &s.url.Host,
-		Key:    key,
+		Key:    s.keyPath(key),
 	})
 	if err != nil {
-		return "", err
+		return err
 	}
-	s.metrics.XferIn += uint64(len(obj))
-	upload.Complete()
-	return id, nil
+	usage.XferIn += uint64(len(value))
+	return nil
 }
 
 const getConcurrency = 32
 
-func (s *Store) getFromS3(ctx context.Context, id string, usage *usageMetrics) ([]byte, error) {
+func (s *Store) getFromS3(ctx context.Context, id string, usage *usageMetrics) ([]byte, time.Time, error) {
 	ctx, span := tracing.StartSpan(ctx, "s3.get_one")
 	defer span.End()
 
@@ -199,12 +626,12 @@ func (s *Store) getFromS3(ctx context.Context, id string, usage *usageMetrics) (
 		Key:    aws.String(path.Join(s.url.Path, id)),
 	})
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	resp.Body.Close()
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
 	span.AddField("s3.read_bytes", len(body))
@@ -213,10 +640,13 @@ func (s *Store) getFromS3(ctx context.Context, id string, usage *usageMetrics) (
 	if s.disk != nil {
 		s.disk.Put(id, body)
 	}
-	return body, nil
+	if s.shared != nil {
+		s.shared.Put(id, body)
+	}
+	return body, aws.TimeValue(resp.LastModified), nil
 }
 
-func (s *Store) decompress(id string, body []byte) (string, []byte, error) {
+func decompress(id string, body []byte) (string, []byte, error) {
 	expectHash := id
 	colon := strings.IndexRune(id, ':')
 	if colon > 0 {
@@ -234,31 +664,53 @@ func (s *Store) decompress(id string, body []byte) (string, []byte, error) {
 	return expectHash, body, nil
 }
 
+// decodeObject decodes an object as stored, and checks it against its
+// ID
+func decodeObject(id string, body []byte) ([]byte, error) {
+	hash, body, err := decompress(id, body)
+	if err != nil {
+		return nil, err
+	}
+	if gotHash, ok := storeutil.CheckHash(hash, body); !ok {
+		return nil, fmt.Errorf("object store mismatch: got csum=%s expected %s", gotHash, id)
+	}
+	return body, nil
+}
+
 func (s *Store) getOne(ctx context.Context, id string, usage *usageMetrics) ([]byte, error) {
 	var body []byte
+	var shared, touched bool
+	var modified time.Time
 	if s.disk != nil {
 		body, _ = s.disk.Get(id)
 	}
+	if body == nil && s.shared != nil {
+		if body, touched, shared = s.shared.GetTouched(id); shared && s.disk != nil {
+			s.disk.Put(id, body)
+		}
+	}
 	if body == nil {
 		var err error
-		body, err = s.getFromS3(ctx, id, usage)
+		body, modified, err = s.getFromS3(ctx, id, usage)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	hash, body, err := s.decompress(id, body)
+	size := int64(len(body))
+	body, err := decodeObject(id, body)
 	if err != nil {
 		return nil, err
 	}
-
-	gotHash := storeutil.HashObject(body)
-	if gotHash != hash {
-		return nil, fmt.Errorf("object store mismatch: got csum=%s expected %s", gotHash, id)
-	}
 	u := s.seen.StartUpload(id)
 	u.Complete()
 
+	if shared {
+		s.usedShared(ctx, id, touched, size, usage)
+	} else {
+		s.used(ctx, id, modified, size, usage)
+	}
+
 	return body, nil
 }
 
@@ -283,6 +735,11 @@ func (s *Store) GetObjects(ctx context.Context, gets []store.GetRequest) {
 		grp.Go(func() error {
 			for idx := range jobs {
 				gets[idx].Data, gets[idx].Err = s.getOne(ctx, gets[idx].Id, &usage)
+				if gets[idx].Err == nil && s.index != nil {
+					if err := s.index.touch(ctx, gets[idx].Id); err != nil {
+						log.Printf("s3: recording read of %s in index: %s", gets[idx].Id, err.Error())
+					}
+				}
 			}
 			return nil
 		})
@@ -292,3 +749,215 @@ func (s *Store) GetObjects(ctx context.Context, gets []store.GetRequest) {
 		log.Fatalf("GetObjects: internal error %s", err)
 	}
 }
+
+// presignExpiry is how long presigned requests remain valid
+const presignExpiry = 15 * time.Minute
+
+func (s *Store) PresignPut(ctx context.Context, id string) (store.PresignedRequest, error) {
+	if s.seen.HasObject(id) {
+		return store.PresignedRequest{}, nil
+	}
+	req, _ := s.s3.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:       &s.url.Host,
+		Key:          aws.String(path.Join(s.url.Path, id)),
+		StorageClass: s.storageClass(),
+	})
+	return presign(ctx, req)
+}
+
+func (s *Store) PresignGet(ctx context.Context, id string) (store.PresignedRequest, error) {
+	req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: &s.url.Host,
+		Key:    aws.String(path.Join(s.url.Path, id)),
+	})
+	return presign(ctx, req)
+}
+
+func presign(ctx context.Context, req *request.Request) (store.PresignedRequest, error) {
+	req.SetContext(ctx)
+	url, header, err := req.PresignRequest(presignExpiry)
+	if err != nil {
+		return store.PresignedRequest{}, err
+	}
+	return store.PresignedRequest{URL: url, Header: header}, nil
+}
+
+// objectPrefix returns the S3 key prefix under which this store's
+// objects live.
+func (s *Store) objectPrefix() string {
+	prefix := strings.Trim(s.url.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return prefix
+}
+
+func (s *Store) list(ctx context.Context, prefix string, delimiter *string, cb func(store.ObjectInfo) error) error {
+	var usage usageMetrics
+	defer s.addUsage(&usage)
+
+	var cbErr error
+	err := s.s3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:    &s.url.Host,
+		Prefix:    &prefix,
+		Delimiter: delimiter,
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		usage.ReadRequests += 1
+		for _, obj := range page.Contents {
+			info := store.ObjectInfo{
+				Id:           strings.TrimPrefix(*obj.Key, prefix),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+			}
+			if cbErr = cb(info); cbErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return cbErr
+}
+
+// ListObjects lists the objects in the index, if we have one, and
+// otherwise in the bucket.
+func (s *Store) ListObjects(ctx context.Context, cb func(store.ObjectInfo) error) error {
+	ctx, span := tracing.StartSpan(ctx, "s3.list_objects")
+	defer span.End()
+	if s.index != nil {
+		span.AddField("index", true)
+		return s.index.scan(ctx, cb)
+	}
+	return s.list(ctx, s.objectPrefix(), aws.String("/"), cb)
+}
+
+func (s *Store) ListKeys(ctx context.Context, prefix string, cb func(store.ObjectInfo) error) error {
+	ctx, span := tracing.StartSpan(ctx, "s3.list_keys")
+	defer span.End()
+	base := s.objectPrefix() + "keys/"
+	return s.list(ctx, base+prefix, nil, func(info store.ObjectInfo) error {
+		info.Id = prefix + info.Id
+		return cb(info)
+	})
+}
+
+// S3 limits DeleteObjects to 1000 keys per request
+const deleteBatch = 1000
+
+func (s *Store) deleteKeys(ctx context.Context, keys []string) error {
+	var usage usageMetrics
+	defer s.addUsage(&usage)
+
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > deleteBatch {
+			n = deleteBatch
+		}
+		var del s3.Delete
+		for _, k := range keys[:n] {
+			del.Objects = append(del.Objects, &s3.ObjectIdentifier{Key: aws.String(k)})
+		}
+		del.Quiet = aws.Bool(true)
+		keys = keys[n:]
+
+		usage.WriteRequests += 1
+		out, err := s.s3.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: &s.url.Host,
+			Delete: &del,
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("delete %s: %s", aws.StringValue(e.Key), aws.StringValue(e.Message))
+		}
+	}
+	return nil
+}
+
+func (s *Store) DeleteObjects(ctx context.Context, ids []string) error {
+	ctx, span := tracing.StartSpan(ctx, "s3.delete_objects")
+	defer span.End()
+	span.AddField("objects", len(ids))
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.objectPrefix() + id
+	}
+	if err := s.deleteKeys(ctx, keys); err != nil {
+		return err
+	}
+	if s.index != nil {
+		return s.index.remove(ctx, ids)
+	}
+	return nil
+}
+
+func (s *Store) DeleteKeys(ctx context.Context, keys []string) error {
+	ctx, span := tracing.StartSpan(ctx, "s3.delete_keys")
+	defer span.End()
+	span.AddField("keys", len(keys))
+	paths := make([]string, len(keys))
+	for i, k := range keys {
+		paths[i] = s.objectPrefix() + "keys/" + k
+	}
+	return s.deleteKeys(ctx, paths)
+}
+
+var errNoIndex = errors.New("s3: the store has no index table configured")
+
+func (s *Store) HasIndex() bool {
+	return s.index != nil
+}
+
+func (s *Store) AddRefs(ctx context.Context, ids []string, delta int64) error {
+	if s.index == nil {
+		return errNoIndex
+	}
+	return s.index.addRefs(ctx, ids, delta)
+}
+
+// Reindex lists the bucket and records every object missing from the
+// index.
+func (s *Store) Reindex(ctx context.Context) (int, error) {
+	ctx, span := tracing.StartSpan(ctx, "s3.reindex")
+	defer span.End()
+	if s.index == nil {
+		return 0, errNoIndex
+	}
+	var added int
+	var batch []store.ObjectInfo
+	flush := func() error {
+		ids := make([]string, len(batch))
+		for i, obj := range batch {
+			ids[i] = obj.Id
+		}
+		have, err := s.index.lookup(ctx, ids)
+		if err != nil {
+			return err
+		}
+		var missing []store.ObjectInfo
+		for _, obj := range batch {
+			if _, ok := have[obj.Id]; !ok {
+				missing = append(missing, obj)
+			}
+		}
+		batch = batch[:0]
+		added += len(missing)
+		return s.index.add(ctx, missing)
+	}
+	err := s.list(ctx, s.objectPrefix(), aws.String("/"), func(obj store.ObjectInfo) error {
+		batch = append(batch, obj)
+		if len(batch) < batchGetLimit {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	span.AddField("added", added)
+	return added, err
+}
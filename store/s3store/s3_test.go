@@ -0,0 +1,311 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/nelhage/llama/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBucket records the last-modified time of each object, the
+// storage class of each PUT, the headers of each copy, and the number
+// of HEADs
+type fakeBucket struct {
+	sync.Mutex
+	modified map[string]time.Time
+	bodies   map[string][]byte
+	puts     []string
+	// The metadata each copy request set
+	copies []http.Header
+
+	heads int
+}
+
+func newFakeBucket() *fakeBucket {
+	return &fakeBucket{
+		modified: make(map[string]time.Time),
+		bodies:   make(map[string][]byte),
+	}
+}
+
+func (f *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	switch r.Method {
+	case http.MethodHead:
+		f.heads++
+		at, ok := f.modified[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", at.UTC().Format(http.TimeFormat))
+	case http.MethodGet:
+		at, ok := f.modified[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", at.UTC().Format(http.TimeFormat))
+		w.Write(f.bodies[r.URL.Path])
+	case http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			if "/"+src != r.URL.Path {
+				w.WriteHeader(http.StatusNotImplemented)
+				return
+			}
+			f.modified[r.URL.Path] = time.Now()
+			f.copies = append(f.copies, r.Header)
+			w.Write([]byte(`<CopyObjectResult><LastModified>` +
+				time.Now().UTC().Format(time.RFC3339) +
+				`</LastModified></CopyObjectResult>`))
+			return
+		}
+		f.modified[r.URL.Path] = time.Now()
+		f.bodies[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+		f.puts = append(f.puts, r.Header.Get("X-Amz-Storage-Class"))
+	}
+}
+
+func TestStoreRefresh(t *testing.T) {
+	bucket := newFakeBucket()
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	opts := Options{
+		Endpoint:         srv.URL,
+		ForcePathStyle:   true,
+		CompressionLevel: -1,
+		StorageClass:     "INTELLIGENT_TIERING",
+		RefreshAge:       24 * time.Hour,
+	}
+	open := func() *Store {
+		st, err := FromSessionAndOptions(sess, "s3://bucket/obj/", opts)
+		require.NoError(t, err)
+		return st
+	}
+	ctx := context.Background()
+	obj := []byte("hello, world")
+	id, err := open().ObjectID(obj)
+	require.NoError(t, err)
+	key := path.Join("/bucket/obj", id)
+
+	_, err = open().Store(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"INTELLIGENT_TIERING"}, bucket.puts)
+
+	bucket.modified[key] = time.Now().Add(-time.Hour)
+	_, err = open().Store(ctx, obj)
+	require.NoError(t, err)
+	assert.Len(t, bucket.puts, 1, "recent objects are not rewritten")
+
+	bucket.modified[key] = time.Now().Add(-48 * time.Hour)
+	_, err = open().Store(ctx, obj)
+	require.NoError(t, err)
+	assert.Len(t, bucket.puts, 2, "old objects are rewritten")
+	assert.WithinDuration(t, time.Now(), bucket.modified[key], time.Minute)
+}
+
+func TestStoreOptions(t *testing.T) {
+	sess := session.Must(session.NewSession(aws.NewConfig().WithRegion("us-east-1")))
+	_, err := FromSessionAndOptions(sess, "s3://bucket/obj/", Options{StorageClass: "GLACIER"})
+	assert.Error(t, err, "objects in GLACIER can't be read back")
+
+	dir := t.TempDir()
+	_, err = FromSessionAndOptions(sess, "s3://bucket/obj/", Options{
+		UploadIndexPath: filepath.Join(dir, "index"),
+		RefreshAge:      time.Hour,
+	})
+	assert.Error(t, err, "refresh age is shorter than the index TTL")
+}
+
+func TestHasObjects(t *testing.T) {
+	bucket := newFakeBucket()
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	st, err := FromSessionAndOptions(sess, "s3://bucket/obj/", Options{
+		Endpoint:         srv.URL,
+		ForcePathStyle:   true,
+		CompressionLevel: -1,
+		RefreshAge:       24 * time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	objs := [][]byte{[]byte("present"), []byte("stale"), []byte("missing")}
+	var ids []string
+	for _, obj := range objs {
+		id, err := st.ObjectID(obj)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	bucket.modified[path.Join("/bucket/obj", ids[0])] = time.Now()
+	bucket.modified[path.Join("/bucket/obj", ids[1])] = time.Now().Add(-48 * time.Hour)
+
+	has, err := st.HasObjects(ctx, ids)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false}, has)
+	assert.Equal(t, 3, bucket.heads)
+
+	// Storing the objects now needs no further HEADs, and only
+	// writes the ones that were missing or stale
+	for _, obj := range objs {
+		_, err := st.Store(ctx, obj)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, bucket.heads)
+	assert.Len(t, bucket.puts, 2)
+
+	has, err = st.HasObjects(ctx, ids)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, true, true}, has)
+	assert.Equal(t, 3, bucket.heads)
+}
+
+func TestTouch(t *testing.T) {
+	bucket := newFakeBucket()
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	opts := Options{
+		Endpoint:         srv.URL,
+		ForcePathStyle:   true,
+		CompressionLevel: -1,
+		DiskCachePath:    t.TempDir(),
+		DiskCacheBytes:   1 << 20,
+	}
+	open := func() *Store {
+		st, err := FromSessionAndOptions(sess, "s3://bucket/obj/", opts)
+		require.NoError(t, err)
+		return st
+	}
+	ctx := context.Background()
+	obj := []byte("hello, world")
+	id, err := open().Store(ctx, obj)
+	require.NoError(t, err)
+	key := path.Join("/bucket/obj", id)
+	get := func(st *Store) {
+		gets := []store.GetRequest{{Id: id}}
+		st.GetObjects(ctx, gets)
+		require.NoError(t, gets[0].Err)
+		assert.Equal(t, obj, gets[0].Data)
+	}
+
+	// Reading a recently-written object leaves it alone
+	st := open()
+	get(st)
+	assert.Len(t, bucket.copies, 0)
+
+	// Reading an old one touches it, once
+	bucket.modified[key] = time.Now().Add(-48 * time.Hour)
+	opts.DiskCacheBytes = 0
+	st = open()
+	get(st)
+	get(st)
+	require.Len(t, bucket.copies, 1)
+	assert.Equal(t, "REPLACE", bucket.copies[0].Get("X-Amz-Metadata-Directive"))
+	assert.NotEmpty(t, bucket.copies[0].Get("X-Amz-Meta-Llama-Used"))
+	assert.WithinDuration(t, time.Now(), bucket.modified[key], time.Minute)
+
+	// So does finding it already stored
+	bucket.modified[key] = time.Now().Add(-48 * time.Hour)
+	has, err := open().HasObjects(ctx, []string{id})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true}, has)
+	assert.Len(t, bucket.copies, 2)
+
+	bucket.modified[key] = time.Now().Add(-48 * time.Hour)
+	_, err = open().Store(ctx, obj)
+	require.NoError(t, err)
+	assert.Len(t, bucket.copies, 3)
+	assert.Len(t, bucket.puts, 1)
+
+	// Reading it from the disk cache checks S3 for its age
+	opts.DiskCacheBytes = 1 << 20
+	bucket.modified[key] = time.Now().Add(-48 * time.Hour)
+	heads := bucket.heads
+	get(open())
+	assert.Equal(t, heads+1, bucket.heads)
+	assert.Len(t, bucket.copies, 4)
+
+	opts.TouchAge = -1
+	bucket.modified[key] = time.Now().Add(-48 * time.Hour)
+	get(open())
+	assert.Len(t, bucket.copies, 4, "touching is disabled")
+}
+
+func TestTouchKeys(t *testing.T) {
+	bucket := newFakeBucket()
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	st, err := FromSessionAndOptions(sess, "s3://bucket/obj/", Options{
+		Endpoint:       srv.URL,
+		ForcePathStyle: true,
+		StorageClass:   "INTELLIGENT_TIERING",
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, st.SetKey(ctx, "results/fresh", []byte("fresh")))
+	require.NoError(t, st.SetKey(ctx, "results/stale", []byte("stale")))
+	stale := "/bucket/obj/keys/results/stale"
+	bucket.modified[stale] = time.Now().Add(-48 * time.Hour)
+
+	get := func(key string) {
+		data, err := st.GetKey(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(path.Base(key)), data)
+	}
+
+	// Reading a recently-written key leaves it alone
+	get("results/fresh")
+	assert.Len(t, bucket.copies, 0)
+
+	// Reading an old one touches it, once, so that gc sees it
+	// in use
+	get("results/stale")
+	get("results/stale")
+	require.Len(t, bucket.copies, 1)
+	assert.NotEmpty(t, bucket.copies[0].Get("X-Amz-Meta-Llama-Used"))
+	assert.Empty(t, bucket.copies[0].Get("X-Amz-Storage-Class"), "keys keep the default storage class")
+	assert.WithinDuration(t, time.Now(), bucket.modified[stale], time.Minute)
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3store
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/nelhage/llama/store/diskcache"
+	"github.com/nelhage/llama/tracing"
+)
+
+// DefaultTouchAge is the default for Options.TouchAge
+const DefaultTouchAge = 24 * time.Hour
+
+// usedMeta is the user metadata in which touch records when it last
+// found an object in use. S3 refuses to copy an object onto itself
+// without changing something, and this also tells anyone looking why
+// the object's Last-Modified time is later than its upload.
+const usedMeta = "Llama-Used"
+
+// S3 copies objects up to this size in a single request
+const maxCopyBytes = 5 << 30
+
+// useTracker remembers, for each object, until when S3 is known to
+// record a recent enough use of it, so that reading a hot object
+// doesn't cost a request every time.
+type useTracker struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// due reports whether we need to check on `id` at `now`
+func (u *useTracker) due(id string, now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.until[id])
+}
+
+// extend records that we needn't check on `id` until `until`
+func (u *useTracker) extend(id string, until time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.until == nil {
+		u.until = make(map[string]time.Time)
+	}
+	if until.After(u.until[id]) {
+		u.until[id] = until
+	}
+}
+
+func (s *Store) touchAge() time.Duration {
+	if s.opts.TouchAge == 0 {
+		return DefaultTouchAge
+	}
+	return s.opts.TouchAge
+}
+
+// used notes that we just read `id`, or found it stored. `id` is
+// relative to the store's prefix, so keys are under "keys/".
+// `modified` is its Last-Modified time, if we learned it doing so,
+// and `size` its size as stored. If the object's Last-Modified time
+// is more than TouchAge ago, we touch it.
+func (s *Store) used(ctx context.Context, id string, modified time.Time, size int64, usage *usageMetrics) {
+	age := s.touchAge()
+	if age <= 0 {
+		return
+	}
+	now := time.Now()
+	if !s.uses.due(id, now) {
+		return
+	}
+	if !modified.IsZero() && now.Sub(modified) < age {
+		s.uses.extend(id, modified.Add(age))
+		return
+	}
+	if size > maxCopyBytes {
+		// S3 would want a multipart copy; leave it to
+		// RefreshAge or the index to keep it alive
+		s.uses.extend(id, now.Add(age))
+		return
+	}
+	if modified.IsZero() {
+		// We read it from a cache, and don't know how
+		// recently S3 saw it used
+		atomic.AddUint64(&usage.ReadRequests, 1)
+		head, err := s.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: &s.url.Host,
+			Key:    aws.String(path.Join(s.url.Path, id)),
+		})
+		if err != nil {
+			log.Printf("s3: checking last use of %s: %s", id, err.Error())
+			return
+		}
+		s.used(ctx, id, aws.TimeValue(head.LastModified), aws.Int64Value(head.ContentLength), usage)
+		return
+	}
+	// Whatever happens, don't try again for a while
+	s.uses.extend(id, now.Add(age))
+	if err := s.touch(ctx, id, now, usage); err != nil {
+		log.Printf("s3: recording use of %s: %s", id, err.Error())
+	}
+}
+
+// usedShared notes that we just read `id` from the shared cache.
+// `touched` reports whether ours was the first read of it there in
+// diskcache.SharedTouchInterval; if it wasn't, whichever process made
+// that read took care of S3, and we leave it to the next first read.
+func (s *Store) usedShared(ctx context.Context, id string, touched bool, size int64, usage *usageMetrics) {
+	if touched {
+		s.used(ctx, id, time.Time{}, size, usage)
+	} else if s.touchAge() > 0 {
+		s.uses.extend(id, time.Now().Add(diskcache.SharedTouchInterval))
+	}
+}
+
+// touch copies `id` onto itself, setting its Last-Modified time to
+// about `now`.
+func (s *Store) touch(ctx context.Context, id string, now time.Time, usage *usageMetrics) error {
+	ctx, span := tracing.StartSpan(ctx, "s3.touch")
+	defer span.End()
+	span.AddField("object_id", id)
+
+	key := strings.TrimPrefix(path.Join(s.url.Path, id), "/")
+	class := s.storageClass()
+	if strings.HasPrefix(id, "keys/") {
+		// SetKey writes keys in the default storage class
+		class = nil
+	}
+	atomic.AddUint64(&usage.WriteRequests, 1)
+	_, err := s.s3.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:            &s.url.Host,
+		Key:               &key,
+		CopySource:        aws.String(url.PathEscape(s.url.Host) + "/" + (&url.URL{Path: key}).EscapedPath()),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+		Metadata:          map[string]*string{usedMeta: aws.String(now.UTC().Format(time.RFC3339))},
+		StorageClass:      class,
+	})
+	return err
+}
@@ -17,6 +17,11 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
 
 	"github.com/nelhage/llama/protocol"
 )
@@ -35,8 +40,133 @@ type Store interface {
 	FetchAWSUsage(u *protocol.UsageMetrics)
 }
 
+// A KeyValue store additionally supports storing small values under
+// caller-chosen keys, for data which is not content-addressed.
+type KeyValue interface {
+	GetKey(ctx context.Context, key string) ([]byte, error)
+	SetKey(ctx context.Context, key string, value []byte) error
+}
+
+type ObjectInfo struct {
+	// The object ID, or the key for a KeyValue entry
+	Id           string
+	Size         int64
+	LastModified time.Time
+
+	// Stores which keep an index of their objects may also know
+	// when each was last read, and how many result-cache entries
+	// refer to it. Otherwise these are zero.
+	LastAccessed time.Time
+	Refs         int64
+}
+
+// LastUsed returns when the object was last written or read,
+// whichever is later.
+func (o *ObjectInfo) LastUsed() time.Time {
+	if o.LastAccessed.After(o.LastModified) {
+		return o.LastAccessed
+	}
+	return o.LastModified
+}
+
+// A Collectable store can enumerate and delete its contents, for
+// garbage collection.
+type Collectable interface {
+	ListObjects(ctx context.Context, cb func(ObjectInfo) error) error
+	ListKeys(ctx context.Context, prefix string, cb func(ObjectInfo) error) error
+	DeleteObjects(ctx context.Context, ids []string) error
+	DeleteKeys(ctx context.Context, keys []string) error
+}
+
+// An Inspectable store can report whether it holds an object without
+// fetching it, and compute the ID under which it would store an
+// object without storing it.
+type Inspectable interface {
+	ObjectID(obj []byte) (string, error)
+	// StatObject returns ErrNotExists if there is no such object
+	StatObject(ctx context.Context, id string) (ObjectInfo, error)
+}
+
+// An ExistenceChecker can find out which of many objects it holds in
+// a round trip or two, rather than checking each object as it is
+// stored. It remembers what it learns, so that storing the objects
+// afterwards doesn't check for them again.
+type ExistenceChecker interface {
+	// HasObjects reports, for each of `ids`, whether the store
+	// holds the object
+	HasObjects(ctx context.Context, ids []string) ([]bool, error)
+}
+
+// An Indexer store can keep an index of its objects, which counts the
+// references to each object and which may need backfilling with
+// objects stored before it existed.
+type Indexer interface {
+	// HasIndex reports whether the store is keeping an index;
+	// without one, the other methods fail.
+	HasIndex() bool
+	// AddRefs adds `delta` to the reference count of each of
+	// `ids`
+	AddRefs(ctx context.Context, ids []string, delta int64) error
+	// Reindex adds any objects the index is missing, returning
+	// how many it added
+	Reindex(ctx context.Context) (int, error)
+}
+
+// A PresignedRequest is an HTTP request that grants temporary access
+// to an object without credentials. Clients must send Header with it.
+type PresignedRequest struct {
+	URL    string
+	Header http.Header
+}
+
+// A Presigner can grant processes without credentials for the store
+// access to individual objects, so that they can transfer them
+// directly. Objects are transferred as encoded in the store.
+type Presigner interface {
+	// PresignPut returns a request to PUT the encoded object `id`,
+	// or one with an empty URL if the store already has it.
+	PresignPut(ctx context.Context, id string) (PresignedRequest, error)
+	PresignGet(ctx context.Context, id string) (PresignedRequest, error)
+}
+
 func Get(ctx context.Context, st Store, id string) ([]byte, error) {
 	gets := []GetRequest{{Id: id}}
 	st.GetObjects(ctx, gets)
 	return gets[0].Data, gets[0].Err
 }
+
+// A Backend constructs a Store from its URL, such as
+// `s3://bucket/path`.
+type Backend func(ctx context.Context, u *url.URL) (Store, error)
+
+var backends struct {
+	sync.Mutex
+	byScheme map[string]Backend
+}
+
+// RegisterBackend makes a Backend available to Open for URLs with the
+// given scheme. Backend packages call it from their `init`.
+func RegisterBackend(scheme string, backend Backend) {
+	backends.Lock()
+	defer backends.Unlock()
+	if backends.byScheme == nil {
+		backends.byScheme = make(map[string]Backend)
+	}
+	backends.byScheme[scheme] = backend
+}
+
+// Open constructs a Store for `address` using the Backend registered
+// for its scheme.
+func Open(ctx context.Context, address string) (Store, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("Parsing store: %q: %w", address, err)
+	}
+	backends.Lock()
+	backend, ok := backends.byScheme[u.Scheme]
+	backends.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Object store: %q: unsupported scheme %s", address, u.Scheme)
+	}
+	return backend(ctx, u)
+}
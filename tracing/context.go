@@ -63,7 +63,7 @@ func StartSpan(ctx context.Context, name string) (context.Context, *SpanBuilder)
 	if ok {
 		return StartSpanInTrace(ctx, name, parent.TraceId, parent.SpanId)
 	} else {
-		return StartSpanInTrace(ctx, name, newId(), "")
+		return StartSpanInTrace(ctx, name, newTraceId(), "")
 	}
 }
 
@@ -98,10 +98,21 @@ func SubmitAll(ctx context.Context, spans []Span) {
 	}
 }
 
-func newId() string {
-	var buf [8]byte
-	if _, err := rand.Reader.Read(buf[:]); err != nil {
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Reader.Read(buf); err != nil {
 		panic(fmt.Sprintf("rand: %s", err.Error()))
 	}
-	return hex.EncodeToString(buf[:])
+	return hex.EncodeToString(buf)
+}
+
+func newId() string {
+	return randomHex(8)
+}
+
+// Trace IDs are 16 bytes, as in W3C trace-context and OpenTelemetry.
+// The first four are the current Unix time, which AWS X-Ray requires
+// of its trace IDs; see tracing/xray.
+func newTraceId() string {
+	return fmt.Sprintf("%08x", uint32(time.Now().Unix())) + randomHex(12)
 }
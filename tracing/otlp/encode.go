@@ -0,0 +1,198 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nelhage/llama/tracing"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// We encode OTLP's protobuf messages by hand, rather than depending
+// on the generated OpenTelemetry packages. Field numbers are from
+// opentelemetry/proto/{collector/trace,trace,common,resource}/v1.
+
+const (
+	// ExportTraceServiceRequest
+	requestResourceSpans = 1
+
+	// ResourceSpans
+	resourceSpansResource   = 1
+	resourceSpansScopeSpans = 2
+
+	// Resource
+	resourceAttributes = 1
+
+	// ScopeSpans
+	scopeSpansScope = 1
+	scopeSpansSpans = 2
+
+	// InstrumentationScope
+	scopeName = 1
+
+	// Span
+	spanTraceId      = 1
+	spanSpanId       = 2
+	spanParentSpanId = 4
+	spanName         = 5
+	spanKind         = 6
+	spanStartTime    = 7
+	spanEndTime      = 8
+	spanAttributes   = 9
+	spanStatus       = 15
+
+	// Status
+	statusMessage = 2
+	statusCode    = 3
+
+	// KeyValue
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	// AnyValue
+	anyValueString = 1
+	anyValueBool   = 2
+	anyValueInt    = 3
+	anyValueDouble = 4
+
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendId(b []byte, num protowire.Number, id string, n int) []byte {
+	if id == "" {
+		return b
+	}
+	raw, err := hex.DecodeString(tracing.PadId(id, n))
+	if err != nil || len(raw) != n {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, raw)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func anyValue(v interface{}) []byte {
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		b = appendString(b, anyValueString, v)
+	case bool:
+		b = protowire.AppendTag(b, anyValueBool, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case int:
+		b = protowire.AppendTag(b, anyValueInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(v))
+	case int64:
+		b = protowire.AppendTag(b, anyValueInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(v))
+	case uint64:
+		b = protowire.AppendTag(b, anyValueInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, v)
+	case time.Duration:
+		b = protowire.AppendTag(b, anyValueInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(v))
+	case float64:
+		// Spans which have been through JSON have
+		// float64 fields even if they started as integers
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			b = protowire.AppendTag(b, anyValueInt, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(int64(v)))
+		} else {
+			b = appendFixed64(b, anyValueDouble, math.Float64bits(v))
+		}
+	default:
+		b = appendString(b, anyValueString, fmt.Sprint(v))
+	}
+	return b
+}
+
+func appendAttribute(b []byte, num protowire.Number, key string, v interface{}) []byte {
+	var kv []byte
+	kv = appendString(kv, keyValueKey, key)
+	kv = appendMessage(kv, keyValueValue, anyValue(v))
+	return appendMessage(b, num, kv)
+}
+
+func encodeSpan(span *tracing.Span) []byte {
+	var b []byte
+	b = appendId(b, spanTraceId, span.TraceId, 16)
+	b = appendId(b, spanSpanId, span.SpanId, 8)
+	b = appendId(b, spanParentSpanId, span.ParentId, 8)
+	b = appendString(b, spanName, span.Name)
+	b = protowire.AppendTag(b, spanKind, protowire.VarintType)
+	b = protowire.AppendVarint(b, spanKindInternal)
+	b = appendFixed64(b, spanStartTime, uint64(span.Start.UnixNano()))
+	b = appendFixed64(b, spanEndTime, uint64(span.Start.Add(span.Duration).UnixNano()))
+
+	keys := make([]string, 0, len(span.Fields))
+	for k := range span.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b = appendAttribute(b, spanAttributes, k, span.Fields[k])
+	}
+
+	if msg, ok := span.Fields["error"]; ok {
+		var status []byte
+		status = appendString(status, statusMessage, fmt.Sprint(msg))
+		status = protowire.AppendTag(status, statusCode, protowire.VarintType)
+		status = protowire.AppendVarint(status, statusCodeError)
+		b = appendMessage(b, spanStatus, status)
+	}
+	return b
+}
+
+// encodeRequest encodes `spans` as an ExportTraceServiceRequest from
+// the service `service`.
+func encodeRequest(service string, spans []tracing.Span) []byte {
+	var resource []byte
+	resource = appendAttribute(resource, resourceAttributes, "service.name", service)
+
+	var scope []byte
+	scope = appendString(scope, scopeName, "github.com/nelhage/llama/tracing")
+
+	var scopeSpans []byte
+	scopeSpans = appendMessage(scopeSpans, scopeSpansScope, scope)
+	for i := range spans {
+		scopeSpans = appendMessage(scopeSpans, scopeSpansSpans, encodeSpan(&spans[i]))
+	}
+
+	var resourceSpans []byte
+	resourceSpans = appendMessage(resourceSpans, resourceSpansResource, resource)
+	resourceSpans = appendMessage(resourceSpans, resourceSpansScopeSpans, scopeSpans)
+
+	return appendMessage(nil, requestResourceSpans, resourceSpans)
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp implements a tracing.Tracer which exports spans to an
+// OpenTelemetry collector using the OTLP protocol, over either gRPC
+// or HTTP.
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nelhage/llama/tracing"
+)
+
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+
+	batchSize     = 512
+	batchInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+type Options struct {
+	// The collector to export to. For HTTP, the base URL, to
+	// which /v1/traces is appended; for gRPC, a host:port or URL.
+	Endpoint string
+	// ProtocolGRPC or ProtocolHTTP
+	Protocol string
+	// Headers to send with each export request
+	Headers map[string]string
+	// Use plaintext gRPC instead of TLS
+	Insecure bool
+	// The service.name resource attribute
+	ServiceName string
+}
+
+// OptionsFromEnv configures an exporter from the standard
+// OTEL_EXPORTER_OTLP_* environment variables. It returns nil if no
+// endpoint is configured.
+func OptionsFromEnv(service string) (*Options, error) {
+	opts := Options{
+		Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		Protocol:    os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"),
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+	}
+	if ep := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); ep != "" {
+		opts.Endpoint = ep
+	}
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); p != "" {
+		opts.Protocol = p
+	}
+	if opts.Endpoint == "" {
+		return nil, nil
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = service
+	}
+	if opts.Protocol == "" {
+		opts.Protocol = ProtocolGRPC
+	}
+	var err error
+	if opts.Headers, err = parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(os.Getenv("OTEL_EXPORTER_OTLP_INSECURE")) {
+	case "true", "1":
+		opts.Insecure = true
+	}
+	return &opts, nil
+}
+
+// parseHeaders parses a list of headers in the form
+// `key1=value1,key2=value2`, with URL-encoded values.
+func parseHeaders(spec string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, kv := range strings.Split(spec, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("bad OTLP header %q: expected key=value", kv)
+		}
+		val, err := url.QueryUnescape(strings.TrimSpace(kv[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("bad OTLP header %q: %w", kv, err)
+		}
+		out[strings.TrimSpace(kv[:eq])] = val
+	}
+	return out, nil
+}
+
+type transport interface {
+	export(ctx context.Context, body []byte) error
+	close() error
+}
+
+// An Exporter batches spans and sends them to an OTLP
+// collector. Spans are exported in the background; errors are logged
+// and the affected spans are dropped.
+type Exporter struct {
+	service string
+	tr      transport
+
+	mu      sync.Mutex
+	pending []tracing.Span
+
+	kick chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(opts *Options) (*Exporter, error) {
+	var tr transport
+	var err error
+	switch opts.Protocol {
+	case ProtocolGRPC:
+		tr, err = newGRPCTransport(opts)
+	case ProtocolHTTP:
+		tr, err = newHTTPTransport(opts)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol: %q", opts.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newExporter(opts.ServiceName, tr), nil
+}
+
+func newExporter(service string, tr transport) *Exporter {
+	e := &Exporter{
+		service: service,
+		tr:      tr,
+		kick:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+func (e *Exporter) Submit(span *tracing.Span) {
+	e.mu.Lock()
+	e.pending = append(e.pending, *span)
+	full := len(e.pending) >= batchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *Exporter) loop() {
+	defer e.wg.Done()
+	tick := time.NewTicker(batchInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-e.kick:
+		case <-e.done:
+			e.flush()
+			return
+		}
+		e.flush()
+	}
+}
+
+func (e *Exporter) flush() {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		batch := spans
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		spans = spans[len(batch):]
+
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		err := e.tr.export(ctx, encodeRequest(e.service, batch))
+		cancel()
+		if err != nil {
+			log.Printf("otlp: exporting %d spans: %s", len(batch), err.Error())
+		}
+	}
+}
+
+// Flush exports any pending spans before returning, for processes
+// which may be frozen before the next periodic export, as in Lambda.
+func (e *Exporter) Flush() {
+	e.flush()
+}
+
+// Close exports any pending spans and shuts down the exporter.
+func (e *Exporter) Close() error {
+	close(e.done)
+	e.wg.Wait()
+	return e.tr.close()
+}
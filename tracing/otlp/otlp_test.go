@@ -0,0 +1,194 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields decodes a protobuf message into a map from field number
+// to the raw values of that field.
+func fields(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	out := make(map[protowire.Number][]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0, "bad tag")
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		require.True(t, n > 0, "bad field %d", num)
+		b = b[n:]
+		out[num] = append(out[num], v)
+	}
+	return out
+}
+
+func decodeSpans(t *testing.T, body []byte) (string, []map[protowire.Number][]interface{}) {
+	req := fields(t, body)
+	require.Len(t, req[requestResourceSpans], 1)
+	rs := fields(t, req[requestResourceSpans][0].([]byte))
+
+	resource := fields(t, rs[resourceSpansResource][0].([]byte))
+	kv := fields(t, resource[resourceAttributes][0].([]byte))
+	assert.Equal(t, "service.name", string(kv[keyValueKey][0].([]byte)))
+	service := fields(t, kv[keyValueValue][0].([]byte))[anyValueString][0].([]byte)
+
+	ss := fields(t, rs[resourceSpansScopeSpans][0].([]byte))
+	var spans []map[protowire.Number][]interface{}
+	for _, sp := range ss[scopeSpansSpans] {
+		spans = append(spans, fields(t, sp.([]byte)))
+	}
+	return string(service), spans
+}
+
+func TestEncodeRequest(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	spans := []tracing.Span{
+		{
+			TraceId:  "0123456789abcdef",
+			SpanId:   "00f067aa0ba902b7",
+			ParentId: "",
+			Name:     "llamacc",
+			Start:    start,
+			Duration: time.Second,
+			Fields:   map[string]interface{}{"global.build_id": "abc", "size": float64(12)},
+		},
+		{
+			TraceId:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanId:   "1111111111111111",
+			ParentId: "00f067aa0ba902b7",
+			Name:     "invoke",
+			Start:    start,
+			Duration: time.Millisecond,
+			Fields:   map[string]interface{}{"error": "boom"},
+		},
+	}
+	service, got := decodeSpans(t, encodeRequest("llama", spans))
+	assert.Equal(t, "llama", service)
+	require.Len(t, got, 2)
+
+	root := got[0]
+	traceId, _ := hex.DecodeString("00000000000000000123456789abcdef")
+	assert.Equal(t, traceId, root[spanTraceId][0])
+	assert.NotContains(t, root, protowire.Number(spanParentSpanId))
+	assert.Equal(t, "llamacc", string(root[spanName][0].([]byte)))
+	assert.Equal(t, uint64(start.UnixNano()), root[spanStartTime][0])
+	assert.Equal(t, uint64(start.Add(time.Second).UnixNano()), root[spanEndTime][0])
+	require.Len(t, root[spanAttributes], 2)
+	size := fields(t, root[spanAttributes][1].([]byte))
+	assert.Equal(t, "size", string(size[keyValueKey][0].([]byte)))
+	assert.Equal(t, uint64(12), fields(t, size[keyValueValue][0].([]byte))[anyValueInt][0])
+	assert.NotContains(t, root, protowire.Number(spanStatus))
+
+	child := got[1]
+	parentId, _ := hex.DecodeString("00f067aa0ba902b7")
+	assert.Equal(t, parentId, child[spanParentSpanId][0])
+	status := fields(t, child[spanStatus][0].([]byte))
+	assert.Equal(t, uint64(statusCodeError), status[statusCode][0])
+	assert.Equal(t, "boom", string(status[statusMessage][0].([]byte)))
+}
+
+func TestHTTPExporter(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	exp, err := New(&Options{
+		Endpoint:    srv.URL,
+		Protocol:    ProtocolHTTP,
+		Headers:     map[string]string{"x-api-key": "secret"},
+		ServiceName: "test",
+	})
+	require.NoError(t, err)
+	exp.Submit(&tracing.Span{
+		TraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanId:  "00f067aa0ba902b7",
+		Name:    "span",
+		Start:   time.Now(),
+	})
+	require.NoError(t, exp.Close())
+
+	select {
+	case body := <-bodies:
+		service, spans := decodeSpans(t, body)
+		assert.Equal(t, "test", service)
+		assert.Len(t, spans, 1)
+	default:
+		t.Fatal("Close() did not flush pending spans")
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	vars := []string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_PROTOCOL",
+		"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL",
+		"OTEL_EXPORTER_OTLP_HEADERS",
+		"OTEL_EXPORTER_OTLP_INSECURE",
+		"OTEL_SERVICE_NAME",
+	}
+	for _, v := range vars {
+		defer os.Setenv(v, os.Getenv(v))
+		os.Unsetenv(v)
+	}
+
+	opts, err := OptionsFromEnv("llama")
+	require.NoError(t, err)
+	assert.Nil(t, opts)
+
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	os.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	os.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=a%3Db, team = llama")
+	opts, err = OptionsFromEnv("llama")
+	require.NoError(t, err)
+	assert.Equal(t, &Options{
+		Endpoint:    "http://collector:4318",
+		Protocol:    ProtocolHTTP,
+		Headers:     map[string]string{"api-key": "a=b", "team": "llama"},
+		ServiceName: "llama",
+	}, opts)
+
+	os.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "bogus")
+	_, err = OptionsFromEnv("llama")
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPTransport(opts *Options) (*httpTransport, error) {
+	u := opts.Endpoint
+	if !strings.Contains(u, "://") {
+		u = "https://" + u
+	}
+	if _, err := url.Parse(u); err != nil {
+		return nil, fmt.Errorf("bad OTLP endpoint: %w", err)
+	}
+	if !strings.HasSuffix(u, "/v1/traces") {
+		u = strings.TrimSuffix(u, "/") + "/v1/traces"
+	}
+	return &httpTransport{url: u, headers: opts.Headers, client: &http.Client{}}, nil
+}
+
+func (t *httpTransport) export(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+func (t *httpTransport) close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+const exportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+// rawCodec passes pre-encoded protobuf messages through gRPC
+// unchanged.
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "proto" }
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("rawCodec: unexpected type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("rawCodec: unexpected type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+type grpcTransport struct {
+	conn    *grpc.ClientConn
+	headers metadata.MD
+}
+
+func newGRPCTransport(opts *Options) (*grpcTransport, error) {
+	target := opts.Endpoint
+	insecure := opts.Insecure
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("bad OTLP endpoint: %w", err)
+		}
+		target = u.Host
+		if u.Scheme == "http" {
+			insecure = true
+		}
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	}
+	if insecure {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	}
+	conn, err := grpc.Dial(target, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcTransport{conn: conn, headers: metadata.New(opts.Headers)}, nil
+}
+
+func (t *grpcTransport) export(ctx context.Context, body []byte) error {
+	ctx = metadata.NewOutgoingContext(ctx, t.headers)
+	var reply []byte
+	return t.conn.Invoke(ctx, exportMethod, &body, &reply)
+}
+
+func (t *grpcTransport) close() error {
+	return t.conn.Close()
+}
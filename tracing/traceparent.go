@@ -0,0 +1,66 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceparentEnv is the environment variable conventionally used to
+// pass a W3C traceparent to a child process.
+const TraceparentEnv = "TRACEPARENT"
+
+// PadId left-pads a hex ID with zeros to `n` bytes. Traces started
+// by older versions of llama have 8-byte trace IDs.
+func PadId(id string, n int) string {
+	if len(id) >= 2*n {
+		return id
+	}
+	return strings.Repeat("0", 2*n-len(id)) + id
+}
+
+// Traceparent formats p as a W3C trace-context `traceparent` header
+// (https://www.w3.org/TR/trace-context/#traceparent-header), marking
+// the trace as sampled.
+func (p *Propagation) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", PadId(p.TraceId, 16), PadId(p.ParentId, 8))
+}
+
+// ParseTraceparent parses a W3C `traceparent` header.
+func ParseTraceparent(header string) (*Propagation, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return nil, fmt.Errorf("traceparent %q: bad format", header)
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return nil, fmt.Errorf("traceparent %q: bad format", header)
+	}
+	traceId, parentId := strings.ToLower(parts[1]), strings.ToLower(parts[2])
+	for _, id := range []struct {
+		hex string
+		len int
+	}{{traceId, 16}, {parentId, 8}} {
+		raw, err := hex.DecodeString(id.hex)
+		if err != nil || len(raw) != id.len {
+			return nil, fmt.Errorf("traceparent %q: bad ID %q", header, id.hex)
+		}
+		if strings.Trim(id.hex, "0") == "" {
+			return nil, fmt.Errorf("traceparent %q: invalid all-zero ID", header)
+		}
+	}
+	return &Propagation{TraceId: traceId, ParentId: parentId}, nil
+}
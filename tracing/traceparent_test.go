@@ -0,0 +1,64 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	p := &Propagation{TraceId: newTraceId(), ParentId: newId()}
+	parsed, err := ParseTraceparent(p.Traceparent())
+	require.NoError(t, err)
+	assert.Equal(t, p, parsed)
+
+	old := &Propagation{TraceId: "0123456789abcdef", ParentId: "fedcba9876543210"}
+	assert.Equal(t, "00-00000000000000000123456789abcdef-fedcba9876543210-01", old.Traceparent())
+}
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		header string
+		want   *Propagation
+	}{
+		{
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			&Propagation{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", ParentId: "00f067aa0ba902b7"},
+		},
+		{
+			"01-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-00-extra",
+			&Propagation{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", ParentId: "00f067aa0ba902b7"},
+		},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", nil},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", nil},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", nil},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", nil},
+		{"00-4bf92f3577b34da6-00f067aa0ba902b7-01", nil},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01", nil},
+		{"", nil},
+	}
+	for _, tc := range cases {
+		got, err := ParseTraceparent(tc.header)
+		if tc.want == nil {
+			assert.Error(t, err, tc.header)
+			continue
+		}
+		require.NoError(t, err, tc.header)
+		assert.Equal(t, tc.want, got)
+	}
+}
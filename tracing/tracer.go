@@ -20,6 +20,20 @@ type Tracer interface {
 	Submit(span *Span)
 }
 
+type teeTracer []Tracer
+
+func (t teeTracer) Submit(span *Span) {
+	for _, tr := range t {
+		tr.Submit(span)
+	}
+}
+
+// Tee returns a Tracer which submits every span to each of
+// `tracers`.
+func Tee(tracers ...Tracer) Tracer {
+	return teeTracer(tracers)
+}
+
 type SpanBuilder struct {
 	tracer Tracer
 	span   Span
@@ -0,0 +1,150 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xray exports spans to AWS X-Ray, by sending them as
+// segments to the X-Ray daemon. Inside Lambda, the daemon runs when
+// the function has active tracing enabled.
+package xray
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nelhage/llama/tracing"
+)
+
+// DaemonAddressEnv is set by Lambda to the X-Ray daemon's address
+// when active tracing is enabled
+const DaemonAddressEnv = "AWS_XRAY_DAEMON_ADDRESS"
+
+const header = `{"format": "json", "version": 1}` + "\n"
+
+// AddrFromEnv returns the X-Ray daemon's UDP address from
+// AWS_XRAY_DAEMON_ADDRESS, or "" if it isn't set. The variable holds
+// either HOST:PORT, or separate addresses as
+// "tcp:HOST:PORT udp:HOST:PORT".
+func AddrFromEnv() string {
+	val := os.Getenv(DaemonAddressEnv)
+	for _, addr := range strings.Fields(val) {
+		if strings.HasPrefix(addr, "udp:") {
+			return addr[len("udp:"):]
+		}
+		if !strings.HasPrefix(addr, "tcp:") {
+			return addr
+		}
+	}
+	return ""
+}
+
+// An Exporter sends spans to the X-Ray daemon over UDP. Sends are
+// fire-and-forget; the daemon batches them up to X-Ray itself.
+type Exporter struct {
+	name string
+	conn net.Conn
+}
+
+// New returns an Exporter which sends to the daemon at `addr`,
+// naming its segments `name`.
+func New(addr, name string) (*Exporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{name: name, conn: conn}, nil
+}
+
+// Export sends `spans`, which are one process's part of a trace. A
+// span whose parent is among them becomes a subsegment; the others
+// become segments, attached to their parents elsewhere in the
+// trace.
+func (e *Exporter) Export(spans []tracing.Span) error {
+	local := make(map[string]bool, len(spans))
+	for _, sp := range spans {
+		local[sp.SpanId] = true
+	}
+	for i := range spans {
+		seg, ok := toSegment(e.name, &spans[i], local[spans[i].ParentId])
+		if !ok {
+			continue
+		}
+		doc, err := json.Marshal(seg)
+		if err != nil {
+			return err
+		}
+		if _, err := e.conn.Write(append([]byte(header), doc...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) Close() error {
+	return e.conn.Close()
+}
+
+type segment struct {
+	Name      string                            `json:"name"`
+	ID        string                            `json:"id"`
+	TraceID   string                            `json:"trace_id"`
+	ParentID  string                            `json:"parent_id,omitempty"`
+	Type      string                            `json:"type,omitempty"`
+	StartTime float64                           `json:"start_time"`
+	EndTime   float64                           `json:"end_time"`
+	Metadata  map[string]map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// TraceID converts a W3C trace ID to X-Ray's format, whose first
+// component is the time the trace began. It returns "" for IDs which
+// are not 32 hex digits.
+func TraceID(id string) string {
+	if len(id) != 32 {
+		return ""
+	}
+	return fmt.Sprintf("1-%s-%s", id[:8], id[8:])
+}
+
+func epoch(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+func toSegment(name string, sp *tracing.Span, subsegment bool) (*segment, bool) {
+	trace := TraceID(sp.TraceId)
+	if trace == "" || len(sp.SpanId) != 16 {
+		return nil, false
+	}
+	seg := &segment{
+		Name:      name,
+		ID:        sp.SpanId,
+		TraceID:   trace,
+		ParentID:  sp.ParentId,
+		StartTime: epoch(sp.Start),
+		EndTime:   epoch(sp.Start.Add(sp.Duration)),
+	}
+	if subsegment {
+		seg.Name = sp.Name
+		seg.Type = "subsegment"
+	}
+	if len(sp.Fields) > 0 || !subsegment {
+		fields := map[string]interface{}{"span": sp.Name}
+		for k, v := range sp.Fields {
+			fields[k] = v
+		}
+		seg.Metadata = map[string]map[string]interface{}{"llama": fields}
+	}
+	return seg, true
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Nelson Elhage
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xray
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nelhage/llama/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrFromEnv(t *testing.T) {
+	defer os.Unsetenv(DaemonAddressEnv)
+	os.Unsetenv(DaemonAddressEnv)
+	assert.Equal(t, "", AddrFromEnv())
+	os.Setenv(DaemonAddressEnv, "169.254.79.129:2000")
+	assert.Equal(t, "169.254.79.129:2000", AddrFromEnv())
+	os.Setenv(DaemonAddressEnv, "tcp:127.0.0.1:2000 udp:127.0.0.2:2000")
+	assert.Equal(t, "127.0.0.2:2000", AddrFromEnv())
+}
+
+func TestTraceID(t *testing.T) {
+	assert.Equal(t, "1-5759e988-bd862e3fe1be46a994272793", TraceID("5759e988bd862e3fe1be46a994272793"))
+	assert.Equal(t, "", TraceID("abc"))
+}
+
+func TestExport(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	exp, err := New(pc.LocalAddr().String(), "gcc")
+	require.NoError(t, err)
+	defer exp.Close()
+
+	trace := "5759e988bd862e3fe1be46a994272793"
+	start := time.Unix(1465510280, 0)
+	spans := []tracing.Span{
+		{
+			TraceId: trace, SpanId: "2222222222222222", ParentId: "1111111111111111",
+			Name: "exec", Start: start.Add(time.Second), Duration: time.Second,
+			Fields: map[string]interface{}{"exit_status": 0},
+		},
+		{
+			TraceId: trace, SpanId: "1111111111111111", ParentId: "0000000000000000",
+			Name: "runtime.Execute", Start: start, Duration: 3 * time.Second,
+		},
+		{TraceId: "short", SpanId: "3333333333333333", Name: "dropped"},
+	}
+	require.NoError(t, exp.Export(spans))
+
+	read := func() map[string]interface{} {
+		buf := make([]byte, 64*1024)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+		msg := string(buf[:n])
+		require.True(t, strings.HasPrefix(msg, header), msg)
+		var seg map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(msg[len(header):]), &seg))
+		return seg
+	}
+
+	sub := read()
+	assert.Equal(t, "exec", sub["name"])
+	assert.Equal(t, "subsegment", sub["type"])
+	assert.Equal(t, "1-5759e988-bd862e3fe1be46a994272793", sub["trace_id"])
+	assert.Equal(t, "1111111111111111", sub["parent_id"])
+	assert.Equal(t, 1465510282.0, sub["end_time"])
+
+	seg := read()
+	assert.Equal(t, "gcc", seg["name"])
+	assert.Nil(t, seg["type"])
+	assert.Equal(t, "0000000000000000", seg["parent_id"])
+	assert.Equal(t, "runtime.Execute", seg["metadata"].(map[string]interface{})["llama"].(map[string]interface{})["span"])
+}